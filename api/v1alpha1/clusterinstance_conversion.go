@@ -0,0 +1,85 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// SchemaVersionAnnotation records the version of the ClusterInstance schema the spec is written against, a
+// ClusterInstance without the annotation is at the initial schema version
+const SchemaVersionAnnotation = Group + "/schema-version"
+
+const (
+	// InitialSchemaVersion is the version of the schema of the ClusterInstances predating the SchemaVersionAnnotation
+	InitialSchemaVersion = 1
+	// SchemaVersion is the current version of the ClusterInstance schema, it is increased along with a spec conversion
+	// whenever a change to the spec requires the stored ClusterInstances to be converted
+	SchemaVersion = 1
+)
+
+// specConversion converts a spec from a schema version to the next one
+type specConversion func(spec *ClusterInstanceSpec)
+
+// specConversions are the conversions of the spec from each older schema version to the next, indexed by the version
+// they convert from. There is none yet, the initial schema version being the current one: the SchemaVersionAnnotation
+// and the conversion on read are the plumbing for the first change of the spec requiring one.
+var specConversions = map[int]specConversion{}
+
+// Hub marks v1alpha1 as the conversion hub of the future versions of the ClusterInstance. No conversion webhook is
+// served while v1alpha1 is the only version.
+func (*ClusterInstance) Hub() {}
+
+// StoredSchemaVersion returns the schema version the spec of the ClusterInstance is written against
+func (r *ClusterInstance) StoredSchemaVersion() (int, error) {
+	value, ok := r.GetAnnotations()[SchemaVersionAnnotation]
+	if !ok {
+		return InitialSchemaVersion, nil
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || version < InitialSchemaVersion {
+		return 0, fmt.Errorf("invalid %s annotation %q", SchemaVersionAnnotation, value)
+	}
+	return version, nil
+}
+
+// ConvertSpec converts, in place, the spec of a ClusterInstance stored at an older schema version to the current
+// SchemaVersion. It returns the schema version the spec was converted from, a spec at a newer schema version than the
+// current one cannot be converted.
+func (r *ClusterInstance) ConvertSpec() (int, error) {
+	return convertSpec(r, SchemaVersion, specConversions)
+}
+
+func convertSpec(r *ClusterInstance, to int, conversions map[int]specConversion) (int, error) {
+	from, err := r.StoredSchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+	if from > to {
+		return from, fmt.Errorf("schema version %d is newer than the supported schema version %d", from, to)
+	}
+
+	for version := from; version < to; version++ {
+		conversion, ok := conversions[version]
+		if !ok {
+			return from, fmt.Errorf("no conversion from schema version %d", version)
+		}
+		conversion(&r.Spec)
+	}
+	return from, nil
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+	"testing"
+
+	aiv1beta1 "github.com/openshift/assisted-service/api/v1beta1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func Test_StoredSchemaVersion(t *testing.T) {
+	testcases := []struct {
+		name        string
+		annotations map[string]string
+		expected    int
+		expectedErr bool
+	}{
+		{name: "no annotation", expected: InitialSchemaVersion},
+		{name: "current version", annotations: map[string]string{SchemaVersionAnnotation: "1"}, expected: 1},
+		{name: "not a number", annotations: map[string]string{SchemaVersionAnnotation: "v1"}, expectedErr: true},
+		{name: "before the initial version", annotations: map[string]string{SchemaVersionAnnotation: "0"},
+			expectedErr: true},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			clusterInstance := &ClusterInstance{ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations}}
+			version, err := clusterInstance.StoredSchemaVersion()
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, version)
+		})
+	}
+}
+
+func Test_convertSpec(t *testing.T) {
+	conversions := map[int]specConversion{
+		1: func(spec *ClusterInstanceSpec) { spec.ClusterLabels = map[string]string{"converted": "1"} },
+		2: func(spec *ClusterInstanceSpec) { spec.ClusterLabels["converted"] += ",2" },
+	}
+
+	clusterInstance := &ClusterInstance{}
+	from, err := convertSpec(clusterInstance, 3, conversions)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, from)
+	assert.Equal(t, map[string]string{"converted": "1,2"}, clusterInstance.Spec.ClusterLabels)
+
+	// Only the conversions from the stored version are applied
+	clusterInstance = &ClusterInstance{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{SchemaVersionAnnotation: "2"}}}
+	clusterInstance.Spec.ClusterLabels = map[string]string{"converted": "stored"}
+	from, err = convertSpec(clusterInstance, 3, conversions)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, from)
+	assert.Equal(t, map[string]string{"converted": "stored,2"}, clusterInstance.Spec.ClusterLabels)
+
+	// A missing conversion is reported
+	_, err = convertSpec(&ClusterInstance{}, 4, conversions)
+	assert.ErrorContains(t, err, "no conversion from schema version 3")
+
+	// A newer schema version cannot be converted
+	clusterInstance = &ClusterInstance{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{SchemaVersionAnnotation: "4"}}}
+	from, err = convertSpec(clusterInstance, 3, conversions)
+	assert.ErrorContains(t, err, "schema version 4 is newer than the supported schema version 3")
+	assert.Equal(t, 4, from)
+}
+
+func Test_ConvertSpecRoundTrip(t *testing.T) {
+	replicas := int64(3)
+	testcases := []struct {
+		name string
+		spec ClusterInstanceSpec
+	}{
+		{
+			name: "single node cluster",
+			spec: ClusterInstanceSpec{
+				ClusterName:            "site-sno-du-1",
+				PullSecretRef:          corev1.LocalObjectReference{Name: "pull-secret"},
+				ClusterImageSetNameRef: "openshift-4.16",
+				BaseDomain:             "example.com",
+				ClusterType:            ClusterTypeSNO,
+				TemplateRefs:           []TemplateRef{{Name: "ai-cluster-templates-v1", Namespace: "default"}},
+				Nodes: []NodeSpec{{
+					HostName:           "node1.example.com",
+					BmcAddress:         "idrac-virtualmedia+https://192.0.2.1/redfish/v1/Systems/1",
+					BmcCredentialsName: BmcCredentialsName{Name: "bmc-secret"},
+					BootMACAddress:     "00:00:00:01:20:30",
+					TemplateRefs:       []TemplateRef{{Name: "ai-node-templates-v1", Namespace: "default"}},
+				}},
+			},
+		},
+		{
+			name: "multi node cluster with machine pools, proxy and disk encryption",
+			spec: ClusterInstanceSpec{
+				ClusterName:            "site-mno-1",
+				PullSecretRef:          corev1.LocalObjectReference{Name: "pull-secret"},
+				ClusterImageSetNameRef: "openshift-4.16",
+				BaseDomain:             "example.com",
+				ClusterType:            ClusterTypeHighlyAvailable,
+				ApiVIPs:                []string{"192.0.2.10"},
+				IngressVIPs:            []string{"192.0.2.11"},
+				MachineNetwork:         []MachineNetworkEntry{{CIDR: "192.0.2.0/24"}},
+				ClusterLabels:          map[string]string{"sites": "site-mno-1"},
+				ExtraAnnotations:       map[string]map[string]string{"BareMetalHost": {"foo": "bar"}},
+				Proxy:                  &aiv1beta1.Proxy{HTTPProxy: "http://proxy.example.com:3128"},
+				DiskEncryption:         &DiskEncryption{Type: "nbde", Tang: []TangConfig{{URL: "http://192.0.2.5"}}},
+				DeprovisionOnFailure:   true,
+				MachinePools: []MachinePoolSpec{{
+					Name:     "worker",
+					Replicas: &replicas,
+					Labels:   map[string]string{"node-role.kubernetes.io/worker": ""},
+				}},
+				Nodes: []NodeSpec{
+					{HostName: "node1.example.com", Role: "master", NodeLabels: map[string]string{"foo": "bar"}},
+					{HostName: "node2.example.com", Role: "master"},
+					{HostName: "node3.example.com", Role: "master"},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			original := &ClusterInstance{
+				TypeMeta:   metav1.TypeMeta{APIVersion: GroupVersion.String(), Kind: ClusterInstanceKind},
+				ObjectMeta: metav1.ObjectMeta{Name: tc.spec.ClusterName, Namespace: tc.spec.ClusterName},
+				Spec:       tc.spec,
+			}
+
+			// Through the JSON serialization of the API server
+			data, err := json.Marshal(original)
+			assert.Nil(t, err)
+			stored := &ClusterInstance{}
+			assert.Nil(t, json.Unmarshal(data, stored))
+			from, err := stored.ConvertSpec()
+			assert.Nil(t, err)
+			assert.Equal(t, InitialSchemaVersion, from)
+			assert.Equal(t, original, stored)
+
+			// Through unstructured objects
+			content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(original)
+			assert.Nil(t, err)
+			converted := &ClusterInstance{}
+			assert.Nil(t, runtime.DefaultUnstructuredConverter.FromUnstructured(content, converted))
+			_, err = converted.ConvertSpec()
+			assert.Nil(t, err)
+			assert.Equal(t, original, converted)
+		})
+	}
+}
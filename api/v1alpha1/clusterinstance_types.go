@@ -22,6 +22,7 @@ import (
 	hivev1 "github.com/openshift/hive/apis/hive/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
@@ -60,6 +61,14 @@ type BmcCredentialsName struct {
 	Name string `json:"name"`
 }
 
+// InlineBmcCredentials are BMC credentials provided in plain text in the ClusterInstance
+type InlineBmcCredentials struct {
+	// +required
+	Username string `json:"username"`
+	// +required
+	Password string `json:"password"`
+}
+
 // IronicInspect
 type IronicInspect string
 
@@ -69,11 +78,81 @@ type TangConfig struct {
 }
 
 type DiskEncryption struct {
+	// Type is the disk encryption type: none, tpm2 or tang. nbde is accepted as an alias of tang.
+	// +kubebuilder:validation:Enum=none;tpm2;tang;nbde
 	// +kubebuilder:default:=none
-	Type string       `json:"type,omitempty"`
+	Type string `json:"type,omitempty"`
+	// EnableOn selects the nodes whose disks are encrypted: all the nodes, the control plane nodes (masters) or the
+	// workers
+	// +kubebuilder:validation:Enum=all;masters;workers
+	// +kubebuilder:default:=all
+	// +optional
+	EnableOn string `json:"enableOn,omitempty"`
+	// Tang is the list of Tang servers, at least one is required by the tang type
+	// +optional
 	Tang []TangConfig `json:"tang,omitempty"`
 }
 
+// ChronyMakeStep configures the chrony makestep directive, the clock is stepped rather than slewed when its offset is
+// larger than the threshold
+type ChronyMakeStep struct {
+	// ThresholdSeconds is the offset, in seconds, above which the clock is stepped
+	// +kubebuilder:validation:Minimum=1
+	// +required
+	ThresholdSeconds int32 `json:"thresholdSeconds"`
+	// Limit is the number of first clock updates during which the clock can be stepped, -1 for no limit
+	// +kubebuilder:validation:Minimum=-1
+	// +required
+	Limit int32 `json:"limit"`
+}
+
+// ChronyConfig defines the chrony configuration of the nodes of the cluster
+type ChronyConfig struct {
+	// Servers are the NTP servers (hostname or IP) the nodes synchronize with
+	// +kubebuilder:validation:MinItems=1
+	// +required
+	Servers []string `json:"servers"`
+	// MakeStep configures the stepping of the clock, by default it is stepped when its offset is larger than 1 second
+	// during the first 3 clock updates
+	// +optional
+	MakeStep *ChronyMakeStep `json:"makeStep,omitempty"`
+	// ExtraDirectives are additional chrony directives appended to the configuration, e.g. "maxdistance 16"
+	// +optional
+	ExtraDirectives []string `json:"extraDirectives,omitempty"`
+}
+
+// ImageDigestSource defines the mirrors of a source repository, the images referenced by digest from the source are
+// pulled from the mirrors
+type ImageDigestSource struct {
+	// Source is the repository the images are referenced from, e.g. quay.io/openshift-release-dev/ocp-release
+	// +required
+	Source string `json:"source"`
+	// Mirrors are the repositories the images of the source are pulled from, in order of preference
+	// +kubebuilder:validation:MinItems=1
+	// +required
+	Mirrors []string `json:"mirrors"`
+}
+
+// OperatorSpec defines an operator installed from an OperatorHub catalog at install time
+type OperatorSpec struct {
+	// Name is the name of the operator package, e.g. local-storage-operator
+	// +required
+	Name string `json:"name"`
+	// Namespace is the namespace the operator is installed in
+	// +required
+	Namespace string `json:"namespace"`
+	// Channel is the channel of the operator package to subscribe to, e.g. stable
+	// +required
+	Channel string `json:"channel"`
+	// Source is the name of the CatalogSource providing the operator package, e.g. redhat-operators
+	// +required
+	Source string `json:"source"`
+	// SourceNamespace is the namespace of the CatalogSource
+	// +kubebuilder:default:=openshift-marketplace
+	// +optional
+	SourceNamespace string `json:"sourceNamespace,omitempty"`
+}
+
 // CPUPartitioningMode is used to drive how a cluster nodes CPUs are Partitioned.
 type CPUPartitioningMode string
 
@@ -91,16 +170,34 @@ type TemplateRef struct {
 	Namespace string `json:"namespace"`
 }
 
+// TemplateSelector selects the template ConfigMaps of a namespace by label
+type TemplateSelector struct {
+	// Namespace is the namespace of the selected template ConfigMaps
+	// +required
+	Namespace string `json:"namespace"`
+	// LabelSelector selects the template ConfigMaps, e.g. by a role=cluster-template label
+	// +required
+	LabelSelector metav1.LabelSelector `json:"labelSelector"`
+}
+
 // NodeSpec
 type NodeSpec struct {
 	// BmcAddress holds the URL for accessing the controller on the network.
-	// +required
-	BmcAddress string `json:"bmcAddress"`
+	// Required for the BareMetal platform, must not be set for other platforms.
+	// +optional
+	BmcAddress string `json:"bmcAddress,omitempty"`
 
 	// BmcCredentialsName is the name of the secret containing the BMC credentials (requires keys "username"
 	// and "password").
-	// +required
-	BmcCredentialsName BmcCredentialsName `json:"bmcCredentialsName"`
+	// Required for the BareMetal platform, must not be set for other platforms.
+	// +optional
+	BmcCredentialsName BmcCredentialsName `json:"bmcCredentialsName,omitempty"`
+
+	// BmcCredentials are the BMC credentials of the node in plain text, from which the controller creates the BMC
+	// credentials secret. Mutually exclusive with BmcCredentialsName, and only accepted when
+	// spec.allowInlineBmcCredentials is true.
+	// +optional
+	BmcCredentials *InlineBmcCredentials `json:"bmcCredentials,omitempty"`
 
 	// Which MAC address will PXE boot? This is optional for some
 	// types, but required for libvirt VMs driven by vbmc.
@@ -162,6 +259,12 @@ type NodeSpec struct {
 	// +optional
 	ExtraAnnotations map[string]map[string]string `json:"extraAnnotations,omitempty"`
 
+	// Annotations are set on the BareMetalHost of the node and on its Agent, e.g. to detach the BareMetalHost. The
+	// annotations managed by the controller, i.e. those of the siteconfig.open-cluster-management.io,
+	// bmac.agent-install.openshift.io and inspect.metal3.io prefixes, are reserved.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
 	// SuppressedManifests is a list of node-level manifest names to be excluded from the template rendering process
 	// +optional
 	SuppressedManifests []string `json:"suppressedManifests,omitempty"`
@@ -175,8 +278,29 @@ type NodeSpec struct {
 	// TemplateRefs is a list of references to node-level templates. A node-level template consists of a ConfigMap
 	// in which the keys of the data field represent the kind of the installation manifest(s).
 	// Node-level templates are instantiated once for each node in the ClusterInstance CR.
+	// The default node-level TemplateRefs configured on the controller are used when it is empty.
+	// +optional
+	TemplateRefs []TemplateRef `json:"templateRefs,omitempty"`
+}
+
+// MachinePoolSpec defines a pool of worker machines, rendered into a Hive MachinePool of the cluster
+type MachinePoolSpec struct {
+	// Name of the machine pool, the Hive MachinePool is named <clusterName>-<name>
 	// +required
-	TemplateRefs []TemplateRef `json:"templateRefs"`
+	Name string `json:"name"`
+
+	// Replicas is the number of machines of the machine pool
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Replicas *int64 `json:"replicas,omitempty"`
+
+	// Labels applied to the nodes of the machine pool
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Taints applied to the nodes of the machine pool
+	// +optional
+	Taints []corev1.Taint `json:"taints,omitempty"`
 }
 
 // ClusterType is a string representing the cluster type
@@ -187,6 +311,24 @@ const (
 	ClusterTypeHighlyAvailable ClusterType = "HighlyAvailable"
 )
 
+// PlatformType is a string representing the platform the cluster is installed on
+type PlatformType string
+
+const (
+	PlatformTypeBareMetal PlatformType = "BareMetal"
+	PlatformTypeNone      PlatformType = "None"
+	PlatformTypeVSphere   PlatformType = "VSphere"
+)
+
+// InstallMethod is a string representing the flow the cluster is installed with
+type InstallMethod string
+
+const (
+	InstallMethodAssisted InstallMethod = "Assisted"
+	InstallMethodIPI      InstallMethod = "IPI"
+	InstallMethodUPI      InstallMethod = "UPI"
+)
+
 // ClusterInstanceSpec defines the desired state of ClusterInstance
 type ClusterInstanceSpec struct {
 	// Desired state of cluster
@@ -200,6 +342,11 @@ type ClusterInstanceSpec struct {
 	// +required
 	PullSecretRef corev1.LocalObjectReference `json:"pullSecretRef"`
 
+	// PullSecretRefs is a list of references to additional pull secrets. When set, the registry auths of the
+	// PullSecretRef and PullSecretRefs secrets are merged into a single pull secret that is used for the installation.
+	// +optional
+	PullSecretRefs []corev1.LocalObjectReference `json:"pullSecretRefs,omitempty"`
+
 	// ClusterImageSetNameRef is the name of the ClusterImageSet resource indicating which
 	// OpenShift version to deploy.
 	// +required
@@ -230,6 +377,13 @@ type ClusterInstanceSpec struct {
 	// +optional
 	IngressVIPs []string `json:"ingressVIPs,omitempty"`
 
+	// ManageDNS creates the api and *.apps DNS records of the cluster, resolving to the API and ingress VIPs, with the
+	// DNS provider configured on the controller. The records are deleted when it is unset or the ClusterInstance is
+	// deleted.
+	// +kubebuilder:default:=false
+	// +optional
+	ManageDNS bool `json:"manageDNS,omitempty"`
+
 	// HoldInstallation will prevent installation from happening when true.
 	// Inspection and validation will proceed as usual, but once the RequirementsMet condition is true,
 	// installation will not begin until this field is set to false.
@@ -237,11 +391,62 @@ type ClusterInstanceSpec struct {
 	// +optional
 	HoldInstallation bool `json:"holdInstallation,omitempty"`
 
+	// AutoApprove approves the Agents of the nodes once they are discovered, so that the installation does not wait
+	// for a manual approval. It only applies to the assisted installation flow.
+	// +kubebuilder:default:=false
+	// +optional
+	AutoApprove bool `json:"autoApprove,omitempty"`
+
+	// PreserveOnDelete preserves the cluster when the ClusterInstance is deleted. It is passed to the
+	// ClusterDeployment, so that Hive does not deprovision the cluster, and all the rendered manifests, e.g. the
+	// ClusterDeployment, the BareMetalHosts and the ManagedCluster, are orphaned rather than deleted when the
+	// ClusterInstance is finalized.
+	// +kubebuilder:default:=false
+	// +optional
+	PreserveOnDelete bool `json:"preserveOnDelete,omitempty"`
+
+	// DeprovisionOnFailure deprovisions the partially installed cluster once its provisioning failed, by deleting
+	// its ClusterDeployment. The cluster is otherwise preserved for debugging.
+	// +kubebuilder:default:=false
+	// +optional
+	DeprovisionOnFailure bool `json:"deprovisionOnFailure,omitempty"`
+
+	// ObserveOnlyClusterDeployment renders the ClusterDeployment without creating nor updating it, the
+	// ClusterDeployment is applied by the user and its drift from the rendered manifest is reported. The other
+	// rendered manifests are applied as usual.
+	// +kubebuilder:default:=false
+	// +optional
+	ObserveOnlyClusterDeployment bool `json:"observeOnlyClusterDeployment,omitempty"`
+
+	// InfraIDHint is the desired infrastructure identifier of the cluster, a DNS-1123 label of at most 27 characters.
+	// Hive assigns the infraID of the installed clusters, the hint is available to the templates, e.g. to set the
+	// clusterMetadata of an adopted ClusterDeployment, and correlates the cluster across systems. The infraID assigned
+	// by Hive is recorded in Status.InfraID.
+	// +optional
+	InfraIDHint string `json:"infraIDHint,omitempty"`
+
+	// InstallAttemptsLimit is the maximum number of times the installation of the cluster is attempted by Hive, it is
+	// passed to the ClusterDeployment. When unset, the Hive default applies.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	InstallAttemptsLimit *int32 `json:"installAttemptsLimit,omitempty"`
+
+	// AllowInlineBmcCredentials allows the nodes to provide their BMC credentials in plain text through
+	// bmcCredentials. Referencing a BMC credentials secret through bmcCredentialsName should be preferred.
+	// +optional
+	AllowInlineBmcCredentials bool `json:"allowInlineBmcCredentials,omitempty"`
+
 	// AdditionalNTPSources is a list of NTP sources (hostname or IP) to be added to all cluster
-	// hosts. They are added to any NTP sources that were configured through other means.
+	// hosts. They are added to any NTP sources that were configured through other means. Entries must be IP addresses
+	// or DNS names.
 	// +optional
 	AdditionalNTPSources []string `json:"additionalNTPSources,omitempty"`
 
+	// ChronyConfig replaces the chrony configuration of the nodes. It is rendered into MachineConfigs that are
+	// included in the installation manifests of the cluster.
+	// +optional
+	ChronyConfig *ChronyConfig `json:"chronyConfig,omitempty"`
+
 	// MachineNetwork is the list of IP address pools for machines.
 	// +optional
 	MachineNetwork []MachineNetworkEntry `json:"machineNetwork,omitempty"`
@@ -269,15 +474,38 @@ type ClusterInstanceSpec struct {
 	// +optional
 	ClusterLabels map[string]string `json:"clusterLabels,omitempty"`
 
+	// ChargebackMetadata is the cost-center / team metadata of the cluster, used for chargeback. The metadata is
+	// applied as labels to all the rendered manifests, hence the keys and values must be valid label keys and values.
+	// The keys that must be present can be enforced by the controller (see the --required-metadata-keys flag).
+	// +optional
+	ChargebackMetadata map[string]string `json:"chargebackMetadata,omitempty"`
+
+	// TemplateValues are free-form values made available to the templates as .Values, in addition to the values
+	// derived from the ClusterInstance (clusterName, clusterNamespace, baseDomain, clusterType and
+	// clusterImageSetNameRef). The derived values are reserved, they cannot be overridden.
+	// +optional
+	TemplateValues map[string]string `json:"templateValues,omitempty"`
+
 	// InstallConfigOverrides is a Json formatted string that provides a generic way of passing
 	// install-config parameters.
 	// +optional
 	InstallConfigOverrides string `json:"installConfigOverrides,omitempty"`
 
+	// FIPS enables the FIPS validated cryptographic modules on the cluster nodes. It is rendered into the
+	// install-config and requires a release that supports FIPS mode.
+	// +kubebuilder:default:=false
+	// +optional
+	FIPS bool `json:"fips,omitempty"`
+
 	// Json formatted string containing the user overrides for the initial ignition config
 	// +optional
 	IgnitionConfigOverride string `json:"ignitionConfigOverride,omitempty"`
 
+	// BootArtifactsBaseURL is the base http or https URL of a custom location serving the boot artifacts of the
+	// discovery image. The nodes fetch the rootfs from <bootArtifactsBaseURL>/rootfs.img instead of the image service.
+	// +optional
+	BootArtifactsBaseURL string `json:"bootArtifactsBaseURL,omitempty"`
+
 	// DiskEncryption is the configuration to enable/disable disk encryption for cluster nodes.
 	// +optional
 	DiskEncryption *DiskEncryption `json:"diskEncryption,omitempty"`
@@ -286,6 +514,22 @@ type ClusterInstanceSpec struct {
 	// +optional
 	Proxy *aiv1beta1.Proxy `json:"proxy,omitempty"`
 
+	// DisableNoProxyExpansion disables the expansion of the proxy noProxy list. When a proxy is configured, the machine,
+	// cluster and service networks and the cluster domain are otherwise appended to the noProxy list.
+	// +kubebuilder:default:=false
+	// +optional
+	DisableNoProxyExpansion bool `json:"disableNoProxyExpansion,omitempty"`
+
+	// ImageDigestSources are the mirrors of the image repositories, for disconnected installations. They are rendered
+	// into the install-config and into an ImageDigestMirrorSet included in the installation manifests of the cluster.
+	// +optional
+	ImageDigestSources []ImageDigestSource `json:"imageDigestSources,omitempty"`
+
+	// InstallOperators are the operators installed at install time. They are rendered into the Namespace, OperatorGroup
+	// and Subscription manifests included in the installation manifests of the cluster.
+	// +optional
+	InstallOperators []OperatorSpec `json:"installOperators,omitempty"`
+
 	// ExtraManifestsRefs is list of config map references containing additional manifests to be applied to the cluster.
 	// +optional
 	ExtraManifestsRefs []corev1.LocalObjectReference `json:"extraManifestsRefs,omitempty"`
@@ -308,16 +552,66 @@ type ClusterInstanceSpec struct {
 	// +optional
 	ClusterType ClusterType `json:"clusterType,omitempty"`
 
+	// PlatformType is the platform the cluster is installed on, defaults to BareMetal. The BareMetalHost resources
+	// (and BMC handling) are only rendered for the BareMetal platform.
+	// +kubebuilder:validation:Enum=BareMetal;None;VSphere
+	// +optional
+	PlatformType PlatformType `json:"platformType,omitempty"`
+
+	// InstallMethod is the flow the cluster is installed with, defaults to Assisted. It selects the default templates
+	// configured on the controller for the install method and the validation rules of the node and platform fields:
+	// the IPI method requires the BareMetal or VSphere platform, the UPI method the None platform.
+	// +kubebuilder:validation:Enum=Assisted;IPI;UPI
+	// +optional
+	InstallMethod InstallMethod `json:"installMethod,omitempty"`
+
 	// TemplateRefs is a list of references to cluster-level templates. A cluster-level template consists of a ConfigMap
 	// in which the keys of the data field represent the kind of the installation manifest(s).
 	// Cluster-level templates are instantiated once per cluster (ClusterInstance CR).
-	// +required
-	TemplateRefs []TemplateRef `json:"templateRefs"`
+	// The default cluster-level TemplateRefs configured on the controller are used when it is empty.
+	// +optional
+	TemplateRefs []TemplateRef `json:"templateRefs,omitempty"`
+
+	// TemplateSelector selects cluster-level templates by label, in addition to the TemplateRefs. The selected
+	// ConfigMaps are resolved at reconcile time and appended to the TemplateRefs in the order of their names.
+	// +optional
+	TemplateSelector *TemplateSelector `json:"templateSelector,omitempty"`
 
 	// CABundle is a reference to a config map containing the new bundle of trusted certificates for the host.
 	// +optional
 	CaBundleRef *corev1.LocalObjectReference `json:"caBundleRef,omitempty"`
 
+	// InfraEnvRef references a pre-existing InfraEnv, in the namespace named after the cluster where the manifests are
+	// rendered, to which the nodes are bound instead of an InfraEnv generated from the templates. The InfraEnv manifest
+	// is not rendered when it is set, and the NMStateConfigs of the nodes are labelled with "nmstate-label: <name>",
+	// which the nmStateConfigLabelSelector of the InfraEnv must select.
+	// +optional
+	InfraEnvRef *corev1.LocalObjectReference `json:"infraEnvRef,omitempty"`
+
+	// DependsOn is a list of ClusterInstances, in the namespace of the ClusterInstance, that must be provisioned before
+	// this cluster is installed. The rendering of the manifests is held, and the WaitingForDependencies condition is
+	// set, until all of them report a completed provisioning.
+	// +optional
+	DependsOn []corev1.LocalObjectReference `json:"dependsOn,omitempty"`
+
+	// ValidationOverrides is a list of names of validations to skip when validating the ClusterInstance, intended for
+	// environments that intentionally use nonconventional values. Supported values are: baseDomain and ntpSources.
+	// Skipped validations are reported in the ValidationsSkipped condition.
+	// +optional
+	ValidationOverrides []string `json:"validationOverrides,omitempty"`
+
+	// TTLSecondsAfterFinished limits the lifetime of a ClusterInstance that has finished provisioning unsuccessfully
+	// (Failed) or whose cluster has been deprovisioned. Once the ClusterInstance has been in such a terminal state for
+	// longer than the TTL, it is deleted. If unset, the ClusterInstance is retained indefinitely.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+
+	// MachinePools is a list of worker machine pools of the cluster, each rendered into a Hive MachinePool referencing
+	// the ClusterDeployment of the cluster.
+	// +optional
+	MachinePools []MachinePoolSpec `json:"machinePools,omitempty"`
+
 	// +required
 	Nodes []NodeSpec `json:"nodes"`
 }
@@ -368,6 +662,101 @@ type ManifestReference struct {
 	Message string `json:"message,omitempty"`
 }
 
+// RenderedTemplateHash records the hash of the last applied rendering of a TemplateRef
+type RenderedTemplateHash struct {
+	// TemplateRef is the reference of the rendered template ConfigMap
+	// +required
+	TemplateRef TemplateRef `json:"templateRef"`
+	// HostName is the host name of the node the node-level templates were rendered for,
+	// empty for cluster-level templates
+	// +optional
+	HostName string `json:"hostName,omitempty"`
+	// Hash of the template ConfigMap content and of the rendering inputs
+	// +required
+	Hash string `json:"hash"`
+}
+
+// TemplateRenderStatus reports the result of rendering a TemplateRef
+type TemplateRenderStatus struct {
+	// Name of the template ConfigMap
+	// +required
+	Name string `json:"name"`
+	// Namespace of the template ConfigMap
+	// +required
+	Namespace string `json:"namespace"`
+	// HostName is the host name of the node the node-level templates were rendered for,
+	// empty for cluster-level templates
+	// +optional
+	HostName string `json:"hostName,omitempty"`
+	// RenderedObjects is the number of manifests rendered from the templates
+	// +optional
+	RenderedObjects int `json:"renderedObjects"`
+	// Empty is set when none of the templates rendered any content and the template ConfigMap does not allow it
+	// +optional
+	Empty bool `json:"empty,omitempty"`
+	// Error encountered while rendering the templates, empty if they were rendered successfully
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// NodePowerState is a string representing the power state of a node
+type NodePowerState string
+
+const (
+	NodePowerStateOn      NodePowerState = "On"
+	NodePowerStateOff     NodePowerState = "Off"
+	NodePowerStateUnknown NodePowerState = "Unknown"
+)
+
+// HostValidation is a host validation reported by the Agent of a node
+type HostValidation struct {
+	// ID of the validation, e.g. has-min-cpu-cores
+	// +required
+	ID string `json:"id"`
+
+	// Message describing the result of the validation
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// NodeStatus defines the observed state of a node of the ClusterInstance
+type NodeStatus struct {
+	// HostName of the node
+	// +required
+	HostName string `json:"hostName"`
+
+	// BareMetalHostState is the provisioning state of the node's BareMetalHost, e.g. registering, inspecting,
+	// provisioning, provisioned.
+	// +optional
+	BareMetalHostState string `json:"bareMetalHostState,omitempty"`
+
+	// PowerState is the power state of the node as reported by its BareMetalHost, Unknown until the BareMetalHost
+	// is registered.
+	// +kubebuilder:validation:Enum=On;Off;Unknown
+	// +optional
+	PowerState NodePowerState `json:"powerState,omitempty"`
+
+	// ErrorMessage is the last error reported for the node, e.g. by its BareMetalHost.
+	// +optional
+	ErrorMessage string `json:"errorMessage,omitempty"`
+
+	// AgentStage is the current installation stage of the node's Agent, for the assisted installation flow.
+	// +optional
+	AgentStage string `json:"agentStage,omitempty"`
+
+	// AgentInstallationPercentage is the estimated installation progress of the node's Agent, in percent.
+	// +optional
+	AgentInstallationPercentage int64 `json:"agentInstallationPercentage,omitempty"`
+
+	// FailedValidations are the host validations of the node's Agent that are failing.
+	// +optional
+	FailedValidations []HostValidation `json:"failedValidations,omitempty"`
+
+	// List of conditions pertaining to the node.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
 // ClusterInstanceStatus defines the observed state of ClusterInstance
 type ClusterInstanceStatus struct {
 	// Important: Run "make" to regenerate code after modifying this file
@@ -381,6 +770,9 @@ type ClusterInstanceStatus struct {
 	ClusterDeploymentRef *corev1.LocalObjectReference `json:"clusterDeploymentRef,omitempty"`
 
 	// List of hive status conditions associated with the ClusterDeployment resource.
+	// The conditions are kept in a stable order: the install conditions (ClusterInstallRequirementsMet,
+	// ClusterInstallCompleted, ClusterInstallFailed, ClusterInstallStopped), the diagnostic conditions and the
+	// additional conditions configured on the controller, followed by any other condition in alphabetical order.
 	// +optional
 	DeploymentConditions []hivev1.ClusterDeploymentCondition `json:"deploymentConditions,omitempty"`
 
@@ -388,6 +780,60 @@ type ClusterInstanceStatus struct {
 	// +optional
 	ManifestsRendered []ManifestReference `json:"manifestsRendered,omitempty"`
 
+	// List of the observed states of the nodes.
+	// +optional
+	Nodes []NodeStatus `json:"nodes,omitempty"`
+
+	// List of the template hashes of the last applied rendering, templates whose content and rendering inputs are
+	// unchanged are not re-rendered.
+	// +optional
+	RenderedTemplateHashes []RenderedTemplateHash `json:"renderedTemplateHashes,omitempty"`
+
+	// List of the render results of each TemplateRef, for the cluster and for each node
+	// +optional
+	TemplateStatus []TemplateRenderStatus `json:"templateStatus,omitempty"`
+
+	// LastInstallSecretsRegeneration is the time at which the rendered install secrets were last regenerated after
+	// an installation failure caused by an expired token.
+	// +optional
+	LastInstallSecretsRegeneration *metav1.Time `json:"lastInstallSecretsRegeneration,omitempty"`
+
+	// LastAppliedSpec is the ClusterInstance spec that was last successfully rendered and applied, without the
+	// passwords of the inline BMC credentials. It is used to detect the spec changes made while provisioning.
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	LastAppliedSpec *runtime.RawExtension `json:"lastAppliedSpec,omitempty"`
+
+	// DNSRecords are the DNS records created for the cluster when manageDNS is set, each as "<name> <type>". The
+	// records that are no longer desired, e.g. after a change of the VIPs, are deleted.
+	// +optional
+	DNSRecords []string `json:"dnsRecords,omitempty"`
+
+	// APIURL is the URL of the spoke cluster's API server, set once provisioning has started.
+	// +optional
+	APIURL string `json:"apiURL,omitempty"`
+
+	// ConsoleURL is the URL of the spoke cluster's web console, set once reported by the ClusterDeployment.
+	// +optional
+	ConsoleURL string `json:"consoleURL,omitempty"`
+
+	// InstallLogsRef is a reference to the object holding the installation logs, set once reported by the
+	// ClusterDeployment: the cluster install, e.g. the AgentClusterInstall, of the agent and image-based installs, or
+	// the Hive ClusterProvision of the last installation attempt otherwise.
+	// +optional
+	InstallLogsRef *corev1.TypedLocalObjectReference `json:"installLogsRef,omitempty"`
+
+	// InstalledVersion is the OpenShift version of the spoke cluster, as reported by the ClusterDeployment, set once
+	// the cluster is provisioned.
+	// +optional
+	InstalledVersion string `json:"installedVersion,omitempty"`
+
+	// InfraID is the infrastructure identifier of the spoke cluster, as reported by the ClusterDeployment, set once
+	// the cluster is provisioned.
+	// +optional
+	InfraID string `json:"infraID,omitempty"`
+
 	// Track the observed generation to avoid unnecessary reconciles
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 }
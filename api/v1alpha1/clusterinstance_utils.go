@@ -30,3 +30,50 @@ func (node *NodeSpec) ExtraAnnotationSearch(kind string, cluster *ClusterInstanc
 	}
 	return cluster.ExtraAnnotationSearch(kind)
 }
+
+// GetPlatformType returns the platform type of the cluster, defaulting to BareMetal
+func (c *ClusterInstanceSpec) GetPlatformType() PlatformType {
+	if c.PlatformType == "" {
+		return PlatformTypeBareMetal
+	}
+	return c.PlatformType
+}
+
+// GetInstallMethod returns the install method of the cluster, defaulting to Assisted
+func (c *ClusterInstanceSpec) GetInstallMethod() InstallMethod {
+	if c.InstallMethod == "" {
+		return InstallMethodAssisted
+	}
+	return c.InstallMethod
+}
+
+// GetBmcCredentialsName returns the name of the BMC credentials secret of the node, i.e. the name of the secret created
+// from the inline BMC credentials when set
+func (node *NodeSpec) GetBmcCredentialsName() string {
+	if node.BmcCredentials != nil {
+		return node.InlineBmcCredentialsSecretName()
+	}
+	return node.BmcCredentialsName.Name
+}
+
+// InlineBmcCredentialsSecretName returns the name of the secret created from the inline BMC credentials of the node
+func (node *NodeSpec) InlineBmcCredentialsSecretName() string {
+	return node.HostName + "-bmc-credentials"
+}
+
+// MACAddresses returns the boot MAC address of the node followed by the MAC addresses of the interfaces of its node
+// network, as declared
+func (node *NodeSpec) MACAddresses() []string {
+	var macAddresses []string
+	if node.BootMACAddress != "" {
+		macAddresses = append(macAddresses, node.BootMACAddress)
+	}
+	if node.NodeNetwork != nil {
+		for _, intf := range node.NodeNetwork.Interfaces {
+			if intf != nil {
+				macAddresses = append(macAddresses, intf.MacAddress)
+			}
+		}
+	}
+	return macAddresses
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// The following constants define the DiskEncryption types
+const (
+	// DiskEncryptionTypeNone disables disk encryption
+	DiskEncryptionTypeNone = "none"
+	// DiskEncryptionTypeTPM2 binds the disk encryption key to the TPM 2.0 chip of the host
+	DiskEncryptionTypeTPM2 = "tpm2"
+	// DiskEncryptionTypeTang retrieves the disk encryption key from Tang servers
+	DiskEncryptionTypeTang = "tang"
+	// DiskEncryptionTypeNBDE (Network-Bound Disk Encryption) is an alias of DiskEncryptionTypeTang
+	DiskEncryptionTypeNBDE = "nbde"
+)
+
+// exclusiveFieldsRule describes a spec field that cannot be set together with another spec field (or value)
+type exclusiveFieldsRule struct {
+	// field is the path of the field that is rejected when the rule is violated
+	field *field.Path
+	// detail explains which field (or value) the field conflicts with
+	detail string
+	// violated returns true when the conflicting fields are both set
+	violated func(spec *ClusterInstanceSpec) bool
+}
+
+var specPath = field.NewPath("spec")
+
+// exclusiveFieldsRules is the list of mutual-exclusion rules of the ClusterInstance spec.
+// New exclusivity rules should be added here, so that they are enforced by both the webhook and the reconciler.
+var exclusiveFieldsRules = []exclusiveFieldsRule{
+	{
+		field:  specPath.Child("apiVIPs"),
+		detail: "must not be set when spec.clusterType is SNO",
+		violated: func(spec *ClusterInstanceSpec) bool {
+			return spec.ClusterType == ClusterTypeSNO && len(spec.ApiVIPs) > 0
+		},
+	},
+	{
+		field:  specPath.Child("ingressVIPs"),
+		detail: "must not be set when spec.clusterType is SNO",
+		violated: func(spec *ClusterInstanceSpec) bool {
+			return spec.ClusterType == ClusterTypeSNO && len(spec.IngressVIPs) > 0
+		},
+	},
+	{
+		field:  specPath.Child("diskEncryption", "tang"),
+		detail: "must not be set when spec.diskEncryption.type is " + DiskEncryptionTypeNone,
+		violated: func(spec *ClusterInstanceSpec) bool {
+			return spec.DiskEncryption != nil && spec.DiskEncryption.Type == DiskEncryptionTypeNone &&
+				len(spec.DiskEncryption.Tang) > 0
+		},
+	},
+	{
+		field:  specPath.Child("diskEncryption", "tang"),
+		detail: "must not be set when spec.diskEncryption.type is " + DiskEncryptionTypeTPM2,
+		violated: func(spec *ClusterInstanceSpec) bool {
+			return spec.DiskEncryption != nil && spec.DiskEncryption.Type == DiskEncryptionTypeTPM2 &&
+				len(spec.DiskEncryption.Tang) > 0
+		},
+	},
+	{
+		field:  specPath.Child("bootArtifactsBaseURL"),
+		detail: "must not be set when spec.infraEnvRef is set, it only applies to the generated InfraEnv",
+		violated: func(spec *ClusterInstanceSpec) bool {
+			return spec.InfraEnvRef != nil && spec.BootArtifactsBaseURL != ""
+		},
+	},
+}
+
+// exclusiveNodeFieldsRule describes a node field that cannot be set together with another node or spec field
+type exclusiveNodeFieldsRule struct {
+	// field is the name of the node field that is rejected when the rule is violated
+	field string
+	// detail explains which field (or value) the field conflicts with
+	detail string
+	// violated returns true when the conflicting fields are both set
+	violated func(spec *ClusterInstanceSpec, node *NodeSpec) bool
+}
+
+// exclusiveNodeFieldsRules is the list of mutual-exclusion rules of the ClusterInstance nodes, they are checked for
+// each node.
+var exclusiveNodeFieldsRules = []exclusiveNodeFieldsRule{
+	{
+		field:  "bmcCredentials",
+		detail: "must not be set together with bmcCredentialsName",
+		violated: func(spec *ClusterInstanceSpec, node *NodeSpec) bool {
+			return node.BmcCredentials != nil && node.BmcCredentialsName.Name != ""
+		},
+	},
+	{
+		field:  "bmcCredentials",
+		detail: "must not be set unless spec.allowInlineBmcCredentials is true",
+		violated: func(spec *ClusterInstanceSpec, node *NodeSpec) bool {
+			return node.BmcCredentials != nil && !spec.AllowInlineBmcCredentials
+		},
+	},
+}
+
+// ValidateMutuallyExclusiveFields checks the ClusterInstance spec against the mutual-exclusion rules and reports all
+// violations at once, returns nil if there are none
+func ValidateMutuallyExclusiveFields(spec *ClusterInstanceSpec) error {
+	var errs field.ErrorList
+	for _, rule := range exclusiveFieldsRules {
+		if rule.violated(spec) {
+			errs = append(errs, field.Forbidden(rule.field, rule.detail))
+		}
+	}
+	for i := range spec.Nodes {
+		for _, rule := range exclusiveNodeFieldsRules {
+			if rule.violated(spec, &spec.Nodes[i]) {
+				errs = append(errs, field.Forbidden(specPath.Child("nodes").Index(i).Child(rule.field), rule.detail))
+			}
+		}
+	}
+	return errs.ToAggregate()
+}
+
+// ValidateSpec runs the validations of the spec enforced by the validating webhook on create and update. The reconciler
+// replays them, along with ValidateNodesUpdate against the last applied spec, to catch a ClusterInstance admitted while
+// the webhook was bypassed.
+func ValidateSpec(spec *ClusterInstanceSpec) error {
+	return ValidateMutuallyExclusiveFields(spec)
+}
+
+// ProvisionedConditionType is the type of the ClusterInstance condition reporting the provisioning of the cluster, the
+// ProvisioningInProgressReason and ProvisioningCompletedReason are the reasons it reports once the installation has
+// started. The conditions of the controller are defined from them.
+const (
+	ProvisionedConditionType     = "Provisioned"
+	ProvisioningInProgressReason = "InProgress"
+	ProvisioningCompletedReason  = "Completed"
+)
+
+// isProvisioningStarted returns true if the Provisioned condition of the ClusterInstance reports a started installation
+func isProvisioningStarted(clusterInstance *ClusterInstance) bool {
+	provisioned := meta.FindStatusCondition(clusterInstance.Status.Conditions, ProvisionedConditionType)
+	return provisioned != nil &&
+		(provisioned.Reason == ProvisioningInProgressReason || provisioned.Reason == ProvisioningCompletedReason)
+}
+
+// ValidateNodesUpdate rejects adding or removing nodes, and changing the host name identifying a node, once the
+// provisioning of the ClusterInstance has started. The nodes are identified by their host name, so that they may be
+// reordered, and other node fields may still be edited in place. Once the cluster is installed, worker nodes may be
+// added to be installed day-2.
+func ValidateNodesUpdate(oldClusterInstance, newClusterInstance *ClusterInstance) error {
+	if !isProvisioningStarted(oldClusterInstance) {
+		return nil
+	}
+
+	oldNodes, newNodes := oldClusterInstance.Spec.Nodes, newClusterInstance.Spec.Nodes
+	nodesPath := specPath.Child("nodes")
+	oldHostNames, newHostNames := map[string]bool{}, map[string]bool{}
+	for i := range oldNodes {
+		oldHostNames[oldNodes[i].HostName] = true
+	}
+	for i := range newNodes {
+		newHostNames[newNodes[i].HostName] = true
+	}
+
+	var errs field.ErrorList
+	for i := range oldNodes {
+		if !newHostNames[oldNodes[i].HostName] {
+			errs = append(errs, field.Forbidden(nodesPath, fmt.Sprintf(
+				"node %q cannot be removed or renamed once provisioning has started", oldNodes[i].HostName)))
+		}
+	}
+	provisioned := meta.FindStatusCondition(oldClusterInstance.Status.Conditions, ProvisionedConditionType)
+	for i := range newNodes {
+		switch {
+		case oldHostNames[newNodes[i].HostName]:
+		case provisioned.Reason != ProvisioningCompletedReason:
+			errs = append(errs, field.Forbidden(nodesPath.Index(i), fmt.Sprintf(
+				"node %q cannot be added until the cluster is provisioned", newNodes[i].HostName)))
+		case newNodes[i].Role != "worker":
+			errs = append(errs, field.Forbidden(nodesPath.Index(i).Child("role"),
+				"only worker nodes can be added once the cluster is provisioned"))
+		}
+	}
+	return errs.ToAggregate()
+}
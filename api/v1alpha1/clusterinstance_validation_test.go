@@ -0,0 +1,298 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_ValidateMutuallyExclusiveFields(t *testing.T) {
+	testcases := []struct {
+		name     string
+		spec     ClusterInstanceSpec
+		expected []string
+	}{
+		{
+			name: "no exclusive fields set",
+			spec: ClusterInstanceSpec{
+				ClusterType:    ClusterTypeHighlyAvailable,
+				ApiVIPs:        []string{"192.0.2.10"},
+				IngressVIPs:    []string{"192.0.2.11"},
+				DiskEncryption: &DiskEncryption{Type: "nbde", Tang: []TangConfig{{URL: "http://192.0.2.5"}}},
+			},
+		},
+		{
+			name: "apiVIPs with SNO cluster type",
+			spec: ClusterInstanceSpec{
+				ClusterType: ClusterTypeSNO,
+				ApiVIPs:     []string{"192.0.2.10"},
+			},
+			expected: []string{"spec.apiVIPs: Forbidden: must not be set when spec.clusterType is SNO"},
+		},
+		{
+			name: "ingressVIPs with SNO cluster type",
+			spec: ClusterInstanceSpec{
+				ClusterType: ClusterTypeSNO,
+				IngressVIPs: []string{"192.0.2.11"},
+			},
+			expected: []string{"spec.ingressVIPs: Forbidden: must not be set when spec.clusterType is SNO"},
+		},
+		{
+			name: "tang servers with TPM disk encryption",
+			spec: ClusterInstanceSpec{
+				DiskEncryption: &DiskEncryption{Type: DiskEncryptionTypeTPM2, Tang: []TangConfig{{URL: "http://192.0.2.5"}}},
+			},
+			expected: []string{"spec.diskEncryption.tang: Forbidden: must not be set when spec.diskEncryption.type is tpm2"},
+		},
+		{
+			name: "tang servers with disk encryption disabled",
+			spec: ClusterInstanceSpec{
+				DiskEncryption: &DiskEncryption{Type: DiskEncryptionTypeNone, Tang: []TangConfig{{URL: "http://192.0.2.5"}}},
+			},
+			expected: []string{
+				"spec.diskEncryption.tang: Forbidden: must not be set when spec.diskEncryption.type is none"},
+		},
+		{
+			name: "inline BMC credentials when allowed",
+			spec: ClusterInstanceSpec{
+				AllowInlineBmcCredentials: true,
+				Nodes: []NodeSpec{{
+					HostName:       "node1",
+					BmcCredentials: &InlineBmcCredentials{Username: "admin", Password: "password"},
+				}},
+			},
+		},
+		{
+			name: "inline BMC credentials together with bmcCredentialsName",
+			spec: ClusterInstanceSpec{
+				AllowInlineBmcCredentials: true,
+				Nodes: []NodeSpec{
+					{HostName: "node1", BmcCredentialsName: BmcCredentialsName{Name: "bmc"}},
+					{
+						HostName:           "node2",
+						BmcCredentialsName: BmcCredentialsName{Name: "bmc"},
+						BmcCredentials:     &InlineBmcCredentials{Username: "admin", Password: "password"},
+					},
+				},
+			},
+			expected: []string{
+				"spec.nodes[1].bmcCredentials: Forbidden: must not be set together with bmcCredentialsName"},
+		},
+		{
+			name: "inline BMC credentials when not allowed",
+			spec: ClusterInstanceSpec{
+				Nodes: []NodeSpec{{
+					HostName:       "node1",
+					BmcCredentials: &InlineBmcCredentials{Username: "admin", Password: "password"},
+				}},
+			},
+			expected: []string{
+				"spec.nodes[0].bmcCredentials: Forbidden: must not be set unless spec.allowInlineBmcCredentials is true"},
+		},
+		{
+			name: "boot artifacts base URL together with an InfraEnv reference",
+			spec: ClusterInstanceSpec{
+				InfraEnvRef:          &corev1.LocalObjectReference{Name: "shared-infraenv"},
+				BootArtifactsBaseURL: "https://images.example.com/boot-artifacts",
+			},
+			expected: []string{
+				"spec.bootArtifactsBaseURL: Forbidden: must not be set when spec.infraEnvRef is set"},
+		},
+		{
+			name: "all violations are reported at once",
+			spec: ClusterInstanceSpec{
+				ClusterType:    ClusterTypeSNO,
+				ApiVIPs:        []string{"192.0.2.10"},
+				IngressVIPs:    []string{"192.0.2.11"},
+				DiskEncryption: &DiskEncryption{Type: DiskEncryptionTypeNone, Tang: []TangConfig{{URL: "http://192.0.2.5"}}},
+			},
+			expected: []string{
+				"spec.apiVIPs: Forbidden: must not be set when spec.clusterType is SNO",
+				"spec.ingressVIPs: Forbidden: must not be set when spec.clusterType is SNO",
+				"spec.diskEncryption.tang: Forbidden: must not be set when spec.diskEncryption.type is none",
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateMutuallyExclusiveFields(&tc.spec)
+			if len(tc.expected) == 0 {
+				assert.Nil(t, err)
+				return
+			}
+			assert.NotNil(t, err)
+			for _, msg := range tc.expected {
+				assert.Contains(t, err.Error(), msg)
+			}
+		})
+	}
+}
+
+func Test_ValidateNodesUpdate(t *testing.T) {
+	newClusterInstance := func(reason string, hostNames ...string) *ClusterInstance {
+		clusterInstance := &ClusterInstance{}
+		for _, hostName := range hostNames {
+			clusterInstance.Spec.Nodes = append(clusterInstance.Spec.Nodes, NodeSpec{HostName: hostName})
+		}
+		if reason != "" {
+			clusterInstance.Status.Conditions = []metav1.Condition{{
+				Type:   ProvisionedConditionType,
+				Status: metav1.ConditionFalse,
+				Reason: reason,
+			}}
+		}
+		return clusterInstance
+	}
+
+	testcases := []struct {
+		name      string
+		reason    string
+		oldNodes  []string
+		newNodes  []string
+		expected  string
+		bmcChange bool
+		addedRole string
+	}{
+		{
+			name:     "node added before provisioning",
+			oldNodes: []string{"node1"},
+			newNodes: []string{"node1", "node2"},
+		},
+		{
+			name:     "node added while provisioning is pending",
+			reason:   "Unknown",
+			oldNodes: []string{"node1"},
+			newNodes: []string{"node1", "node2"},
+		},
+		{
+			name:     "node added during provisioning",
+			reason:   "InProgress",
+			oldNodes: []string{"node1"},
+			newNodes: []string{"node1", "node2"},
+			expected: `spec.nodes[1]: Forbidden: node "node2" cannot be added until the cluster is provisioned`,
+		},
+		{
+			name:     "node removed after provisioning",
+			reason:   "Completed",
+			oldNodes: []string{"node1", "node2", "node3"},
+			newNodes: []string{"node1", "node2"},
+			expected: `spec.nodes: Forbidden: node "node3" cannot be removed or renamed once provisioning has started`,
+		},
+		{
+			name:      "worker added after provisioning",
+			reason:    "Completed",
+			oldNodes:  []string{"node1", "node2", "node3"},
+			newNodes:  []string{"node1", "node2", "node3", "node4"},
+			addedRole: "worker",
+		},
+		{
+			name:      "control-plane node added after provisioning",
+			reason:    "Completed",
+			oldNodes:  []string{"node1", "node2", "node3"},
+			newNodes:  []string{"node1", "node2", "node3", "node4"},
+			addedRole: "master",
+			expected:  "spec.nodes[3].role: Forbidden: only worker nodes can be added once the cluster is provisioned",
+		},
+		{
+			name:      "worker inserted before the existing nodes after provisioning",
+			reason:    "Completed",
+			oldNodes:  []string{"node1"},
+			newNodes:  []string{"node2", "node1"},
+			addedRole: "worker",
+		},
+		{
+			name:     "nodes reordered during provisioning",
+			reason:   "InProgress",
+			oldNodes: []string{"node1", "node2", "node3"},
+			newNodes: []string{"node3", "node1", "node2"},
+		},
+		{
+			name:      "node renamed after provisioning",
+			reason:    "Completed",
+			oldNodes:  []string{"node1", "node2"},
+			newNodes:  []string{"node1", "node3"},
+			addedRole: "worker",
+			expected:  `spec.nodes: Forbidden: node "node2" cannot be removed or renamed once provisioning has started`,
+		},
+		{
+			name:      "node edited in place after provisioning",
+			reason:    "Completed",
+			oldNodes:  []string{"node1"},
+			newNodes:  []string{"node1"},
+			bmcChange: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			oldClusterInstance := newClusterInstance(tc.reason, tc.oldNodes...)
+			updated := newClusterInstance(tc.reason, tc.newNodes...)
+			if tc.bmcChange {
+				updated.Spec.Nodes[0].BmcAddress = "redfish-virtualmedia://192.0.2.20/redfish/v1/Systems/1"
+			}
+			for i := range updated.Spec.Nodes {
+				if !slices.Contains(tc.oldNodes, updated.Spec.Nodes[i].HostName) {
+					updated.Spec.Nodes[i].Role = tc.addedRole
+				}
+			}
+
+			_, err := updated.ValidateUpdate(oldClusterInstance)
+			if tc.expected == "" {
+				assert.Nil(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.expected)
+		})
+	}
+}
+
+func Test_ClusterInstanceWebhook(t *testing.T) {
+	clusterInstance := &ClusterInstance{Spec: ClusterInstanceSpec{ClusterType: ClusterTypeSNO}}
+
+	_, err := clusterInstance.ValidateCreate()
+	assert.Nil(t, err)
+
+	updated := clusterInstance.DeepCopy()
+	updated.Spec.ApiVIPs = []string{"192.0.2.10"}
+	_, err = updated.ValidateUpdate(clusterInstance)
+	assert.ErrorContains(t, err, "spec.apiVIPs")
+
+	_, err = updated.ValidateCreate()
+	assert.ErrorContains(t, err, "spec.apiVIPs")
+
+	_, err = updated.ValidateDelete()
+	assert.Nil(t, err)
+
+	// The metadata of a ClusterInstance with an invalid spec can be updated, e.g. to remove its finalizer
+	relabeled := updated.DeepCopy()
+	relabeled.Labels = map[string]string{"foo": "bar"}
+	_, err = relabeled.ValidateUpdate(updated)
+	assert.Nil(t, err)
+
+	deleted := updated.DeepCopy()
+	deleted.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	deleted.Spec.ApiVIPs = []string{"192.0.2.11"}
+	_, err = deleted.ValidateUpdate(updated)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// log is for logging in this package.
+var clusterinstancelog = logf.Log.WithName("clusterinstance-resource")
+
+// SetupWebhookWithManager will setup the manager to manage the webhooks
+func (r *ClusterInstance) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-siteconfig-open-cluster-management-io-v1alpha1-clusterinstance,mutating=false,failurePolicy=fail,sideEffects=None,groups=siteconfig.open-cluster-management.io,resources=clusterinstances,verbs=create;update,versions=v1alpha1,name=vclusterinstance.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &ClusterInstance{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *ClusterInstance) ValidateCreate() (admission.Warnings, error) {
+	clusterinstancelog.Info("validate create", "name", r.Name)
+
+	return nil, ValidateSpec(&r.Spec)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *ClusterInstance) ValidateUpdate(old runtime.Object) (admission.Warnings, error) {
+	clusterinstancelog.Info("validate update", "name", r.Name)
+
+	oldClusterInstance, ok := old.(*ClusterInstance)
+	if !ok {
+		return nil, fmt.Errorf("expected a ClusterInstance, got %T", old)
+	}
+
+	// Only a changed spec is validated, so that the metadata of a ClusterInstance, e.g. its finalizers, can always be
+	// updated, in particular when it is being deleted
+	if r.DeletionTimestamp != nil || equality.Semantic.DeepEqual(oldClusterInstance.Spec, r.Spec) {
+		return nil, nil
+	}
+
+	if err := ValidateSpec(&r.Spec); err != nil {
+		return nil, err
+	}
+	return nil, ValidateNodesUpdate(oldClusterInstance, r)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *ClusterInstance) ValidateDelete() (admission.Warnings, error) {
+	clusterinstancelog.Info("validate delete", "name", r.Name)
+
+	return nil, nil
+}
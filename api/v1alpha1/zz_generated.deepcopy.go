@@ -44,6 +44,51 @@ func (in *BmcCredentialsName) DeepCopy() *BmcCredentialsName {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChronyConfig) DeepCopyInto(out *ChronyConfig) {
+	*out = *in
+	if in.Servers != nil {
+		in, out := &in.Servers, &out.Servers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MakeStep != nil {
+		in, out := &in.MakeStep, &out.MakeStep
+		*out = new(ChronyMakeStep)
+		**out = **in
+	}
+	if in.ExtraDirectives != nil {
+		in, out := &in.ExtraDirectives, &out.ExtraDirectives
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChronyConfig.
+func (in *ChronyConfig) DeepCopy() *ChronyConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ChronyConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChronyMakeStep) DeepCopyInto(out *ChronyMakeStep) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChronyMakeStep.
+func (in *ChronyMakeStep) DeepCopy() *ChronyMakeStep {
+	if in == nil {
+		return nil
+	}
+	out := new(ChronyMakeStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterInstance) DeepCopyInto(out *ClusterInstance) {
 	*out = *in
@@ -107,6 +152,11 @@ func (in *ClusterInstanceList) DeepCopyObject() runtime.Object {
 func (in *ClusterInstanceSpec) DeepCopyInto(out *ClusterInstanceSpec) {
 	*out = *in
 	out.PullSecretRef = in.PullSecretRef
+	if in.PullSecretRefs != nil {
+		in, out := &in.PullSecretRefs, &out.PullSecretRefs
+		*out = make([]v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
 	if in.ApiVIPs != nil {
 		in, out := &in.ApiVIPs, &out.ApiVIPs
 		*out = make([]string, len(*in))
@@ -117,11 +167,21 @@ func (in *ClusterInstanceSpec) DeepCopyInto(out *ClusterInstanceSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.InstallAttemptsLimit != nil {
+		in, out := &in.InstallAttemptsLimit, &out.InstallAttemptsLimit
+		*out = new(int32)
+		**out = **in
+	}
 	if in.AdditionalNTPSources != nil {
 		in, out := &in.AdditionalNTPSources, &out.AdditionalNTPSources
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.ChronyConfig != nil {
+		in, out := &in.ChronyConfig, &out.ChronyConfig
+		*out = new(ChronyConfig)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.MachineNetwork != nil {
 		in, out := &in.MachineNetwork, &out.MachineNetwork
 		*out = make([]MachineNetworkEntry, len(*in))
@@ -162,6 +222,20 @@ func (in *ClusterInstanceSpec) DeepCopyInto(out *ClusterInstanceSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.ChargebackMetadata != nil {
+		in, out := &in.ChargebackMetadata, &out.ChargebackMetadata
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.TemplateValues != nil {
+		in, out := &in.TemplateValues, &out.TemplateValues
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.DiskEncryption != nil {
 		in, out := &in.DiskEncryption, &out.DiskEncryption
 		*out = new(DiskEncryption)
@@ -172,6 +246,18 @@ func (in *ClusterInstanceSpec) DeepCopyInto(out *ClusterInstanceSpec) {
 		*out = new(v1beta1.Proxy)
 		**out = **in
 	}
+	if in.ImageDigestSources != nil {
+		in, out := &in.ImageDigestSources, &out.ImageDigestSources
+		*out = make([]ImageDigestSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.InstallOperators != nil {
+		in, out := &in.InstallOperators, &out.InstallOperators
+		*out = make([]OperatorSpec, len(*in))
+		copy(*out, *in)
+	}
 	if in.ExtraManifestsRefs != nil {
 		in, out := &in.ExtraManifestsRefs, &out.ExtraManifestsRefs
 		*out = make([]v1.LocalObjectReference, len(*in))
@@ -187,11 +273,43 @@ func (in *ClusterInstanceSpec) DeepCopyInto(out *ClusterInstanceSpec) {
 		*out = make([]TemplateRef, len(*in))
 		copy(*out, *in)
 	}
+	if in.TemplateSelector != nil {
+		in, out := &in.TemplateSelector, &out.TemplateSelector
+		*out = new(TemplateSelector)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.CaBundleRef != nil {
 		in, out := &in.CaBundleRef, &out.CaBundleRef
 		*out = new(v1.LocalObjectReference)
 		**out = **in
 	}
+	if in.InfraEnvRef != nil {
+		in, out := &in.InfraEnvRef, &out.InfraEnvRef
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.ValidationOverrides != nil {
+		in, out := &in.ValidationOverrides, &out.ValidationOverrides
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TTLSecondsAfterFinished != nil {
+		in, out := &in.TTLSecondsAfterFinished, &out.TTLSecondsAfterFinished
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MachinePools != nil {
+		in, out := &in.MachinePools, &out.MachinePools
+		*out = make([]MachinePoolSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Nodes != nil {
 		in, out := &in.Nodes, &out.Nodes
 		*out = make([]NodeSpec, len(*in))
@@ -240,6 +358,42 @@ func (in *ClusterInstanceStatus) DeepCopyInto(out *ClusterInstanceStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]NodeStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RenderedTemplateHashes != nil {
+		in, out := &in.RenderedTemplateHashes, &out.RenderedTemplateHashes
+		*out = make([]RenderedTemplateHash, len(*in))
+		copy(*out, *in)
+	}
+	if in.TemplateStatus != nil {
+		in, out := &in.TemplateStatus, &out.TemplateStatus
+		*out = make([]TemplateRenderStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastInstallSecretsRegeneration != nil {
+		in, out := &in.LastInstallSecretsRegeneration, &out.LastInstallSecretsRegeneration
+		*out = (*in).DeepCopy()
+	}
+	if in.LastAppliedSpec != nil {
+		in, out := &in.LastAppliedSpec, &out.LastAppliedSpec
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DNSRecords != nil {
+		in, out := &in.DNSRecords, &out.DNSRecords
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.InstallLogsRef != nil {
+		in, out := &in.InstallLogsRef, &out.InstallLogsRef
+		*out = new(v1.TypedLocalObjectReference)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterInstanceStatus.
@@ -287,6 +441,56 @@ func (in *DiskEncryption) DeepCopy() *DiskEncryption {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostValidation) DeepCopyInto(out *HostValidation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostValidation.
+func (in *HostValidation) DeepCopy() *HostValidation {
+	if in == nil {
+		return nil
+	}
+	out := new(HostValidation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageDigestSource) DeepCopyInto(out *ImageDigestSource) {
+	*out = *in
+	if in.Mirrors != nil {
+		in, out := &in.Mirrors, &out.Mirrors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageDigestSource.
+func (in *ImageDigestSource) DeepCopy() *ImageDigestSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageDigestSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InlineBmcCredentials) DeepCopyInto(out *InlineBmcCredentials) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InlineBmcCredentials.
+func (in *InlineBmcCredentials) DeepCopy() *InlineBmcCredentials {
+	if in == nil {
+		return nil
+	}
+	out := new(InlineBmcCredentials)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MachineNetworkEntry) DeepCopyInto(out *MachineNetworkEntry) {
 	*out = *in
@@ -302,6 +506,40 @@ func (in *MachineNetworkEntry) DeepCopy() *MachineNetworkEntry {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachinePoolSpec) DeepCopyInto(out *MachinePoolSpec) {
+	*out = *in
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Taints != nil {
+		in, out := &in.Taints, &out.Taints
+		*out = make([]v1.Taint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachinePoolSpec.
+func (in *MachinePoolSpec) DeepCopy() *MachinePoolSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MachinePoolSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ManifestReference) DeepCopyInto(out *ManifestReference) {
 	*out = *in
@@ -327,6 +565,11 @@ func (in *ManifestReference) DeepCopy() *ManifestReference {
 func (in *NodeSpec) DeepCopyInto(out *NodeSpec) {
 	*out = *in
 	out.BmcCredentialsName = in.BmcCredentialsName
+	if in.BmcCredentials != nil {
+		in, out := &in.BmcCredentials, &out.BmcCredentials
+		*out = new(InlineBmcCredentials)
+		**out = **in
+	}
 	if in.RootDeviceHints != nil {
 		in, out := &in.RootDeviceHints, &out.RootDeviceHints
 		*out = new(metal3_iov1alpha1.RootDeviceHints)
@@ -362,6 +605,13 @@ func (in *NodeSpec) DeepCopyInto(out *NodeSpec) {
 			(*out)[key] = outVal
 		}
 	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.SuppressedManifests != nil {
 		in, out := &in.SuppressedManifests, &out.SuppressedManifests
 		*out = make([]string, len(*in))
@@ -384,6 +634,64 @@ func (in *NodeSpec) DeepCopy() *NodeSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeStatus) DeepCopyInto(out *NodeStatus) {
+	*out = *in
+	if in.FailedValidations != nil {
+		in, out := &in.FailedValidations, &out.FailedValidations
+		*out = make([]HostValidation, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeStatus.
+func (in *NodeStatus) DeepCopy() *NodeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperatorSpec) DeepCopyInto(out *OperatorSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperatorSpec.
+func (in *OperatorSpec) DeepCopy() *OperatorSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(OperatorSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RenderedTemplateHash) DeepCopyInto(out *RenderedTemplateHash) {
+	*out = *in
+	out.TemplateRef = in.TemplateRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RenderedTemplateHash.
+func (in *RenderedTemplateHash) DeepCopy() *RenderedTemplateHash {
+	if in == nil {
+		return nil
+	}
+	out := new(RenderedTemplateHash)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServiceNetworkEntry) DeepCopyInto(out *ServiceNetworkEntry) {
 	*out = *in
@@ -428,3 +736,34 @@ func (in *TemplateRef) DeepCopy() *TemplateRef {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateSelector) DeepCopyInto(out *TemplateSelector) {
+	*out = *in
+	in.LabelSelector.DeepCopyInto(&out.LabelSelector)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateSelector.
+func (in *TemplateSelector) DeepCopy() *TemplateSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateRenderStatus) DeepCopyInto(out *TemplateRenderStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateRenderStatus.
+func (in *TemplateRenderStatus) DeepCopy() *TemplateRenderStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateRenderStatus)
+	in.DeepCopyInto(out)
+	return out
+}
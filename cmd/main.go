@@ -21,6 +21,8 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	bmh_v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
@@ -44,6 +46,7 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
@@ -80,12 +83,106 @@ func init() {
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var leaseDuration time.Duration
+	var renewDeadline time.Duration
+	var retryPeriod time.Duration
 	var probeAddr string
+	var requiredMetadataKeys string
+	var provisioningFailureGracePeriod time.Duration
+	var pauseConfigMapName string
+	var pauseConfigMapNamespace string
+	var additionalCDConditions string
+	var watchNamespaces string
+	var defaultClusterTemplateRefs string
+	var defaultNodeTemplateRefs string
+	var installMethodClusterTemplateRefs string
+	var installMethodNodeTemplateRefs string
+	var hiveCRDsTimeout time.Duration
+	var allowMissingHiveCRDs bool
+	var registryPreflight bool
+	var registryPreflightInterval time.Duration
+	var conditionProbeInterval time.Duration
+	var manifestsDumpDir string
+	var reconcileBreakerThreshold int
+	var reconcileBreakerBackoff time.Duration
+	var reasonAnnotations string
+	var installReports bool
+	var installSecretsExpiryReasons string
+	var dnsProviderName string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.DurationVar(&leaseDuration, "leader-elect-lease-duration", 15*time.Second,
+		"The duration that the non-leader candidates wait after observing a leadership renewal before attempting to "+
+			"acquire the leadership.")
+	flag.DurationVar(&renewDeadline, "leader-elect-renew-deadline", 10*time.Second,
+		"The duration that the leader retries refreshing its leadership before giving it up.")
+	flag.DurationVar(&retryPeriod, "leader-elect-retry-period", 2*time.Second,
+		"The duration the leader election clients wait between tries of actions.")
+	flag.StringVar(&requiredMetadataKeys, "required-metadata-keys", "",
+		"Comma-separated list of chargebackMetadata keys every ClusterInstance must define before it is rendered.")
+	flag.DurationVar(&provisioningFailureGracePeriod, "provisioning-failure-grace-period",
+		controller.DefaultProvisioningFailureGracePeriod,
+		"The duration for which an installation failure must persist before the ClusterInstance is marked as failed, "+
+			"0 marks it as failed as soon as the failure is reported.")
+	flag.StringVar(&pauseConfigMapName, "pause-configmap-name", "siteconfig-pause",
+		"The name of the ConfigMap pausing all reconciliation when its \"paused\" key is set to \"true\".")
+	flag.StringVar(&pauseConfigMapNamespace, "pause-configmap-namespace", "",
+		"The namespace of the pause ConfigMap, defaults to the SiteConfig namespace.")
+	flag.StringVar(&additionalCDConditions, "additional-cd-conditions", "",
+		"Comma-separated list of ClusterDeployment condition types mirrored into the ClusterInstance status, in "+
+			"addition to the install and diagnostic conditions.")
+	flag.StringVar(&watchNamespaces, "watch-namespaces", "",
+		"Comma-separated list of the namespaces in which ClusterInstances and ClusterDeployments are reconciled, "+
+			"all namespaces are reconciled when empty.")
+	flag.StringVar(&defaultClusterTemplateRefs, "default-cluster-template-refs", "",
+		"Comma-separated list of <namespace>/<name> cluster-level templates used by the Assisted ClusterInstances that "+
+			"do not define their own.")
+	flag.StringVar(&defaultNodeTemplateRefs, "default-node-template-refs", "",
+		"Comma-separated list of <namespace>/<name> node-level templates used by the nodes of the Assisted "+
+			"ClusterInstances that do not define their own.")
+	flag.StringVar(&installMethodClusterTemplateRefs, "install-method-cluster-template-refs", "",
+		"Comma-separated list of <installMethod>=<namespace>/<name> cluster-level templates used by the "+
+			"ClusterInstances of the install method that do not define their own, instead of the default ones.")
+	flag.StringVar(&installMethodNodeTemplateRefs, "install-method-node-template-refs", "",
+		"Comma-separated list of <installMethod>=<namespace>/<name> node-level templates used by the nodes of the "+
+			"ClusterInstances of the install method that do not define their own, instead of the default ones.")
+	flag.DurationVar(&hiveCRDsTimeout, "hive-crds-timeout", 0,
+		"The duration to wait at startup for the Hive CRDs to be installed, they are checked once when zero.")
+	flag.BoolVar(&allowMissingHiveCRDs, "allow-missing-hive-crds", false,
+		"Start without the ClusterDeployment reconciler, instead of exiting, when the Hive CRDs are not installed.")
+	flag.BoolVar(&registryPreflight, "registry-preflight", false,
+		"Check that the release image registry is reachable and accepts the pull secret before rendering, this "+
+			"requires network access to the registries.")
+	flag.DurationVar(&registryPreflightInterval, "registry-preflight-interval", ci.RegistryCheckInterval,
+		"The interval at which the registries are checked again by the registry preflight, the result of a check is "+
+			"shared by the ClusterInstances using the same registry and credentials.")
+	flag.DurationVar(&conditionProbeInterval, "condition-probe-interval", 10*time.Minute,
+		"The minimum interval at which the probe time of the unchanged deploymentConditions is refreshed, they are "+
+			"only refreshed along with other status changes when zero.")
+	flag.StringVar(&manifestsDumpDir, "manifests-dump-dir", "",
+		"Debugging aid writing the rendered manifests, with the Secret values redacted, to "+
+			"<dir>/<namespace>/<name> in addition to applying them, they are not written when empty.")
+	flag.IntVar(&reconcileBreakerThreshold, "reconcile-breaker-threshold", 5,
+		"The number of consecutive failed reconciles after which a ClusterInstance is backed off, it is never "+
+			"backed off when zero.")
+	flag.DurationVar(&reconcileBreakerBackoff, "reconcile-breaker-backoff", 30*time.Minute,
+		"The interval at which a backed off ClusterInstance is reconciled until a reconcile succeeds.")
+	flag.StringVar(&reasonAnnotations, "reason-annotations", "",
+		"Comma-separated list of <reason>=<annotation> mappings, the message of the ClusterDeployment condition "+
+			"reporting the reason is exposed in the annotation of the ClusterInstance.")
+	flag.BoolVar(&installReports, "install-reports", false,
+		"Generate the <name>-install-report ConfigMap summarizing the provisioning outcome of a ClusterInstance once "+
+			"it is provisioned or failed.")
+	flag.StringVar(&installSecretsExpiryReasons, "install-secrets-expiry-reasons",
+		strings.Join(controller.DefaultInstallSecretsExpiryReasons, ","),
+		"Comma-separated list of the reasons of the ClusterDeployment install failures caused by an expired install "+
+			"or ignition token, the install secrets of the ClusterInstance are regenerated before the retry.")
+	flag.StringVar(&dnsProviderName, "dns-provider", "",
+		"The provider of the DNS records of the ClusterInstances setting manageDNS, only \"logging\" is supported. "+
+			"The records are not managed when empty.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -94,8 +191,34 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	defaultTemplateRefs, err := parseDefaultTemplateRefs(defaultClusterTemplateRefs, defaultNodeTemplateRefs,
+		installMethodClusterTemplateRefs, installMethodNodeTemplateRefs)
+	if err != nil {
+		setupLog.Error(err, "unable to parse the default template references")
+		os.Exit(1)
+	}
+
+	cdReasonAnnotations, err := controller.ParseReasonAnnotations(splitList(reasonAnnotations))
+	if err != nil {
+		setupLog.Error(err, "unable to parse the reason annotations")
+		os.Exit(1)
+	}
+
+	// The SiteConfig namespace, holding the default templates, the namespaces of the default TemplateRefs and the
+	// pause ConfigMap namespace are always cached
+	watchedNamespaces := controller.WatchedNamespaces(splitList(watchNamespaces))
+	if len(watchedNamespaces) > 0 {
+		setupLog.Info("Restricting reconciliation to the watched namespaces", "namespaces", watchedNamespaces)
+	}
+
+	cacheNamespaces := watchedNamespaces.CacheNamespaces(append(defaultTemplateRefs.Namespaces(),
+		getSiteConfigNamespace(setupLog), pauseConfigMapNamespace)...)
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
+		Cache: cache.Options{
+			DefaultNamespaces: cacheNamespaces,
+		},
 		Metrics: server.Options{
 			BindAddress: metricsAddr,
 		},
@@ -104,6 +227,9 @@ func main() {
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "manager." + v1alpha1.Group,
+		LeaseDuration:          &leaseDuration,
+		RenewDeadline:          &renewDeadline,
+		RetryPeriod:            &retryPeriod,
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -132,26 +258,135 @@ func main() {
 		os.Exit(1)
 	}
 
+	pauseSwitch := &controller.PauseSwitch{Name: pauseConfigMapName, Namespace: pauseConfigMapNamespace}
+	if pauseSwitch.Namespace == "" {
+		pauseSwitch.Namespace = getSiteConfigNamespace(setupLog)
+	}
+
+	var registryChecker *ci.RegistryChecker
+	if registryPreflight {
+		registryChecker = ci.NewRegistryChecker(ci.RegistryCheckTimeout, registryPreflightInterval)
+	}
+
+	dnsProvider, err := newDNSProvider(dnsProviderName, ctrl.Log.WithName("controllers").WithName("DNSProvider"))
+	if err != nil {
+		setupLog.Error(err, "unable to configure the DNS provider")
+		os.Exit(1)
+	}
+
 	log := ctrl.Log.WithName("controllers").WithName("ClusterInstance")
+	// The ClusterInstances reference templates from namespaces that may not be cached, they are read from the API server
+	clusterInstanceClient := controller.NewUncachedNamespacesClient(mgr.GetClient(), mgr.GetAPIReader(), cacheNamespaces)
 	if err = (&controller.ClusterInstanceReconciler{
-		Client:     mgr.GetClient(),
-		Scheme:     mgr.GetScheme(),
-		Recorder:   mgr.GetEventRecorderFor("ClusterInstance-controller"),
-		Log:        log,
-		TmplEngine: ci.NewTemplateEngine(log.WithName("TemplateEngine")),
+		Client:               clusterInstanceClient,
+		APIReader:            mgr.GetAPIReader(),
+		Scheme:               mgr.GetScheme(),
+		Recorder:             mgr.GetEventRecorderFor("ClusterInstance-controller"),
+		Log:                  log,
+		TmplEngine:           ci.NewTemplateEngine(log.WithName("TemplateEngine")),
+		RequiredMetadataKeys: splitList(requiredMetadataKeys),
+		PauseSwitch:          pauseSwitch,
+		WatchedNamespaces:    watchedNamespaces,
+		DefaultTemplateRefs:  defaultTemplateRefs,
+		RegistryChecker:      registryChecker,
+		ManifestsDumpDir:     manifestsDumpDir,
+		DNSProvider:          dnsProvider,
+		CircuitBreaker: &controller.CircuitBreaker{
+			Threshold: reconcileBreakerThreshold,
+			Backoff:   reconcileBreakerBackoff,
+		},
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ClusterInstance")
 		os.Exit(1)
 	}
 
-	if err = (&controller.ClusterDeploymentReconciler{
+	// The ClusterDeployment reconciler cannot watch ClusterDeployments without the Hive CRDs
+	missingHiveKinds, err := controller.WaitForKinds(context.TODO(), mgr.GetRESTMapper(), hiveCRDsTimeout,
+		controller.HiveKinds...)
+	if err != nil {
+		setupLog.Error(err, "unable to check whether the Hive CRDs are installed")
+		os.Exit(1)
+	}
+	switch {
+	case len(missingHiveKinds) == 0:
+		if err = (&controller.ClusterDeploymentReconciler{
+			Client:                         mgr.GetClient(),
+			Log:                            ctrl.Log.WithName("controllers").WithName("ClusterDeploymentReconciler"),
+			Scheme:                         mgr.GetScheme(),
+			ProvisioningFailureGracePeriod: provisioningFailureGracePeriod,
+			PauseSwitch:                    pauseSwitch,
+			AdditionalConditionTypes:       cdConditionTypes(splitList(additionalCDConditions)),
+			WatchedNamespaces:              watchedNamespaces,
+			ConditionProbeInterval:         conditionProbeInterval,
+			ReasonAnnotations:              cdReasonAnnotations,
+			InstallReports:                 installReports,
+			InstallSecretsExpiryReasons:    splitList(installSecretsExpiryReasons),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ClusterDeploymentReconciler")
+			os.Exit(1)
+		}
+	case allowMissingHiveCRDs:
+		setupLog.Info("The Hive CRDs are not installed, the ClusterDeployment reconciler is disabled: the "+
+			"provisioning status of the ClusterInstances is not reported", "missing", missingHiveKinds)
+	default:
+		setupLog.Error(fmt.Errorf("the Hive CRDs are not installed, missing kinds: %v", missingHiveKinds),
+			"unable to create controller, install Hive or set --allow-missing-hive-crds",
+			"controller", "ClusterDeploymentReconciler")
+		os.Exit(1)
+	}
+	// The import status of the clusters is only reported when the ManagedCluster CRD is installed
+	missingManagedClusterKinds, err := controller.MissingKinds(mgr.GetRESTMapper(), controller.ManagedClusterKinds...)
+	if err != nil {
+		setupLog.Error(err, "unable to check whether the ManagedCluster CRD is installed")
+		os.Exit(1)
+	}
+	if len(missingManagedClusterKinds) == 0 {
+		if err = (&controller.ManagedClusterReconciler{
+			Client: mgr.GetClient(),
+			Log:    ctrl.Log.WithName("controllers").WithName("ManagedClusterReconciler"),
+			Scheme: mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ManagedClusterReconciler")
+			os.Exit(1)
+		}
+	} else {
+		setupLog.Info("The ManagedCluster CRD is not installed, the ManagedCluster reconciler is disabled: the "+
+			"import status of the ClusterInstances is not reported", "missing", missingManagedClusterKinds)
+	}
+	// The validations and installation progress of the nodes are only reported when the Agent CRD is installed
+	missingAgentKinds, err := controller.MissingKinds(mgr.GetRESTMapper(), controller.AgentKinds...)
+	if err != nil {
+		setupLog.Error(err, "unable to check whether the Agent CRD is installed")
+		os.Exit(1)
+	}
+	if len(missingAgentKinds) == 0 {
+		if err = (&controller.AgentReconciler{
+			Client: mgr.GetClient(),
+			Log:    ctrl.Log.WithName("controllers").WithName("AgentReconciler"),
+			Scheme: mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "AgentReconciler")
+			os.Exit(1)
+		}
+	} else {
+		setupLog.Info("The Agent CRD is not installed, the Agent reconciler is disabled: the validations and "+
+			"installation progress of the nodes are not reported", "missing", missingAgentKinds)
+	}
+	if err = (&controller.BareMetalHostReconciler{
 		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("ClusterDeploymentReconciler"),
+		Log:    ctrl.Log.WithName("controllers").WithName("BareMetalHostReconciler"),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "ClusterDeploymentReconciler")
+		setupLog.Error(err, "unable to create controller", "controller", "BareMetalHostReconciler")
 		os.Exit(1)
 	}
+	// The validating webhook requires serving certificates, it is therefore only enabled on demand
+	if os.Getenv("ENABLE_WEBHOOKS") == "true" {
+		if err = (&v1alpha1.ClusterInstance{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ClusterInstance")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -178,6 +413,77 @@ func getSiteConfigNamespace(log logr.Logger) string {
 	return namespace
 }
 
+// splitList splits a comma-separated flag value into its non-empty, trimmed items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// cdConditionTypes converts the given names into ClusterDeployment condition types
+func cdConditionTypes(names []string) []hivev1.ClusterDeploymentConditionType {
+	conditionTypes := make([]hivev1.ClusterDeploymentConditionType, 0, len(names))
+	for _, name := range names {
+		conditionTypes = append(conditionTypes, hivev1.ClusterDeploymentConditionType(name))
+	}
+	return conditionTypes
+}
+
+// parseDefaultTemplateRefs parses the comma-separated lists of default cluster-level and node-level templates, and of
+// the default cluster-level and node-level templates of the install methods
+func parseDefaultTemplateRefs(
+	clusterTemplateRefs, nodeTemplateRefs, installMethodClusterTemplateRefs, installMethodNodeTemplateRefs string,
+) (controller.DefaultTemplateRefs, error) {
+	var (
+		defaults controller.DefaultTemplateRefs
+		err      error
+	)
+	if defaults.Cluster, err = controller.ParseTemplateRefs(splitList(clusterTemplateRefs)); err != nil {
+		return defaults, err
+	}
+	if defaults.Node, err = controller.ParseTemplateRefs(splitList(nodeTemplateRefs)); err != nil {
+		return defaults, err
+	}
+
+	clusterRefs, err := controller.ParseInstallMethodTemplateRefs(splitList(installMethodClusterTemplateRefs))
+	if err != nil {
+		return defaults, err
+	}
+	nodeRefs, err := controller.ParseInstallMethodTemplateRefs(splitList(installMethodNodeTemplateRefs))
+	if err != nil {
+		return defaults, err
+	}
+	if len(clusterRefs) == 0 && len(nodeRefs) == 0 {
+		return defaults, nil
+	}
+	defaults.InstallMethods = map[v1alpha1.InstallMethod]controller.DefaultTemplateRefs{}
+	for installMethod, templateRefs := range clusterRefs {
+		defaults.InstallMethods[installMethod] = controller.DefaultTemplateRefs{Cluster: templateRefs}
+	}
+	for installMethod, templateRefs := range nodeRefs {
+		methodDefaults := defaults.InstallMethods[installMethod]
+		methodDefaults.Node = templateRefs
+		defaults.InstallMethods[installMethod] = methodDefaults
+	}
+	return defaults, nil
+}
+
+// newDNSProvider returns the DNS provider of the given name, nil if the name is empty
+func newDNSProvider(name string, log logr.Logger) (ci.DNSProvider, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case "logging":
+		return &ci.LoggingDNSProvider{Log: log}, nil
+	default:
+		return nil, fmt.Errorf("unknown DNS provider %q", name)
+	}
+}
+
 func initConfigMapTemplates(ctx context.Context, c client.Client, log logr.Logger) error {
 	templates := make(map[string]map[string]string, 4)
 	templates[AssistedInstallerClusterTemplates] = assistedinstaller.GetClusterTemplates()
@@ -0,0 +1,400 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	aiv1beta1 "github.com/openshift/assisted-service/api/v1beta1"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	ci "github.com/stolostron/siteconfig/internal/controller/clusterinstance"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// NodeAnnotationsAnnotation records on an Agent the comma-separated keys of the node annotations set on it, so that
+// the annotations removed from the node are removed from the Agent
+const NodeAnnotationsAnnotation = v1alpha1.Group + "/node-annotations"
+
+// failedValidationStatuses are the statuses of the Agent host validations that are reported as failed
+var failedValidationStatuses = map[string]bool{
+	"failure": true,
+	"error":   true,
+}
+
+//+kubebuilder:rbac:groups=agent-install.openshift.io,resources=agents,verbs=list;watch;patch
+
+// AgentReconciler reconciles an Agent object to
+// update the validation and installation progress of the corresponding ClusterInstance node
+type AgentReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+func (r *AgentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// Get the Agent CR
+	agent := &aiv1beta1.Agent{}
+	if err := r.Get(ctx, req.NamespacedName, agent); err != nil {
+		if errors.IsNotFound(err) {
+			r.Log.Info("Agent not found", "name", req.NamespacedName)
+			return completed(), nil
+		}
+		r.Log.Error(err, "Failed to get Agent")
+		// This is likely a case where the API is down, so requeue and try again shortly
+		return requeueWithError(err)
+	}
+
+	// The Agent is bound to the ClusterInstance through the InfraEnv it booted from
+	infraEnv := &aiv1beta1.InfraEnv{}
+	infraEnvKey := types.NamespacedName{
+		Name:      agent.GetLabels()[aiv1beta1.InfraEnvNameLabel],
+		Namespace: agent.Namespace,
+	}
+	if err := r.Get(ctx, infraEnvKey, infraEnv); err != nil {
+		if errors.IsNotFound(err) {
+			r.Log.Info("InfraEnv of the Agent not found", "name", agent.Name, "InfraEnv", infraEnvKey)
+			return completed(), nil
+		}
+		return requeueWithError(err)
+	}
+
+	var (
+		clusterInstance *v1alpha1.ClusterInstance
+		node            *v1alpha1.NodeSpec
+		err             error
+	)
+	if _, _, owned := ownerClusterInstance(infraEnv); owned {
+		// Fetch ClusterInstance associated with the InfraEnv object
+		clusterInstance, err = getOwnerClusterInstance(ctx, r.Client, r.Log, infraEnv)
+		if err != nil {
+			return requeueWithError(err)
+		}
+		if clusterInstance == nil {
+			return completed(), nil
+		}
+
+		// Do not act on a ghost, i.e. a ClusterInstance of the same name that does not own the InfraEnv
+		if !isOwnerUIDMatching(infraEnv, clusterInstance) {
+			r.Log.Info("ClusterInstance UID does not match the InfraEnv owner, skipping",
+				"Agent", req.NamespacedName, "ClusterInstance UID", clusterInstance.UID)
+			return completed(), nil
+		}
+		node = findAgentNodeSpec(clusterInstance, agent)
+	} else {
+		// The InfraEnv is not generated, it may be referenced by the InfraEnvRef of several ClusterInstances
+		if clusterInstance, node, err = r.findReferencingClusterInstance(ctx, infraEnv, agent); err != nil {
+			return requeueWithError(err)
+		}
+		if clusterInstance == nil {
+			r.Log.Info("Agent does not match the node of a ClusterInstance referencing its InfraEnv",
+				"name", agent.Name, "InfraEnv", infraEnvKey)
+			return completed(), nil
+		}
+	}
+
+	if node == nil {
+		r.Log.Info("Agent does not match a ClusterInstance node", "name", agent.Name,
+			"ClusterInstance", clusterInstance.Name)
+		return completed(), nil
+	}
+
+	hostName := node.HostName
+	if err := updateNodeStatus(ctx, r.Client, clusterInstance, func(clusterInstance *v1alpha1.ClusterInstance) {
+		updateNodeAgentStatus(agent, getOrCreateNodeStatus(clusterInstance, hostName))
+	}); err != nil {
+		return requeueWithError(err)
+	}
+
+	if err := r.updateAgentAnnotations(ctx, agent, node); err != nil {
+		return requeueWithError(err)
+	}
+
+	if err := r.approveAgent(ctx, clusterInstance, node, agent); err != nil {
+		return requeueWithError(err)
+	}
+
+	// Wait for the Agent to report further progress
+	return waitForEvent(), nil
+}
+
+// findAgentNodeSpec returns the node of the ClusterInstance the Agent belongs to, matched by the requested or the
+// discovered host name of the Agent, falling back to the MAC addresses declared by the node. It returns nil if there is
+// none.
+func findAgentNodeSpec(clusterInstance *v1alpha1.ClusterInstance, agent *aiv1beta1.Agent) *v1alpha1.NodeSpec {
+	for _, hostName := range []string{agent.Spec.Hostname, agent.Status.Inventory.Hostname} {
+		if hostName == "" {
+			continue
+		}
+		if node := findNodeSpec(clusterInstance, hostName); node != nil {
+			return node
+		}
+	}
+
+	for i := range clusterInstance.Spec.Nodes {
+		node := &clusterInstance.Spec.Nodes[i]
+		for _, macAddress := range node.MACAddresses() {
+			for _, nic := range agent.Status.Inventory.Interfaces {
+				if strings.EqualFold(nic.MacAddress, macAddress) {
+					return node
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// findReferencingClusterInstance returns the ClusterInstance binding its nodes to the InfraEnv through its InfraEnvRef,
+// along with its node matching the Agent. It returns nil if there is none.
+func (r *AgentReconciler) findReferencingClusterInstance(
+	ctx context.Context,
+	infraEnv *aiv1beta1.InfraEnv,
+	agent *aiv1beta1.Agent,
+) (*v1alpha1.ClusterInstance, *v1alpha1.NodeSpec, error) {
+	clusterInstances := &v1alpha1.ClusterInstanceList{}
+	if err := r.List(ctx, clusterInstances); err != nil {
+		return nil, nil, err
+	}
+	for i := range clusterInstances.Items {
+		clusterInstance := &clusterInstances.Items[i]
+		if clusterInstance.Spec.InfraEnvRef == nil || ci.InfraEnvName(clusterInstance) != infraEnv.Name ||
+			ci.InfraEnvNamespace(clusterInstance) != infraEnv.Namespace {
+			continue
+		}
+		if node := findAgentNodeSpec(clusterInstance, agent); node != nil {
+			return clusterInstance, node, nil
+		}
+	}
+	return nil, nil, nil
+}
+
+// isAgentOfNode returns true if the Agent is the one of the node, as identified by the host name requested for the
+// Agent or by the MAC addresses declared by the node. The host name discovered by the Agent is not trusted, since any
+// host booting from the InfraEnv may report it.
+func isAgentOfNode(node *v1alpha1.NodeSpec, agent *aiv1beta1.Agent) bool {
+	if agent.Spec.Hostname != "" && agent.Spec.Hostname == node.HostName {
+		return true
+	}
+	for _, macAddress := range node.MACAddresses() {
+		for _, nic := range agent.Status.Inventory.Interfaces {
+			if strings.EqualFold(nic.MacAddress, macAddress) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// agentFailedValidations returns the failed host validations of the Agent, ordered by category and ID
+func agentFailedValidations(agent *aiv1beta1.Agent) []v1alpha1.HostValidation {
+	categories := make([]string, 0, len(agent.Status.ValidationsInfo))
+	for category := range agent.Status.ValidationsInfo {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	var failed []v1alpha1.HostValidation
+	for _, category := range categories {
+		results := agent.Status.ValidationsInfo[category]
+		sorted := make([]v1alpha1.HostValidation, 0, len(results))
+		for _, result := range results {
+			if failedValidationStatuses[result.Status] {
+				sorted = append(sorted, v1alpha1.HostValidation{ID: result.ID, Message: result.Message})
+			}
+		}
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+		failed = append(failed, sorted...)
+	}
+	return failed
+}
+
+// updateNodeAgentStatus maps the installation progress and the host validations of the Agent into the node status,
+// the failed validations are reported with their message in the AgentValidated condition
+func updateNodeAgentStatus(agent *aiv1beta1.Agent, nodeStatus *v1alpha1.NodeStatus) {
+	nodeStatus.AgentStage = string(agent.Status.Progress.CurrentStage)
+	nodeStatus.AgentInstallationPercentage = agent.Status.Progress.InstallationPercentage
+	nodeStatus.FailedValidations = agentFailedValidations(agent)
+
+	switch {
+	case len(nodeStatus.FailedValidations) > 0:
+		messages := make([]string, 0, len(nodeStatus.FailedValidations))
+		for _, validation := range nodeStatus.FailedValidations {
+			messages = append(messages, fmt.Sprintf("%s: %s", validation.ID, validation.Message))
+		}
+		conditions.SetStatusCondition(&nodeStatus.Conditions,
+			conditions.AgentValidated,
+			conditions.Failed,
+			metav1.ConditionFalse,
+			fmt.Sprintf("Host validations failed: %s", strings.Join(messages, "; ")))
+	case len(agent.Status.ValidationsInfo) == 0:
+		conditions.SetStatusCondition(&nodeStatus.Conditions,
+			conditions.AgentValidated,
+			conditions.Unknown,
+			metav1.ConditionUnknown,
+			"Waiting for the Agent to report its host validations")
+	default:
+		conditions.SetStatusCondition(&nodeStatus.Conditions,
+			conditions.AgentValidated,
+			conditions.Completed,
+			metav1.ConditionTrue,
+			"Host validations passed")
+	}
+}
+
+// setAgentAnnotations sets the annotations of the node on the Agent and removes those that were removed from the node,
+// as recorded in the NodeAnnotationsAnnotation. It returns true if the annotations of the Agent changed.
+func setAgentAnnotations(agent *aiv1beta1.Agent, node *v1alpha1.NodeSpec) bool {
+	desired := ci.NodeAnnotations(node)
+	annotations := agent.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	changed := false
+	for _, key := range strings.Split(annotations[NodeAnnotationsAnnotation], ",") {
+		if _, ok := desired[key]; !ok && key != "" {
+			delete(annotations, key)
+			changed = true
+		}
+	}
+	keys := make([]string, 0, len(desired))
+	for key, value := range desired {
+		keys = append(keys, key)
+		if existing, ok := annotations[key]; !ok || existing != value {
+			annotations[key] = value
+			changed = true
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		changed = changed || annotations[NodeAnnotationsAnnotation] != strings.Join(keys, ",")
+		annotations[NodeAnnotationsAnnotation] = strings.Join(keys, ",")
+	} else if _, ok := annotations[NodeAnnotationsAnnotation]; ok {
+		delete(annotations, NodeAnnotationsAnnotation)
+		changed = true
+	}
+	agent.SetAnnotations(annotations)
+	return changed
+}
+
+// updateAgentAnnotations patches the Agent with the annotations of its node
+func (r *AgentReconciler) updateAgentAnnotations(
+	ctx context.Context,
+	agent *aiv1beta1.Agent,
+	node *v1alpha1.NodeSpec,
+) error {
+	patch := client.MergeFrom(agent.DeepCopy())
+	if !setAgentAnnotations(agent, node) {
+		return nil
+	}
+	r.Log.Info("Updating the node annotations of the Agent", "Agent", agent.Name, "node", node.HostName)
+	return r.Patch(ctx, agent, patch)
+}
+
+// shouldApproveAgent returns true if the Agent of a node of the ClusterInstance is to be approved, i.e. the
+// ClusterInstance requests the automatic approval, is not being deleted, and the Agent is not approved yet
+func shouldApproveAgent(clusterInstance *v1alpha1.ClusterInstance, agent *aiv1beta1.Agent) bool {
+	return clusterInstance.Spec.AutoApprove && clusterInstance.DeletionTimestamp.IsZero() && !agent.Spec.Approved
+}
+
+// approveAgent approves the Agent of a node when the ClusterInstance requests the automatic approval, provided the
+// Agent is identified as the one of the node by its requested host name or the MAC addresses of the node
+func (r *AgentReconciler) approveAgent(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+	node *v1alpha1.NodeSpec,
+	agent *aiv1beta1.Agent,
+) error {
+	if !shouldApproveAgent(clusterInstance, agent) {
+		return nil
+	}
+	if !isAgentOfNode(node, agent) {
+		r.Log.Info("Not approving the Agent, it is not identified by the requested host name or a MAC address of the "+
+			"node", "Agent", agent.Name, "ClusterInstance", clusterInstance.Name, "node", node.HostName)
+		return nil
+	}
+	patch := client.MergeFrom(agent.DeepCopy())
+	agent.Spec.Approved = true
+	if err := r.Patch(ctx, agent, patch); err != nil {
+		return err
+	}
+	r.Log.Info("Approved the Agent", "Agent", agent.Name, "ClusterInstance", clusterInstance.Name)
+	return nil
+}
+
+// mapClusterInstanceToAgents returns the Agents bound to the InfraEnv of the ClusterInstance, so that the changes of
+// the node annotations are propagated to them
+func (r *AgentReconciler) mapClusterInstanceToAgents(ctx context.Context, obj client.Object) []reconcile.Request {
+	clusterInstance, ok := obj.(*v1alpha1.ClusterInstance)
+	if !ok {
+		return nil
+	}
+
+	agents := &aiv1beta1.AgentList{}
+	if err := r.List(ctx, agents, client.InNamespace(ci.InfraEnvNamespace(clusterInstance)),
+		client.MatchingLabels{aiv1beta1.InfraEnvNameLabel: ci.InfraEnvName(clusterInstance)}); err != nil {
+		r.Log.Error(err, "Failed to list the Agents of the ClusterInstance", "ClusterInstance", clusterInstance.Name)
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(agents.Items))
+	for _, agent := range agents.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&agent)})
+	}
+	return requests
+}
+
+// isBoundToInfraEnv returns true if the Agent booted from an InfraEnv
+func isBoundToInfraEnv(obj client.Object) bool {
+	return obj.GetLabels()[aiv1beta1.InfraEnvNameLabel] != ""
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AgentReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("agentReconciler").
+		For(&aiv1beta1.Agent{},
+			// watch for create and update event for Agent
+			builder.WithPredicates(predicate.Funcs{
+				GenericFunc: func(e event.GenericEvent) bool { return false },
+				CreateFunc: func(e event.CreateEvent) bool {
+					return isBoundToInfraEnv(e.Object)
+				},
+				DeleteFunc: func(e event.DeleteEvent) bool { return false },
+				UpdateFunc: func(e event.UpdateEvent) bool {
+					return isBoundToInfraEnv(e.ObjectNew)
+				},
+			})).
+		// Propagate the changes of the node annotations and of the automatic approval to the Agents
+		Watches(&v1alpha1.ClusterInstance{},
+			handler.EnqueueRequestsFromMapFunc(r.mapClusterInstanceToAgents),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(instrument("agent", r))
+}
@@ -0,0 +1,368 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/openshift/assisted-service/api/common"
+	aiv1beta1 "github.com/openshift/assisted-service/api/v1beta1"
+	"github.com/openshift/assisted-service/models"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("AgentReconciler", func() {
+	var (
+		c                client.Client
+		r                *AgentReconciler
+		ctx              = context.Background()
+		clusterName      = "test-cluster"
+		clusterNamespace = "test-namespace"
+		clusterInstance  *v1alpha1.ClusterInstance
+	)
+
+	newAgent := func(name string, status aiv1beta1.AgentStatus) *aiv1beta1.Agent {
+		return &aiv1beta1.Agent{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: clusterName,
+				Labels:    map[string]string{aiv1beta1.InfraEnvNameLabel: clusterName},
+			},
+			Status: status,
+		}
+	}
+
+	reconcileNodeStatus := func(agent *aiv1beta1.Agent) *v1alpha1.NodeStatus {
+		Expect(c.Create(ctx, agent)).To(Succeed())
+		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(agent)})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(waitForEvent()))
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		Expect(clusterInstance.Status.Nodes).To(HaveLen(1))
+		return &clusterInstance.Status.Nodes[0]
+	}
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		r = &AgentReconciler{
+			Client: c,
+			Scheme: scheme.Scheme,
+			Log:    ctrl.Log.WithName("AgentReconciler"),
+		}
+
+		clusterInstance = &v1alpha1.ClusterInstance{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterName,
+				Namespace: clusterNamespace,
+			},
+			Spec: v1alpha1.ClusterInstanceSpec{
+				ClusterName:            clusterName,
+				PullSecretRef:          corev1.LocalObjectReference{Name: "pull-secret"},
+				ClusterImageSetNameRef: "testimage:foobar",
+				BaseDomain:             "example.com",
+				ClusterType:            v1alpha1.ClusterTypeSNO,
+				Nodes: []v1alpha1.NodeSpec{
+					{HostName: "node1.example.com", BootMACAddress: "00:00:00:01:20:30"},
+				},
+			},
+		}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		infraEnv := &aiv1beta1.InfraEnv{
+			ObjectMeta: metav1.ObjectMeta{Name: clusterName, Namespace: clusterName},
+		}
+		setLabelOwnership(clusterInstance, infraEnv)
+		Expect(c.Create(ctx, infraEnv)).To(Succeed())
+	})
+
+	It("maps the failed host validations and the progress of the Agent into the node status", func() {
+		agent := newAgent("agent-1", aiv1beta1.AgentStatus{
+			Inventory: aiv1beta1.HostInventory{Hostname: "node1.example.com"},
+			Progress: aiv1beta1.HostProgressInfo{
+				CurrentStage:           models.HostStageWritingImageToDisk,
+				InstallationPercentage: 42,
+			},
+			ValidationsInfo: common.ValidationsStatus{
+				"network": {
+					{ID: "ntp-synced", Status: "failure", Message: "Host couldn't synchronize with any NTP server"},
+					{ID: "connected", Status: "success", Message: "Host is connected"},
+				},
+				"hardware": {
+					{ID: "has-min-memory", Status: "failure", Message: "Insufficient memory"},
+					{ID: "has-min-cpu-cores", Status: "error", Message: "Missing inventory"},
+					{ID: "has-inventory", Status: "pending", Message: "Waiting for inventory"},
+				},
+			},
+		})
+
+		nodeStatus := reconcileNodeStatus(agent)
+		Expect(nodeStatus.HostName).To(Equal("node1.example.com"))
+		Expect(nodeStatus.AgentStage).To(Equal(string(models.HostStageWritingImageToDisk)))
+		Expect(nodeStatus.AgentInstallationPercentage).To(Equal(int64(42)))
+		Expect(nodeStatus.FailedValidations).To(Equal([]v1alpha1.HostValidation{
+			{ID: "has-min-cpu-cores", Message: "Missing inventory"},
+			{ID: "has-min-memory", Message: "Insufficient memory"},
+			{ID: "ntp-synced", Message: "Host couldn't synchronize with any NTP server"},
+		}))
+		cond := meta.FindStatusCondition(nodeStatus.Conditions, string(conditions.AgentValidated))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Reason).To(Equal(string(conditions.Failed)))
+		Expect(cond.Message).To(Equal("Host validations failed: has-min-cpu-cores: Missing inventory; " +
+			"has-min-memory: Insufficient memory; ntp-synced: Host couldn't synchronize with any NTP server"))
+
+		// The validations pass once the failures are resolved
+		agent.Status.ValidationsInfo = common.ValidationsStatus{
+			"network": {{ID: "ntp-synced", Status: "success", Message: "Host NTP is synced"}},
+		}
+		Expect(c.Update(ctx, agent)).To(Succeed())
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(agent)})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		nodeStatus = &clusterInstance.Status.Nodes[0]
+		Expect(nodeStatus.FailedValidations).To(BeEmpty())
+		cond = meta.FindStatusCondition(nodeStatus.Conditions, string(conditions.AgentValidated))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(cond.Reason).To(Equal(string(conditions.Completed)))
+	})
+
+	It("propagates the node annotations to the Agent", func() {
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		clusterInstance.Spec.Nodes[0].Annotations = map[string]string{
+			"example.com/rack":         "r12",
+			"example.com/owner":        "team-a",
+			v1alpha1.Group + "/paused": "true",
+		}
+		Expect(c.Update(ctx, clusterInstance)).To(Succeed())
+
+		agent := newAgent("agent-1", aiv1beta1.AgentStatus{
+			Inventory: aiv1beta1.HostInventory{Hostname: "node1.example.com"},
+		})
+		agent.Annotations = map[string]string{"example.com/external": "kept"}
+		reconcileNodeStatus(agent)
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(agent), agent)).To(Succeed())
+		Expect(agent.Annotations).To(Equal(map[string]string{
+			"example.com/external":    "kept",
+			"example.com/owner":       "team-a",
+			"example.com/rack":        "r12",
+			NodeAnnotationsAnnotation: "example.com/owner,example.com/rack",
+		}))
+
+		// The annotations removed from the node are removed from the Agent
+		clusterInstance.Spec.Nodes[0].Annotations = map[string]string{"example.com/rack": "r13"}
+		Expect(c.Update(ctx, clusterInstance)).To(Succeed())
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(agent)})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(agent), agent)).To(Succeed())
+		Expect(agent.Annotations).To(Equal(map[string]string{
+			"example.com/external":    "kept",
+			"example.com/rack":        "r13",
+			NodeAnnotationsAnnotation: "example.com/rack",
+		}))
+	})
+
+	It("matches the Agent to the node by its boot MAC address", func() {
+		agent := newAgent("agent-1", aiv1beta1.AgentStatus{
+			Inventory: aiv1beta1.HostInventory{
+				Hostname:   "localhost",
+				Interfaces: []aiv1beta1.HostInterface{{Name: "eno1", MacAddress: "00:00:00:01:20:30"}},
+			},
+		})
+
+		nodeStatus := reconcileNodeStatus(agent)
+		Expect(nodeStatus.HostName).To(Equal("node1.example.com"))
+		cond := meta.FindStatusCondition(nodeStatus.Conditions, string(conditions.AgentValidated))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionUnknown))
+		Expect(cond.Message).To(Equal("Waiting for the Agent to report its host validations"))
+	})
+
+	It("matches the Agent to the node by a MAC address of its node network", func() {
+		clusterInstance.Spec.Nodes[0].NodeNetwork = &aiv1beta1.NMStateConfigSpec{
+			Interfaces: []*aiv1beta1.Interface{{Name: "eno2", MacAddress: "00:00:00:01:20:31"}},
+		}
+		Expect(c.Update(ctx, clusterInstance)).To(Succeed())
+		agent := newAgent("agent-1", aiv1beta1.AgentStatus{
+			Inventory: aiv1beta1.HostInventory{
+				Hostname:   "localhost",
+				Interfaces: []aiv1beta1.HostInterface{{Name: "eno2", MacAddress: "00:00:00:01:20:31"}},
+			},
+		})
+
+		nodeStatus := reconcileNodeStatus(agent)
+		Expect(nodeStatus.HostName).To(Equal("node1.example.com"))
+	})
+
+	It("ignores an Agent that does not match a node", func() {
+		agent := newAgent("agent-1", aiv1beta1.AgentStatus{
+			Inventory: aiv1beta1.HostInventory{Hostname: "other.example.com"},
+		})
+		Expect(c.Create(ctx, agent)).To(Succeed())
+		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(agent)})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(completed()))
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		Expect(clusterInstance.Status.Nodes).To(BeEmpty())
+	})
+
+	Context("when the ClusterInstance requests the automatic approval", func() {
+		BeforeEach(func() {
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+			clusterInstance.Spec.AutoApprove = true
+			Expect(c.Update(ctx, clusterInstance)).To(Succeed())
+		})
+
+		It("approves the Agent of a node", func() {
+			agent := newAgent("agent-1", aiv1beta1.AgentStatus{})
+			agent.Spec.Hostname = "node1.example.com"
+			reconcileNodeStatus(agent)
+
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(agent), agent)).To(Succeed())
+			Expect(agent.Spec.Approved).To(BeTrue())
+		})
+
+		It("approves the Agent of a node identified by its MAC address", func() {
+			agent := newAgent("agent-1", aiv1beta1.AgentStatus{
+				Inventory: aiv1beta1.HostInventory{
+					Hostname:   "localhost",
+					Interfaces: []aiv1beta1.HostInterface{{MacAddress: "00:00:00:01:20:30"}},
+				},
+			})
+			reconcileNodeStatus(agent)
+
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(agent), agent)).To(Succeed())
+			Expect(agent.Spec.Approved).To(BeTrue())
+		})
+
+		It("does not approve an Agent identified only by its discovered host name", func() {
+			agent := newAgent("agent-1", aiv1beta1.AgentStatus{
+				Inventory: aiv1beta1.HostInventory{Hostname: "node1.example.com"},
+			})
+			reconcileNodeStatus(agent)
+
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(agent), agent)).To(Succeed())
+			Expect(agent.Spec.Approved).To(BeFalse())
+		})
+
+		It("approves the Agent of a node of a ClusterInstance referencing the InfraEnv", func() {
+			infraEnv := &aiv1beta1.InfraEnv{
+				ObjectMeta: metav1.ObjectMeta{Name: "shared-infraenv", Namespace: clusterName},
+			}
+			Expect(c.Create(ctx, infraEnv)).To(Succeed())
+			clusterInstance.Spec.InfraEnvRef = &corev1.LocalObjectReference{Name: infraEnv.Name}
+			Expect(c.Update(ctx, clusterInstance)).To(Succeed())
+
+			agent := newAgent("agent-1", aiv1beta1.AgentStatus{})
+			agent.Labels[aiv1beta1.InfraEnvNameLabel] = infraEnv.Name
+			agent.Spec.Hostname = "node1.example.com"
+			reconcileNodeStatus(agent)
+
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(agent), agent)).To(Succeed())
+			Expect(agent.Spec.Approved).To(BeTrue())
+		})
+
+		It("ignores an Agent of an InfraEnv that is neither owned nor referenced by a ClusterInstance", func() {
+			infraEnv := &aiv1beta1.InfraEnv{
+				ObjectMeta: metav1.ObjectMeta{Name: "other-infraenv", Namespace: clusterName},
+			}
+			Expect(c.Create(ctx, infraEnv)).To(Succeed())
+
+			agent := newAgent("agent-1", aiv1beta1.AgentStatus{})
+			agent.Labels[aiv1beta1.InfraEnvNameLabel] = infraEnv.Name
+			agent.Spec.Hostname = "node1.example.com"
+			Expect(c.Create(ctx, agent)).To(Succeed())
+			res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(agent)})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res).To(Equal(completed()))
+
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(agent), agent)).To(Succeed())
+			Expect(agent.Spec.Approved).To(BeFalse())
+		})
+
+		It("does not approve an Agent that does not match a node", func() {
+			agent := newAgent("agent-1", aiv1beta1.AgentStatus{
+				Inventory: aiv1beta1.HostInventory{Hostname: "other.example.com"},
+			})
+			Expect(c.Create(ctx, agent)).To(Succeed())
+			_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(agent)})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(agent), agent)).To(Succeed())
+			Expect(agent.Spec.Approved).To(BeFalse())
+		})
+	})
+
+	It("does not approve the Agent of a node unless requested", func() {
+		agent := newAgent("agent-1", aiv1beta1.AgentStatus{
+			Inventory: aiv1beta1.HostInventory{Hostname: "node1.example.com"},
+		})
+		reconcileNodeStatus(agent)
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(agent), agent)).To(Succeed())
+		Expect(agent.Spec.Approved).To(BeFalse())
+	})
+
+	It("only approves the Agents that are not approved yet while the ClusterInstance is not being deleted", func() {
+		now := metav1.Now()
+		agent := &aiv1beta1.Agent{}
+		for _, tc := range []struct {
+			autoApprove bool
+			deleting    bool
+			approved    bool
+			expected    bool
+		}{
+			{autoApprove: true, expected: true},
+			{autoApprove: true, approved: true, expected: false},
+			{autoApprove: true, deleting: true, expected: false},
+			{autoApprove: false, expected: false},
+		} {
+			clusterInstance.Spec.AutoApprove = tc.autoApprove
+			clusterInstance.DeletionTimestamp = nil
+			if tc.deleting {
+				clusterInstance.DeletionTimestamp = &now
+			}
+			agent.Spec.Approved = tc.approved
+			Expect(shouldApproveAgent(clusterInstance, agent)).To(Equal(tc.expected))
+		}
+	})
+
+	It("ignores an Agent whose InfraEnv is not found", func() {
+		agent := newAgent("agent-1", aiv1beta1.AgentStatus{})
+		agent.Labels[aiv1beta1.InfraEnvNameLabel] = "other"
+		Expect(c.Create(ctx, agent)).To(Succeed())
+		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(agent)})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(completed()))
+	})
+})
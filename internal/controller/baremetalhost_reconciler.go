@@ -0,0 +1,308 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	bmh_v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	"github.com/stolostron/siteconfig/internal/controller/retry"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// bmhHostNameAnnotation is the BareMetalHost annotation holding the host name of the node
+const bmhHostNameAnnotation = "bmac.agent-install.openshift.io/hostname"
+
+// hardwareReadyStates are the BareMetalHost provisioning states in which the hardware of a node is ready for the
+// installation of the cluster
+var hardwareReadyStates = map[string]bool{
+	string(bmh_v1alpha1.StateAvailable):             true,
+	string(bmh_v1alpha1.StatePreparing):             true,
+	string(bmh_v1alpha1.StateProvisioning):          true,
+	string(bmh_v1alpha1.StateProvisioned):           true,
+	string(bmh_v1alpha1.StateExternallyProvisioned): true,
+}
+
+//+kubebuilder:rbac:groups=metal3.io,resources=baremetalhosts,verbs=list;watch
+//+kubebuilder:rbac:groups=metal3.io,resources=baremetalhosts/status,verbs=get
+
+// BareMetalHostReconciler reconciles a BareMetalHost object to
+// update the provisioning state of the corresponding ClusterInstance node
+type BareMetalHostReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+func (r *BareMetalHostReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// Get the BareMetalHost CR
+	bmh := &bmh_v1alpha1.BareMetalHost{}
+	if err := r.Get(ctx, req.NamespacedName, bmh); err != nil {
+		if errors.IsNotFound(err) {
+			r.Log.Info("BareMetalHost not found", "name", req.NamespacedName)
+			return completed(), nil
+		}
+		r.Log.Error(err, "Failed to get BareMetalHost")
+		// This is likely a case where the API is down, so requeue and try again shortly
+		return requeueWithError(err)
+	}
+
+	// Fetch ClusterInstance associated with BareMetalHost object
+	clusterInstance, err := getOwnerClusterInstance(ctx, r.Client, r.Log, bmh)
+	if err != nil {
+		return requeueWithError(err)
+	}
+	if clusterInstance == nil {
+		return completed(), nil
+	}
+
+	// Do not act on a ghost, i.e. a ClusterInstance of the same name that does not own the BareMetalHost
+	if !isOwnerUIDMatching(bmh, clusterInstance) {
+		r.Log.Info("ClusterInstance UID does not match the BareMetalHost owner, skipping",
+			"BareMetalHost", req.NamespacedName, "ClusterInstance UID", clusterInstance.UID)
+		return completed(), nil
+	}
+
+	hostName := bmhHostName(bmh)
+	if findNodeSpec(clusterInstance, hostName) == nil {
+		r.Log.Info("BareMetalHost does not match a ClusterInstance node", "name", bmh.Name,
+			"ClusterInstance", clusterInstance.Name)
+		return completed(), nil
+	}
+
+	if err := updateNodeStatus(ctx, r.Client, clusterInstance, func(clusterInstance *v1alpha1.ClusterInstance) {
+		updateNodeBareMetalHostStatus(bmh, getOrCreateNodeStatus(clusterInstance, hostName))
+		updateCIHardwareReadyStatus(clusterInstance)
+	}); err != nil {
+		return requeueWithError(err)
+	}
+
+	// Wait for the BareMetalHost to report further progress
+	return waitForEvent(), nil
+}
+
+// bmhHostName returns the host name of the node a BareMetalHost belongs to, as set by the host name annotation,
+// falling back to the BareMetalHost name
+func bmhHostName(bmh *bmh_v1alpha1.BareMetalHost) string {
+	if hostName := bmh.GetAnnotations()[bmhHostNameAnnotation]; hostName != "" {
+		return hostName
+	}
+	return bmh.Name
+}
+
+// findNodeSpec returns the node of the ClusterInstance with the given host name, nil if there is none
+func findNodeSpec(clusterInstance *v1alpha1.ClusterInstance, hostName string) *v1alpha1.NodeSpec {
+	for i := range clusterInstance.Spec.Nodes {
+		if clusterInstance.Spec.Nodes[i].HostName == hostName {
+			return &clusterInstance.Spec.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// getOrCreateNodeStatus returns the status of the node with the given host name, it is added to the ClusterInstance
+// status if not found
+func getOrCreateNodeStatus(clusterInstance *v1alpha1.ClusterInstance, hostName string) *v1alpha1.NodeStatus {
+	for i := range clusterInstance.Status.Nodes {
+		if clusterInstance.Status.Nodes[i].HostName == hostName {
+			return &clusterInstance.Status.Nodes[i]
+		}
+	}
+	clusterInstance.Status.Nodes = append(clusterInstance.Status.Nodes, v1alpha1.NodeStatus{HostName: hostName})
+	return &clusterInstance.Status.Nodes[len(clusterInstance.Status.Nodes)-1]
+}
+
+// updateNodeStatus applies the update to the status of the ClusterInstance and patches it when changed. The patch
+// carries an optimistic lock: the node statuses are a list replaced as a whole by a merge patch, so that the concurrent
+// update of another node status would otherwise be lost. On a conflict, the ClusterInstance is fetched again and the
+// update is re-applied.
+func updateNodeStatus(
+	ctx context.Context,
+	c client.Client,
+	clusterInstance *v1alpha1.ClusterInstance,
+	update func(*v1alpha1.ClusterInstance),
+) error {
+	refresh := false
+	if err := retry.RetryOnConflictOrRetriable(retry.RetryBackoff30Seconds, func() error {
+		if refresh {
+			if err := c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance); err != nil {
+				return err //nolint:wrapcheck
+			}
+		}
+		refresh = true
+
+		original := clusterInstance.DeepCopy()
+		update(clusterInstance)
+		if !conditions.StatusChanged(&original.Status, &clusterInstance.Status) {
+			return nil
+		}
+		conditions.SetStandardConditions(clusterInstance)
+		return c.Status().Patch(ctx, clusterInstance, //nolint:wrapcheck
+			client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{}))
+	}); err != nil {
+		return fmt.Errorf("failed to update the node status of ClusterInstance %s: %w", clusterInstance.Name, err)
+	}
+	return nil
+}
+
+// updateNodeBareMetalHostStatus maps the provisioning state of the BareMetalHost into the node status, BareMetalHost
+// errors take precedence over the provisioning state
+func updateNodeBareMetalHostStatus(bmh *bmh_v1alpha1.BareMetalHost, nodeStatus *v1alpha1.NodeStatus) {
+	state := bmh.Status.Provisioning.State
+	nodeStatus.BareMetalHostState = string(state)
+	nodeStatus.PowerState = bmhPowerState(bmh)
+	nodeStatus.ErrorMessage = ""
+
+	if bmh.Status.OperationalStatus == bmh_v1alpha1.OperationalStatusError || bmh.Status.ErrorMessage != "" {
+		message := fmt.Sprintf("BareMetalHost error: %s", bmh.Status.ErrorMessage)
+		if bmh.Status.ErrorType != "" {
+			message = fmt.Sprintf("BareMetalHost %s: %s", bmh.Status.ErrorType, bmh.Status.ErrorMessage)
+		}
+		nodeStatus.ErrorMessage = message
+		conditions.SetStatusCondition(&nodeStatus.Conditions,
+			conditions.BareMetalHostProvisioned,
+			conditions.Failed,
+			metav1.ConditionFalse,
+			message)
+		return
+	}
+
+	switch state {
+	case bmh_v1alpha1.StateProvisioned, bmh_v1alpha1.StateExternallyProvisioned:
+		conditions.SetStatusCondition(&nodeStatus.Conditions,
+			conditions.BareMetalHostProvisioned,
+			conditions.Completed,
+			metav1.ConditionTrue,
+			fmt.Sprintf("BareMetalHost is %s", state))
+	case bmh_v1alpha1.StateNone:
+		conditions.SetStatusCondition(&nodeStatus.Conditions,
+			conditions.BareMetalHostProvisioned,
+			conditions.Unknown,
+			metav1.ConditionUnknown,
+			"Waiting for the BareMetalHost to report its provisioning state")
+	default:
+		conditions.SetStatusCondition(&nodeStatus.Conditions,
+			conditions.BareMetalHostProvisioned,
+			conditions.InProgress,
+			metav1.ConditionFalse,
+			fmt.Sprintf("BareMetalHost is %s", state))
+	}
+}
+
+// updateCIHardwareReadyStatus aggregates the hardware readiness of the nodes into the HardwareReady condition, which
+// is only True once the BareMetalHost of every node is ready for the installation. While the hardware is not ready,
+// the Provisioned condition waiting for the provisioning to start reports the readiness of the nodes.
+func updateCIHardwareReadyStatus(clusterInstance *v1alpha1.ClusterInstance) {
+	ready, notReady := 0, []string{}
+	for _, node := range clusterInstance.Spec.Nodes {
+		if isNodeHardwareReady(clusterInstance, node.HostName) {
+			ready++
+		} else {
+			notReady = append(notReady, node.HostName)
+		}
+	}
+	total := len(clusterInstance.Spec.Nodes)
+
+	if len(notReady) == 0 {
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+			conditions.HardwareReady,
+			conditions.Completed,
+			metav1.ConditionTrue,
+			fmt.Sprintf("Hardware ready: %d/%d nodes", ready, total))
+	} else {
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+			conditions.HardwareReady,
+			conditions.InProgress,
+			metav1.ConditionFalse,
+			fmt.Sprintf("Hardware ready: %d/%d nodes, waiting for: %s", ready, total, strings.Join(notReady, ", ")))
+	}
+
+	provisioned := meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.Provisioned))
+	if provisioned != nil && provisioned.Status == metav1.ConditionUnknown &&
+		provisioned.Reason == string(conditions.Unknown) {
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+			conditions.Provisioned,
+			conditions.Unknown,
+			metav1.ConditionUnknown,
+			provisioningWaitMessage(clusterInstance))
+	}
+}
+
+// isNodeHardwareReady returns true if the BareMetalHost of the node reports a ready state without error
+func isNodeHardwareReady(clusterInstance *v1alpha1.ClusterInstance, hostName string) bool {
+	for _, nodeStatus := range clusterInstance.Status.Nodes {
+		if nodeStatus.HostName == hostName {
+			return nodeStatus.ErrorMessage == "" && hardwareReadyStates[nodeStatus.BareMetalHostState]
+		}
+	}
+	return false
+}
+
+// provisioningWaitMessage returns the message of the Provisioned condition while waiting for the provisioning to
+// start, including the hardware readiness while it is not ready
+func provisioningWaitMessage(clusterInstance *v1alpha1.ClusterInstance) string {
+	hardwareReady := meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.HardwareReady))
+	if hardwareReady != nil && hardwareReady.Status == metav1.ConditionFalse {
+		return fmt.Sprintf("Waiting for provisioning to start, %s", hardwareReady.Message)
+	}
+	return "Waiting for provisioning to start"
+}
+
+// bmhPowerState maps the power status of the BareMetalHost to the node power state, the power status is only known
+// once the BareMetalHost has been registered with its BMC
+func bmhPowerState(bmh *bmh_v1alpha1.BareMetalHost) v1alpha1.NodePowerState {
+	switch bmh.Status.Provisioning.State {
+	case bmh_v1alpha1.StateNone, bmh_v1alpha1.StateUnmanaged, bmh_v1alpha1.StateRegistering:
+		return v1alpha1.NodePowerStateUnknown
+	}
+	if bmh.Status.PoweredOn {
+		return v1alpha1.NodePowerStateOn
+	}
+	return v1alpha1.NodePowerStateOff
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *BareMetalHostReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("bareMetalHostReconciler").
+		For(&bmh_v1alpha1.BareMetalHost{},
+			// watch for create and update event for BareMetalHost
+			builder.WithPredicates(predicate.Funcs{
+				GenericFunc: func(e event.GenericEvent) bool { return false },
+				CreateFunc: func(e event.CreateEvent) bool {
+					return isOwnedByClusterInstance(e.Object)
+				},
+				DeleteFunc: func(e event.DeleteEvent) bool { return false },
+				UpdateFunc: func(e event.UpdateEvent) bool {
+					return isOwnedByClusterInstance(e.ObjectNew)
+				},
+			})).
+		Complete(instrument("baremetalhost", r))
+}
@@ -0,0 +1,296 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	bmh_v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+var _ = Describe("BareMetalHostReconciler", func() {
+	var (
+		c                client.Client
+		r                *BareMetalHostReconciler
+		ctx              = context.Background()
+		clusterName      = "test-cluster"
+		clusterNamespace = "test-namespace"
+		hostName         = "node1.example.com"
+		clusterInstance  *v1alpha1.ClusterInstance
+	)
+
+	newBareMetalHost := func(status bmh_v1alpha1.BareMetalHostStatus) *bmh_v1alpha1.BareMetalHost {
+		return &bmh_v1alpha1.BareMetalHost{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "node1",
+				Namespace:   clusterNamespace,
+				Annotations: map[string]string{bmhHostNameAnnotation: hostName},
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: ClusterInstanceApiVersion,
+					Kind:       v1alpha1.ClusterInstanceKind,
+					Name:       clusterName,
+				}},
+			},
+			Status: status,
+		}
+	}
+
+	reconcileNodeStatus := func(bmh *bmh_v1alpha1.BareMetalHost) *v1alpha1.NodeStatus {
+		Expect(c.Create(ctx, bmh)).To(Succeed())
+		res, err := r.Reconcile(ctx, ctrl.Request{
+			NamespacedName: types.NamespacedName{Name: bmh.Name, Namespace: bmh.Namespace}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(waitForEvent()))
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		Expect(clusterInstance.Status.Nodes).To(HaveLen(1))
+		Expect(clusterInstance.Status.Nodes[0].HostName).To(Equal(hostName))
+		return &clusterInstance.Status.Nodes[0]
+	}
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		r = &BareMetalHostReconciler{
+			Client: c,
+			Scheme: scheme.Scheme,
+			Log:    ctrl.Log.WithName("BareMetalHostReconciler"),
+		}
+
+		clusterInstance = &v1alpha1.ClusterInstance{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterName,
+				Namespace: clusterNamespace,
+			},
+			Spec: v1alpha1.ClusterInstanceSpec{
+				ClusterName:            clusterName,
+				PullSecretRef:          corev1.LocalObjectReference{Name: "pull-secret"},
+				ClusterImageSetNameRef: "testimage:foobar",
+				BaseDomain:             "example.com",
+				ClusterType:            v1alpha1.ClusterTypeSNO,
+				Nodes: []v1alpha1.NodeSpec{{
+					HostName:           hostName,
+					BmcAddress:         "192.0.2.0",
+					BmcCredentialsName: v1alpha1.BmcCredentialsName{Name: "bmc"},
+				}},
+			},
+		}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+	})
+
+	DescribeTable("maps the BareMetalHost provisioning state to the node status",
+		func(state bmh_v1alpha1.ProvisioningState, status metav1.ConditionStatus, reason conditions.ConditionReason) {
+			nodeStatus := reconcileNodeStatus(newBareMetalHost(bmh_v1alpha1.BareMetalHostStatus{
+				OperationalStatus: bmh_v1alpha1.OperationalStatusOK,
+				Provisioning:      bmh_v1alpha1.ProvisionStatus{State: state},
+			}))
+
+			Expect(nodeStatus.BareMetalHostState).To(Equal(string(state)))
+			Expect(nodeStatus.ErrorMessage).To(BeEmpty())
+			cond := meta.FindStatusCondition(nodeStatus.Conditions, string(conditions.BareMetalHostProvisioned))
+			Expect(cond).ToNot(BeNil())
+			Expect(cond.Status).To(Equal(status))
+			Expect(cond.Reason).To(Equal(string(reason)))
+		},
+		Entry("registering", bmh_v1alpha1.StateRegistering, metav1.ConditionFalse, conditions.InProgress),
+		Entry("inspecting", bmh_v1alpha1.StateInspecting, metav1.ConditionFalse, conditions.InProgress),
+		Entry("provisioning", bmh_v1alpha1.StateProvisioning, metav1.ConditionFalse, conditions.InProgress),
+		Entry("provisioned", bmh_v1alpha1.StateProvisioned, metav1.ConditionTrue, conditions.Completed),
+		Entry("externally provisioned", bmh_v1alpha1.StateExternallyProvisioned,
+			metav1.ConditionTrue, conditions.Completed),
+		Entry("not reported yet", bmh_v1alpha1.StateNone, metav1.ConditionUnknown, conditions.Unknown),
+	)
+
+	DescribeTable("maps the BareMetalHost power status to the node power state",
+		func(state bmh_v1alpha1.ProvisioningState, poweredOn bool, powerState v1alpha1.NodePowerState) {
+			nodeStatus := reconcileNodeStatus(newBareMetalHost(bmh_v1alpha1.BareMetalHostStatus{
+				OperationalStatus: bmh_v1alpha1.OperationalStatusOK,
+				Provisioning:      bmh_v1alpha1.ProvisionStatus{State: state},
+				PoweredOn:         poweredOn,
+			}))
+
+			Expect(nodeStatus.PowerState).To(Equal(powerState))
+		},
+		Entry("powered on", bmh_v1alpha1.StateProvisioned, true, v1alpha1.NodePowerStateOn),
+		Entry("powered off", bmh_v1alpha1.StateProvisioned, false, v1alpha1.NodePowerStateOff),
+		Entry("powered off while inspecting", bmh_v1alpha1.StateInspecting, false, v1alpha1.NodePowerStateOff),
+		Entry("registering", bmh_v1alpha1.StateRegistering, false, v1alpha1.NodePowerStateUnknown),
+		Entry("unmanaged", bmh_v1alpha1.StateUnmanaged, true, v1alpha1.NodePowerStateUnknown),
+		Entry("not reported yet", bmh_v1alpha1.StateNone, false, v1alpha1.NodePowerStateUnknown),
+	)
+
+	It("surfaces the BareMetalHost error message in the node status", func() {
+		nodeStatus := reconcileNodeStatus(newBareMetalHost(bmh_v1alpha1.BareMetalHostStatus{
+			OperationalStatus: bmh_v1alpha1.OperationalStatusError,
+			ErrorType:         bmh_v1alpha1.RegistrationError,
+			ErrorMessage:      "Failed to get power state for node",
+			Provisioning:      bmh_v1alpha1.ProvisionStatus{State: bmh_v1alpha1.StateRegistering},
+		}))
+
+		Expect(nodeStatus.BareMetalHostState).To(Equal(string(bmh_v1alpha1.StateRegistering)))
+		Expect(nodeStatus.ErrorMessage).To(Equal(
+			"BareMetalHost registration error: Failed to get power state for node"))
+		cond := meta.FindStatusCondition(nodeStatus.Conditions, string(conditions.BareMetalHostProvisioned))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Reason).To(Equal(string(conditions.Failed)))
+		Expect(cond.Message).To(Equal(nodeStatus.ErrorMessage))
+	})
+
+	It("reports the hardware readiness of all the nodes in the HardwareReady condition", func() {
+		clusterInstance.Spec.ClusterType = v1alpha1.ClusterTypeHighlyAvailable
+		clusterInstance.Spec.Nodes = append(clusterInstance.Spec.Nodes, v1alpha1.NodeSpec{
+			HostName:           "node2.example.com",
+			BmcAddress:         "192.0.2.1",
+			BmcCredentialsName: v1alpha1.BmcCredentialsName{Name: "bmc"},
+		})
+		Expect(c.Update(ctx, clusterInstance)).To(Succeed())
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+			conditions.Provisioned, conditions.Unknown, metav1.ConditionUnknown, "Waiting for provisioning to start")
+		Expect(c.Status().Update(ctx, clusterInstance)).To(Succeed())
+
+		reconcileBMH := func(bmh *bmh_v1alpha1.BareMetalHost) {
+			res, err := r.Reconcile(ctx, ctrl.Request{
+				NamespacedName: types.NamespacedName{Name: bmh.Name, Namespace: bmh.Namespace}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res).To(Equal(waitForEvent()))
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		}
+
+		// Partial readiness
+		node1 := newBareMetalHost(bmh_v1alpha1.BareMetalHostStatus{
+			OperationalStatus: bmh_v1alpha1.OperationalStatusOK,
+			Provisioning:      bmh_v1alpha1.ProvisionStatus{State: bmh_v1alpha1.StateProvisioned},
+		})
+		Expect(c.Create(ctx, node1)).To(Succeed())
+		reconcileBMH(node1)
+
+		cond := meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.HardwareReady))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Message).To(Equal("Hardware ready: 1/2 nodes, waiting for: node2.example.com"))
+		provisioned := meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.Provisioned))
+		Expect(provisioned.Message).To(Equal(
+			"Waiting for provisioning to start, Hardware ready: 1/2 nodes, waiting for: node2.example.com"))
+
+		// A node whose BareMetalHost is still inspecting is not ready
+		node2 := newBareMetalHost(bmh_v1alpha1.BareMetalHostStatus{
+			OperationalStatus: bmh_v1alpha1.OperationalStatusOK,
+			Provisioning:      bmh_v1alpha1.ProvisionStatus{State: bmh_v1alpha1.StateInspecting},
+		})
+		node2.Name = "node2"
+		node2.Annotations[bmhHostNameAnnotation] = "node2.example.com"
+		Expect(c.Create(ctx, node2)).To(Succeed())
+		reconcileBMH(node2)
+
+		cond = meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.HardwareReady))
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Message).To(ContainSubstring("1/2 nodes"))
+
+		// Full readiness
+		node2.Status.Provisioning.State = bmh_v1alpha1.StateProvisioning
+		Expect(c.Update(ctx, node2)).To(Succeed())
+		reconcileBMH(node2)
+
+		cond = meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.HardwareReady))
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(cond.Reason).To(Equal(string(conditions.Completed)))
+		Expect(cond.Message).To(Equal("Hardware ready: 2/2 nodes"))
+		provisioned = meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.Provisioned))
+		Expect(provisioned.Message).To(Equal("Waiting for provisioning to start"))
+	})
+
+	It("ignores a BareMetalHost that does not match a ClusterInstance node", func() {
+		bmh := newBareMetalHost(bmh_v1alpha1.BareMetalHostStatus{})
+		bmh.Annotations[bmhHostNameAnnotation] = "unknown.example.com"
+		Expect(c.Create(ctx, bmh)).To(Succeed())
+
+		res, err := r.Reconcile(ctx, ctrl.Request{
+			NamespacedName: types.NamespacedName{Name: bmh.Name, Namespace: bmh.Namespace}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(completed()))
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		Expect(clusterInstance.Status.Nodes).To(BeEmpty())
+	})
+
+	It("returns the error failing to get the ClusterInstance", func() {
+		r.Client = interceptor.NewClient(c.(client.WithWatch), interceptor.Funcs{
+			Get: func(ctx context.Context, client client.WithWatch, key client.ObjectKey, obj client.Object,
+				opts ...client.GetOption) error {
+				if _, ok := obj.(*v1alpha1.ClusterInstance); ok {
+					return fmt.Errorf("the server is currently unable to handle the request")
+				}
+				return client.Get(ctx, key, obj, opts...)
+			},
+		})
+		bmh := newBareMetalHost(bmh_v1alpha1.BareMetalHostStatus{})
+		Expect(c.Create(ctx, bmh)).To(Succeed())
+
+		_, err := r.Reconcile(ctx, ctrl.Request{
+			NamespacedName: types.NamespacedName{Name: bmh.Name, Namespace: bmh.Namespace}})
+		Expect(err).To(MatchError(ContainSubstring("unable to handle the request")))
+	})
+
+	It("keeps the status of a node updated concurrently", func() {
+		concurrentUpdate := true
+		r.Client = interceptor.NewClient(c.(client.WithWatch), interceptor.Funcs{
+			SubResourcePatch: func(ctx context.Context, cl client.Client, subResourceName string,
+				obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+				if concurrentUpdate {
+					// Another reconciler adds the status of a node in the meantime
+					concurrentUpdate = false
+					other := &v1alpha1.ClusterInstance{}
+					Expect(cl.Get(ctx, client.ObjectKeyFromObject(obj), other)).To(Succeed())
+					other.Status.Nodes = append(other.Status.Nodes, v1alpha1.NodeStatus{HostName: "node2.example.com"})
+					Expect(cl.Status().Update(ctx, other)).To(Succeed())
+				}
+				return cl.SubResource(subResourceName).Patch(ctx, obj, patch, opts...)
+			},
+		})
+		bmh := newBareMetalHost(bmh_v1alpha1.BareMetalHostStatus{
+			Provisioning: bmh_v1alpha1.ProvisionStatus{State: bmh_v1alpha1.StateProvisioned},
+		})
+		Expect(c.Create(ctx, bmh)).To(Succeed())
+
+		_, err := r.Reconcile(ctx, ctrl.Request{
+			NamespacedName: types.NamespacedName{Name: bmh.Name, Namespace: bmh.Namespace}})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		Expect(clusterInstance.Status.Nodes).To(ConsistOf(
+			HaveField("HostName", "node2.example.com"),
+			HaveField("HostName", hostName),
+		))
+	})
+})
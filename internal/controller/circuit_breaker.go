@@ -0,0 +1,123 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CircuitBreaker backs off the ClusterInstances whose reconciles keep failing, so that they do not dominate the work
+// queue: once Threshold consecutive reconciles of a ClusterInstance have failed, the failing reconciles are requeued
+// after Backoff instead of being retried with the rate limiter. Any successful reconcile resets the count. A nil
+// CircuitBreaker, or one with a zero Threshold, never trips.
+//
+// The failure counts are only held in memory, the tripped state is reconstructed from the ReconcileBackoff condition
+// of the ClusterInstance, e.g. by a new leader after a leader election: the failures of a ClusterInstance carrying the
+// condition are counted from the Threshold, so that it stays backed off until its next successful reconcile.
+type CircuitBreaker struct {
+	Threshold int
+	Backoff   time.Duration
+
+	mu       sync.Mutex
+	failures map[types.NamespacedName]int
+}
+
+// record counts the outcome of the reconcile of the ClusterInstance. It returns the failure count, which is zero after
+// a successful reconcile. The failures of a ClusterInstance without a count that is backing off are counted from the
+// Threshold.
+func (b *CircuitBreaker) record(key types.NamespacedName, err error, backingOff bool) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		delete(b.failures, key)
+		return 0
+	}
+	if b.failures == nil {
+		b.failures = map[types.NamespacedName]int{}
+	}
+	if _, ok := b.failures[key]; !ok && backingOff {
+		b.failures[key] = b.Threshold - 1
+	}
+	b.failures[key]++
+	return b.failures[key]
+}
+
+// forget drops the failure count of the ClusterInstance, once it no longer exists
+func (b *CircuitBreaker) forget(key types.NamespacedName) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.failures, key)
+}
+
+// handleReconcileResult applies the circuit breaker to the result of the reconcile of the ClusterInstance. A tripped
+// breaker replaces the error with a requeue after the Backoff and sets the ReconcileBackoff condition, which is removed
+// by the next successful reconcile.
+func (b *CircuitBreaker) handleReconcileResult(
+	ctx context.Context,
+	c client.Client,
+	clusterInstance *v1alpha1.ClusterInstance,
+	res ctrl.Result,
+	err error,
+) (ctrl.Result, error) {
+	// The errors that are not requeued cannot dominate the work queue
+	if b == nil || b.Threshold <= 0 || clusterInstance.Name == "" || isNotRequeued(err) {
+		return res, err
+	}
+
+	failures := b.record(client.ObjectKeyFromObject(clusterInstance), err,
+		meta.IsStatusConditionTrue(clusterInstance.Status.Conditions, string(conditions.ReconcileBackoff)))
+	if failures == 0 {
+		if meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.ReconcileBackoff)) == nil {
+			return res, nil
+		}
+		patch := client.MergeFrom(clusterInstance.DeepCopy())
+		meta.RemoveStatusCondition(&clusterInstance.Status.Conditions, string(conditions.ReconcileBackoff))
+		if updateErr := conditions.PatchCIStatus(ctx, c, clusterInstance, patch); updateErr != nil {
+			return requeueWithError(updateErr)
+		}
+		return res, nil
+	}
+	if failures < b.Threshold {
+		return res, err
+	}
+
+	patch := client.MergeFrom(clusterInstance.DeepCopy())
+	conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+		conditions.ReconcileBackoff,
+		conditions.RepeatedFailures,
+		metav1.ConditionTrue,
+		fmt.Sprintf("Reconcile failed %d consecutive times, retrying every %s: %s", failures, b.Backoff, err.Error()))
+	if updateErr := conditions.PatchCIStatus(ctx, c, clusterInstance, patch); updateErr != nil {
+		return requeueWithError(updateErr)
+	}
+	return requeueAfter(b.Backoff), nil
+}
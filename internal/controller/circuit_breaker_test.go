@@ -0,0 +1,157 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	ci "github.com/stolostron/siteconfig/internal/controller/clusterinstance"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("CircuitBreaker", func() {
+	var (
+		c               client.Client
+		ctx             = context.Background()
+		breaker         *CircuitBreaker
+		clusterInstance *v1alpha1.ClusterInstance
+		reconcileErr    = fmt.Errorf("failed to apply the rendered manifests")
+		testParams      = &ci.TestParams{
+			BmcCredentialsName:  "bmh-secret",
+			ClusterName:         "test-cluster",
+			ClusterNamespace:    "test-cluster",
+			ClusterImageSetName: "testimage:foobar",
+			PullSecret:          "pull-secret",
+		}
+	)
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		breaker = &CircuitBreaker{Threshold: 3, Backoff: 30 * time.Minute}
+		clusterInstance = testParams.GenerateSNOClusterInstance()
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+	})
+
+	backoffCondition := func() *metav1.Condition {
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		return meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.ReconcileBackoff))
+	}
+
+	It("trips after the threshold of consecutive failed reconciles", func() {
+		for i := 1; i < breaker.Threshold; i++ {
+			res, err := breaker.handleReconcileResult(ctx, c, clusterInstance, ctrl.Result{}, reconcileErr)
+			Expect(err).To(MatchError(reconcileErr))
+			Expect(res).To(Equal(ctrl.Result{}))
+		}
+		Expect(backoffCondition()).To(BeNil())
+
+		res, err := breaker.handleReconcileResult(ctx, c, clusterInstance, ctrl.Result{}, reconcileErr)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(requeueAfter(breaker.Backoff)))
+
+		cond := backoffCondition()
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(cond.Reason).To(Equal(string(conditions.RepeatedFailures)))
+		Expect(cond.Message).To(Equal("Reconcile failed 3 consecutive times, retrying every 30m0s: " +
+			"failed to apply the rendered manifests"))
+	})
+
+	It("resets after a successful reconcile", func() {
+		for i := 0; i < breaker.Threshold; i++ {
+			_, _ = breaker.handleReconcileResult(ctx, c, clusterInstance, ctrl.Result{}, reconcileErr)
+		}
+		Expect(backoffCondition()).ToNot(BeNil())
+
+		res, err := breaker.handleReconcileResult(ctx, c, clusterInstance, waitForEvent(), nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(waitForEvent()))
+		Expect(backoffCondition()).To(BeNil())
+
+		// The failures are counted from zero again
+		_, err = breaker.handleReconcileResult(ctx, c, clusterInstance, ctrl.Result{}, reconcileErr)
+		Expect(err).To(MatchError(reconcileErr))
+		Expect(backoffCondition()).To(BeNil())
+	})
+
+	It("reconstructs the tripped state from the status after a leader change", func() {
+		for i := 0; i < breaker.Threshold; i++ {
+			_, _ = breaker.handleReconcileResult(ctx, c, clusterInstance, ctrl.Result{}, reconcileErr)
+		}
+		Expect(backoffCondition()).ToNot(BeNil())
+
+		// The new leader starts without the failure counts of the previous one
+		newLeader := &CircuitBreaker{Threshold: breaker.Threshold, Backoff: breaker.Backoff}
+		res, err := newLeader.handleReconcileResult(ctx, c, clusterInstance, ctrl.Result{}, reconcileErr)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(requeueAfter(newLeader.Backoff)))
+		Expect(backoffCondition()).ToNot(BeNil())
+
+		// A successful reconcile still resets the breaker
+		_, err = newLeader.handleReconcileResult(ctx, c, clusterInstance, waitForEvent(), nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(backoffCondition()).To(BeNil())
+		_, err = newLeader.handleReconcileResult(ctx, c, clusterInstance, ctrl.Result{}, reconcileErr)
+		Expect(err).To(MatchError(reconcileErr))
+	})
+
+	It("counts the failures of each ClusterInstance separately", func() {
+		other := testParams.GenerateSNOClusterInstance()
+		other.Name = "other-cluster"
+		Expect(c.Create(ctx, other)).To(Succeed())
+
+		for i := 1; i < breaker.Threshold; i++ {
+			_, _ = breaker.handleReconcileResult(ctx, c, clusterInstance, ctrl.Result{}, reconcileErr)
+		}
+		_, err := breaker.handleReconcileResult(ctx, c, other, ctrl.Result{}, reconcileErr)
+		Expect(err).To(MatchError(reconcileErr))
+	})
+
+	It("does not count the errors that are not requeued", func() {
+		validationErr := &ci.ValidationError{Reason: conditions.ClusterNameInvalid, Err: fmt.Errorf("invalid")}
+		res, terminalErr := requeueWithError(validationErr)
+		for i := 0; i < breaker.Threshold; i++ {
+			_, err := breaker.handleReconcileResult(ctx, c, clusterInstance, res, terminalErr)
+			Expect(isNotRequeued(err)).To(BeTrue())
+		}
+		Expect(backoffCondition()).To(BeNil())
+	})
+
+	It("never trips when disabled", func() {
+		for _, disabled := range []*CircuitBreaker{nil, {Threshold: 0, Backoff: time.Minute}} {
+			for i := 0; i < 10; i++ {
+				_, err := disabled.handleReconcileResult(ctx, c, clusterInstance, ctrl.Result{}, reconcileErr)
+				Expect(err).To(MatchError(reconcileErr))
+			}
+		}
+		Expect(backoffCondition()).To(BeNil())
+	})
+})
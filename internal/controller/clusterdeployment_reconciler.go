@@ -18,6 +18,10 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -40,39 +44,145 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
+// provisioningSafetyNetInterval is the interval at which an in-progress provisioning is re-evaluated, in case a
+// ClusterDeployment update is missed
+const provisioningSafetyNetInterval = 10 * time.Minute
+
+// releaseImageUnreachableRequeueInterval is the interval at which an unreachable release image is re-evaluated, to
+// allow for the mirror or network to recover
+const releaseImageUnreachableRequeueInterval = 2 * time.Minute
+
+// installedVersionRequeueInterval is the interval at which the installed version of a provisioned cluster is
+// re-evaluated until the ClusterDeployment reports it
+const installedVersionRequeueInterval = 30 * time.Second
+
+// installedVersionWaitPeriod bounds the re-evaluation of the installed version after the provisioning completed, the
+// version is then only picked up from the ClusterDeployment update that reports it
+const installedVersionWaitPeriod = 5 * time.Minute
+
+// DefaultProvisioningFailureGracePeriod is the default duration for which a reported installation failure must persist
+// before the ClusterInstance is marked as failed, long enough for Hive to clear a transient failure on retry
+const DefaultProvisioningFailureGracePeriod = 5 * time.Minute
+
+// SkipClusterDeploymentRefInitAnnotation disables the initialization of the ClusterInstance
+// Status.ClusterDeploymentRef when set to "true", so that the reference can be managed by external tooling
+const SkipClusterDeploymentRefInitAnnotation = v1alpha1.Group + "/skip-cluster-deployment-ref-init"
+
+// RebuildStatusAnnotation requests the ClusterInstance DeploymentConditions to be cleared and rebuilt from the current
+// ClusterDeployment conditions, it is removed once the conditions are rebuilt
+const RebuildStatusAnnotation = v1alpha1.Group + "/rebuild-status"
+
 // ClusterDeploymentReconciler reconciles a ClusterDeployment object to
 // update the ClusterInstance cluster deployment status conditions
 type ClusterDeploymentReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+	// ProvisioningFailureGracePeriod is the duration for which a reported installation failure must persist before
+	// the ClusterInstance is marked as failed, so that failures cleared by Hive retries are not reported
+	ProvisioningFailureGracePeriod time.Duration
+	// PauseSwitch identifies the ConfigMap pausing all reconciliation, nil if pausing is disabled
+	PauseSwitch *PauseSwitch
+	// AdditionalConditionTypes are the ClusterDeployment condition types mirrored into the ClusterInstance
+	// DeploymentConditions in addition to the install and diagnostic condition types
+	AdditionalConditionTypes []hivev1.ClusterDeploymentConditionType
+	// WatchedNamespaces restricts the reconciled ClusterDeployments to the given namespaces, all if empty
+	WatchedNamespaces WatchedNamespaces
+	// ConditionProbeInterval is the minimum interval at which the LastProbeTime of the unchanged DeploymentConditions
+	// is refreshed, causing a status write of its own. When zero, the probe times are only written along with other
+	// status changes.
+	ConditionProbeInterval time.Duration
+	// ReasonAnnotations maps reasons of the ClusterDeployment conditions to the ClusterInstance annotations exposing
+	// their messages
+	ReasonAnnotations ReasonAnnotations
+	// InstallReports enables the generation of the install report ConfigMap of the ClusterInstances once their
+	// provisioning reached a terminal phase
+	InstallReports bool
+	// InstallSecretsExpiryReasons are the reasons of the install failures caused by an expired install or ignition
+	// token, they trigger the regeneration of the install secrets. DefaultInstallSecretsExpiryReasons are used when
+	// empty.
+	InstallSecretsExpiryReasons []string
 }
 
 func (r *ClusterDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	if paused, err := r.PauseSwitch.IsPaused(ctx, r.Client); err != nil {
+		return requeueWithError(err)
+	} else if paused {
+		r.Log.Info("Reconciliation is paused", "name", req.NamespacedName)
+		return requeueAfter(pausedRequeueInterval), nil
+	}
+
+	if !r.WatchedNamespaces.Contains(req.Namespace) {
+		r.Log.Info("ClusterDeployment is not in a watched namespace, ignoring it", "name", req.NamespacedName)
+		return completed(), nil
+	}
+
 	// Get the ClusterDeployment CR
 	clusterDeployment := &hivev1.ClusterDeployment{}
 	if err := r.Get(ctx, req.NamespacedName, clusterDeployment); err != nil {
 		if errors.IsNotFound(err) {
-			r.Log.Info("ClusterDeployment not found", "name", clusterDeployment.Name)
-			return doNotRequeue(), nil
+			r.Log.Info("ClusterDeployment not found", "name", req.NamespacedName)
+			// The ClusterDeployment is gone once the cluster is deprovisioned
+			if err := r.completeDeprovisioning(ctx, req.NamespacedName); err != nil {
+				return requeueWithError(err)
+			}
+			return completed(), nil
 		}
 		r.Log.Error(err, "Failed to get ClusterDeployment")
 		// This is likely a case where the API is down, so requeue and try again shortly
 		return requeueWithError(err)
 	}
 
-	// Fetch ClusterInstance associated with ClusterDeployment object
-	clusterInstance, err := r.getClusterInstance(ctx, clusterDeployment)
-	if clusterInstance == nil {
-		return doNotRequeue(), nil
-	} else if err != nil {
-		return requeueWithError(err)
+	var clusterInstance *v1alpha1.ClusterInstance
+	if isOwnedByClusterInstance(clusterDeployment) {
+		// Fetch ClusterInstance associated with ClusterDeployment object
+		owner, err := getOwnerClusterInstance(ctx, r.Client, r.Log, clusterDeployment)
+		if err != nil {
+			return requeueWithError(err)
+		}
+		if owner == nil {
+			return completed(), nil
+		}
+
+		// Do not act on a ghost, i.e. a ClusterInstance of the same name that does not own the ClusterDeployment
+		// and re-evaluate it on its next event rather than polling for a ClusterInstance that may never match
+		if !isOwnerUIDMatching(clusterDeployment, owner) {
+			r.Log.Info("ClusterInstance UID does not match the ClusterDeployment owner, skipping",
+				"ClusterDeployment", req.NamespacedName, "ClusterInstance UID", owner.UID)
+			return completed(), nil
+		}
+		clusterInstance = owner
+	} else {
+		// A ClusterDeployment applied by the user is mirrored into the observe-only ClusterInstance referencing it
+		observer, err := getObservingClusterInstance(ctx, r.Client, clusterDeployment)
+		if err != nil {
+			return requeueWithError(err)
+		}
+		// Ignore any other ClusterDeployment not owned by a ClusterInstance, the watch predicates filter them out but
+		// they can still be enqueued through the ClusterInstance mapping, e.g. when the ClusterDeploymentRef is
+		// managed externally
+		if observer == nil {
+			r.Log.Info("ClusterDeployment is not owned by a ClusterInstance, ignoring it", "name", req.NamespacedName)
+			return completed(), nil
+		}
+		clusterInstance = observer
+	}
+
+	// Leave the status of a terminating ClusterInstance alone while its finalizer deletes the rendered manifests,
+	// mirroring the conditions of the ClusterDeployment being deleted along with it is wasteful and conflicts with the
+	// finalizer handling
+	if !clusterInstance.DeletionTimestamp.IsZero() {
+		r.Log.Info("ClusterInstance is being deleted, skipping the status update", "ClusterInstance",
+			clusterInstance.Name)
+		return completed(), nil
 	}
 
-	patch := client.MergeFrom(clusterInstance.DeepCopy())
+	original := clusterInstance.DeepCopy()
+	patch := client.MergeFrom(original)
 
-	// Initialize ClusterInstance clusterdeployment reference if unset
-	if clusterInstance.Status.ClusterDeploymentRef == nil || clusterInstance.Status.ClusterDeploymentRef.Name == "" {
+	// Initialize ClusterInstance clusterdeployment reference if unset, unless it is managed externally
+	if clusterInstance.GetAnnotations()[SkipClusterDeploymentRefInitAnnotation] != "true" &&
+		(clusterInstance.Status.ClusterDeploymentRef == nil || clusterInstance.Status.ClusterDeploymentRef.Name == "") {
 		clusterInstance.Status.ClusterDeploymentRef = &corev1.LocalObjectReference{Name: clusterDeployment.Name}
 	}
 
@@ -86,16 +196,243 @@ func (r *ClusterDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Re
 			conditions.Provisioned,
 			conditions.Unknown,
 			metav1.ConditionUnknown,
-			"Waiting for provisioning to start")
+			provisioningWaitMessage(clusterInstance))
 	}
 
-	updateCIProvisionedStatus(clusterDeployment, clusterInstance, r.Log)
-	updateCIDeploymentConditions(clusterDeployment, clusterInstance)
-	if updateErr := conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch); updateErr != nil {
-		return requeueWithError(updateErr)
+	failureGraceRemaining := updateCIProvisionedStatus(clusterDeployment, clusterInstance, r.Log,
+		r.ProvisioningFailureGracePeriod)
+	updateCIDeprovisionedStatus(clusterDeployment, clusterInstance)
+	releaseImageUnreachable := updateCIReleaseImageStatus(clusterDeployment, clusterInstance)
+	_, rebuildStatus := clusterInstance.GetAnnotations()[RebuildStatusAnnotation]
+	if rebuildStatus {
+		r.Log.Info("Rebuilding the DeploymentConditions", "ClusterInstance", clusterInstance.Name)
+		clusterInstance.Status.DeploymentConditions = nil
+	}
+	updateCIDeploymentConditions(clusterDeployment, clusterInstance, r.ConditionProbeInterval,
+		r.AdditionalConditionTypes...)
+	if rebuildStatus {
+		restoreCDTransitionTimes(clusterDeployment, clusterInstance)
+	}
+	updateCIClusterURLs(clusterDeployment, clusterInstance)
+	updateCIInstallLogsRef(clusterDeployment, clusterInstance)
+	updateCIConsistencyStatus(clusterDeployment, clusterInstance)
+	installedVersionPending := updateCIInstalledVersion(clusterDeployment, clusterInstance)
+	updateCIInfraID(clusterDeployment, clusterInstance)
+	// Skip the patch when only the probe times of the mirrored conditions were refreshed, unless they are refreshed on
+	// their own once older than the probe interval
+	probeTimesRefreshed := r.ConditionProbeInterval > 0 && conditions.CDProbeTimesChanged(
+		original.Status.DeploymentConditions, clusterInstance.Status.DeploymentConditions)
+	if rebuildStatus || probeTimesRefreshed || conditions.StatusChanged(&original.Status, &clusterInstance.Status) {
+		if updateErr := conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch); updateErr != nil {
+			return requeueWithError(updateErr)
+		}
+	}
+	if rebuildStatus {
+		if err := r.removeRebuildStatusAnnotation(ctx, clusterInstance); err != nil {
+			return requeueWithError(err)
+		}
+	}
+	if err := r.updateReasonAnnotations(ctx, clusterDeployment, clusterInstance); err != nil {
+		return requeueWithError(err)
+	}
+	if err := r.updateInstallReport(ctx, clusterInstance); err != nil {
+		return requeueWithError(err)
+	}
+	r.Log.Info("Updated ClusterInstance status from ClusterDeployment", "ClusterInstance", clusterInstance.Name,
+		"summary", conditions.Summarize(clusterInstance))
+
+	// Deprovision the partially installed cluster once its provisioning failed, if requested
+	if shouldDeprovisionOnFailure(clusterDeployment, clusterInstance) {
+		if err := r.deprovisionFailedCluster(ctx, clusterDeployment, clusterInstance); err != nil {
+			return requeueWithError(err)
+		}
+		// Wait for the ClusterDeployment to report the deprovisioning progress
+		return waitForEvent(provisioningSafetyNetInterval), nil
+	}
+
+	// Wait for the release image to become reachable, e.g. once the mirror or network recovered
+	if releaseImageUnreachable {
+		return requeueAfter(releaseImageUnreachableRequeueInterval), nil
+	}
+
+	// Regenerate the install secrets before the installation is retried, rather than retrying with an expired token
+	if installFailed := r.installSecretsExpiryFailure(clusterDeployment); installFailed != nil &&
+		shouldRegenerateInstallSecrets(installFailed, clusterInstance) {
+		if err := r.requestInstallSecretsRegeneration(ctx, installFailed, clusterInstance); err != nil {
+			return requeueWithError(err)
+		}
+	}
+
+	// Re-check the reported failure once the grace period has elapsed
+	if failureGraceRemaining > 0 {
+		return requeueAfter(failureGraceRemaining), nil
+	}
+
+	// Wait for the ClusterDeployment to report the version of the provisioned cluster
+	if installedVersionPending {
+		return requeueAfter(installedVersionRequeueInterval), nil
 	}
 
-	return doNotRequeue(), nil
+	if isProvisioningFinished(clusterInstance) {
+		return completed(), nil
+	}
+	// Wait for the ClusterDeployment to report further provisioning progress
+	return waitForEvent(provisioningSafetyNetInterval), nil
+}
+
+// DefaultInstallSecretsExpiryReasons are the reasons of the install failures caused by an expired install or
+// ignition token, as reported by the ClusterInstallFailed or ProvisionFailed conditions of the ClusterDeployment, e.g.
+// through the install log regexes of Hive
+var DefaultInstallSecretsExpiryReasons = []string{"InstallTokenExpired", "IgnitionTokenExpired"}
+
+// installSecretsExpiryFailure returns the failed install condition of the ClusterDeployment reporting one of the
+// InstallSecretsExpiryReasons, nil if the installation did not fail due to an expired token
+func (r *ClusterDeploymentReconciler) installSecretsExpiryFailure(
+	cd *hivev1.ClusterDeployment,
+) *hivev1.ClusterDeploymentCondition {
+	reasons := r.InstallSecretsExpiryReasons
+	if len(reasons) == 0 {
+		reasons = DefaultInstallSecretsExpiryReasons
+	}
+	for _, conditionType := range []hivev1.ClusterDeploymentConditionType{
+		hivev1.ClusterInstallFailedClusterDeploymentCondition,
+		hivev1.ProvisionFailedCondition,
+	} {
+		failed := conditions.FindCDConditionType(cd.Status.Conditions, conditionType)
+		if failed != nil && failed.Status == corev1.ConditionTrue && slices.Contains(reasons, failed.Reason) {
+			return failed
+		}
+	}
+	return nil
+}
+
+// shouldRegenerateInstallSecrets returns true if the install secrets have not been regenerated since the installation
+// failure caused by an expired token was reported
+func shouldRegenerateInstallSecrets(
+	installFailed *hivev1.ClusterDeploymentCondition,
+	ci *v1alpha1.ClusterInstance,
+) bool {
+	if _, requested := ci.GetAnnotations()[RegenerateInstallSecretsAnnotation]; requested {
+		return false
+	}
+	lastRegeneration := ci.Status.LastInstallSecretsRegeneration
+	return lastRegeneration == nil || lastRegeneration.Time.Before(installFailedSince(installFailed, ci))
+}
+
+// requestInstallSecretsRegeneration annotates the ClusterInstance for its install secrets to be regenerated
+func (r *ClusterDeploymentReconciler) requestInstallSecretsRegeneration(
+	ctx context.Context,
+	installFailed *hivev1.ClusterDeploymentCondition,
+	ci *v1alpha1.ClusterInstance,
+) error {
+	r.Log.Info("Installation failed due to an expired token, requesting the regeneration of the install secrets",
+		"ClusterInstance", ci.Name, "reason", installFailed.Reason)
+
+	patch := client.MergeFrom(ci.DeepCopy())
+	annotations := ci.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[RegenerateInstallSecretsAnnotation] = installFailedSince(installFailed, ci).Format(time.RFC3339)
+	ci.SetAnnotations(annotations)
+	return r.Patch(ctx, ci, patch)
+}
+
+// shouldDeprovisionOnFailure returns true if the ClusterInstance requests the deprovisioning of a failed cluster and
+// its provisioning failure has been committed, unless the ClusterDeployment is already being deleted or is applied by
+// the user, i.e. observe-only
+func shouldDeprovisionOnFailure(cd *hivev1.ClusterDeployment, ci *v1alpha1.ClusterInstance) bool {
+	if !ci.Spec.DeprovisionOnFailure || ci.Spec.ObserveOnlyClusterDeployment || !cd.DeletionTimestamp.IsZero() ||
+		cd.Spec.Installed {
+		return false
+	}
+	provisioned := meta.FindStatusCondition(ci.Status.Conditions, string(conditions.Provisioned))
+	return provisioned != nil && provisioned.Reason == string(conditions.Failed)
+}
+
+// deprovisionFailedCluster deletes the ClusterDeployment of the failed cluster, for Hive to deprovision it
+func (r *ClusterDeploymentReconciler) deprovisionFailedCluster(
+	ctx context.Context,
+	cd *hivev1.ClusterDeployment,
+	ci *v1alpha1.ClusterInstance,
+) error {
+	r.Log.Info("Provisioning failed, deleting the ClusterDeployment to deprovision the cluster",
+		"ClusterInstance", ci.Name, "ClusterDeployment", cd.Name)
+	if err := r.Delete(ctx, cd); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to delete ClusterDeployment %s/%s: %w", cd.Namespace, cd.Name, err)
+	}
+	return nil
+}
+
+// releaseImageUnreachableReasons maps the ClusterDeployment conditions reporting a failure to pull the release image
+// to the reasons identifying it; an empty list matches any reason the condition is set with
+var releaseImageUnreachableReasons = map[hivev1.ClusterDeploymentConditionType][]string{
+	hivev1.InstallImagesNotResolvedCondition:              {},
+	hivev1.ProvisionFailedCondition:                       {"ErrImagePull", "ImagePullBackOff"},
+	hivev1.ClusterInstallFailedClusterDeploymentCondition: {"ErrImagePull", "ImagePullBackOff"},
+}
+
+// findReleaseImageUnreachableCondition returns the ClusterDeployment condition reporting that the release image could
+// not be pulled, nil if there is none
+func findReleaseImageUnreachableCondition(cd *hivev1.ClusterDeployment) *hivev1.ClusterDeploymentCondition {
+	for i := range cd.Status.Conditions {
+		cond := &cd.Status.Conditions[i]
+		reasons, ok := releaseImageUnreachableReasons[cond.Type]
+		if !ok || cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		if len(reasons) == 0 || slices.Contains(reasons, cond.Reason) {
+			return cond
+		}
+	}
+	return nil
+}
+
+// updateCIReleaseImageStatus sets the ClusterInstance Provisioned condition reason to ReleaseImageUnreachable when
+// the ClusterDeployment reports that the release image could not be pulled, rather than a generic provisioning
+// failure. Returns true if the release image is unreachable.
+func updateCIReleaseImageStatus(cd *hivev1.ClusterDeployment, ci *v1alpha1.ClusterInstance) bool {
+	if cd.Spec.Installed || !cd.DeletionTimestamp.IsZero() {
+		return false
+	}
+
+	cond := findReleaseImageUnreachableCondition(cd)
+	if cond == nil {
+		return false
+	}
+
+	conditions.SetStatusCondition(&ci.Status.Conditions,
+		conditions.Provisioned,
+		conditions.ReleaseImageUnreachable,
+		metav1.ConditionFalse,
+		conditions.SanitizeMessage(fmt.Sprintf("Release image of ClusterImageSet %s is unreachable (%s: %s)",
+			ci.Spec.ClusterImageSetNameRef, cond.Reason, cond.Message), conditions.MaxMessageLength))
+	return true
+}
+
+// isProvisioningFinished returns true if the ClusterInstance Provisioned condition reports a completed, failed or
+// deprovisioned cluster
+func isProvisioningFinished(ci *v1alpha1.ClusterInstance) bool {
+	provisioned := meta.FindStatusCondition(ci.Status.Conditions, string(conditions.Provisioned))
+	if provisioned == nil {
+		return false
+	}
+	switch conditions.ConditionReason(provisioned.Reason) {
+	case conditions.Completed, conditions.Failed, conditions.Deprovisioned:
+		return true
+	}
+	return false
+}
+
+// diagnosticConditionTypes are the ClusterDeployment condition types that can explain a provisioning failure, they are
+// mirrored into the ClusterInstance DeploymentConditions once reported by the ClusterDeployment
+func diagnosticConditionTypes() []hivev1.ClusterDeploymentConditionType {
+	return []hivev1.ClusterDeploymentConditionType{
+		hivev1.AuthenticationFailureClusterDeploymentCondition,
+		hivev1.DNSNotReadyCondition,
+		hivev1.InstallImagesNotResolvedCondition,
+		hivev1.ProvisionFailedCondition,
+	}
 }
 
 func clusterInstallConditionTypes() []hivev1.ClusterDeploymentConditionType {
@@ -107,66 +444,156 @@ func clusterInstallConditionTypes() []hivev1.ClusterDeploymentConditionType {
 	}
 }
 
-func updateCIProvisionedStatus(cd *hivev1.ClusterDeployment, ci *v1alpha1.ClusterInstance, log logr.Logger) {
-
-	installStopped := conditions.FindCDConditionType(cd.Status.Conditions,
-		hivev1.ClusterInstallStoppedClusterDeploymentCondition)
-
-	installCompleted := conditions.FindCDConditionType(cd.Status.Conditions,
-		hivev1.ClusterInstallCompletedClusterDeploymentCondition)
-
-	installFailed := conditions.FindCDConditionType(cd.Status.Conditions,
-		hivev1.ClusterInstallFailedClusterDeploymentCondition)
+// setProvisionedTerminalCondition sets the Provisioned condition to a terminal (Failed or Deprovisioned) reason.
+// The LastTransitionTime is reset whenever the reason changes, so that it reflects the time at which the terminal
+// state was reached, even though the condition status remains False.
+func setProvisionedTerminalCondition(ci *v1alpha1.ClusterInstance, reason conditions.ConditionReason, message string) {
+	if provisioned := meta.FindStatusCondition(ci.Status.Conditions, string(conditions.Provisioned)); provisioned != nil &&
+		provisioned.Reason != string(reason) {
+		meta.RemoveStatusCondition(&ci.Status.Conditions, string(conditions.Provisioned))
+	}
+	conditions.SetStatusCondition(&ci.Status.Conditions,
+		conditions.Provisioned,
+		reason,
+		metav1.ConditionFalse,
+		message)
+}
 
-	if installStopped == nil || installCompleted == nil || installFailed == nil {
-		log.Info("Failed to extract condition(s)", "name", cd.Name)
-		return
+// installFailedSince returns the time at which the ClusterDeployment reported the installation failure, falling back
+// to the transition time recorded in the ClusterInstance DeploymentConditions, or now if none is known
+func installFailedSince(installFailed *hivev1.ClusterDeploymentCondition, ci *v1alpha1.ClusterInstance) time.Time {
+	if !installFailed.LastTransitionTime.IsZero() {
+		return installFailed.LastTransitionTime.Time
 	}
+	if ciCond := conditions.FindCDConditionType(ci.Status.DeploymentConditions,
+		hivev1.ClusterInstallFailedClusterDeploymentCondition); ciCond != nil &&
+		ciCond.Status == corev1.ConditionTrue && !ciCond.LastTransitionTime.IsZero() {
+		return ciCond.LastTransitionTime.Time
+	}
+	return time.Now()
+}
 
-	// Check whether cluster has finished provisioning
-	if cd.Spec.Installed {
-		// Check for successful provisioning
-		if installStopped.Status == corev1.ConditionTrue && installCompleted.Status == corev1.ConditionTrue {
-			conditions.SetStatusCondition(&ci.Status.Conditions,
-				conditions.Provisioned,
-				conditions.Completed,
-				metav1.ConditionTrue,
-				"Provisioning completed")
-			return
-		}
-		// Check for stale deployment conditions:
-		//  - either Stopped OR Completed deployment conditions are reflecting a `ConditionFalse` status
-		if installStopped.Status == corev1.ConditionFalse || installCompleted.Status == corev1.ConditionFalse {
+// updateCIProvisionedStatus updates the ClusterInstance Provisioned condition from the ClusterDeployment install
+// conditions, as derived by conditions.DeriveProvisionedState. A reported installation failure is only committed once
+// it persisted for the failureGracePeriod, the remaining grace period is returned while it has not elapsed.
+func updateCIProvisionedStatus(
+	cd *hivev1.ClusterDeployment,
+	ci *v1alpha1.ClusterInstance,
+	log logr.Logger,
+	failureGracePeriod time.Duration,
+) time.Duration {
+
+	reason, status, message := conditions.DeriveProvisionedState(cd)
+	switch reason {
+	case "":
+		log.Info("Unable to derive the provisioned state from the ClusterDeployment conditions", "name", cd.Name)
+	case conditions.Deprovisioned:
+		setProvisionedTerminalCondition(ci, reason, message)
+	case conditions.Failed:
+		// Give Hive the chance to retry the installation before committing the failure
+		installFailed := conditions.FindCDConditionType(cd.Status.Conditions,
+			hivev1.ClusterInstallFailedClusterDeploymentCondition)
+		if remaining := failureGracePeriod - time.Since(installFailedSince(installFailed, ci)); remaining > 0 {
 			conditions.SetStatusCondition(&ci.Status.Conditions,
 				conditions.Provisioned,
-				conditions.StaleConditions,
-				metav1.ConditionUnknown,
-				"ClusterDeployment Spec.Installed=true, but Status.Conditions are not updated")
-			return
+				conditions.InProgress,
+				metav1.ConditionFalse,
+				fmt.Sprintf("Provisioning failure reported, waiting up to %s for it to clear", failureGracePeriod))
+			return remaining
 		}
+		setProvisionedTerminalCondition(ci, reason, message)
+	default:
+		conditions.SetStatusCondition(&ci.Status.Conditions, conditions.Provisioned, reason, status, message)
 	}
+	return 0
+}
 
-	// Check whether cluster has failed provisioning
-	if installStopped.Status == corev1.ConditionTrue && installFailed.Status == corev1.ConditionTrue {
-		conditions.SetStatusCondition(&ci.Status.Conditions,
-			conditions.Provisioned,
-			conditions.Failed,
-			metav1.ConditionFalse,
-			"Provisioning failed")
+// updateCIDeprovisionedStatus updates the ClusterInstance Deprovisioned condition from the ClusterDeployment
+// deprovision status, once the ClusterDeployment is being deleted
+func updateCIDeprovisionedStatus(cd *hivev1.ClusterDeployment, ci *v1alpha1.ClusterInstance) {
+	if cd.DeletionTimestamp.IsZero() {
 		return
 	}
 
-	// Check whether provisioning is in-progress
-	if installStopped.Status == corev1.ConditionFalse {
+	provisioned := conditions.FindCDConditionType(cd.Status.Conditions, hivev1.ProvisionedCondition)
+	launchError := conditions.FindCDConditionType(cd.Status.Conditions, hivev1.DeprovisionLaunchErrorCondition)
+
+	switch {
+	case provisioned != nil && provisioned.Reason == hivev1.ProvisionedReasonDeprovisioned:
 		conditions.SetStatusCondition(&ci.Status.Conditions,
-			conditions.Provisioned,
+			conditions.ClusterDeprovisioned,
+			conditions.Completed,
+			metav1.ConditionTrue,
+			"Deprovisioning completed")
+	case provisioned != nil && provisioned.Reason == hivev1.ProvisionedReasonDeprovisionFailed:
+		conditions.SetStatusCondition(&ci.Status.Conditions,
+			conditions.ClusterDeprovisioned,
+			conditions.Failed,
+			metav1.ConditionFalse,
+			conditions.SanitizeMessage(fmt.Sprintf("Deprovisioning failed: %s", provisioned.Message),
+				conditions.MaxMessageLength))
+	case launchError != nil && launchError.Status == corev1.ConditionTrue:
+		conditions.SetStatusCondition(&ci.Status.Conditions,
+			conditions.ClusterDeprovisioned,
+			conditions.Failed,
+			metav1.ConditionFalse,
+			conditions.SanitizeMessage(fmt.Sprintf("Deprovisioning failed to launch: %s", launchError.Message),
+				conditions.MaxMessageLength))
+	default:
+		conditions.SetStatusCondition(&ci.Status.Conditions,
+			conditions.ClusterDeprovisioned,
 			conditions.InProgress,
 			metav1.ConditionFalse,
-			"Provisioning cluster")
+			"Deprovisioning cluster")
+	}
+}
+
+// completeDeprovisioning marks the deprovisioning of the ClusterInstance referencing the deleted ClusterDeployment as
+// completed, if it was being tracked and the ClusterInstance is not being deleted
+func (r *ClusterDeploymentReconciler) completeDeprovisioning(ctx context.Context, cdKey types.NamespacedName) error {
+	clusterInstances := &v1alpha1.ClusterInstanceList{}
+	if err := r.List(ctx, clusterInstances, client.InNamespace(cdKey.Namespace)); err != nil {
+		return err
+	}
+
+	for i := range clusterInstances.Items {
+		clusterInstance := &clusterInstances.Items[i]
+		if ref := clusterInstance.Status.ClusterDeploymentRef; ref == nil || ref.Name != cdKey.Name ||
+			!clusterInstance.DeletionTimestamp.IsZero() {
+			continue
+		}
+		deprovisioned := meta.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.ClusterDeprovisioned))
+		if deprovisioned == nil || deprovisioned.Status == metav1.ConditionTrue {
+			continue
+		}
+
+		patch := client.MergeFrom(clusterInstance.DeepCopy())
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+			conditions.ClusterDeprovisioned,
+			conditions.Completed,
+			metav1.ConditionTrue,
+			"Deprovisioning completed, ClusterDeployment deleted")
+		if err := conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch); err != nil {
+			return err
+		}
+		r.Log.Info("Deprovisioning completed", "ClusterInstance", clusterInstance.Name)
 	}
+	return nil
 }
 
-func updateCIDeploymentConditions(cd *hivev1.ClusterDeployment, ci *v1alpha1.ClusterInstance) {
+// updateCIDeploymentConditions mirrors the ClusterDeployment install conditions into the ClusterInstance
+// DeploymentConditions, a missing install condition is reported as Unknown. The diagnostic and additional condition
+// types are only mirrored once reported by the ClusterDeployment. The probe time of an unchanged condition is only
+// refreshed once older than the probeInterval.
+func updateCIDeploymentConditions(
+	cd *hivev1.ClusterDeployment,
+	ci *v1alpha1.ClusterInstance,
+	probeInterval time.Duration,
+	additionalConditionTypes ...hivev1.ClusterDeploymentConditionType,
+) {
+	now := metav1.NewTime(time.Now())
+
 	// Compare ClusterInstance.Status.installConditions to clusterDeployment.Conditions
 	for _, cond := range clusterInstallConditionTypes() {
 		installCond := conditions.FindCDConditionType(cd.Status.Conditions, cond)
@@ -178,58 +605,253 @@ func updateCIDeploymentConditions(cd *hivev1.ClusterDeployment, ci *v1alpha1.Clu
 				Reason:  "Unknown",
 				Message: "Unknown"}
 		}
+		mirrorCDCondition(ci, installCond, now, probeInterval)
+	}
 
-		now := metav1.NewTime(time.Now())
-
-		// Search ClusterInstance status DeploymentConditions for the installCond
-		ciCond := conditions.FindCDConditionType(ci.Status.DeploymentConditions, installCond.Type)
-		if ciCond == nil {
-			installCond.LastTransitionTime = now
-			installCond.LastProbeTime = now
-			ci.Status.DeploymentConditions = append(ci.Status.DeploymentConditions, *installCond)
-		} else {
-			ciCond.Status = installCond.Status
-			ciCond.Reason = installCond.Reason
-			ciCond.Message = installCond.Message
-			ciCond.LastProbeTime = now
-
-			if ciCond.Status != installCond.Status {
-				ciCond.LastTransitionTime = now
-			}
+	// The tracked condition types may overlap, each is mirrored once
+	mirrored := map[hivev1.ClusterDeploymentConditionType]bool{}
+	for _, cond := range clusterInstallConditionTypes() {
+		mirrored[cond] = true
+	}
+	for _, cond := range append(diagnosticConditionTypes(), additionalConditionTypes...) {
+		if mirrored[cond] {
+			continue
+		}
+		mirrored[cond] = true
+		if cdCond := conditions.FindCDConditionType(cd.Status.Conditions, cond); cdCond != nil {
+			mirrorCDCondition(ci, cdCond, now, probeInterval)
 		}
 	}
+
+	sortDeploymentConditions(ci.Status.DeploymentConditions, additionalConditionTypes...)
 }
 
-func clusterInstanceOwner(ownerRefs []metav1.OwnerReference) string {
-	for _, ownerRef := range ownerRefs {
-		if ownerRef.Kind == v1alpha1.ClusterInstanceKind {
-			return ownerRef.Name
+// sortDeploymentConditions sorts the DeploymentConditions into their canonical order, so that the status does not
+// churn with the order in which the conditions were first mirrored: the install condition types, the diagnostic
+// condition types and the additional condition types, in the order they are listed, followed by any other condition
+// type (e.g. an additional condition type that is no longer configured) in alphabetical order
+func sortDeploymentConditions(
+	deploymentConditions []hivev1.ClusterDeploymentCondition,
+	additionalConditionTypes ...hivev1.ClusterDeploymentConditionType,
+) {
+	rank := map[hivev1.ClusterDeploymentConditionType]int{}
+	canonical := append(append(clusterInstallConditionTypes(), diagnosticConditionTypes()...),
+		additionalConditionTypes...)
+	for _, cond := range canonical {
+		if _, found := rank[cond]; !found {
+			rank[cond] = len(rank)
+		}
+	}
+
+	sort.SliceStable(deploymentConditions, func(i, j int) bool {
+		rankI, rankedI := rank[deploymentConditions[i].Type]
+		rankJ, rankedJ := rank[deploymentConditions[j].Type]
+		switch {
+		case rankedI && rankedJ:
+			return rankI < rankJ
+		case rankedI != rankedJ:
+			return rankedI
+		default:
+			return deploymentConditions[i].Type < deploymentConditions[j].Type
 		}
+	})
+}
+
+// mirrorCDCondition mirrors the ClusterDeployment condition into the matching ClusterInstance DeploymentCondition,
+// which is added if not found
+func mirrorCDCondition(
+	ci *v1alpha1.ClusterInstance,
+	cdCond *hivev1.ClusterDeploymentCondition,
+	now metav1.Time,
+	probeInterval time.Duration,
+) {
+	// Search ClusterInstance status DeploymentConditions for the cdCond
+	ciCond := conditions.FindCDConditionType(ci.Status.DeploymentConditions, cdCond.Type)
+	if ciCond == nil {
+		ci.Status.DeploymentConditions = append(ci.Status.DeploymentConditions,
+			hivev1.ClusterDeploymentCondition{Type: cdCond.Type})
+		ciCond = &ci.Status.DeploymentConditions[len(ci.Status.DeploymentConditions)-1]
 	}
-	return ""
+	conditions.MirrorCDCondition(ciCond, cdCond, now, probeInterval)
 }
-func isOwnedByClusterInstance(ownerRefs []metav1.OwnerReference) bool {
-	return clusterInstanceOwner(ownerRefs) != ""
+
+// restoreCDTransitionTimes sets the last transition time of the rebuilt DeploymentConditions to the one reported by
+// the ClusterDeployment, rather than the time of the rebuild
+func restoreCDTransitionTimes(cd *hivev1.ClusterDeployment, ci *v1alpha1.ClusterInstance) {
+	for i := range ci.Status.DeploymentConditions {
+		ciCond := &ci.Status.DeploymentConditions[i]
+		if cdCond := conditions.FindCDConditionType(cd.Status.Conditions, ciCond.Type); cdCond != nil &&
+			!cdCond.LastTransitionTime.IsZero() {
+			ciCond.LastTransitionTime = cdCond.LastTransitionTime
+		}
+	}
 }
 
-func (r *ClusterDeploymentReconciler) getClusterInstance(
+// removeRebuildStatusAnnotation removes the RebuildStatusAnnotation once the DeploymentConditions are rebuilt
+func (r *ClusterDeploymentReconciler) removeRebuildStatusAnnotation(
 	ctx context.Context,
-	cd *hivev1.ClusterDeployment,
+	ci *v1alpha1.ClusterInstance,
+) error {
+	patch := client.MergeFrom(ci.DeepCopy())
+	annotations := ci.GetAnnotations()
+	delete(annotations, RebuildStatusAnnotation)
+	ci.SetAnnotations(annotations)
+	return r.Patch(ctx, ci, patch)
+}
+
+// clusterAPIURL derives the spoke cluster API URL from the cluster name and base domain
+func clusterAPIURL(clusterName, baseDomain string) string {
+	return fmt.Sprintf("https://api.%s.%s:6443", clusterName, baseDomain)
+}
+
+// updateCIClusterURLs sets the ClusterInstance API and console URLs once provisioning has started.
+// The URLs reported by the ClusterDeployment take precedence over the derived API URL.
+func updateCIClusterURLs(cd *hivev1.ClusterDeployment, ci *v1alpha1.ClusterInstance) {
+	provisioned := meta.FindStatusCondition(ci.Status.Conditions, string(conditions.Provisioned))
+	if provisioned == nil || provisioned.Reason == string(conditions.Unknown) {
+		return
+	}
+
+	if cd.Status.APIURL != "" {
+		ci.Status.APIURL = cd.Status.APIURL
+	} else {
+		ci.Status.APIURL = clusterAPIURL(ci.Spec.ClusterName, ci.Spec.BaseDomain)
+	}
+
+	if cd.Status.WebConsoleURL != "" {
+		ci.Status.ConsoleURL = cd.Status.WebConsoleURL
+	}
+}
+
+// updateCIInstalledVersion sets the ClusterInstance InstalledVersion to the version reported by the ClusterDeployment
+// once the cluster is provisioned. It returns true when the cluster is provisioned but the version is not yet reported,
+// within the installedVersionWaitPeriod following the completion of the provisioning.
+func updateCIInstalledVersion(cd *hivev1.ClusterDeployment, ci *v1alpha1.ClusterInstance) bool {
+	provisioned := meta.FindStatusCondition(ci.Status.Conditions, string(conditions.Provisioned))
+	if provisioned == nil || provisioned.Reason != string(conditions.Completed) {
+		return false
+	}
+
+	if cd.Status.InstallVersion == nil || *cd.Status.InstallVersion == "" {
+		return ci.Status.InstalledVersion == "" &&
+			time.Since(provisioned.LastTransitionTime.Time) < installedVersionWaitPeriod
+	}
+	ci.Status.InstalledVersion = *cd.Status.InstallVersion
+	return false
+}
+
+// updateCIInfraID sets the ClusterInstance InfraID to the infraID reported in the ClusterDeployment cluster metadata
+// once the cluster is provisioned, a previously recorded infraID is kept while it is not reported
+func updateCIInfraID(cd *hivev1.ClusterDeployment, ci *v1alpha1.ClusterInstance) {
+	provisioned := meta.FindStatusCondition(ci.Status.Conditions, string(conditions.Provisioned))
+	if provisioned == nil || provisioned.Reason != string(conditions.Completed) {
+		return
+	}
+
+	if cd.Spec.ClusterMetadata == nil || cd.Spec.ClusterMetadata.InfraID == "" {
+		return
+	}
+	ci.Status.InfraID = cd.Spec.ClusterMetadata.InfraID
+}
+
+// updateCIInstallLogsRef sets the ClusterInstance InstallLogsRef to the object holding the installation logs: the
+// cluster install (e.g. the AgentClusterInstall or ImageClusterInstall) referenced by the ClusterDeployment for the
+// agent and image-based installs, which Hive does not run through a ClusterProvision, or otherwise the ClusterProvision
+// of the last installation attempt. The reference is kept once the ClusterDeployment no longer reports it.
+func updateCIInstallLogsRef(cd *hivev1.ClusterDeployment, ci *v1alpha1.ClusterInstance) {
+	if installRef := cd.Spec.ClusterInstallRef; installRef != nil && installRef.Name != "" {
+		apiGroup := installRef.Group
+		ci.Status.InstallLogsRef = &corev1.TypedLocalObjectReference{
+			APIGroup: &apiGroup,
+			Kind:     installRef.Kind,
+			Name:     installRef.Name,
+		}
+		return
+	}
+
+	if cd.Status.ProvisionRef == nil || cd.Status.ProvisionRef.Name == "" {
+		return
+	}
+
+	apiGroup := hivev1.SchemeGroupVersion.Group
+	ci.Status.InstallLogsRef = &corev1.TypedLocalObjectReference{
+		APIGroup: &apiGroup,
+		Kind:     "ClusterProvision",
+		Name:     cd.Status.ProvisionRef.Name,
+	}
+}
+
+// clusterDeploymentInconsistencies returns the identity fields of the ClusterDeployment that do not match the
+// ClusterInstance spec, e.g. after the adoption of the wrong ClusterDeployment or an out-of-band edit. The image set is
+// only compared when the ClusterDeployment references one, i.e. not for the agent installs where it is held by the
+// AgentClusterInstall.
+func clusterDeploymentInconsistencies(cd *hivev1.ClusterDeployment, ci *v1alpha1.ClusterInstance) []string {
+	var inconsistencies []string
+	if cd.Spec.ClusterName != ci.Spec.ClusterName {
+		inconsistencies = append(inconsistencies, fmt.Sprintf("clusterName %q does not match %q",
+			cd.Spec.ClusterName, ci.Spec.ClusterName))
+	}
+	if cd.Spec.BaseDomain != ci.Spec.BaseDomain {
+		inconsistencies = append(inconsistencies, fmt.Sprintf("baseDomain %q does not match %q",
+			cd.Spec.BaseDomain, ci.Spec.BaseDomain))
+	}
+	if cd.Spec.Provisioning != nil && cd.Spec.Provisioning.ImageSetRef != nil &&
+		cd.Spec.Provisioning.ImageSetRef.Name != ci.Spec.ClusterImageSetNameRef {
+		inconsistencies = append(inconsistencies, fmt.Sprintf("imageSetRef %q does not match %q",
+			cd.Spec.Provisioning.ImageSetRef.Name, ci.Spec.ClusterImageSetNameRef))
+	}
+	return inconsistencies
+}
+
+// updateCIConsistencyStatus sets the ClusterInstance ClusterDeploymentInconsistent condition to True when the identity
+// fields of the ClusterDeployment do not match the ClusterInstance spec, False otherwise
+func updateCIConsistencyStatus(cd *hivev1.ClusterDeployment, ci *v1alpha1.ClusterInstance) {
+	if inconsistencies := clusterDeploymentInconsistencies(cd, ci); len(inconsistencies) > 0 {
+		conditions.SetStatusCondition(&ci.Status.Conditions,
+			conditions.ClusterDeploymentInconsistent,
+			conditions.IdentityMismatch,
+			metav1.ConditionTrue,
+			fmt.Sprintf("The ClusterDeployment %s does not match the ClusterInstance spec: %s", cd.Name,
+				strings.Join(inconsistencies, ", ")))
+		return
+	}
+	conditions.SetStatusCondition(&ci.Status.Conditions,
+		conditions.ClusterDeploymentInconsistent,
+		conditions.InSync,
+		metav1.ConditionFalse,
+		fmt.Sprintf("The ClusterDeployment %s matches the ClusterInstance spec", cd.Name))
+}
+
+// isOwnerUIDMatching returns false if the ClusterInstance owner reference or ownership labels of the object record a
+// UID that differs from the UID of the fetched ClusterInstance, i.e. the object belongs to a deleted ClusterInstance of
+// the same name or the ClusterInstance was served from a stale cache
+func isOwnerUIDMatching(obj client.Object, clusterInstance *v1alpha1.ClusterInstance) bool {
+	if _, uid, owned := ownerClusterInstance(obj); owned && uid != "" {
+		return uid == clusterInstance.UID
+	}
+	return true
+}
+
+// getOwnerClusterInstance returns the ClusterInstance owning the given object, nil if there is none
+func getOwnerClusterInstance(
+	ctx context.Context,
+	c client.Client,
+	log logr.Logger,
+	obj client.Object,
 ) (*v1alpha1.ClusterInstance, error) {
-	clusterInstanceRef := clusterInstanceOwner(cd.GetOwnerReferences())
-	if clusterInstanceRef == "" {
-		r.Log.Info("ClusterInstance owner-reference not found for ClusterDeployment", "name", cd.Name)
+	clusterInstanceRef, _, owned := ownerClusterInstance(obj)
+	if !owned {
+		log.Info("ClusterInstance owner-reference not found", "name", obj.GetName())
 		return nil, nil
 	}
 
 	clusterInstance := &v1alpha1.ClusterInstance{}
-	if err := r.Get(ctx, types.NamespacedName{Name: clusterInstanceRef, Namespace: cd.Namespace},
-		clusterInstance); err != nil {
+	if err := c.Get(ctx, clusterInstanceRef, clusterInstance); err != nil {
 		if errors.IsNotFound(err) {
-			r.Log.Info("ClusterInstance not found", "name", clusterInstanceRef)
+			log.Info("ClusterInstance not found", "name", clusterInstanceRef.String())
 			return nil, nil
 		}
-		r.Log.Info("Failed to get ClusterInstance", "name", clusterInstanceRef, "ClusterDeployment", cd.Name)
+		log.Info("Failed to get ClusterInstance", "name", clusterInstanceRef.String(), "owned", obj.GetName())
 		return nil, err
 	}
 	return clusterInstance, nil
@@ -260,21 +882,33 @@ func (r *ClusterDeploymentReconciler) mapClusterInstanceToCD(
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ClusterDeploymentReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	b := ctrl.NewControllerManagedBy(mgr).
 		Named("clusterDeploymentReconciler").
 		For(&hivev1.ClusterDeployment{},
 			// watch for create and update event for ClusterDeployment
-			builder.WithPredicates(predicate.Funcs{
+			builder.WithPredicates(r.WatchedNamespaces.predicate(), predicate.Funcs{
 				GenericFunc: func(e event.GenericEvent) bool { return false },
 				CreateFunc: func(e event.CreateEvent) bool {
-					return isOwnedByClusterInstance(e.Object.GetOwnerReferences())
+					return r.isMirroredClusterDeployment(e.Object)
+				},
+				// watch for the deletion of the ClusterDeployment to complete the deprovisioning
+				DeleteFunc: func(e event.DeleteEvent) bool {
+					return isOwnedByClusterInstance(e.Object)
 				},
-				DeleteFunc: func(e event.DeleteEvent) bool { return false },
 				UpdateFunc: func(e event.UpdateEvent) bool {
-					return isOwnedByClusterInstance(e.ObjectNew.GetOwnerReferences())
+					return r.isMirroredClusterDeployment(e.ObjectNew)
 				},
 			})).
 		WatchesRawSource(source.Kind(mgr.GetCache(), &v1alpha1.ClusterInstance{}),
-			handler.EnqueueRequestsFromMapFunc(r.mapClusterInstanceToCD)).
-		Complete(r)
+			handler.EnqueueRequestsFromMapFunc(r.mapClusterInstanceToCD))
+
+	// Reconcile all ClusterDeployments when the pause ConfigMap changes
+	if r.PauseSwitch != nil {
+		b = b.Watches(&corev1.ConfigMap{},
+			handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, _ client.Object) []reconcile.Request {
+				return mapPauseToClusterDeployments(ctx, r.Client)
+			}),
+			builder.WithPredicates(r.PauseSwitch.predicate()))
+	}
+	return b.Complete(instrument("clusterdeployment", r))
 }
@@ -18,6 +18,8 @@ package controller
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -25,16 +27,21 @@ import (
 	"github.com/stolostron/siteconfig/api/v1alpha1"
 	"github.com/stolostron/siteconfig/internal/controller/conditions"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 const ClusterInstanceApiVersion = v1alpha1.Group + "/" + v1alpha1.Version
 
+var installVersion = "4.15.12"
+
 // compareToExpectedCondition compares the observed condition to the expected condition
 func compareToExpectedCondition(observed, expected *metav1.Condition) {
 	Expect(observed).ToNot(BeNil())
@@ -103,7 +110,7 @@ var _ = Describe("Reconcile", func() {
 
 		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
 		Expect(err).NotTo(HaveOccurred())
-		Expect(res).To(Equal(ctrl.Result{}))
+		Expect(res).To(Equal(completed()))
 	})
 
 	It("doesn't reconcile a ClusterDeployment that is not owned by ClusterInstance", func() {
@@ -137,7 +144,7 @@ var _ = Describe("Reconcile", func() {
 
 		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
 		Expect(err).NotTo(HaveOccurred())
-		Expect(res).To(Equal(ctrl.Result{}))
+		Expect(res).To(Equal(completed()))
 
 		// Fetch ClusterInstance and verify that the status is unchanged
 		ci := &v1alpha1.ClusterInstance{}
@@ -145,6 +152,88 @@ var _ = Describe("Reconcile", func() {
 		Expect(ci.Status).To(Equal(clusterInstance.Status))
 	})
 
+	It("ignores an unowned ClusterDeployment enqueued through the ClusterInstance mapping", func() {
+		key := types.NamespacedName{
+			Namespace: clusterNamespace,
+			Name:      clusterName,
+		}
+		// The ClusterDeploymentRef is managed externally and references a ClusterDeployment the ClusterInstance
+		// does not own
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		clusterInstance.Status.ClusterDeploymentRef = &corev1.LocalObjectReference{Name: "external-cd"}
+		Expect(c.Status().Update(ctx, clusterInstance)).To(Succeed())
+
+		clusterDeployment := &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "external-cd", Namespace: clusterNamespace},
+			Spec:       hivev1.ClusterDeploymentSpec{Installed: true},
+			Status: hivev1.ClusterDeploymentStatus{
+				Conditions: []hivev1.ClusterDeploymentCondition{
+					{
+						Type:   hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+						Status: corev1.ConditionTrue,
+					},
+				},
+			},
+		}
+		Expect(c.Create(ctx, clusterDeployment)).To(Succeed())
+
+		requests := r.mapClusterInstanceToCD(ctx, clusterInstance)
+		Expect(requests).To(ConsistOf(reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: clusterNamespace, Name: "external-cd"},
+		}))
+
+		res, err := r.Reconcile(ctx, requests[0])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(Equal(completed()))
+
+		// The ClusterInstance status is not updated from the unowned ClusterDeployment
+		ci := &v1alpha1.ClusterInstance{}
+		Expect(c.Get(ctx, key, ci)).To(Succeed())
+		Expect(ci.Status).To(Equal(clusterInstance.Status))
+	})
+
+	It("mirrors the status of the ClusterDeployment applied by the user into the observe-only ClusterInstance", func() {
+		key := types.NamespacedName{
+			Namespace: clusterNamespace,
+			Name:      clusterName,
+		}
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		clusterInstance.Spec.ObserveOnlyClusterDeployment = true
+		Expect(c.Update(ctx, clusterInstance)).To(Succeed())
+		clusterInstance.Status.ClusterDeploymentRef = &corev1.LocalObjectReference{Name: "user-cd"}
+		Expect(c.Status().Update(ctx, clusterInstance)).To(Succeed())
+
+		clusterDeployment := &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "user-cd", Namespace: clusterNamespace},
+			Status: hivev1.ClusterDeploymentStatus{
+				Conditions: []hivev1.ClusterDeploymentCondition{
+					{
+						Type:   hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+						Status: corev1.ConditionFalse,
+						Reason: "InstallationInProgress",
+					},
+				},
+			},
+		}
+		Expect(c.Create(ctx, clusterDeployment)).To(Succeed())
+		Expect(r.isMirroredClusterDeployment(clusterDeployment)).To(BeTrue())
+
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(clusterDeployment)})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		Expect(clusterInstance.Status.ClusterDeploymentRef.Name).To(Equal("user-cd"))
+		Expect(meta.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.Provisioned))).ToNot(BeNil())
+		Expect(clusterInstance.Status.DeploymentConditions).To(ContainElement(HaveField("Type",
+			hivev1.ClusterInstallCompletedClusterDeploymentCondition)))
+
+		// A ClusterDeployment referenced by a managed ClusterInstance is not mirrored unless owned
+		clusterInstance.Spec.ObserveOnlyClusterDeployment = false
+		Expect(c.Update(ctx, clusterInstance)).To(Succeed())
+		Expect(r.isMirroredClusterDeployment(clusterDeployment)).To(BeFalse())
+	})
+
 	It("tests that ClusterDeploymentReconciler initializes ClusterInstance ClusterDeployment correctly", func() {
 		key := types.NamespacedName{
 			Namespace: clusterNamespace,
@@ -167,7 +256,7 @@ var _ = Describe("Reconcile", func() {
 
 		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
 		Expect(err).NotTo(HaveOccurred())
-		Expect(res).To(Equal(doNotRequeue()))
+		Expect(res).To(Equal(waitForEvent(provisioningSafetyNetInterval)))
 
 		expectedConditions := []hivev1.ClusterDeploymentCondition{
 			{
@@ -204,6 +293,39 @@ var _ = Describe("Reconcile", func() {
 		}
 	})
 
+	It("leaves the ClusterDeploymentRef untouched when its initialization is disabled", func() {
+		key := types.NamespacedName{
+			Namespace: clusterNamespace,
+			Name:      clusterName,
+		}
+		clusterInstance.Annotations = map[string]string{SkipClusterDeploymentRefInitAnnotation: "true"}
+		Expect(c.Update(ctx, clusterInstance)).To(Succeed())
+
+		clusterDeployment := &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterName,
+				Namespace: clusterNamespace,
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: ClusterInstanceApiVersion,
+						Kind:       v1alpha1.ClusterInstanceKind,
+						Name:       clusterName,
+					},
+				},
+			},
+		}
+		Expect(c.Create(ctx, clusterDeployment)).To(Succeed())
+
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+
+		ci := &v1alpha1.ClusterInstance{}
+		Expect(c.Get(ctx, key, ci)).To(Succeed())
+		Expect(ci.Status.ClusterDeploymentRef).To(BeNil())
+		// The remaining status is still updated
+		Expect(ci.Status.DeploymentConditions).ToNot(BeEmpty())
+	})
+
 	It("tests that ClusterDeploymentReconciler updates ClusterInstance deploymentConditions", func() {
 		key := types.NamespacedName{
 			Namespace: clusterNamespace,
@@ -298,7 +420,7 @@ var _ = Describe("Reconcile", func() {
 
 			res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
 			Expect(err).NotTo(HaveOccurred())
-			Expect(res).To(Equal(doNotRequeue()))
+			Expect(res).To(Equal(waitForEvent(provisioningSafetyNetInterval)))
 
 			ci := &v1alpha1.ClusterInstance{}
 			Expect(c.Get(ctx, key, ci)).To(Succeed())
@@ -313,6 +435,50 @@ var _ = Describe("Reconcile", func() {
 		}
 	})
 
+	It("does not update the DeploymentConditions of a terminating ClusterInstance", func() {
+		key := types.NamespacedName{
+			Namespace: clusterNamespace,
+			Name:      clusterName,
+		}
+		clusterDeployment := &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterName,
+				Namespace: clusterNamespace,
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: ClusterInstanceApiVersion,
+						Kind:       v1alpha1.ClusterInstanceKind,
+						Name:       clusterName,
+					},
+				},
+			},
+			Status: hivev1.ClusterDeploymentStatus{
+				Conditions: []hivev1.ClusterDeploymentCondition{{
+					Type:    hivev1.ClusterInstallFailedClusterDeploymentCondition,
+					Status:  corev1.ConditionTrue,
+					Reason:  "InstallationFailed",
+					Message: "The installation has failed",
+				}},
+			},
+		}
+		Expect(c.Create(ctx, clusterDeployment)).To(Succeed())
+
+		// The finalizer keeps the ClusterInstance terminating
+		Expect(c.Delete(ctx, clusterInstance)).To(Succeed())
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		Expect(clusterInstance.DeletionTimestamp.IsZero()).To(BeFalse())
+
+		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(Equal(completed()))
+
+		ci := &v1alpha1.ClusterInstance{}
+		Expect(c.Get(ctx, key, ci)).To(Succeed())
+		Expect(ci.Status.DeploymentConditions).To(BeEmpty())
+		Expect(ci.Status.Conditions).To(BeEmpty())
+		Expect(ci.Status.ClusterDeploymentRef).To(BeNil())
+	})
+
 	It("tests that ClusterInstance provisioned status condition is set to True with reason set to Completed when provisioning succeeded", func() {
 		key := types.NamespacedName{
 			Namespace: clusterNamespace,
@@ -335,6 +501,7 @@ var _ = Describe("Reconcile", func() {
 				Installed: true,
 			},
 			Status: hivev1.ClusterDeploymentStatus{
+				InstallVersion: &installVersion,
 				Conditions: []hivev1.ClusterDeploymentCondition{
 					{
 						Type:    hivev1.ClusterInstallRequirementsMetClusterDeploymentCondition,
@@ -368,7 +535,7 @@ var _ = Describe("Reconcile", func() {
 
 		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
 		Expect(err).NotTo(HaveOccurred())
-		Expect(res).To(Equal(ctrl.Result{}))
+		Expect(res).To(Equal(completed()))
 
 		ci := &v1alpha1.ClusterInstance{}
 		Expect(c.Get(ctx, key, ci)).To(Succeed())
@@ -438,7 +605,7 @@ var _ = Describe("Reconcile", func() {
 
 		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
 		Expect(err).NotTo(HaveOccurred())
-		Expect(res).To(Equal(ctrl.Result{}))
+		Expect(res).To(Equal(completed()))
 
 		ci := &v1alpha1.ClusterInstance{}
 		Expect(c.Get(ctx, key, ci)).To(Succeed())
@@ -516,7 +683,7 @@ var _ = Describe("Reconcile", func() {
 
 		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
 		Expect(err).NotTo(HaveOccurred())
-		Expect(res).To(Equal(ctrl.Result{}))
+		Expect(res).To(Equal(waitForEvent(provisioningSafetyNetInterval)))
 
 		ci := &v1alpha1.ClusterInstance{}
 		Expect(c.Get(ctx, key, ci)).To(Succeed())
@@ -531,4 +698,1281 @@ var _ = Describe("Reconcile", func() {
 		compareToExpectedCondition(found, expectedCondition)
 	})
 
+	It("reports an unreachable release image instead of a provisioning failure and requeues", func() {
+		key := types.NamespacedName{
+			Namespace: clusterNamespace,
+			Name:      clusterName,
+		}
+
+		clusterDeployment := &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterName,
+				Namespace: clusterNamespace,
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: ClusterInstanceApiVersion,
+						Kind:       v1alpha1.ClusterInstanceKind,
+						Name:       clusterName,
+					},
+				},
+			},
+			Status: hivev1.ClusterDeploymentStatus{
+				Conditions: []hivev1.ClusterDeploymentCondition{
+					{
+						Type:   hivev1.ClusterInstallStoppedClusterDeploymentCondition,
+						Status: corev1.ConditionTrue,
+					},
+					{
+						Type:   hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+						Status: corev1.ConditionFalse,
+					},
+					{
+						Type:   hivev1.ClusterInstallFailedClusterDeploymentCondition,
+						Status: corev1.ConditionTrue,
+						Reason: "InstallationFailed",
+					},
+					{
+						Type:    hivev1.InstallImagesNotResolvedCondition,
+						Status:  corev1.ConditionTrue,
+						Reason:  "JobToResolveImagesFailed",
+						Message: "Failed to resolve the release image",
+					},
+				},
+			},
+		}
+		Expect(c.Create(ctx, clusterDeployment)).To(Succeed())
+
+		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(Equal(requeueAfter(releaseImageUnreachableRequeueInterval)))
+
+		ci := &v1alpha1.ClusterInstance{}
+		Expect(c.Get(ctx, key, ci)).To(Succeed())
+
+		found := conditions.FindStatusCondition(ci.Status.Conditions, string(conditions.Provisioned))
+		compareToExpectedCondition(found, &metav1.Condition{
+			Type:   string(conditions.Provisioned),
+			Status: metav1.ConditionFalse,
+			Reason: string(conditions.ReleaseImageUnreachable),
+		})
+	})
+
+	It("tracks the deprovisioning of the cluster through to completion", func() {
+		key := types.NamespacedName{
+			Namespace: clusterNamespace,
+			Name:      clusterName,
+		}
+
+		clusterDeployment := &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       clusterName,
+				Namespace:  clusterNamespace,
+				Finalizers: []string{"hive.openshift.io/deprovision"},
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: ClusterInstanceApiVersion,
+						Kind:       v1alpha1.ClusterInstanceKind,
+						Name:       clusterName,
+					},
+				},
+			},
+			Spec: hivev1.ClusterDeploymentSpec{Installed: true},
+			Status: hivev1.ClusterDeploymentStatus{
+				Conditions: []hivev1.ClusterDeploymentCondition{
+					{
+						Type:   hivev1.ProvisionedCondition,
+						Status: corev1.ConditionFalse,
+						Reason: hivev1.ProvisionedReasonDeprovisioning,
+					},
+				},
+			},
+		}
+		Expect(c.Create(ctx, clusterDeployment)).To(Succeed())
+
+		// No deprovisioning is reported until the ClusterDeployment is deleted
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		ci := &v1alpha1.ClusterInstance{}
+		Expect(c.Get(ctx, key, ci)).To(Succeed())
+		Expect(conditions.FindStatusCondition(ci.Status.Conditions,
+			string(conditions.ClusterDeprovisioned))).To(BeNil())
+
+		expectDeprovisioned := func(status metav1.ConditionStatus, reason conditions.ConditionReason) {
+			_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Get(ctx, key, ci)).To(Succeed())
+			found := conditions.FindStatusCondition(ci.Status.Conditions, string(conditions.ClusterDeprovisioned))
+			compareToExpectedCondition(found, &metav1.Condition{
+				Type:   string(conditions.ClusterDeprovisioned),
+				Status: status,
+				Reason: string(reason),
+			})
+		}
+
+		// Deprovisioning starts
+		Expect(c.Delete(ctx, clusterDeployment)).To(Succeed())
+		expectDeprovisioned(metav1.ConditionFalse, conditions.InProgress)
+
+		// Deprovisioning fails
+		Expect(c.Get(ctx, key, clusterDeployment)).To(Succeed())
+		clusterDeployment.Status.Conditions[0].Reason = hivev1.ProvisionedReasonDeprovisionFailed
+		Expect(c.Update(ctx, clusterDeployment)).To(Succeed())
+		expectDeprovisioned(metav1.ConditionFalse, conditions.Failed)
+
+		// Hive retries the deprovisioning
+		Expect(c.Get(ctx, key, clusterDeployment)).To(Succeed())
+		clusterDeployment.Status.Conditions[0].Reason = hivev1.ProvisionedReasonDeprovisioning
+		Expect(c.Update(ctx, clusterDeployment)).To(Succeed())
+		expectDeprovisioned(metav1.ConditionFalse, conditions.InProgress)
+
+		// Deprovisioning completes and the ClusterDeployment is deleted
+		Expect(c.Get(ctx, key, clusterDeployment)).To(Succeed())
+		clusterDeployment.Finalizers = nil
+		Expect(c.Update(ctx, clusterDeployment)).To(Succeed())
+		expectDeprovisioned(metav1.ConditionTrue, conditions.Completed)
+	})
+
+	It("rebuilds corrupted DeploymentConditions from the ClusterDeployment when requested", func() {
+		key := types.NamespacedName{
+			Namespace: clusterNamespace,
+			Name:      clusterName,
+		}
+		failedAt := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+
+		clusterDeployment := &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterName,
+				Namespace: clusterNamespace,
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: ClusterInstanceApiVersion,
+						Kind:       v1alpha1.ClusterInstanceKind,
+						Name:       clusterName,
+					},
+				},
+			},
+			Status: hivev1.ClusterDeploymentStatus{
+				Conditions: []hivev1.ClusterDeploymentCondition{
+					{
+						Type:   hivev1.ClusterInstallRequirementsMetClusterDeploymentCondition,
+						Status: corev1.ConditionTrue,
+						Reason: "ClusterAlreadyInstalling",
+					},
+					{
+						Type:   hivev1.ClusterInstallStoppedClusterDeploymentCondition,
+						Status: corev1.ConditionFalse,
+						Reason: "InProgress",
+					},
+					{
+						Type:   hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+						Status: corev1.ConditionFalse,
+						Reason: "InProgress",
+					},
+					{
+						Type:               hivev1.ClusterInstallFailedClusterDeploymentCondition,
+						Status:             corev1.ConditionFalse,
+						Reason:             "InstallationNotFailed",
+						LastTransitionTime: failedAt,
+					},
+				},
+			},
+		}
+		Expect(c.Create(ctx, clusterDeployment)).To(Succeed())
+
+		// Corrupt the DeploymentConditions: duplicated, stale and unknown entries
+		ci := &v1alpha1.ClusterInstance{}
+		Expect(c.Get(ctx, key, ci)).To(Succeed())
+		ci.Status.DeploymentConditions = []hivev1.ClusterDeploymentCondition{
+			{Type: hivev1.ClusterInstallFailedClusterDeploymentCondition, Status: corev1.ConditionTrue,
+				Reason: "InstallationFailed"},
+			{Type: hivev1.ClusterInstallFailedClusterDeploymentCondition, Status: corev1.ConditionTrue,
+				Reason: "InstallationFailed"},
+			{Type: "Bogus", Status: corev1.ConditionTrue},
+		}
+		Expect(c.Status().Update(ctx, ci)).To(Succeed())
+		ci.SetAnnotations(map[string]string{RebuildStatusAnnotation: ""})
+		Expect(c.Update(ctx, ci)).To(Succeed())
+
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(c.Get(ctx, key, ci)).To(Succeed())
+		Expect(ci.GetAnnotations()).ToNot(HaveKey(RebuildStatusAnnotation))
+		Expect(ci.Status.DeploymentConditions).To(HaveLen(len(clusterInstallConditionTypes())))
+		for _, cdCond := range clusterDeployment.Status.Conditions {
+			ciCond := conditions.FindCDConditionType(ci.Status.DeploymentConditions, cdCond.Type)
+			Expect(ciCond).ToNot(BeNil())
+			Expect(ciCond.Status).To(Equal(cdCond.Status))
+			Expect(ciCond.Reason).To(Equal(cdCond.Reason))
+		}
+		installFailed := conditions.FindCDConditionType(ci.Status.DeploymentConditions,
+			hivev1.ClusterInstallFailedClusterDeploymentCondition)
+		Expect(installFailed.LastTransitionTime.Equal(&failedAt)).To(BeTrue())
+
+		// Provisioning is not affected by the rebuild
+		provisioned := conditions.FindStatusCondition(ci.Status.Conditions, string(conditions.Provisioned))
+		Expect(provisioned).ToNot(BeNil())
+		Expect(provisioned.Reason).To(Equal(string(conditions.InProgress)))
+	})
+
+	It("requests the regeneration of the install secrets when the installation failed due to an expired token", func() {
+		key := types.NamespacedName{
+			Namespace: clusterNamespace,
+			Name:      clusterName,
+		}
+		failedAt := metav1.NewTime(time.Now().Add(-time.Minute).Truncate(time.Second))
+
+		clusterDeployment := &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterName,
+				Namespace: clusterNamespace,
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: ClusterInstanceApiVersion,
+						Kind:       v1alpha1.ClusterInstanceKind,
+						Name:       clusterName,
+					},
+				},
+			},
+			Status: hivev1.ClusterDeploymentStatus{
+				Conditions: []hivev1.ClusterDeploymentCondition{
+					{
+						Type:   hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+						Status: corev1.ConditionFalse,
+					},
+					{
+						Type:   hivev1.ClusterInstallStoppedClusterDeploymentCondition,
+						Status: corev1.ConditionTrue,
+					},
+					{
+						Type:               hivev1.ClusterInstallFailedClusterDeploymentCondition,
+						Status:             corev1.ConditionTrue,
+						Reason:             "IgnitionTokenExpired",
+						Message:            "The installation failed: ignition token has expired",
+						LastTransitionTime: failedAt,
+					},
+				},
+			},
+		}
+		Expect(c.Create(ctx, clusterDeployment)).To(Succeed())
+
+		// The regeneration does not depend on the provisioning failure grace period
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+
+		ci := &v1alpha1.ClusterInstance{}
+		Expect(c.Get(ctx, key, ci)).To(Succeed())
+		Expect(ci.GetAnnotations()).To(HaveKeyWithValue(RegenerateInstallSecretsAnnotation,
+			failedAt.Format(time.RFC3339)))
+
+		// The install secrets are not regenerated again for the same failure
+		delete(ci.Annotations, RegenerateInstallSecretsAnnotation)
+		Expect(c.Update(ctx, ci)).To(Succeed())
+		regeneratedAt := metav1.Now()
+		ci.Status.LastInstallSecretsRegeneration = &regeneratedAt
+		Expect(c.Status().Update(ctx, ci)).To(Succeed())
+
+		_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Get(ctx, key, ci)).To(Succeed())
+		Expect(ci.GetAnnotations()).ToNot(HaveKey(RegenerateInstallSecretsAnnotation))
+	})
+
+	DescribeTable("applies the deprovision-on-failure policy once the provisioning failed",
+		func(deprovisionOnFailure bool) {
+			key := types.NamespacedName{
+				Namespace: clusterNamespace,
+				Name:      clusterName,
+			}
+			clusterInstance.Spec.DeprovisionOnFailure = deprovisionOnFailure
+			Expect(c.Update(ctx, clusterInstance)).To(Succeed())
+
+			clusterDeployment := &hivev1.ClusterDeployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      clusterName,
+					Namespace: clusterNamespace,
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: ClusterInstanceApiVersion,
+							Kind:       v1alpha1.ClusterInstanceKind,
+							Name:       clusterName,
+						},
+					},
+				},
+				Status: hivev1.ClusterDeploymentStatus{
+					Conditions: []hivev1.ClusterDeploymentCondition{
+						{
+							Type:   hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+							Status: corev1.ConditionFalse,
+						},
+						{
+							Type:   hivev1.ClusterInstallStoppedClusterDeploymentCondition,
+							Status: corev1.ConditionTrue,
+						},
+						{
+							Type:    hivev1.ClusterInstallFailedClusterDeploymentCondition,
+							Status:  corev1.ConditionTrue,
+							Reason:  "InstallationFailed",
+							Message: "The installation failed",
+						},
+					},
+				},
+			}
+			Expect(c.Create(ctx, clusterDeployment)).To(Succeed())
+
+			_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			ci := &v1alpha1.ClusterInstance{}
+			Expect(c.Get(ctx, key, ci)).To(Succeed())
+			provisioned := conditions.FindStatusCondition(ci.Status.Conditions, string(conditions.Provisioned))
+			Expect(provisioned).ToNot(BeNil())
+			Expect(provisioned.Reason).To(Equal(string(conditions.Failed)))
+
+			err = c.Get(ctx, key, &hivev1.ClusterDeployment{})
+			if deprovisionOnFailure {
+				Expect(apierrors.IsNotFound(err)).To(BeTrue())
+			} else {
+				Expect(err).ToNot(HaveOccurred())
+			}
+		},
+		Entry("the failed cluster is preserved by default", false),
+		Entry("the failed cluster is deprovisioned when requested", true),
+	)
+
+	It("tests that the ClusterInstance API URL is derived from the cluster name and base domain once provisioning starts", func() {
+		key := types.NamespacedName{
+			Namespace: clusterNamespace,
+			Name:      clusterName,
+		}
+		clusterDeployment := &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterName,
+				Namespace: clusterNamespace,
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: ClusterInstanceApiVersion,
+						Kind:       v1alpha1.ClusterInstanceKind,
+						Name:       clusterName,
+					},
+				},
+			},
+		}
+		Expect(c.Create(ctx, clusterDeployment)).To(Succeed())
+
+		// Provisioning has not started, the URLs are not set
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		ci := &v1alpha1.ClusterInstance{}
+		Expect(c.Get(ctx, key, ci)).To(Succeed())
+		Expect(ci.Status.APIURL).To(BeEmpty())
+		Expect(ci.Status.ConsoleURL).To(BeEmpty())
+
+		clusterDeployment.Status.Conditions = []hivev1.ClusterDeploymentCondition{
+			{
+				Type:   hivev1.ClusterInstallRequirementsMetClusterDeploymentCondition,
+				Status: corev1.ConditionTrue,
+			},
+			{
+				Type:   hivev1.ClusterInstallStoppedClusterDeploymentCondition,
+				Status: corev1.ConditionFalse,
+			},
+			{
+				Type:   hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+				Status: corev1.ConditionFalse,
+			},
+			{
+				Type:   hivev1.ClusterInstallFailedClusterDeploymentCondition,
+				Status: corev1.ConditionFalse,
+			},
+		}
+		Expect(c.Update(ctx, clusterDeployment)).To(Succeed())
+
+		_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Get(ctx, key, ci)).To(Succeed())
+		Expect(ci.Status.APIURL).To(Equal("https://api.test-cluster.abcd:6443"))
+		Expect(ci.Status.ConsoleURL).To(BeEmpty())
+	})
+
+	It("tests that the ClusterInstance URLs reflect the ClusterDeployment URLs of an installed cluster", func() {
+		key := types.NamespacedName{
+			Namespace: clusterNamespace,
+			Name:      clusterName,
+		}
+		clusterDeployment := &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterName,
+				Namespace: clusterNamespace,
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: ClusterInstanceApiVersion,
+						Kind:       v1alpha1.ClusterInstanceKind,
+						Name:       clusterName,
+					},
+				},
+			},
+			Spec: hivev1.ClusterDeploymentSpec{
+				Installed: true,
+			},
+			Status: hivev1.ClusterDeploymentStatus{
+				APIURL:        "https://api.test-cluster.example.com:6443",
+				WebConsoleURL: "https://console-openshift-console.apps.test-cluster.example.com",
+				Conditions: []hivev1.ClusterDeploymentCondition{
+					{
+						Type:   hivev1.ClusterInstallStoppedClusterDeploymentCondition,
+						Status: corev1.ConditionTrue,
+					},
+					{
+						Type:   hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+						Status: corev1.ConditionTrue,
+					},
+					{
+						Type:   hivev1.ClusterInstallFailedClusterDeploymentCondition,
+						Status: corev1.ConditionFalse,
+					},
+				},
+			},
+		}
+		Expect(c.Create(ctx, clusterDeployment)).To(Succeed())
+
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+
+		ci := &v1alpha1.ClusterInstance{}
+		Expect(c.Get(ctx, key, ci)).To(Succeed())
+		Expect(ci.Status.APIURL).To(Equal(clusterDeployment.Status.APIURL))
+		Expect(ci.Status.ConsoleURL).To(Equal(clusterDeployment.Status.WebConsoleURL))
+	})
+
+	It("sets the installed version of a provisioned cluster", func() {
+		key := types.NamespacedName{
+			Namespace: clusterNamespace,
+			Name:      clusterName,
+		}
+		clusterDeployment := &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterName,
+				Namespace: clusterNamespace,
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: ClusterInstanceApiVersion,
+						Kind:       v1alpha1.ClusterInstanceKind,
+						Name:       clusterName,
+					},
+				},
+			},
+			Spec: hivev1.ClusterDeploymentSpec{
+				Installed: true,
+			},
+			Status: hivev1.ClusterDeploymentStatus{
+				InstallVersion: &installVersion,
+				Conditions: []hivev1.ClusterDeploymentCondition{
+					{
+						Type:   hivev1.ClusterInstallStoppedClusterDeploymentCondition,
+						Status: corev1.ConditionTrue,
+					},
+					{
+						Type:   hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+						Status: corev1.ConditionTrue,
+					},
+					{
+						Type:   hivev1.ClusterInstallFailedClusterDeploymentCondition,
+						Status: corev1.ConditionFalse,
+					},
+				},
+			},
+		}
+		Expect(c.Create(ctx, clusterDeployment)).To(Succeed())
+
+		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(Equal(completed()))
+
+		ci := &v1alpha1.ClusterInstance{}
+		Expect(c.Get(ctx, key, ci)).To(Succeed())
+		Expect(ci.Status.InstalledVersion).To(Equal("4.15.12"))
+	})
+
+	It("does not write the status when only the probe times would change within the probe interval", func() {
+		r.ConditionProbeInterval = 10 * time.Minute
+		key := types.NamespacedName{
+			Namespace: clusterNamespace,
+			Name:      clusterName,
+		}
+		clusterDeployment := &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterName,
+				Namespace: clusterNamespace,
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: ClusterInstanceApiVersion,
+						Kind:       v1alpha1.ClusterInstanceKind,
+						Name:       clusterName,
+					},
+				},
+			},
+			Status: hivev1.ClusterDeploymentStatus{
+				Conditions: []hivev1.ClusterDeploymentCondition{
+					{
+						Type:   hivev1.ClusterInstallStoppedClusterDeploymentCondition,
+						Status: corev1.ConditionFalse,
+					},
+					{
+						Type:   hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+						Status: corev1.ConditionFalse,
+					},
+				},
+			},
+		}
+		Expect(c.Create(ctx, clusterDeployment)).To(Succeed())
+
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		ci := &v1alpha1.ClusterInstance{}
+		Expect(c.Get(ctx, key, ci)).To(Succeed())
+		resourceVersion := ci.ResourceVersion
+
+		// Nothing changed and the probe times are recent
+		_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Get(ctx, key, ci)).To(Succeed())
+		Expect(ci.ResourceVersion).To(Equal(resourceVersion))
+
+		// The probe times are refreshed once older than the probe interval
+		patch := client.MergeFrom(ci.DeepCopy())
+		probedAt := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+		for i := range ci.Status.DeploymentConditions {
+			ci.Status.DeploymentConditions[i].LastProbeTime = probedAt
+		}
+		Expect(c.Status().Patch(ctx, ci, patch)).To(Succeed())
+		resourceVersion = ci.ResourceVersion
+
+		_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Get(ctx, key, ci)).To(Succeed())
+		Expect(ci.ResourceVersion).ToNot(Equal(resourceVersion))
+		for _, cond := range ci.Status.DeploymentConditions {
+			Expect(cond.LastProbeTime.After(probedAt.Time)).To(BeTrue())
+		}
+	})
+
+	It("skips a ClusterDeployment owned by a ClusterInstance with a different UID", func() {
+		key := types.NamespacedName{
+			Namespace: clusterNamespace,
+			Name:      clusterName,
+		}
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		clusterInstance.UID = "recreated-uid"
+		Expect(c.Update(ctx, clusterInstance)).To(Succeed())
+
+		// The ClusterDeployment belongs to a previous incarnation of the ClusterInstance
+		clusterDeployment := &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterName,
+				Namespace: clusterNamespace,
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: ClusterInstanceApiVersion,
+						Kind:       v1alpha1.ClusterInstanceKind,
+						Name:       clusterName,
+						UID:        "deleted-uid",
+					},
+				},
+			},
+		}
+		Expect(c.Create(ctx, clusterDeployment)).To(Succeed())
+
+		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(Equal(completed()))
+
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		Expect(clusterInstance.Status.ClusterDeploymentRef).To(BeNil())
+		Expect(clusterInstance.Status.Conditions).To(BeEmpty())
+
+		// The ClusterDeployment is processed once owned by the ClusterInstance
+		clusterDeployment.OwnerReferences[0].UID = clusterInstance.UID
+		Expect(c.Update(ctx, clusterDeployment)).To(Succeed())
+
+		_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		Expect(clusterInstance.Status.ClusterDeploymentRef).To(Equal(
+			&corev1.LocalObjectReference{Name: clusterDeployment.Name}))
+	})
+
+	It("references the ClusterProvision holding the install logs when the installation fails", func() {
+		key := types.NamespacedName{
+			Namespace: clusterNamespace,
+			Name:      clusterName,
+		}
+		clusterDeployment := &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterName,
+				Namespace: clusterNamespace,
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: ClusterInstanceApiVersion,
+						Kind:       v1alpha1.ClusterInstanceKind,
+						Name:       clusterName,
+					},
+				},
+			},
+			Status: hivev1.ClusterDeploymentStatus{
+				ProvisionRef: &corev1.LocalObjectReference{Name: "test-cluster-0-abcde"},
+				Conditions: []hivev1.ClusterDeploymentCondition{
+					{
+						Type:   hivev1.ClusterInstallStoppedClusterDeploymentCondition,
+						Status: corev1.ConditionTrue,
+					},
+					{
+						Type:   hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+						Status: corev1.ConditionFalse,
+					},
+					{
+						Type:   hivev1.ClusterInstallFailedClusterDeploymentCondition,
+						Status: corev1.ConditionTrue,
+					},
+				},
+			},
+		}
+		Expect(c.Create(ctx, clusterDeployment)).To(Succeed())
+
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+
+		ci := &v1alpha1.ClusterInstance{}
+		Expect(c.Get(ctx, key, ci)).To(Succeed())
+		Expect(conditions.FindStatusCondition(ci.Status.Conditions, string(conditions.Provisioned)).Reason).To(
+			Equal(string(conditions.Failed)))
+		Expect(ci.Status.InstallLogsRef).ToNot(BeNil())
+		Expect(*ci.Status.InstallLogsRef.APIGroup).To(Equal(hivev1.SchemeGroupVersion.Group))
+		Expect(ci.Status.InstallLogsRef.Kind).To(Equal("ClusterProvision"))
+		Expect(ci.Status.InstallLogsRef.Name).To(Equal("test-cluster-0-abcde"))
+	})
+
+	It("references the cluster install holding the install logs of an agent install", func() {
+		key := types.NamespacedName{
+			Namespace: clusterNamespace,
+			Name:      clusterName,
+		}
+		clusterDeployment := &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterName,
+				Namespace: clusterNamespace,
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: ClusterInstanceApiVersion,
+						Kind:       v1alpha1.ClusterInstanceKind,
+						Name:       clusterName,
+					},
+				},
+			},
+			Spec: hivev1.ClusterDeploymentSpec{
+				ClusterInstallRef: &hivev1.ClusterInstallLocalReference{
+					Group:   "extensions.hive.openshift.io",
+					Version: "v1beta1",
+					Kind:    "AgentClusterInstall",
+					Name:    clusterName,
+				},
+			},
+			Status: hivev1.ClusterDeploymentStatus{
+				Conditions: []hivev1.ClusterDeploymentCondition{
+					{
+						Type:   hivev1.ClusterInstallStoppedClusterDeploymentCondition,
+						Status: corev1.ConditionTrue,
+					},
+					{
+						Type:   hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+						Status: corev1.ConditionFalse,
+					},
+					{
+						Type:   hivev1.ClusterInstallFailedClusterDeploymentCondition,
+						Status: corev1.ConditionTrue,
+					},
+				},
+			},
+		}
+		Expect(c.Create(ctx, clusterDeployment)).To(Succeed())
+
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+
+		ci := &v1alpha1.ClusterInstance{}
+		Expect(c.Get(ctx, key, ci)).To(Succeed())
+		Expect(ci.Status.InstallLogsRef).ToNot(BeNil())
+		Expect(*ci.Status.InstallLogsRef.APIGroup).To(Equal("extensions.hive.openshift.io"))
+		Expect(ci.Status.InstallLogsRef.Kind).To(Equal("AgentClusterInstall"))
+		Expect(ci.Status.InstallLogsRef.Name).To(Equal(clusterName))
+	})
+})
+
+var _ = Describe("updateCIProvisionedStatus", func() {
+	var (
+		clusterDeployment *hivev1.ClusterDeployment
+		clusterInstance   *v1alpha1.ClusterInstance
+		startedAt         = metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	)
+
+	BeforeEach(func() {
+		clusterDeployment = &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-cluster"},
+			Status: hivev1.ClusterDeploymentStatus{
+				Conditions: []hivev1.ClusterDeploymentCondition{
+					{
+						Type:   hivev1.ClusterInstallStoppedClusterDeploymentCondition,
+						Status: corev1.ConditionTrue,
+					},
+					{
+						Type:   hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+						Status: corev1.ConditionFalse,
+					},
+					{
+						Type:   hivev1.ClusterInstallFailedClusterDeploymentCondition,
+						Status: corev1.ConditionTrue,
+					},
+				},
+			},
+		}
+		clusterInstance = &v1alpha1.ClusterInstance{
+			Status: v1alpha1.ClusterInstanceStatus{
+				Conditions: []metav1.Condition{{
+					Type:               string(conditions.Provisioned),
+					Reason:             string(conditions.InProgress),
+					Status:             metav1.ConditionFalse,
+					LastTransitionTime: startedAt,
+				}},
+			},
+		}
+	})
+
+	It("resets the transition time when provisioning fails", func() {
+		updateCIProvisionedStatus(clusterDeployment, clusterInstance, ctrl.Log, 0)
+
+		provisioned := conditions.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.Provisioned))
+		Expect(provisioned).ToNot(BeNil())
+		Expect(provisioned.Reason).To(Equal(string(conditions.Failed)))
+		Expect(provisioned.LastTransitionTime.After(startedAt.Time)).To(BeTrue())
+	})
+
+	It("does not mark provisioning as failed while the failure grace period has not elapsed", func() {
+		installFailed := conditions.FindCDConditionType(clusterDeployment.Status.Conditions,
+			hivev1.ClusterInstallFailedClusterDeploymentCondition)
+		installFailed.LastTransitionTime = metav1.NewTime(time.Now().Add(-time.Minute))
+
+		remaining := updateCIProvisionedStatus(clusterDeployment, clusterInstance, ctrl.Log, 10*time.Minute)
+		Expect(remaining).To(BeNumerically(">", 8*time.Minute))
+		Expect(remaining).To(BeNumerically("<=", 9*time.Minute))
+
+		provisioned := conditions.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.Provisioned))
+		Expect(provisioned).ToNot(BeNil())
+		Expect(provisioned.Reason).To(Equal(string(conditions.InProgress)))
+		Expect(provisioned.Message).To(ContainSubstring("Provisioning failure reported"))
+
+		// Hive retries the installation and the failure clears
+		installFailed.Status = corev1.ConditionFalse
+		installStopped := conditions.FindCDConditionType(clusterDeployment.Status.Conditions,
+			hivev1.ClusterInstallStoppedClusterDeploymentCondition)
+		installStopped.Status = corev1.ConditionFalse
+
+		remaining = updateCIProvisionedStatus(clusterDeployment, clusterInstance, ctrl.Log, 10*time.Minute)
+		Expect(remaining).To(BeZero())
+		provisioned = conditions.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.Provisioned))
+		Expect(provisioned.Reason).To(Equal(string(conditions.InProgress)))
+		Expect(provisioned.Message).To(Equal("Provisioning cluster"))
+	})
+
+	It("marks provisioning as failed once the failure persisted for the grace period", func() {
+		installFailed := conditions.FindCDConditionType(clusterDeployment.Status.Conditions,
+			hivev1.ClusterInstallFailedClusterDeploymentCondition)
+		installFailed.LastTransitionTime = metav1.NewTime(time.Now().Add(-11 * time.Minute))
+
+		remaining := updateCIProvisionedStatus(clusterDeployment, clusterInstance, ctrl.Log, 10*time.Minute)
+		Expect(remaining).To(BeZero())
+
+		provisioned := conditions.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.Provisioned))
+		Expect(provisioned).ToNot(BeNil())
+		Expect(provisioned.Reason).To(Equal(string(conditions.Failed)))
+	})
+
+	It("sets the Deprovisioned reason when the ClusterDeployment is being deleted", func() {
+		now := metav1.Now()
+		clusterDeployment.DeletionTimestamp = &now
+		updateCIProvisionedStatus(clusterDeployment, clusterInstance, ctrl.Log, 0)
+
+		provisioned := conditions.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.Provisioned))
+		Expect(provisioned).ToNot(BeNil())
+		Expect(provisioned.Status).To(Equal(metav1.ConditionFalse))
+		Expect(provisioned.Reason).To(Equal(string(conditions.Deprovisioned)))
+	})
+})
+
+var _ = Describe("updateCIReleaseImageStatus", func() {
+	DescribeTable("maps the release image pull failures to the ReleaseImageUnreachable reason",
+		func(condType hivev1.ClusterDeploymentConditionType, status corev1.ConditionStatus, reason string,
+			expected bool) {
+			clusterDeployment := &hivev1.ClusterDeployment{
+				Status: hivev1.ClusterDeploymentStatus{
+					Conditions: []hivev1.ClusterDeploymentCondition{{
+						Type:    condType,
+						Status:  status,
+						Reason:  reason,
+						Message: "failed to pull quay.io/openshift-release-dev/ocp-release:4.15.0",
+					}},
+				},
+			}
+			clusterInstance := &v1alpha1.ClusterInstance{
+				Spec: v1alpha1.ClusterInstanceSpec{ClusterImageSetNameRef: "img4.15.0"},
+			}
+
+			Expect(updateCIReleaseImageStatus(clusterDeployment, clusterInstance)).To(Equal(expected))
+
+			provisioned := conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+				string(conditions.Provisioned))
+			if !expected {
+				Expect(provisioned).To(BeNil())
+				return
+			}
+			Expect(provisioned).ToNot(BeNil())
+			Expect(provisioned.Status).To(Equal(metav1.ConditionFalse))
+			Expect(provisioned.Reason).To(Equal(string(conditions.ReleaseImageUnreachable)))
+			Expect(provisioned.Message).To(ContainSubstring("img4.15.0"))
+			Expect(provisioned.Message).To(ContainSubstring(reason))
+		},
+		Entry("install images not resolved", hivev1.InstallImagesNotResolvedCondition, corev1.ConditionTrue,
+			"JobToResolveImagesFailed", true),
+		Entry("install images resolved", hivev1.InstallImagesNotResolvedCondition, corev1.ConditionFalse,
+			"ImagesResolved", false),
+		Entry("provision failed to pull the image", hivev1.ProvisionFailedCondition, corev1.ConditionTrue,
+			"ImagePullBackOff", true),
+		Entry("cluster install failed to pull the image", hivev1.ClusterInstallFailedClusterDeploymentCondition,
+			corev1.ConditionTrue, "ErrImagePull", true),
+		Entry("unrelated installation failure", hivev1.ClusterInstallFailedClusterDeploymentCondition,
+			corev1.ConditionTrue, "InstallationFailed", false),
+	)
+
+	It("does not report an unreachable release image once the cluster is installed", func() {
+		clusterDeployment := &hivev1.ClusterDeployment{
+			Spec: hivev1.ClusterDeploymentSpec{Installed: true},
+			Status: hivev1.ClusterDeploymentStatus{
+				Conditions: []hivev1.ClusterDeploymentCondition{{
+					Type:   hivev1.InstallImagesNotResolvedCondition,
+					Status: corev1.ConditionTrue,
+				}},
+			},
+		}
+		Expect(updateCIReleaseImageStatus(clusterDeployment, &v1alpha1.ClusterInstance{})).To(BeFalse())
+	})
+})
+
+var _ = Describe("updateCIInstalledVersion", func() {
+	provisionedCI := func(reason conditions.ConditionReason) *v1alpha1.ClusterInstance {
+		clusterInstance := &v1alpha1.ClusterInstance{}
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions, conditions.Provisioned, reason,
+			metav1.ConditionTrue, "")
+		return clusterInstance
+	}
+
+	It("sets the version reported by the ClusterDeployment once the cluster is provisioned", func() {
+		clusterDeployment := &hivev1.ClusterDeployment{
+			Status: hivev1.ClusterDeploymentStatus{InstallVersion: &installVersion},
+		}
+		clusterInstance := provisionedCI(conditions.Completed)
+
+		Expect(updateCIInstalledVersion(clusterDeployment, clusterInstance)).To(BeFalse())
+		Expect(clusterInstance.Status.InstalledVersion).To(Equal("4.15.12"))
+	})
+
+	It("does not set the version while the cluster is being provisioned", func() {
+		clusterDeployment := &hivev1.ClusterDeployment{
+			Status: hivev1.ClusterDeploymentStatus{InstallVersion: &installVersion},
+		}
+		clusterInstance := provisionedCI(conditions.InProgress)
+
+		Expect(updateCIInstalledVersion(clusterDeployment, clusterInstance)).To(BeFalse())
+		Expect(clusterInstance.Status.InstalledVersion).To(BeEmpty())
+	})
+
+	It("waits for the ClusterDeployment to report the version", func() {
+		clusterInstance := provisionedCI(conditions.Completed)
+
+		Expect(updateCIInstalledVersion(&hivev1.ClusterDeployment{}, clusterInstance)).To(BeTrue())
+		Expect(clusterInstance.Status.InstalledVersion).To(BeEmpty())
+
+		// A previously recorded version is kept
+		clusterInstance.Status.InstalledVersion = "4.15.12"
+		Expect(updateCIInstalledVersion(&hivev1.ClusterDeployment{}, clusterInstance)).To(BeFalse())
+		Expect(clusterInstance.Status.InstalledVersion).To(Equal("4.15.12"))
+	})
+
+	It("stops waiting for the version once the wait period has elapsed", func() {
+		clusterInstance := provisionedCI(conditions.Completed)
+		clusterInstance.Status.Conditions[0].LastTransitionTime = metav1.NewTime(
+			time.Now().Add(-installedVersionWaitPeriod))
+
+		// The version is then picked up from the ClusterDeployment update that reports it
+		Expect(updateCIInstalledVersion(&hivev1.ClusterDeployment{}, clusterInstance)).To(BeFalse())
+		Expect(clusterInstance.Status.InstalledVersion).To(BeEmpty())
+	})
+})
+
+var _ = Describe("updateCIInfraID", func() {
+	provisionedCI := func(reason conditions.ConditionReason) *v1alpha1.ClusterInstance {
+		clusterInstance := &v1alpha1.ClusterInstance{}
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions, conditions.Provisioned, reason,
+			metav1.ConditionTrue, "")
+		return clusterInstance
+	}
+	clusterDeployment := &hivev1.ClusterDeployment{
+		Spec: hivev1.ClusterDeploymentSpec{
+			ClusterMetadata: &hivev1.ClusterMetadata{ClusterID: "b7a2c1d4", InfraID: "test-cluster-x7k2p"},
+		},
+	}
+
+	It("records the infraID reported by the ClusterDeployment once the cluster is provisioned", func() {
+		clusterInstance := provisionedCI(conditions.Completed)
+
+		updateCIInfraID(clusterDeployment, clusterInstance)
+		Expect(clusterInstance.Status.InfraID).To(Equal("test-cluster-x7k2p"))
+	})
+
+	It("does not record the infraID while the cluster is being provisioned", func() {
+		clusterInstance := provisionedCI(conditions.InProgress)
+
+		updateCIInfraID(clusterDeployment, clusterInstance)
+		Expect(clusterInstance.Status.InfraID).To(BeEmpty())
+	})
+
+	It("keeps the recorded infraID while the ClusterDeployment does not report it", func() {
+		clusterInstance := provisionedCI(conditions.Completed)
+
+		updateCIInfraID(&hivev1.ClusterDeployment{}, clusterInstance)
+		Expect(clusterInstance.Status.InfraID).To(BeEmpty())
+
+		clusterInstance.Status.InfraID = "test-cluster-x7k2p"
+		updateCIInfraID(&hivev1.ClusterDeployment{}, clusterInstance)
+		Expect(clusterInstance.Status.InfraID).To(Equal("test-cluster-x7k2p"))
+	})
+})
+
+var _ = Describe("updateCIConsistencyStatus", func() {
+	var (
+		clusterInstance   *v1alpha1.ClusterInstance
+		clusterDeployment *hivev1.ClusterDeployment
+	)
+
+	BeforeEach(func() {
+		clusterInstance = &v1alpha1.ClusterInstance{
+			Spec: v1alpha1.ClusterInstanceSpec{
+				ClusterName:            "test-cluster",
+				BaseDomain:             "example.com",
+				ClusterImageSetNameRef: "img4.16",
+			},
+		}
+		clusterDeployment = &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-cluster"},
+			Spec: hivev1.ClusterDeploymentSpec{
+				ClusterName: "test-cluster",
+				BaseDomain:  "example.com",
+			},
+		}
+	})
+
+	It("reports a ClusterDeployment matching the ClusterInstance spec as consistent", func() {
+		updateCIConsistencyStatus(clusterDeployment, clusterInstance)
+
+		cond := meta.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.ClusterDeploymentInconsistent))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Reason).To(Equal(string(conditions.InSync)))
+	})
+
+	It("reports the identity fields of the ClusterDeployment not matching the ClusterInstance spec", func() {
+		clusterDeployment.Spec.BaseDomain = "example.org"
+		clusterDeployment.Spec.Provisioning = &hivev1.Provisioning{
+			ImageSetRef: &hivev1.ClusterImageSetReference{Name: "img4.15"},
+		}
+		updateCIConsistencyStatus(clusterDeployment, clusterInstance)
+
+		cond := meta.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.ClusterDeploymentInconsistent))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(cond.Reason).To(Equal(string(conditions.IdentityMismatch)))
+		Expect(cond.Message).To(Equal("The ClusterDeployment test-cluster does not match the ClusterInstance spec: " +
+			`baseDomain "example.org" does not match "example.com", imageSetRef "img4.15" does not match "img4.16"`))
+
+		// The condition is cleared once the ClusterDeployment matches again
+		clusterDeployment.Spec.BaseDomain = "example.com"
+		clusterDeployment.Spec.Provisioning = nil
+		updateCIConsistencyStatus(clusterDeployment, clusterInstance)
+		Expect(meta.IsStatusConditionFalse(clusterInstance.Status.Conditions,
+			string(conditions.ClusterDeploymentInconsistent))).To(BeTrue())
+	})
+
+	It("reports a ClusterDeployment of another cluster as inconsistent", func() {
+		clusterDeployment.Spec.ClusterName = "other-cluster"
+		Expect(clusterDeploymentInconsistencies(clusterDeployment, clusterInstance)).To(Equal(
+			[]string{`clusterName "other-cluster" does not match "test-cluster"`}))
+	})
+})
+
+var _ = Describe("updateCIDeprovisionedStatus", func() {
+	DescribeTable("maps the ClusterDeployment deprovision status to the Deprovisioned condition",
+		func(cdConditions []hivev1.ClusterDeploymentCondition, status metav1.ConditionStatus,
+			reason conditions.ConditionReason) {
+			now := metav1.Now()
+			clusterDeployment := &hivev1.ClusterDeployment{
+				ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &now},
+				Status:     hivev1.ClusterDeploymentStatus{Conditions: cdConditions},
+			}
+			clusterInstance := &v1alpha1.ClusterInstance{}
+			updateCIDeprovisionedStatus(clusterDeployment, clusterInstance)
+
+			deprovisioned := conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+				string(conditions.ClusterDeprovisioned))
+			Expect(deprovisioned).ToNot(BeNil())
+			Expect(deprovisioned.Status).To(Equal(status))
+			Expect(deprovisioned.Reason).To(Equal(string(reason)))
+		},
+		Entry("deprovisioning", []hivev1.ClusterDeploymentCondition{{
+			Type: hivev1.ProvisionedCondition, Status: corev1.ConditionFalse,
+			Reason: hivev1.ProvisionedReasonDeprovisioning,
+		}}, metav1.ConditionFalse, conditions.InProgress),
+		Entry("deprovisioned", []hivev1.ClusterDeploymentCondition{{
+			Type: hivev1.ProvisionedCondition, Status: corev1.ConditionFalse,
+			Reason: hivev1.ProvisionedReasonDeprovisioned,
+		}}, metav1.ConditionTrue, conditions.Completed),
+		Entry("deprovision failed", []hivev1.ClusterDeploymentCondition{{
+			Type: hivev1.ProvisionedCondition, Status: corev1.ConditionFalse,
+			Reason: hivev1.ProvisionedReasonDeprovisionFailed,
+		}}, metav1.ConditionFalse, conditions.Failed),
+		Entry("deprovision failed to launch", []hivev1.ClusterDeploymentCondition{{
+			Type: hivev1.DeprovisionLaunchErrorCondition, Status: corev1.ConditionTrue,
+		}}, metav1.ConditionFalse, conditions.Failed),
+	)
+
+	It("sanitizes the deprovisioning failure message reported by the ClusterDeployment", func() {
+		now := metav1.Now()
+		clusterDeployment := &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &now},
+			Status: hivev1.ClusterDeploymentStatus{Conditions: []hivev1.ClusterDeploymentCondition{{
+				Type: hivev1.ProvisionedCondition, Status: corev1.ConditionFalse,
+				Reason:  hivev1.ProvisionedReasonDeprovisionFailed,
+				Message: "deprovision job failed:\n\tsecret not found\x00" + strings.Repeat("a", conditions.MaxMessageLength),
+			}}},
+		}
+		clusterInstance := &v1alpha1.ClusterInstance{}
+		updateCIDeprovisionedStatus(clusterDeployment, clusterInstance)
+
+		deprovisioned := conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.ClusterDeprovisioned))
+		Expect(deprovisioned).ToNot(BeNil())
+		Expect(deprovisioned.Message).To(HavePrefix("Deprovisioning failed: deprovision job failed:  secret not foundaaa"))
+		Expect(deprovisioned.Message).To(HaveSuffix("..."))
+		Expect(deprovisioned.Message).To(HaveLen(conditions.MaxMessageLength))
+	})
+
+	It("does not set the Deprovisioned condition while the ClusterDeployment is not deleted", func() {
+		clusterInstance := &v1alpha1.ClusterInstance{}
+		updateCIDeprovisionedStatus(&hivev1.ClusterDeployment{}, clusterInstance)
+		Expect(clusterInstance.Status.Conditions).To(BeEmpty())
+	})
+})
+
+var _ = Describe("installSecretsExpiryFailure", func() {
+	DescribeTable("detects installation failures caused by an expired token",
+		func(reasons []string, conditionType hivev1.ClusterDeploymentConditionType, status corev1.ConditionStatus,
+			reason, message string, expected bool) {
+			r := &ClusterDeploymentReconciler{InstallSecretsExpiryReasons: reasons}
+			failed := r.installSecretsExpiryFailure(&hivev1.ClusterDeployment{
+				Status: hivev1.ClusterDeploymentStatus{
+					Conditions: []hivev1.ClusterDeploymentCondition{{
+						Type:    conditionType,
+						Status:  status,
+						Reason:  reason,
+						Message: message,
+					}},
+				},
+			})
+			Expect(failed != nil).To(Equal(expected))
+		},
+		Entry("default expiry reason", nil, hivev1.ClusterInstallFailedClusterDeploymentCondition,
+			corev1.ConditionTrue, "InstallTokenExpired", "", true),
+		Entry("expiry reason of a failed provision", nil, hivev1.ProvisionFailedCondition,
+			corev1.ConditionTrue, "IgnitionTokenExpired", "", true),
+		Entry("configured expiry reason", []string{"CertificateExpired"},
+			hivev1.ClusterInstallFailedClusterDeploymentCondition, corev1.ConditionTrue, "CertificateExpired", "", true),
+		Entry("default reason not configured", []string{"CertificateExpired"},
+			hivev1.ClusterInstallFailedClusterDeploymentCondition, corev1.ConditionTrue, "InstallTokenExpired", "", false),
+		Entry("expiry only mentioned in the message", nil, hivev1.ClusterInstallFailedClusterDeploymentCondition,
+			corev1.ConditionTrue, "InstallationFailed", "The installation failed: certificate has expired", false),
+		Entry("failure cleared", nil, hivev1.ClusterInstallFailedClusterDeploymentCondition,
+			corev1.ConditionFalse, "InstallTokenExpired", "", false),
+	)
+})
+
+var _ = Describe("updateCIDeploymentConditions", func() {
+	var (
+		clusterDeployment *hivev1.ClusterDeployment
+		clusterInstance   *v1alpha1.ClusterInstance
+		transitionedAt    = metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	)
+
+	BeforeEach(func() {
+		clusterDeployment = &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-cluster"},
+			Status: hivev1.ClusterDeploymentStatus{
+				Conditions: []hivev1.ClusterDeploymentCondition{{
+					Type:   hivev1.ClusterInstallStoppedClusterDeploymentCondition,
+					Status: corev1.ConditionFalse,
+				}},
+			},
+		}
+		clusterInstance = &v1alpha1.ClusterInstance{
+			Status: v1alpha1.ClusterInstanceStatus{
+				DeploymentConditions: []hivev1.ClusterDeploymentCondition{{
+					Type:               hivev1.ClusterInstallStoppedClusterDeploymentCondition,
+					Status:             corev1.ConditionFalse,
+					LastTransitionTime: transitionedAt,
+				}},
+			},
+		}
+	})
+
+	It("preserves the persisted transition time when the status is unchanged", func() {
+		updateCIDeploymentConditions(clusterDeployment, clusterInstance, 0)
+
+		cond := conditions.FindCDConditionType(clusterInstance.Status.DeploymentConditions,
+			hivev1.ClusterInstallStoppedClusterDeploymentCondition)
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.LastTransitionTime).To(Equal(transitionedAt))
+	})
+
+	It("updates the transition time when the status changes", func() {
+		clusterDeployment.Status.Conditions[0].Status = corev1.ConditionTrue
+		updateCIDeploymentConditions(clusterDeployment, clusterInstance, 0)
+
+		cond := conditions.FindCDConditionType(clusterInstance.Status.DeploymentConditions,
+			hivev1.ClusterInstallStoppedClusterDeploymentCondition)
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(corev1.ConditionTrue))
+		Expect(cond.LastTransitionTime.After(transitionedAt.Time)).To(BeTrue())
+	})
+
+	It("mirrors the diagnostic conditions only once reported by the ClusterDeployment", func() {
+		updateCIDeploymentConditions(clusterDeployment, clusterInstance, 0)
+		Expect(clusterInstance.Status.DeploymentConditions).To(HaveLen(len(clusterInstallConditionTypes())))
+		Expect(conditions.FindCDConditionType(clusterInstance.Status.DeploymentConditions,
+			hivev1.DNSNotReadyCondition)).To(BeNil())
+
+		clusterDeployment.Status.Conditions = append(clusterDeployment.Status.Conditions,
+			hivev1.ClusterDeploymentCondition{
+				Type:    hivev1.DNSNotReadyCondition,
+				Status:  corev1.ConditionTrue,
+				Reason:  "DNSNotReadyTimedOut",
+				Message: "DNS zone not ready",
+			})
+		updateCIDeploymentConditions(clusterDeployment, clusterInstance, 0)
+
+		cond := conditions.FindCDConditionType(clusterInstance.Status.DeploymentConditions,
+			hivev1.DNSNotReadyCondition)
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(corev1.ConditionTrue))
+		Expect(cond.Reason).To(Equal("DNSNotReadyTimedOut"))
+		Expect(clusterInstance.Status.DeploymentConditions).To(HaveLen(len(clusterInstallConditionTypes()) + 1))
+	})
+
+	It("mirrors the additional condition types once, even when they overlap with the tracked ones", func() {
+		clusterDeployment.Status.Conditions = append(clusterDeployment.Status.Conditions,
+			hivev1.ClusterDeploymentCondition{
+				Type:   hivev1.SyncSetFailedCondition,
+				Status: corev1.ConditionTrue,
+				Reason: "SyncSetApplyFailure",
+			},
+			hivev1.ClusterDeploymentCondition{
+				Type:   hivev1.AuthenticationFailureClusterDeploymentCondition,
+				Status: corev1.ConditionFalse,
+				Reason: "AuthenticationSucceeded",
+			})
+
+		for i := 0; i < 2; i++ {
+			updateCIDeploymentConditions(clusterDeployment, clusterInstance, 0,
+				hivev1.SyncSetFailedCondition,
+				hivev1.SyncSetFailedCondition,
+				hivev1.AuthenticationFailureClusterDeploymentCondition,
+				hivev1.ClusterInstallStoppedClusterDeploymentCondition)
+		}
+
+		counts := map[hivev1.ClusterDeploymentConditionType]int{}
+		for _, cond := range clusterInstance.Status.DeploymentConditions {
+			counts[cond.Type]++
+		}
+		Expect(counts).To(HaveLen(len(clusterInstallConditionTypes()) + 2))
+		for conditionType, count := range counts {
+			Expect(count).To(Equal(1), "condition type %s", conditionType)
+		}
+
+		cond := conditions.FindCDConditionType(clusterInstance.Status.DeploymentConditions,
+			hivev1.SyncSetFailedCondition)
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Reason).To(Equal("SyncSetApplyFailure"))
+	})
+
+	DescribeTable("sorts the DeploymentConditions into the canonical order regardless of their existing order",
+		func(existing []hivev1.ClusterDeploymentConditionType) {
+			clusterDeployment.Status.Conditions = append(clusterDeployment.Status.Conditions,
+				hivev1.ClusterDeploymentCondition{Type: hivev1.SyncSetFailedCondition, Status: corev1.ConditionFalse},
+				hivev1.ClusterDeploymentCondition{Type: hivev1.DNSNotReadyCondition, Status: corev1.ConditionFalse})
+			clusterInstance.Status.DeploymentConditions = nil
+			for _, conditionType := range existing {
+				clusterInstance.Status.DeploymentConditions = append(clusterInstance.Status.DeploymentConditions,
+					hivev1.ClusterDeploymentCondition{Type: conditionType, Status: corev1.ConditionUnknown})
+			}
+
+			updateCIDeploymentConditions(clusterDeployment, clusterInstance, 0, hivev1.SyncSetFailedCondition)
+
+			var types []hivev1.ClusterDeploymentConditionType
+			for _, cond := range clusterInstance.Status.DeploymentConditions {
+				types = append(types, cond.Type)
+			}
+			Expect(types).To(Equal([]hivev1.ClusterDeploymentConditionType{
+				hivev1.ClusterInstallRequirementsMetClusterDeploymentCondition,
+				hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+				hivev1.ClusterInstallFailedClusterDeploymentCondition,
+				hivev1.ClusterInstallStoppedClusterDeploymentCondition,
+				hivev1.DNSNotReadyCondition,
+				hivev1.SyncSetFailedCondition,
+				"Alpha",
+				"Zeta",
+			}))
+		},
+		Entry("in canonical order", []hivev1.ClusterDeploymentConditionType{
+			hivev1.ClusterInstallRequirementsMetClusterDeploymentCondition,
+			hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+			hivev1.ClusterInstallFailedClusterDeploymentCondition,
+			hivev1.ClusterInstallStoppedClusterDeploymentCondition,
+			hivev1.DNSNotReadyCondition,
+			hivev1.SyncSetFailedCondition,
+			"Alpha",
+			"Zeta",
+		}),
+		Entry("in reverse order", []hivev1.ClusterDeploymentConditionType{
+			"Zeta",
+			"Alpha",
+			hivev1.SyncSetFailedCondition,
+			hivev1.DNSNotReadyCondition,
+			hivev1.ClusterInstallStoppedClusterDeploymentCondition,
+			hivev1.ClusterInstallFailedClusterDeploymentCondition,
+			hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+			hivev1.ClusterInstallRequirementsMetClusterDeploymentCondition,
+		}),
+		Entry("partially mirrored", []hivev1.ClusterDeploymentConditionType{
+			"Zeta",
+			hivev1.ClusterInstallStoppedClusterDeploymentCondition,
+			"Alpha",
+		}),
+	)
 })
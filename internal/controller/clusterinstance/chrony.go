@@ -0,0 +1,134 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	k8syaml "sigs.k8s.io/yaml"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+)
+
+const (
+	// chronySyncWave is the sync-wave of the ConfigMap holding the chrony MachineConfigs, it is applied before the
+	// installation manifests referencing it
+	chronySyncWave = "0"
+	// chronyConfigPath is the path of the chrony configuration on the nodes
+	chronyConfigPath = "/etc/chrony.conf"
+	// machineConfigRoleLabel is the label selecting the MachineConfigPool of a MachineConfig
+	machineConfigRoleLabel = "machineconfiguration.openshift.io/role"
+)
+
+// chronyRoles are the roles of the nodes the chrony configuration is installed on
+var chronyRoles = []string{"master", "worker"}
+
+// defaultChronyMakeStep is the stepping of the clock when the ChronyConfig does not configure it
+var defaultChronyMakeStep = v1alpha1.ChronyMakeStep{ThresholdSeconds: 1, Limit: 3}
+
+// ChronyConfigMapName returns the name of the ConfigMap holding the chrony MachineConfigs of the cluster, it is added
+// to the ExtraManifestsRefs of the rendering context
+func ChronyConfigMapName(clusterInstance *v1alpha1.ClusterInstance) string {
+	return clusterInstance.Spec.ClusterName + "-chrony-config"
+}
+
+// chronyConf returns the content of the chrony configuration file
+func chronyConf(config *v1alpha1.ChronyConfig) string {
+	makeStep := defaultChronyMakeStep
+	if config.MakeStep != nil {
+		makeStep = *config.MakeStep
+	}
+
+	var conf strings.Builder
+	for _, server := range config.Servers {
+		fmt.Fprintf(&conf, "server %s iburst\n", server)
+	}
+	conf.WriteString("driftfile /var/lib/chrony/drift\n")
+	fmt.Fprintf(&conf, "makestep %d %d\n", makeStep.ThresholdSeconds, makeStep.Limit)
+	conf.WriteString("rtcsync\n")
+	conf.WriteString("logdir /var/log/chrony\n")
+	for _, directive := range config.ExtraDirectives {
+		conf.WriteString(directive + "\n")
+	}
+	return conf.String()
+}
+
+// chronyMachineConfig returns the MachineConfig installing the chrony configuration on the nodes of the given role
+func chronyMachineConfig(role, conf string) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "machineconfiguration.openshift.io/v1",
+		"kind":       "MachineConfig",
+		"metadata": map[string]interface{}{
+			"name":   fmt.Sprintf("99-%s-chrony-configuration", role),
+			"labels": map[string]interface{}{machineConfigRoleLabel: role},
+		},
+		"spec": map[string]interface{}{
+			"config": map[string]interface{}{
+				"ignition": map[string]interface{}{"version": "3.2.0"},
+				"storage": map[string]interface{}{
+					"files": []interface{}{
+						map[string]interface{}{
+							"contents": map[string]interface{}{
+								"source": "data:text/plain;charset=utf-8;base64," +
+									base64.StdEncoding.EncodeToString([]byte(conf)),
+							},
+							"mode":      420,
+							"overwrite": true,
+							"path":      chronyConfigPath,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// renderChronyConfig returns the manifest of the ConfigMap holding the chrony MachineConfigs of the cluster, one per
+// node role, to be included in the installation manifests. The ConfigMap is annotated and labeled like the manifests
+// rendered from the cluster-level templates.
+func renderChronyConfig(clusterInstance *v1alpha1.ClusterInstance) ([]interface{}, error) {
+	if clusterInstance.Spec.ChronyConfig == nil {
+		return nil, nil
+	}
+
+	conf := chronyConf(clusterInstance.Spec.ChronyConfig)
+	data := map[string]interface{}{}
+	for _, role := range chronyRoles {
+		machineConfig, err := k8syaml.Marshal(chronyMachineConfig(role, conf))
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize the %s chrony MachineConfig: %w", role, err)
+		}
+		data[fmt.Sprintf("99-%s-chrony-configuration.yaml", role)] = string(machineConfig)
+	}
+
+	manifest := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":        ChronyConfigMapName(clusterInstance),
+			"namespace":   clusterInstance.Spec.ClusterName,
+			"annotations": map[string]interface{}{WaveAnnotation: chronySyncWave},
+		},
+		"data": data,
+	}
+	extraAnnotations, _ := clusterInstance.Spec.ExtraAnnotationSearch("ConfigMap")
+	manifest = appendManifestAnnotations(extraAnnotations, manifest)
+	manifest = appendManifestLabels(clusterInstance.Spec.ChargebackMetadata, manifest)
+	return []interface{}{manifest}, nil
+}
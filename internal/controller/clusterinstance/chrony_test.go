@@ -0,0 +1,98 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	k8syaml "sigs.k8s.io/yaml"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+)
+
+func Test_chronyConf(t *testing.T) {
+	config := &v1alpha1.ChronyConfig{
+		Servers:         []string{"192.0.2.1", "ntp.example.com"},
+		ExtraDirectives: []string{"maxdistance 16"},
+	}
+	assert.Equal(t, `server 192.0.2.1 iburst
+server ntp.example.com iburst
+driftfile /var/lib/chrony/drift
+makestep 1 3
+rtcsync
+logdir /var/log/chrony
+maxdistance 16
+`, chronyConf(config))
+
+	config.MakeStep = &v1alpha1.ChronyMakeStep{ThresholdSeconds: 10, Limit: -1}
+	assert.Contains(t, chronyConf(config), "makestep 10 -1\n")
+}
+
+func Test_renderChronyConfig(t *testing.T) {
+	clusterInstance := &v1alpha1.ClusterInstance{
+		Spec: v1alpha1.ClusterInstanceSpec{
+			ClusterName:        "site-sno-du-1",
+			ChargebackMetadata: map[string]string{"team": "ran"},
+			ExtraAnnotations:   map[string]map[string]string{"ConfigMap": {"foo": "bar"}},
+		},
+	}
+
+	manifests, err := renderChronyConfig(clusterInstance)
+	assert.Nil(t, err)
+	assert.Empty(t, manifests)
+
+	clusterInstance.Spec.ChronyConfig = &v1alpha1.ChronyConfig{Servers: []string{"192.0.2.1"}}
+	manifests, err = renderChronyConfig(clusterInstance)
+	assert.Nil(t, err)
+	assert.Len(t, manifests, 1)
+
+	manifest := manifests[0].(map[string]interface{})
+	assert.Equal(t, "ConfigMap", manifest["kind"])
+	metadata := manifest["metadata"].(map[string]interface{})
+	assert.Equal(t, "site-sno-du-1-chrony-config", metadata["name"])
+	assert.Equal(t, "site-sno-du-1", metadata["namespace"])
+	assert.Equal(t, map[string]interface{}{WaveAnnotation: chronySyncWave, "foo": "bar"}, metadata["annotations"])
+	assert.Equal(t, map[string]interface{}{"team": "ran"}, metadata["labels"])
+
+	data := manifest["data"].(map[string]interface{})
+	assert.Len(t, data, len(chronyRoles))
+	for _, role := range chronyRoles {
+		content, ok := data["99-"+role+"-chrony-configuration.yaml"].(string)
+		assert.True(t, ok, role)
+
+		machineConfig := map[string]interface{}{}
+		assert.Nil(t, k8syaml.Unmarshal([]byte(content), &machineConfig))
+		assert.Equal(t, "MachineConfig", machineConfig["kind"])
+		labels := machineConfig["metadata"].(map[string]interface{})["labels"]
+		assert.Equal(t, map[string]interface{}{machineConfigRoleLabel: role}, labels)
+
+		config := machineConfig["spec"].(map[string]interface{})["config"].(map[string]interface{})
+		files := config["storage"].(map[string]interface{})["files"].([]interface{})
+		assert.Len(t, files, 1)
+		file := files[0].(map[string]interface{})
+		assert.Equal(t, chronyConfigPath, file["path"])
+		source := file["contents"].(map[string]interface{})["source"].(string)
+		encoded, found := strings.CutPrefix(source, "data:text/plain;charset=utf-8;base64,")
+		assert.True(t, found)
+		conf, err := base64.StdEncoding.DecodeString(encoded)
+		assert.Nil(t, err)
+		assert.Equal(t, chronyConf(clusterInstance.Spec.ChronyConfig), string(conf))
+	}
+}
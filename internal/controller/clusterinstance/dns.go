@@ -0,0 +1,141 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/go-logr/logr"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+)
+
+// The DNS record types created for the VIPs of the cluster
+const (
+	DNSRecordTypeA    = "A"
+	DNSRecordTypeAAAA = "AAAA"
+)
+
+// DNSRecord is a DNS record of the cluster, resolving a fully qualified name to the given targets
+type DNSRecord struct {
+	Name    string
+	Type    string
+	Targets []string
+}
+
+// String returns the name, type and targets of the record, e.g. "api.sno1.example.com A 192.0.2.10"
+func (r DNSRecord) String() string {
+	return fmt.Sprintf("%s %s %s", r.Name, r.Type, strings.Join(r.Targets, ","))
+}
+
+// Key returns the name and type identifying the record, e.g. "api.sno1.example.com A"
+func (r DNSRecord) Key() string {
+	return fmt.Sprintf("%s %s", r.Name, r.Type)
+}
+
+// ParseDNSRecordKey returns the record identified by the "<name> <type>" key, without its targets
+func ParseDNSRecordKey(key string) (DNSRecord, error) {
+	name, recordType, found := strings.Cut(key, " ")
+	if !found || name == "" || recordType == "" {
+		return DNSRecord{}, fmt.Errorf("invalid DNS record %q, expected <name> <type>", key)
+	}
+	return DNSRecord{Name: name, Type: recordType}, nil
+}
+
+// DNSProvider creates and deletes the DNS records of the clusters in the DNS service of the environment
+type DNSProvider interface {
+	// EnsureRecords creates the records, or updates their targets if they already exist
+	EnsureRecords(ctx context.Context, records []DNSRecord) error
+	// DeleteRecords deletes the records identified by their name and type, their targets may be empty. The records
+	// that do not exist are ignored.
+	DeleteRecords(ctx context.Context, records []DNSRecord) error
+}
+
+// LoggingDNSProvider is a DNSProvider that only logs the records, for the environments whose DNS records are managed
+// out of band and for testing
+type LoggingDNSProvider struct {
+	Log logr.Logger
+}
+
+// EnsureRecords logs the records to create
+func (p *LoggingDNSProvider) EnsureRecords(_ context.Context, records []DNSRecord) error {
+	for _, record := range records {
+		p.Log.Info("Ensuring DNS record", "record", record.String())
+	}
+	return nil
+}
+
+// DeleteRecords logs the records to delete
+func (p *LoggingDNSProvider) DeleteRecords(_ context.Context, records []DNSRecord) error {
+	for _, record := range records {
+		p.Log.Info("Deleting DNS record", "record", record.String())
+	}
+	return nil
+}
+
+// vipRecords returns the A and AAAA records resolving the name to the IPv4 and IPv6 VIPs
+func vipRecords(name string, vips []string) []DNSRecord {
+	var records []DNSRecord
+	var ipv4, ipv6 []string
+	for _, vip := range vips {
+		if ip := net.ParseIP(vip); ip != nil && ip.To4() != nil {
+			ipv4 = append(ipv4, vip)
+		} else {
+			ipv6 = append(ipv6, vip)
+		}
+	}
+	if len(ipv4) > 0 {
+		records = append(records, DNSRecord{Name: name, Type: DNSRecordTypeA, Targets: ipv4})
+	}
+	if len(ipv6) > 0 {
+		records = append(records, DNSRecord{Name: name, Type: DNSRecordTypeAAAA, Targets: ipv6})
+	}
+	return records
+}
+
+// ClusterDNSRecords returns the DNS records of the cluster FQDN: the api record resolving to the API VIPs and the
+// *.apps wildcard record resolving to the ingress VIPs
+func ClusterDNSRecords(clusterInstance *v1alpha1.ClusterInstance) []DNSRecord {
+	clusterDomain := fmt.Sprintf("%s.%s", clusterInstance.Spec.ClusterName, clusterInstance.Spec.BaseDomain)
+	return append(vipRecords("api."+clusterDomain, clusterInstance.Spec.ApiVIPs),
+		vipRecords("*.apps."+clusterDomain, clusterInstance.Spec.IngressVIPs)...)
+}
+
+// StaleDNSRecords returns the records identified by the given keys, as recorded once created, that are not part of the
+// desired records, e.g. the AAAA record of removed IPv6 VIPs or the records of a former cluster name
+func StaleDNSRecords(keys []string, desired []DNSRecord) ([]DNSRecord, error) {
+	desiredKeys := map[string]bool{}
+	for _, record := range desired {
+		desiredKeys[record.Key()] = true
+	}
+
+	var stale []DNSRecord
+	for _, key := range keys {
+		if desiredKeys[key] {
+			continue
+		}
+		record, err := ParseDNSRecordKey(key)
+		if err != nil {
+			return nil, err
+		}
+		stale = append(stale, record)
+	}
+	return stale, nil
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+)
+
+func Test_ClusterDNSRecords(t *testing.T) {
+	clusterInstance := &v1alpha1.ClusterInstance{
+		Spec: v1alpha1.ClusterInstanceSpec{
+			ClusterName: "site-1",
+			BaseDomain:  "example.com",
+			ApiVIPs:     []string{"192.0.2.10", "2001:db8::10"},
+			IngressVIPs: []string{"192.0.2.11"},
+		},
+	}
+	assert.Equal(t, []DNSRecord{
+		{Name: "api.site-1.example.com", Type: DNSRecordTypeA, Targets: []string{"192.0.2.10"}},
+		{Name: "api.site-1.example.com", Type: DNSRecordTypeAAAA, Targets: []string{"2001:db8::10"}},
+		{Name: "*.apps.site-1.example.com", Type: DNSRecordTypeA, Targets: []string{"192.0.2.11"}},
+	}, ClusterDNSRecords(clusterInstance))
+
+	clusterInstance.Spec.ApiVIPs, clusterInstance.Spec.IngressVIPs = nil, nil
+	assert.Empty(t, ClusterDNSRecords(clusterInstance))
+}
+
+func Test_StaleDNSRecords(t *testing.T) {
+	desired := []DNSRecord{
+		{Name: "api.site-1.example.com", Type: DNSRecordTypeA, Targets: []string{"192.0.2.20"}},
+		{Name: "*.apps.site-1.example.com", Type: DNSRecordTypeA, Targets: []string{"192.0.2.21"}},
+	}
+
+	// The records whose targets changed are updated in place, they are not stale
+	stale, err := StaleDNSRecords([]string{
+		"api.site-1.example.com A", "api.site-1.example.com AAAA", "*.apps.site-1.example.com A",
+	}, desired)
+	assert.Nil(t, err)
+	assert.Equal(t, []DNSRecord{{Name: "api.site-1.example.com", Type: DNSRecordTypeAAAA}}, stale)
+
+	stale, err = StaleDNSRecords(nil, desired)
+	assert.Nil(t, err)
+	assert.Empty(t, stale)
+
+	_, err = StaleDNSRecords([]string{"api.site-1.example.com"}, desired)
+	assert.EqualError(t, err, `invalid DNS record "api.site-1.example.com", expected <name> <type>`)
+}
@@ -20,21 +20,132 @@ import (
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"net/url"
+	"regexp"
 	"strings"
 
 	sprig "github.com/go-task/slim-sprig"
 	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"golang.org/x/exp/slices"
+	corev1 "k8s.io/api/core/v1"
 	k8syaml "sigs.k8s.io/yaml"
 )
 
 const (
 	cpuPartitioningKey = "cpuPartitioningMode"
+	fipsKey            = "fips"
 )
 
 type SpecialVars struct {
 	CurrentNode                      v1alpha1.NodeSpec
 	InstallConfigOverrides           string
 	ControlPlaneAgents, WorkerAgents int
+	// DiskEncryption is the disk encryption of the AgentClusterInstall, nil when disk encryption is disabled
+	DiskEncryption *AgentDiskEncryption
+	// RootFSURL is the URL of the rootfs served from the BootArtifactsBaseURL, empty when it is not set
+	RootFSURL string
+	// InfraEnvName is the name of the InfraEnv the nodes are bound to, i.e. the InfraEnvRef or the generated InfraEnv
+	InfraEnvName string
+}
+
+// InfraEnvName returns the name of the InfraEnv the nodes are bound to, the referenced InfraEnv when the InfraEnvRef
+// is set, otherwise the InfraEnv generated from the templates, which is named after the cluster
+func InfraEnvName(clusterInstance *v1alpha1.ClusterInstance) string {
+	if clusterInstance.Spec.InfraEnvRef != nil && clusterInstance.Spec.InfraEnvRef.Name != "" {
+		return clusterInstance.Spec.InfraEnvRef.Name
+	}
+	return clusterInstance.Spec.ClusterName
+}
+
+// InfraEnvNamespace returns the namespace of the InfraEnv the nodes are bound to. The BareMetalHosts are bound to the
+// InfraEnv of their own namespace, therefore the referenced InfraEnv must be in the namespace of the rendered
+// manifests, which is named after the cluster.
+func InfraEnvNamespace(clusterInstance *v1alpha1.ClusterInstance) string {
+	return clusterInstance.Spec.ClusterName
+}
+
+// getRootFSURL returns the URL of the rootfs served from the BootArtifactsBaseURL of the ClusterInstance, empty when
+// the BootArtifactsBaseURL is not set
+func getRootFSURL(clusterInstance *v1alpha1.ClusterInstance) (string, error) {
+	if clusterInstance.Spec.BootArtifactsBaseURL == "" {
+		return "", nil
+	}
+	return url.JoinPath(clusterInstance.Spec.BootArtifactsBaseURL, "rootfs.img")
+}
+
+// AgentDiskEncryption is the disk encryption configuration of an AgentClusterInstall
+type AgentDiskEncryption struct {
+	EnableOn    string `json:"enableOn"`
+	Mode        string `json:"mode"`
+	TangServers string `json:"tangServers,omitempty"`
+}
+
+// getExpandedNoProxy returns the noProxy list of the proxy of the ClusterInstance with the machine, cluster and service
+// networks and the cluster domain appended, unless the expansion is disabled, no proxy is configured or the noProxy
+// already bypasses the proxy for all destinations. The user-provided entries are kept first and duplicates are dropped.
+func getExpandedNoProxy(clusterInstance *v1alpha1.ClusterInstance) string {
+	proxy := clusterInstance.Spec.Proxy
+	if proxy == nil {
+		return ""
+	}
+	if clusterInstance.Spec.DisableNoProxyExpansion || (proxy.HTTPProxy == "" && proxy.HTTPSProxy == "") ||
+		strings.TrimSpace(proxy.NoProxy) == "*" {
+		return proxy.NoProxy
+	}
+
+	var entries []string
+	add := func(entry string) {
+		entry = strings.TrimSpace(entry)
+		if entry != "" && !slices.Contains(entries, entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	for _, entry := range strings.Split(proxy.NoProxy, ",") {
+		add(entry)
+	}
+	for _, network := range clusterInstance.Spec.MachineNetwork {
+		add(network.CIDR)
+	}
+	for _, network := range clusterInstance.Spec.ClusterNetwork {
+		add(network.CIDR)
+	}
+	for _, network := range clusterInstance.Spec.ServiceNetwork {
+		add(network.CIDR)
+	}
+	if clusterInstance.Spec.ClusterName != "" && clusterInstance.Spec.BaseDomain != "" {
+		add(fmt.Sprintf(".%s.%s", clusterInstance.Spec.ClusterName, clusterInstance.Spec.BaseDomain))
+	}
+
+	return strings.Join(entries, ",")
+}
+
+// getAgentDiskEncryption converts the DiskEncryption of the ClusterInstance into the disk encryption of the
+// AgentClusterInstall, it returns nil when disk encryption is disabled
+func getAgentDiskEncryption(clusterInstance *v1alpha1.ClusterInstance) (*AgentDiskEncryption, error) {
+	diskEncryption := clusterInstance.Spec.DiskEncryption
+	if diskEncryption == nil {
+		return nil, nil
+	}
+
+	agentDiskEncryption := &AgentDiskEncryption{EnableOn: diskEncryption.EnableOn}
+	if agentDiskEncryption.EnableOn == "" {
+		agentDiskEncryption.EnableOn = "all"
+	}
+	switch diskEncryption.Type {
+	case v1alpha1.DiskEncryptionTypeTPM2:
+		agentDiskEncryption.Mode = "tpmv2"
+	case v1alpha1.DiskEncryptionTypeTang, v1alpha1.DiskEncryptionTypeNBDE:
+		agentDiskEncryption.Mode = "tang"
+		tangServers, err := json.Marshal(diskEncryption.Tang)
+		if err != nil {
+			return nil, err
+		}
+		agentDiskEncryption.TangServers = string(tangServers)
+	default:
+		return nil, nil
+	}
+	return agentDiskEncryption, nil
 }
 
 // ClusterData is a special object that provides an interface to the ClusterInstance spec fields for use in rendering
@@ -42,6 +153,33 @@ type SpecialVars struct {
 type ClusterData struct {
 	Spec        v1alpha1.ClusterInstanceSpec
 	SpecialVars SpecialVars
+	// Values are the TemplateValues of the ClusterInstance merged with the reserved values derived from it
+	Values map[string]string
+}
+
+// ReservedTemplateValues are the keys of the Values derived from the ClusterInstance, they cannot be set through the
+// TemplateValues
+var ReservedTemplateValues = []string{
+	"baseDomain",
+	"clusterImageSetNameRef",
+	"clusterName",
+	"clusterNamespace",
+	"clusterType",
+}
+
+// getTemplateValues returns the TemplateValues of the ClusterInstance merged with the reserved values, which take
+// precedence
+func getTemplateValues(clusterInstance *v1alpha1.ClusterInstance) map[string]string {
+	values := make(map[string]string, len(clusterInstance.Spec.TemplateValues)+len(ReservedTemplateValues))
+	for key, value := range clusterInstance.Spec.TemplateValues {
+		values[key] = value
+	}
+	values["baseDomain"] = clusterInstance.Spec.BaseDomain
+	values["clusterImageSetNameRef"] = clusterInstance.Spec.ClusterImageSetNameRef
+	values["clusterName"] = clusterInstance.Spec.ClusterName
+	values["clusterNamespace"] = clusterInstance.Namespace
+	values["clusterType"] = string(clusterInstance.Spec.ClusterType)
+	return values
 }
 
 // getWorkloadPinningInstallConfigOverrides applies workload pinning to install config overrides if applicable
@@ -71,6 +209,29 @@ func getWorkloadPinningInstallConfigOverrides(clusterInstance *v1alpha1.ClusterI
 	return scInstallConfigOverrides, nil
 }
 
+// getFIPSInstallConfigOverrides enables FIPS mode in the install config overrides if requested
+func getFIPSInstallConfigOverrides(clusterInstance *v1alpha1.ClusterInstance, installConfigOverrides string) (string, error) {
+	if !clusterInstance.Spec.FIPS {
+		return installConfigOverrides, nil
+	}
+
+	installOverrideValues := map[string]interface{}{}
+	if installConfigOverrides != "" {
+		if err := json.Unmarshal([]byte(installConfigOverrides), &installOverrideValues); err != nil {
+			return installConfigOverrides, err
+		}
+	}
+
+	// The explicit spec field takes precedence over a fips value set in the installConfigOverrides
+	installOverrideValues[fipsKey] = true
+
+	byteData, err := json.Marshal(installOverrideValues)
+	if err != nil {
+		return installConfigOverrides, err
+	}
+	return string(byteData), nil
+}
+
 // getInstallConfigOverrides builds the InstallConfigOverrides and returns it as a JSON string
 func getInstallConfigOverrides(clusterInstance *v1alpha1.ClusterInstance) (string, error) {
 
@@ -80,6 +241,18 @@ func getInstallConfigOverrides(clusterInstance *v1alpha1.ClusterInstance) (strin
 		return installConfigOverrides, err
 	}
 
+	// Get FIPS install config overrides
+	installConfigOverrides, err = getFIPSInstallConfigOverrides(clusterInstance, installConfigOverrides)
+	if err != nil {
+		return installConfigOverrides, err
+	}
+
+	// Get the ImageDigestSources install config overrides
+	installConfigOverrides, err = getImageDigestSourcesInstallConfigOverrides(clusterInstance, installConfigOverrides)
+	if err != nil {
+		return installConfigOverrides, err
+	}
+
 	var commonKey = "networking"
 	networkAnnotation := "{\"networking\":{\"networkType\":\"" + clusterInstance.Spec.NetworkType + "\"}}"
 	if !json.Valid([]byte(networkAnnotation)) {
@@ -126,7 +299,7 @@ func buildClusterData(clusterInstance *v1alpha1.ClusterInstance, node *v1alpha1.
 	// Prepare specialVars
 	var currentNode v1alpha1.NodeSpec
 	if node != nil {
-		currentNode = *node
+		currentNode = withoutInlineBmcCredentials(*node)
 	}
 
 	installConfigOverrides, err := getInstallConfigOverrides(clusterInstance)
@@ -134,6 +307,16 @@ func buildClusterData(clusterInstance *v1alpha1.ClusterInstance, node *v1alpha1.
 		installConfigOverrides = ""
 	}
 
+	diskEncryption, diskEncryptionErr := getAgentDiskEncryption(clusterInstance)
+	if diskEncryptionErr != nil {
+		return nil, diskEncryptionErr
+	}
+
+	rootFSURL, rootFSURLErr := getRootFSURL(clusterInstance)
+	if rootFSURLErr != nil {
+		return nil, rootFSURLErr
+	}
+
 	// Determine the number of control-plane and worker agents
 	controlPlaneAgents := 0
 	workerAgents := 0
@@ -146,19 +329,63 @@ func buildClusterData(clusterInstance *v1alpha1.ClusterInstance, node *v1alpha1.
 		}
 	}
 
+	spec := clusterInstance.Spec
+	// Reference the merged pull secret when additional pull secrets are defined
+	spec.PullSecretRef.Name = EffectivePullSecretName(clusterInstance)
+	if spec.Proxy != nil {
+		spec.Proxy = spec.Proxy.DeepCopy()
+		spec.Proxy.NoProxy = getExpandedNoProxy(clusterInstance)
+	}
+	// Include the chrony MachineConfigs in the installation manifests
+	if spec.ChronyConfig != nil {
+		spec.ExtraManifestsRefs = append(slices.Clone(spec.ExtraManifestsRefs),
+			corev1.LocalObjectReference{Name: ChronyConfigMapName(clusterInstance)})
+	}
+	// Include the ImageDigestMirrorSet in the installation manifests
+	if len(spec.ImageDigestSources) > 0 {
+		spec.ExtraManifestsRefs = append(slices.Clone(spec.ExtraManifestsRefs),
+			corev1.LocalObjectReference{Name: ImageDigestSourcesConfigMapName(clusterInstance)})
+	}
+	// Include the operator Subscriptions in the installation manifests
+	if len(spec.InstallOperators) > 0 {
+		spec.ExtraManifestsRefs = append(slices.Clone(spec.ExtraManifestsRefs),
+			corev1.LocalObjectReference{Name: InstallOperatorsConfigMapName(clusterInstance)})
+	}
+	// Keep the inline BMC credentials out of the rendering context
+	if len(clusterInstance.Spec.Nodes) > 0 {
+		spec.Nodes = make([]v1alpha1.NodeSpec, len(clusterInstance.Spec.Nodes))
+		for i, n := range clusterInstance.Spec.Nodes {
+			spec.Nodes[i] = withoutInlineBmcCredentials(n)
+		}
+	}
+
 	data = &ClusterData{
-		Spec: clusterInstance.Spec,
+		Spec: spec,
 		SpecialVars: SpecialVars{
 			CurrentNode:            currentNode,
 			InstallConfigOverrides: installConfigOverrides,
 			ControlPlaneAgents:     controlPlaneAgents,
 			WorkerAgents:           workerAgents,
+			DiskEncryption:         diskEncryption,
+			RootFSURL:              rootFSURL,
+			InfraEnvName:           InfraEnvName(clusterInstance),
 		},
+		Values: getTemplateValues(clusterInstance),
 	}
 
 	return
 }
 
+// withoutInlineBmcCredentials returns the node with its inline BMC credentials replaced by a reference to the secret
+// created from them
+func withoutInlineBmcCredentials(node v1alpha1.NodeSpec) v1alpha1.NodeSpec {
+	if node.BmcCredentials != nil {
+		node.BmcCredentialsName = v1alpha1.BmcCredentialsName{Name: node.InlineBmcCredentialsSecretName()}
+		node.BmcCredentials = nil
+	}
+	return node
+}
+
 // suppressManifest function returns true if the manifest-rendering should be suppressed
 func suppressManifest(kind string, suppressedManifests []string) bool {
 	if kind == "" || len(suppressedManifests) == 0 {
@@ -246,6 +473,29 @@ func appendManifestAnnotations(extraAnnotations map[string]string, manifest map[
 	return manifest
 }
 
+// appendManifestLabels adds the given labels to the manifest, labels already defined by the manifest are kept
+func appendManifestLabels(labels map[string]string, manifest map[string]interface{}) map[string]interface{} {
+	if len(labels) == 0 {
+		return manifest
+	}
+	if manifest["metadata"] == nil {
+		manifest["metadata"] = make(map[string]interface{})
+	}
+	metadata, _ := manifest["metadata"].(map[string]interface{})
+
+	if metadata["labels"] == nil {
+		metadata["labels"] = make(map[string]interface{})
+	}
+	manifestLabels, _ := metadata["labels"].(map[string]interface{})
+
+	for key, value := range labels {
+		if _, found := manifestLabels[key]; !found {
+			manifestLabels[key] = value
+		}
+	}
+	return manifest
+}
+
 // toYaml marshals a given field to Yaml
 func toYaml(v interface{}) string {
 	data, err := k8syaml.Marshal(v)
@@ -256,9 +506,29 @@ func toYaml(v interface{}) string {
 	return strings.TrimSuffix(string(data), "\n")
 }
 
-// funcMap provides additional useful functions for template rendering
+// disallowedTemplateFunctions are the Sprig functions that are not available to the templates, as they expose the
+// environment of the controller or reach out to the network
+var disallowedTemplateFunctions = []string{"env", "expandenv", "getHostByName"}
+
+// undefinedFunctionRegex matches the parse error of a template calling an undefined function
+var undefinedFunctionRegex = regexp.MustCompile(`function "([^"]+)" not defined`)
+
+// funcMap provides additional useful functions for template rendering, i.e. the Sprig functions minus the
+// disallowedTemplateFunctions, and toYaml
 func funcMap() template.FuncMap {
 	f := sprig.TxtFuncMap()
+	for _, name := range disallowedTemplateFunctions {
+		delete(f, name)
+	}
 	f["toYaml"] = toYaml
 	return f
 }
+
+// templateParseError returns a clear error when the template fails to parse because it calls a disallowed function
+func templateParseError(templateKey string, err error) error {
+	matches := undefinedFunctionRegex.FindStringSubmatch(err.Error())
+	if matches != nil && slices.Contains(disallowedTemplateFunctions, matches[1]) {
+		return fmt.Errorf("template %s uses the disallowed function %q: %w", templateKey, matches[1], err)
+	}
+	return err
+}
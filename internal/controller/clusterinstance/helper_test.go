@@ -21,8 +21,10 @@ import (
 	"reflect"
 	"testing"
 
+	aiv1beta1 "github.com/openshift/assisted-service/api/v1beta1"
 	"github.com/stolostron/siteconfig/api/v1alpha1"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -31,6 +33,8 @@ func Test_getInstallConfigOverrides(t *testing.T) {
 	testcases := []struct {
 		networkType, installConfigOverride string
 		CPUPartitioning                    v1alpha1.CPUPartitioningMode
+		FIPS                               bool
+		imageDigestSources                 []v1alpha1.ImageDigestSource
 		expected                           string
 		error                              error
 		name                               string
@@ -97,6 +101,39 @@ func Test_getInstallConfigOverrides(t *testing.T) {
 			error:                 nil,
 			name:                  "cpuPartitioningMode set to AllNodes",
 		},
+
+		{
+			networkType:           "OVNKubernetes",
+			installConfigOverride: "{\"controlPlane\":{\"hyperthreading\":\"Disabled\"}}",
+			CPUPartitioning:       v1alpha1.CPUPartitioningNone,
+			FIPS:                  true,
+			expected:              "{\"networking\":{\"networkType\":\"OVNKubernetes\"},\"controlPlane\":{\"hyperthreading\":\"Disabled\"},\"fips\":true}",
+			error:                 nil,
+			name:                  "fips enabled",
+		},
+
+		{
+			networkType:           "OVNKubernetes",
+			installConfigOverride: "",
+			CPUPartitioning:       v1alpha1.CPUPartitioningNone,
+			FIPS:                  true,
+			expected:              "{\"networking\":{\"networkType\":\"OVNKubernetes\"},\"fips\":true}",
+			error:                 nil,
+			name:                  "fips enabled when installConfigOverride is not set",
+		},
+
+		{
+			networkType:           "OVNKubernetes",
+			installConfigOverride: "{\"imageDigestSources\":[{\"source\":\"quay.io/foo\",\"mirrors\":[\"mirror.example.com/foo\"]}]}",
+			CPUPartitioning:       v1alpha1.CPUPartitioningNone,
+			imageDigestSources: []v1alpha1.ImageDigestSource{{
+				Source:  "quay.io/openshift-release-dev/ocp-release",
+				Mirrors: []string{"mirror.example.com:5000/ocp/release"},
+			}},
+			expected: "{\"networking\":{\"networkType\":\"OVNKubernetes\"},\"imageDigestSources\":[{\"mirrors\":[\"mirror.example.com:5000/ocp/release\"],\"source\":\"quay.io/openshift-release-dev/ocp-release\"}]}",
+			error:    nil,
+			name:     "imageDigestSources take precedence over the installConfigOverride",
+		},
 	}
 
 	for _, tc := range testcases {
@@ -110,6 +147,8 @@ func Test_getInstallConfigOverrides(t *testing.T) {
 					NetworkType:            tc.networkType,
 					InstallConfigOverrides: tc.installConfigOverride,
 					CPUPartitioning:        tc.CPUPartitioning,
+					FIPS:                   tc.FIPS,
+					ImageDigestSources:     tc.imageDigestSources,
 				},
 			}
 			actual, err := getInstallConfigOverrides(clusterInstance)
@@ -128,6 +167,13 @@ func Test_buildClusterData(t *testing.T) {
 	InstallConfigOverrides := "{\"controlPlane\":{\"hyperthreading\":\"Disabled\"}}"
 	CPUPartitioning := v1alpha1.CPUPartitioningNone
 	expectedInstallConfigOverrides := "{\"networking\":{\"networkType\":\"OVNKubernetes\"},\"controlPlane\":{\"hyperthreading\":\"Disabled\"}}"
+	expectedValues := map[string]string{
+		"baseDomain":             "",
+		"clusterImageSetNameRef": "",
+		"clusterName":            "",
+		"clusterNamespace":       "test-cluster",
+		"clusterType":            "",
+	}
 
 	testcases := []struct {
 		clusterInstance *v1alpha1.ClusterInstance
@@ -173,6 +219,7 @@ func Test_buildClusterData(t *testing.T) {
 					ControlPlaneAgents:     1,
 					WorkerAgents:           0,
 				},
+				Values: expectedValues,
 			},
 			error: nil,
 			name:  "single master-node ClusterInstance with nodeId undefined",
@@ -237,6 +284,7 @@ func Test_buildClusterData(t *testing.T) {
 					ControlPlaneAgents:     2,
 					WorkerAgents:           1,
 				},
+				Values: expectedValues,
 			},
 			error: nil,
 			name:  "3 node (2 master, 1 worker) ClusterInstance with nodeId set to first node",
@@ -257,6 +305,207 @@ func Test_buildClusterData(t *testing.T) {
 
 }
 
+func Test_getAgentDiskEncryption(t *testing.T) {
+	tang := []v1alpha1.TangConfig{{URL: "http://198.51.100.1:7500", Thumbprint: "1c3wJKh6TQKTghTjWgS4MlIXtGk"}}
+
+	testcases := []struct {
+		name           string
+		diskEncryption *v1alpha1.DiskEncryption
+		expected       *AgentDiskEncryption
+	}{
+		{
+			name: "disk encryption unset",
+		},
+		{
+			name:           "disk encryption disabled",
+			diskEncryption: &v1alpha1.DiskEncryption{Type: v1alpha1.DiskEncryptionTypeNone},
+		},
+		{
+			name:           "TPM on all the nodes by default",
+			diskEncryption: &v1alpha1.DiskEncryption{Type: v1alpha1.DiskEncryptionTypeTPM2},
+			expected:       &AgentDiskEncryption{EnableOn: "all", Mode: "tpmv2"},
+		},
+		{
+			name: "Tang on the control plane nodes",
+			diskEncryption: &v1alpha1.DiskEncryption{
+				Type:     v1alpha1.DiskEncryptionTypeTang,
+				EnableOn: "masters",
+				Tang:     tang,
+			},
+			expected: &AgentDiskEncryption{
+				EnableOn:    "masters",
+				Mode:        "tang",
+				TangServers: `[{"url":"http://198.51.100.1:7500","thumbprint":"1c3wJKh6TQKTghTjWgS4MlIXtGk"}]`,
+			},
+		},
+		{
+			name:           "NBDE alias of Tang",
+			diskEncryption: &v1alpha1.DiskEncryption{Type: v1alpha1.DiskEncryptionTypeNBDE, Tang: tang},
+			expected: &AgentDiskEncryption{
+				EnableOn:    "all",
+				Mode:        "tang",
+				TangServers: `[{"url":"http://198.51.100.1:7500","thumbprint":"1c3wJKh6TQKTghTjWgS4MlIXtGk"}]`,
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			clusterInstance := &v1alpha1.ClusterInstance{
+				Spec: v1alpha1.ClusterInstanceSpec{DiskEncryption: tc.diskEncryption},
+			}
+			actual, err := getAgentDiskEncryption(clusterInstance)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, actual)
+
+			data, err := buildClusterData(clusterInstance, nil)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, data.SpecialVars.DiskEncryption)
+		})
+	}
+}
+
+func Test_getRootFSURL(t *testing.T) {
+	testcases := []struct {
+		name     string
+		baseURL  string
+		expected string
+	}{
+		{name: "unset"},
+		{
+			name:     "base URL",
+			baseURL:  "https://images.example.com/boot-artifacts",
+			expected: "https://images.example.com/boot-artifacts/rootfs.img",
+		},
+		{
+			name:     "base URL with a trailing slash",
+			baseURL:  "http://198.51.100.1:8080/",
+			expected: "http://198.51.100.1:8080/rootfs.img",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			clusterInstance := &v1alpha1.ClusterInstance{
+				Spec: v1alpha1.ClusterInstanceSpec{BootArtifactsBaseURL: tc.baseURL},
+			}
+			actual, err := getRootFSURL(clusterInstance)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func Test_InfraEnvName(t *testing.T) {
+	clusterInstance := &v1alpha1.ClusterInstance{
+		Spec: v1alpha1.ClusterInstanceSpec{ClusterName: "site-sno-du-1"},
+	}
+	assert.Equal(t, "site-sno-du-1", InfraEnvName(clusterInstance))
+
+	clusterInstance.Spec.InfraEnvRef = &corev1.LocalObjectReference{Name: "shared-infraenv"}
+	assert.Equal(t, "shared-infraenv", InfraEnvName(clusterInstance))
+}
+
+func Test_getExpandedNoProxy(t *testing.T) {
+	newClusterInstance := func(proxy *aiv1beta1.Proxy) *v1alpha1.ClusterInstance {
+		return &v1alpha1.ClusterInstance{
+			Spec: v1alpha1.ClusterInstanceSpec{
+				ClusterName:    "site-sno-du-1",
+				BaseDomain:     "example.com",
+				MachineNetwork: []v1alpha1.MachineNetworkEntry{{CIDR: "10.16.231.0/24"}},
+				ClusterNetwork: []v1alpha1.ClusterNetworkEntry{{CIDR: "10.128.0.0/14", HostPrefix: 23}},
+				ServiceNetwork: []v1alpha1.ServiceNetworkEntry{{CIDR: "172.30.0.0/16"}},
+				Proxy:          proxy,
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		proxy   *aiv1beta1.Proxy
+		disable bool
+		want    string
+	}{
+		{
+			name: "no proxy configured",
+			want: "",
+		},
+		{
+			name:  "appends the networks and the cluster domain after the user entries",
+			proxy: &aiv1beta1.Proxy{HTTPProxy: "http://proxy.example.com:3128", NoProxy: "registry.example.com"},
+			want:  "registry.example.com,10.16.231.0/24,10.128.0.0/14,172.30.0.0/16,.site-sno-du-1.example.com",
+		},
+		{
+			name: "drops the duplicate and empty entries",
+			proxy: &aiv1beta1.Proxy{
+				HTTPSProxy: "http://proxy.example.com:3128",
+				NoProxy:    " 172.30.0.0/16, ,.site-sno-du-1.example.com",
+			},
+			want: "172.30.0.0/16,.site-sno-du-1.example.com,10.16.231.0/24,10.128.0.0/14",
+		},
+		{
+			name:    "keeps the noProxy as is when the expansion is disabled",
+			proxy:   &aiv1beta1.Proxy{HTTPProxy: "http://proxy.example.com:3128", NoProxy: "registry.example.com"},
+			disable: true,
+			want:    "registry.example.com",
+		},
+		{
+			name:  "keeps the noProxy as is when no proxy URL is set",
+			proxy: &aiv1beta1.Proxy{NoProxy: "registry.example.com"},
+			want:  "registry.example.com",
+		},
+		{
+			name:  "keeps a noProxy bypassing the proxy for all destinations",
+			proxy: &aiv1beta1.Proxy{HTTPProxy: "http://proxy.example.com:3128", NoProxy: "*"},
+			want:  "*",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clusterInstance := newClusterInstance(tt.proxy)
+			clusterInstance.Spec.DisableNoProxyExpansion = tt.disable
+			assert.Equal(t, tt.want, getExpandedNoProxy(clusterInstance))
+		})
+	}
+
+	// The expanded noProxy is rendered, while the ClusterInstance is left untouched
+	clusterInstance := newClusterInstance(&aiv1beta1.Proxy{HTTPProxy: "http://proxy.example.com:3128"})
+	data, err := buildClusterData(clusterInstance, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "10.16.231.0/24,10.128.0.0/14,172.30.0.0/16,.site-sno-du-1.example.com", data.Spec.Proxy.NoProxy)
+	assert.Equal(t, "", clusterInstance.Spec.Proxy.NoProxy)
+}
+
+func Test_getTemplateValues(t *testing.T) {
+	clusterInstance := &v1alpha1.ClusterInstance{
+		ObjectMeta: metav1.ObjectMeta{Name: "site-sno-du-1", Namespace: "site-sno-du-1"},
+		Spec: v1alpha1.ClusterInstanceSpec{
+			ClusterName:            "site-sno-du-1",
+			BaseDomain:             "example.com",
+			ClusterType:            v1alpha1.ClusterTypeSNO,
+			ClusterImageSetNameRef: "img4.15.0",
+			TemplateValues: map[string]string{
+				"siteId":      "du-1",
+				"clusterName": "overridden",
+			},
+		},
+	}
+
+	expected := map[string]string{
+		"siteId":                 "du-1",
+		"baseDomain":             "example.com",
+		"clusterImageSetNameRef": "img4.15.0",
+		"clusterName":            "site-sno-du-1",
+		"clusterNamespace":       "site-sno-du-1",
+		"clusterType":            "SNO",
+	}
+	assert.Equal(t, expected, getTemplateValues(clusterInstance))
+
+	data, err := buildClusterData(clusterInstance, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, data.Values)
+}
+
 func Test_suppressManifest(t *testing.T) {
 	type args struct {
 		kind                string
@@ -378,6 +627,38 @@ func Test_appendManifestAnnotations(t *testing.T) {
 		})
 	}
 }
+func Test_appendManifestLabels(t *testing.T) {
+	manifest := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{
+				"team": "core",
+			},
+		},
+	}
+	want := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{
+				"team":        "core",
+				"cost-center": "cc-42",
+			},
+		},
+	}
+	got := appendManifestLabels(map[string]string{"team": "ran", "cost-center": "cc-42"}, manifest)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("appendManifestLabels() = %v, want %v", got, want)
+	}
+
+	got = appendManifestLabels(map[string]string{"team": "ran"}, map[string]interface{}{})
+	want = map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{"team": "ran"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("appendManifestLabels() = %v, want %v", got, want)
+	}
+}
+
 func Test_mergeJSONCommonKey(t *testing.T) {
 	type args struct {
 		mergeWith string
@@ -458,3 +739,77 @@ func Test_mergeJSONCommonKey(t *testing.T) {
 		})
 	}
 }
+
+func Test_buildClusterData_inlineBmcCredentials(t *testing.T) {
+	clusterInstance := GetMockSNOClusterInstance(&TestParams{
+		ClusterName: "test-cluster", ClusterNamespace: "test-cluster", PullSecret: "pull-secret"})
+	clusterInstance.Spec.AllowInlineBmcCredentials = true
+	node := &clusterInstance.Spec.Nodes[0]
+	node.BmcCredentialsName = v1alpha1.BmcCredentialsName{}
+	node.BmcCredentials = &v1alpha1.InlineBmcCredentials{Username: "admin", Password: "password"}
+
+	data, err := buildClusterData(clusterInstance, node)
+	assert.Nil(t, err)
+
+	// The templates reference the secret created from the inline credentials, not the credentials themselves
+	secretName := node.InlineBmcCredentialsSecretName()
+	assert.Equal(t, secretName, data.SpecialVars.CurrentNode.BmcCredentialsName.Name)
+	assert.Nil(t, data.SpecialVars.CurrentNode.BmcCredentials)
+	assert.Equal(t, secretName, data.Spec.Nodes[0].BmcCredentialsName.Name)
+	assert.Nil(t, data.Spec.Nodes[0].BmcCredentials)
+
+	// The ClusterInstance itself is left untouched
+	assert.NotNil(t, node.BmcCredentials)
+	assert.Empty(t, node.BmcCredentialsName.Name)
+}
+
+func Test_buildClusterData_chronyConfig(t *testing.T) {
+	clusterInstance := GetMockSNOClusterInstance(&TestParams{
+		ClusterName: "test-cluster", ClusterNamespace: "test-cluster", PullSecret: "pull-secret",
+		ExtraManifestName: "extra-manifests"})
+	clusterInstance.Spec.ChronyConfig = &v1alpha1.ChronyConfig{Servers: []string{"192.0.2.1"}}
+
+	data, err := buildClusterData(clusterInstance, nil)
+	assert.Nil(t, err)
+
+	// The chrony ConfigMap is included in the installation manifests
+	assert.Equal(t, []corev1.LocalObjectReference{{Name: "extra-manifests"}, {Name: "test-cluster-chrony-config"}},
+		data.Spec.ExtraManifestsRefs)
+	assert.Equal(t, []corev1.LocalObjectReference{{Name: "extra-manifests"}}, clusterInstance.Spec.ExtraManifestsRefs)
+}
+
+func Test_buildClusterData_imageDigestSources(t *testing.T) {
+	clusterInstance := GetMockSNOClusterInstance(&TestParams{
+		ClusterName: "test-cluster", ClusterNamespace: "test-cluster", PullSecret: "pull-secret",
+		ExtraManifestName: "extra-manifests"})
+	clusterInstance.Spec.ImageDigestSources = []v1alpha1.ImageDigestSource{{
+		Source:  "quay.io/openshift-release-dev/ocp-release",
+		Mirrors: []string{"mirror.example.com/ocp/release"},
+	}}
+
+	data, err := buildClusterData(clusterInstance, nil)
+	assert.Nil(t, err)
+
+	// The ImageDigestMirrorSet ConfigMap is included in the installation manifests
+	assert.Equal(t, []corev1.LocalObjectReference{{Name: "extra-manifests"}, {Name: "test-cluster-image-digest-sources"}},
+		data.Spec.ExtraManifestsRefs)
+	assert.Contains(t, data.SpecialVars.InstallConfigOverrides, `"imageDigestSources":[{"mirrors":`+
+		`["mirror.example.com/ocp/release"],"source":"quay.io/openshift-release-dev/ocp-release"}]`)
+}
+
+func Test_buildClusterData_installOperators(t *testing.T) {
+	clusterInstance := GetMockSNOClusterInstance(&TestParams{
+		ClusterName: "test-cluster", ClusterNamespace: "test-cluster", PullSecret: "pull-secret",
+		ExtraManifestName: "extra-manifests"})
+	clusterInstance.Spec.InstallOperators = []v1alpha1.OperatorSpec{{
+		Name: "local-storage-operator", Namespace: "openshift-local-storage", Channel: "stable",
+		Source: "redhat-operators",
+	}}
+
+	data, err := buildClusterData(clusterInstance, nil)
+	assert.Nil(t, err)
+
+	// The operator Subscriptions ConfigMap is included in the installation manifests
+	assert.Equal(t, []corev1.LocalObjectReference{{Name: "extra-manifests"}, {Name: "test-cluster-install-operators"}},
+		data.Spec.ExtraManifestsRefs)
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	k8syaml "sigs.k8s.io/yaml"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+)
+
+const (
+	// imageDigestSourcesSyncWave is the sync-wave of the ConfigMap holding the ImageDigestMirrorSet, it is applied
+	// before the installation manifests referencing it
+	imageDigestSourcesSyncWave = "0"
+	// imageDigestMirrorSetName is the name of the ImageDigestMirrorSet rendered from the ImageDigestSources
+	imageDigestMirrorSetName = "siteconfig-image-digest-sources"
+	// imageDigestSourcesKey is the install-config key of the ImageDigestSources
+	imageDigestSourcesKey = "imageDigestSources"
+)
+
+// imageRepositoryRegex matches a repository of an ImageDigestSource, i.e. a registry host with an optional port followed by
+// optional path components, without a tag nor a digest
+var imageRepositoryRegex = regexp.MustCompile(`^(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])` +
+	`(?:\.(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9]))*(?::[0-9]+)?` +
+	`(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+
+// ImageDigestSourcesConfigMapName returns the name of the ConfigMap holding the ImageDigestMirrorSet of the cluster, it
+// is added to the ExtraManifestsRefs of the rendering context
+func ImageDigestSourcesConfigMapName(clusterInstance *v1alpha1.ClusterInstance) string {
+	return clusterInstance.Spec.ClusterName + "-image-digest-sources"
+}
+
+// imageDigestMirrors returns the ImageDigestSources as a list of source and mirrors, as found in the install-config
+// and in the ImageDigestMirrorSet
+func imageDigestMirrors(sources []v1alpha1.ImageDigestSource) []interface{} {
+	mirrors := make([]interface{}, 0, len(sources))
+	for _, source := range sources {
+		sourceMirrors := make([]interface{}, 0, len(source.Mirrors))
+		for _, mirror := range source.Mirrors {
+			sourceMirrors = append(sourceMirrors, mirror)
+		}
+		mirrors = append(mirrors, map[string]interface{}{"source": source.Source, "mirrors": sourceMirrors})
+	}
+	return mirrors
+}
+
+// getImageDigestSourcesInstallConfigOverrides adds the ImageDigestSources to the install config overrides
+func getImageDigestSourcesInstallConfigOverrides(
+	clusterInstance *v1alpha1.ClusterInstance,
+	installConfigOverrides string,
+) (string, error) {
+	if len(clusterInstance.Spec.ImageDigestSources) == 0 {
+		return installConfigOverrides, nil
+	}
+
+	installOverrideValues := map[string]interface{}{}
+	if installConfigOverrides != "" {
+		if err := json.Unmarshal([]byte(installConfigOverrides), &installOverrideValues); err != nil {
+			return installConfigOverrides, err
+		}
+	}
+
+	// The explicit spec field takes precedence over imageDigestSources set in the installConfigOverrides
+	installOverrideValues[imageDigestSourcesKey] = imageDigestMirrors(clusterInstance.Spec.ImageDigestSources)
+
+	byteData, err := json.Marshal(installOverrideValues)
+	if err != nil {
+		return installConfigOverrides, err
+	}
+	return string(byteData), nil
+}
+
+// renderImageDigestMirrorSet returns the manifest of the ConfigMap holding the ImageDigestMirrorSet of the cluster, to
+// be included in the installation manifests. The ConfigMap is annotated and labeled like the manifests rendered from
+// the cluster-level templates.
+func renderImageDigestMirrorSet(clusterInstance *v1alpha1.ClusterInstance) ([]interface{}, error) {
+	if len(clusterInstance.Spec.ImageDigestSources) == 0 {
+		return nil, nil
+	}
+
+	imageDigestMirrorSet, err := k8syaml.Marshal(map[string]interface{}{
+		"apiVersion": "config.openshift.io/v1",
+		"kind":       "ImageDigestMirrorSet",
+		"metadata":   map[string]interface{}{"name": imageDigestMirrorSetName},
+		"spec": map[string]interface{}{
+			"imageDigestMirrors": imageDigestMirrors(clusterInstance.Spec.ImageDigestSources),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize the ImageDigestMirrorSet: %w", err)
+	}
+
+	manifest := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":        ImageDigestSourcesConfigMapName(clusterInstance),
+			"namespace":   clusterInstance.Spec.ClusterName,
+			"annotations": map[string]interface{}{WaveAnnotation: imageDigestSourcesSyncWave},
+		},
+		"data": map[string]interface{}{imageDigestMirrorSetName + ".yaml": string(imageDigestMirrorSet)},
+	}
+	extraAnnotations, _ := clusterInstance.Spec.ExtraAnnotationSearch("ConfigMap")
+	manifest = appendManifestAnnotations(extraAnnotations, manifest)
+	manifest = appendManifestLabels(clusterInstance.Spec.ChargebackMetadata, manifest)
+	return []interface{}{manifest}, nil
+}
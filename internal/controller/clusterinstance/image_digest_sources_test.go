@@ -0,0 +1,76 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	k8syaml "sigs.k8s.io/yaml"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+)
+
+func Test_renderImageDigestMirrorSet(t *testing.T) {
+	clusterInstance := &v1alpha1.ClusterInstance{
+		Spec: v1alpha1.ClusterInstanceSpec{
+			ClusterName:        "site-sno-du-1",
+			ChargebackMetadata: map[string]string{"team": "ran"},
+			ExtraAnnotations:   map[string]map[string]string{"ConfigMap": {"foo": "bar"}},
+		},
+	}
+
+	manifests, err := renderImageDigestMirrorSet(clusterInstance)
+	assert.Nil(t, err)
+	assert.Empty(t, manifests)
+
+	clusterInstance.Spec.ImageDigestSources = []v1alpha1.ImageDigestSource{{
+		Source:  "quay.io/openshift-release-dev/ocp-release",
+		Mirrors: []string{"mirror.example.com:5000/ocp/release", "localhost/ocp-release"},
+	}}
+	manifests, err = renderImageDigestMirrorSet(clusterInstance)
+	assert.Nil(t, err)
+	assert.Len(t, manifests, 1)
+
+	manifest := manifests[0].(map[string]interface{})
+	assert.Equal(t, "ConfigMap", manifest["kind"])
+	metadata := manifest["metadata"].(map[string]interface{})
+	assert.Equal(t, "site-sno-du-1-image-digest-sources", metadata["name"])
+	assert.Equal(t, "site-sno-du-1", metadata["namespace"])
+	assert.Equal(t, map[string]interface{}{WaveAnnotation: imageDigestSourcesSyncWave, "foo": "bar"},
+		metadata["annotations"])
+	assert.Equal(t, map[string]interface{}{"team": "ran"}, metadata["labels"])
+
+	data := manifest["data"].(map[string]interface{})
+	content, ok := data[imageDigestMirrorSetName+".yaml"].(string)
+	assert.True(t, ok)
+	assert.Equal(t, `apiVersion: config.openshift.io/v1
+kind: ImageDigestMirrorSet
+metadata:
+  name: siteconfig-image-digest-sources
+spec:
+  imageDigestMirrors:
+  - mirrors:
+    - mirror.example.com:5000/ocp/release
+    - localhost/ocp-release
+    source: quay.io/openshift-release-dev/ocp-release
+`, content)
+
+	imageDigestMirrorSet := map[string]interface{}{}
+	assert.Nil(t, k8syaml.Unmarshal([]byte(content), &imageDigestMirrorSet))
+	assert.Equal(t, "ImageDigestMirrorSet", imageDigestMirrorSet["kind"])
+}
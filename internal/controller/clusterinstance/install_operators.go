@@ -0,0 +1,115 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"fmt"
+
+	k8syaml "sigs.k8s.io/yaml"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+)
+
+const (
+	// installOperatorsSyncWave is the sync-wave of the ConfigMap holding the operator Subscriptions, it is applied
+	// before the installation manifests referencing it
+	installOperatorsSyncWave = "0"
+	// defaultCatalogSourceNamespace is the namespace of the CatalogSource when the OperatorSpec does not set it
+	defaultCatalogSourceNamespace = "openshift-marketplace"
+	// globalOperatorsNamespace is the namespace of the operators watching all the namespaces, it already holds an
+	// OperatorGroup
+	globalOperatorsNamespace = "openshift-operators"
+)
+
+// InstallOperatorsConfigMapName returns the name of the ConfigMap holding the operator Subscriptions of the cluster, it
+// is added to the ExtraManifestsRefs of the rendering context
+func InstallOperatorsConfigMapName(clusterInstance *v1alpha1.ClusterInstance) string {
+	return clusterInstance.Spec.ClusterName + "-install-operators"
+}
+
+// installOperatorsManifests returns the Namespace, OperatorGroup and Subscription manifests of the operators, keyed by
+// their file name. The Namespace and OperatorGroup are rendered once per namespace, except for the namespace of the
+// global operators which already exists with its OperatorGroup.
+func installOperatorsManifests(operators []v1alpha1.OperatorSpec) map[string]interface{} {
+	manifests := map[string]interface{}{}
+	for _, operator := range operators {
+		if operator.Namespace != globalOperatorsNamespace {
+			manifests[operator.Namespace+"-namespace.yaml"] = map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Namespace",
+				"metadata":   map[string]interface{}{"name": operator.Namespace},
+			}
+			manifests[operator.Namespace+"-operatorgroup.yaml"] = map[string]interface{}{
+				"apiVersion": "operators.coreos.com/v1",
+				"kind":       "OperatorGroup",
+				"metadata":   map[string]interface{}{"name": operator.Namespace, "namespace": operator.Namespace},
+				"spec":       map[string]interface{}{"targetNamespaces": []interface{}{operator.Namespace}},
+			}
+		}
+
+		sourceNamespace := operator.SourceNamespace
+		if sourceNamespace == "" {
+			sourceNamespace = defaultCatalogSourceNamespace
+		}
+		manifests[operator.Name+"-subscription.yaml"] = map[string]interface{}{
+			"apiVersion": "operators.coreos.com/v1alpha1",
+			"kind":       "Subscription",
+			"metadata":   map[string]interface{}{"name": operator.Name, "namespace": operator.Namespace},
+			"spec": map[string]interface{}{
+				"name":                operator.Name,
+				"channel":             operator.Channel,
+				"source":              operator.Source,
+				"sourceNamespace":     sourceNamespace,
+				"installPlanApproval": "Automatic",
+			},
+		}
+	}
+	return manifests
+}
+
+// renderInstallOperators returns the manifest of the ConfigMap holding the Namespaces, OperatorGroups and Subscriptions
+// of the operators installed with the cluster, to be included in the installation manifests. The ConfigMap is
+// annotated and labeled like the manifests rendered from the cluster-level templates.
+func renderInstallOperators(clusterInstance *v1alpha1.ClusterInstance) ([]interface{}, error) {
+	if len(clusterInstance.Spec.InstallOperators) == 0 {
+		return nil, nil
+	}
+
+	data := map[string]interface{}{}
+	for name, manifest := range installOperatorsManifests(clusterInstance.Spec.InstallOperators) {
+		content, err := k8syaml.Marshal(manifest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize the operator manifest %s: %w", name, err)
+		}
+		data[name] = string(content)
+	}
+
+	manifest := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":        InstallOperatorsConfigMapName(clusterInstance),
+			"namespace":   clusterInstance.Spec.ClusterName,
+			"annotations": map[string]interface{}{WaveAnnotation: installOperatorsSyncWave},
+		},
+		"data": data,
+	}
+	extraAnnotations, _ := clusterInstance.Spec.ExtraAnnotationSearch("ConfigMap")
+	manifest = appendManifestAnnotations(extraAnnotations, manifest)
+	manifest = appendManifestLabels(clusterInstance.Spec.ChargebackMetadata, manifest)
+	return []interface{}{manifest}, nil
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+)
+
+func Test_renderInstallOperators(t *testing.T) {
+	clusterInstance := &v1alpha1.ClusterInstance{
+		Spec: v1alpha1.ClusterInstanceSpec{
+			ClusterName:        "site-sno-du-1",
+			ChargebackMetadata: map[string]string{"team": "ran"},
+			ExtraAnnotations:   map[string]map[string]string{"ConfigMap": {"foo": "bar"}},
+		},
+	}
+
+	manifests, err := renderInstallOperators(clusterInstance)
+	assert.Nil(t, err)
+	assert.Empty(t, manifests)
+
+	clusterInstance.Spec.InstallOperators = []v1alpha1.OperatorSpec{
+		{Name: "local-storage-operator", Namespace: "openshift-local-storage", Channel: "stable",
+			Source: "redhat-operators"},
+		{Name: "lvms-operator", Namespace: "openshift-local-storage", Channel: "stable-4.16",
+			Source: "redhat-operators-disconnected", SourceNamespace: "openshift-marketplace-mirror"},
+		{Name: "cluster-logging", Namespace: "openshift-operators", Channel: "stable-6.0",
+			Source: "redhat-operators"},
+	}
+	manifests, err = renderInstallOperators(clusterInstance)
+	assert.Nil(t, err)
+	assert.Len(t, manifests, 1)
+
+	manifest := manifests[0].(map[string]interface{})
+	assert.Equal(t, "ConfigMap", manifest["kind"])
+	metadata := manifest["metadata"].(map[string]interface{})
+	assert.Equal(t, "site-sno-du-1-install-operators", metadata["name"])
+	assert.Equal(t, "site-sno-du-1", metadata["namespace"])
+	assert.Equal(t, map[string]interface{}{WaveAnnotation: installOperatorsSyncWave, "foo": "bar"},
+		metadata["annotations"])
+	assert.Equal(t, map[string]interface{}{"team": "ran"}, metadata["labels"])
+
+	// The Namespace and OperatorGroup are rendered once per namespace, but not for the global operators namespace
+	assert.Equal(t, map[string]interface{}{
+		"openshift-local-storage-namespace.yaml": `apiVersion: v1
+kind: Namespace
+metadata:
+  name: openshift-local-storage
+`,
+		"openshift-local-storage-operatorgroup.yaml": `apiVersion: operators.coreos.com/v1
+kind: OperatorGroup
+metadata:
+  name: openshift-local-storage
+  namespace: openshift-local-storage
+spec:
+  targetNamespaces:
+  - openshift-local-storage
+`,
+		"local-storage-operator-subscription.yaml": `apiVersion: operators.coreos.com/v1alpha1
+kind: Subscription
+metadata:
+  name: local-storage-operator
+  namespace: openshift-local-storage
+spec:
+  channel: stable
+  installPlanApproval: Automatic
+  name: local-storage-operator
+  source: redhat-operators
+  sourceNamespace: openshift-marketplace
+`,
+		"lvms-operator-subscription.yaml": `apiVersion: operators.coreos.com/v1alpha1
+kind: Subscription
+metadata:
+  name: lvms-operator
+  namespace: openshift-local-storage
+spec:
+  channel: stable-4.16
+  installPlanApproval: Automatic
+  name: lvms-operator
+  source: redhat-operators-disconnected
+  sourceNamespace: openshift-marketplace-mirror
+`,
+		"cluster-logging-subscription.yaml": `apiVersion: operators.coreos.com/v1alpha1
+kind: Subscription
+metadata:
+  name: cluster-logging
+  namespace: openshift-operators
+spec:
+  channel: stable-6.0
+  installPlanApproval: Automatic
+  name: cluster-logging
+  source: redhat-operators
+  sourceNamespace: openshift-marketplace
+`,
+	}, manifest["data"])
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"fmt"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+)
+
+const (
+	// MachinePoolKind is the kind of the Hive MachinePools rendered for the machine pools of the ClusterInstance
+	MachinePoolKind = "MachinePool"
+	// machinePoolSyncWave is the sync-wave of the MachinePools, they are applied after the ClusterDeployment they
+	// reference
+	machinePoolSyncWave = "2"
+)
+
+// MachinePoolName returns the name of the Hive MachinePool of the given machine pool, Hive requires it to be the name
+// of the ClusterDeployment followed by the name of the pool
+func MachinePoolName(clusterInstance *v1alpha1.ClusterInstance, machinePool *v1alpha1.MachinePoolSpec) string {
+	return fmt.Sprintf("%s-%s", clusterInstance.Spec.ClusterName, machinePool.Name)
+}
+
+// renderMachinePools returns the manifests of the Hive MachinePools of the machine pools of the ClusterInstance. They
+// reference the ClusterDeployment rendered from the templates, named and namespaced after the cluster. The
+// MachinePools are suppressed, annotated and labeled like the manifests rendered from the cluster-level templates.
+func renderMachinePools(clusterInstance *v1alpha1.ClusterInstance) ([]interface{}, error) {
+	if len(clusterInstance.Spec.MachinePools) == 0 ||
+		suppressManifest(MachinePoolKind, clusterInstance.Spec.SuppressedManifests) {
+		return nil, nil
+	}
+
+	extraAnnotations, _ := clusterInstance.Spec.ExtraAnnotationSearch(MachinePoolKind)
+	manifests := make([]interface{}, 0, len(clusterInstance.Spec.MachinePools))
+	for i := range clusterInstance.Spec.MachinePools {
+		machinePool := &clusterInstance.Spec.MachinePools[i]
+		obj := &hivev1.MachinePool{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: hivev1.SchemeGroupVersion.String(),
+				Kind:       MachinePoolKind,
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        MachinePoolName(clusterInstance, machinePool),
+				Namespace:   clusterInstance.Spec.ClusterName,
+				Annotations: map[string]string{WaveAnnotation: machinePoolSyncWave},
+			},
+			Spec: hivev1.MachinePoolSpec{
+				ClusterDeploymentRef: corev1.LocalObjectReference{Name: clusterInstance.Spec.ClusterName},
+				Name:                 machinePool.Name,
+				Replicas:             machinePool.Replicas,
+				Labels:               machinePool.Labels,
+				Taints:               machinePool.Taints,
+			},
+		}
+
+		manifest, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert MachinePool %s: %w", obj.Name, err)
+		}
+		// Leave the status and the server-populated metadata to the API server
+		delete(manifest, "status")
+		if metadata, ok := manifest["metadata"].(map[string]interface{}); ok {
+			delete(metadata, "creationTimestamp")
+		}
+
+		manifest = appendManifestAnnotations(extraAnnotations, manifest)
+		manifest = appendManifestLabels(clusterInstance.Spec.ChargebackMetadata, manifest)
+		manifests = append(manifests, manifest)
+	}
+	return manifests, nil
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+)
+
+func Test_renderMachinePools(t *testing.T) {
+	replicas := int64(2)
+	clusterInstance := &v1alpha1.ClusterInstance{
+		Spec: v1alpha1.ClusterInstanceSpec{
+			ClusterName:        "site-sno-du-1",
+			ChargebackMetadata: map[string]string{"team": "ran"},
+			ExtraAnnotations:   map[string]map[string]string{MachinePoolKind: {"foo": "bar"}},
+			MachinePools: []v1alpha1.MachinePoolSpec{
+				{
+					Name:     "worker",
+					Replicas: &replicas,
+					Labels:   map[string]string{"node-role.kubernetes.io/worker": ""},
+					Taints: []corev1.Taint{
+						{Key: "dedicated", Value: "ran", Effect: corev1.TaintEffectNoSchedule},
+					},
+				},
+				{Name: "infra"},
+			},
+		},
+	}
+
+	manifests, err := renderMachinePools(clusterInstance)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"apiVersion": "hive.openshift.io/v1",
+			"kind":       "MachinePool",
+			"metadata": map[string]interface{}{
+				"name":        "site-sno-du-1-worker",
+				"namespace":   "site-sno-du-1",
+				"annotations": map[string]interface{}{WaveAnnotation: "2", "foo": "bar"},
+				"labels":      map[string]interface{}{"team": "ran"},
+			},
+			"spec": map[string]interface{}{
+				"clusterDeploymentRef": map[string]interface{}{"name": "site-sno-du-1"},
+				"name":                 "worker",
+				"replicas":             int64(2),
+				"labels":               map[string]interface{}{"node-role.kubernetes.io/worker": ""},
+				"taints": []interface{}{
+					map[string]interface{}{"key": "dedicated", "value": "ran", "effect": "NoSchedule"},
+				},
+				"platform": map[string]interface{}{},
+			},
+		},
+		map[string]interface{}{
+			"apiVersion": "hive.openshift.io/v1",
+			"kind":       "MachinePool",
+			"metadata": map[string]interface{}{
+				"name":        "site-sno-du-1-infra",
+				"namespace":   "site-sno-du-1",
+				"annotations": map[string]interface{}{WaveAnnotation: "2", "foo": "bar"},
+				"labels":      map[string]interface{}{"team": "ran"},
+			},
+			"spec": map[string]interface{}{
+				"clusterDeploymentRef": map[string]interface{}{"name": "site-sno-du-1"},
+				"name":                 "infra",
+				"platform":             map[string]interface{}{},
+			},
+		},
+	}, manifests)
+
+	// The MachinePools are not rendered when suppressed
+	clusterInstance.Spec.SuppressedManifests = []string{MachinePoolKind}
+	manifests, err = renderMachinePools(clusterInstance)
+	assert.Nil(t, err)
+	assert.Empty(t, manifests)
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	k8syaml "sigs.k8s.io/yaml"
+)
+
+// secretDataFields are the fields of a Secret manifest holding its (possibly sensitive) values
+var secretDataFields = []string{"data", "stringData"}
+
+// redactManifest returns the manifest to dump, with the values of a Secret masked. The given manifest is not modified.
+func redactManifest(manifest map[string]interface{}) map[string]interface{} {
+	if manifest["kind"] != "Secret" {
+		return manifest
+	}
+
+	redacted := make(map[string]interface{}, len(manifest))
+	for key, value := range manifest {
+		redacted[key] = value
+	}
+	for _, field := range secretDataFields {
+		values, ok := manifest[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		masked := make(map[string]interface{}, len(values))
+		for key := range values {
+			masked[key] = redactedValue
+		}
+		redacted[field] = masked
+	}
+	return redacted
+}
+
+// ManifestsDumpPath returns the directory holding the dumped manifests of the ClusterInstance, i.e.
+// <dir>/<namespace>/<name>
+func ManifestsDumpPath(dir string, clusterInstance *v1alpha1.ClusterInstance) string {
+	return filepath.Join(dir, clusterInstance.Namespace, clusterInstance.Name)
+}
+
+// DumpManifests writes each of the rendered manifests, grouped by sync-wave, to the <wave>_<kind>_<name>.yaml file of
+// the ManifestsDumpPath of the ClusterInstance, overwriting the file of a previous rendering. The values of the
+// Secrets are redacted.
+func DumpManifests(dir string, clusterInstance *v1alpha1.ClusterInstance, manifestGroups map[int][]interface{}) error {
+	path := ManifestsDumpPath(dir, clusterInstance)
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return fmt.Errorf("failed to create the manifests dump directory %s: %w", path, err)
+	}
+
+	for syncWave, group := range manifestGroups {
+		for _, item := range group {
+			manifest, ok := item.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("manifest should be of type 'map[string]interface{}'")
+			}
+			kind, _ := manifest["kind"].(string)
+			metadata, _ := manifest["metadata"].(map[string]interface{})
+			name, _ := metadata["name"].(string)
+			if kind == "" || name == "" {
+				return fmt.Errorf("missing kind or name in rendered manifest")
+			}
+
+			out, err := k8syaml.Marshal(redactManifest(manifest))
+			if err != nil {
+				return fmt.Errorf("failed to serialize %s %s: %w", kind, name, err)
+			}
+			file := filepath.Join(path, fmt.Sprintf("%d_%s_%s.yaml", syncWave, kind, name))
+			if err := os.WriteFile(file, out, 0o644); err != nil {
+				return fmt.Errorf("failed to write the manifest dump %s: %w", file, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+)
+
+func TestDumpManifests(t *testing.T) {
+	dir := t.TempDir()
+	clusterInstance := &v1alpha1.ClusterInstance{
+		ObjectMeta: metav1.ObjectMeta{Name: "site-sno-du-1", Namespace: "site-sno-du-1"},
+	}
+	secret := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "bmc-secret", "namespace": "site-sno-du-1"},
+		"data":       map[string]interface{}{"password": "c2VjcmV0"},
+		"stringData": map[string]interface{}{"username": "admin"},
+	}
+	manifestGroups := map[int][]interface{}{
+		0: {secret},
+		1: {map[string]interface{}{
+			"apiVersion": "hive.openshift.io/v1",
+			"kind":       "ClusterDeployment",
+			"metadata":   map[string]interface{}{"name": "site-sno-du-1", "namespace": "site-sno-du-1"},
+		}},
+	}
+
+	assert.NoError(t, DumpManifests(dir, clusterInstance, manifestGroups))
+
+	path := filepath.Join(dir, "site-sno-du-1", "site-sno-du-1")
+	assert.Equal(t, path, ManifestsDumpPath(dir, clusterInstance))
+	out, err := os.ReadFile(filepath.Join(path, "1_ClusterDeployment_site-sno-du-1.yaml"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "kind: ClusterDeployment")
+
+	out, err = os.ReadFile(filepath.Join(path, "0_Secret_bmc-secret.yaml"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "password: REDACTED")
+	assert.Contains(t, string(out), "username: REDACTED")
+	assert.NotContains(t, string(out), "c2VjcmV0")
+	assert.NotContains(t, string(out), "admin")
+	// The rendered manifest is not modified
+	assert.Equal(t, "c2VjcmV0", secret["data"].(map[string]interface{})["password"])
+
+	// Manifests missing their name are rejected
+	assert.Error(t, DumpManifests(dir, clusterInstance, map[int][]interface{}{
+		0: {map[string]interface{}{"kind": "Secret"}},
+	}))
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+)
+
+// MissingMetadataKeys returns the required keys that are absent (or empty) in the ChargebackMetadata of the
+// ClusterInstance, in sorted order
+func MissingMetadataKeys(clusterInstance *v1alpha1.ClusterInstance, requiredKeys []string) []string {
+	var missing []string
+	for _, key := range requiredKeys {
+		if clusterInstance.Spec.ChargebackMetadata[key] == "" {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// validateChargebackMetadata checks that the ChargebackMetadata can be applied as labels
+func validateChargebackMetadata(clusterInstance *v1alpha1.ClusterInstance) error {
+	keys := make([]string, 0, len(clusterInstance.Spec.ChargebackMetadata))
+	for key := range clusterInstance.Spec.ChargebackMetadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid chargebackMetadata key %q: %s", key, strings.Join(errs, "; "))
+		}
+		value := clusterInstance.Spec.ChargebackMetadata[key]
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("invalid chargebackMetadata value %q for key %q: %s", value, key, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+)
+
+func Test_MissingMetadataKeys(t *testing.T) {
+	clusterInstance := &v1alpha1.ClusterInstance{
+		Spec: v1alpha1.ClusterInstanceSpec{
+			ChargebackMetadata: map[string]string{"team": "ran", "owner": ""},
+		},
+	}
+
+	assert.Empty(t, MissingMetadataKeys(clusterInstance, nil))
+	assert.Empty(t, MissingMetadataKeys(clusterInstance, []string{"team"}))
+	assert.Equal(t, []string{"cost-center", "owner"},
+		MissingMetadataKeys(clusterInstance, []string{"team", "owner", "cost-center"}))
+}
+
+func Test_validateChargebackMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		wantErr  string
+	}{
+		{
+			name:     "valid metadata",
+			metadata: map[string]string{"team": "ran", "example.com/cost-center": "cc-42"},
+		},
+		{
+			name:     "invalid key",
+			metadata: map[string]string{"cost center": "cc-42"},
+			wantErr:  `invalid chargebackMetadata key "cost center"`,
+		},
+		{
+			name:     "invalid value",
+			metadata: map[string]string{"team": "radio access network"},
+			wantErr:  `invalid chargebackMetadata value "radio access network" for key "team"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clusterInstance := &v1alpha1.ClusterInstance{
+				Spec: v1alpha1.ClusterInstanceSpec{ChargebackMetadata: tt.metadata},
+			}
+			err := validateChargebackMetadata(clusterInstance)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
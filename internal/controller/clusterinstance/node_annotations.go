@@ -0,0 +1,54 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"strings"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+)
+
+// reservedNodeAnnotationPrefixes are the prefixes of the BareMetalHost and Agent annotations managed by the controller,
+// the node templates and the assisted installer, e.g. the sync-wave, the node labels and the ironic inspection
+var reservedNodeAnnotationPrefixes = []string{
+	v1alpha1.Group + "/",
+	"bmac.agent-install.openshift.io",
+	"inspect.metal3.io",
+}
+
+// IsReservedNodeAnnotation returns true if the annotation is managed by the controller and cannot be set by the node
+// annotations
+func IsReservedNodeAnnotation(key string) bool {
+	for _, prefix := range reservedNodeAnnotationPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// NodeAnnotations returns the annotations of the node to set on its BareMetalHost and Agent, the reserved annotations
+// are left out
+func NodeAnnotations(node *v1alpha1.NodeSpec) map[string]string {
+	annotations := map[string]string{}
+	for key, value := range node.Annotations {
+		if !IsReservedNodeAnnotation(key) {
+			annotations[key] = value
+		}
+	}
+	return annotations
+}
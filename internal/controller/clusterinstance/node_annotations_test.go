@@ -0,0 +1,61 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+)
+
+func Test_NodeAnnotations(t *testing.T) {
+	node := &v1alpha1.NodeSpec{
+		Annotations: map[string]string{
+			"example.com/rack":                         "r12",
+			v1alpha1.Group + "/paused":                 "true",
+			"bmac.agent-install.openshift.io/role":     "master",
+			"inspect.metal3.io":                        "disabled",
+			"example.com/inspect.metal3.io-compatible": "yes",
+		},
+	}
+	assert.Equal(t, map[string]string{
+		"example.com/rack":                         "r12",
+		"example.com/inspect.metal3.io-compatible": "yes",
+	}, NodeAnnotations(node))
+
+	assert.Empty(t, NodeAnnotations(&v1alpha1.NodeSpec{}))
+}
+
+func Test_appendManifestAnnotations_nodeAnnotations(t *testing.T) {
+	node := &v1alpha1.NodeSpec{
+		Annotations: map[string]string{"example.com/rack": "r12", "inspect.metal3.io": "disabled"},
+	}
+	manifest := map[string]interface{}{
+		"kind": "BareMetalHost",
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{"inspect.metal3.io": "enabled"},
+		},
+	}
+	assert.Equal(t, map[string]interface{}{
+		"kind": "BareMetalHost",
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{"inspect.metal3.io": "enabled", "example.com/rack": "r12"},
+		},
+	}, appendManifestAnnotations(NodeAnnotations(node), manifest))
+}
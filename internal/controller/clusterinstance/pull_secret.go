@@ -0,0 +1,163 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+)
+
+// dockerConfigJSON is the content of a kubernetes.io/dockerconfigjson secret
+type dockerConfigJSON struct {
+	Auths map[string]interface{} `json:"auths"`
+}
+
+// MergedPullSecretName returns the name of the secret holding the merged pull secrets of the ClusterInstance
+func MergedPullSecretName(clusterInstance *v1alpha1.ClusterInstance) string {
+	return clusterInstance.Name + "-merged-pull-secret"
+}
+
+// EffectivePullSecretName returns the name of the pull secret used for the installation, i.e. the merged pull secret
+// when additional PullSecretRefs are defined, otherwise the PullSecretRef
+func EffectivePullSecretName(clusterInstance *v1alpha1.ClusterInstance) string {
+	if len(clusterInstance.Spec.PullSecretRefs) > 0 {
+		return MergedPullSecretName(clusterInstance)
+	}
+	return clusterInstance.Spec.PullSecretRef.Name
+}
+
+// PullSecretRefs returns the references of all the pull secrets of the ClusterInstance, starting with the PullSecretRef
+func PullSecretRefs(clusterInstance *v1alpha1.ClusterInstance) []corev1.LocalObjectReference {
+	return append([]corev1.LocalObjectReference{clusterInstance.Spec.PullSecretRef},
+		clusterInstance.Spec.PullSecretRefs...)
+}
+
+// MergePullSecrets merges the registry auths of the given pull secrets into a single dockerconfigjson. Identical
+// auths for the same registry are merged, whereas differing auths for the same registry are reported as conflicts,
+// in which case the first auth is kept.
+func MergePullSecrets(secrets []*corev1.Secret) (merged []byte, conflicts []string, err error) {
+	auths := map[string]interface{}{}
+	conflicting := map[string]bool{}
+
+	for _, secret := range secrets {
+		data, ok := secret.Data[corev1.DockerConfigJsonKey]
+		if !ok {
+			return nil, nil, fmt.Errorf("pull secret %s is missing the %s key", secret.Name, corev1.DockerConfigJsonKey)
+		}
+
+		config := dockerConfigJSON{}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse pull secret %s: %w", secret.Name, err)
+		}
+
+		for registry, auth := range config.Auths {
+			existing, found := auths[registry]
+			if !found {
+				auths[registry] = auth
+			} else if !reflect.DeepEqual(existing, auth) {
+				conflicting[registry] = true
+			}
+		}
+	}
+
+	for registry := range conflicting {
+		conflicts = append(conflicts, registry)
+	}
+	sort.Strings(conflicts)
+
+	merged, err = json.Marshal(dockerConfigJSON{Auths: auths})
+	return merged, conflicts, err
+}
+
+// imageRepository returns the repository of the image reference, i.e. without its digest or tag
+func imageRepository(image string) string {
+	repository, _, _ := strings.Cut(image, "@")
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository = repository[:i]
+	}
+	return repository
+}
+
+// matchesRepository returns true if the repository is the source repository or one of its sub-repositories
+func matchesRepository(repository, source string) bool {
+	return repository == source || strings.HasPrefix(repository, strings.TrimSuffix(source, "/")+"/")
+}
+
+// RequiredRegistries returns the sorted registries the pull secret must hold an auth for to install the release image:
+// the registries of the mirrors of the image digest sources matching the release image, or the registry of the
+// release image when none matches. The mirrors of the other repositories are not required, their images may be pulled
+// with other credentials or not at all.
+func RequiredRegistries(releaseImage string, imageDigestSources []v1alpha1.ImageDigestSource) []string {
+	if releaseImage == "" {
+		return nil
+	}
+
+	required := map[string]bool{}
+	for _, source := range imageDigestSources {
+		if !matchesRepository(imageRepository(releaseImage), source.Source) {
+			continue
+		}
+		for _, mirror := range source.Mirrors {
+			required[ImageRegistry(mirror)] = true
+		}
+	}
+	if len(required) == 0 {
+		required[ImageRegistry(releaseImage)] = true
+	}
+
+	registries := make([]string, 0, len(required))
+	for registry := range required {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+	return registries
+}
+
+// MissingRegistries returns the registries the pull secret does not hold an auth for. An auth of a registry, or of one
+// of its repositories, e.g. registry.example.com/ocp, covers the registry.
+func MissingRegistries(secret *corev1.Secret, registries []string) ([]string, error) {
+	data, ok := secret.Data[corev1.DockerConfigJsonKey]
+	if !ok {
+		return nil, fmt.Errorf("pull secret %s is missing the %s key", secret.Name, corev1.DockerConfigJsonKey)
+	}
+
+	config := dockerConfigJSON{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse pull secret %s: %w", secret.Name, err)
+	}
+	authRegistries := map[string]bool{}
+	for key := range config.Auths {
+		key = strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+		registry, _, _ := strings.Cut(key, "/")
+		authRegistries[registry] = true
+	}
+
+	var missing []string
+	for _, registry := range registries {
+		if !authRegistries[registry] {
+			missing = append(missing, registry)
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+)
+
+func getPullSecret(name, dockerConfigJSON string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte(dockerConfigJSON)},
+	}
+}
+
+func Test_MergePullSecrets(t *testing.T) {
+	testcases := []struct {
+		name              string
+		secrets           []*corev1.Secret
+		expected          string
+		expectedConflicts []string
+		expectedErr       bool
+	}{
+		{
+			name: "merges auths of different registries",
+			secrets: []*corev1.Secret{
+				getPullSecret("ps1", `{"auths":{"quay.io":{"auth":"YTpi"}}}`),
+				getPullSecret("ps2", `{"auths":{"mirror.example.com:5000":{"auth":"Yzpk","email":"c@d.com"}}}`),
+			},
+			expected: `{"auths":{"mirror.example.com:5000":{"auth":"Yzpk","email":"c@d.com"},` +
+				`"quay.io":{"auth":"YTpi"}}}`,
+		},
+		{
+			name: "identical auths for the same registry are not a conflict",
+			secrets: []*corev1.Secret{
+				getPullSecret("ps1", `{"auths":{"quay.io":{"auth":"YTpi"}}}`),
+				getPullSecret("ps2", `{"auths":{"quay.io":{"auth":"YTpi"}}}`),
+			},
+			expected: `{"auths":{"quay.io":{"auth":"YTpi"}}}`,
+		},
+		{
+			name: "differing auths for the same registry are a conflict",
+			secrets: []*corev1.Secret{
+				getPullSecret("ps1", `{"auths":{"quay.io":{"auth":"YTpi"},"registry.redhat.io":{"auth":"YTpi"}}}`),
+				getPullSecret("ps2", `{"auths":{"quay.io":{"auth":"Yzpk"},"registry.redhat.io":{"auth":"Yzpk"}}}`),
+			},
+			expected:          `{"auths":{"quay.io":{"auth":"YTpi"},"registry.redhat.io":{"auth":"YTpi"}}}`,
+			expectedConflicts: []string{"quay.io", "registry.redhat.io"},
+		},
+		{
+			name: "malformed pull secret",
+			secrets: []*corev1.Secret{
+				getPullSecret("ps1", `{"auths":{"quay.io":{"auth":"YTpi"}}}`),
+				getPullSecret("ps2", `foobar`),
+			},
+			expectedErr: true,
+		},
+		{
+			name: "pull secret missing the dockerconfigjson key",
+			secrets: []*corev1.Secret{
+				{ObjectMeta: metav1.ObjectMeta{Name: "ps1"}, Data: map[string][]byte{"foo": []byte("bar")}},
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			merged, conflicts, err := MergePullSecrets(tc.secrets)
+			if tc.expectedErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.JSONEq(t, tc.expected, string(merged))
+			assert.Equal(t, tc.expectedConflicts, conflicts)
+		})
+	}
+}
+
+func Test_EffectivePullSecretName(t *testing.T) {
+	clusterInstance := GetMockSNOClusterInstance(&TestParams{
+		ClusterName: "test-cluster", ClusterNamespace: "test-cluster", PullSecret: "pull-secret"})
+	assert.Equal(t, "pull-secret", EffectivePullSecretName(clusterInstance))
+
+	data, err := buildClusterData(clusterInstance, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "pull-secret", data.Spec.PullSecretRef.Name)
+
+	clusterInstance.Spec.PullSecretRefs = []corev1.LocalObjectReference{{Name: "mirror-pull-secret"}}
+	assert.Equal(t, "test-cluster-merged-pull-secret", EffectivePullSecretName(clusterInstance))
+
+	data, err = buildClusterData(clusterInstance, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "test-cluster-merged-pull-secret", data.Spec.PullSecretRef.Name)
+	// The ClusterInstance itself is left untouched
+	assert.Equal(t, "pull-secret", clusterInstance.Spec.PullSecretRef.Name)
+}
+
+func Test_RequiredRegistries(t *testing.T) {
+	testcases := []struct {
+		name               string
+		releaseImage       string
+		imageDigestSources []v1alpha1.ImageDigestSource
+		expected           []string
+	}{
+		{
+			name:         "registry of the release image",
+			releaseImage: "quay.io/openshift-release-dev/ocp-release:4.16.3-x86_64",
+			expected:     []string{"quay.io"},
+		},
+		{
+			name:         "mirrors of the release image replace its registry",
+			releaseImage: "quay.io/openshift-release-dev/ocp-release@sha256:0123456789abcdef",
+			imageDigestSources: []v1alpha1.ImageDigestSource{
+				{
+					Source:  "quay.io/openshift-release-dev/ocp-release",
+					Mirrors: []string{"mirror.example.com:5000/ocp/release", "backup.example.com/ocp/release"},
+				},
+				{
+					Source:  "registry.redhat.io/rhacm2",
+					Mirrors: []string{"mirror.example.com:5000/rhacm2"},
+				},
+			},
+			expected: []string{"backup.example.com", "mirror.example.com:5000"},
+		},
+		{
+			name:         "mirrors of other repositories are not required",
+			releaseImage: "quay.io/openshift-release-dev/ocp-release:4.16.3-x86_64",
+			imageDigestSources: []v1alpha1.ImageDigestSource{
+				{Source: "registry.redhat.io/rhacm2", Mirrors: []string{"mirror.example.com:5000/rhacm2"}},
+			},
+			expected: []string{"quay.io"},
+		},
+		{
+			name:     "no release image",
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, RequiredRegistries(tc.releaseImage, tc.imageDigestSources))
+		})
+	}
+}
+
+func Test_MissingRegistries(t *testing.T) {
+	secret := getPullSecret("ps1", `{"auths":{"https://quay.io":{"auth":"YTpi"},`+
+		`"mirror.example.com:5000/ocp":{"auth":"Yzpk"}}}`)
+
+	missing, err := MissingRegistries(secret, []string{"mirror.example.com:5000", "quay.io"})
+	assert.Nil(t, err)
+	assert.Empty(t, missing)
+
+	missing, err = MissingRegistries(secret, []string{"quay.io", "registry.redhat.io", "mirror.example.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"registry.redhat.io", "mirror.example.com"}, missing)
+
+	_, err = MissingRegistries(getPullSecret("ps2", "foobar"), []string{"quay.io"})
+	assert.NotNil(t, err)
+}
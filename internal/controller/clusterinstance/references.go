@@ -0,0 +1,160 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+)
+
+// MissingSecretReferences returns a description of each secret referenced by the ClusterInstance that does not exist,
+// i.e. the pull secrets and, for the BareMetal platform, the node BMC credentials. All the references are checked so
+// that the missing ones are reported at once. Errors other than NotFound are returned as is.
+func MissingSecretReferences(
+	ctx context.Context,
+	c client.Client,
+	clusterInstance *v1alpha1.ClusterInstance,
+) ([]string, error) {
+	var missing []string
+
+	check := func(name, description string) error {
+		key := types.NamespacedName{Name: name, Namespace: clusterInstance.Namespace}
+		if err := c.Get(ctx, key, &corev1.Secret{}); err != nil {
+			if !errors.IsNotFound(err) {
+				return fmt.Errorf("failed to get Secret %s: %w", key, err)
+			}
+			missing = append(missing, fmt.Sprintf("Secret %s (%s)", name, description))
+		}
+		return nil
+	}
+
+	for _, pullSecretRef := range PullSecretRefs(clusterInstance) {
+		if err := check(pullSecretRef.Name, "pull secret"); err != nil {
+			return nil, err
+		}
+	}
+
+	if clusterInstance.Spec.GetPlatformType() == v1alpha1.PlatformTypeBareMetal {
+		for _, node := range clusterInstance.Spec.Nodes {
+			if err := check(node.GetBmcCredentialsName(),
+				fmt.Sprintf("BMC credentials of node %s", node.HostName)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return missing, nil
+}
+
+// forEachTemplateRef calls fn with each template ConfigMap referenced by the cluster-level and node-level TemplateRefs
+// of the ClusterInstance and its description. A TemplateRef shared by several nodes is only passed once.
+func forEachTemplateRef(
+	clusterInstance *v1alpha1.ClusterInstance,
+	fn func(key types.NamespacedName, description string) error,
+) error {
+	checked := map[v1alpha1.TemplateRef]bool{}
+	check := func(templateRef v1alpha1.TemplateRef, description string) error {
+		if checked[templateRef] {
+			return nil
+		}
+		checked[templateRef] = true
+		return fn(types.NamespacedName{Name: templateRef.Name, Namespace: templateRef.Namespace}, description)
+	}
+
+	for _, templateRef := range clusterInstance.Spec.TemplateRefs {
+		if err := check(templateRef, "cluster-level template"); err != nil {
+			return err
+		}
+	}
+
+	for _, node := range clusterInstance.Spec.Nodes {
+		for _, templateRef := range node.TemplateRefs {
+			if err := check(templateRef, fmt.Sprintf("node-level template of node %s", node.HostName)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MissingTemplateReferences returns a description of each template ConfigMap referenced by the cluster-level and
+// node-level TemplateRefs of the ClusterInstance that does not exist. A TemplateRef shared by several nodes is reported
+// once. Errors other than NotFound are returned as is.
+func MissingTemplateReferences(
+	ctx context.Context,
+	c client.Client,
+	clusterInstance *v1alpha1.ClusterInstance,
+) ([]string, error) {
+	var missing []string
+	err := forEachTemplateRef(clusterInstance, func(key types.NamespacedName, description string) error {
+		if err := c.Get(ctx, key, &corev1.ConfigMap{}); err != nil {
+			if !errors.IsNotFound(err) {
+				return fmt.Errorf("failed to get ConfigMap %s: %w", key, err)
+			}
+			missing = append(missing, fmt.Sprintf("ConfigMap %s (%s)", key, description))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
+
+// ForbiddenTemplateReferences returns a description of each template ConfigMap referenced by the cluster-level and
+// node-level TemplateRefs of the ClusterInstance that the controller is not allowed to read, along with the sorted
+// namespaces of those ConfigMaps. The templates that do not exist are reported by MissingTemplateReferences, errors
+// other than Forbidden and NotFound are returned as is. The reader must not be cached, since a cached reader does not
+// report the missing permissions as Forbidden.
+func ForbiddenTemplateReferences(
+	ctx context.Context,
+	c client.Reader,
+	clusterInstance *v1alpha1.ClusterInstance,
+) ([]string, []string, error) {
+	var forbidden []string
+	namespaces := map[string]bool{}
+	err := forEachTemplateRef(clusterInstance, func(key types.NamespacedName, description string) error {
+		if err := c.Get(ctx, key, &corev1.ConfigMap{}); err != nil {
+			switch {
+			case errors.IsForbidden(err):
+				forbidden = append(forbidden, fmt.Sprintf("ConfigMap %s (%s)", key, description))
+				namespaces[key.Namespace] = true
+			case !errors.IsNotFound(err):
+				return fmt.Errorf("failed to get ConfigMap %s: %w", key, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sortedNamespaces := make([]string, 0, len(namespaces))
+	for namespace := range namespaces {
+		sortedNamespaces = append(sortedNamespaces, namespace)
+	}
+	sort.Strings(sortedNamespaces)
+	return forbidden, sortedNamespaces, nil
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// defaultRegistry is the registry of the image references that do not include one
+const defaultRegistry = "docker.io"
+
+// RegistryCheckTimeout is the timeout of the requests of the RegistryChecker
+const RegistryCheckTimeout = 10 * time.Second
+
+// RegistryCheckInterval is the default duration the result of a registry check is reused
+const RegistryCheckInterval = 5 * time.Minute
+
+// RegistryChecker checks that a container registry is reachable and accepts the credentials of a pull secret, with a
+// lightweight request to the base endpoint of the registry API
+type RegistryChecker struct {
+	// Client sends the requests to the registry, it must enforce a timeout
+	Client *http.Client
+	// Interval is the duration the result of a check is reused by CachedCheck for the same registry and credentials,
+	// the registry is checked on every call when zero
+	Interval time.Duration
+
+	mu      sync.Mutex
+	results map[string]registryCheckResult
+}
+
+// registryCheckResult is the outcome of the check of a registry with given credentials
+type registryCheckResult struct {
+	err       error
+	checkedAt time.Time
+}
+
+// NewRegistryChecker returns a RegistryChecker whose requests time out after the given duration, and whose results
+// are reused for the given interval
+func NewRegistryChecker(timeout, interval time.Duration) *RegistryChecker {
+	return &RegistryChecker{Client: &http.Client{Timeout: timeout}, Interval: interval}
+}
+
+// CachedCheck returns the result of the last check of the registry with the same credentials when it is more recent
+// than the Interval, otherwise it checks the registry again. This keeps the ClusterInstances sharing a registry from
+// each sending requests to it on every reconcile.
+func (rc *RegistryChecker) CachedCheck(ctx context.Context, registry, auth string) error {
+	// The credentials are only kept hashed in the key of the results
+	sum := sha256.Sum256([]byte(auth))
+	key := registry + "/" + hex.EncodeToString(sum[:])
+
+	rc.mu.Lock()
+	result, ok := rc.results[key]
+	rc.mu.Unlock()
+	if ok && time.Since(result.checkedAt) < rc.Interval {
+		return result.err
+	}
+
+	err := rc.Check(ctx, registry, auth)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.results == nil {
+		rc.results = map[string]registryCheckResult{}
+	}
+	rc.results[key] = registryCheckResult{err: err, checkedAt: time.Now()}
+	return err
+}
+
+// ImageRegistry returns the registry of the image reference, i.e. its first path component when it is a host name,
+// otherwise docker.io
+func ImageRegistry(image string) string {
+	host, _, found := strings.Cut(image, "/")
+	if !found || (!strings.ContainsAny(host, ".:") && host != "localhost") {
+		return defaultRegistry
+	}
+	return host
+}
+
+// RegistryAuth returns the base64 encoded credentials of the registry in the pull secret, empty if it has none
+func RegistryAuth(secret *corev1.Secret, registry string) (string, error) {
+	data, ok := secret.Data[corev1.DockerConfigJsonKey]
+	if !ok {
+		return "", fmt.Errorf("pull secret %s is missing the %s key", secret.Name, corev1.DockerConfigJsonKey)
+	}
+
+	config := struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return "", fmt.Errorf("failed to parse pull secret %s: %w", secret.Name, err)
+	}
+	return config.Auths[registry].Auth, nil
+}
+
+// Check verifies that the registry is reachable and accepts the credentials. Registries using token authentication
+// are checked by requesting a token from the realm they advertise.
+func (rc *RegistryChecker) Check(ctx context.Context, registry, auth string) error {
+	resp, err := rc.get(ctx, "https://"+registry+"/v2/", auth)
+	if err != nil {
+		return err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized:
+		realm, service := bearerChallenge(resp.Header.Get("WWW-Authenticate"))
+		if realm == "" {
+			return fmt.Errorf("registry %s rejected the credentials of the pull secret", registry)
+		}
+		tokenURL, err := url.Parse(realm)
+		if err != nil {
+			return fmt.Errorf("registry %s advertises an invalid token realm %q: %w", registry, realm, err)
+		}
+		if service != "" {
+			query := tokenURL.Query()
+			query.Set("service", service)
+			tokenURL.RawQuery = query.Encode()
+		}
+
+		resp, err = rc.get(ctx, tokenURL.String(), auth)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("registry %s rejected the credentials of the pull secret: %s", registry, resp.Status)
+		}
+		return nil
+	default:
+		return fmt.Errorf("registry %s responded with an unexpected status: %s", registry, resp.Status)
+	}
+}
+
+// get sends a GET request with the given basic credentials, the response body is discarded
+func (rc *RegistryChecker) get(ctx context.Context, target, auth string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", "Basic "+auth)
+	}
+
+	resp, err := rc.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach %s: %w", req.URL.Host, err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	return resp, nil
+}
+
+// bearerChallenge returns the realm and service of a Bearer WWW-Authenticate challenge, empty if it is not one
+func bearerChallenge(challenge string) (realm, service string) {
+	params, found := strings.CutPrefix(challenge, "Bearer ")
+	if !found {
+		return "", ""
+	}
+	for _, param := range strings.Split(params, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		value = strings.Trim(value, `"`)
+		switch strings.ToLower(key) {
+		case "realm":
+			realm = value
+		case "service":
+			service = value
+		}
+	}
+	return realm, service
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testRegistryAuth is the base64 encoding of "user:password"
+const testRegistryAuth = "dXNlcjpwYXNzd29yZA=="
+
+// newTestRegistry starts a mock registry accepting testRegistryAuth, either directly or through a token endpoint
+func newTestRegistry(t *testing.T, tokenAuth bool) (*httptest.Server, string) {
+	mux := http.NewServeMux()
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+
+	authorized := func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "Basic "+testRegistryAuth
+	}
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case tokenAuth:
+			w.Header().Set("WWW-Authenticate",
+				`Bearer realm="`+server.URL+`/token",service="test-registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		case authorized(r):
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) || r.URL.Query().Get("service") != "test-registry" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"token":"test-token"}`))
+	})
+
+	return server, strings.TrimPrefix(server.URL, "https://")
+}
+
+func Test_ImageRegistry(t *testing.T) {
+	testcases := map[string]string{
+		"quay.io/openshift-release-dev/ocp-release:4.14.0-x86_64": "quay.io",
+		"registry.example.com:5000/ocp/release@sha256:0123":       "registry.example.com:5000",
+		"localhost/ocp/release:4.14":                              "localhost",
+		"library/busybox:latest":                                  "docker.io",
+		"busybox":                                                 "docker.io",
+	}
+	for image, expected := range testcases {
+		assert.Equal(t, expected, ImageRegistry(image), image)
+	}
+}
+
+func Test_RegistryAuth(t *testing.T) {
+	secret := getPullSecret("pull-secret", `{"auths":{"quay.io":{"auth":"`+testRegistryAuth+`"}}}`)
+
+	auth, err := RegistryAuth(secret, "quay.io")
+	assert.Nil(t, err)
+	assert.Equal(t, testRegistryAuth, auth)
+
+	auth, err = RegistryAuth(secret, "registry.example.com")
+	assert.Nil(t, err)
+	assert.Empty(t, auth)
+
+	_, err = RegistryAuth(getPullSecret("invalid", "{"), "quay.io")
+	assert.ErrorContains(t, err, "failed to parse pull secret invalid")
+}
+
+func Test_RegistryChecker(t *testing.T) {
+	testcases := []struct {
+		name      string
+		tokenAuth bool
+		auth      string
+		expected  string
+	}{
+		{
+			name: "basic auth accepted",
+			auth: testRegistryAuth,
+		},
+		{
+			name:     "basic auth rejected",
+			auth:     "d3Jvbmc6d3Jvbmc=",
+			expected: "rejected the credentials of the pull secret",
+		},
+		{
+			name:      "token auth accepted",
+			tokenAuth: true,
+			auth:      testRegistryAuth,
+		},
+		{
+			name:      "token auth without credentials",
+			tokenAuth: true,
+			expected:  "rejected the credentials of the pull secret: 401 Unauthorized",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			server, registry := newTestRegistry(t, tc.tokenAuth)
+			checker := &RegistryChecker{Client: server.Client()}
+
+			err := checker.Check(context.Background(), registry, tc.auth)
+			if tc.expected == "" {
+				assert.Nil(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tc.expected)
+		})
+	}
+
+	t.Run("cached result", func(t *testing.T) {
+		server, registry := newTestRegistry(t, false)
+		checker := &RegistryChecker{Client: server.Client(), Interval: time.Minute}
+
+		assert.Nil(t, checker.CachedCheck(context.Background(), registry, testRegistryAuth))
+		server.Close()
+		assert.Nil(t, checker.CachedCheck(context.Background(), registry, testRegistryAuth))
+
+		checker.Interval = 0
+		err := checker.CachedCheck(context.Background(), registry, testRegistryAuth)
+		assert.ErrorContains(t, err, "failed to reach "+registry)
+	})
+
+	t.Run("registry unreachable", func(t *testing.T) {
+		server, registry := newTestRegistry(t, false)
+		checker := &RegistryChecker{Client: server.Client()}
+		server.Close()
+
+		err := checker.Check(context.Background(), registry, testRegistryAuth)
+		assert.ErrorContains(t, err, "failed to reach "+registry)
+	})
+}
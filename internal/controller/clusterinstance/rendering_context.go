@@ -0,0 +1,115 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	k8syaml "sigs.k8s.io/yaml"
+)
+
+const (
+	// DumpRenderingContextAnnotation triggers a one-off dump of the template rendering context of the ClusterInstance
+	// to a ConfigMap, the annotation is removed once the dump is written
+	DumpRenderingContextAnnotation = v1alpha1.Group + "/dump-rendering-context"
+
+	// RenderingContextClusterKey is the ConfigMap key holding the cluster-level rendering context
+	RenderingContextClusterKey = "cluster"
+
+	redactedValue = "REDACTED"
+)
+
+// RenderingContextConfigMapName returns the name of the ConfigMap holding the rendering context dump
+func RenderingContextConfigMapName(clusterInstance *v1alpha1.ClusterInstance) string {
+	return clusterInstance.Name + "-rendering-context"
+}
+
+// RenderingContextNodeKey returns the ConfigMap key holding the rendering context of the node at the given index
+func RenderingContextNodeKey(index int) string {
+	return fmt.Sprintf("node-%d", index)
+}
+
+// redactURL masks the password of a URL, if any
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		// Do not risk leaking credentials from an unparsable URL
+		return redactedValue
+	}
+	if _, hasPassword := u.User.Password(); hasPassword {
+		u.User = url.UserPassword(u.User.Username(), redactedValue)
+	}
+	return u.String()
+}
+
+func redactString(value string) string {
+	if value == "" {
+		return value
+	}
+	return redactedValue
+}
+
+// redactClusterData masks the values of the rendering context that may carry credentials: the ignition config
+// overrides and the proxy URLs
+func redactClusterData(data *ClusterData) {
+	spec := data.Spec.DeepCopy()
+	spec.IgnitionConfigOverride = redactString(spec.IgnitionConfigOverride)
+	if spec.Proxy != nil {
+		spec.Proxy.HTTPProxy = redactURL(spec.Proxy.HTTPProxy)
+		spec.Proxy.HTTPSProxy = redactURL(spec.Proxy.HTTPSProxy)
+	}
+	for i := range spec.Nodes {
+		spec.Nodes[i].IgnitionConfigOverride = redactString(spec.Nodes[i].IgnitionConfigOverride)
+	}
+	data.Spec = *spec
+
+	data.SpecialVars.CurrentNode.IgnitionConfigOverride = redactString(
+		data.SpecialVars.CurrentNode.IgnitionConfigOverride)
+}
+
+// DumpRenderingContext serializes the template rendering context of the ClusterInstance, i.e. the cluster-level
+// context and the context of each node, with the sensitive values redacted. The result is keyed by
+// RenderingContextClusterKey and RenderingContextNodeKey.
+func DumpRenderingContext(clusterInstance *v1alpha1.ClusterInstance) (map[string]string, error) {
+	dump := map[string]string{}
+
+	add := func(key string, node *v1alpha1.NodeSpec) error {
+		data, err := buildClusterData(clusterInstance, node)
+		if err != nil {
+			return err
+		}
+		redactClusterData(data)
+		out, err := k8syaml.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("failed to serialize rendering context %s: %w", key, err)
+		}
+		dump[key] = string(out)
+		return nil
+	}
+
+	if err := add(RenderingContextClusterKey, nil); err != nil {
+		return nil, err
+	}
+	for i := range clusterInstance.Spec.Nodes {
+		if err := add(RenderingContextNodeKey(i), &clusterInstance.Spec.Nodes[i]); err != nil {
+			return nil, err
+		}
+	}
+	return dump, nil
+}
@@ -0,0 +1,229 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// LastAppliedSpecAnnotation is the legacy annotation in which the last applied spec was recorded before it moved
+	// to the status; it is only read when the status does not record the last applied spec
+	LastAppliedSpecAnnotation = v1alpha1.Group + "/last-applied-spec"
+
+	nodesField = "nodes"
+)
+
+// safeClusterFields are the cluster-level spec fields (json names) that can be applied while provisioning is in-progress
+var safeClusterFields = map[string]bool{
+	"extraAnnotations":        true,
+	"clusterLabels":           true,
+	"chargebackMetadata":      true,
+	"ttlSecondsAfterFinished": true,
+	"machinePools":            true,
+	"deprovisionOnFailure":    true,
+}
+
+// safeNodeFields are the node-level spec fields (json names) that can be applied while provisioning is in-progress
+var safeNodeFields = map[string]bool{
+	"extraAnnotations": true,
+	"nodeLabels":       true,
+}
+
+// GetLastAppliedSpec returns the spec recorded in the status of the ClusterInstance, falling back to the legacy
+// LastAppliedSpecAnnotation, or nil if neither is set
+func GetLastAppliedSpec(clusterInstance *v1alpha1.ClusterInstance) (*v1alpha1.ClusterInstanceSpec, error) {
+	spec := &v1alpha1.ClusterInstanceSpec{}
+	if raw := clusterInstance.Status.LastAppliedSpec; raw != nil && len(raw.Raw) > 0 {
+		if err := json.Unmarshal(raw.Raw, spec); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal status.lastAppliedSpec: %w", err)
+		}
+		return spec, nil
+	}
+
+	value, ok := clusterInstance.GetAnnotations()[LastAppliedSpecAnnotation]
+	if !ok || value == "" {
+		return nil, nil
+	}
+	if err := json.Unmarshal([]byte(value), spec); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal annotation %s: %w", LastAppliedSpecAnnotation, err)
+	}
+	return spec, nil
+}
+
+// withoutInlineBmcPasswords returns a copy of the spec without the passwords of the inline BMC credentials, which must
+// not be recorded in plain text outside of their secret
+func withoutInlineBmcPasswords(spec *v1alpha1.ClusterInstanceSpec) *v1alpha1.ClusterInstanceSpec {
+	stripped := spec.DeepCopy()
+	for i := range stripped.Nodes {
+		if stripped.Nodes[i].BmcCredentials != nil {
+			stripped.Nodes[i].BmcCredentials.Password = ""
+		}
+	}
+	return stripped
+}
+
+// SetLastAppliedSpec records the given spec in the status of the ClusterInstance, without the passwords of the inline
+// BMC credentials. The status is not bound by the size limit of the annotations, which large specs can exceed.
+func SetLastAppliedSpec(clusterInstance *v1alpha1.ClusterInstance, spec *v1alpha1.ClusterInstanceSpec) error {
+	data, err := json.Marshal(withoutInlineBmcPasswords(spec))
+	if err != nil {
+		return fmt.Errorf("failed to marshal the last applied spec: %w", err)
+	}
+	clusterInstance.Status.LastAppliedSpec = &runtime.RawExtension{Raw: data}
+	return nil
+}
+
+// toFieldMap converts an object to a map of its json fields
+func toFieldMap(obj interface{}) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// changedFields returns the sorted list of json fields that differ between the applied and desired field maps,
+// excluding the fields flagged as safe
+func changedFields(applied, desired map[string]json.RawMessage, safeFields map[string]bool) []string {
+	keys := map[string]bool{}
+	for k := range applied {
+		keys[k] = true
+	}
+	for k := range desired {
+		keys[k] = true
+	}
+
+	changes := []string{}
+	for k := range keys {
+		if safeFields[k] || k == nodesField {
+			continue
+		}
+		if !bytes.Equal(applied[k], desired[k]) {
+			changes = append(changes, k)
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// UnsafeSpecChanges returns the list of spec fields that differ between the applied and desired specs and that are
+// considered disruptive while the cluster is being provisioned, i.e. everything except labels, annotations, the TTL
+// and the machine pools.
+// Node-level fields are reported as "nodes[<index>].<field>", whereas a change in the number of nodes is reported
+// as "nodes". The passwords of the inline BMC credentials are not compared, they are not recorded in the applied spec.
+func UnsafeSpecChanges(applied, desired *v1alpha1.ClusterInstanceSpec) ([]string, error) {
+	applied, desired = withoutInlineBmcPasswords(applied), withoutInlineBmcPasswords(desired)
+	appliedFields, err := toFieldMap(applied)
+	if err != nil {
+		return nil, err
+	}
+	desiredFields, err := toFieldMap(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := changedFields(appliedFields, desiredFields, safeClusterFields)
+
+	if len(applied.Nodes) != len(desired.Nodes) {
+		return append(changes, nodesField), nil
+	}
+
+	for i := range desired.Nodes {
+		appliedNode, err := toFieldMap(applied.Nodes[i])
+		if err != nil {
+			return nil, err
+		}
+		desiredNode, err := toFieldMap(desired.Nodes[i])
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range changedFields(appliedNode, desiredNode, safeNodeFields) {
+			changes = append(changes, fmt.Sprintf("%s[%d].%s", nodesField, i, field))
+		}
+	}
+
+	return changes, nil
+}
+
+// MergeSafeSpecChanges returns a copy of the applied spec with the safe (labels, annotations, TTL, machine pools and
+// deprovision policy) changes of the desired spec applied on top of it
+func MergeSafeSpecChanges(applied, desired *v1alpha1.ClusterInstanceSpec) *v1alpha1.ClusterInstanceSpec {
+	merged := applied.DeepCopy()
+	merged.ExtraAnnotations = desired.ExtraAnnotations
+	merged.ClusterLabels = desired.ClusterLabels
+	merged.ChargebackMetadata = desired.ChargebackMetadata
+	merged.TTLSecondsAfterFinished = desired.TTLSecondsAfterFinished
+	merged.MachinePools = desired.MachinePools
+	merged.DeprovisionOnFailure = desired.DeprovisionOnFailure
+
+	if len(merged.Nodes) == len(desired.Nodes) {
+		for i := range merged.Nodes {
+			merged.Nodes[i].ExtraAnnotations = desired.Nodes[i].ExtraAnnotations
+			merged.Nodes[i].NodeLabels = desired.Nodes[i].NodeLabels
+		}
+	}
+	return merged
+}
+
+// AddedNodes returns the host names of the nodes of the desired spec that are not in the applied spec, when adding
+// them is the only change of the desired spec, and nil otherwise. The nodes are identified by their host name, so that
+// the added nodes may be inserted anywhere in the list.
+func AddedNodes(applied, desired *v1alpha1.ClusterInstanceSpec) []string {
+	if len(desired.Nodes) <= len(applied.Nodes) {
+		return nil
+	}
+
+	desiredNodes := map[string]v1alpha1.NodeSpec{}
+	for _, node := range desired.Nodes {
+		desiredNodes[node.HostName] = node
+	}
+	// Compare the existing nodes in their applied order
+	existing := desired.DeepCopy()
+	existing.Nodes = make([]v1alpha1.NodeSpec, 0, len(applied.Nodes))
+	appliedHostNames := map[string]bool{}
+	for _, node := range applied.Nodes {
+		desiredNode, found := desiredNodes[node.HostName]
+		if !found {
+			return nil
+		}
+		existing.Nodes = append(existing.Nodes, desiredNode)
+		appliedHostNames[node.HostName] = true
+	}
+	if !equality.Semantic.DeepEqual(withoutInlineBmcPasswords(existing), withoutInlineBmcPasswords(applied)) {
+		return nil
+	}
+
+	var hostNames []string
+	for _, node := range desired.Nodes {
+		if !appliedHostNames[node.HostName] {
+			hostNames = append(hostNames, node.HostName)
+		}
+	}
+	return hostNames
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_UnsafeSpecChanges(t *testing.T) {
+	applied := GetMockSNOClusterInstance(&TestParams{ClusterName: "test-cluster", ClusterNamespace: "test-cluster"}).Spec
+
+	testcases := []struct {
+		name     string
+		mutate   func(spec *v1alpha1.ClusterInstanceSpec)
+		expected []string
+	}{
+		{
+			name:     "no changes",
+			mutate:   func(spec *v1alpha1.ClusterInstanceSpec) {},
+			expected: []string{},
+		},
+		{
+			name: "labels and annotations are safe changes",
+			mutate: func(spec *v1alpha1.ClusterInstanceSpec) {
+				spec.ClusterLabels = map[string]string{"foo": "bar"}
+				spec.ExtraAnnotations = map[string]map[string]string{"BareMetalHost": {"foo": "bar"}}
+				spec.Nodes[0].NodeLabels = map[string]string{"node-role.kubernetes.io/infra": ""}
+				spec.Nodes[0].ExtraAnnotations = map[string]map[string]string{"BareMetalHost": {"foo": "bar"}}
+			},
+			expected: []string{},
+		},
+		{
+			name: "machine pool changes are safe",
+			mutate: func(spec *v1alpha1.ClusterInstanceSpec) {
+				replicas := int64(3)
+				spec.MachinePools = []v1alpha1.MachinePoolSpec{{Name: "worker", Replicas: &replicas}}
+			},
+			expected: []string{},
+		},
+		{
+			name: "deprovision policy changes are safe",
+			mutate: func(spec *v1alpha1.ClusterInstanceSpec) {
+				spec.DeprovisionOnFailure = true
+			},
+			expected: []string{},
+		},
+		{
+			name: "cluster name and node BMC changes are unsafe",
+			mutate: func(spec *v1alpha1.ClusterInstanceSpec) {
+				spec.ClusterName = "new-name"
+				spec.ClusterLabels = map[string]string{"foo": "bar"}
+				spec.Nodes[0].BmcAddress = "192.0.2.99"
+				spec.Nodes[0].BmcCredentialsName.Name = "new-bmc-secret"
+			},
+			expected: []string{"clusterName", "nodes[0].bmcAddress", "nodes[0].bmcCredentialsName"},
+		},
+		{
+			name: "adding a node is unsafe",
+			mutate: func(spec *v1alpha1.ClusterInstanceSpec) {
+				spec.Nodes = append(spec.Nodes, spec.Nodes[0])
+			},
+			expected: []string{"nodes"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			desired := applied.DeepCopy()
+			tc.mutate(desired)
+			changes, err := UnsafeSpecChanges(&applied, desired)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, changes)
+		})
+	}
+}
+
+func Test_MergeSafeSpecChanges(t *testing.T) {
+	applied := GetMockSNOClusterInstance(&TestParams{ClusterName: "test-cluster", ClusterNamespace: "test-cluster"}).Spec
+
+	desired := applied.DeepCopy()
+	desired.ClusterName = "new-name"
+	desired.ClusterLabels = map[string]string{"foo": "bar"}
+	desired.Nodes[0].BmcAddress = "192.0.2.99"
+	desired.Nodes[0].NodeLabels = map[string]string{"node-role.kubernetes.io/infra": ""}
+	desired.MachinePools = []v1alpha1.MachinePoolSpec{{Name: "worker"}}
+	desired.DeprovisionOnFailure = true
+
+	merged := MergeSafeSpecChanges(&applied, desired)
+	assert.Equal(t, applied.ClusterName, merged.ClusterName)
+	assert.Equal(t, applied.Nodes[0].BmcAddress, merged.Nodes[0].BmcAddress)
+	assert.Equal(t, desired.ClusterLabels, merged.ClusterLabels)
+	assert.Equal(t, desired.Nodes[0].NodeLabels, merged.Nodes[0].NodeLabels)
+	assert.Equal(t, desired.MachinePools, merged.MachinePools)
+	assert.True(t, merged.DeprovisionOnFailure)
+}
+
+func Test_LastAppliedSpec(t *testing.T) {
+	clusterInstance := GetMockSNOClusterInstance(&TestParams{ClusterName: "test-cluster", ClusterNamespace: "test-cluster"})
+
+	spec, err := GetLastAppliedSpec(clusterInstance)
+	assert.Nil(t, err)
+	assert.Nil(t, spec)
+
+	assert.Nil(t, SetLastAppliedSpec(clusterInstance, &clusterInstance.Spec))
+	assert.NotContains(t, clusterInstance.GetAnnotations(), LastAppliedSpecAnnotation)
+	spec, err = GetLastAppliedSpec(clusterInstance)
+	assert.Nil(t, err)
+	assert.Equal(t, clusterInstance.Spec, *spec)
+}
+
+func Test_LastAppliedSpecLegacyAnnotation(t *testing.T) {
+	clusterInstance := GetMockSNOClusterInstance(&TestParams{ClusterName: "test-cluster", ClusterNamespace: "test-cluster"})
+	data, err := json.Marshal(clusterInstance.Spec)
+	assert.Nil(t, err)
+	clusterInstance.SetAnnotations(map[string]string{LastAppliedSpecAnnotation: string(data)})
+
+	spec, err := GetLastAppliedSpec(clusterInstance)
+	assert.Nil(t, err)
+	assert.Equal(t, clusterInstance.Spec, *spec)
+
+	// The status takes precedence over the legacy annotation
+	applied := clusterInstance.Spec.DeepCopy()
+	applied.ClusterName = "applied-cluster"
+	assert.Nil(t, SetLastAppliedSpec(clusterInstance, applied))
+	spec, err = GetLastAppliedSpec(clusterInstance)
+	assert.Nil(t, err)
+	assert.Equal(t, "applied-cluster", spec.ClusterName)
+}
+
+func Test_LastAppliedSpecWithoutInlineBmcPasswords(t *testing.T) {
+	clusterInstance := GetMockSNOClusterInstance(&TestParams{ClusterName: "test-cluster", ClusterNamespace: "test-cluster"})
+	clusterInstance.Spec.Nodes[0].BmcCredentials = &v1alpha1.InlineBmcCredentials{Username: "admin", Password: "secret"}
+
+	assert.Nil(t, SetLastAppliedSpec(clusterInstance, &clusterInstance.Spec))
+	assert.NotContains(t, string(clusterInstance.Status.LastAppliedSpec.Raw), "secret")
+	assert.Equal(t, "secret", clusterInstance.Spec.Nodes[0].BmcCredentials.Password)
+
+	spec, err := GetLastAppliedSpec(clusterInstance)
+	assert.Nil(t, err)
+	assert.Equal(t, "admin", spec.Nodes[0].BmcCredentials.Username)
+	assert.Empty(t, spec.Nodes[0].BmcCredentials.Password)
+
+	// The stripped password is not reported as a change
+	changes, err := UnsafeSpecChanges(spec, &clusterInstance.Spec)
+	assert.Nil(t, err)
+	assert.Empty(t, changes)
+}
+
+func Test_AddedNodes(t *testing.T) {
+	applied := GetMockSNOClusterInstance(&TestParams{ClusterName: "test-cluster", ClusterNamespace: "test-cluster"}).Spec
+
+	worker := *applied.Nodes[0].DeepCopy()
+	worker.HostName = "worker-1"
+	worker.Role = "worker"
+
+	desired := applied.DeepCopy()
+	assert.Nil(t, AddedNodes(&applied, desired))
+
+	desired.Nodes = append(desired.Nodes, worker)
+	assert.Equal(t, []string{"worker-1"}, AddedNodes(&applied, desired))
+
+	// The nodes are identified by their host name regardless of their position
+	desired.Nodes = []v1alpha1.NodeSpec{worker, applied.Nodes[0]}
+	assert.Equal(t, []string{"worker-1"}, AddedNodes(&applied, desired))
+
+	// The nodes are not only added when other fields are changed along with them
+	desired.ClusterLabels = map[string]string{"foo": "bar"}
+	assert.Nil(t, AddedNodes(&applied, desired))
+}
@@ -19,11 +19,14 @@ package clusterinstance
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"regexp"
 	"text/template"
 	"unicode"
 
 	"github.com/go-logr/logr"
+	"golang.org/x/exp/slices"
 	"gopkg.in/yaml.v3"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -35,8 +38,20 @@ import (
 const (
 	WaveAnnotation        = v1alpha1.Group + "/sync-wave"
 	DefaultWaveAnnotation = "0"
+	// AllowEmptyRenderAnnotation marks a template ConfigMap whose templates may intentionally render no content
+	AllowEmptyRenderAnnotation = v1alpha1.Group + "/allow-empty-render"
 )
 
+// bareMetalKinds are the kinds of the manifests that are only rendered for the BareMetal platform, i.e. the manifests
+// of the hosts managed through their BMC
+var bareMetalKinds = map[string]bool{
+	"BareMetalHost":        true,
+	"HostFirmwareSettings": true,
+}
+
+// clusterDeploymentKindRegex matches the templates declaring the Hive ClusterDeployment kind
+var clusterDeploymentKindRegex = regexp.MustCompile(`(?m)^kind:\s*["']?ClusterDeployment["']?\s*$`)
+
 type TemplateEngine struct {
 	Log logr.Logger
 }
@@ -45,24 +60,120 @@ func NewTemplateEngine(pLog logr.Logger) *TemplateEngine {
 	return &TemplateEngine{Log: pLog}
 }
 
+// RenderResult is the outcome of rendering the templates of a ClusterInstance
+type RenderResult struct {
+	// Manifests are the rendered manifests
+	Manifests []interface{}
+	// TemplateHashes are the hashes of all the current templates, to be recorded once the manifests are applied
+	TemplateHashes []v1alpha1.RenderedTemplateHash
+	// TemplateStatus is the render result of each TemplateRef
+	TemplateStatus []v1alpha1.TemplateRenderStatus
+}
+
+// ProcessTemplates renders the cluster-level and node-level templates of the ClusterInstance
 func (te *TemplateEngine) ProcessTemplates(
 	ctx context.Context,
 	c client.Client,
 	clusterInstance v1alpha1.ClusterInstance,
 ) ([]interface{}, error) {
+	result, err := te.processTemplates(ctx, c, &clusterInstance, nil)
+	return result.Manifests, err
+}
+
+// ProcessChangedTemplates renders only the templates whose content or rendering inputs changed since the last applied
+// rendering, as recorded in the RenderedTemplateHashes status of the ClusterInstance. The returned result holds the
+// render status of every TemplateRef, including on error, so that the failing templates can be reported.
+func (te *TemplateEngine) ProcessChangedTemplates(
+	ctx context.Context,
+	c client.Client,
+	clusterInstance v1alpha1.ClusterInstance,
+) (*RenderResult, error) {
+	return te.processTemplates(ctx, c, &clusterInstance,
+		templateHashIndex(clusterInstance.Status.RenderedTemplateHashes))
+}
+
+// ProcessNodesTemplates renders only the node-level templates of the nodes with the given host names, e.g. of the nodes
+// added to an installed cluster. The cluster-level templates and those of the other nodes are not rendered, hence the
+// returned result only holds the hashes and the render status of the given nodes' TemplateRefs.
+func (te *TemplateEngine) ProcessNodesTemplates(
+	ctx context.Context,
+	c client.Client,
+	clusterInstance v1alpha1.ClusterInstance,
+	hostNames []string,
+) (*RenderResult, error) {
+
+	result := &RenderResult{}
+	var errs []error
+	for i := range clusterInstance.Spec.Nodes {
+		node := clusterInstance.Spec.Nodes[i]
+		if !slices.Contains(hostNames, node.HostName) {
+			continue
+		}
+		if err := te.renderChangedTemplates(ctx, c, &clusterInstance, &node, nil, result); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		result.TemplateHashes = nil
+		return result, errors.Join(errs...)
+	}
+	return result, nil
+}
+
+// processTemplates renders the templates of the cluster and of each node, along with the MachinePools, the chrony
+// configuration, the ImageDigestMirrorSet and the operator Subscriptions of the cluster. All the TemplateRefs are
+// rendered even if some of them fail, the errors are aggregated in the returned error.
+func (te *TemplateEngine) processTemplates(
+	ctx context.Context,
+	c client.Client,
+	clusterInstance *v1alpha1.ClusterInstance,
+	lastHashes map[string]string,
+) (*RenderResult, error) {
+
+	result := &RenderResult{}
+	var errs []error
 
 	te.Log.Info(fmt.Sprintf("Processing cluster-level templates for ClusterInstance %s", clusterInstance.Name))
 
 	// Render cluster-level templates
-	clusterManifests, err := te.renderTemplates(ctx, c, &clusterInstance, nil)
-	if err != nil {
+	if err := te.renderChangedTemplates(ctx, c, clusterInstance, nil, lastHashes, result); err != nil {
 		te.Log.Info(
 			fmt.Sprintf(
 				"encountered error while processing cluster-level templates for ClusterInstance %s, err: %s",
 				clusterInstance.Name, err.Error()))
-		return clusterManifests, err
+		errs = append(errs, err)
+	} else {
+		te.Log.Info(fmt.Sprintf("Processed cluster-level templates for ClusterInstance %s", clusterInstance.Name))
+	}
+
+	// Render the MachinePools of the cluster
+	if machinePools, err := renderMachinePools(clusterInstance); err != nil {
+		errs = append(errs, err)
+	} else {
+		result.Manifests = append(result.Manifests, machinePools...)
+	}
+
+	// Render the chrony configuration of the cluster
+	if chronyConfig, err := renderChronyConfig(clusterInstance); err != nil {
+		errs = append(errs, err)
+	} else {
+		result.Manifests = append(result.Manifests, chronyConfig...)
+	}
+
+	// Render the ImageDigestMirrorSet of the cluster
+	if imageDigestMirrorSet, err := renderImageDigestMirrorSet(clusterInstance); err != nil {
+		errs = append(errs, err)
+	} else {
+		result.Manifests = append(result.Manifests, imageDigestMirrorSet...)
+	}
+
+	// Render the operator Subscriptions of the cluster
+	if installOperators, err := renderInstallOperators(clusterInstance); err != nil {
+		errs = append(errs, err)
+	} else {
+		result.Manifests = append(result.Manifests, installOperators...)
 	}
-	te.Log.Info(fmt.Sprintf("Processed cluster-level templates for ClusterInstance %s", clusterInstance.Name))
 
 	// Process node-level templates
 	numNodes := len(clusterInstance.Spec.Nodes)
@@ -73,38 +184,111 @@ func (te *TemplateEngine) ProcessTemplates(
 				clusterInstance.Name, nodeId+1, numNodes))
 
 		// Render node-level templates
-		nodeManifests, err := te.renderTemplates(ctx, c, &clusterInstance, &node)
-		if err != nil {
+		if err := te.renderChangedTemplates(ctx, c, clusterInstance, &node, lastHashes, result); err != nil {
 			te.Log.Info(
 				fmt.Sprintf(
 					"encountered error while processing node-level templates for ClusterInstance %s [%d of %d], err: %s",
 					clusterInstance.Name, nodeId+1, numNodes, err.Error()))
-			return clusterManifests, err
+			errs = append(errs, err)
+			continue
 		}
 		te.Log.Info(fmt.Sprintf(
 			"Processed node-level templates for ClusterInstance %s [node: %d of %d]",
 			clusterInstance.Name, nodeId+1, numNodes))
+	}
+
+	if len(errs) > 0 {
+		result.TemplateHashes = nil
+		return result, errors.Join(errs...)
+	}
+	return result, nil
+}
 
-		for _, nodeCR := range nodeManifests {
-			if nodeCR != nil {
-				clusterManifests = append(clusterManifests, nodeCR)
+func (te *TemplateEngine) renderTemplates(
+	ctx context.Context,
+	c client.Client,
+	clusterInstance *v1alpha1.ClusterInstance,
+	node *v1alpha1.NodeSpec,
+) ([]interface{}, error) {
+	result := &RenderResult{}
+	err := te.renderChangedTemplates(ctx, c, clusterInstance, node, nil, result)
+	return result.Manifests, err
+}
+
+// RenderNodeHardwareManifests renders the node-level templates of the given node and returns the manifests of its
+// hardware resources, i.e. the manifests only rendered for the hosts managed through their BMC
+func (te *TemplateEngine) RenderNodeHardwareManifests(
+	ctx context.Context,
+	c client.Client,
+	clusterInstance v1alpha1.ClusterInstance,
+	node v1alpha1.NodeSpec,
+) ([]interface{}, error) {
+	manifests, err := te.renderTemplates(ctx, c, &clusterInstance, &node)
+	if err != nil {
+		return nil, err
+	}
+
+	var hardware []interface{}
+	for _, manifest := range manifests {
+		if m, ok := manifest.(map[string]interface{}); ok {
+			if kind, ok := m["kind"].(string); ok && bareMetalKinds[kind] {
+				hardware = append(hardware, manifest)
 			}
 		}
 	}
+	return hardware, nil
+}
 
-	return clusterManifests, nil
+// RenderClusterDeployment renders only the cluster-level template declaring the ClusterDeployment kind and returns the
+// ClusterDeployment manifest, nil if none is rendered. The other templates of the ClusterInstance are not rendered.
+func (te *TemplateEngine) RenderClusterDeployment(
+	ctx context.Context,
+	c client.Client,
+	clusterInstance v1alpha1.ClusterInstance,
+) (map[string]interface{}, error) {
+	for _, templateRef := range clusterInstance.Spec.TemplateRefs {
+		templatesConfigMap := &corev1.ConfigMap{}
+		if err := c.Get(ctx, types.NamespacedName{
+			Name:      templateRef.Name,
+			Namespace: templateRef.Namespace,
+		}, templatesConfigMap); err != nil {
+			return nil, fmt.Errorf("templateRef %s/%s: %w", templateRef.Namespace, templateRef.Name, err)
+		}
+
+		for templateKey, template := range templatesConfigMap.Data {
+			if !clusterDeploymentKindRegex.MatchString(template) {
+				continue
+			}
+			manifest, _, err := te.renderManifestFromTemplate(&clusterInstance, nil, templateRef.Name, templateKey,
+				template)
+			if err != nil {
+				return nil, fmt.Errorf("templateRef %s/%s: %w", templateRef.Namespace, templateRef.Name, err)
+			}
+			if manifest != nil && manifest["kind"] == "ClusterDeployment" {
+				return manifest, nil
+			}
+		}
+	}
+	return nil, nil
 }
 
-func (te *TemplateEngine) renderTemplates(
+// renderChangedTemplates renders the templates of the cluster (or of the given node) whose hash differs from the hash
+// recorded in lastHashes, and adds the rendered manifests, the hashes of all the templates and the render status of
+// each TemplateRef to the given result. The render status of an unchanged TemplateRef is carried over from the
+// ClusterInstance status.
+func (te *TemplateEngine) renderChangedTemplates(
 	ctx context.Context,
 	c client.Client,
 	clusterInstance *v1alpha1.ClusterInstance,
 	node *v1alpha1.NodeSpec,
-) ([]interface{}, error) {
+	lastHashes map[string]string,
+	result *RenderResult,
+) error {
 
 	var (
-		manifests    []interface{}
 		templateRefs []v1alpha1.TemplateRef
+		hostName     string
+		errs         []error
 	)
 
 	// Determine whether templateRefs are cluster-based or node-based
@@ -114,52 +298,149 @@ func (te *TemplateEngine) renderTemplates(
 	} else {
 		// use node-level values
 		templateRefs = node.TemplateRefs
+		hostName = node.HostName
+	}
+
+	if len(templateRefs) == 0 {
+		return nil
+	}
+
+	clusterData, err := buildClusterData(clusterInstance, node)
+	if err != nil {
+		return err
 	}
 
 	for tId, templateRef := range templateRefs {
 		te.Log.Info(fmt.Sprintf("renderTemplates: processing templateRef %d of %d", tId+1, len(templateRefs)))
 
-		templatesConfigMap := &corev1.ConfigMap{}
-		if err := c.Get(ctx, types.NamespacedName{
+		templateStatus := v1alpha1.TemplateRenderStatus{
 			Name:      templateRef.Name,
 			Namespace: templateRef.Namespace,
-		}, templatesConfigMap); err != nil {
-			te.Log.Info(fmt.Sprintf("renderTemplates: failed to get ConfigMap, err: %s", err.Error()))
-			return manifests, err
+			HostName:  hostName,
 		}
+		manifests, hash, empty, err := te.renderTemplateRef(ctx, c, clusterInstance, node, templateRef, clusterData,
+			lastHashes)
+		switch {
+		case err != nil:
+			templateStatus.Error = err.Error()
+			errs = append(errs, fmt.Errorf("templateRef %s/%s: %w", templateRef.Namespace, templateRef.Name, err))
+		case lastHashes[templateHashKey(templateRef, hostName)] == hash:
+			// The templates are unchanged, keep their last render status
+			if last := findTemplateStatus(clusterInstance.Status.TemplateStatus, templateStatus); last != nil {
+				templateStatus = *last
+			}
+		default:
+			templateStatus.RenderedObjects = len(manifests)
+			templateStatus.Empty = empty
+		}
+		result.TemplateStatus = append(result.TemplateStatus, templateStatus)
+
+		if hash != "" {
+			result.TemplateHashes = append(result.TemplateHashes, v1alpha1.RenderedTemplateHash{
+				TemplateRef: templateRef,
+				HostName:    hostName,
+				Hash:        hash,
+			})
+		}
+		result.Manifests = append(result.Manifests, manifests...)
+	}
+	return errors.Join(errs...)
+}
 
-		// process Template ConfigMap
-		for templateKey, template := range templatesConfigMap.Data {
+// renderTemplateRef renders the templates of the given TemplateRef and returns the rendered manifests along with the
+// hash of the templates. Nil manifests are returned when the hash matches the one recorded in lastHashes. The returned
+// empty flag is set when none of the templates rendered any content, unless the ConfigMap has the
+// AllowEmptyRenderAnnotation. Manifests skipped or suppressed after being rendered do not count as empty.
+func (te *TemplateEngine) renderTemplateRef(
+	ctx context.Context,
+	c client.Client,
+	clusterInstance *v1alpha1.ClusterInstance,
+	node *v1alpha1.NodeSpec,
+	templateRef v1alpha1.TemplateRef,
+	clusterData *ClusterData,
+	lastHashes map[string]string,
+) ([]interface{}, string, bool, error) {
 
-			manifest, err := te.renderManifestFromTemplate(
-				clusterInstance,
-				node,
-				templateRef.Name,
-				templateKey,
-				template)
-			if err != nil {
-				return nil, err
-			}
-			if manifest != nil {
-				manifests = append(manifests, manifest)
-			}
+	hostName := ""
+	if node != nil {
+		hostName = node.HostName
+	}
+
+	templatesConfigMap := &corev1.ConfigMap{}
+	if err := c.Get(ctx, types.NamespacedName{
+		Name:      templateRef.Name,
+		Namespace: templateRef.Namespace,
+	}, templatesConfigMap); err != nil {
+		te.Log.Info(fmt.Sprintf("renderTemplates: failed to get ConfigMap, err: %s", err.Error()))
+		return nil, "", false, err
+	}
+
+	hash, err := computeTemplateHash(templatesConfigMap.Data, clusterData)
+	if err != nil {
+		return nil, "", false, err
+	}
+	if lastHashes[templateHashKey(templateRef, hostName)] == hash {
+		te.Log.Info(fmt.Sprintf("renderTemplates: skipping unchanged templateRef %s/%s",
+			templateRef.Namespace, templateRef.Name))
+		return nil, hash, false, nil
+	}
+
+	// process Template ConfigMap
+	var manifests []interface{}
+	empty := templatesConfigMap.Annotations[AllowEmptyRenderAnnotation] != "true"
+	for templateKey, template := range templatesConfigMap.Data {
+
+		manifest, rendered, err := te.renderManifestFromTemplate(
+			clusterInstance,
+			node,
+			templateRef.Name,
+			templateKey,
+			template)
+		if err != nil {
+			return nil, "", false, err
+		}
+		if rendered {
+			empty = false
+		}
+		if manifest != nil {
+			manifests = append(manifests, manifest)
+		}
+	}
+	if empty {
+		te.Log.Info(fmt.Sprintf("renderTemplates: templateRef %s/%s rendered no content",
+			templateRef.Namespace, templateRef.Name))
+	}
+	return manifests, hash, empty, nil
+}
+
+// findTemplateStatus returns the status of the same TemplateRef and host name as the given one, nil if there is none
+func findTemplateStatus(
+	statuses []v1alpha1.TemplateRenderStatus,
+	templateStatus v1alpha1.TemplateRenderStatus,
+) *v1alpha1.TemplateRenderStatus {
+	for i := range statuses {
+		if statuses[i].Name == templateStatus.Name && statuses[i].Namespace == templateStatus.Namespace &&
+			statuses[i].HostName == templateStatus.HostName {
+			return &statuses[i]
 		}
 	}
-	return manifests, nil
+	return nil
 }
 
+// renderManifestFromTemplate renders the given template and returns the resulting manifest, nil if the manifest is
+// skipped or suppressed. The returned rendered flag is set when the template rendered some content.
 func (te *TemplateEngine) renderManifestFromTemplate(
 	clusterInstance *v1alpha1.ClusterInstance,
 	node *v1alpha1.NodeSpec,
 	templateRefName, templateKey, template string,
-) (map[string]interface{}, error) {
+) (map[string]interface{}, bool, error) {
 
 	clusterData, err := buildClusterData(clusterInstance, node)
 	if err != nil {
 		te.Log.Error(err,
 			fmt.Sprintf("renderTemplates: failed to build ClusterInstance data for ClusterInstance %s",
 				clusterInstance.Name))
-		return nil, err
+		return nil, false, err
 	}
 
 	manifest, err := te.render(templateKey, template, clusterData)
@@ -167,11 +448,11 @@ func (te *TemplateEngine) renderManifestFromTemplate(
 		te.Log.Error(err,
 			fmt.Sprintf("renderTemplates: failed to render templateRef %s for ClusterInstance %s",
 				templateRefName, clusterInstance.Name))
-		return nil, err
+		return nil, false, err
 	}
 
 	if manifest == nil {
-		return nil, nil
+		return nil, false, nil
 	}
 
 	var (
@@ -179,7 +460,7 @@ func (te *TemplateEngine) renderManifestFromTemplate(
 		ok   bool
 	)
 	if kind, ok = manifest["kind"].(string); !ok {
-		return nil, fmt.Errorf("missing kind in template %s", templateKey)
+		return nil, true, fmt.Errorf("missing kind in template %s", templateKey)
 	}
 
 	suppressedManifests := clusterInstance.Spec.SuppressedManifests
@@ -187,10 +468,22 @@ func (te *TemplateEngine) renderManifestFromTemplate(
 		suppressedManifests = append(suppressedManifests, node.SuppressedManifests...)
 	}
 
+	if bareMetalKinds[kind] && clusterInstance.Spec.GetPlatformType() != v1alpha1.PlatformTypeBareMetal {
+		te.Log.Info(fmt.Sprintf("renderTemplates: skipping manifest %s for the %s platform of ClusterInstance %s",
+			kind, clusterInstance.Spec.GetPlatformType(), clusterInstance.Name))
+		return nil, true, nil
+	}
+
+	if kind == "InfraEnv" && clusterInstance.Spec.InfraEnvRef != nil {
+		te.Log.Info(fmt.Sprintf("renderTemplates: skipping manifest %s, ClusterInstance %s references InfraEnv %s",
+			kind, clusterInstance.Name, clusterInstance.Spec.InfraEnvRef.Name))
+		return nil, true, nil
+	}
+
 	if suppressManifest(kind, suppressedManifests) {
 		te.Log.Info(fmt.Sprintf("renderTemplates: suppressing manifest %s for ClusterInstance %s",
 			kind, clusterInstance.Name))
-		return nil, nil
+		return nil, true, nil
 	}
 
 	if node == nil {
@@ -203,9 +496,30 @@ func (te *TemplateEngine) renderManifestFromTemplate(
 		if extraManifestAnnotations, ok := node.ExtraAnnotationSearch(kind, &clusterInstance.Spec); ok {
 			manifest = appendManifestAnnotations(extraManifestAnnotations, manifest)
 		}
+		// Set the node annotations on the BareMetalHost of the node
+		if kind == "BareMetalHost" {
+			manifest = appendManifestAnnotations(NodeAnnotations(node), manifest)
+		}
 	}
 
-	return manifest, nil
+	// Propagate the chargeback metadata as labels
+	manifest = appendManifestLabels(clusterInstance.Spec.ChargebackMetadata, manifest)
+
+	return manifest, true, nil
+}
+
+// RenderError is a failure to render a template, e.g. a syntax error or a missing value, which persists until the
+// template or the ClusterInstance is changed
+type RenderError struct {
+	Err error
+}
+
+func (e *RenderError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RenderError) Unwrap() error {
+	return e.Err
 }
 
 func (te *TemplateEngine) render(templateKey, templateStr string, data *ClusterData) (map[string]interface{}, error) {
@@ -214,20 +528,20 @@ func (te *TemplateEngine) render(templateKey, templateStr string, data *ClusterD
 	fMap := funcMap()
 	t, err := template.New(templateKey).Funcs(fMap).Parse(templateStr)
 	if err != nil {
-		return nil, err
+		return nil, &RenderError{Err: templateParseError(templateKey, err)}
 	}
 
 	var buffer bytes.Buffer
 	err = t.Execute(&buffer, data)
 	if err != nil {
-		return nil, err
+		return nil, &RenderError{Err: err}
 	}
 
 	// Ensure there's non-whitespace content
 	for _, r := range buffer.String() {
 		if !unicode.IsSpace(r) {
 			if err := yaml.Unmarshal(buffer.Bytes(), &renderedTemplate); err != nil {
-				return renderedTemplate, err
+				return renderedTemplate, &RenderError{Err: err}
 			}
 			return renderedTemplate, nil
 		}
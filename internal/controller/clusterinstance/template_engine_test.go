@@ -18,6 +18,8 @@ package clusterinstance
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"reflect"
 	"testing"
 
@@ -25,6 +27,9 @@ import (
 	bmh_v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	aiv1beta1 "github.com/openshift/assisted-service/api/v1beta1"
 	"github.com/stolostron/siteconfig/api/v1alpha1"
+	assistedinstaller "github.com/stolostron/siteconfig/internal/templates/assisted-installer"
+	imagebasedinstall "github.com/stolostron/siteconfig/internal/templates/image-based-install"
+	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -191,6 +196,45 @@ func TestTemplateEngine_render(t *testing.T) {
 	}
 }
 
+func TestTemplateEngine_renderFunctions(t *testing.T) {
+	data := &ClusterData{Spec: v1alpha1.ClusterInstanceSpec{
+		ClusterName:   "site-sno-du-1",
+		ClusterLabels: map[string]string{"common": "true"},
+	}}
+	tmplEngine := &TemplateEngine{}
+
+	t.Run("helper functions are available", func(t *testing.T) {
+		templateStr := `kind: ConfigMap
+data:
+  baseDomain: {{ .Spec.BaseDomain | default "example.com" }}
+  clusterName: {{ .Spec.ClusterName | b64enc }}
+  labels: {{ .Spec.ClusterLabels | toYaml | quote }}
+  upper: {{ .Spec.ClusterName | upper }}`
+		got, err := tmplEngine.render("ConfigMap", templateStr, data)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"baseDomain":  "example.com",
+			"clusterName": "c2l0ZS1zbm8tZHUtMQ==",
+			"labels":      "common: \"true\"",
+			"upper":       "SITE-SNO-DU-1",
+		}, got["data"])
+	})
+
+	for _, function := range disallowedTemplateFunctions {
+		t.Run("disallowed function "+function, func(t *testing.T) {
+			templateStr := fmt.Sprintf("kind: ConfigMap\ndata:\n  value: {{ %s \"HOME\" }}", function)
+			_, err := tmplEngine.render("ConfigMap", templateStr, data)
+			assert.ErrorContains(t, err, fmt.Sprintf("template ConfigMap uses the disallowed function %q", function))
+		})
+	}
+
+	t.Run("undefined functions are reported as is", func(t *testing.T) {
+		_, err := tmplEngine.render("ConfigMap", "kind: {{ foobar }}", data)
+		assert.ErrorContains(t, err, `function "foobar" not defined`)
+		assert.NotContains(t, err.Error(), "disallowed")
+	})
+}
+
 var _ = Describe("renderTemplates", func() {
 	var (
 		c                   client.Client
@@ -264,6 +308,8 @@ var _ = Describe("renderTemplates", func() {
 		_, err := tmplEngine.renderTemplates(ctx, c, TestClusterInstance, nil)
 		Expect(err).To(HaveOccurred())
 		Expect(err).To(MatchError(ContainSubstring("field doesNotExist")))
+		var renderErr *RenderError
+		Expect(errors.As(err, &renderErr)).To(BeTrue())
 	})
 
 	It("suppresses rendering manifests at cluster-level", func() {
@@ -327,6 +373,279 @@ var _ = Describe("renderTemplates", func() {
 		}))
 	})
 
+	It("skips rendering BareMetalHost manifests for the None platform", func() {
+
+		node := &TestClusterInstance.Spec.Nodes[0]
+		node.TemplateRefs = []v1alpha1.TemplateRef{
+			{Name: "node-level", Namespace: "test"},
+		}
+
+		nodeTemplates := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-level", Namespace: "test"},
+			Data: map[string]string{
+				"BareMetalHost": GetMockBasicNodeTemplate("BareMetalHost"),
+				"TestD":         GetMockBasicNodeTemplate("TestD"),
+			},
+		}
+		Expect(c.Create(ctx, nodeTemplates)).To(Succeed())
+
+		// The BareMetalHost is rendered for the (default) BareMetal platform
+		got, err := tmplEngine.renderTemplates(ctx, c, TestClusterInstance, node)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(HaveLen(2))
+
+		TestClusterInstance.Spec.PlatformType = v1alpha1.PlatformTypeNone
+		got, err = tmplEngine.renderTemplates(ctx, c, TestClusterInstance, node)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(HaveLen(1))
+		Expect(got[0]).To(HaveKeyWithValue("kind", "TestD"))
+	})
+
+	It("renders only the hardware manifests of a node", func() {
+		node := &TestClusterInstance.Spec.Nodes[0]
+		node.TemplateRefs = []v1alpha1.TemplateRef{
+			{Name: "node-level", Namespace: "test"},
+		}
+
+		nodeTemplates := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-level", Namespace: "test"},
+			Data: map[string]string{
+				"BareMetalHost":        GetMockBasicNodeTemplate("BareMetalHost"),
+				"HostFirmwareSettings": GetMockBasicNodeTemplate("HostFirmwareSettings"),
+				"NMStateConfig":        GetMockBasicNodeTemplate("NMStateConfig"),
+			},
+		}
+		Expect(c.Create(ctx, nodeTemplates)).To(Succeed())
+
+		got, err := tmplEngine.RenderNodeHardwareManifests(ctx, c, *TestClusterInstance, *node)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(ConsistOf(
+			HaveKeyWithValue("kind", "BareMetalHost"),
+			HaveKeyWithValue("kind", "HostFirmwareSettings")))
+	})
+
+	It("propagates the installAttemptsLimit to the ClusterDeployment only when set", func() {
+		TestClusterInstance.Spec.TemplateRefs = []v1alpha1.TemplateRef{
+			{Name: "cluster-level", Namespace: "test"},
+		}
+
+		clusterTemplates := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-level", Namespace: "test"},
+			Data: map[string]string{
+				"ClusterDeployment": assistedinstaller.ClusterDeployment,
+			},
+		}
+		Expect(c.Create(ctx, clusterTemplates)).To(Succeed())
+
+		got, err := tmplEngine.renderTemplates(ctx, c, TestClusterInstance, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(HaveLen(1))
+		Expect(got[0]).To(HaveKeyWithValue("spec", Not(HaveKey("installAttemptsLimit"))))
+
+		limit := int32(3)
+		TestClusterInstance.Spec.InstallAttemptsLimit = &limit
+		got, err = tmplEngine.renderTemplates(ctx, c, TestClusterInstance, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(HaveLen(1))
+		Expect(got[0]).To(HaveKeyWithValue("spec", HaveKeyWithValue("installAttemptsLimit", 3)))
+	})
+
+	It("renders the disk encryption of the AgentClusterInstall", func() {
+		TestClusterInstance.Spec.TemplateRefs = []v1alpha1.TemplateRef{
+			{Name: "cluster-level", Namespace: "test"},
+		}
+
+		clusterTemplates := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-level", Namespace: "test"},
+			Data: map[string]string{
+				"AgentClusterInstall": assistedinstaller.AgentClusterInstall,
+			},
+		}
+		Expect(c.Create(ctx, clusterTemplates)).To(Succeed())
+
+		TestClusterInstance.Spec.DiskEncryption = nil
+		got, err := tmplEngine.renderTemplates(ctx, c, TestClusterInstance, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(HaveLen(1))
+		Expect(got[0]).To(HaveKeyWithValue("spec", Not(HaveKey("diskEncryption"))))
+
+		TestClusterInstance.Spec.DiskEncryption = &v1alpha1.DiskEncryption{
+			Type:     v1alpha1.DiskEncryptionTypeTPM2,
+			EnableOn: "workers",
+		}
+		got, err = tmplEngine.renderTemplates(ctx, c, TestClusterInstance, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(HaveLen(1))
+		Expect(got[0]).To(HaveKeyWithValue("spec", HaveKeyWithValue("diskEncryption", map[string]interface{}{
+			"enableOn": "workers",
+			"mode":     "tpmv2",
+		})))
+	})
+
+	It("renders the boot artifacts URL of the InfraEnv", func() {
+		TestClusterInstance.Spec.TemplateRefs = []v1alpha1.TemplateRef{
+			{Name: "cluster-level", Namespace: "test"},
+		}
+
+		clusterTemplates := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-level", Namespace: "test"},
+			Data: map[string]string{
+				"InfraEnv": assistedinstaller.InfraEnv,
+			},
+		}
+		Expect(c.Create(ctx, clusterTemplates)).To(Succeed())
+
+		got, err := tmplEngine.renderTemplates(ctx, c, TestClusterInstance, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(HaveLen(1))
+		Expect(got[0]).To(HaveKeyWithValue("spec", Not(HaveKey("kernelArguments"))))
+
+		TestClusterInstance.Spec.BootArtifactsBaseURL = "https://images.example.com/boot-artifacts"
+		got, err = tmplEngine.renderTemplates(ctx, c, TestClusterInstance, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(HaveLen(1))
+		Expect(got[0]).To(HaveKeyWithValue("spec", HaveKeyWithValue("kernelArguments", []interface{}{
+			map[string]interface{}{
+				"operation": "append",
+				"value":     "coreos.live.rootfs_url=https://images.example.com/boot-artifacts/rootfs.img",
+			},
+		})))
+	})
+
+	It("binds the nodes to the referenced InfraEnv instead of rendering one", func() {
+		TestClusterInstance.Spec.InfraEnvRef = &corev1.LocalObjectReference{Name: "shared-infraenv"}
+		TestClusterInstance.Spec.TemplateRefs = []v1alpha1.TemplateRef{
+			{Name: "cluster-level", Namespace: "test"},
+		}
+		node := &TestClusterInstance.Spec.Nodes[0]
+		node.TemplateRefs = []v1alpha1.TemplateRef{
+			{Name: "node-level", Namespace: "test"},
+		}
+		netConfig := GetMockNetConfig()
+		node.NodeNetwork = &aiv1beta1.NMStateConfigSpec{
+			NetConfig:  aiv1beta1.NetConfig{Raw: []byte(netConfig.RawNetConfig())},
+			Interfaces: netConfig.Interfaces,
+		}
+
+		Expect(c.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-level", Namespace: "test"},
+			Data: map[string]string{
+				"InfraEnv": assistedinstaller.InfraEnv,
+				"TestA":    GetMockBasicClusterTemplate("TestA"),
+			},
+		})).To(Succeed())
+		Expect(c.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-level", Namespace: "test"},
+			Data: map[string]string{
+				"BareMetalHost": assistedinstaller.BareMetalHost,
+				"NMStateConfig": assistedinstaller.NMStateConfig,
+			},
+		})).To(Succeed())
+
+		got, err := tmplEngine.renderTemplates(ctx, c, TestClusterInstance, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(HaveLen(1))
+		Expect(got[0]).To(HaveKeyWithValue("kind", "TestA"))
+
+		got, err = tmplEngine.renderTemplates(ctx, c, TestClusterInstance, node)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(HaveLen(2))
+		// The BareMetalHost is bound to the InfraEnv, which selects the NMStateConfig by its label
+		Expect(got).To(ContainElement(And(HaveKeyWithValue("kind", "BareMetalHost"),
+			HaveKeyWithValue("metadata", HaveKeyWithValue("labels",
+				HaveKeyWithValue("infraenvs.agent-install.openshift.io", "shared-infraenv"))))))
+		Expect(got).To(ContainElement(And(HaveKeyWithValue("kind", "NMStateConfig"),
+			HaveKeyWithValue("metadata", HaveKeyWithValue("labels",
+				HaveKeyWithValue("nmstate-label", "shared-infraenv"))))))
+	})
+
+	It("renders the template values", func() {
+		TestClusterInstance.Spec.TemplateRefs = []v1alpha1.TemplateRef{
+			{Name: "cluster-level", Namespace: "test"},
+		}
+
+		clusterTemplates := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-level", Namespace: "test"},
+			Data: map[string]string{
+				"TestA": `apiVersion: test.io/v1
+kind: TestA
+metadata:
+  name: "{{ .Values.clusterName }}"
+  labels:
+    site-id: "{{ .Values.siteId }}"`,
+			},
+		}
+		Expect(c.Create(ctx, clusterTemplates)).To(Succeed())
+
+		TestClusterInstance.Spec.TemplateValues = map[string]string{"siteId": "du-1"}
+		got, err := tmplEngine.renderTemplates(ctx, c, TestClusterInstance, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(HaveLen(1))
+		Expect(got[0]).To(HaveKeyWithValue("metadata", SatisfyAll(
+			HaveKeyWithValue("name", "site-sno-du-1"),
+			HaveKeyWithValue("labels", HaveKeyWithValue("site-id", "du-1")))))
+	})
+
+	DescribeTable("propagates preserveOnDelete to the ClusterDeployment only when set",
+		func(clusterDeploymentTemplate string) {
+			TestClusterInstance.Spec.TemplateRefs = []v1alpha1.TemplateRef{
+				{Name: "cluster-level", Namespace: "test"},
+			}
+
+			clusterTemplates := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster-level", Namespace: "test"},
+				Data: map[string]string{
+					"ClusterDeployment": clusterDeploymentTemplate,
+				},
+			}
+			Expect(c.Create(ctx, clusterTemplates)).To(Succeed())
+
+			got, err := tmplEngine.renderTemplates(ctx, c, TestClusterInstance, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(got).To(HaveLen(1))
+			Expect(got[0]).To(HaveKeyWithValue("spec", Not(HaveKey("preserveOnDelete"))))
+
+			TestClusterInstance.Spec.PreserveOnDelete = true
+			got, err = tmplEngine.renderTemplates(ctx, c, TestClusterInstance, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(got).To(HaveLen(1))
+			Expect(got[0]).To(HaveKeyWithValue("spec", HaveKeyWithValue("preserveOnDelete", true)))
+		},
+		Entry("assisted installer", assistedinstaller.ClusterDeployment),
+		Entry("image-based install", imagebasedinstall.ClusterDeployment),
+	)
+
+	DescribeTable("propagates the node labels to the BareMetalHost node-label annotations",
+		func(bareMetalHostTemplate string) {
+			node := &TestClusterInstance.Spec.Nodes[0]
+			node.TemplateRefs = []v1alpha1.TemplateRef{
+				{Name: "node-level", Namespace: "test"},
+			}
+			node.NodeLabels = map[string]string{
+				"node-role.kubernetes.io/infra": "",
+				"environment":                   "production",
+			}
+
+			nodeTemplates := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-level", Namespace: "test"},
+				Data: map[string]string{
+					"BareMetalHost": bareMetalHostTemplate,
+				},
+			}
+			Expect(c.Create(ctx, nodeTemplates)).To(Succeed())
+
+			got, err := tmplEngine.renderTemplates(ctx, c, TestClusterInstance, node)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(got).To(HaveLen(1))
+			Expect(got[0]).To(HaveKeyWithValue("metadata", HaveKeyWithValue("annotations", And(
+				HaveKeyWithValue("bmac.agent-install.openshift.io.node-label.node-role.kubernetes.io/infra", ""),
+				HaveKeyWithValue("bmac.agent-install.openshift.io.node-label.environment", "production"),
+			))))
+		},
+		Entry("assisted installer", assistedinstaller.BareMetalHost),
+		Entry("image-based install", imagebasedinstall.BareMetalHost),
+	)
+
 	It("renders a cluster-level template with extra annotations", func() {
 
 		TestClusterInstance.Spec.TemplateRefs = []v1alpha1.TemplateRef{
@@ -366,6 +685,42 @@ var _ = Describe("renderTemplates", func() {
 		}))
 	})
 
+	It("renders the extra annotations of the ClusterDeployment on the ClusterDeployment only", func() {
+		TestClusterInstance.Spec.TemplateRefs = []v1alpha1.TemplateRef{
+			{Name: "cluster-level", Namespace: "test"},
+		}
+
+		clusterTemplates := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-level", Namespace: "test"},
+			Data: map[string]string{
+				"ClusterDeployment": GetMockBasicClusterTemplate("ClusterDeployment"),
+				"ManagedCluster":    GetMockBasicClusterTemplate("ManagedCluster"),
+			},
+		}
+		Expect(c.Create(ctx, clusterTemplates)).To(Succeed())
+
+		TestClusterInstance.Spec.ExtraAnnotations = map[string]map[string]string{
+			"ClusterDeployment": {
+				"argocd.argoproj.io/sync-wave": "1",
+			},
+		}
+		got, err := tmplEngine.renderTemplates(ctx, c, TestClusterInstance, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(got).To(HaveLen(2))
+		for _, manifest := range got {
+			manifest := manifest.(map[string]interface{})
+			metadata, _ := manifest["metadata"].(map[string]interface{})
+			if manifest["kind"] == "ClusterDeployment" {
+				Expect(metadata).To(HaveKeyWithValue("annotations", map[string]interface{}{
+					"argocd.argoproj.io/sync-wave": "1",
+				}))
+			} else {
+				Expect(metadata).ToNot(HaveKey("annotations"))
+			}
+		}
+	})
+
 	It("renders a node-level template with extra annotations defined at cluster-level", func() {
 		node := &TestClusterInstance.Spec.Nodes[0]
 		node.TemplateRefs = []v1alpha1.TemplateRef{
@@ -532,6 +887,90 @@ var _ = Describe("ProcessTemplates", func() {
 		Expect(err).To(MatchError(ContainSubstring("can't evaluate field")))
 	})
 
+	It("reports the render status of each TemplateRef after a mixed success and failure render", func() {
+		Expect(c.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-level", Namespace: "test"},
+			Data: map[string]string{
+				"TestA": GetMockBasicClusterTemplate("TestA"),
+				"TestB": GetMockBasicClusterTemplate("TestB"),
+			},
+		})).To(Succeed())
+		Expect(c.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-level-bad", Namespace: "test"},
+			Data: map[string]string{
+				"TestC": `{{.foobar}}`,
+			},
+		})).To(Succeed())
+		Expect(c.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-level", Namespace: "test"},
+			Data: map[string]string{
+				"TestD": GetMockBasicNodeTemplate("TestD"),
+			},
+		})).To(Succeed())
+
+		TestClusterInstance.Spec.TemplateRefs = []v1alpha1.TemplateRef{
+			{Name: "cluster-level", Namespace: "test"},
+			{Name: "cluster-level-bad", Namespace: "test"},
+		}
+		TestClusterInstance.Spec.Nodes[0].TemplateRefs = []v1alpha1.TemplateRef{
+			{Name: "node-level", Namespace: "test"},
+			{Name: "does-not-exist", Namespace: "test"},
+		}
+
+		result, err := tmplEngine.ProcessChangedTemplates(ctx, c, TestClusterInstance)
+		Expect(err).To(HaveOccurred())
+		Expect(err).To(MatchError(ContainSubstring("templateRef test/cluster-level-bad")))
+		Expect(err).To(MatchError(ContainSubstring("templateRef test/does-not-exist")))
+		Expect(result.TemplateHashes).To(BeEmpty())
+
+		Expect(result.TemplateStatus).To(HaveLen(4))
+		Expect(result.TemplateStatus[0]).To(Equal(v1alpha1.TemplateRenderStatus{
+			Name: "cluster-level", Namespace: "test", RenderedObjects: 2}))
+		Expect(result.TemplateStatus[1].Name).To(Equal("cluster-level-bad"))
+		Expect(result.TemplateStatus[1].RenderedObjects).To(BeZero())
+		Expect(result.TemplateStatus[1].Error).To(ContainSubstring("can't evaluate field"))
+		Expect(result.TemplateStatus[2]).To(Equal(v1alpha1.TemplateRenderStatus{
+			Name: "node-level", Namespace: "test", HostName: "node1", RenderedObjects: 1}))
+		Expect(result.TemplateStatus[3].Name).To(Equal("does-not-exist"))
+		Expect(result.TemplateStatus[3].HostName).To(Equal("node1"))
+		Expect(result.TemplateStatus[3].Error).To(ContainSubstring("not found"))
+	})
+
+	It("marks the TemplateRefs whose templates render no content as empty", func() {
+		Expect(c.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "empty", Namespace: "test"},
+			Data:       map[string]string{"TestA": `{{ if false }}kind: TestA{{ end }}`},
+		})).To(Succeed())
+		Expect(c.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "allowed-empty",
+				Namespace:   "test",
+				Annotations: map[string]string{AllowEmptyRenderAnnotation: "true"},
+			},
+			Data: map[string]string{"TestB": ""},
+		})).To(Succeed())
+		Expect(c.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "suppressed", Namespace: "test"},
+			Data:       map[string]string{"TestC": GetMockBasicClusterTemplate("TestC")},
+		})).To(Succeed())
+
+		TestClusterInstance.Spec.TemplateRefs = []v1alpha1.TemplateRef{
+			{Name: "empty", Namespace: "test"},
+			{Name: "allowed-empty", Namespace: "test"},
+			{Name: "suppressed", Namespace: "test"},
+		}
+		TestClusterInstance.Spec.SuppressedManifests = []string{"TestC"}
+
+		result, err := tmplEngine.ProcessChangedTemplates(ctx, c, TestClusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Manifests).To(BeEmpty())
+		Expect(result.TemplateStatus).To(Equal([]v1alpha1.TemplateRenderStatus{
+			{Name: "empty", Namespace: "test", Empty: true},
+			{Name: "allowed-empty", Namespace: "test"},
+			{Name: "suppressed", Namespace: "test"},
+		}))
+	})
+
 	It("successfully processes cluster and node level templates with manifest suppression", func() {
 
 		// Define and create cluster-level template refs
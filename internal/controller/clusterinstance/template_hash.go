@@ -0,0 +1,58 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+)
+
+// templateHashInputs is the content hashed to detect whether the templates of a TemplateRef must be re-rendered
+type templateHashInputs struct {
+	Templates map[string]string `json:"templates"`
+	Data      *ClusterData      `json:"data"`
+}
+
+// computeTemplateHash returns the hash of the templates of a TemplateRef ConfigMap together with the rendering
+// context, any change to either one results in a different hash
+func computeTemplateHash(templates map[string]string, data *ClusterData) (string, error) {
+	// json.Marshal sorts the map keys, hence the serialization is deterministic
+	content, err := json.Marshal(templateHashInputs{Templates: templates, Data: data})
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize template hash inputs: %w", err)
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// templateHashKey identifies the rendering of a TemplateRef, for the cluster or for a given node
+func templateHashKey(templateRef v1alpha1.TemplateRef, hostName string) string {
+	return templateRef.Namespace + "/" + templateRef.Name + "/" + hostName
+}
+
+// templateHashIndex indexes the given template hashes by templateHashKey
+func templateHashIndex(hashes []v1alpha1.RenderedTemplateHash) map[string]string {
+	index := make(map[string]string, len(hashes))
+	for _, h := range hashes {
+		index[templateHashKey(h.TemplateRef, h.HostName)] = h.Hash
+	}
+	return index
+}
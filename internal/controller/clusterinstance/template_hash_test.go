@@ -0,0 +1,46 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinstance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+)
+
+func Test_computeTemplateHash(t *testing.T) {
+	templates := map[string]string{"a": "kind: A", "b": "kind: B"}
+	data := &ClusterData{Spec: v1alpha1.ClusterInstanceSpec{ClusterName: "test-cluster"}}
+
+	hash, err := computeTemplateHash(templates, data)
+	assert.NoError(t, err)
+
+	same, err := computeTemplateHash(map[string]string{"b": "kind: B", "a": "kind: A"}, data)
+	assert.NoError(t, err)
+	assert.Equal(t, hash, same, "hash must not depend on the map ordering")
+
+	changedTemplate, err := computeTemplateHash(map[string]string{"a": "kind: A", "b": "kind: C"}, data)
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, changedTemplate)
+
+	changedData, err := computeTemplateHash(templates,
+		&ClusterData{Spec: v1alpha1.ClusterInstanceSpec{ClusterName: "other-cluster"}})
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, changedData)
+}
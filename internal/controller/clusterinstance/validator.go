@@ -18,16 +18,79 @@ package clusterinstance
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	aiv1beta1 "github.com/openshift/assisted-service/api/v1beta1"
 	hivev1 "github.com/openshift/hive/apis/hive/v1"
 	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	"golang.org/x/exp/slices"
 	corev1 "k8s.io/api/core/v1"
 )
 
+// The following constants define the names of the validations that can be skipped using Spec.ValidationOverrides
+const (
+	BaseDomainValidation = "baseDomain"
+	NTPSourcesValidation = "ntpSources"
+)
+
+// overridableValidations maps the names of the validations that can be skipped to their implementation
+var overridableValidations = map[string]func(*v1alpha1.ClusterInstance) error{
+	BaseDomainValidation: validateBaseDomain,
+	NTPSourcesValidation: validateNTPSources,
+}
+
+// FIPSMinimumRelease is the earliest major.minor release that supports installing a cluster in FIPS mode
+var FIPSMinimumRelease = [2]int{4, 12}
+
+// releaseVersionRegex matches the major.minor version at the start of a release image tag
+var releaseVersionRegex = regexp.MustCompile(`^v?(\d+)\.(\d+)`)
+
+// macAddressRegex matches a MAC address in the colon-separated hexadecimal notation, e.g. 00:00:5E:00:53:00
+var macAddressRegex = regexp.MustCompile(`^[0-9a-fA-F]{2}(:[0-9a-fA-F]{2}){5}$`)
+
+// ValidationError is a validation failure that carries the reason to report in the ClusterInstanceValidated condition
+type ValidationError struct {
+	Reason conditions.ConditionReason
+	Err    error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+func newValidationError(reason conditions.ConditionReason, format string, args ...interface{}) error {
+	return &ValidationError{Reason: reason, Err: fmt.Errorf(format, args...)}
+}
+
+// ValidationFailureReason returns the condition reason of a validation failure, defaulting to Failed
+func ValidationFailureReason(err error) conditions.ConditionReason {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr.Reason
+	}
+	return conditions.Failed
+}
+
 func validateResources(ctx context.Context, c client.Client, clusterInstance *v1alpha1.ClusterInstance) error {
 	if clusterInstance.Spec.ClusterImageSetNameRef == "" {
 		return fmt.Errorf("clusterImageSetNameRef cannot be empty")
@@ -40,12 +103,18 @@ func validateResources(ctx context.Context, c client.Client, clusterInstance *v1
 			clusterInstance.Spec.ClusterImageSetNameRef, err)
 	}
 
-	// Check that pull secret exists in cluster namespace
-	pullSecret := &corev1.Secret{}
-	key = types.NamespacedName{Name: clusterInstance.Spec.PullSecretRef.Name, Namespace: clusterInstance.Namespace}
-	if err := c.Get(ctx, key, pullSecret); err != nil {
-		return fmt.Errorf("failed to validate Pull Secret: [%s in namespace %s], err: %w",
-			key.Name, key.Namespace, err)
+	if err := validateFIPS(clusterInstance, &clusterImageSet); err != nil {
+		return err
+	}
+
+	// Check that the pull secrets exist in cluster namespace
+	for _, pullSecretRef := range PullSecretRefs(clusterInstance) {
+		pullSecret := &corev1.Secret{}
+		key = types.NamespacedName{Name: pullSecretRef.Name, Namespace: clusterInstance.Namespace}
+		if err := c.Get(ctx, key, pullSecret); err != nil {
+			return fmt.Errorf("failed to validate Pull Secret: [%s in namespace %s], err: %w",
+				key.Name, key.Namespace, err)
+		}
 	}
 
 	// If extraManifests are defined - check that they exist
@@ -60,14 +129,289 @@ func validateResources(ctx context.Context, c client.Client, clusterInstance *v1
 		}
 	}
 
-	// Check that node BMC secrets exist in namespace
+	// Check that the referenced InfraEnv exists in the cluster namespace
+	if clusterInstance.Spec.InfraEnvRef != nil {
+		key = types.NamespacedName{Name: InfraEnvName(clusterInstance), Namespace: InfraEnvNamespace(clusterInstance)}
+		infraEnv := &unstructured.Unstructured{}
+		infraEnv.SetGroupVersionKind(aiv1beta1.GroupVersion.WithKind("InfraEnv"))
+		if err := c.Get(ctx, key, infraEnv); err != nil {
+			return fmt.Errorf("failed to retrieve InfraEnv: %s in namespace %s, err: %w",
+				key.Name, key.Namespace, err)
+		}
+	}
+
+	// Check that node BMC secrets exist in namespace, BMC credentials are only used on the BareMetal platform
 	for _, node := range clusterInstance.Spec.Nodes {
-		key = types.NamespacedName{Name: node.BmcCredentialsName.Name, Namespace: clusterInstance.Namespace}
+		if clusterInstance.Spec.GetPlatformType() != v1alpha1.PlatformTypeBareMetal {
+			break
+		}
+		key = types.NamespacedName{Name: node.GetBmcCredentialsName(), Namespace: clusterInstance.Namespace}
 		bmcSecret := &corev1.Secret{}
 		if err := c.Get(ctx, key, bmcSecret); err != nil {
 			return fmt.Errorf(
 				"failed to validate BMC credentials: %s in namespace %s [Node: Hostname=%s], err: %w",
-				node.BmcCredentialsName.Name, clusterInstance.Spec.ClusterName, node.HostName, err)
+				node.GetBmcCredentialsName(), clusterInstance.Spec.ClusterName, node.HostName, err)
+		}
+	}
+
+	// validation succeeded
+	return nil
+}
+
+// validateFIPS checks that the release of the ClusterImageSet supports FIPS mode when it is requested. The check is
+// skipped when the release version cannot be determined from the release image, e.g. it is referenced by digest.
+func validateFIPS(clusterInstance *v1alpha1.ClusterInstance, clusterImageSet *hivev1.ClusterImageSet) error {
+	if !clusterInstance.Spec.FIPS {
+		return nil
+	}
+
+	major, minor, ok := releaseVersion(clusterImageSet.Spec.ReleaseImage)
+	if !ok {
+		return nil
+	}
+	if major < FIPSMinimumRelease[0] || (major == FIPSMinimumRelease[0] && minor < FIPSMinimumRelease[1]) {
+		return newValidationError(conditions.FIPSIncompatible,
+			"FIPS mode requires a release of %d.%d or later, ClusterImageSet %s references release %d.%d",
+			FIPSMinimumRelease[0], FIPSMinimumRelease[1], clusterImageSet.Name, major, minor)
+	}
+
+	// validation succeeded
+	return nil
+}
+
+// releaseVersion returns the major and minor version of the release image from its tag, e.g.
+// quay.io/openshift-release-dev/ocp-release:4.15.2-x86_64
+func releaseVersion(releaseImage string) (major, minor int, ok bool) {
+	if strings.Contains(releaseImage, "@") {
+		return 0, 0, false
+	}
+	i := strings.LastIndex(releaseImage, ":")
+	if i < 0 || strings.Contains(releaseImage[i:], "/") {
+		return 0, 0, false
+	}
+	matches := releaseVersionRegex.FindStringSubmatch(releaseImage[i+1:])
+	if matches == nil {
+		return 0, 0, false
+	}
+	major, _ = strconv.Atoi(matches[1])
+	minor, _ = strconv.Atoi(matches[2])
+	return major, minor, true
+}
+
+// validateClusterName checks that the ClusterName is a valid RFC 1123 DNS label, as it is used to derive the names of
+// the rendered resources and the cluster DNS records. The resulting cluster FQDN is checked by validateBaseDomain.
+func validateClusterName(clusterInstance *v1alpha1.ClusterInstance) error {
+	clusterName := clusterInstance.Spec.ClusterName
+	if clusterName == "" {
+		return newValidationError(conditions.ClusterNameInvalid, "missing cluster name")
+	}
+
+	if errs := validation.IsDNS1123Label(clusterName); len(errs) > 0 {
+		return newValidationError(conditions.ClusterNameInvalid,
+			"invalid clusterName %q: %s", clusterName, strings.Join(errs, ", "))
+	}
+
+	// validation succeeded
+	return nil
+}
+
+// maxInfraIDLength is the maximum length of the infraID generated by the installer, i.e. the cluster name truncated to
+// 21 characters followed by a dash and 5 random characters
+const maxInfraIDLength = 27
+
+// validateInfraIDHint checks that the InfraIDHint, when set, is a valid RFC 1123 DNS label no longer than an infraID
+// generated by the installer
+func validateInfraIDHint(clusterInstance *v1alpha1.ClusterInstance) error {
+	infraIDHint := clusterInstance.Spec.InfraIDHint
+	if infraIDHint == "" {
+		return nil
+	}
+
+	if errs := validation.IsDNS1123Label(infraIDHint); len(errs) > 0 {
+		return newValidationError(conditions.InfraIDHintInvalid,
+			"invalid infraIDHint %q: %s", infraIDHint, strings.Join(errs, ", "))
+	}
+
+	if len(infraIDHint) > maxInfraIDLength {
+		return newValidationError(conditions.InfraIDHintInvalid,
+			"invalid infraIDHint %q: must be no more than %d characters", infraIDHint, maxInfraIDLength)
+	}
+
+	// validation succeeded
+	return nil
+}
+
+// validateBaseDomain checks that the BaseDomain is a well-formed DNS domain and that the resulting cluster API FQDN
+// (api.<clusterName>.<baseDomain>) is within the DNS length limits
+func validateBaseDomain(clusterInstance *v1alpha1.ClusterInstance) error {
+	baseDomain := clusterInstance.Spec.BaseDomain
+	if baseDomain == "" {
+		return newValidationError(conditions.BaseDomainInvalid, "baseDomain cannot be empty")
+	}
+
+	if strings.Contains(baseDomain, "://") {
+		return newValidationError(conditions.BaseDomainInvalid,
+			"invalid baseDomain %q: must be a DNS domain without a scheme", baseDomain)
+	}
+
+	if errs := validation.IsDNS1123Subdomain(baseDomain); len(errs) > 0 {
+		return newValidationError(conditions.BaseDomainInvalid,
+			"invalid baseDomain %q: %s", baseDomain, strings.Join(errs, ", "))
+	}
+
+	for _, label := range strings.Split(baseDomain, ".") {
+		if errs := validation.IsDNS1123Label(label); len(errs) > 0 {
+			return newValidationError(conditions.BaseDomainInvalid,
+				"invalid baseDomain %q: label %q %s", baseDomain, label, strings.Join(errs, ", "))
+		}
+	}
+
+	fqdn := fmt.Sprintf("api.%s.%s", clusterInstance.Spec.ClusterName, baseDomain)
+	if len(fqdn) > validation.DNS1123SubdomainMaxLength {
+		return newValidationError(conditions.BaseDomainInvalid,
+			"cluster FQDN %q must be no more than %d characters", fqdn, validation.DNS1123SubdomainMaxLength)
+	}
+
+	// validation succeeded
+	return nil
+}
+
+// validatePlatformNodeFields checks that the node BMC fields are consistent with the platform type: they are required
+// for the BareMetal platform, which manages the hosts through their BMC, and must not be set for other platforms
+func validatePlatformNodeFields(clusterInstance *v1alpha1.ClusterInstance) error {
+	platformType := clusterInstance.Spec.GetPlatformType()
+	for _, node := range clusterInstance.Spec.Nodes {
+		hasBmcFields := node.BmcAddress != "" || node.GetBmcCredentialsName() != ""
+		if platformType == v1alpha1.PlatformTypeBareMetal {
+			if node.BmcAddress == "" || node.GetBmcCredentialsName() == "" {
+				return newValidationError(conditions.PlatformFieldsInvalid,
+					"bmcAddress and bmcCredentialsName are required for the %s platform [Node: Hostname=%s]",
+					platformType, node.HostName)
+			}
+		} else if hasBmcFields {
+			return newValidationError(conditions.PlatformFieldsInvalid,
+				"bmcAddress and bmcCredentialsName must not be set for the %s platform [Node: Hostname=%s]",
+				platformType, node.HostName)
+		}
+	}
+
+	// validation succeeded
+	return nil
+}
+
+// validateInstallMethod checks that the platform and node fields are consistent with the install method: the installer
+// provisioned hosts of the IPI method require the BareMetal or VSphere platform, a HighlyAvailable cluster and, on
+// BareMetal, the API and ingress VIPs, while the user provisioned hosts of the UPI method require the None platform.
+// The InfraEnv of the infraEnvRef is only used by the Assisted method.
+func validateInstallMethod(clusterInstance *v1alpha1.ClusterInstance) error {
+	installMethod := clusterInstance.Spec.GetInstallMethod()
+	platformType := clusterInstance.Spec.GetPlatformType()
+
+	var errs field.ErrorList
+	fldPath := field.NewPath("spec")
+	switch installMethod {
+	case v1alpha1.InstallMethodIPI:
+		if platformType != v1alpha1.PlatformTypeBareMetal && platformType != v1alpha1.PlatformTypeVSphere {
+			errs = append(errs, field.NotSupported(fldPath.Child("platformType"), platformType, []string{
+				string(v1alpha1.PlatformTypeBareMetal), string(v1alpha1.PlatformTypeVSphere)}))
+		}
+		if clusterInstance.Spec.ClusterType == v1alpha1.ClusterTypeSNO {
+			errs = append(errs, field.NotSupported(fldPath.Child("clusterType"), clusterInstance.Spec.ClusterType,
+				[]string{string(v1alpha1.ClusterTypeHighlyAvailable)}))
+		}
+		if platformType == v1alpha1.PlatformTypeBareMetal {
+			if len(clusterInstance.Spec.ApiVIPs) == 0 {
+				errs = append(errs, field.Required(fldPath.Child("apiVIPs"), "required for the BareMetal platform"))
+			}
+			if len(clusterInstance.Spec.IngressVIPs) == 0 {
+				errs = append(errs, field.Required(fldPath.Child("ingressVIPs"), "required for the BareMetal platform"))
+			}
+		}
+	case v1alpha1.InstallMethodUPI:
+		if platformType != v1alpha1.PlatformTypeNone {
+			errs = append(errs, field.NotSupported(fldPath.Child("platformType"), platformType, []string{
+				string(v1alpha1.PlatformTypeNone)}))
+		}
+	}
+	if installMethod != v1alpha1.InstallMethodAssisted && clusterInstance.Spec.InfraEnvRef != nil {
+		errs = append(errs, field.Forbidden(fldPath.Child("infraEnvRef"),
+			"only supported by the Assisted install method"))
+	}
+	if len(errs) > 0 {
+		return newValidationError(conditions.InstallMethodInvalid, "invalid fields for the %s install method: %s",
+			installMethod, errs.ToAggregate().Error())
+	}
+
+	// validation succeeded
+	return nil
+}
+
+// validateManageDNS checks that the API and ingress VIPs the DNS records resolve to are set when the DNS records of
+// the cluster are managed
+func validateManageDNS(clusterInstance *v1alpha1.ClusterInstance) error {
+	if !clusterInstance.Spec.ManageDNS {
+		return nil
+	}
+
+	var errs field.ErrorList
+	fldPath := field.NewPath("spec")
+	if len(clusterInstance.Spec.ApiVIPs) == 0 {
+		errs = append(errs, field.Required(fldPath.Child("apiVIPs"), "required for the api DNS record"))
+	}
+	if len(clusterInstance.Spec.IngressVIPs) == 0 {
+		errs = append(errs, field.Required(fldPath.Child("ingressVIPs"), "required for the *.apps DNS record"))
+	}
+	if len(errs) > 0 {
+		return newValidationError(conditions.DNSConfigInvalid, "invalid fields for manageDNS: %s",
+			errs.ToAggregate().Error())
+	}
+
+	// validation succeeded
+	return nil
+}
+
+// validateInstallAttemptsLimit checks that the InstallAttemptsLimit, if set, is not negative
+func validateInstallAttemptsLimit(clusterInstance *v1alpha1.ClusterInstance) error {
+	if limit := clusterInstance.Spec.InstallAttemptsLimit; limit != nil && *limit < 0 {
+		return fmt.Errorf("installAttemptsLimit must not be negative, got %d", *limit)
+	}
+
+	// validation succeeded
+	return nil
+}
+
+// ValidateRootDeviceHints checks that the rootDeviceHints of the node, if set, contain at least one recognizable hint
+// and that the hints do not contradict each other, so that the install disk can be selected
+func ValidateRootDeviceHints(node *v1alpha1.NodeSpec) error {
+	hints := node.RootDeviceHints
+	if hints == nil {
+		return nil
+	}
+
+	if hints.DeviceName == "" && hints.HCTL == "" && hints.Model == "" && hints.Vendor == "" &&
+		hints.SerialNumber == "" && hints.MinSizeGigabytes == 0 && hints.WWN == "" &&
+		hints.WWNWithExtension == "" && hints.WWNVendorExtension == "" && hints.Rotational == nil {
+		return fmt.Errorf("rootDeviceHints must set at least one hint")
+	}
+
+	if hints.DeviceName != "" && !strings.HasPrefix(hints.DeviceName, "/dev/") {
+		return fmt.Errorf("rootDeviceHints deviceName %q must be a path under /dev/, e.g. /dev/disk/by-path/...",
+			hints.DeviceName)
+	}
+
+	if hints.MinSizeGigabytes < 0 {
+		return fmt.Errorf("rootDeviceHints minSizeGigabytes must not be negative, got %d", hints.MinSizeGigabytes)
+	}
+
+	// The wwnWithExtension is the concatenation of the wwn and the wwnVendorExtension
+	if hints.WWNWithExtension != "" {
+		if hints.WWN != "" && !strings.HasPrefix(hints.WWNWithExtension, hints.WWN) {
+			return fmt.Errorf("rootDeviceHints wwnWithExtension %q contradicts wwn %q",
+				hints.WWNWithExtension, hints.WWN)
+		}
+		if hints.WWNVendorExtension != "" && !strings.HasSuffix(hints.WWNWithExtension, hints.WWNVendorExtension) {
+			return fmt.Errorf("rootDeviceHints wwnWithExtension %q contradicts wwnVendorExtension %q",
+				hints.WWNWithExtension, hints.WWNVendorExtension)
 		}
 	}
 
@@ -75,6 +419,506 @@ func validateResources(ctx context.Context, c client.Client, clusterInstance *v1
 	return nil
 }
 
+// validateRootDeviceHints checks the rootDeviceHints of all the nodes
+func validateRootDeviceHints(clusterInstance *v1alpha1.ClusterInstance) error {
+	for i := range clusterInstance.Spec.Nodes {
+		node := &clusterInstance.Spec.Nodes[i]
+		if err := ValidateRootDeviceHints(node); err != nil {
+			return newValidationError(conditions.RootDeviceHintsInvalid, "%s [Node: Hostname=%s]",
+				err.Error(), node.HostName)
+		}
+	}
+
+	// validation succeeded
+	return nil
+}
+
+// ValidateMACAddresses checks that the boot MAC address of the node and the MAC addresses of the interfaces of its
+// node network are well-formed, as they are used to match the node to its host
+func ValidateMACAddresses(node *v1alpha1.NodeSpec) error {
+	if node.BootMACAddress != "" && !macAddressRegex.MatchString(node.BootMACAddress) {
+		return fmt.Errorf("bootMACAddress %q is not a valid MAC address", node.BootMACAddress)
+	}
+	if node.NodeNetwork != nil {
+		for _, intf := range node.NodeNetwork.Interfaces {
+			if intf != nil && !macAddressRegex.MatchString(intf.MacAddress) {
+				return fmt.Errorf("nodeNetwork interface %q macAddress %q is not a valid MAC address",
+					intf.Name, intf.MacAddress)
+			}
+		}
+	}
+
+	// validation succeeded
+	return nil
+}
+
+// validateMACAddresses checks the MAC addresses of all the nodes, and that no MAC address is declared by more than one
+// node
+func validateMACAddresses(clusterInstance *v1alpha1.ClusterInstance) error {
+	owners := map[string]string{}
+	for i := range clusterInstance.Spec.Nodes {
+		node := &clusterInstance.Spec.Nodes[i]
+		if err := ValidateMACAddresses(node); err != nil {
+			return newValidationError(conditions.MACAddressInvalid, "%s [Node: Hostname=%s]",
+				err.Error(), node.HostName)
+		}
+		for _, macAddress := range node.MACAddresses() {
+			macAddress = strings.ToLower(macAddress)
+			if owner, ok := owners[macAddress]; ok && owner != node.HostName {
+				return newValidationError(conditions.MACAddressInvalid,
+					"MAC address %s is declared by both nodes %s and %s", macAddress, owner, node.HostName)
+			}
+			owners[macAddress] = node.HostName
+		}
+	}
+
+	// validation succeeded
+	return nil
+}
+
+// validateNetworking checks that the machine, cluster and service networks are valid CIDRs that do not overlap, and
+// that the cluster network host prefixes fit in their CIDR
+func validateNetworking(clusterInstance *v1alpha1.ClusterInstance) error {
+	type network struct {
+		field string
+		cidr  *net.IPNet
+	}
+	networks := []network{}
+	parse := func(field, cidr string) (*net.IPNet, error) {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, newValidationError(conditions.NetworkingInvalid, "invalid %s CIDR %q: %v", field, cidr, err)
+		}
+		networks = append(networks, network{field: field, cidr: ipNet})
+		return ipNet, nil
+	}
+
+	for _, entry := range clusterInstance.Spec.MachineNetwork {
+		if _, err := parse("machineNetwork", entry.CIDR); err != nil {
+			return err
+		}
+	}
+	for _, entry := range clusterInstance.Spec.ClusterNetwork {
+		ipNet, err := parse("clusterNetwork", entry.CIDR)
+		if err != nil {
+			return err
+		}
+		if entry.HostPrefix == 0 {
+			continue
+		}
+		ones, bits := ipNet.Mask.Size()
+		if int(entry.HostPrefix) < ones || int(entry.HostPrefix) > bits {
+			return newValidationError(conditions.NetworkingInvalid,
+				"clusterNetwork hostPrefix %d does not fit in CIDR %s, it must be between %d and %d",
+				entry.HostPrefix, entry.CIDR, ones, bits)
+		}
+	}
+	for _, entry := range clusterInstance.Spec.ServiceNetwork {
+		if _, err := parse("serviceNetwork", entry.CIDR); err != nil {
+			return err
+		}
+	}
+
+	for i := range networks {
+		for j := i + 1; j < len(networks); j++ {
+			a, b := networks[i], networks[j]
+			if a.cidr.Contains(b.cidr.IP) || b.cidr.Contains(a.cidr.IP) {
+				return newValidationError(conditions.NetworkingInvalid, "%s CIDR %s overlaps with %s CIDR %s",
+					a.field, a.cidr, b.field, b.cidr)
+			}
+		}
+	}
+
+	// validation succeeded
+	return nil
+}
+
+// validateNodeLabels checks that the node labels are valid label keys and values, so that they can be applied to the
+// spoke Node objects
+func validateNodeLabels(clusterInstance *v1alpha1.ClusterInstance) error {
+	for _, node := range clusterInstance.Spec.Nodes {
+		keys := make([]string, 0, len(node.NodeLabels))
+		for key := range node.NodeLabels {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+				return fmt.Errorf("invalid nodeLabels key %q: %s [Node: Hostname=%s]",
+					key, strings.Join(errs, "; "), node.HostName)
+			}
+			value := node.NodeLabels[key]
+			if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+				return fmt.Errorf("invalid nodeLabels value %q for key %q: %s [Node: Hostname=%s]",
+					value, key, strings.Join(errs, "; "), node.HostName)
+			}
+		}
+	}
+
+	// validation succeeded
+	return nil
+}
+
+// validateExtraAnnotations checks that the cluster-level and node-level extraAnnotations are valid annotations, so that
+// they can be applied to the rendered manifests of their kind (e.g. the Argo CD sync-wave of the ClusterDeployment)
+func validateExtraAnnotations(clusterInstance *v1alpha1.ClusterInstance) error {
+	errs := extraAnnotationsErrors(clusterInstance.Spec.ExtraAnnotations, field.NewPath("spec", "extraAnnotations"))
+	for i, node := range clusterInstance.Spec.Nodes {
+		errs = append(errs, extraAnnotationsErrors(node.ExtraAnnotations,
+			field.NewPath("spec", "nodes").Index(i).Child("extraAnnotations"))...)
+	}
+	if len(errs) > 0 {
+		return newValidationError(conditions.AnnotationsInvalid, "invalid extraAnnotations: %s",
+			errs.ToAggregate().Error())
+	}
+
+	// validation succeeded
+	return nil
+}
+
+// validateNodeAnnotations checks that the node annotations are valid annotations and are not reserved, i.e. managed by
+// the controller, so that they can be set on the BareMetalHost and the Agent of the node
+func validateNodeAnnotations(clusterInstance *v1alpha1.ClusterInstance) error {
+	var errs field.ErrorList
+	for i, node := range clusterInstance.Spec.Nodes {
+		fldPath := field.NewPath("spec", "nodes").Index(i).Child("annotations")
+		errs = append(errs, apivalidation.ValidateAnnotations(node.Annotations, fldPath)...)
+
+		keys := make([]string, 0, len(node.Annotations))
+		for key := range node.Annotations {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if IsReservedNodeAnnotation(key) {
+				errs = append(errs, field.Forbidden(fldPath.Key(key), "the annotation is managed by the controller"))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return newValidationError(conditions.AnnotationsInvalid, "invalid node annotations: %s",
+			errs.ToAggregate().Error())
+	}
+
+	// validation succeeded
+	return nil
+}
+
+// extraAnnotationsErrors returns the errors of the extra annotations of each kind, in kind order
+func extraAnnotationsErrors(extraAnnotations map[string]map[string]string, fldPath *field.Path) field.ErrorList {
+	kinds := make([]string, 0, len(extraAnnotations))
+	for kind := range extraAnnotations {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	var errs field.ErrorList
+	for _, kind := range kinds {
+		if kind == "" {
+			errs = append(errs, field.Invalid(fldPath, kind, "the manifest kind must not be empty"))
+			continue
+		}
+		errs = append(errs, apivalidation.ValidateAnnotations(extraAnnotations[kind], fldPath.Key(kind))...)
+	}
+	return errs
+}
+
+// validateMachinePools checks that the machine pools have unique names, usable in the name of their Hive MachinePool,
+// non-negative replicas and valid node labels and taints
+func validateMachinePools(clusterInstance *v1alpha1.ClusterInstance) error {
+	var errs field.ErrorList
+	names := map[string]bool{}
+	for i, machinePool := range clusterInstance.Spec.MachinePools {
+		fldPath := field.NewPath("spec", "machinePools").Index(i)
+		if msgs := validation.IsDNS1123Label(machinePool.Name); len(msgs) > 0 {
+			errs = append(errs, field.Invalid(fldPath.Child("name"), machinePool.Name, strings.Join(msgs, "; ")))
+		} else if names[machinePool.Name] {
+			errs = append(errs, field.Duplicate(fldPath.Child("name"), machinePool.Name))
+		}
+		names[machinePool.Name] = true
+
+		if machinePool.Replicas != nil && *machinePool.Replicas < 0 {
+			errs = append(errs, field.Invalid(fldPath.Child("replicas"), *machinePool.Replicas,
+				"must be greater than or equal to 0"))
+		}
+		errs = append(errs, metav1validation.ValidateLabels(machinePool.Labels, fldPath.Child("labels"))...)
+		for j, taint := range machinePool.Taints {
+			taintPath := fldPath.Child("taints").Index(j)
+			if msgs := validation.IsQualifiedName(taint.Key); len(msgs) > 0 {
+				errs = append(errs, field.Invalid(taintPath.Child("key"), taint.Key, strings.Join(msgs, "; ")))
+			}
+			if msgs := validation.IsValidLabelValue(taint.Value); len(msgs) > 0 {
+				errs = append(errs, field.Invalid(taintPath.Child("value"), taint.Value, strings.Join(msgs, "; ")))
+			}
+			switch taint.Effect {
+			case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+			default:
+				errs = append(errs, field.NotSupported(taintPath.Child("effect"), taint.Effect, []string{
+					string(corev1.TaintEffectNoSchedule),
+					string(corev1.TaintEffectPreferNoSchedule),
+					string(corev1.TaintEffectNoExecute),
+				}))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return newValidationError(conditions.MachinePoolsInvalid, "invalid machinePools: %s",
+			errs.ToAggregate().Error())
+	}
+
+	// validation succeeded
+	return nil
+}
+
+// validateSNOMachinePools checks that a single-node cluster does not declare machine pools, the workers of a single-node
+// cluster can only be added day-2 as nodes
+func validateSNOMachinePools(clusterInstance *v1alpha1.ClusterInstance) error {
+	if clusterInstance.Spec.ClusterType != v1alpha1.ClusterTypeSNO || len(clusterInstance.Spec.MachinePools) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(clusterInstance.Spec.MachinePools))
+	for _, machinePool := range clusterInstance.Spec.MachinePools {
+		names = append(names, machinePool.Name)
+	}
+	return newValidationError(conditions.SNOMachinePools,
+		"sno cluster-type cannot declare machinePools, found %s", strings.Join(names, ", "))
+}
+
+// validateChronyConfig checks that the chrony configuration defines at least one server, that the servers are IP
+// addresses or DNS names, that the stepping of the clock is enabled and that the extra directives are single lines
+func validateChronyConfig(clusterInstance *v1alpha1.ClusterInstance) error {
+	chronyConfig := clusterInstance.Spec.ChronyConfig
+	if chronyConfig == nil {
+		return nil
+	}
+
+	var errs field.ErrorList
+	fldPath := field.NewPath("spec", "chronyConfig")
+	if len(chronyConfig.Servers) == 0 {
+		errs = append(errs, field.Required(fldPath.Child("servers"), "at least one server is required"))
+	}
+	for i, server := range chronyConfig.Servers {
+		if net.ParseIP(server) == nil && len(validation.IsDNS1123Subdomain(server)) > 0 {
+			errs = append(errs, field.Invalid(fldPath.Child("servers").Index(i), server,
+				"must be an IP address or a DNS name"))
+		}
+	}
+	if makeStep := chronyConfig.MakeStep; makeStep != nil {
+		if makeStep.ThresholdSeconds < 1 {
+			errs = append(errs, field.Invalid(fldPath.Child("makeStep", "thresholdSeconds"), makeStep.ThresholdSeconds,
+				"must be greater than or equal to 1"))
+		}
+		if makeStep.Limit < -1 || makeStep.Limit == 0 {
+			errs = append(errs, field.Invalid(fldPath.Child("makeStep", "limit"), makeStep.Limit,
+				"must be greater than 0, or -1 for no limit"))
+		}
+	}
+	for i, directive := range chronyConfig.ExtraDirectives {
+		if strings.TrimSpace(directive) == "" || strings.ContainsAny(directive, "\r\n") {
+			errs = append(errs, field.Invalid(fldPath.Child("extraDirectives").Index(i), directive,
+				"must be a non-empty single line"))
+		}
+	}
+	if len(errs) > 0 {
+		return newValidationError(conditions.ChronyConfigInvalid, "invalid chronyConfig: %s",
+			errs.ToAggregate().Error())
+	}
+
+	// validation succeeded
+	return nil
+}
+
+// validateImageDigestSources checks that the sources and the mirrors of the ImageDigestSources are repositories
+// without a tag nor a digest, and that each source has mirrors
+func validateImageDigestSources(clusterInstance *v1alpha1.ClusterInstance) error {
+	var errs field.ErrorList
+	fldPath := field.NewPath("spec", "imageDigestSources")
+	for i, source := range clusterInstance.Spec.ImageDigestSources {
+		if !imageRepositoryRegex.MatchString(source.Source) {
+			errs = append(errs, field.Invalid(fldPath.Index(i).Child("source"), source.Source,
+				"must be a repository, without a tag nor a digest"))
+		}
+		if len(source.Mirrors) == 0 {
+			errs = append(errs, field.Required(fldPath.Index(i).Child("mirrors"), "at least one mirror is required"))
+		}
+		for j, mirror := range source.Mirrors {
+			if !imageRepositoryRegex.MatchString(mirror) {
+				errs = append(errs, field.Invalid(fldPath.Index(i).Child("mirrors").Index(j), mirror,
+					"must be a repository, without a tag nor a digest"))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return newValidationError(conditions.ImageDigestSourcesInvalid, "invalid imageDigestSources: %s",
+			errs.ToAggregate().Error())
+	}
+
+	// validation succeeded
+	return nil
+}
+
+// validateInstallOperators checks that the operators to install set their name, namespace, channel and source, that
+// the names and namespaces are valid, and that each operator is only declared once
+func validateInstallOperators(clusterInstance *v1alpha1.ClusterInstance) error {
+	var errs field.ErrorList
+	fldPath := field.NewPath("spec", "installOperators")
+	names := map[string]bool{}
+	for i, operator := range clusterInstance.Spec.InstallOperators {
+		idxPath := fldPath.Index(i)
+		if operator.Name == "" {
+			errs = append(errs, field.Required(idxPath.Child("name"), ""))
+		} else if msgs := validation.IsDNS1123Subdomain(operator.Name); len(msgs) > 0 {
+			errs = append(errs, field.Invalid(idxPath.Child("name"), operator.Name, strings.Join(msgs, "; ")))
+		} else if names[operator.Name] {
+			errs = append(errs, field.Duplicate(idxPath.Child("name"), operator.Name))
+		}
+		names[operator.Name] = true
+
+		if operator.Namespace == "" {
+			errs = append(errs, field.Required(idxPath.Child("namespace"), ""))
+		} else if msgs := validation.IsDNS1123Label(operator.Namespace); len(msgs) > 0 {
+			errs = append(errs, field.Invalid(idxPath.Child("namespace"), operator.Namespace,
+				strings.Join(msgs, "; ")))
+		}
+		if operator.Channel == "" {
+			errs = append(errs, field.Required(idxPath.Child("channel"), ""))
+		}
+		if operator.Source == "" {
+			errs = append(errs, field.Required(idxPath.Child("source"), ""))
+		}
+	}
+	if len(errs) > 0 {
+		return newValidationError(conditions.InstallOperatorsInvalid, "invalid installOperators: %s",
+			errs.ToAggregate().Error())
+	}
+
+	// validation succeeded
+	return nil
+}
+
+// validateTemplateValues checks that the TemplateValues do not override the reserved values derived from the
+// ClusterInstance
+func validateTemplateValues(clusterInstance *v1alpha1.ClusterInstance) error {
+	var reserved []string
+	for _, key := range ReservedTemplateValues {
+		if _, ok := clusterInstance.Spec.TemplateValues[key]; ok {
+			reserved = append(reserved, key)
+		}
+	}
+	if len(reserved) > 0 {
+		return newValidationError(conditions.TemplateValuesInvalid,
+			"templateValues must not override the reserved keys: %s", strings.Join(reserved, ", "))
+	}
+
+	// validation succeeded
+	return nil
+}
+
+// validateBootArtifactsBaseURL checks that the BootArtifactsBaseURL is an http or https URL
+func validateBootArtifactsBaseURL(clusterInstance *v1alpha1.ClusterInstance) error {
+	baseURL := clusterInstance.Spec.BootArtifactsBaseURL
+	if baseURL == "" {
+		return nil
+	}
+
+	if u, err := url.Parse(baseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return newValidationError(conditions.BootArtifactsInvalid,
+			"bootArtifactsBaseURL %q must be an http or https URL", baseURL)
+	}
+
+	// validation succeeded
+	return nil
+}
+
+// validateDiskEncryption checks that the Tang disk encryption defines at least one Tang server, with a valid URL and
+// a thumbprint
+func validateDiskEncryption(clusterInstance *v1alpha1.ClusterInstance) error {
+	diskEncryption := clusterInstance.Spec.DiskEncryption
+	if diskEncryption == nil ||
+		(diskEncryption.Type != v1alpha1.DiskEncryptionTypeTang && diskEncryption.Type != v1alpha1.DiskEncryptionTypeNBDE) {
+		return nil
+	}
+
+	if len(diskEncryption.Tang) == 0 {
+		return newValidationError(conditions.DiskEncryptionInvalid,
+			"diskEncryption type %s requires at least one Tang server", diskEncryption.Type)
+	}
+	for i, tang := range diskEncryption.Tang {
+		if u, err := url.Parse(tang.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return newValidationError(conditions.DiskEncryptionInvalid,
+				"diskEncryption.tang[%d].url %q must be an http or https URL", i, tang.URL)
+		}
+		if tang.Thumbprint == "" {
+			return newValidationError(conditions.DiskEncryptionInvalid,
+				"diskEncryption.tang[%d].thumbprint is required", i)
+		}
+	}
+
+	// validation succeeded
+	return nil
+}
+
+// validateNTPSources checks that the AdditionalNTPSources are IP addresses or DNS names
+func validateNTPSources(clusterInstance *v1alpha1.ClusterInstance) error {
+	for _, source := range clusterInstance.Spec.AdditionalNTPSources {
+		if net.ParseIP(source) == nil && len(validation.IsDNS1123Subdomain(source)) > 0 {
+			return newValidationError(conditions.NTPSourceInvalid,
+				"invalid additionalNTPSources entry %q: must be an IP address or DNS name", source)
+		}
+	}
+
+	// validation succeeded
+	return nil
+}
+
+// validateValidationOverrides checks that the validations listed in Spec.ValidationOverrides are known
+func validateValidationOverrides(clusterInstance *v1alpha1.ClusterInstance) error {
+	for _, name := range clusterInstance.Spec.ValidationOverrides {
+		if _, ok := overridableValidations[name]; !ok {
+			return fmt.Errorf("unknown validation %q in validationOverrides, supported validations: %s",
+				name, strings.Join(overridableValidationNames(), ", "))
+		}
+	}
+	return nil
+}
+
+func overridableValidationNames() []string {
+	names := make([]string, 0, len(overridableValidations))
+	for name := range overridableValidations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SkippedValidations returns the sorted list of known validations that are skipped for the ClusterInstance
+func SkippedValidations(clusterInstance *v1alpha1.ClusterInstance) []string {
+	skipped := []string{}
+	for _, name := range clusterInstance.Spec.ValidationOverrides {
+		if _, ok := overridableValidations[name]; ok && !slices.Contains(skipped, name) {
+			skipped = append(skipped, name)
+		}
+	}
+	sort.Strings(skipped)
+	return skipped
+}
+
+// validateOverridable runs the overridable validations, except for those listed in Spec.ValidationOverrides
+func validateOverridable(clusterInstance *v1alpha1.ClusterInstance) error {
+	skipped := SkippedValidations(clusterInstance)
+	for _, name := range overridableValidationNames() {
+		if slices.Contains(skipped, name) {
+			continue
+		}
+		if err := overridableValidations[name](clusterInstance); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func validateTemplateRefs(ctx context.Context, c client.Client, clusterInstance *v1alpha1.ClusterInstance) error {
 
 	// Check the cluster-level template references are defined
@@ -170,15 +1014,127 @@ func validateControlPlaneAgents(clusterInstance *v1alpha1.ClusterInstance) error
 		return fmt.Errorf("sno cluster-type can only have 1 control-plane agent")
 	}
 
+	// An even number of control-plane nodes does not improve the etcd fault tolerance and risks losing the quorum
+	if clusterInstance.Spec.ClusterType == v1alpha1.ClusterTypeHighlyAvailable && numControlPlaneAgents%2 == 0 {
+		return newValidationError(conditions.ControlPlaneQuorumInvalid,
+			"HighlyAvailable cluster-type requires an odd number of control-plane agents to keep the etcd quorum, "+
+				"found %d", numControlPlaneAgents)
+	}
+
 	// validation succeeded
 	return nil
 }
 
+// ReplayWebhookValidations runs the validations of the validating webhook against the ClusterInstance: those of the
+// spec, and those of the nodes update against the last applied spec, which stands in for the spec the update was made
+// from
+func ReplayWebhookValidations(clusterInstance *v1alpha1.ClusterInstance) error {
+	if err := v1alpha1.ValidateSpec(&clusterInstance.Spec); err != nil {
+		return err
+	}
+
+	appliedSpec, err := GetLastAppliedSpec(clusterInstance)
+	if err != nil || appliedSpec == nil {
+		return err
+	}
+	oldClusterInstance := &v1alpha1.ClusterInstance{Spec: *appliedSpec, Status: clusterInstance.Status}
+	return v1alpha1.ValidateNodesUpdate(oldClusterInstance, clusterInstance)
+}
+
 // Validate checks the given ClusterInstance, returns an error if validation fails, returns nil if it succeeds
 func Validate(ctx context.Context, c client.Client, clusterInstance *v1alpha1.ClusterInstance) error {
 
-	if clusterInstance.Spec.ClusterName == "" {
-		return fmt.Errorf("missing cluster name")
+	if err := validateClusterName(clusterInstance); err != nil {
+		return err
+	}
+
+	if err := validateInfraIDHint(clusterInstance); err != nil {
+		return err
+	}
+
+	// Enforce the webhook validations, in case the ClusterInstance was admitted without the validating webhook
+	if err := ReplayWebhookValidations(clusterInstance); err != nil {
+		return err
+	}
+
+	if err := validatePlatformNodeFields(clusterInstance); err != nil {
+		return err
+	}
+
+	if err := validateInstallMethod(clusterInstance); err != nil {
+		return err
+	}
+
+	if err := validateManageDNS(clusterInstance); err != nil {
+		return err
+	}
+
+	if err := validateTemplateValues(clusterInstance); err != nil {
+		return err
+	}
+
+	if err := validateBootArtifactsBaseURL(clusterInstance); err != nil {
+		return err
+	}
+
+	if err := validateDiskEncryption(clusterInstance); err != nil {
+		return err
+	}
+
+	if err := validateInstallAttemptsLimit(clusterInstance); err != nil {
+		return err
+	}
+
+	if err := validateNodeLabels(clusterInstance); err != nil {
+		return err
+	}
+
+	if err := validateExtraAnnotations(clusterInstance); err != nil {
+		return err
+	}
+
+	if err := validateNodeAnnotations(clusterInstance); err != nil {
+		return err
+	}
+
+	if err := validateMachinePools(clusterInstance); err != nil {
+		return err
+	}
+
+	if err := validateSNOMachinePools(clusterInstance); err != nil {
+		return err
+	}
+
+	if err := validateChronyConfig(clusterInstance); err != nil {
+		return err
+	}
+
+	if err := validateImageDigestSources(clusterInstance); err != nil {
+		return err
+	}
+
+	if err := validateInstallOperators(clusterInstance); err != nil {
+		return err
+	}
+
+	if err := validateRootDeviceHints(clusterInstance); err != nil {
+		return err
+	}
+
+	if err := validateMACAddresses(clusterInstance); err != nil {
+		return err
+	}
+
+	if err := validateNetworking(clusterInstance); err != nil {
+		return err
+	}
+
+	if err := validateValidationOverrides(clusterInstance); err != nil {
+		return err
+	}
+
+	if err := validateOverridable(clusterInstance); err != nil {
+		return err
 	}
 
 	if err := validateResources(ctx, c, clusterInstance); err != nil {
@@ -193,6 +1149,10 @@ func Validate(ctx context.Context, c client.Client, clusterInstance *v1alpha1.Cl
 		return err
 	}
 
+	if err := validateChargebackMetadata(clusterInstance); err != nil {
+		return err
+	}
+
 	if err := validateControlPlaneAgents(clusterInstance); err != nil {
 		return err
 	}
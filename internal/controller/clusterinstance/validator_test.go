@@ -17,13 +17,19 @@ package clusterinstance
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 
+	bmh_v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	aiv1beta1 "github.com/openshift/assisted-service/api/v1beta1"
 	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -77,6 +83,516 @@ var _ = Describe("Validate", func() {
 
 		err := Validate(ctx, c, clusterInstance)
 		Expect(err).To(MatchError(ContainSubstring("missing cluster name")))
+		Expect(ValidationFailureReason(err)).To(Equal(conditions.ClusterNameInvalid))
+	})
+
+	It("fails validation when the cluster name is not a valid DNS label", func() {
+		for _, clusterName := range []string{
+			"Test-Cluster",
+			"-test-cluster",
+			"test-cluster-",
+			"test_cluster",
+			"test.cluster",
+			strings.Repeat("a", 64),
+		} {
+			clusterInstance.Spec.ClusterName = clusterName
+			err := Validate(ctx, c, clusterInstance)
+			Expect(err).To(MatchError(ContainSubstring("invalid clusterName")), "clusterName: %q", clusterName)
+			Expect(ValidationFailureReason(err)).To(Equal(conditions.ClusterNameInvalid))
+		}
+	})
+
+	It("successfully validates the infraIDHint", func() {
+		for _, infraIDHint := range []string{"", "site-sno-du-1-x7k2p", strings.Repeat("a", 27)} {
+			clusterInstance.Spec.InfraIDHint = infraIDHint
+			Expect(validateInfraIDHint(clusterInstance)).To(Succeed(), "infraIDHint: %q", infraIDHint)
+		}
+	})
+
+	It("fails validation when the infraIDHint is not a valid infraID", func() {
+		for _, infraIDHint := range []string{
+			"Site-SNO",
+			"site_sno",
+			"site-sno-",
+			strings.Repeat("a", 28),
+		} {
+			clusterInstance.Spec.InfraIDHint = infraIDHint
+			err := Validate(ctx, c, clusterInstance)
+			Expect(err).To(MatchError(ContainSubstring("invalid infraIDHint")), "infraIDHint: %q", infraIDHint)
+			Expect(ValidationFailureReason(err)).To(Equal(conditions.InfraIDHintInvalid))
+		}
+	})
+
+	It("fails validation when the baseDomain is not a valid DNS domain", func() {
+		for _, baseDomain := range []string{
+			"",
+			"https://example.com",
+			"-example.com",
+			"example_domain.com",
+			"example..com",
+			strings.Repeat("a", 64) + ".com",
+		} {
+			clusterInstance.Spec.BaseDomain = baseDomain
+			err := Validate(ctx, c, clusterInstance)
+			Expect(err).To(HaveOccurred(), "baseDomain: %q", baseDomain)
+			Expect(ValidationFailureReason(err)).To(Equal(conditions.BaseDomainInvalid))
+		}
+	})
+
+	It("fails validation when the cluster FQDN exceeds the DNS length limit", func() {
+		clusterInstance.Spec.BaseDomain = strings.Repeat(strings.Repeat("a", 60)+".", 4) + "com"
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		err := Validate(ctx, c, clusterInstance)
+		Expect(err).To(MatchError(ContainSubstring("must be no more than 253 characters")))
+		Expect(ValidationFailureReason(err)).To(Equal(conditions.BaseDomainInvalid))
+	})
+
+	It("successfully validates hostname and IP additionalNTPSources", func() {
+		clusterInstance.Spec.AdditionalNTPSources = []string{"ntp.example.com", "192.0.2.123", "2001:db8::123"}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		Expect(Validate(ctx, c, clusterInstance)).To(Succeed())
+	})
+
+	It("fails validation when an additionalNTPSources entry is malformed", func() {
+		for _, source := range []string{"", "ntp://ntp.example.com", "ntp_server.example.com", "192.0.2.1:123"} {
+			clusterInstance.Spec.AdditionalNTPSources = []string{"ntp.example.com", source}
+			err := Validate(ctx, c, clusterInstance)
+			Expect(err).To(MatchError(ContainSubstring("invalid additionalNTPSources entry")), "source: %q", source)
+			Expect(ValidationFailureReason(err)).To(Equal(conditions.NTPSourceInvalid))
+		}
+	})
+
+	It("requires the node BMC fields for the BareMetal platform", func() {
+		clusterInstance.Spec.PlatformType = v1alpha1.PlatformTypeBareMetal
+		clusterInstance.Spec.Nodes[0].BmcCredentialsName = v1alpha1.BmcCredentialsName{}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		err := Validate(ctx, c, clusterInstance)
+		Expect(err).To(MatchError(ContainSubstring(
+			"bmcAddress and bmcCredentialsName are required for the BareMetal platform")))
+		Expect(ValidationFailureReason(err)).To(Equal(conditions.PlatformFieldsInvalid))
+	})
+
+	It("successfully validates a None platform ClusterInstance without node BMC fields", func() {
+		clusterInstance.Spec.PlatformType = v1alpha1.PlatformTypeNone
+		clusterInstance.Spec.Nodes[0].BmcAddress = ""
+		clusterInstance.Spec.Nodes[0].BmcCredentialsName = v1alpha1.BmcCredentialsName{}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		Expect(Validate(ctx, c, clusterInstance)).To(Succeed())
+	})
+
+	It("fails validation when node BMC fields are set for the None platform", func() {
+		clusterInstance.Spec.PlatformType = v1alpha1.PlatformTypeNone
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		err := Validate(ctx, c, clusterInstance)
+		Expect(err).To(MatchError(ContainSubstring(
+			"bmcAddress and bmcCredentialsName must not be set for the None platform")))
+		Expect(ValidationFailureReason(err)).To(Equal(conditions.PlatformFieldsInvalid))
+	})
+
+	It("successfully validates a UPI ClusterInstance on the None platform", func() {
+		clusterInstance.Spec.InstallMethod = v1alpha1.InstallMethodUPI
+		clusterInstance.Spec.PlatformType = v1alpha1.PlatformTypeNone
+		clusterInstance.Spec.Nodes[0].BmcAddress = ""
+		clusterInstance.Spec.Nodes[0].BmcCredentialsName = v1alpha1.BmcCredentialsName{}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		Expect(Validate(ctx, c, clusterInstance)).To(Succeed())
+	})
+
+	It("fails validation when the platform is not consistent with the install method", func() {
+		clusterInstance.Spec.InstallMethod = v1alpha1.InstallMethodUPI
+		clusterInstance.Spec.PlatformType = v1alpha1.PlatformTypeBareMetal
+		clusterInstance.Spec.InfraEnvRef = &corev1.LocalObjectReference{Name: "infraenv"}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		err := Validate(ctx, c, clusterInstance)
+		Expect(err).To(MatchError(And(
+			ContainSubstring("invalid fields for the UPI install method"),
+			ContainSubstring(`spec.platformType: Unsupported value: "BareMetal": supported values: "None"`),
+			ContainSubstring("spec.infraEnvRef: Forbidden: only supported by the Assisted install method"))))
+		Expect(ValidationFailureReason(err)).To(Equal(conditions.InstallMethodInvalid))
+	})
+
+	It("fails validation when an IPI ClusterInstance is a single-node cluster without VIPs", func() {
+		clusterInstance.Spec.InstallMethod = v1alpha1.InstallMethodIPI
+		clusterInstance.Spec.ClusterType = v1alpha1.ClusterTypeSNO
+		clusterInstance.Spec.ApiVIPs = nil
+		clusterInstance.Spec.IngressVIPs = nil
+
+		err := validateInstallMethod(clusterInstance)
+		Expect(err).To(MatchError(And(
+			ContainSubstring(`spec.clusterType: Unsupported value: "SNO"`),
+			ContainSubstring("spec.apiVIPs: Required value"),
+			ContainSubstring("spec.ingressVIPs: Required value"))))
+		Expect(ValidationFailureReason(err)).To(Equal(conditions.InstallMethodInvalid))
+
+		clusterInstance.Spec.ClusterType = v1alpha1.ClusterTypeHighlyAvailable
+		clusterInstance.Spec.ApiVIPs = []string{"192.0.2.10"}
+		clusterInstance.Spec.IngressVIPs = []string{"192.0.2.11"}
+		Expect(validateInstallMethod(clusterInstance)).To(Succeed())
+	})
+
+	It("fails validation when the installAttemptsLimit is negative", func() {
+		limit := int32(-1)
+		clusterInstance.Spec.InstallAttemptsLimit = &limit
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		err := Validate(ctx, c, clusterInstance)
+		Expect(err).To(MatchError("installAttemptsLimit must not be negative, got -1"))
+	})
+
+	It("fails validation when a node label key is invalid", func() {
+		clusterInstance.Spec.Nodes[0].NodeLabels = map[string]string{"node-role.kubernetes.io/infra": "", "bad key": "x"}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		err := Validate(ctx, c, clusterInstance)
+		Expect(err).To(MatchError(ContainSubstring(`invalid nodeLabels key "bad key"`)))
+	})
+
+	It("fails validation when a node label value is invalid", func() {
+		clusterInstance.Spec.Nodes[0].NodeLabels = map[string]string{"environment": "not/valid"}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		err := Validate(ctx, c, clusterInstance)
+		Expect(err).To(MatchError(ContainSubstring(`invalid nodeLabels value "not/valid" for key "environment"`)))
+	})
+
+	It("successfully validates the extraAnnotations of the ClusterDeployment", func() {
+		clusterInstance.Spec.ExtraAnnotations = map[string]map[string]string{
+			"ClusterDeployment": {"argocd.argoproj.io/sync-wave": "1", "argocd.argoproj.io/hook": "PreSync"},
+		}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+		Expect(validateExtraAnnotations(clusterInstance)).To(Succeed())
+	})
+
+	It("fails validation when an extraAnnotations key is invalid", func() {
+		clusterInstance.Spec.ExtraAnnotations = map[string]map[string]string{
+			"ClusterDeployment": {"argocd.argoproj.io/sync wave": "1"},
+		}
+		clusterInstance.Spec.Nodes[0].ExtraAnnotations = map[string]map[string]string{
+			"": {"foo": "bar"},
+		}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		err := Validate(ctx, c, clusterInstance)
+		Expect(err).To(MatchError(And(
+			ContainSubstring(`spec.extraAnnotations[ClusterDeployment]: Invalid value: "argocd.argoproj.io/sync wave"`),
+			ContainSubstring(`spec.nodes[0].extraAnnotations: Invalid value: "": the manifest kind must not be empty`))))
+		Expect(ValidationFailureReason(err)).To(Equal(conditions.AnnotationsInvalid))
+	})
+
+	It("fails validation when a node annotation is invalid or reserved", func() {
+		clusterInstance.Spec.Nodes[0].Annotations = map[string]string{
+			"example.com/rack":          "r12",
+			"example.com/rack position": "2",
+			"inspect.metal3.io":         "disabled",
+		}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		err := Validate(ctx, c, clusterInstance)
+		Expect(err).To(MatchError(And(
+			ContainSubstring(`spec.nodes[0].annotations: Invalid value: "example.com/rack position"`),
+			ContainSubstring(`spec.nodes[0].annotations[inspect.metal3.io]: Forbidden: `+
+				`the annotation is managed by the controller`))))
+		Expect(ValidationFailureReason(err)).To(Equal(conditions.AnnotationsInvalid))
+	})
+
+	It("fails validation when the DNS is managed without the VIPs", func() {
+		clusterInstance.Spec.ManageDNS = true
+		clusterInstance.Spec.ApiVIPs = []string{"192.0.2.10"}
+		clusterInstance.Spec.IngressVIPs = nil
+
+		err := validateManageDNS(clusterInstance)
+		Expect(err).To(MatchError(ContainSubstring(
+			"spec.ingressVIPs: Required value: required for the *.apps DNS record")))
+		Expect(ValidationFailureReason(err)).To(Equal(conditions.DNSConfigInvalid))
+
+		clusterInstance.Spec.IngressVIPs = []string{"192.0.2.11"}
+		Expect(validateManageDNS(clusterInstance)).To(Succeed())
+	})
+
+	It("successfully validates the machinePools", func() {
+		replicas := int64(0)
+		clusterInstance.Spec.MachinePools = []v1alpha1.MachinePoolSpec{
+			{Name: "worker", Replicas: &replicas},
+			{
+				Name:   "infra",
+				Labels: map[string]string{"node-role.kubernetes.io/infra": ""},
+				Taints: []corev1.Taint{{Key: "node-role.kubernetes.io/infra", Effect: corev1.TaintEffectNoSchedule}},
+			},
+		}
+		Expect(validateMachinePools(clusterInstance)).To(Succeed())
+	})
+
+	It("fails validation when the machinePools are invalid", func() {
+		replicas := int64(-1)
+		clusterInstance.Spec.MachinePools = []v1alpha1.MachinePoolSpec{
+			{Name: "worker", Replicas: &replicas},
+			{Name: "worker"},
+			{Name: "Infra_Pool"},
+			{
+				Name:   "storage",
+				Labels: map[string]string{"bad key": "value"},
+				Taints: []corev1.Taint{{Key: "storage", Effect: "Sometimes"}},
+			},
+		}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		err := Validate(ctx, c, clusterInstance)
+		Expect(err).To(MatchError(And(
+			ContainSubstring(`spec.machinePools[0].replicas: Invalid value: -1: must be greater than or equal to 0`),
+			ContainSubstring(`spec.machinePools[1].name: Duplicate value: "worker"`),
+			ContainSubstring(`spec.machinePools[2].name: Invalid value: "Infra_Pool"`),
+			ContainSubstring(`spec.machinePools[3].labels: Invalid value: "bad key"`),
+			ContainSubstring(`spec.machinePools[3].taints[0].effect: Unsupported value: "Sometimes"`))))
+		Expect(ValidationFailureReason(err)).To(Equal(conditions.MachinePoolsInvalid))
+	})
+
+	It("fails validation when an SNO cluster declares machinePools", func() {
+		clusterInstance.Spec.MachinePools = []v1alpha1.MachinePoolSpec{{Name: "worker"}, {Name: "infra"}}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		err := Validate(ctx, c, clusterInstance)
+		Expect(err).To(MatchError("sno cluster-type cannot declare machinePools, found worker, infra"))
+		Expect(ValidationFailureReason(err)).To(Equal(conditions.SNOMachinePools))
+	})
+
+	It("successfully validates the machinePools of a HighlyAvailable cluster", func() {
+		clusterInstance.Spec.ClusterType = v1alpha1.ClusterTypeHighlyAvailable
+		clusterInstance.Spec.MachinePools = []v1alpha1.MachinePoolSpec{{Name: "worker"}}
+		Expect(validateSNOMachinePools(clusterInstance)).To(Succeed())
+	})
+
+	It("successfully validates the chronyConfig", func() {
+		clusterInstance.Spec.ChronyConfig = &v1alpha1.ChronyConfig{
+			Servers:         []string{"192.0.2.1", "ntp.example.com"},
+			MakeStep:        &v1alpha1.ChronyMakeStep{ThresholdSeconds: 10, Limit: -1},
+			ExtraDirectives: []string{"maxdistance 16"},
+		}
+		Expect(validateChronyConfig(clusterInstance)).To(Succeed())
+	})
+
+	It("successfully validates the imageDigestSources", func() {
+		clusterInstance.Spec.ImageDigestSources = []v1alpha1.ImageDigestSource{{
+			Source:  "quay.io/openshift-release-dev/ocp-release",
+			Mirrors: []string{"mirror.example.com:5000/ocp/release", "localhost/ocp-release"},
+		}}
+		Expect(validateImageDigestSources(clusterInstance)).To(Succeed())
+	})
+
+	It("fails validation when the imageDigestSources are invalid", func() {
+		clusterInstance.Spec.ImageDigestSources = []v1alpha1.ImageDigestSource{
+			{Source: "quay.io/openshift-release-dev/ocp-release:4.16", Mirrors: []string{"mirror.example.com/ocp"}},
+			{Source: "quay.io/openshift-release-dev/ocp-v4.0-art-dev"},
+			{Source: "registry.redhat.io/rhel9", Mirrors: []string{"https://mirror.example.com/rhel9"}},
+		}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		err := Validate(ctx, c, clusterInstance)
+		Expect(err).To(MatchError(And(
+			ContainSubstring(`spec.imageDigestSources[0].source: Invalid value: `+
+				`"quay.io/openshift-release-dev/ocp-release:4.16"`),
+			ContainSubstring(`spec.imageDigestSources[1].mirrors: Required value`),
+			ContainSubstring(`spec.imageDigestSources[2].mirrors[0]: Invalid value: `+
+				`"https://mirror.example.com/rhel9"`))))
+		Expect(ValidationFailureReason(err)).To(Equal(conditions.ImageDigestSourcesInvalid))
+	})
+
+	It("successfully validates the installOperators", func() {
+		clusterInstance.Spec.InstallOperators = []v1alpha1.OperatorSpec{
+			{Name: "local-storage-operator", Namespace: "openshift-local-storage", Channel: "stable",
+				Source: "redhat-operators"},
+			{Name: "sriov-network-operator", Namespace: "openshift-sriov-network-operator", Channel: "stable",
+				Source: "redhat-operators", SourceNamespace: "openshift-marketplace"},
+		}
+		Expect(validateInstallOperators(clusterInstance)).To(Succeed())
+	})
+
+	It("fails validation when the installOperators are invalid", func() {
+		clusterInstance.Spec.InstallOperators = []v1alpha1.OperatorSpec{
+			{Name: "local-storage-operator", Namespace: "openshift-local-storage", Channel: "stable"},
+			{Name: "local-storage-operator", Namespace: "Local_Storage", Channel: "stable", Source: "redhat-operators"},
+			{Namespace: "openshift-ptp", Source: "redhat-operators"},
+		}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		err := Validate(ctx, c, clusterInstance)
+		Expect(err).To(MatchError(And(
+			ContainSubstring("spec.installOperators[0].source: Required value"),
+			ContainSubstring(`spec.installOperators[1].name: Duplicate value: "local-storage-operator"`),
+			ContainSubstring(`spec.installOperators[1].namespace: Invalid value: "Local_Storage"`),
+			ContainSubstring("spec.installOperators[2].name: Required value"),
+			ContainSubstring("spec.installOperators[2].channel: Required value"))))
+		Expect(ValidationFailureReason(err)).To(Equal(conditions.InstallOperatorsInvalid))
+	})
+
+	It("fails validation when the chronyConfig is invalid", func() {
+		clusterInstance.Spec.ChronyConfig = &v1alpha1.ChronyConfig{
+			Servers:         []string{"not a server"},
+			MakeStep:        &v1alpha1.ChronyMakeStep{ThresholdSeconds: 0, Limit: 0},
+			ExtraDirectives: []string{"rtcsync\nserver 198.51.100.1"},
+		}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		err := Validate(ctx, c, clusterInstance)
+		Expect(err).To(MatchError(And(
+			ContainSubstring(`spec.chronyConfig.servers[0]: Invalid value: "not a server"`),
+			ContainSubstring(`spec.chronyConfig.makeStep.thresholdSeconds: Invalid value: 0`),
+			ContainSubstring(`spec.chronyConfig.makeStep.limit: Invalid value: 0`),
+			ContainSubstring(`spec.chronyConfig.extraDirectives[0]: Invalid value`))))
+		Expect(ValidationFailureReason(err)).To(Equal(conditions.ChronyConfigInvalid))
+
+		clusterInstance.Spec.ChronyConfig = &v1alpha1.ChronyConfig{}
+		Expect(validateChronyConfig(clusterInstance)).To(MatchError(
+			ContainSubstring("spec.chronyConfig.servers: Required value")))
+	})
+
+	It("successfully validates recognizable rootDeviceHints", func() {
+		rotational := false
+		for _, hints := range []*bmh_v1alpha1.RootDeviceHints{
+			{DeviceName: "/dev/disk/by-path/pci-0000:01:00.0-scsi-0:2:0:0"},
+			{SerialNumber: "S3YJNX0K123456", MinSizeGigabytes: 100},
+			{WWN: "0x5000c500a0b1c2d3", WWNVendorExtension: "0x1", WWNWithExtension: "0x5000c500a0b1c2d30x1"},
+			{Rotational: &rotational},
+		} {
+			clusterInstance.Spec.Nodes[0].RootDeviceHints = hints
+			Expect(Validate(ctx, c, clusterInstance)).To(Succeed(), "rootDeviceHints: %+v", hints)
+		}
+	})
+
+	DescribeTable("fails validation when the rootDeviceHints are not usable",
+		func(hints *bmh_v1alpha1.RootDeviceHints, expected string) {
+			clusterInstance.Spec.Nodes[0].RootDeviceHints = hints
+			err := Validate(ctx, c, clusterInstance)
+			Expect(err).To(MatchError(ContainSubstring(expected)))
+			Expect(ValidationFailureReason(err)).To(Equal(conditions.RootDeviceHintsInvalid))
+		},
+		Entry("no hint set", &bmh_v1alpha1.RootDeviceHints{}, "must set at least one hint"),
+		Entry("deviceName not under /dev", &bmh_v1alpha1.RootDeviceHints{DeviceName: "sda"}, "must be a path under /dev/"),
+		Entry("negative minSizeGigabytes", &bmh_v1alpha1.RootDeviceHints{MinSizeGigabytes: -1},
+			"minSizeGigabytes must not be negative"),
+		Entry("wwnWithExtension contradicting wwn",
+			&bmh_v1alpha1.RootDeviceHints{WWN: "0x5000c500a0b1c2d3", WWNWithExtension: "0x5000c500ffffffff0x1"},
+			"contradicts wwn"),
+		Entry("wwnWithExtension contradicting wwnVendorExtension",
+			&bmh_v1alpha1.RootDeviceHints{WWNVendorExtension: "0x1", WWNWithExtension: "0x5000c500a0b1c2d30x2"},
+			"contradicts wwnVendorExtension"),
+	)
+
+	It("successfully validates the MAC addresses of the nodes", func() {
+		clusterInstance.Spec.Nodes[0].BootMACAddress = "00:00:5E:00:53:00"
+		clusterInstance.Spec.Nodes[0].NodeNetwork = &aiv1beta1.NMStateConfigSpec{
+			Interfaces: []*aiv1beta1.Interface{
+				{Name: "eno1", MacAddress: "00:00:5e:00:53:00"},
+				{Name: "eno2", MacAddress: "00:00:5E:00:53:01"},
+			},
+		}
+		Expect(Validate(ctx, c, clusterInstance)).To(Succeed())
+	})
+
+	DescribeTable("fails validation when a MAC address of a node is malformed",
+		func(bootMACAddress, interfaceMACAddress, expected string) {
+			clusterInstance.Spec.Nodes[0].BootMACAddress = bootMACAddress
+			clusterInstance.Spec.Nodes[0].NodeNetwork = &aiv1beta1.NMStateConfigSpec{
+				Interfaces: []*aiv1beta1.Interface{{Name: "eno1", MacAddress: interfaceMACAddress}},
+			}
+			err := Validate(ctx, c, clusterInstance)
+			Expect(err).To(MatchError(ContainSubstring(expected)))
+			Expect(ValidationFailureReason(err)).To(Equal(conditions.MACAddressInvalid))
+		},
+		Entry("boot MAC address with a trailing octet", "00:00:5E:00:53:00:01", "00:00:5E:00:53:01",
+			`bootMACAddress "00:00:5E:00:53:00:01" is not a valid MAC address`),
+		Entry("boot MAC address with hyphens", "00-00-5E-00-53-00", "00:00:5E:00:53:01",
+			`bootMACAddress "00-00-5E-00-53-00" is not a valid MAC address`),
+		Entry("interface MAC address with a non-hexadecimal digit", "00:00:5E:00:53:00", "00:00:5E:00:53:0g",
+			`nodeNetwork interface "eno1" macAddress "00:00:5E:00:53:0g" is not a valid MAC address`),
+		Entry("missing interface MAC address", "00:00:5E:00:53:00", "",
+			`nodeNetwork interface "eno1" macAddress "" is not a valid MAC address`),
+	)
+
+	It("fails validation when a MAC address is declared by more than one node", func() {
+		clusterInstance.Spec.Nodes[0].BootMACAddress = "00:00:5E:00:53:00"
+		node := *clusterInstance.Spec.Nodes[0].DeepCopy()
+		node.HostName = "node2"
+		node.Role = "worker"
+		clusterInstance.Spec.Nodes = append(clusterInstance.Spec.Nodes, node)
+		err := validateMACAddresses(clusterInstance)
+		Expect(err).To(MatchError(ContainSubstring("is declared by both nodes")))
+		Expect(ValidationFailureReason(err)).To(Equal(conditions.MACAddressInvalid))
+	})
+
+	It("successfully validates non-overlapping networks", func() {
+		clusterInstance.Spec.MachineNetwork = []v1alpha1.MachineNetworkEntry{{CIDR: "192.0.2.0/24"}, {CIDR: "2001:db8::/64"}}
+		clusterInstance.Spec.ClusterNetwork = []v1alpha1.ClusterNetworkEntry{
+			{CIDR: "10.128.0.0/14", HostPrefix: 23}, {CIDR: "fd01::/48", HostPrefix: 64}}
+		clusterInstance.Spec.ServiceNetwork = []v1alpha1.ServiceNetworkEntry{{CIDR: "172.30.0.0/16"}, {CIDR: "fd02::/112"}}
+		Expect(Validate(ctx, c, clusterInstance)).To(Succeed())
+	})
+
+	DescribeTable("fails validation when the networks are malformed or overlap",
+		func(update func(*v1alpha1.ClusterInstanceSpec), expected string) {
+			clusterInstance.Spec.MachineNetwork = []v1alpha1.MachineNetworkEntry{{CIDR: "192.0.2.0/24"}}
+			clusterInstance.Spec.ClusterNetwork = []v1alpha1.ClusterNetworkEntry{{CIDR: "10.128.0.0/14", HostPrefix: 23}}
+			clusterInstance.Spec.ServiceNetwork = []v1alpha1.ServiceNetworkEntry{{CIDR: "172.30.0.0/16"}}
+			update(&clusterInstance.Spec)
+
+			err := Validate(ctx, c, clusterInstance)
+			Expect(err).To(MatchError(ContainSubstring(expected)))
+			Expect(ValidationFailureReason(err)).To(Equal(conditions.NetworkingInvalid))
+		},
+		Entry("malformed machineNetwork CIDR", func(spec *v1alpha1.ClusterInstanceSpec) {
+			spec.MachineNetwork[0].CIDR = "192.0.2.0"
+		}, `invalid machineNetwork CIDR "192.0.2.0"`),
+		Entry("malformed serviceNetwork CIDR", func(spec *v1alpha1.ClusterInstanceSpec) {
+			spec.ServiceNetwork[0].CIDR = "172.30.0.0/33"
+		}, `invalid serviceNetwork CIDR "172.30.0.0/33"`),
+		Entry("hostPrefix shorter than the clusterNetwork prefix", func(spec *v1alpha1.ClusterInstanceSpec) {
+			spec.ClusterNetwork[0].HostPrefix = 12
+		}, "clusterNetwork hostPrefix 12 does not fit in CIDR 10.128.0.0/14"),
+		Entry("clusterNetwork overlapping the serviceNetwork", func(spec *v1alpha1.ClusterInstanceSpec) {
+			spec.ServiceNetwork[0].CIDR = "10.130.0.0/16"
+		}, "clusterNetwork CIDR 10.128.0.0/14 overlaps with serviceNetwork CIDR 10.130.0.0/16"),
+		Entry("machineNetwork containing the clusterNetwork", func(spec *v1alpha1.ClusterInstanceSpec) {
+			spec.MachineNetwork[0].CIDR = "10.0.0.0/8"
+		}, "machineNetwork CIDR 10.0.0.0/8 overlaps with clusterNetwork CIDR 10.128.0.0/14"),
+	)
+
+	It("skips the validations listed in validationOverrides", func() {
+		clusterInstance.Spec.BaseDomain = "https://example.com"
+		clusterInstance.Spec.AdditionalNTPSources = []string{"not a host"}
+		clusterInstance.Spec.ValidationOverrides = []string{BaseDomainValidation}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		// Only the listed validation is skipped
+		err := Validate(ctx, c, clusterInstance)
+		Expect(err).To(MatchError(ContainSubstring("invalid additionalNTPSources entry")))
+
+		clusterInstance.Spec.ValidationOverrides = []string{NTPSourcesValidation, BaseDomainValidation}
+		Expect(Validate(ctx, c, clusterInstance)).To(Succeed())
+		Expect(SkippedValidations(clusterInstance)).To(Equal([]string{BaseDomainValidation, NTPSourcesValidation}))
+	})
+
+	It("fails validation when validationOverrides lists an unknown validation", func() {
+		clusterInstance.Spec.ValidationOverrides = []string{BaseDomainValidation, "foobar"}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		err := Validate(ctx, c, clusterInstance)
+		Expect(err).To(MatchError(ContainSubstring(`unknown validation "foobar" in validationOverrides`)))
+	})
+
+	It("fails validation when mutually exclusive fields are set", func() {
+		clusterInstance.Spec.ApiVIPs = []string{"192.0.2.10"}
+		clusterInstance.Spec.IngressVIPs = []string{"192.0.2.11"}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		err := Validate(ctx, c, clusterInstance)
+		Expect(err).To(MatchError(ContainSubstring("spec.apiVIPs: Forbidden")))
+		Expect(err).To(MatchError(ContainSubstring("spec.ingressVIPs: Forbidden")))
 	})
 
 	It("fails validation when clusterImageSetName reference is not defined", func() {
@@ -95,6 +611,109 @@ var _ = Describe("Validate", func() {
 		Expect(err).To(MatchError(ContainSubstring("encountered error validating ClusterImageSetNameRef")))
 	})
 
+	DescribeTable("validates the template values",
+		func(templateValues map[string]string, expected string) {
+			clusterInstance.Spec.TemplateValues = templateValues
+			Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+			err := validateTemplateValues(clusterInstance)
+			if expected == "" {
+				Expect(err).ToNot(HaveOccurred())
+				return
+			}
+			Expect(err).To(MatchError(ContainSubstring(expected)))
+			Expect(ValidationFailureReason(err)).To(Equal(conditions.TemplateValuesInvalid))
+		},
+		Entry("unset", nil, ""),
+		Entry("free-form values", map[string]string{"siteId": "du-1", "rack": "r12"}, ""),
+		Entry("reserved keys", map[string]string{"siteId": "du-1", "clusterName": "other", "baseDomain": "other"},
+			"templateValues must not override the reserved keys: baseDomain, clusterName"),
+	)
+
+	DescribeTable("validates the boot artifacts base URL",
+		func(baseURL string, valid bool) {
+			clusterInstance.Spec.BootArtifactsBaseURL = baseURL
+			Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+			err := validateBootArtifactsBaseURL(clusterInstance)
+			if valid {
+				Expect(err).ToNot(HaveOccurred())
+				return
+			}
+			Expect(err).To(MatchError(ContainSubstring("must be an http or https URL")))
+			Expect(ValidationFailureReason(err)).To(Equal(conditions.BootArtifactsInvalid))
+		},
+		Entry("unset", "", true),
+		Entry("https URL", "https://images.example.com/boot-artifacts", true),
+		Entry("http URL with a port", "http://198.51.100.1:8080/", true),
+		Entry("no scheme", "images.example.com/boot-artifacts", false),
+		Entry("unsupported scheme", "ftp://images.example.com/boot-artifacts", false),
+		Entry("no host", "https:///boot-artifacts", false),
+	)
+
+	DescribeTable("validates the disk encryption",
+		func(diskEncryption *v1alpha1.DiskEncryption, expected string) {
+			clusterInstance.Spec.DiskEncryption = diskEncryption
+			Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+			err := validateDiskEncryption(clusterInstance)
+			if expected == "" {
+				Expect(err).ToNot(HaveOccurred())
+				return
+			}
+			Expect(err).To(MatchError(ContainSubstring(expected)))
+			Expect(ValidationFailureReason(err)).To(Equal(conditions.DiskEncryptionInvalid))
+		},
+		Entry("TPM", &v1alpha1.DiskEncryption{Type: v1alpha1.DiskEncryptionTypeTPM2, EnableOn: "all"}, ""),
+		Entry("Tang", &v1alpha1.DiskEncryption{
+			Type: v1alpha1.DiskEncryptionTypeTang,
+			Tang: []v1alpha1.TangConfig{{URL: "http://198.51.100.1:7500", Thumbprint: "1c3wJKh6TQKTghTjWgS4MlIXtGk"}},
+		}, ""),
+		Entry("Tang without servers", &v1alpha1.DiskEncryption{Type: v1alpha1.DiskEncryptionTypeTang},
+			"diskEncryption type tang requires at least one Tang server"),
+		Entry("NBDE without servers", &v1alpha1.DiskEncryption{Type: v1alpha1.DiskEncryptionTypeNBDE},
+			"diskEncryption type nbde requires at least one Tang server"),
+		Entry("Tang server without a URL scheme", &v1alpha1.DiskEncryption{
+			Type: v1alpha1.DiskEncryptionTypeTang,
+			Tang: []v1alpha1.TangConfig{{URL: "198.51.100.1:7500", Thumbprint: "1c3wJKh6TQKTghTjWgS4MlIXtGk"}},
+		}, `diskEncryption.tang[0].url "198.51.100.1:7500" must be an http or https URL`),
+		Entry("Tang server without a thumbprint", &v1alpha1.DiskEncryption{
+			Type: v1alpha1.DiskEncryptionTypeTang,
+			Tang: []v1alpha1.TangConfig{{URL: "http://198.51.100.1:7500"}},
+		}, "diskEncryption.tang[0].thumbprint is required"),
+	)
+
+	Context("when FIPS mode is requested", func() {
+		createClusterImageSet := func(releaseImage string) {
+			clusterImageSet := GetMockClusterImageSet("fips-image-set")
+			clusterImageSet.Spec.ReleaseImage = releaseImage
+			Expect(c.Create(ctx, clusterImageSet)).To(Succeed())
+			clusterInstance.Spec.ClusterImageSetNameRef = clusterImageSet.Name
+			clusterInstance.Spec.FIPS = true
+		}
+
+		It("successfully validates a release that supports FIPS mode", func() {
+			createClusterImageSet("quay.io/openshift-release-dev/ocp-release:4.15.2-x86_64")
+			Expect(Validate(ctx, c, clusterInstance)).To(Succeed())
+		})
+
+		It("fails validation when the release does not support FIPS mode", func() {
+			createClusterImageSet("quay.io/openshift-release-dev/ocp-release:4.11.0-x86_64")
+			err := Validate(ctx, c, clusterInstance)
+			Expect(err).To(MatchError(ContainSubstring("FIPS mode requires a release of 4.12 or later")))
+			Expect(ValidationFailureReason(err)).To(Equal(conditions.FIPSIncompatible))
+
+			// The release is not checked when FIPS mode is disabled
+			clusterInstance.Spec.FIPS = false
+			Expect(Validate(ctx, c, clusterInstance)).To(Succeed())
+		})
+
+		It("skips the release check when the release version cannot be determined", func() {
+			createClusterImageSet("quay.io/openshift-release-dev/ocp-release@sha256:abcdef")
+			Expect(Validate(ctx, c, clusterInstance)).To(Succeed())
+		})
+	})
+
 	It("fails validation when cluster-level template refs are not defined", func() {
 		clusterInstance.Spec.TemplateRefs = []v1alpha1.TemplateRef{}
 		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
@@ -157,6 +776,25 @@ var _ = Describe("Validate", func() {
 		Expect(err).To(MatchError(ContainSubstring("failed to validate BMC credentials")))
 	})
 
+	It("validates that the referenced InfraEnv exists in the namespace of the rendered manifests", func() {
+		clusterInstance.Spec.ClusterName = "site-cluster"
+		clusterInstance.Spec.InfraEnvRef = &corev1.LocalObjectReference{Name: "shared-infraenv"}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		// The BareMetalHosts are rendered in the namespace named after the cluster, not the ClusterInstance namespace
+		Expect(c.Create(ctx, &aiv1beta1.InfraEnv{
+			ObjectMeta: metav1.ObjectMeta{Name: "shared-infraenv", Namespace: testParams.ClusterNamespace},
+		})).To(Succeed())
+		err := Validate(ctx, c, clusterInstance)
+		Expect(err).To(MatchError(ContainSubstring(
+			"failed to retrieve InfraEnv: shared-infraenv in namespace site-cluster")))
+
+		Expect(c.Create(ctx, &aiv1beta1.InfraEnv{
+			ObjectMeta: metav1.ObjectMeta{Name: "shared-infraenv", Namespace: "site-cluster"},
+		})).To(Succeed())
+		Expect(Validate(ctx, c, clusterInstance)).To(Succeed())
+	})
+
 	It("fails validation due to invalid node-level installerArgs JSON-formatted strings", func() {
 		clusterInstance.Spec.Nodes[0].InstallerArgs = "{foo:bar}"
 		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
@@ -200,4 +838,42 @@ var _ = Describe("Validate", func() {
 		err := Validate(ctx, c, clusterInstance)
 		Expect(err).To(MatchError(ContainSubstring("sno cluster-type can only have 1 control-plane agent")))
 	})
+
+	DescribeTable("checks the etcd quorum of HighlyAvailable cluster-types",
+		func(numControlPlaneAgents int, valid bool) {
+			clusterInstance.Spec.ClusterType = v1alpha1.ClusterTypeHighlyAvailable
+			clusterInstance.Spec.Nodes = nil
+			for i := 0; i < numControlPlaneAgents; i++ {
+				clusterInstance.Spec.Nodes = append(clusterInstance.Spec.Nodes, v1alpha1.NodeSpec{
+					HostName:           fmt.Sprintf("master-%d", i),
+					Role:               "master",
+					BmcAddress:         fmt.Sprintf("192.0.2.%d", i+1),
+					BmcCredentialsName: v1alpha1.BmcCredentialsName{Name: testParams.BmcCredentialsName},
+					TemplateRefs: []v1alpha1.TemplateRef{
+						{Name: testParams.NodeTemplateRef, Namespace: testParams.ClusterName}}})
+			}
+			// The workers do not take part in the quorum
+			clusterInstance.Spec.Nodes = append(clusterInstance.Spec.Nodes, v1alpha1.NodeSpec{
+				HostName:           "worker-0",
+				Role:               "worker",
+				BmcAddress:         "192.0.2.100",
+				BmcCredentialsName: v1alpha1.BmcCredentialsName{Name: testParams.BmcCredentialsName},
+				TemplateRefs: []v1alpha1.TemplateRef{
+					{Name: testParams.NodeTemplateRef, Namespace: testParams.ClusterName}}})
+			Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+			err := Validate(ctx, c, clusterInstance)
+			if valid {
+				Expect(err).ToNot(HaveOccurred())
+				return
+			}
+			Expect(err).To(MatchError(fmt.Sprintf("HighlyAvailable cluster-type requires an odd number of "+
+				"control-plane agents to keep the etcd quorum, found %d", numControlPlaneAgents)))
+			Expect(ValidationFailureReason(err)).To(Equal(conditions.ControlPlaneQuorumInvalid))
+		},
+		Entry("3 control-plane agents", 3, true),
+		Entry("5 control-plane agents", 5, true),
+		Entry("2 control-plane agents", 2, false),
+		Entry("4 control-plane agents", 4, false),
+	)
 })
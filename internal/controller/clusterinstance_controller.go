@@ -22,27 +22,56 @@ import (
 	"fmt"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
 	ci "github.com/stolostron/siteconfig/internal/controller/clusterinstance"
 	"github.com/stolostron/siteconfig/internal/controller/conditions"
 	"golang.org/x/exp/maps"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/stolostron/siteconfig/api/v1alpha1"
 )
 
-const clusterInstanceFinalizer = "clusterinstance." + v1alpha1.Group + "/finalizer"
+// ForceDeleteAnnotation, when set to "true", lets the finalizer be removed from a deleted ClusterInstance even if
+// some of its rendered manifests could not be deleted
+const ForceDeleteAnnotation = v1alpha1.Group + "/force-delete"
+
+// RegenerateInstallSecretsAnnotation requests the rendered install secrets of the ClusterInstance to be deleted and
+// re-created, it is set when the installation failed due to an expired token and removed once the secrets are deleted
+const RegenerateInstallSecretsAnnotation = v1alpha1.Group + "/regenerate-install-secrets"
+
+const (
+	clusterInstanceFinalizer = "clusterinstance." + v1alpha1.Group + "/finalizer"
+
+	// deferredChangesRequeueInterval is the interval at which deferred spec changes are re-evaluated
+	deferredChangesRequeueInterval = time.Minute
+
+	// pullSecretsRequeueInterval is the interval at which pull secrets that failed to merge are re-evaluated
+	pullSecretsRequeueInterval = time.Minute
+
+	// missingReferencesRequeueInterval is the interval at which missing secret and template references are re-checked
+	missingReferencesRequeueInterval = time.Minute
+)
 
 // ClusterInstanceReconciler reconciles a ClusterInstance object
 type ClusterInstanceReconciler struct {
@@ -51,16 +80,73 @@ type ClusterInstanceReconciler struct {
 	Recorder   record.EventRecorder
 	Log        logr.Logger
 	TmplEngine *ci.TemplateEngine
+	// RequiredMetadataKeys are the ChargebackMetadata keys every ClusterInstance must define before it is rendered
+	RequiredMetadataKeys []string
+	// PauseSwitch identifies the ConfigMap pausing all reconciliation, nil if pausing is disabled
+	PauseSwitch *PauseSwitch
+	// WatchedNamespaces restricts the reconciled ClusterInstances to the given namespaces, all if empty
+	WatchedNamespaces WatchedNamespaces
+	// DefaultTemplateRefs are the TemplateRefs used by the ClusterInstances and nodes that do not define their own
+	DefaultTemplateRefs DefaultTemplateRefs
+	// RegistryChecker checks that the release image registry is reachable before rendering, nil if the preflight is
+	// disabled
+	RegistryChecker *ci.RegistryChecker
+	// ManifestsDumpDir is the directory the rendered manifests are written to, in addition to being applied, for
+	// debugging. The manifests are not written when it is empty.
+	ManifestsDumpDir string
+	// CircuitBreaker backs off the ClusterInstances whose reconciles keep failing, nil if it is disabled
+	CircuitBreaker *CircuitBreaker
+	// APIReader reads from the API server, bypassing the cache of the Client, e.g. to probe the permissions of the
+	// controller. The Client is used when it is nil.
+	APIReader client.Reader
+	// DNSProvider creates the DNS records of the ClusterInstances managing their DNS, nil if no provider is configured
+	DNSProvider ci.DNSProvider
 }
 
-//nolint:unused
-func doNotRequeue() ctrl.Result {
+// apiReader returns the reader bypassing the cache, the Client if none is configured
+func (r *ClusterInstanceReconciler) apiReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+// completed is the result of a reconcile that has nothing left to do until the watched resources change
+func completed() ctrl.Result {
 	return ctrl.Result{Requeue: false}
 }
 
-//nolint:unused
+// waitForEvent is the result of a reconcile that is waiting on an external change (e.g. an update of a watched
+// resource) to make progress. An optional safety-net interval requeues the reconcile in case the event is missed.
+func waitForEvent(safetyNet ...time.Duration) ctrl.Result {
+	if len(safetyNet) > 0 {
+		return ctrl.Result{RequeueAfter: safetyNet[0]}
+	}
+	return ctrl.Result{}
+}
+
+// requeueAfter is the result of a reconcile that must be re-evaluated at a known point in time
+func requeueAfter(interval time.Duration) ctrl.Result {
+	return ctrl.Result{RequeueAfter: interval}
+}
+
+// earliestRequeue returns the result requeuing the soonest, the results that do not requeue are ignored
+func earliestRequeue(results ...ctrl.Result) ctrl.Result {
+	earliest := ctrl.Result{}
+	for _, result := range results {
+		if result.RequeueAfter > 0 && (earliest.RequeueAfter == 0 || result.RequeueAfter < earliest.RequeueAfter) {
+			earliest = result
+		}
+	}
+	return earliest
+}
+
+// requeueWithError is the result of a failed reconcile. A transient error is requeued with the rate limiter, while a
+// terminal or validation error, which is surfaced in a condition, is not requeued until the watched resources change.
 func requeueWithError(err error) (ctrl.Result, error) {
-	// can not be fixed by user during reconcile
+	if isTerminal(err) {
+		return ctrl.Result{}, reconcile.TerminalError(err)
+	}
 	return ctrl.Result{}, err
 }
 
@@ -71,41 +157,72 @@ func requeueWithError(err error) (ctrl.Result, error) {
 //+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;create;update;patch;delete
 //+kubebuilder:rbac:groups=hive.openshift.io,resources=clusterimagesets,verbs=get;list;watch
-//+kubebuilder:rbac:groups=agent-install.openshift.io,resources=infraenvs,verbs=get;create;update;patch;delete
-//+kubebuilder:rbac:groups=agent-install.openshift.io,resources=nmstateconfigs,verbs=get;create;update;patch;delete
+//+kubebuilder:rbac:groups=hive.openshift.io,resources=machinepools,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=agent-install.openshift.io,resources=infraenvs,verbs=get;list;create;update;patch;delete
+//+kubebuilder:rbac:groups=agent-install.openshift.io,resources=nmstateconfigs,verbs=get;list;create;update;patch;delete
 //+kubebuilder:rbac:groups=cluster.open-cluster-management.io,resources=managedclusters,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=register.open-cluster-management.io,resources=managedclusters/accept,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=cluster.open-cluster-management.io,resources=managedclustersets/join,verbs=create
-//+kubebuilder:rbac:groups=extensions.hive.openshift.io,resources=agentclusterinstalls,verbs=get;create;update;patch;delete
-//+kubebuilder:rbac:groups=extensions.hive.openshift.io,resources=imageclusterinstalls,verbs=get;create;update;patch;delete
+//+kubebuilder:rbac:groups=extensions.hive.openshift.io,resources=agentclusterinstalls,verbs=get;list;create;update;patch;delete
+//+kubebuilder:rbac:groups=extensions.hive.openshift.io,resources=imageclusterinstalls,verbs=get;list;create;update;patch;delete
 //+kubebuilder:rbac:groups=hive.openshift.io,resources=clusterdeployments,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=hive.openshift.io,resources=clusterdeployments/status,verbs=get;watch
-//+kubebuilder:rbac:groups=metal3.io,resources=baremetalhosts,verbs=get;create;update;patch;delete
-//+kubebuilder:rbac:groups=agent.open-cluster-management.io,resources=klusterletaddonconfigs,verbs=get;create;update;patch;delete
-//+kubebuilder:rbac:groups=metal3.io,resources=hostfirmwaresettings,verbs=get;create;update;patch;delete
+//+kubebuilder:rbac:groups=metal3.io,resources=baremetalhosts,verbs=get;list;create;update;patch;delete
+//+kubebuilder:rbac:groups=agent.open-cluster-management.io,resources=klusterletaddonconfigs,verbs=get;list;create;update;patch;delete
+//+kubebuilder:rbac:groups=metal3.io,resources=hostfirmwaresettings,verbs=get;list;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
-func (r *ClusterInstanceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *ClusterInstanceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res ctrl.Result, err error) {
+	// Get the ClusterInstance CR
+	clusterInstance := &v1alpha1.ClusterInstance{}
+
+	defer func() {
+		if clusterInstance.Name != "" {
+			clusterInstancePhases.record(req.NamespacedName, conditions.Phase(clusterInstance))
+		}
+		r.Log.Info("Finished reconciling ClusterInstance", "name", req.NamespacedName,
+			"summary", conditions.Summarize(clusterInstance))
+	}()
+
+	// Back off the ClusterInstance when its reconciles keep failing
 	defer func() {
-		r.Log.Info("Finished reconciling ClusterInstance", "name", req.NamespacedName)
+		reconcileErr := err
+		res, err = r.CircuitBreaker.handleReconcileResult(ctx, r.Client, clusterInstance, res, err)
+		if reconcileErr != nil && err == nil {
+			r.Log.Error(reconcileErr, "Reconcile keeps failing, backing off", "name", req.NamespacedName,
+				"backoff", r.CircuitBreaker.Backoff)
+		}
 	}()
 
 	r.Log.Info("Start reconciling ClusterInstance", "name", req.NamespacedName)
 
-	// Get the ClusterInstance CR
-	clusterInstance := &v1alpha1.ClusterInstance{}
+	if paused, err := r.PauseSwitch.IsPaused(ctx, r.Client); err != nil {
+		return requeueWithError(err)
+	} else if paused {
+		r.Log.Info("Reconciliation is paused", "name", req.NamespacedName)
+		return requeueAfter(pausedRequeueInterval), nil
+	}
+
+	if !r.WatchedNamespaces.Contains(req.Namespace) {
+		r.Log.Info("ClusterInstance is not in a watched namespace, ignoring it", "name", req.NamespacedName)
+		return completed(), nil
+	}
+
 	if err := r.Get(ctx, req.NamespacedName, clusterInstance); err != nil {
 		if errors.IsNotFound(err) {
 			r.Log.Info("ClusterInstance not found", "name", req.NamespacedName)
-			return doNotRequeue(), nil
+			clusterInstancePhases.forget(req.NamespacedName)
+			r.CircuitBreaker.forget(req.NamespacedName)
+			return completed(), nil
 		}
 		r.Log.Error(err, "Failed to get ClusterInstance", "name", req.NamespacedName)
 		// This is likely a case where the API is down, so requeue and try again shortly
 		return requeueWithError(err)
 	}
 
-	r.Log.Info("Loaded ClusterInstance", "name", req.NamespacedName, "version", clusterInstance.GetResourceVersion())
+	r.Log.Info("Loaded ClusterInstance", "name", req.NamespacedName, "version", clusterInstance.GetResourceVersion(),
+		"summary", conditions.Summarize(clusterInstance))
 
 	if res, stop, err := r.handleFinalizer(ctx, clusterInstance); !res.IsZero() || stop || err != nil {
 		if err != nil {
@@ -114,11 +231,98 @@ func (r *ClusterInstanceReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		return res, err
 	}
 
+	// Convert the spec of a ClusterInstance stored at an older schema version
+	if res, stop, err := r.handleSpecConversion(ctx, clusterInstance); err != nil {
+		return requeueWithError(err)
+	} else if stop {
+		return res, nil
+	}
+
+	// Dump the template rendering context when requested
+	if err := r.handleDumpRenderingContext(ctx, clusterInstance); err != nil {
+		return requeueWithError(err)
+	}
+
+	// Delete finished ClusterInstances whose TTL has expired, otherwise requeue until the TTL deadline
+	ttlResult, deleted, err := r.handleTTLAfterFinished(ctx, clusterInstance)
+	if deleted || err != nil {
+		return ttlResult, err
+	}
+
+	// Delete the install secrets to be regenerated, they are re-created when the templates are applied again
+	regenerating, err := r.handleRegenerateInstallSecrets(ctx, clusterInstance)
+	if err != nil {
+		return requeueWithError(err)
+	}
+
+	// Delete the hardware resources of the node to be retried, they are re-created when the templates are applied again
+	retryResult, retrying, err := r.handleRetryNode(ctx, clusterInstance)
+	if err != nil {
+		return requeueWithError(err)
+	} else if !retryResult.IsZero() {
+		return retryResult, nil
+	}
+
+	// Report the drift of an observe-only ClusterDeployment, it is compared periodically as its changes are not watched
+	observeResult, err := r.handleObservedClusterDeployment(ctx, clusterInstance)
+	if err != nil {
+		return requeueWithError(err)
+	}
+
+	// Report the registries the release image is pulled from that the pull secret holds no auth for. The pull
+	// secrets are not watched, they are checked again periodically regardless of the spec changes.
+	registriesResult, err := r.handlePullSecretRegistries(ctx, clusterInstance)
+	if err != nil {
+		return requeueWithError(err)
+	}
+
+	// Report an unreachable release image registry, when the registry preflight is enabled. The registry is not
+	// watched, it is checked again periodically regardless of the spec changes.
+	preflightResult, err := r.handleRegistryPreflight(ctx, clusterInstance)
+	if err != nil {
+		return requeueWithError(err)
+	}
+	result := earliestRequeue(ttlResult, observeResult, registriesResult, preflightResult)
+
 	// Pre-empt the reconcile-loop when the ObservedGeneration is the same as the ObjectMeta.Generation
-	if clusterInstance.Status.ObservedGeneration == clusterInstance.ObjectMeta.Generation {
+	if !regenerating && !retrying && clusterInstance.Status.ObservedGeneration == clusterInstance.ObjectMeta.Generation {
+		// The pull secrets merged into the merged pull secret are not part of the spec, propagate their rotation
+		pullSecretsResult, _, err := r.handlePullSecrets(ctx, clusterInstance)
+		if err != nil {
+			return requeueWithError(err)
+		}
 		r.Log.Info("ObservedGeneration and ObjectMeta.Generation are the same, pre-empting reconcile",
 			"ClusterInstance", req.NamespacedName)
-		return doNotRequeue(), nil
+		return earliestRequeue(result, pullSecretsResult), nil
+	}
+
+	// Append the templates selected by label to the cluster-level TemplateRefs
+	if res, stop, err := r.handleTemplateSelector(ctx, clusterInstance); stop || err != nil {
+		return res, err
+	}
+
+	// Use the default TemplateRefs for the cluster and the nodes that do not define their own
+	if err := r.handleDefaultTemplateRefs(ctx, clusterInstance); err != nil {
+		return requeueWithError(err)
+	}
+
+	// Create the BMC credentials secrets of the nodes providing inline BMC credentials
+	if res, stop, err := r.handleInlineBmcCredentials(ctx, clusterInstance); stop || err != nil {
+		return res, err
+	}
+
+	// Check that all the referenced secrets exist before creating any child resources
+	if res, stop, err := r.handleMissingReferences(ctx, clusterInstance); stop || err != nil {
+		return res, err
+	}
+
+	// Check that all the referenced template ConfigMaps exist before rendering
+	if res, stop, err := r.handleTemplateRefPermissions(ctx, clusterInstance); stop || err != nil {
+		return res, err
+	}
+
+	if res, stop, err := r.handleMissingTemplateRefs(ctx, clusterInstance); stop || err != nil {
+		return res, err
 	}
 
 	// Validate ClusterInstance
@@ -126,11 +330,46 @@ func (r *ClusterInstanceReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		return requeueWithError(err)
 	}
 
+	// Defer disruptive spec changes while the cluster is being provisioned
+	if res, deferred, err := r.handleChangesDuringProvisioning(ctx, clusterInstance); err != nil {
+		return requeueWithError(err)
+	} else if deferred {
+		return res, nil
+	}
+
+	// Only render the templates of the nodes added to an installed cluster, leaving the existing ones untouched
+	if res, stop, err := r.handleAddedNodes(ctx, clusterInstance); stop || err != nil {
+		return res, err
+	}
+
+	// Merge the pull secrets into a single pull secret when additional pull secrets are defined
+	if res, stop, err := r.handlePullSecrets(ctx, clusterInstance); stop || err != nil {
+		return res, err
+	}
+
+	// Gate the rendering on the presence of the required chargeback metadata
+	if res, stop, err := r.handleRequiredMetadata(ctx, clusterInstance); stop || err != nil {
+		return res, err
+	}
+
+	// Hold the rendering until the ClusterInstances it depends on are provisioned
+	if res, stop, err := r.handleDependencies(ctx, clusterInstance); stop || err != nil {
+		return res, err
+	}
+
+	// Create the DNS records of the cluster before it is installed
+	if err := r.handleDNSRecords(ctx, clusterInstance); err != nil {
+		return requeueWithError(err)
+	}
+
 	// Render, validate and apply templates
 	if rendered, err := r.handleRenderTemplates(ctx, clusterInstance); err != nil {
 		return requeueWithError(err)
 	} else if rendered {
 		r.Log.Info("ClusterInstance templates are rendered", "name", req.NamespacedName)
+		if err := r.updateLastAppliedSpec(ctx, clusterInstance, &clusterInstance.Spec); err != nil {
+			return requeueWithError(err)
+		}
 	} else {
 		r.Log.Info("Failed to render templates for ClusterInstance", "name", req.NamespacedName)
 	}
@@ -147,16 +386,19 @@ func (r *ClusterInstanceReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 			"ClusterInstance", req.NamespacedName)
 		patch := client.MergeFrom(clusterInstance.DeepCopy())
 		clusterInstance.Status.ObservedGeneration = clusterInstance.ObjectMeta.Generation
-		return ctrl.Result{}, conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
+		return result, conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
 	}
 
-	return doNotRequeue(), nil
+	return result, nil
 }
 
 func (r *ClusterInstanceReconciler) finalizeClusterInstance(
 	ctx context.Context,
 	clusterInstance *v1alpha1.ClusterInstance,
 ) error {
+	if clusterInstance.Spec.PreserveOnDelete {
+		return r.orphanRenderedManifests(ctx, clusterInstance)
+	}
 
 	// Group the manifests by the sync-wave
 	// This is so that the manifests can be deleted in descending order of sync-wave
@@ -190,10 +432,58 @@ func (r *ClusterInstanceReconciler) finalizeClusterInstance(
 			}
 		}
 	}
+
+	// Delete the DNS records of the cluster
+	if err := r.deleteDNSRecords(ctx, clusterInstance); err != nil {
+		r.Log.Info("Failed to delete the DNS records of the ClusterInstance", "error", err.Error())
+		return err
+	}
+
+	// Delete the remaining resources labeled as owned by the ClusterInstance
+	if err := deleteOwnedObjects(ctx, r.Client, clusterInstance); err != nil {
+		r.Log.Info("Failed to delete the resources owned by the ClusterInstance", "error", err.Error())
+		return err
+	}
 	r.Log.Info("Successfully finalized ClusterInstance", "name", clusterInstance.Name)
 	return nil
 }
 
+// orphanRenderedManifests preserves the cluster by orphaning all the rendered manifests, i.e. the ClusterDeployment,
+// the cluster install, the BareMetalHosts, whose deletion would deprovision the running nodes, the InfraEnv and the
+// ManagedCluster, instead of deleting them. The DNS records of the cluster are preserved as well.
+func (r *ClusterInstanceReconciler) orphanRenderedManifests(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+) error {
+	for _, manifest := range clusterInstance.Status.ManifestsRendered {
+		if manifest.APIGroup == nil {
+			continue
+		}
+		obj := &unstructured.Unstructured{}
+		obj.SetName(manifest.Name)
+		obj.SetNamespace(manifest.Namespace)
+		obj.SetAPIVersion(*manifest.APIGroup)
+		obj.SetKind(manifest.Kind)
+		if err := orphanObject(ctx, r.Client, clusterInstance, obj); err != nil {
+			if meta.IsNoMatchError(err) {
+				// The kind is no longer served, there is nothing left to orphan
+				continue
+			}
+			r.Log.Info("Failed to orphan resource", manifest.Kind, manifest.Name)
+			return err
+		}
+		r.Log.Info("Preserved resource on delete", manifest.Kind, manifest.Name)
+	}
+	r.Log.Info("Successfully finalized ClusterInstance, the cluster is preserved", "name", clusterInstance.Name)
+	return nil
+}
+
+// isForceDeleted returns true if the ClusterInstance is annotated to have its finalizer removed even when the
+// rendered manifests could not all be deleted
+func isForceDeleted(clusterInstance *v1alpha1.ClusterInstance) bool {
+	return clusterInstance.GetAnnotations()[ForceDeleteAnnotation] == "true"
+}
+
 func (r *ClusterInstanceReconciler) handleFinalizer(
 	ctx context.Context,
 	clusterInstance *v1alpha1.ClusterInstance,
@@ -211,9 +501,16 @@ func (r *ClusterInstanceReconciler) handleFinalizer(
 	} else if controllerutil.ContainsFinalizer(clusterInstance, clusterInstanceFinalizer) {
 		// Run finalization logic for clusterInstanceFinalizer. If the
 		// finalization logic fails, don't remove the finalizer so
-		// that we can retry during the next reconciliation.
+		// that we can retry during the next reconciliation, unless
+		// the ClusterInstance is annotated to be force deleted.
 		if err := r.finalizeClusterInstance(ctx, clusterInstance); err != nil {
-			return ctrl.Result{}, true, err
+			if !isForceDeleted(clusterInstance) {
+				return ctrl.Result{}, true, err
+			}
+			r.Log.Error(err, "Failed to delete the rendered manifests, force deleting ClusterInstance",
+				"name", clusterInstance.Name)
+			message := fmt.Sprintf("Force deleting ClusterInstance, rendered manifests may remain: %s", err.Error())
+			r.Recorder.Event(clusterInstance, corev1.EventTypeWarning, "ForceDeleted", message)
 		}
 
 		// Remove clusterInstanceFinalizer. Once all finalizers have been
@@ -240,7 +537,7 @@ func (r *ClusterInstanceReconciler) handleValidate(
 	if err != nil {
 		r.Log.Error(err, "ClusterInstance validation failed due to error", "ClusterInstance", clusterInstance.Name)
 
-		newCond.Reason = string(conditions.Failed)
+		newCond.Reason = string(ci.ValidationFailureReason(err))
 		newCond.Status = metav1.ConditionFalse
 		newCond.Message = fmt.Sprintf("Validation failed: %s", err.Error())
 
@@ -256,6 +553,32 @@ func (r *ClusterInstanceReconciler) handleValidate(
 	conditions.SetStatusCondition(&clusterInstance.Status.Conditions, conditions.ConditionType(newCond.Type),
 		conditions.ConditionReason(newCond.Reason), newCond.Status, newCond.Message)
 
+	updateNodeRootDeviceHintsStatus(clusterInstance)
+	updateNodeMACAddressesStatus(clusterInstance)
+
+	// Record the validations that have been skipped for auditing purposes
+	if skipped := ci.SkippedValidations(clusterInstance); len(skipped) > 0 {
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+			conditions.ValidationsSkipped,
+			conditions.ValidationsOverridden,
+			metav1.ConditionTrue,
+			fmt.Sprintf("Skipped validations: %s", strings.Join(skipped, ", ")))
+	} else {
+		meta.RemoveStatusCondition(&clusterInstance.Status.Conditions, string(conditions.ValidationsSkipped))
+	}
+
+	// Replay the webhook validations, a violation means the ClusterInstance was admitted without the webhook
+	if webhookErr := ci.ReplayWebhookValidations(clusterInstance); webhookErr != nil {
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+			conditions.WebhookValidationReplayed,
+			conditions.WebhookBypassed,
+			metav1.ConditionTrue,
+			fmt.Sprintf("The ClusterInstance was admitted without passing the validating webhook: %s",
+				webhookErr.Error()))
+	} else {
+		meta.RemoveStatusCondition(&clusterInstance.Status.Conditions, string(conditions.WebhookValidationReplayed))
+	}
+
 	if updateErr := conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch); updateErr != nil {
 		if err == nil {
 			r.Log.Info(
@@ -265,17 +588,80 @@ func (r *ClusterInstanceReconciler) handleValidate(
 		}
 	}
 
-	return err
+	return transientAPIError(err)
+}
+
+// updateNodeRootDeviceHintsStatus sets the RootDeviceHintsValidated condition of the nodes that set rootDeviceHints
+func updateNodeRootDeviceHintsStatus(clusterInstance *v1alpha1.ClusterInstance) {
+	for i := range clusterInstance.Spec.Nodes {
+		node := &clusterInstance.Spec.Nodes[i]
+		if node.RootDeviceHints == nil {
+			for j := range clusterInstance.Status.Nodes {
+				if clusterInstance.Status.Nodes[j].HostName == node.HostName {
+					meta.RemoveStatusCondition(&clusterInstance.Status.Nodes[j].Conditions,
+						string(conditions.RootDeviceHintsValidated))
+				}
+			}
+			continue
+		}
+
+		nodeStatus := getOrCreateNodeStatus(clusterInstance, node.HostName)
+		if err := ci.ValidateRootDeviceHints(node); err != nil {
+			conditions.SetStatusCondition(&nodeStatus.Conditions,
+				conditions.RootDeviceHintsValidated,
+				conditions.RootDeviceHintsInvalid,
+				metav1.ConditionFalse,
+				err.Error())
+			continue
+		}
+		conditions.SetStatusCondition(&nodeStatus.Conditions,
+			conditions.RootDeviceHintsValidated,
+			conditions.Completed,
+			metav1.ConditionTrue,
+			"rootDeviceHints are valid")
+	}
+}
+
+// updateNodeMACAddressesStatus sets the MACAddressesValidated condition of the nodes that declare MAC addresses
+func updateNodeMACAddressesStatus(clusterInstance *v1alpha1.ClusterInstance) {
+	for i := range clusterInstance.Spec.Nodes {
+		node := &clusterInstance.Spec.Nodes[i]
+		if len(node.MACAddresses()) == 0 {
+			for j := range clusterInstance.Status.Nodes {
+				if clusterInstance.Status.Nodes[j].HostName == node.HostName {
+					meta.RemoveStatusCondition(&clusterInstance.Status.Nodes[j].Conditions,
+						string(conditions.MACAddressesValidated))
+				}
+			}
+			continue
+		}
+
+		nodeStatus := getOrCreateNodeStatus(clusterInstance, node.HostName)
+		if err := ci.ValidateMACAddresses(node); err != nil {
+			conditions.SetStatusCondition(&nodeStatus.Conditions,
+				conditions.MACAddressesValidated,
+				conditions.MACAddressInvalid,
+				metav1.ConditionFalse,
+				err.Error())
+			continue
+		}
+		conditions.SetStatusCondition(&nodeStatus.Conditions,
+			conditions.MACAddressesValidated,
+			conditions.Completed,
+			metav1.ConditionTrue,
+			"MAC addresses are valid")
+	}
 }
 
 func (r *ClusterInstanceReconciler) renderManifests(
 	ctx context.Context,
 	clusterInstance *v1alpha1.ClusterInstance,
-) ([]interface{}, error) {
+) ([]interface{}, []v1alpha1.RenderedTemplateHash, error) {
 	r.Log.Info(fmt.Sprintf("Rendering templates for ClusterInstance %s", clusterInstance.Name))
 
 	patch := client.MergeFrom(clusterInstance.DeepCopy())
-	renderedManifests, err := r.TmplEngine.ProcessTemplates(ctx, r.Client, *clusterInstance)
+	result, err := r.TmplEngine.ProcessChangedTemplates(ctx, r.Client, *clusterInstance)
+	clusterInstance.Status.TemplateStatus = result.TemplateStatus
 	if err != nil {
 		r.Log.Error(err, "Failed to render manifests", "ClusterInstance", clusterInstance.Name)
 		conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
@@ -290,6 +676,7 @@ func (r *ClusterInstanceReconciler) renderManifests(
 			metav1.ConditionTrue,
 			"Rendered templates successfully")
 	}
+	setTemplateProducedNoManifests(clusterInstance)
 
 	if updateErr := conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch); updateErr != nil {
 		if err == nil {
@@ -300,7 +687,34 @@ func (r *ClusterInstanceReconciler) renderManifests(
 		}
 	}
 
-	return renderedManifests, err
+	return result.Manifests, result.TemplateHashes, renderError(err)
+}
+
+// setTemplateProducedNoManifests reports the TemplateRefs whose templates rendered no content in the
+// TemplateProducedNoManifests condition, and removes the condition when there are none
+func setTemplateProducedNoManifests(clusterInstance *v1alpha1.ClusterInstance) {
+	var empty []string
+	for _, templateStatus := range clusterInstance.Status.TemplateStatus {
+		if !templateStatus.Empty {
+			continue
+		}
+		description := fmt.Sprintf("ConfigMap %s/%s", templateStatus.Namespace, templateStatus.Name)
+		if templateStatus.HostName != "" {
+			description += fmt.Sprintf(" (node %s)", templateStatus.HostName)
+		}
+		empty = append(empty, description)
+	}
+
+	if len(empty) == 0 {
+		meta.RemoveStatusCondition(&clusterInstance.Status.Conditions, string(conditions.TemplateProducedNoManifests))
+		return
+	}
+
+	conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+		conditions.TemplateProducedNoManifests,
+		conditions.EmptyRender,
+		metav1.ConditionTrue,
+		fmt.Sprintf("Templates rendered no manifests: %s", strings.Join(empty, ", ")))
 }
 
 // getSyncWave extracts the syncWave from the given object manifest
@@ -496,6 +910,11 @@ func (r *ClusterInstanceReconciler) executeRenderedManifests(
 				successfulExecution = false
 				setManifestFailure(manifestRef, err)
 			} else {
+				setOwnedByLabel(clusterInstance, &obj)
+				if manifestRef.Namespace != clusterInstance.Namespace {
+					// An owner reference cannot cross namespaces, record the ownership in labels instead
+					setLabelOwnership(clusterInstance, &obj)
+				}
 				if result, err := createOrPatch(
 					ctx, c, obj,
 					setOwnerRefFunc(manifestRef.Namespace, clusterInstance, &obj, r.Scheme)); err != nil {
@@ -663,11 +1082,12 @@ func (r *ClusterInstanceReconciler) handleRenderTemplates(
 	var (
 		unsortedManifests []interface{}
 		manifestGroups    map[int][]interface{}
+		hashes            []v1alpha1.RenderedTemplateHash
 	)
 
-	// Render templates manifests
+	// Render templates manifests, only the templates that changed since the last applied rendering are rendered
 	r.Log.Info(fmt.Sprintf("Rendering templates for ClusterInstance %s", clusterInstance.Name))
-	unsortedManifests, err = r.renderManifests(ctx, clusterInstance)
+	unsortedManifests, hashes, err = r.renderManifests(ctx, clusterInstance)
 	if err != nil {
 		r.Log.Info(
 			fmt.Sprintf("encountered error while rendering templates for ClusterInstance %s, err: %v",
@@ -675,6 +1095,12 @@ func (r *ClusterInstanceReconciler) handleRenderTemplates(
 		return
 	}
 
+	// Nothing to validate nor apply when none of the templates changed
+	if equality.Semantic.DeepEqual(hashes, clusterInstance.Status.RenderedTemplateHashes) {
+		r.Log.Info(fmt.Sprintf("Templates are unchanged for ClusterInstance %s, skipping apply", clusterInstance.Name))
+		return true, nil
+	}
+
 	// Organize rendered manifests by sync-wave and sort groups by manifest type
 	manifestGroups, err = groupAndSortManifests(unsortedManifests)
 	if err != nil {
@@ -684,17 +1110,722 @@ func (r *ClusterInstanceReconciler) handleRenderTemplates(
 		return
 	}
 
+	// Write the rendered manifests to disk for debugging, a failure does not prevent them from being applied
+	if r.ManifestsDumpDir != "" {
+		if dumpErr := ci.DumpManifests(r.ManifestsDumpDir, clusterInstance, manifestGroups); dumpErr != nil {
+			r.Log.Error(dumpErr, "Failed to dump the rendered manifests", "ClusterInstance", clusterInstance.Name)
+		} else {
+			r.Log.Info("Dumped the rendered manifests", "ClusterInstance", clusterInstance.Name,
+				"path", ci.ManifestsDumpPath(r.ManifestsDumpDir, clusterInstance))
+		}
+	}
+
+	// The observe-only ClusterDeployment is applied by the user
+	if clusterInstance.Spec.ObserveOnlyClusterDeployment {
+		if err = excludeClusterDeploymentManifests(manifestGroups); err != nil {
+			return
+		}
+	}
+
 	// Validate rendered manifests using kubernetes dry-run
 	if rendered, err = r.validateRenderedManifests(ctx, clusterInstance, manifestGroups); !rendered || err != nil {
 		return
 	}
 
 	// Apply the rendered manifests
-	rendered, err = r.applyRenderedManifests(ctx, clusterInstance, manifestGroups)
+	if rendered, err = r.applyRenderedManifests(ctx, clusterInstance, manifestGroups); !rendered || err != nil {
+		return
+	}
+
+	// Delete the previously applied manifests that are no longer rendered, e.g. those of a removed node
+	if err = r.pruneOrphanedManifests(ctx, clusterInstance); err != nil {
+		return false, err
+	}
+
+	// Record the hashes of the applied templates
+	patch := client.MergeFrom(clusterInstance.DeepCopy())
+	clusterInstance.Status.RenderedTemplateHashes = hashes
+	err = conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
 
 	return
 }
 
+// isProvisioningInProgress returns true if the ClusterInstance Provisioned condition reports an in-progress installation
+func isProvisioningInProgress(clusterInstance *v1alpha1.ClusterInstance) bool {
+	provisioned := meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.Provisioned))
+	return provisioned != nil && provisioned.Reason == string(conditions.InProgress)
+}
+
+// finishedTime returns the time at which the ClusterInstance reached a terminal state, i.e. provisioning failed or the
+// cluster was deprovisioned, or nil if the ClusterInstance has not finished
+func finishedTime(clusterInstance *v1alpha1.ClusterInstance) *metav1.Time {
+	provisioned := meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.Provisioned))
+	if provisioned == nil {
+		return nil
+	}
+	switch conditions.ConditionReason(provisioned.Reason) {
+	case conditions.Failed, conditions.Deprovisioned:
+		return &provisioned.LastTransitionTime
+	}
+	return nil
+}
+
+// handleTTLAfterFinished deletes the ClusterInstance once it has been in a terminal state for longer than
+// Spec.TTLSecondsAfterFinished. When the TTL has not yet expired, the returned result requeues the ClusterInstance at
+// the TTL deadline. It returns true when the ClusterInstance has been deleted and the reconcile should stop.
+func (r *ClusterInstanceReconciler) handleTTLAfterFinished(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+) (ctrl.Result, bool, error) {
+	if clusterInstance.Spec.TTLSecondsAfterFinished == nil {
+		return completed(), false, nil
+	}
+
+	finishedAt := finishedTime(clusterInstance)
+	if finishedAt == nil {
+		return completed(), false, nil
+	}
+
+	ttl := time.Duration(*clusterInstance.Spec.TTLSecondsAfterFinished) * time.Second
+	if remaining := time.Until(finishedAt.Add(ttl)); remaining > 0 {
+		return requeueAfter(remaining), false, nil
+	}
+
+	r.Log.Info("Deleting finished ClusterInstance, TTL expired", "ClusterInstance", clusterInstance.Name,
+		"finishedAt", finishedAt.Time, "ttl", ttl)
+	if err := r.Delete(ctx, clusterInstance); err != nil && !errors.IsNotFound(err) {
+		return ctrl.Result{}, false, err
+	}
+	return completed(), true, nil
+}
+
+// handleDumpRenderingContext writes the (redacted) template rendering context of the ClusterInstance to a ConfigMap
+// owned by the ClusterInstance when the DumpRenderingContextAnnotation is set, and then removes the annotation
+func (r *ClusterInstanceReconciler) handleDumpRenderingContext(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+) error {
+	if _, ok := clusterInstance.GetAnnotations()[ci.DumpRenderingContextAnnotation]; !ok {
+		return nil
+	}
+
+	dump, err := ci.DumpRenderingContext(clusterInstance)
+	if err != nil {
+		return err
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ci.RenderingContextConfigMapName(clusterInstance),
+			Namespace: clusterInstance.Namespace,
+		},
+	}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, configMap, func() error {
+		configMap.Data = dump
+		setOwnedByLabel(clusterInstance, configMap)
+		return ctrl.SetControllerReference(clusterInstance, configMap, r.Scheme)
+	}); err != nil {
+		return err
+	}
+	r.Log.Info("Dumped rendering context", "ClusterInstance", clusterInstance.Name, "ConfigMap", configMap.Name)
+
+	patch := client.MergeFrom(clusterInstance.DeepCopy())
+	annotations := clusterInstance.GetAnnotations()
+	delete(annotations, ci.DumpRenderingContextAnnotation)
+	clusterInstance.SetAnnotations(annotations)
+	return r.Patch(ctx, clusterInstance, patch)
+}
+
+// installTokenKinds are the kinds of the rendered manifests carrying the install and ignition tokens, they are
+// deleted to be re-created with new tokens: the install Secrets and the InfraEnv, whose discovery image embeds the
+// ignition token
+var installTokenKinds = map[string]bool{"Secret": true, "InfraEnv": true}
+
+// handleRegenerateInstallSecrets deletes the rendered manifests carrying the install and ignition tokens of a
+// ClusterInstance annotated with the RegenerateInstallSecretsAnnotation and clears the rendered template hashes, so
+// that all the templates are rendered and applied again, re-creating them with new tokens. It returns true when the
+// secrets are being regenerated.
+func (r *ClusterInstanceReconciler) handleRegenerateInstallSecrets(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+) (bool, error) {
+	if _, ok := clusterInstance.GetAnnotations()[RegenerateInstallSecretsAnnotation]; !ok {
+		return false, nil
+	}
+
+	for _, manifest := range clusterInstance.Status.ManifestsRendered {
+		if !installTokenKinds[manifest.Kind] || manifest.APIGroup == nil {
+			continue
+		}
+		obj := &unstructured.Unstructured{}
+		obj.SetName(manifest.Name)
+		obj.SetNamespace(manifest.Namespace)
+		obj.SetAPIVersion(*manifest.APIGroup)
+		obj.SetKind(manifest.Kind)
+		if err := r.Client.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+			r.Log.Info("Failed to delete install token manifest", manifest.Kind, manifest.Name)
+			return false, err
+		}
+		r.Log.Info("Deleted install token manifest to be regenerated", manifest.Kind, manifest.Name)
+	}
+
+	patch := client.MergeFrom(clusterInstance.DeepCopy())
+	now := metav1.Now()
+	clusterInstance.Status.RenderedTemplateHashes = nil
+	clusterInstance.Status.LastInstallSecretsRegeneration = &now
+	if err := conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch); err != nil {
+		return false, err
+	}
+	r.Recorder.Event(clusterInstance, corev1.EventTypeNormal, "InstallSecretsRegenerated",
+		"Deleted the install secrets and tokens to be regenerated after an installation failure due to an "+
+			"expired token")
+
+	patch = client.MergeFrom(clusterInstance.DeepCopy())
+	annotations := clusterInstance.GetAnnotations()
+	delete(annotations, RegenerateInstallSecretsAnnotation)
+	clusterInstance.SetAnnotations(annotations)
+	return true, r.Patch(ctx, clusterInstance, patch)
+}
+
+// annotationSetPredicate triggers a reconcile when the given annotation is added or its value changes
+func annotationSetPredicate(annotation string) predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			newValue, ok := e.ObjectNew.GetAnnotations()[annotation]
+			return ok && newValue != e.ObjectOld.GetAnnotations()[annotation]
+		},
+	}
+}
+
+// provisionedReasonChangedPredicate triggers a reconcile when the reason of the Provisioned condition changes
+func provisionedReasonChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldCI, okOld := e.ObjectOld.(*v1alpha1.ClusterInstance)
+			newCI, okNew := e.ObjectNew.(*v1alpha1.ClusterInstance)
+			if !okOld || !okNew {
+				return false
+			}
+			oldCond := meta.FindStatusCondition(oldCI.Status.Conditions, string(conditions.Provisioned))
+			newCond := meta.FindStatusCondition(newCI.Status.Conditions, string(conditions.Provisioned))
+			if oldCond == nil || newCond == nil {
+				return oldCond != newCond
+			}
+			return oldCond.Reason != newCond.Reason
+		},
+	}
+}
+
+// handlePullSecrets merges the registry auths of the PullSecretRef and PullSecretRefs secrets into a single pull secret,
+// owned by the ClusterInstance, and reports the outcome in the PullSecretsMerged condition. It returns true when the
+// pull secrets could not be merged (e.g. a missing pull secret or conflicting auths for the same registry) and the
+// reconcile should stop. The pull secrets are watched, the merged pull secret is updated when one of them is rotated.
+func (r *ClusterInstanceReconciler) handlePullSecrets(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+) (ctrl.Result, bool, error) {
+	patch := client.MergeFrom(clusterInstance.DeepCopy())
+
+	if len(clusterInstance.Spec.PullSecretRefs) == 0 {
+		if meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.PullSecretsMerged)) != nil {
+			meta.RemoveStatusCondition(&clusterInstance.Status.Conditions, string(conditions.PullSecretsMerged))
+			return completed(), false, conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
+		}
+		return completed(), false, nil
+	}
+
+	var (
+		secrets []*corev1.Secret
+		missing []string
+	)
+	for _, pullSecretRef := range ci.PullSecretRefs(clusterInstance) {
+		secret := &corev1.Secret{}
+		key := types.NamespacedName{Name: pullSecretRef.Name, Namespace: clusterInstance.Namespace}
+		if err := r.Get(ctx, key, secret); err != nil {
+			if !errors.IsNotFound(err) {
+				return ctrl.Result{}, true, err
+			}
+			missing = append(missing, pullSecretRef.Name)
+			continue
+		}
+		secrets = append(secrets, secret)
+	}
+
+	var (
+		merged    []byte
+		conflicts []string
+		err       error
+	)
+	if len(missing) == 0 {
+		merged, conflicts, err = ci.MergePullSecrets(secrets)
+	}
+	if len(missing) > 0 || err != nil || len(conflicts) > 0 {
+		var (
+			reason  conditions.ConditionReason
+			message string
+		)
+		if len(missing) > 0 {
+			reason = conditions.ReferencesNotFound
+			message = fmt.Sprintf("Pull secrets not found: %s", strings.Join(missing, ", "))
+		} else if err != nil {
+			reason = conditions.Failed
+			message = fmt.Sprintf("Failed to merge pull secrets: %s", err.Error())
+		} else {
+			reason = conditions.PullSecretConflict
+			message = fmt.Sprintf("Pull secrets have conflicting auths for registries: %s", strings.Join(conflicts, ", "))
+		}
+		r.Log.Info(message, "ClusterInstance", clusterInstance.Name)
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+			conditions.PullSecretsMerged,
+			reason,
+			metav1.ConditionFalse,
+			message)
+		// Wait for the pull secrets to be updated, with a safety-net requeue in case an update is missed
+		return waitForEvent(pullSecretsRequeueInterval), true,
+			conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
+	}
+
+	mergedSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ci.MergedPullSecretName(clusterInstance),
+			Namespace: clusterInstance.Namespace,
+		},
+	}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, mergedSecret, func() error {
+		mergedSecret.Type = corev1.SecretTypeDockerConfigJson
+		mergedSecret.Data = map[string][]byte{corev1.DockerConfigJsonKey: merged}
+		setOwnedByLabel(clusterInstance, mergedSecret)
+		return ctrl.SetControllerReference(clusterInstance, mergedSecret, r.Scheme)
+	}); err != nil {
+		return ctrl.Result{}, true, err
+	}
+
+	conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+		conditions.PullSecretsMerged,
+		conditions.Completed,
+		metav1.ConditionTrue,
+		fmt.Sprintf("Merged %d pull secrets into %s", len(secrets), mergedSecret.Name))
+	return completed(), false, conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
+}
+
+// mapPullSecretToClusterInstances enqueues the ClusterInstances merging the given pull secret, so that its rotation is
+// propagated to their merged pull secret
+func (r *ClusterInstanceReconciler) mapPullSecretToClusterInstances(
+	ctx context.Context,
+	obj client.Object,
+) []reconcile.Request {
+	clusterInstances := &v1alpha1.ClusterInstanceList{}
+	if err := r.List(ctx, clusterInstances, client.InNamespace(obj.GetNamespace())); err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := []reconcile.Request{}
+	for _, clusterInstance := range clusterInstances.Items {
+		if len(clusterInstance.Spec.PullSecretRefs) == 0 {
+			continue
+		}
+		for _, pullSecretRef := range ci.PullSecretRefs(&clusterInstance) {
+			if pullSecretRef.Name == obj.GetName() {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Name: clusterInstance.Name, Namespace: clusterInstance.Namespace},
+				})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// handlePullSecretRegistries checks that the pull secret holds an auth for each registry the release image is pulled
+// from, as derived from the release image and the image digest sources, and reports the missing registries in the
+// PullSecretMissingRegistry condition. The check is informative, it does not stop the reconcile; while registries are
+// missing, the ClusterInstance is requeued to check the pull secret again.
+func (r *ClusterInstanceReconciler) handlePullSecretRegistries(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+) (ctrl.Result, error) {
+	// A missing ClusterImageSet or pull secret is reported by the validation
+	clusterImageSet := &hivev1.ClusterImageSet{}
+	if err := r.Get(ctx, types.NamespacedName{Name: clusterInstance.Spec.ClusterImageSetNameRef},
+		clusterImageSet); err != nil {
+		return completed(), client.IgnoreNotFound(err)
+	}
+	pullSecret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{
+		Name:      ci.EffectivePullSecretName(clusterInstance),
+		Namespace: clusterInstance.Namespace,
+	}, pullSecret); err != nil {
+		return completed(), client.IgnoreNotFound(err)
+	}
+
+	patch := client.MergeFrom(clusterInstance.DeepCopy())
+
+	missing, err := ci.MissingRegistries(pullSecret, ci.RequiredRegistries(clusterImageSet.Spec.ReleaseImage,
+		clusterInstance.Spec.ImageDigestSources))
+	if err != nil {
+		return completed(), err
+	}
+	if len(missing) == 0 {
+		if meta.RemoveStatusCondition(&clusterInstance.Status.Conditions,
+			string(conditions.PullSecretMissingRegistry)) {
+			return completed(), conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
+		}
+		return completed(), nil
+	}
+
+	message := fmt.Sprintf("Pull secret %s has no auth for the registries: %s", pullSecret.Name,
+		strings.Join(missing, ", "))
+	r.Log.Info(message, "ClusterInstance", clusterInstance.Name)
+	conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+		conditions.PullSecretMissingRegistry,
+		conditions.RegistryAuthMissing,
+		metav1.ConditionTrue,
+		message)
+	// The pull secrets are not watched, re-evaluate them periodically
+	return requeueAfter(pullSecretsRequeueInterval), conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
+}
+
+// handleRegistryPreflight checks that the registry of the release image is reachable and accepts the credentials of
+// the pull secret, and reports a failure in the RegistryUnreachable condition. The check is informative, it does not
+// stop the reconcile. The results are cached per registry by the RegistryChecker, which sets the interval at which
+// the ClusterInstance is requeued to check the registry again.
+func (r *ClusterInstanceReconciler) handleRegistryPreflight(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+) (ctrl.Result, error) {
+	if r.RegistryChecker == nil {
+		return completed(), nil
+	}
+	result := completed()
+	if r.RegistryChecker.Interval > 0 {
+		result = requeueAfter(r.RegistryChecker.Interval)
+	}
+
+	// A missing ClusterImageSet or pull secret is reported by the validation
+	clusterImageSet := &hivev1.ClusterImageSet{}
+	if err := r.Get(ctx, types.NamespacedName{Name: clusterInstance.Spec.ClusterImageSetNameRef},
+		clusterImageSet); err != nil {
+		return result, client.IgnoreNotFound(err)
+	}
+	pullSecret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{
+		Name:      ci.EffectivePullSecretName(clusterInstance),
+		Namespace: clusterInstance.Namespace,
+	}, pullSecret); err != nil {
+		return result, client.IgnoreNotFound(err)
+	}
+
+	patch := client.MergeFrom(clusterInstance.DeepCopy())
+
+	registry := ci.ImageRegistry(clusterImageSet.Spec.ReleaseImage)
+	auth, err := ci.RegistryAuth(pullSecret, registry)
+	if err == nil {
+		err = r.RegistryChecker.CachedCheck(ctx, registry, auth)
+	}
+	if err == nil {
+		if meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.RegistryUnreachable)) != nil {
+			meta.RemoveStatusCondition(&clusterInstance.Status.Conditions, string(conditions.RegistryUnreachable))
+			return result, conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
+		}
+		return result, nil
+	}
+
+	message := fmt.Sprintf("Registry %s of the release image is unreachable: %s", registry, err.Error())
+	r.Log.Info(message, "ClusterInstance", clusterInstance.Name)
+	conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+		conditions.RegistryUnreachable,
+		conditions.Failed,
+		metav1.ConditionTrue,
+		message)
+	return result, conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
+}
+
+// handleInlineBmcCredentials creates (or updates) the BMC credentials secret of each node providing inline BMC
+// credentials, the secrets are owned by the ClusterInstance. Nothing is created when the inline BMC credentials are
+// not allowed, the validation failure is reported instead and it returns true so that the reconcile stops until the
+// spec is fixed.
+func (r *ClusterInstanceReconciler) handleInlineBmcCredentials(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+) (ctrl.Result, bool, error) {
+	hasInlineBmcCredentials := false
+	for _, node := range clusterInstance.Spec.Nodes {
+		hasInlineBmcCredentials = hasInlineBmcCredentials || node.BmcCredentials != nil
+	}
+	if !hasInlineBmcCredentials {
+		return completed(), false, nil
+	}
+
+	if err := v1alpha1.ValidateMutuallyExclusiveFields(&clusterInstance.Spec); err != nil {
+		r.Log.Info("Inline BMC credentials rejected", "ClusterInstance", clusterInstance.Name, "error", err.Error())
+		patch := client.MergeFrom(clusterInstance.DeepCopy())
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+			conditions.ClusterInstanceValidated,
+			conditions.Failed,
+			metav1.ConditionFalse,
+			fmt.Sprintf("Validation failed: %s", err.Error()))
+		// Fixing the spec triggers a new reconcile
+		return waitForEvent(), true, conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
+	}
+
+	for _, node := range clusterInstance.Spec.Nodes {
+		if node.BmcCredentials == nil {
+			continue
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      node.InlineBmcCredentialsSecretName(),
+				Namespace: clusterInstance.Namespace,
+			},
+		}
+		if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+			secret.Type = corev1.SecretTypeOpaque
+			secret.Data = map[string][]byte{
+				"username": []byte(node.BmcCredentials.Username),
+				"password": []byte(node.BmcCredentials.Password),
+			}
+			setOwnedByLabel(clusterInstance, secret)
+			return ctrl.SetControllerReference(clusterInstance, secret, r.Scheme)
+		}); err != nil {
+			return ctrl.Result{}, true,
+				fmt.Errorf("failed to create the BMC credentials secret of node %s: %w", node.HostName, err)
+		}
+		r.Log.Info("Created BMC credentials secret from inline credentials", "ClusterInstance", clusterInstance.Name,
+			"Secret", secret.Name)
+	}
+	return completed(), false, nil
+}
+
+// handleMissingReferences checks that the secrets referenced by the ClusterInstance exist and reports the missing ones
+// in the MissingReferences condition. It returns true when secrets are missing and the reconcile should stop, so that
+// provisioning is not started with missing prerequisites.
+func (r *ClusterInstanceReconciler) handleMissingReferences(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+) (ctrl.Result, bool, error) {
+	patch := client.MergeFrom(clusterInstance.DeepCopy())
+
+	missing, err := ci.MissingSecretReferences(ctx, r.Client, clusterInstance)
+	if err != nil {
+		return ctrl.Result{}, true, err
+	}
+
+	if len(missing) == 0 {
+		if meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.MissingReferences)) != nil {
+			meta.RemoveStatusCondition(&clusterInstance.Status.Conditions, string(conditions.MissingReferences))
+			return completed(), false, conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
+		}
+		return completed(), false, nil
+	}
+
+	message := fmt.Sprintf("Missing referenced resources: %s", strings.Join(missing, ", "))
+	r.Log.Info(message, "ClusterInstance", clusterInstance.Name)
+	conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+		conditions.MissingReferences,
+		conditions.ReferencesNotFound,
+		metav1.ConditionTrue,
+		message)
+	// The secrets are not watched, re-check them periodically
+	return requeueAfter(missingReferencesRequeueInterval), true,
+		conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
+}
+
+// handleTemplateRefPermissions checks that the controller is allowed to read the template ConfigMaps referenced by the
+// ClusterInstance and reports the namespaces and templates it cannot read in the InsufficientPermissions condition, so
+// that an RBAC gap is not reported as a generic rendering failure. It returns true when a template cannot be read and
+// the reconcile should stop.
+func (r *ClusterInstanceReconciler) handleTemplateRefPermissions(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+) (ctrl.Result, bool, error) {
+	patch := client.MergeFrom(clusterInstance.DeepCopy())
+
+	// The cached Client never reports a missing permission as Forbidden, its informers fail to list and watch instead
+	forbidden, namespaces, err := ci.ForbiddenTemplateReferences(ctx, r.apiReader(), clusterInstance)
+	if err != nil {
+		return ctrl.Result{}, true, err
+	}
+
+	if len(forbidden) == 0 {
+		if meta.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.InsufficientPermissions)) != nil {
+			meta.RemoveStatusCondition(&clusterInstance.Status.Conditions, string(conditions.InsufficientPermissions))
+			return completed(), false, conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
+		}
+		return completed(), false, nil
+	}
+
+	message := fmt.Sprintf("Not allowed to get the configmaps of namespaces %s: %s",
+		strings.Join(namespaces, ", "), strings.Join(forbidden, ", "))
+	r.Log.Info(message, "ClusterInstance", clusterInstance.Name)
+	conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+		conditions.InsufficientPermissions,
+		conditions.ReadForbidden,
+		metav1.ConditionTrue,
+		message)
+	// The RBAC changes are not watched, re-check the permissions periodically
+	return requeueAfter(missingReferencesRequeueInterval), true,
+		conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
+}
+
+// handleMissingTemplateRefs checks that the template ConfigMaps referenced by the ClusterInstance exist and reports the
+// missing ones in the TemplateRefNotFound condition. It returns true when templates are missing and the reconcile
+// should stop.
+func (r *ClusterInstanceReconciler) handleMissingTemplateRefs(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+) (ctrl.Result, bool, error) {
+	patch := client.MergeFrom(clusterInstance.DeepCopy())
+
+	missing, err := ci.MissingTemplateReferences(ctx, r.Client, clusterInstance)
+	if err != nil {
+		return ctrl.Result{}, true, err
+	}
+
+	if len(missing) == 0 {
+		if meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.TemplateRefNotFound)) != nil {
+			meta.RemoveStatusCondition(&clusterInstance.Status.Conditions, string(conditions.TemplateRefNotFound))
+			return completed(), false, conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
+		}
+		return completed(), false, nil
+	}
+
+	message := fmt.Sprintf("Missing referenced templates: %s", strings.Join(missing, ", "))
+	r.Log.Info(message, "ClusterInstance", clusterInstance.Name)
+	conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+		conditions.TemplateRefNotFound,
+		conditions.ReferencesNotFound,
+		metav1.ConditionTrue,
+		message)
+	// The template ConfigMaps are not watched, re-check them periodically
+	return requeueAfter(missingReferencesRequeueInterval), true,
+		conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
+}
+
+// handleRequiredMetadata checks that the ChargebackMetadata defines all the RequiredMetadataKeys and reports the missing
+// keys in the MetadataIncomplete condition. It returns true when keys are missing and the reconcile should stop.
+func (r *ClusterInstanceReconciler) handleRequiredMetadata(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+) (ctrl.Result, bool, error) {
+	patch := client.MergeFrom(clusterInstance.DeepCopy())
+
+	missing := ci.MissingMetadataKeys(clusterInstance, r.RequiredMetadataKeys)
+	if len(missing) == 0 {
+		if meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.MetadataIncomplete)) != nil {
+			meta.RemoveStatusCondition(&clusterInstance.Status.Conditions, string(conditions.MetadataIncomplete))
+			return completed(), false, conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
+		}
+		return completed(), false, nil
+	}
+
+	message := fmt.Sprintf("Missing required chargebackMetadata keys: %s", strings.Join(missing, ", "))
+	r.Log.Info(message, "ClusterInstance", clusterInstance.Name)
+	conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+		conditions.MetadataIncomplete,
+		conditions.MissingMetadataKeys,
+		metav1.ConditionTrue,
+		message)
+	// Adding the missing keys changes the spec, which triggers a new reconcile
+	return waitForEvent(), true, conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
+}
+
+// updateLastAppliedSpec records the given spec as the last successfully applied spec of the ClusterInstance
+func (r *ClusterInstanceReconciler) updateLastAppliedSpec(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+	spec *v1alpha1.ClusterInstanceSpec,
+) error {
+	patch := client.MergeFrom(clusterInstance.DeepCopy())
+	if err := ci.SetLastAppliedSpec(clusterInstance, spec); err != nil {
+		return err
+	}
+	if err := conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch); err != nil {
+		return err
+	}
+
+	// Drop the legacy annotation now that the status records the last applied spec
+	if _, ok := clusterInstance.GetAnnotations()[ci.LastAppliedSpecAnnotation]; !ok {
+		return nil
+	}
+	patch = client.MergeFrom(clusterInstance.DeepCopy())
+	annotations := clusterInstance.GetAnnotations()
+	delete(annotations, ci.LastAppliedSpecAnnotation)
+	clusterInstance.SetAnnotations(annotations)
+	return r.Patch(ctx, clusterInstance, patch)
+}
+
+// handleChangesDuringProvisioning classifies the spec changes made since the last applied spec while provisioning is
+// in-progress. Safe changes (labels and annotations) are rendered and applied, whereas disruptive changes are deferred
+// until provisioning completes or fails and are listed in the ChangesDeferredDuringProvisioning condition.
+// It returns true when changes have been deferred and the reconcile should stop.
+func (r *ClusterInstanceReconciler) handleChangesDuringProvisioning(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+) (ctrl.Result, bool, error) {
+
+	var (
+		appliedSpec   *v1alpha1.ClusterInstanceSpec
+		unsafeChanges []string
+		err           error
+	)
+
+	if isProvisioningInProgress(clusterInstance) {
+		if appliedSpec, err = ci.GetLastAppliedSpec(clusterInstance); err != nil {
+			return ctrl.Result{}, false, err
+		}
+		if appliedSpec != nil {
+			if unsafeChanges, err = ci.UnsafeSpecChanges(appliedSpec, &clusterInstance.Spec); err != nil {
+				return ctrl.Result{}, false, err
+			}
+		}
+	}
+
+	if len(unsafeChanges) == 0 {
+		// Clear previously deferred changes, if any
+		if meta.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.ChangesDeferredDuringProvisioning)) != nil {
+			patch := client.MergeFrom(clusterInstance.DeepCopy())
+			meta.RemoveStatusCondition(&clusterInstance.Status.Conditions,
+				string(conditions.ChangesDeferredDuringProvisioning))
+			return ctrl.Result{}, false, conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
+		}
+		return ctrl.Result{}, false, nil
+	}
+
+	r.Log.Info("Deferring spec changes while provisioning is in-progress",
+		"ClusterInstance", clusterInstance.Name, "changes", unsafeChanges)
+
+	// Render and apply the safe changes on top of the last applied spec
+	mergedSpec := ci.MergeSafeSpecChanges(appliedSpec, &clusterInstance.Spec)
+	effectiveInstance := clusterInstance.DeepCopy()
+	effectiveInstance.Spec = *mergedSpec
+
+	rendered, err := r.handleRenderTemplates(ctx, effectiveInstance)
+	if err != nil {
+		return ctrl.Result{}, true, err
+	}
+	if rendered {
+		if err := r.updateLastAppliedSpec(ctx, effectiveInstance, mergedSpec); err != nil {
+			return ctrl.Result{}, true, err
+		}
+	}
+
+	patch := client.MergeFrom(effectiveInstance.DeepCopy())
+	conditions.SetStatusCondition(&effectiveInstance.Status.Conditions,
+		conditions.ChangesDeferredDuringProvisioning,
+		conditions.InProgress,
+		metav1.ConditionTrue,
+		fmt.Sprintf("Changes to the following fields are deferred until provisioning completes or fails: %s",
+			strings.Join(unsafeChanges, ", ")))
+	if err := conditions.PatchCIStatus(ctx, r.Client, effectiveInstance, patch); err != nil {
+		return ctrl.Result{}, true, err
+	}
+
+	// Wait for provisioning to complete or fail, with a safety-net requeue to re-evaluate the deferred changes
+	return waitForEvent(deferredChangesRequeueInterval), true, nil
+}
+
 func (r *ClusterInstanceReconciler) updateSuppressedManifestsStatus(
 	ctx context.Context,
 	clusterInstance *v1alpha1.ClusterInstance,
@@ -728,9 +1859,28 @@ func (r *ClusterInstanceReconciler) updateSuppressedManifestsStatus(
 func (r *ClusterInstanceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.Recorder = mgr.GetEventRecorderFor("ClusterInstance")
 
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1alpha1.ClusterInstance{}).
-		WithEventFilter(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.LabelChangedPredicate{})).
-		WithOptions(controller.Options{MaxConcurrentReconciles: 1}).
-		Complete(r)
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.ClusterInstance{},
+			builder.WithPredicates(r.WatchedNamespaces.predicate(), predicate.Or(
+				predicate.GenerationChangedPredicate{},
+				predicate.LabelChangedPredicate{},
+				provisionedReasonChangedPredicate(),
+				annotationSetPredicate(ci.DumpRenderingContextAnnotation),
+				annotationSetPredicate(RegenerateInstallSecretsAnnotation),
+				annotationSetPredicate(RetryNodeAnnotation)))).
+		// Propagate the rotation of the pull secrets to the merged pull secrets
+		Watches(&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.mapPullSecretToClusterInstances),
+			builder.WithPredicates(r.WatchedNamespaces.predicate())).
+		WithOptions(controller.Options{MaxConcurrentReconciles: 1})
+
+	// Reconcile all ClusterInstances when the pause ConfigMap changes
+	if r.PauseSwitch != nil {
+		b = b.Watches(&corev1.ConfigMap{},
+			handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, _ client.Object) []reconcile.Request {
+				return mapPauseToClusterInstances(ctx, r.Client)
+			}),
+			builder.WithPredicates(r.PauseSwitch.predicate()))
+	}
+	return b.Complete(instrument("clusterinstance", r))
 }
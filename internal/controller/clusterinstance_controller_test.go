@@ -18,27 +18,39 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
+	"time"
 
 	bmh_v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	aiv1beta1 "github.com/openshift/assisted-service/api/v1beta1"
 	hivev1 "github.com/openshift/hive/apis/hive/v1"
 	"github.com/stolostron/siteconfig/api/v1alpha1"
 	ci "github.com/stolostron/siteconfig/internal/controller/clusterinstance"
 	"github.com/stolostron/siteconfig/internal/controller/conditions"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 var _ = Describe("Reconcile", func() {
@@ -103,7 +115,7 @@ var _ = Describe("Reconcile", func() {
 		}
 		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
 		Expect(err).NotTo(HaveOccurred())
-		Expect(res).To(Equal(ctrl.Result{}))
+		Expect(res).To(Equal(completed()))
 	})
 
 	It("doesn't error for a missing ClusterInstance", func() {
@@ -113,7 +125,7 @@ var _ = Describe("Reconcile", func() {
 		}
 		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
 		Expect(err).NotTo(HaveOccurred())
-		Expect(res).To(Equal(ctrl.Result{}))
+		Expect(res).To(Equal(completed()))
 	})
 
 	It("continues to validate the ClusterInstance when the ObjectMeta.Generation and ObservedGeneration are different", func() {
@@ -122,6 +134,9 @@ var _ = Describe("Reconcile", func() {
 		clusterInstance.Status = v1alpha1.ClusterInstanceStatus{
 			ObservedGeneration: generation - 1,
 		}
+		Expect(c.Create(ctx, ci.GetMockBmcSecret("bmc", testParams.ClusterNamespace))).To(Succeed())
+		Expect(c.Create(ctx, ci.GetMockClusterTemplate("test-cluster-template", "default"))).To(Succeed())
+		Expect(c.Create(ctx, ci.GetMockNodeTemplate("test-node-template", "default"))).To(Succeed())
 		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
 
 		key := types.NamespacedName{
@@ -136,6 +151,161 @@ var _ = Describe("Reconcile", func() {
 		Expect(res).To(Equal(ctrl.Result{}))
 	})
 
+	It("reports all the missing secret references at once and requeues before creating child resources", func() {
+		clusterInstance.ObjectMeta.Generation = 1
+		clusterInstance.Spec.PullSecretRefs = []corev1.LocalObjectReference{{Name: "mirror-pull-secret"}}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		key := types.NamespacedName{
+			Namespace: testParams.ClusterName,
+			Name:      testParams.ClusterNamespace,
+		}
+		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(Equal(requeueAfter(missingReferencesRequeueInterval)))
+
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		cond := conditions.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.MissingReferences))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(cond.Reason).To(Equal(string(conditions.ReferencesNotFound)))
+		Expect(cond.Message).To(ContainSubstring("Secret mirror-pull-secret (pull secret)"))
+		Expect(cond.Message).To(ContainSubstring("Secret bmc (BMC credentials of node"))
+		// Neither validation nor rendering has started
+		Expect(conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.ClusterInstanceValidated))).To(BeNil())
+
+		// The condition is removed once the secrets exist
+		Expect(c.Create(ctx, ci.GetMockBmcSecret("bmc", testParams.ClusterNamespace))).To(Succeed())
+		Expect(c.Create(ctx, ci.GetMockPullSecret("mirror-pull-secret", testParams.ClusterNamespace))).To(Succeed())
+		_, stop, err := r.handleMissingReferences(ctx, clusterInstance)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stop).To(BeFalse())
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		Expect(conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.MissingReferences))).To(BeNil())
+	})
+
+	It("reports the missing cluster-level and node-level templates and requeues until they exist", func() {
+		clusterInstance.ObjectMeta.Generation = 1
+		clusterInstance.Spec.Nodes[0].HostName = "node1"
+		Expect(c.Create(ctx, ci.GetMockBmcSecret("bmc", testParams.ClusterNamespace))).To(Succeed())
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		key := types.NamespacedName{
+			Namespace: testParams.ClusterName,
+			Name:      testParams.ClusterNamespace,
+		}
+		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(Equal(requeueAfter(missingReferencesRequeueInterval)))
+
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		cond := conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.TemplateRefNotFound))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(cond.Reason).To(Equal(string(conditions.ReferencesNotFound)))
+		Expect(cond.Message).To(ContainSubstring("ConfigMap default/test-cluster-template (cluster-level template)"))
+		Expect(cond.Message).To(ContainSubstring("ConfigMap default/test-node-template (node-level template of node node1)"))
+		// Validation has not started
+		Expect(conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.ClusterInstanceValidated))).To(BeNil())
+
+		// Only the missing node-level template is reported once the cluster-level template exists
+		Expect(c.Create(ctx, ci.GetMockClusterTemplate("test-cluster-template", "default"))).To(Succeed())
+		_, stop, err := r.handleMissingTemplateRefs(ctx, clusterInstance)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stop).To(BeTrue())
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		cond = conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.TemplateRefNotFound))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Message).To(Equal(
+			"Missing referenced templates: ConfigMap default/test-node-template (node-level template of node node1)"))
+
+		// The condition is removed once the templates exist
+		Expect(c.Create(ctx, ci.GetMockNodeTemplate("test-node-template", "default"))).To(Succeed())
+		_, stop, err = r.handleMissingTemplateRefs(ctx, clusterInstance)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stop).To(BeFalse())
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		Expect(conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.TemplateRefNotFound))).To(BeNil())
+	})
+
+	It("reports the templates the controller is not allowed to read and requeues until it is", func() {
+		allowed := false
+		restricted := func(obj client.Object, key client.ObjectKey) bool {
+			_, ok := obj.(*corev1.ConfigMap)
+			return ok && key.Namespace == "restricted" && !allowed
+		}
+		// The cached client cannot read the namespace without surfacing a Forbidden error, only the API server does
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Get: func(ctx context.Context, client client.WithWatch, key client.ObjectKey, obj client.Object,
+					opts ...client.GetOption) error {
+					if restricted(obj, key) {
+						return fmt.Errorf("unable to get: %s because of unknown namespace for the cache", key)
+					}
+					return client.Get(ctx, key, obj, opts...)
+				},
+			}).
+			Build()
+		r.Client = c
+		r.APIReader = interceptor.NewClient(fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).Build(),
+			interceptor.Funcs{
+				Get: func(ctx context.Context, _ client.WithWatch, key client.ObjectKey, obj client.Object,
+					opts ...client.GetOption) error {
+					if restricted(obj, key) {
+						return apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, key.Name,
+							fmt.Errorf("cannot get resource \"configmaps\" in the namespace \"restricted\""))
+					}
+					return c.Get(ctx, key, obj, opts...)
+				},
+			})
+		Expect(c.Create(ctx, testParams.GeneratePullSecret())).To(Succeed())
+		Expect(c.Create(ctx, ci.GetMockBmcSecret("bmc", testParams.ClusterNamespace))).To(Succeed())
+		Expect(c.Create(ctx, ci.GetMockClusterTemplate("test-cluster-template", "restricted"))).To(Succeed())
+		Expect(c.Create(ctx, ci.GetMockNodeTemplate("test-node-template", "default"))).To(Succeed())
+		clusterInstance.ObjectMeta.Generation = 1
+		clusterInstance.Spec.TemplateRefs[0].Namespace = "restricted"
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		key := types.NamespacedName{
+			Namespace: testParams.ClusterName,
+			Name:      testParams.ClusterNamespace,
+		}
+		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(Equal(requeueAfter(missingReferencesRequeueInterval)))
+
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		cond := conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.InsufficientPermissions))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(cond.Reason).To(Equal(string(conditions.ReadForbidden)))
+		Expect(cond.Message).To(Equal("Not allowed to get the configmaps of namespaces restricted: " +
+			"ConfigMap restricted/test-cluster-template (cluster-level template)"))
+		// The forbidden template is not reported as missing and validation has not started
+		Expect(conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.TemplateRefNotFound))).To(BeNil())
+		Expect(conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.ClusterInstanceValidated))).To(BeNil())
+
+		// The condition is removed once the controller is allowed to read the templates
+		allowed = true
+		_, stop, err := r.handleTemplateRefPermissions(ctx, clusterInstance)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stop).To(BeFalse())
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		Expect(conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.InsufficientPermissions))).To(BeNil())
+	})
+
 	It("pre-empts the reconcile-loop when the ObjectMeta.Generation and ObservedGeneration are the same", func() {
 		generation := int64(2)
 		clusterInstance.ObjectMeta.Generation = generation
@@ -155,7 +325,7 @@ var _ = Describe("Reconcile", func() {
 		// reconcile should stop early since we have intentionally set the ObservedGeneration to be the same as
 		// ObjectMeta.Generation
 		Expect(err).NotTo(HaveOccurred())
-		Expect(res).To(Equal(ctrl.Result{}))
+		Expect(res).To(Equal(completed()))
 	})
 })
 
@@ -216,7 +386,7 @@ var _ = Describe("handleFinalizer", func() {
 		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
 
 		res, stop, err := r.handleFinalizer(ctx, clusterInstance)
-		Expect(res).To(Equal(ctrl.Result{}))
+		Expect(res).To(Equal(completed()))
 		Expect(stop).To(BeFalse())
 		Expect(err).ToNot(HaveOccurred())
 	})
@@ -306,7 +476,7 @@ var _ = Describe("handleFinalizer", func() {
 
 		// Expect the manifests previously created to be deleted after the handleFinalizer is called
 		res, stop, err := r.handleFinalizer(ctx, clusterInstance)
-		Expect(res).To(Equal(ctrl.Result{}))
+		Expect(res).To(Equal(completed()))
 		Expect(stop).To(BeTrue())
 		Expect(err).ToNot(HaveOccurred())
 
@@ -395,7 +565,7 @@ var _ = Describe("handleFinalizer", func() {
 
 		// Expect the manifests previously created to be deleted after the handleFinalizer is called
 		res, stop, err := r.handleFinalizer(ctx, clusterInstance)
-		Expect(res).To(Equal(ctrl.Result{}))
+		Expect(res).To(Equal(completed()))
 		Expect(stop).To(BeTrue())
 		Expect(err).ToNot(HaveOccurred())
 
@@ -403,6 +573,187 @@ var _ = Describe("handleFinalizer", func() {
 		Expect(c.Get(ctx, keyMc, mc)).ToNot(Succeed())
 	})
 
+	It("removes the finalizer despite a lingering manifest when the ClusterInstance is force deleted", func() {
+		manifestName := "test"
+		cdApiGroup := "hive.openshift.io/v1"
+
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Delete: func(ctx context.Context, client client.WithWatch, obj client.Object,
+					opts ...client.DeleteOption) error {
+					if obj.GetObjectKind().GroupVersionKind().Kind == "ClusterDeployment" {
+						return fmt.Errorf("ClusterDeployment is locked")
+					}
+					return client.Delete(ctx, obj, opts...)
+				},
+			}).
+			Build()
+		recorder := record.NewFakeRecorder(10)
+		r.Client = c
+		r.Recorder = recorder
+
+		clusterInstance := &v1alpha1.ClusterInstance{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       clusterName,
+				Namespace:  clusterNamespace,
+				Finalizers: []string{clusterInstanceFinalizer},
+			},
+			Status: v1alpha1.ClusterInstanceStatus{
+				ManifestsRendered: []v1alpha1.ManifestReference{{
+					APIGroup:  &cdApiGroup,
+					Kind:      "ClusterDeployment",
+					Name:      manifestName,
+					Namespace: clusterNamespace,
+					SyncWave:  1,
+					Status:    v1alpha1.ManifestRenderedSuccess,
+				}},
+			},
+		}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		cd := &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      manifestName,
+				Namespace: clusterNamespace,
+			},
+		}
+		Expect(c.Create(ctx, cd)).To(Succeed())
+
+		deletionTimeStamp := metav1.Now()
+		clusterInstance.ObjectMeta.DeletionTimestamp = &deletionTimeStamp
+
+		// The finalizer is kept while the ClusterDeployment cannot be deleted
+		_, stop, err := r.handleFinalizer(ctx, clusterInstance)
+		Expect(stop).To(BeTrue())
+		Expect(err).To(HaveOccurred())
+		Expect(clusterInstance.GetFinalizers()).To(ContainElement(clusterInstanceFinalizer))
+
+		// The finalizer is removed once the ClusterInstance is annotated to be force deleted
+		clusterInstance.SetAnnotations(map[string]string{ForceDeleteAnnotation: "true"})
+		res, stop, err := r.handleFinalizer(ctx, clusterInstance)
+		Expect(res).To(Equal(completed()))
+		Expect(stop).To(BeTrue())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(clusterInstance.GetFinalizers()).ToNot(ContainElement(clusterInstanceFinalizer))
+		Expect(recorder.Events).To(Receive(ContainSubstring("ForceDeleted")))
+
+		// The lingering ClusterDeployment is left behind
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(cd), cd)).To(Succeed())
+	})
+
+})
+
+var _ = Describe("handleRegenerateInstallSecrets", func() {
+	var (
+		c                client.Client
+		r                *ClusterInstanceReconciler
+		recorder         *record.FakeRecorder
+		ctx              = context.Background()
+		clusterName      = "test-cluster"
+		clusterNamespace = "test-namespace"
+		secretApiGroup   = "v1"
+		infraEnvApiGroup = "agent-install.openshift.io/v1beta1"
+		clusterInstance  *v1alpha1.ClusterInstance
+	)
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		recorder = record.NewFakeRecorder(10)
+		testLogger := ctrl.Log.WithName("TemplateEngine")
+		r = &ClusterInstanceReconciler{
+			Client:     c,
+			Scheme:     scheme.Scheme,
+			Log:        testLogger,
+			Recorder:   recorder,
+			TmplEngine: ci.NewTemplateEngine(testLogger),
+		}
+
+		clusterInstance = &v1alpha1.ClusterInstance{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       clusterName,
+				Namespace:  clusterNamespace,
+				Finalizers: []string{clusterInstanceFinalizer},
+				Generation: 1,
+			},
+			Spec: v1alpha1.ClusterInstanceSpec{
+				ClusterName: clusterName,
+			},
+			Status: v1alpha1.ClusterInstanceStatus{
+				ObservedGeneration: 1,
+				ManifestsRendered: []v1alpha1.ManifestReference{{
+					APIGroup:  &secretApiGroup,
+					Kind:      "Secret",
+					Name:      "install-secret",
+					Namespace: clusterNamespace,
+					SyncWave:  1,
+					Status:    v1alpha1.ManifestRenderedSuccess,
+				}, {
+					APIGroup:  &infraEnvApiGroup,
+					Kind:      "InfraEnv",
+					Name:      clusterName,
+					Namespace: clusterNamespace,
+					SyncWave:  1,
+					Status:    v1alpha1.ManifestRenderedSuccess,
+				}},
+				RenderedTemplateHashes: []v1alpha1.RenderedTemplateHash{{
+					TemplateRef: v1alpha1.TemplateRef{Name: "test-cluster-template", Namespace: "default"},
+					Hash:        "abcd",
+				}},
+			},
+		}
+		Expect(c.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "install-secret", Namespace: clusterNamespace},
+		})).To(Succeed())
+		Expect(c.Create(ctx, &aiv1beta1.InfraEnv{
+			ObjectMeta: metav1.ObjectMeta{Name: clusterName, Namespace: clusterNamespace},
+		})).To(Succeed())
+	})
+
+	It("does nothing when the regeneration is not requested", func() {
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		regenerating, err := r.handleRegenerateInstallSecrets(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(regenerating).To(BeFalse())
+		Expect(c.Get(ctx, types.NamespacedName{Name: "install-secret", Namespace: clusterNamespace},
+			&corev1.Secret{})).To(Succeed())
+	})
+
+	It("deletes the rendered secrets and InfraEnv and forces the templates to be applied again", func() {
+		clusterInstance.Annotations = map[string]string{RegenerateInstallSecretsAnnotation: "2024-01-01T00:00:00Z"}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		regenerating, err := r.handleRegenerateInstallSecrets(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(regenerating).To(BeTrue())
+
+		err = c.Get(ctx, types.NamespacedName{Name: "install-secret", Namespace: clusterNamespace}, &corev1.Secret{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		// The InfraEnv is re-created with a new discovery image embedding a new ignition token
+		err = c.Get(ctx, types.NamespacedName{Name: clusterName, Namespace: clusterNamespace}, &aiv1beta1.InfraEnv{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		Expect(clusterInstance.GetAnnotations()).ToNot(HaveKey(RegenerateInstallSecretsAnnotation))
+		Expect(clusterInstance.Status.RenderedTemplateHashes).To(BeEmpty())
+		Expect(clusterInstance.Status.LastInstallSecretsRegeneration).ToNot(BeNil())
+		Expect(recorder.Events).To(Receive(ContainSubstring("InstallSecretsRegenerated")))
+	})
+
+	It("does not pre-empt the reconcile-loop while regenerating the install secrets", func() {
+		clusterInstance.Annotations = map[string]string{RegenerateInstallSecretsAnnotation: "2024-01-01T00:00:00Z"}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(clusterInstance)})
+		Expect(err).ToNot(HaveOccurred())
+		// The reconcile proceeds to the secret references precheck rather than being pre-empted
+		Expect(res).To(Equal(requeueAfter(missingReferencesRequeueInterval)))
+	})
 })
 
 var _ = Describe("handleValidate", func() {
@@ -486,6 +837,172 @@ var _ = Describe("handleValidate", func() {
 		Expect(matched).To(BeTrue())
 	})
 
+	It("sets the ClusterNameInvalid reason when the clusterName is not a valid DNS label", func() {
+		clusterInstance.Spec.ClusterName = "Test_Cluster"
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		err := r.handleValidate(ctx, clusterInstance)
+		Expect(err).To(HaveOccurred())
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		cond := meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.ClusterInstanceValidated))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Reason).To(Equal(string(conditions.ClusterNameInvalid)))
+	})
+
+	It("sets the RootDeviceHintsValidated node condition when the rootDeviceHints are contradictory", func() {
+		clusterInstance.Spec.Nodes[0].RootDeviceHints = &bmh_v1alpha1.RootDeviceHints{
+			WWN:              "0x5000c500a0b1c2d3",
+			WWNWithExtension: "0x5000c500ffffffff0x1",
+		}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		err := r.handleValidate(ctx, clusterInstance)
+		Expect(err).To(HaveOccurred())
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		cond := meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.ClusterInstanceValidated))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Reason).To(Equal(string(conditions.RootDeviceHintsInvalid)))
+
+		Expect(clusterInstance.Status.Nodes).To(HaveLen(1))
+		nodeCond := meta.FindStatusCondition(clusterInstance.Status.Nodes[0].Conditions,
+			string(conditions.RootDeviceHintsValidated))
+		Expect(nodeCond).ToNot(BeNil())
+		Expect(nodeCond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(nodeCond.Reason).To(Equal(string(conditions.RootDeviceHintsInvalid)))
+		Expect(nodeCond.Message).To(ContainSubstring("contradicts wwn"))
+
+		// The node condition is updated once the hints are fixed
+		clusterInstance.Spec.Nodes[0].RootDeviceHints.WWNWithExtension = "0x5000c500a0b1c2d30x1"
+		Expect(r.handleValidate(ctx, clusterInstance)).To(Succeed())
+		nodeCond = meta.FindStatusCondition(clusterInstance.Status.Nodes[0].Conditions,
+			string(conditions.RootDeviceHintsValidated))
+		Expect(nodeCond.Status).To(Equal(metav1.ConditionTrue))
+	})
+
+	It("sets the MACAddressesValidated node condition when a MAC address is malformed", func() {
+		clusterInstance.Spec.Nodes[0].BootMACAddress = "00:00:5E:00:53:0g"
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		err := r.handleValidate(ctx, clusterInstance)
+		Expect(err).To(HaveOccurred())
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		cond := meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.ClusterInstanceValidated))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Reason).To(Equal(string(conditions.MACAddressInvalid)))
+
+		Expect(clusterInstance.Status.Nodes).To(HaveLen(1))
+		nodeCond := meta.FindStatusCondition(clusterInstance.Status.Nodes[0].Conditions,
+			string(conditions.MACAddressesValidated))
+		Expect(nodeCond).ToNot(BeNil())
+		Expect(nodeCond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(nodeCond.Reason).To(Equal(string(conditions.MACAddressInvalid)))
+		Expect(nodeCond.Message).To(ContainSubstring("is not a valid MAC address"))
+
+		// The node condition is updated once the MAC address is fixed
+		clusterInstance.Spec.Nodes[0].BootMACAddress = "00:00:5E:00:53:00"
+		Expect(r.handleValidate(ctx, clusterInstance)).To(Succeed())
+		nodeCond = meta.FindStatusCondition(clusterInstance.Status.Nodes[0].Conditions,
+			string(conditions.MACAddressesValidated))
+		Expect(nodeCond.Status).To(Equal(metav1.ConditionTrue))
+	})
+
+	It("sets the BaseDomainInvalid reason when the baseDomain is not a valid DNS domain", func() {
+		clusterInstance.Spec.BaseDomain = "https://example.com"
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		err := r.handleValidate(ctx, clusterInstance)
+		Expect(err).To(HaveOccurred())
+
+		key := types.NamespacedName{
+			Name:      testParams.ClusterName,
+			Namespace: testParams.ClusterNamespace,
+		}
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		cond := meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.ClusterInstanceValidated))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Reason).To(Equal(string(conditions.BaseDomainInvalid)))
+	})
+
+	It("sets the ValidationsSkipped condition when validations are overridden", func() {
+		clusterInstance.Spec.BaseDomain = "https://example.com"
+		clusterInstance.Spec.ValidationOverrides = []string{ci.BaseDomainValidation}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		Expect(r.handleValidate(ctx, clusterInstance)).To(Succeed())
+
+		key := types.NamespacedName{
+			Name:      testParams.ClusterName,
+			Namespace: testParams.ClusterNamespace,
+		}
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		cond := meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.ValidationsSkipped))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(cond.Message).To(Equal("Skipped validations: baseDomain"))
+
+		// The condition is removed once no validations are skipped anymore
+		clusterInstance.Spec.BaseDomain = "example.com"
+		clusterInstance.Spec.ValidationOverrides = nil
+		Expect(r.handleValidate(ctx, clusterInstance)).To(Succeed())
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		Expect(meta.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.ValidationsSkipped))).To(BeNil())
+	})
+
+	It("sets the WebhookValidationReplayed condition when the ClusterInstance bypassed the webhook", func() {
+		// The fake client does not call the webhook, as when it is bypassed
+		clusterInstance.Spec.ClusterType = v1alpha1.ClusterTypeSNO
+		clusterInstance.Spec.ApiVIPs = []string{"192.0.2.10"}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		Expect(r.handleValidate(ctx, clusterInstance)).ToNot(Succeed())
+
+		key := types.NamespacedName{
+			Name:      testParams.ClusterName,
+			Namespace: testParams.ClusterNamespace,
+		}
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		cond := meta.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.WebhookValidationReplayed))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(cond.Reason).To(Equal(string(conditions.WebhookBypassed)))
+		Expect(cond.Message).To(ContainSubstring("spec.apiVIPs"))
+
+		// The condition is removed once the spec passes the webhook validations
+		clusterInstance.Spec.ApiVIPs = nil
+		Expect(r.handleValidate(ctx, clusterInstance)).To(Succeed())
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		Expect(meta.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.WebhookValidationReplayed))).To(BeNil())
+	})
+
+	It("replays the validation of the nodes update against the last applied spec", func() {
+		Expect(ci.SetLastAppliedSpec(clusterInstance, &clusterInstance.Spec)).To(Succeed())
+		clusterInstance.Status.Conditions = []metav1.Condition{{
+			Type: string(conditions.Provisioned), Status: metav1.ConditionFalse,
+			Reason: string(conditions.InProgress), LastTransitionTime: metav1.Now()}}
+		removedNode := clusterInstance.Spec.Nodes[0].HostName
+		clusterInstance.Spec.Nodes[0].HostName = "renamed-node"
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		Expect(r.handleValidate(ctx, clusterInstance)).ToNot(Succeed())
+
+		key := client.ObjectKeyFromObject(clusterInstance)
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		cond := meta.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.WebhookValidationReplayed))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Reason).To(Equal(string(conditions.WebhookBypassed)))
+		Expect(cond.Message).To(ContainSubstring(
+			fmt.Sprintf("node %q cannot be removed or renamed once provisioning has started", removedNode)))
+	})
+
 	It("does not require a reconcile when the ClusterInstanceValidated condition remains unchanged", func() {
 		clusterInstance.Status.Conditions = []metav1.Condition{
 			{
@@ -726,44 +1243,239 @@ spec:
 			Expect(matched).To(Equal(true), "Condition %s was not found", expCond.Type)
 		}
 	})
-})
-
-var _ = Describe("updateSuppressedManifestsStatus", func() {
-	var (
-		c               client.Client
-		r               *ClusterInstanceReconciler
-		ctx             = context.Background()
-		clusterInstance *v1alpha1.ClusterInstance
-		Manifests       []v1alpha1.ManifestReference
-		aciApiGroup     = "extensions.hive.openshift.io/v1beta1"
-		cdApiGroup      = "hive.openshift.io/v1"
-		bmhApilGroup    = "metal3.io/v1alpha1"
-		nmscApiGroup    = "agent-install.openshift.io/v1beta1"
-	)
-
-	BeforeEach(func() {
-
-		var (
-			clusterName      = "test-cluster"
-			clusterNamespace = "test-cluster"
-		)
 
+	It("does not write child resources when the templates and rendering inputs are unchanged", func() {
+		childWrites := 0
+		isChild := func(obj client.Object) bool {
+			_, isClusterInstance := obj.(*v1alpha1.ClusterInstance)
+			return !isClusterInstance
+		}
 		c = fakeclient.NewClientBuilder().
 			WithScheme(scheme.Scheme).
 			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Create: func(ctx context.Context, client client.WithWatch, obj client.Object,
+					opts ...client.CreateOption) error {
+					if isChild(obj) {
+						childWrites++
+					}
+					return client.Create(ctx, obj, opts...)
+				},
+				Update: func(ctx context.Context, client client.WithWatch, obj client.Object,
+					opts ...client.UpdateOption) error {
+					if isChild(obj) {
+						childWrites++
+					}
+					return client.Update(ctx, obj, opts...)
+				},
+				Patch: func(ctx context.Context, client client.WithWatch, obj client.Object, patch client.Patch,
+					opts ...client.PatchOption) error {
+					if isChild(obj) {
+						childWrites++
+					}
+					return client.Patch(ctx, obj, patch, opts...)
+				},
+			}).
 			Build()
-		testLogger := ctrl.Log.WithName("TemplateEngine")
-		tmplEngine := ci.NewTemplateEngine(testLogger)
-		r = &ClusterInstanceReconciler{
-			Client:     c,
-			Scheme:     scheme.Scheme,
-			Log:        testLogger,
-			TmplEngine: tmplEngine,
-		}
+		r.Client = c
+		ci.SetupTestResources(ctx, c, testParams)
 
-		clusterInstance = &v1alpha1.ClusterInstance{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      clusterName,
+		templateRefs := []v1alpha1.TemplateRef{{Name: "test", Namespace: "default"}}
+		clusterInstance.Spec.TemplateRefs = templateRefs
+		clusterInstance.Spec.Nodes[0].TemplateRefs = templateRefs
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Data: map[string]string{"Test": `apiVersion: test.io/v1
+metadata:
+  name: "{{ .Spec.ClusterName }}"
+  namespace: "{{ .Spec.ClusterName }}"
+kind: Test
+spec:
+  name: "{{ .Spec.ClusterName }}"`},
+		}
+		Expect(c.Create(ctx, cm)).To(Succeed())
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		rendered, err := r.handleRenderTemplates(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rendered).To(BeTrue())
+		Expect(childWrites).ToNot(BeZero())
+		Expect(clusterInstance.Status.RenderedTemplateHashes).To(HaveLen(2))
+		Expect(clusterInstance.Status.TemplateStatus).To(HaveLen(2))
+		for _, templateStatus := range clusterInstance.Status.TemplateStatus {
+			Expect(templateStatus.RenderedObjects).To(Equal(1))
+			Expect(templateStatus.Error).To(BeEmpty())
+		}
+
+		// Reconciling again without any change neither renders nor applies the templates
+		childWrites = 0
+		rendered, err = r.handleRenderTemplates(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rendered).To(BeTrue())
+		Expect(childWrites).To(BeZero())
+		// The render status of the unchanged templates is kept
+		Expect(clusterInstance.Status.TemplateStatus).To(HaveLen(2))
+		Expect(clusterInstance.Status.TemplateStatus[0].RenderedObjects).To(Equal(1))
+
+		// Changing the template content re-renders the templates
+		Expect(c.Get(ctx, types.NamespacedName{Name: cm.Name, Namespace: cm.Namespace}, cm)).To(Succeed())
+		cm.Data["Test"] += "\n  extra: value"
+		Expect(c.Update(ctx, cm)).To(Succeed())
+		hashes := clusterInstance.Status.RenderedTemplateHashes
+		childWrites = 0
+		rendered, err = r.handleRenderTemplates(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rendered).To(BeTrue())
+		Expect(childWrites).ToNot(BeZero())
+		Expect(clusterInstance.Status.RenderedTemplateHashes).ToNot(Equal(hashes))
+	})
+
+	It("applies the MachinePools and reconciles their replicas", func() {
+		templateRefs := []v1alpha1.TemplateRef{{Name: "test", Namespace: "default"}}
+		clusterInstance.Spec.TemplateRefs = templateRefs
+		clusterInstance.Spec.Nodes[0].TemplateRefs = templateRefs
+		replicas := int64(2)
+		clusterInstance.Spec.MachinePools = []v1alpha1.MachinePoolSpec{{Name: "worker", Replicas: &replicas}}
+		Expect(c.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Data: map[string]string{"Test": `apiVersion: test.io/v1
+metadata:
+  name: "{{ .Spec.ClusterName }}"
+  namespace: "{{ .Spec.ClusterName }}"
+kind: Test`},
+		})).To(Succeed())
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		rendered, err := r.handleRenderTemplates(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rendered).To(BeTrue())
+
+		machinePool := &hivev1.MachinePool{}
+		key := types.NamespacedName{Name: "test-cluster-worker", Namespace: "test-cluster"}
+		Expect(c.Get(ctx, key, machinePool)).To(Succeed())
+		Expect(machinePool.Spec.ClusterDeploymentRef.Name).To(Equal("test-cluster"))
+		Expect(machinePool.Spec.Name).To(Equal("worker"))
+		Expect(*machinePool.Spec.Replicas).To(Equal(int64(2)))
+		Expect(clusterInstance.Status.ManifestsRendered).To(ContainElement(
+			HaveField("Kind", ci.MachinePoolKind)))
+
+		// Changing the replicas updates the MachinePool
+		replicas = 5
+		clusterInstance.Spec.MachinePools[0].Replicas = &replicas
+		rendered, err = r.handleRenderTemplates(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rendered).To(BeTrue())
+		Expect(c.Get(ctx, key, machinePool)).To(Succeed())
+		Expect(*machinePool.Spec.Replicas).To(Equal(int64(5)))
+	})
+
+	It("writes the rendered manifests to the dump directory when set", func() {
+		r.ManifestsDumpDir = GinkgoT().TempDir()
+		templateRefs := []v1alpha1.TemplateRef{{Name: "test", Namespace: "default"}}
+		clusterInstance.Spec.TemplateRefs = templateRefs
+		clusterInstance.Spec.Nodes[0].TemplateRefs = nil
+		Expect(c.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Data: map[string]string{"Test": `apiVersion: test.io/v1
+metadata:
+  name: "{{ .Spec.ClusterName }}"
+  namespace: "{{ .Spec.ClusterName }}"
+kind: Test`},
+		})).To(Succeed())
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		rendered, err := r.handleRenderTemplates(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rendered).To(BeTrue())
+
+		files, err := filepath.Glob(filepath.Join(ci.ManifestsDumpPath(r.ManifestsDumpDir, clusterInstance),
+			"*_Test_test-cluster.yaml"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+		out, err := os.ReadFile(files[0])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(out)).To(ContainSubstring("kind: Test"))
+	})
+
+	It("reports the templates that render no manifests in the TemplateProducedNoManifests condition", func() {
+		clusterInstance.Spec.TemplateRefs = []v1alpha1.TemplateRef{{Name: "empty", Namespace: "default"}}
+		clusterInstance.Spec.Nodes[0].TemplateRefs = nil
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "empty", Namespace: "default"},
+			Data:       map[string]string{"Test": `{{ if .Spec.Nodes }}{{ end }}`},
+		}
+		Expect(c.Create(ctx, cm)).To(Succeed())
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		rendered, err := r.handleRenderTemplates(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rendered).To(BeTrue())
+		Expect(clusterInstance.Status.TemplateStatus).To(ContainElement(v1alpha1.TemplateRenderStatus{
+			Name: "empty", Namespace: "default", Empty: true}))
+		cond := conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.TemplateProducedNoManifests))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(cond.Reason).To(Equal(string(conditions.EmptyRender)))
+		Expect(cond.Message).To(Equal("Templates rendered no manifests: ConfigMap default/empty"))
+
+		// The condition is kept while the template is unchanged
+		rendered, err = r.handleRenderTemplates(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rendered).To(BeTrue())
+		Expect(conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.TemplateProducedNoManifests))).ToNot(BeNil())
+
+		// The condition is removed once the template allows an empty rendering
+		key := types.NamespacedName{Name: cm.Name, Namespace: cm.Namespace}
+		Expect(c.Get(ctx, key, cm)).To(Succeed())
+		cm.Annotations = map[string]string{ci.AllowEmptyRenderAnnotation: "true"}
+		cm.Data["Test"] += " "
+		Expect(c.Update(ctx, cm)).To(Succeed())
+		rendered, err = r.handleRenderTemplates(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rendered).To(BeTrue())
+		Expect(conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.TemplateProducedNoManifests))).To(BeNil())
+	})
+})
+
+var _ = Describe("updateSuppressedManifestsStatus", func() {
+	var (
+		c               client.Client
+		r               *ClusterInstanceReconciler
+		ctx             = context.Background()
+		clusterInstance *v1alpha1.ClusterInstance
+		Manifests       []v1alpha1.ManifestReference
+		aciApiGroup     = "extensions.hive.openshift.io/v1beta1"
+		cdApiGroup      = "hive.openshift.io/v1"
+		bmhApilGroup    = "metal3.io/v1alpha1"
+		nmscApiGroup    = "agent-install.openshift.io/v1beta1"
+	)
+
+	BeforeEach(func() {
+
+		var (
+			clusterName      = "test-cluster"
+			clusterNamespace = "test-cluster"
+		)
+
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		testLogger := ctrl.Log.WithName("TemplateEngine")
+		tmplEngine := ci.NewTemplateEngine(testLogger)
+		r = &ClusterInstanceReconciler{
+			Client:     c,
+			Scheme:     scheme.Scheme,
+			Log:        testLogger,
+			TmplEngine: tmplEngine,
+		}
+
+		clusterInstance = &v1alpha1.ClusterInstance{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterName,
 				Namespace: clusterNamespace,
 			},
 			Spec: v1alpha1.ClusterInstanceSpec{
@@ -1170,4 +1882,1025 @@ var _ = Describe("executeRenderedManifests", func() {
 		Expect(manifest.Message).To(ContainSubstring(testError))
 	})
 
+	It("propagates the updated annotations of the ClusterDeployment", func() {
+		withSyncWave := func(syncWave string) map[int][]interface{} {
+			return map[int][]interface{}{
+				0: {
+					map[string]interface{}{
+						"apiVersion": *expManifest.APIGroup,
+						"kind":       expManifest.Kind,
+						"metadata": map[string]interface{}{
+							"name":        clusterName,
+							"namespace":   clusterNamespace,
+							"annotations": map[string]interface{}{"argocd.argoproj.io/sync-wave": syncWave},
+						},
+					},
+				},
+			}
+		}
+
+		clusterDeployment := &hivev1.ClusterDeployment{}
+		for _, syncWave := range []string{"1", "2"} {
+			ok, err := r.executeRenderedManifests(ctx, c, clusterInstance, withSyncWave(syncWave),
+				v1alpha1.ManifestRenderedSuccess)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+
+			Expect(c.Get(ctx, key, clusterDeployment)).To(Succeed())
+			Expect(clusterDeployment.Annotations).To(HaveKeyWithValue("argocd.argoproj.io/sync-wave", syncWave))
+		}
+	})
+})
+
+var _ = Describe("handleChangesDuringProvisioning", func() {
+	var (
+		c          client.Client
+		r          *ClusterInstanceReconciler
+		ctx        = context.Background()
+		testParams = &ci.TestParams{
+			BmcCredentialsName:  "bmh-secret",
+			ClusterName:         "test-cluster",
+			ClusterNamespace:    "test-cluster",
+			ClusterImageSetName: "testimage:foobar",
+			ExtraManifestName:   "extra-manifest",
+			ClusterTemplateRef:  "cluster-template-ref",
+			NodeTemplateRef:     "node-template-ref",
+			PullSecret:          "pull-secret",
+		}
+		clusterInstance *v1alpha1.ClusterInstance
+		key             types.NamespacedName
+	)
+
+	const (
+		clusterTemplate = `apiVersion: test.io/v1
+kind: TestCluster
+metadata:
+  name: "{{ .Spec.ClusterName }}"
+  namespace: "{{ .Spec.ClusterName }}"
+{{ if .Spec.ClusterLabels }}
+  labels:
+{{ .Spec.ClusterLabels | toYaml | indent 4 }}
+{{ end }}
+spec:
+  name: "{{ .Spec.ClusterName }}"`
+
+		nodeTemplate = `apiVersion: test.io/v1
+kind: TestNode
+metadata:
+  name: "{{ .Spec.ClusterName }}-node"
+  namespace: "{{ .Spec.ClusterName }}"
+spec:
+  bmcAddress: "{{ .SpecialVars.CurrentNode.BmcAddress }}"`
+	)
+
+	getRenderedObject := func(kind, name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("test.io/v1")
+		obj.SetKind(kind)
+		Expect(c.Get(ctx, types.NamespacedName{Name: name, Namespace: testParams.ClusterNamespace}, obj)).To(Succeed())
+		return obj
+	}
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		testLogger := ctrl.Log.WithName("TemplateEngine")
+		tmplEngine := ci.NewTemplateEngine(testLogger)
+		r = &ClusterInstanceReconciler{
+			Client:     c,
+			Scheme:     scheme.Scheme,
+			Log:        testLogger,
+			TmplEngine: tmplEngine,
+		}
+
+		ci.SetupTestResources(ctx, c, testParams)
+		Expect(c.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-cluster-tmpl", Namespace: "default"},
+			Data:       map[string]string{"TestCluster": clusterTemplate},
+		})).To(Succeed())
+		Expect(c.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-node-tmpl", Namespace: "default"},
+			Data:       map[string]string{"TestNode": nodeTemplate},
+		})).To(Succeed())
+
+		clusterInstance = testParams.GenerateSNOClusterInstance()
+		clusterInstance.Finalizers = []string{clusterInstanceFinalizer}
+		clusterInstance.Spec.TemplateRefs = []v1alpha1.TemplateRef{{Name: "test-cluster-tmpl", Namespace: "default"}}
+		clusterInstance.Spec.Nodes[0].TemplateRefs = []v1alpha1.TemplateRef{
+			{Name: "test-node-tmpl", Namespace: "default"}}
+
+		// Record the current spec as the last applied spec and then edit it mid-provisioning
+		Expect(ci.SetLastAppliedSpec(clusterInstance, &clusterInstance.Spec)).To(Succeed())
+		clusterInstance.Spec.ClusterLabels = map[string]string{"foo": "bar"}
+		clusterInstance.Spec.Nodes[0].BmcAddress = "192.0.2.99"
+
+		clusterInstance.Generation = 2
+		clusterInstance.Status.ObservedGeneration = 1
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+			conditions.Provisioned,
+			conditions.InProgress,
+			metav1.ConditionFalse,
+			"Provisioning cluster")
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		key = types.NamespacedName{Name: testParams.ClusterName, Namespace: testParams.ClusterNamespace}
+	})
+
+	AfterEach(func() {
+		ci.TeardownTestResources(ctx, c, testParams)
+	})
+
+	It("applies safe changes and defers unsafe changes while provisioning is in-progress", func() {
+		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(waitForEvent(deferredChangesRequeueInterval)))
+
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		deferredCond := conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.ChangesDeferredDuringProvisioning))
+		Expect(deferredCond).ToNot(BeNil())
+		Expect(deferredCond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(deferredCond.Message).To(ContainSubstring("nodes[0].bmcAddress"))
+		Expect(deferredCond.Message).ToNot(ContainSubstring("clusterLabels"))
+		Expect(clusterInstance.Status.ObservedGeneration).To(Equal(int64(1)))
+
+		// The safe label change is applied, whereas the BMC address change is not
+		Expect(getRenderedObject("TestCluster", testParams.ClusterName).GetLabels()).To(
+			HaveKeyWithValue("foo", "bar"))
+		bmcAddress, _, _ := unstructured.NestedString(
+			getRenderedObject("TestNode", testParams.ClusterName+"-node").Object, "spec", "bmcAddress")
+		Expect(bmcAddress).To(Equal("192.0.2.1"))
+	})
+
+	It("applies the deferred changes once provisioning has completed", func() {
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+			conditions.Provisioned,
+			conditions.Completed,
+			metav1.ConditionTrue,
+			"Provisioning completed")
+		Expect(c.Status().Update(ctx, clusterInstance)).To(Succeed())
+
+		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(completed()))
+
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		Expect(conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.ChangesDeferredDuringProvisioning))).To(BeNil())
+		Expect(clusterInstance.Status.ObservedGeneration).To(Equal(int64(2)))
+
+		bmcAddress, _, _ := unstructured.NestedString(
+			getRenderedObject("TestNode", testParams.ClusterName+"-node").Object, "spec", "bmcAddress")
+		Expect(bmcAddress).To(Equal("192.0.2.99"))
+
+		appliedSpec, err := ci.GetLastAppliedSpec(clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(appliedSpec.Nodes[0].BmcAddress).To(Equal("192.0.2.99"))
+	})
+	It("moves the last applied spec from the legacy annotation to the status", func() {
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		appliedSpec, err := ci.GetLastAppliedSpec(clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		data, err := json.Marshal(appliedSpec)
+		Expect(err).ToNot(HaveOccurred())
+		clusterInstance.Status.LastAppliedSpec = nil
+		Expect(c.Status().Update(ctx, clusterInstance)).To(Succeed())
+		clusterInstance.SetAnnotations(map[string]string{ci.LastAppliedSpecAnnotation: string(data)})
+		Expect(c.Update(ctx, clusterInstance)).To(Succeed())
+
+		_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		Expect(clusterInstance.GetAnnotations()).ToNot(HaveKey(ci.LastAppliedSpecAnnotation))
+		Expect(clusterInstance.Status.LastAppliedSpec).ToNot(BeNil())
+
+		// The BMC address change recorded against the legacy annotation is still deferred
+		deferredCond := conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.ChangesDeferredDuringProvisioning))
+		Expect(deferredCond).ToNot(BeNil())
+		Expect(deferredCond.Message).To(ContainSubstring("nodes[0].bmcAddress"))
+	})
+})
+
+var _ = Describe("handleTTLAfterFinished", func() {
+	var (
+		c          client.Client
+		r          *ClusterInstanceReconciler
+		ctx        = context.Background()
+		testParams = &ci.TestParams{
+			BmcCredentialsName:  "bmh-secret",
+			ClusterName:         "test-cluster",
+			ClusterNamespace:    "test-cluster",
+			ClusterImageSetName: "testimage:foobar",
+			ExtraManifestName:   "extra-manifest",
+			ClusterTemplateRef:  "cluster-template-ref",
+			NodeTemplateRef:     "node-template-ref",
+			PullSecret:          "pull-secret",
+		}
+		clusterInstance *v1alpha1.ClusterInstance
+		key             types.NamespacedName
+	)
+
+	setFinished := func(reason conditions.ConditionReason, finishedAt time.Time) {
+		clusterInstance.Status.Conditions = []metav1.Condition{{
+			Type:               string(conditions.Provisioned),
+			Reason:             string(reason),
+			Status:             metav1.ConditionFalse,
+			LastTransitionTime: metav1.NewTime(finishedAt),
+		}}
+	}
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		r = &ClusterInstanceReconciler{
+			Client: c,
+			Scheme: scheme.Scheme,
+			Log:    ctrl.Log.WithName("ClusterInstanceReconciler"),
+		}
+
+		clusterInstance = testParams.GenerateSNOClusterInstance()
+		ttl := int32(3600)
+		clusterInstance.Spec.TTLSecondsAfterFinished = &ttl
+		key = types.NamespacedName{Name: testParams.ClusterName, Namespace: testParams.ClusterNamespace}
+	})
+
+	It("deletes a failed ClusterInstance once the TTL has expired", func() {
+		setFinished(conditions.Failed, time.Now().Add(-2*time.Hour))
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		res, deleted, err := r.handleTTLAfterFinished(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(deleted).To(BeTrue())
+		Expect(res).To(Equal(completed()))
+
+		err = c.Get(ctx, key, &v1alpha1.ClusterInstance{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("deletes a deprovisioned ClusterInstance once the TTL has expired", func() {
+		setFinished(conditions.Deprovisioned, time.Now().Add(-2*time.Hour))
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		_, deleted, err := r.handleTTLAfterFinished(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(deleted).To(BeTrue())
+	})
+
+	It("requeues a failed ClusterInstance until the TTL deadline", func() {
+		setFinished(conditions.Failed, time.Now().Add(-30*time.Minute))
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		res, deleted, err := r.handleTTLAfterFinished(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(deleted).To(BeFalse())
+		Expect(res.RequeueAfter).To(BeNumerically("~", 30*time.Minute, time.Minute))
+
+		Expect(c.Get(ctx, key, &v1alpha1.ClusterInstance{})).To(Succeed())
+	})
+
+	It("preserves the TTL deadline across controller restarts", func() {
+		setFinished(conditions.Failed, time.Now().Add(-30*time.Minute))
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		res, _, err := r.handleTTLAfterFinished(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		deadline := time.Now().Add(res.RequeueAfter)
+
+		// Simulate a controller restart: a new reconciler instance working off the persisted ClusterInstance only
+		restarted := &ClusterInstanceReconciler{
+			Client: c,
+			Scheme: scheme.Scheme,
+			Log:    ctrl.Log.WithName("ClusterInstanceReconciler"),
+		}
+		persisted := &v1alpha1.ClusterInstance{}
+		Expect(c.Get(ctx, key, persisted)).To(Succeed())
+
+		res, deleted, err := restarted.handleTTLAfterFinished(ctx, persisted)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(deleted).To(BeFalse())
+		Expect(time.Now().Add(res.RequeueAfter)).To(BeTemporally("~", deadline, time.Second))
+	})
+
+	It("does not delete a ClusterInstance that has not finished", func() {
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+			conditions.Provisioned,
+			conditions.Completed,
+			metav1.ConditionTrue,
+			"Provisioning completed")
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		res, deleted, err := r.handleTTLAfterFinished(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(deleted).To(BeFalse())
+		Expect(res).To(Equal(completed()))
+	})
+
+	It("does not delete a finished ClusterInstance when no TTL is set", func() {
+		clusterInstance.Spec.TTLSecondsAfterFinished = nil
+		setFinished(conditions.Failed, time.Now().Add(-2*time.Hour))
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		res, deleted, err := r.handleTTLAfterFinished(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(deleted).To(BeFalse())
+		Expect(res).To(Equal(completed()))
+
+		Expect(c.Get(ctx, key, &v1alpha1.ClusterInstance{})).To(Succeed())
+	})
+})
+
+var _ = Describe("Reconcile results", func() {
+	It("distinguishes completed, waiting and timed requeue results", func() {
+		Expect(completed()).To(Equal(ctrl.Result{}))
+
+		Expect(waitForEvent()).To(Equal(ctrl.Result{}))
+		Expect(waitForEvent(time.Minute)).To(Equal(ctrl.Result{RequeueAfter: time.Minute}))
+
+		Expect(requeueAfter(time.Hour)).To(Equal(ctrl.Result{RequeueAfter: time.Hour}))
+
+		res, err := requeueWithError(fmt.Errorf("test error"))
+		Expect(err).To(HaveOccurred())
+		Expect(res).To(Equal(ctrl.Result{}))
+	})
+
+	DescribeTable("requeues the transient errors but not the terminal and validation errors",
+		func(err error, requeued bool) {
+			res, resErr := requeueWithError(err)
+			Expect(res).To(Equal(ctrl.Result{}))
+			Expect(resErr).To(MatchError(err))
+			Expect(isNotRequeued(resErr)).To(Equal(!requeued))
+		},
+		Entry("unclassified error", fmt.Errorf("connection refused"), true),
+		Entry("transient error", &TransientError{Err: fmt.Errorf("connection refused")}, true),
+		Entry("validation error",
+			&ci.ValidationError{Reason: conditions.ClusterNameInvalid, Err: fmt.Errorf("invalid clusterName")}, false),
+		Entry("wrapped validation error", fmt.Errorf("validation failed: %w",
+			&ci.ValidationError{Reason: conditions.ClusterNameInvalid, Err: fmt.Errorf("invalid clusterName")}), false),
+		Entry("terminal error", &TerminalError{Err: fmt.Errorf("unsupported schema version")}, false),
+		Entry("transient error wrapping a validation error", &TransientError{
+			Err: &ci.ValidationError{Reason: conditions.Failed, Err: fmt.Errorf("clusterImageSet not found")}}, true),
+		Entry("unrenderable template", renderError(fmt.Errorf("failed to render templates: %w",
+			&ci.RenderError{Err: fmt.Errorf("map has no entry for key \"doesNotExist\"")})), false),
+		Entry("failure to retrieve a template", renderError(fmt.Errorf("connection refused")), true),
+		Entry("API error reported as a validation error", transientAPIError(&ci.ValidationError{
+			Reason: conditions.Failed, Err: apierrors.NewConflict(
+				v1alpha1.GroupVersion.WithResource("clusterinstances").GroupResource(), "test",
+				fmt.Errorf("the object has been modified"))}), true),
+	)
+})
+
+var _ = Describe("handlePullSecrets", func() {
+	var (
+		c          client.Client
+		r          *ClusterInstanceReconciler
+		ctx        = context.Background()
+		testParams = &ci.TestParams{
+			BmcCredentialsName:  "bmh-secret",
+			ClusterName:         "test-cluster",
+			ClusterNamespace:    "test-cluster",
+			ClusterImageSetName: "testimage:foobar",
+			ExtraManifestName:   "extra-manifest",
+			ClusterTemplateRef:  "cluster-template-ref",
+			NodeTemplateRef:     "node-template-ref",
+			PullSecret:          "pull-secret",
+		}
+		clusterInstance *v1alpha1.ClusterInstance
+		key             types.NamespacedName
+	)
+
+	createPullSecret := func(name, dockerConfigJSON string) {
+		Expect(c.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testParams.ClusterNamespace},
+			Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte(dockerConfigJSON)},
+		})).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		r = &ClusterInstanceReconciler{
+			Client: c,
+			Scheme: scheme.Scheme,
+			Log:    ctrl.Log.WithName("ClusterInstanceReconciler"),
+		}
+
+		ci.SetupTestResources(ctx, c, testParams)
+		clusterInstance = testParams.GenerateSNOClusterInstance()
+		key = types.NamespacedName{Name: testParams.ClusterName, Namespace: testParams.ClusterNamespace}
+	})
+
+	AfterEach(func() {
+		ci.TeardownTestResources(ctx, c, testParams)
+	})
+
+	It("does nothing when no additional pull secrets are defined", func() {
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		res, stop, err := r.handlePullSecrets(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stop).To(BeFalse())
+		Expect(res).To(Equal(completed()))
+		Expect(conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.PullSecretsMerged))).To(BeNil())
+	})
+
+	It("merges the pull secrets into a pull secret owned by the ClusterInstance", func() {
+		createPullSecret("mirror-pull-secret", `{"auths":{"mirror.example.com:5000":{"auth":"Yzpk"}}}`)
+		clusterInstance.Spec.PullSecretRefs = []corev1.LocalObjectReference{{Name: "mirror-pull-secret"}}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		_, stop, err := r.handlePullSecrets(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stop).To(BeFalse())
+
+		merged := &corev1.Secret{}
+		Expect(c.Get(ctx, types.NamespacedName{
+			Name:      ci.MergedPullSecretName(clusterInstance),
+			Namespace: testParams.ClusterNamespace}, merged)).To(Succeed())
+		Expect(merged.Type).To(Equal(corev1.SecretTypeDockerConfigJson))
+		Expect(string(merged.Data[corev1.DockerConfigJsonKey])).To(MatchJSON(
+			`{"auths":{"cloud.openshift.com":{"auth":"dXNlcjpwYXNzd29yZAo=","email":"r@r.com"},` +
+				`"mirror.example.com:5000":{"auth":"Yzpk"}}}`))
+		Expect(merged.OwnerReferences).To(HaveLen(1))
+		Expect(merged.OwnerReferences[0].Kind).To(Equal(v1alpha1.ClusterInstanceKind))
+
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		cond := conditions.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.PullSecretsMerged))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+	})
+
+	It("sets the PullSecretsMerged condition to false on conflicting registry auths", func() {
+		createPullSecret("conflicting-pull-secret", `{"auths":{"cloud.openshift.com":{"auth":"Yzpk"}}}`)
+		clusterInstance.Spec.PullSecretRefs = []corev1.LocalObjectReference{{Name: "conflicting-pull-secret"}}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		res, stop, err := r.handlePullSecrets(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stop).To(BeTrue())
+		Expect(res).To(Equal(waitForEvent(pullSecretsRequeueInterval)))
+
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		cond := conditions.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.PullSecretsMerged))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Reason).To(Equal(string(conditions.PullSecretConflict)))
+		Expect(cond.Message).To(ContainSubstring("cloud.openshift.com"))
+
+		err = c.Get(ctx, types.NamespacedName{
+			Name:      ci.MergedPullSecretName(clusterInstance),
+			Namespace: testParams.ClusterNamespace}, &corev1.Secret{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("sets the PullSecretsMerged condition to false when a pull secret is missing", func() {
+		clusterInstance.Spec.PullSecretRefs = []corev1.LocalObjectReference{{Name: "mirror-pull-secret"}}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		res, stop, err := r.handlePullSecrets(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stop).To(BeTrue())
+		Expect(res).To(Equal(waitForEvent(pullSecretsRequeueInterval)))
+
+		cond := conditions.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.PullSecretsMerged))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Reason).To(Equal(string(conditions.ReferencesNotFound)))
+		Expect(cond.Message).To(Equal("Pull secrets not found: mirror-pull-secret"))
+	})
+
+	It("updates the merged pull secret when a pull secret is rotated after rendering", func() {
+		createPullSecret("mirror-pull-secret", `{"auths":{"mirror.example.com:5000":{"auth":"Yzpk"}}}`)
+		clusterInstance.Spec.PullSecretRefs = []corev1.LocalObjectReference{{Name: "mirror-pull-secret"}}
+		clusterInstance.Finalizers = []string{clusterInstanceFinalizer}
+		clusterInstance.Generation = 1
+		clusterInstance.Status.ObservedGeneration = 1
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+		_, _, err := r.handlePullSecrets(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+
+		mirrorPullSecret := &corev1.Secret{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "mirror-pull-secret", Namespace: testParams.ClusterNamespace},
+			mirrorPullSecret)).To(Succeed())
+		mirrorPullSecret.Data[corev1.DockerConfigJsonKey] = []byte(
+			`{"auths":{"mirror.example.com:5000":{"auth":"cm90YXRlZA=="}}}`)
+		Expect(c.Update(ctx, mirrorPullSecret)).To(Succeed())
+
+		// The rotated pull secret enqueues the ClusterInstance, whose spec is unchanged
+		Expect(r.mapPullSecretToClusterInstances(ctx, mirrorPullSecret)).To(Equal([]reconcile.Request{
+			{NamespacedName: key},
+		}))
+		Expect(r.mapPullSecretToClusterInstances(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-secret", Namespace: testParams.ClusterNamespace},
+		})).To(BeEmpty())
+
+		_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ToNot(HaveOccurred())
+
+		merged := &corev1.Secret{}
+		Expect(c.Get(ctx, types.NamespacedName{
+			Name:      ci.MergedPullSecretName(clusterInstance),
+			Namespace: testParams.ClusterNamespace}, merged)).To(Succeed())
+		Expect(string(merged.Data[corev1.DockerConfigJsonKey])).To(MatchJSON(
+			`{"auths":{"cloud.openshift.com":{"auth":"dXNlcjpwYXNzd29yZAo=","email":"r@r.com"},` +
+				`"mirror.example.com:5000":{"auth":"cm90YXRlZA=="}}}`))
+	})
+})
+
+var _ = Describe("handlePullSecretRegistries", func() {
+	var (
+		c               client.Client
+		r               *ClusterInstanceReconciler
+		ctx             = context.Background()
+		clusterInstance *v1alpha1.ClusterInstance
+		pullSecret      *corev1.Secret
+	)
+
+	missingRegistryCondition := func() *metav1.Condition {
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		return meta.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.PullSecretMissingRegistry))
+	}
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		r = &ClusterInstanceReconciler{
+			Client: c,
+			Scheme: scheme.Scheme,
+			Log:    ctrl.Log.WithName("ClusterInstanceReconciler"),
+		}
+
+		Expect(c.Create(ctx, &hivev1.ClusterImageSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "release"},
+			Spec: hivev1.ClusterImageSetSpec{
+				ReleaseImage: "quay.io/openshift-release-dev/ocp-release:4.16.3-x86_64",
+			},
+		})).To(Succeed())
+		pullSecret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "pull-secret", Namespace: "test-cluster"},
+			Data: map[string][]byte{
+				corev1.DockerConfigJsonKey: []byte(`{"auths":{"cloud.openshift.com":{"auth":"YTpi"}}}`),
+			},
+		}
+		Expect(c.Create(ctx, pullSecret)).To(Succeed())
+		clusterInstance = &v1alpha1.ClusterInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-cluster"},
+			Spec: v1alpha1.ClusterInstanceSpec{
+				ClusterImageSetNameRef: "release",
+				PullSecretRef:          corev1.LocalObjectReference{Name: "pull-secret"},
+			},
+		}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+	})
+
+	It("reports and requeues while the pull secret is missing the release registry", func() {
+		res, err := r.handlePullSecretRegistries(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(requeueAfter(pullSecretsRequeueInterval)))
+
+		cond := missingRegistryCondition()
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(cond.Reason).To(Equal(string(conditions.RegistryAuthMissing)))
+		Expect(cond.Message).To(Equal("Pull secret pull-secret has no auth for the registries: quay.io"))
+
+		// The condition is removed once the pull secret is fixed
+		pullSecret.Data[corev1.DockerConfigJsonKey] = []byte(`{"auths":{"quay.io":{"auth":"YTpi"}}}`)
+		Expect(c.Update(ctx, pullSecret)).To(Succeed())
+		res, err = r.handlePullSecretRegistries(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(completed()))
+		Expect(missingRegistryCondition()).To(BeNil())
+	})
+
+	It("requires the registries of the mirrors of the release image instead of its registry", func() {
+		clusterInstance.Spec.ImageDigestSources = []v1alpha1.ImageDigestSource{{
+			Source:  "quay.io/openshift-release-dev/ocp-release",
+			Mirrors: []string{"mirror.example.com:5000/ocp/release"},
+		}}
+
+		_, err := r.handlePullSecretRegistries(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(missingRegistryCondition().Message).To(Equal(
+			"Pull secret pull-secret has no auth for the registries: mirror.example.com:5000"))
+	})
+
+	It("does not require the registries of the mirrors of other repositories", func() {
+		clusterInstance.Spec.ImageDigestSources = []v1alpha1.ImageDigestSource{{
+			Source:  "registry.redhat.io/rhacm2",
+			Mirrors: []string{"mirror.example.com:5000/rhacm2"},
+		}}
+		pullSecret.Data[corev1.DockerConfigJsonKey] = []byte(`{"auths":{"quay.io":{"auth":"YTpi"}}}`)
+		Expect(c.Update(ctx, pullSecret)).To(Succeed())
+
+		res, err := r.handlePullSecretRegistries(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(completed()))
+		Expect(missingRegistryCondition()).To(BeNil())
+	})
+
+	It("does nothing when the ClusterImageSet does not exist", func() {
+		clusterInstance.Spec.ClusterImageSetNameRef = "missing"
+		res, err := r.handlePullSecretRegistries(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(completed()))
+		Expect(missingRegistryCondition()).To(BeNil())
+	})
+})
+
+var _ = Describe("handleDumpRenderingContext", func() {
+	var (
+		c          client.Client
+		r          *ClusterInstanceReconciler
+		ctx        = context.Background()
+		testParams = &ci.TestParams{
+			BmcCredentialsName:  "bmh-secret",
+			ClusterName:         "test-cluster",
+			ClusterNamespace:    "test-cluster",
+			ClusterImageSetName: "testimage:foobar",
+			ExtraManifestName:   "extra-manifest",
+			ClusterTemplateRef:  "cluster-template-ref",
+			NodeTemplateRef:     "node-template-ref",
+			PullSecret:          "pull-secret",
+		}
+		clusterInstance *v1alpha1.ClusterInstance
+		key             types.NamespacedName
+		cmKey           types.NamespacedName
+	)
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		r = &ClusterInstanceReconciler{
+			Client: c,
+			Scheme: scheme.Scheme,
+			Log:    ctrl.Log.WithName("ClusterInstanceReconciler"),
+		}
+
+		clusterInstance = testParams.GenerateSNOClusterInstance()
+		key = types.NamespacedName{Name: testParams.ClusterName, Namespace: testParams.ClusterNamespace}
+		cmKey = types.NamespacedName{
+			Name:      ci.RenderingContextConfigMapName(clusterInstance),
+			Namespace: testParams.ClusterNamespace,
+		}
+	})
+
+	It("does not dump the rendering context when the annotation is not set", func() {
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		Expect(r.handleDumpRenderingContext(ctx, clusterInstance)).To(Succeed())
+		err := c.Get(ctx, cmKey, &corev1.ConfigMap{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("dumps the rendering context to a ConfigMap and removes the annotation", func() {
+		clusterInstance.Annotations = map[string]string{ci.DumpRenderingContextAnnotation: "true"}
+		clusterInstance.Spec.IgnitionConfigOverride = `{"ignition":{"version":"3.1.0"},"passwd":"cluster-secret"}`
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		Expect(r.handleDumpRenderingContext(ctx, clusterInstance)).To(Succeed())
+
+		configMap := &corev1.ConfigMap{}
+		Expect(c.Get(ctx, cmKey, configMap)).To(Succeed())
+		Expect(configMap.Data).To(HaveKey(ci.RenderingContextClusterKey))
+		Expect(configMap.Data).To(HaveKey(ci.RenderingContextNodeKey(0)))
+		Expect(configMap.Data[ci.RenderingContextClusterKey]).To(ContainSubstring("clusterName: test-cluster"))
+		Expect(configMap.Data[ci.RenderingContextClusterKey]).ToNot(ContainSubstring("cluster-secret"))
+		Expect(configMap.OwnerReferences).To(HaveLen(1))
+
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		Expect(clusterInstance.Annotations).ToNot(HaveKey(ci.DumpRenderingContextAnnotation))
+	})
+})
+
+var _ = Describe("handleRequiredMetadata", func() {
+	var (
+		c          client.Client
+		r          *ClusterInstanceReconciler
+		ctx        = context.Background()
+		testParams = &ci.TestParams{
+			BmcCredentialsName:  "bmh-secret",
+			ClusterName:         "test-cluster",
+			ClusterNamespace:    "test-cluster",
+			ClusterImageSetName: "testimage:foobar",
+			ExtraManifestName:   "extra-manifest",
+			ClusterTemplateRef:  "cluster-template-ref",
+			NodeTemplateRef:     "node-template-ref",
+			PullSecret:          "pull-secret",
+		}
+		clusterInstance *v1alpha1.ClusterInstance
+		key             types.NamespacedName
+	)
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		r = &ClusterInstanceReconciler{
+			Client:               c,
+			Scheme:               scheme.Scheme,
+			Log:                  ctrl.Log.WithName("ClusterInstanceReconciler"),
+			RequiredMetadataKeys: []string{"team", "cost-center"},
+		}
+
+		clusterInstance = testParams.GenerateSNOClusterInstance()
+		key = types.NamespacedName{Name: testParams.ClusterName, Namespace: testParams.ClusterNamespace}
+	})
+
+	It("proceeds when all the required metadata keys are present", func() {
+		clusterInstance.Spec.ChargebackMetadata = map[string]string{"team": "ran", "cost-center": "cc-42"}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		res, stop, err := r.handleRequiredMetadata(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stop).To(BeFalse())
+		Expect(res).To(Equal(completed()))
+		Expect(conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.MetadataIncomplete))).To(BeNil())
+	})
+
+	It("gates the rendering and sets MetadataIncomplete when required metadata keys are missing", func() {
+		clusterInstance.Spec.ChargebackMetadata = map[string]string{"team": "ran"}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		res, stop, err := r.handleRequiredMetadata(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stop).To(BeTrue())
+		Expect(res).To(Equal(waitForEvent()))
+
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		cond := conditions.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.MetadataIncomplete))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(cond.Reason).To(Equal(string(conditions.MissingMetadataKeys)))
+		Expect(cond.Message).To(ContainSubstring("cost-center"))
+		Expect(cond.Message).ToNot(ContainSubstring("team"))
+	})
+
+	It("removes the MetadataIncomplete condition once the missing keys are added", func() {
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+		_, stop, err := r.handleRequiredMetadata(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stop).To(BeTrue())
+
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		clusterInstance.Spec.ChargebackMetadata = map[string]string{"team": "ran", "cost-center": "cc-42"}
+		Expect(c.Update(ctx, clusterInstance)).To(Succeed())
+
+		_, stop, err = r.handleRequiredMetadata(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stop).To(BeFalse())
+
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		Expect(conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.MetadataIncomplete))).To(BeNil())
+	})
+})
+
+var _ = Describe("handleInlineBmcCredentials", func() {
+	var (
+		c          client.Client
+		r          *ClusterInstanceReconciler
+		ctx        = context.Background()
+		testParams = &ci.TestParams{
+			BmcCredentialsName:  "bmh-secret",
+			ClusterName:         "test-cluster",
+			ClusterNamespace:    "test-cluster",
+			ClusterImageSetName: "testimage:foobar",
+			PullSecret:          "pull-secret",
+		}
+		clusterInstance *v1alpha1.ClusterInstance
+		node            *v1alpha1.NodeSpec
+	)
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		r = &ClusterInstanceReconciler{
+			Client: c,
+			Scheme: scheme.Scheme,
+			Log:    ctrl.Log.WithName("ClusterInstanceReconciler"),
+		}
+
+		clusterInstance = testParams.GenerateSNOClusterInstance()
+		node = &clusterInstance.Spec.Nodes[0]
+		node.BmcCredentialsName = v1alpha1.BmcCredentialsName{}
+		node.BmcCredentials = &v1alpha1.InlineBmcCredentials{Username: "admin", Password: "password"}
+	})
+
+	It("creates an owned BMC credentials secret from the inline credentials", func() {
+		clusterInstance.Spec.AllowInlineBmcCredentials = true
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		_, stop, err := r.handleInlineBmcCredentials(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stop).To(BeFalse())
+
+		secret := &corev1.Secret{}
+		Expect(c.Get(ctx, types.NamespacedName{
+			Name:      node.InlineBmcCredentialsSecretName(),
+			Namespace: clusterInstance.Namespace,
+		}, secret)).To(Succeed())
+		Expect(secret.Data).To(Equal(map[string][]byte{
+			"username": []byte("admin"),
+			"password": []byte("password"),
+		}))
+		Expect(metav1.IsControlledBy(secret, clusterInstance)).To(BeTrue())
+
+		// The secret is updated when the inline credentials change
+		node.BmcCredentials.Password = "new-password"
+		_, _, err = r.handleInlineBmcCredentials(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(secret), secret)).To(Succeed())
+		Expect(secret.Data["password"]).To(Equal([]byte("new-password")))
+	})
+
+	It("rejects inline credentials that are not allowed without creating the secret", func() {
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		// The reconcile stops until the spec is fixed
+		res, stop, err := r.handleInlineBmcCredentials(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stop).To(BeTrue())
+		Expect(res).To(Equal(ctrl.Result{}))
+
+		err = c.Get(ctx, types.NamespacedName{
+			Name:      node.InlineBmcCredentialsSecretName(),
+			Namespace: clusterInstance.Namespace,
+		}, &corev1.Secret{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		cond := conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.ClusterInstanceValidated))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Message).To(ContainSubstring(
+			"spec.nodes[0].bmcCredentials: Forbidden: must not be set unless spec.allowInlineBmcCredentials is true"))
+	})
+
+	It("rejects inline credentials set together with a BMC credentials secret reference", func() {
+		clusterInstance.Spec.AllowInlineBmcCredentials = true
+		node.BmcCredentialsName = v1alpha1.BmcCredentialsName{Name: "bmh-secret"}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		_, stop, err := r.handleInlineBmcCredentials(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stop).To(BeTrue())
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		cond := conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.ClusterInstanceValidated))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Message).To(ContainSubstring(
+			"spec.nodes[0].bmcCredentials: Forbidden: must not be set together with bmcCredentialsName"))
+	})
+})
+
+var _ = Describe("handleRegistryPreflight", func() {
+	const auth = "dXNlcjpwYXNzd29yZA=="
+
+	var (
+		c               client.Client
+		r               *ClusterInstanceReconciler
+		ctx             = context.Background()
+		registry        *httptest.Server
+		clusterInstance *v1alpha1.ClusterInstance
+		pullSecret      *corev1.Secret
+	)
+
+	BeforeEach(func() {
+		registry = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("Authorization") != "Basic "+auth {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}))
+		DeferCleanup(registry.Close)
+		host := strings.TrimPrefix(registry.URL, "https://")
+
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		r = &ClusterInstanceReconciler{
+			Client:          c,
+			Scheme:          scheme.Scheme,
+			Log:             ctrl.Log.WithName("ClusterInstanceReconciler"),
+			RegistryChecker: &ci.RegistryChecker{Client: registry.Client()},
+		}
+
+		Expect(c.Create(ctx, &hivev1.ClusterImageSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "release"},
+			Spec:       hivev1.ClusterImageSetSpec{ReleaseImage: host + "/ocp/release:4.14.0"},
+		})).To(Succeed())
+		pullSecret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "pull-secret", Namespace: "test-cluster"},
+			Data: map[string][]byte{
+				corev1.DockerConfigJsonKey: []byte(`{"auths":{"` + host + `":{"auth":"` + auth + `"}}}`),
+			},
+		}
+		Expect(c.Create(ctx, pullSecret)).To(Succeed())
+		clusterInstance = &v1alpha1.ClusterInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-cluster"},
+			Spec: v1alpha1.ClusterInstanceSpec{
+				ClusterImageSetNameRef: "release",
+				PullSecretRef:          corev1.LocalObjectReference{Name: "pull-secret"},
+			},
+		}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+	})
+
+	It("does not set the condition when the registry accepts the pull secret", func() {
+		_, err := r.handleRegistryPreflight(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(meta.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.RegistryUnreachable))).To(BeNil())
+	})
+
+	It("reports a registry rejecting the pull secret until it is fixed", func() {
+		pullSecret.Data[corev1.DockerConfigJsonKey] = []byte(`{"auths":{}}`)
+		Expect(c.Update(ctx, pullSecret)).To(Succeed())
+
+		_, err := r.handleRegistryPreflight(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		cond := meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.RegistryUnreachable))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(cond.Message).To(ContainSubstring("rejected the credentials of the pull secret"))
+
+		pullSecret.Data[corev1.DockerConfigJsonKey] = []byte(
+			`{"auths":{"` + strings.TrimPrefix(registry.URL, "https://") + `":{"auth":"` + auth + `"}}}`)
+		Expect(c.Update(ctx, pullSecret)).To(Succeed())
+
+		_, err = r.handleRegistryPreflight(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		Expect(meta.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.RegistryUnreachable))).To(BeNil())
+	})
+
+	It("reports an unreachable registry", func() {
+		registry.Close()
+
+		_, err := r.handleRegistryPreflight(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		cond := meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.RegistryUnreachable))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Message).To(ContainSubstring("failed to reach"))
+	})
+
+	It("is disabled without a RegistryChecker", func() {
+		r.RegistryChecker = nil
+		registry.Close()
+
+		_, err := r.handleRegistryPreflight(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(clusterInstance.Status.Conditions).To(BeEmpty())
+	})
+
+	It("reuses the result of the check of a registry and requeues to check it again", func() {
+		r.RegistryChecker.Interval = time.Minute
+
+		res, err := r.handleRegistryPreflight(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(requeueAfter(time.Minute)))
+
+		// The cached result is reported while the registry is not checked again
+		registry.Close()
+		res, err = r.handleRegistryPreflight(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(requeueAfter(time.Minute)))
+		Expect(meta.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.RegistryUnreachable))).To(BeNil())
+
+		// Other credentials are checked right away
+		pullSecret.Data[corev1.DockerConfigJsonKey] = []byte(`{"auths":{}}`)
+		Expect(c.Update(ctx, pullSecret)).To(Succeed())
+		_, err = r.handleRegistryPreflight(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		cond := meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.RegistryUnreachable))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Message).To(ContainSubstring("failed to reach"))
+	})
 })
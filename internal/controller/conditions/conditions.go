@@ -3,10 +3,15 @@ package conditions
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	hivev1 "github.com/openshift/hive/apis/hive/v1"
 	"github.com/stolostron/siteconfig/api/v1alpha1"
 	"github.com/stolostron/siteconfig/internal/controller/retry"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -21,7 +26,38 @@ const (
 	RenderedTemplates          ConditionType = "RenderedTemplates"
 	RenderedTemplatesValidated ConditionType = "RenderedTemplatesValidated"
 	RenderedTemplatesApplied   ConditionType = "RenderedTemplatesApplied"
-	Provisioned                ConditionType = "Provisioned"
+	Provisioned                ConditionType = v1alpha1.ProvisionedConditionType
+	// ClusterDeprovisioned tracks the teardown of the cluster, its type is "Deprovisioned"
+	ClusterDeprovisioned ConditionType = "Deprovisioned"
+
+	ChangesDeferredDuringProvisioning ConditionType = "ChangesDeferredDuringProvisioning"
+	ValidationsSkipped                ConditionType = "ValidationsSkipped"
+	PullSecretsMerged                 ConditionType = "PullSecretsMerged"
+	MetadataIncomplete                ConditionType = "MetadataIncomplete"
+	MissingReferences                 ConditionType = "MissingReferences"
+	TemplateRefNotFound               ConditionType = "TemplateRefNotFound"
+	InsufficientPermissions           ConditionType = "InsufficientPermissions"
+	DNSRecordsReady                   ConditionType = "DNSRecordsReady"
+	HardwareReady                     ConditionType = "HardwareReady"
+	DefaultTemplateRefsApplied        ConditionType = "DefaultTemplateRefsApplied"
+	TemplateSelectorResolved          ConditionType = "TemplateSelectorResolved"
+	RegistryUnreachable               ConditionType = "RegistryUnreachable"
+	PullSecretMissingRegistry         ConditionType = "PullSecretMissingRegistry"
+	WaitingForDependencies            ConditionType = "WaitingForDependencies"
+	TemplateProducedNoManifests       ConditionType = "TemplateProducedNoManifests"
+	Imported                          ConditionType = "Imported"
+	SpecConverted                     ConditionType = "SpecConverted"
+	WebhookValidationReplayed         ConditionType = "WebhookValidationReplayed"
+	ReconcileBackoff                  ConditionType = "ReconcileBackoff"
+	ClusterDeploymentDrifted          ConditionType = "ClusterDeploymentDrifted"
+	ClusterDeploymentInconsistent     ConditionType = "ClusterDeploymentInconsistent"
+
+	// Node conditions
+	BareMetalHostProvisioned ConditionType = "BareMetalHostProvisioned"
+	RootDeviceHintsValidated ConditionType = "RootDeviceHintsValidated"
+	AgentValidated           ConditionType = "AgentValidated"
+	MACAddressesValidated    ConditionType = "MACAddressesValidated"
+	AddedDay2                ConditionType = "AddedDay2"
 )
 
 // ConditionReason is a string representing the condition's reason
@@ -29,12 +65,64 @@ type ConditionReason string
 
 // The following constants define the different reasons that conditions will be set for
 const (
-	Completed       ConditionReason = "Completed"
+	Completed       ConditionReason = v1alpha1.ProvisioningCompletedReason
 	Failed          ConditionReason = "Failed"
 	TimedOut        ConditionReason = "TimedOut"
-	InProgress      ConditionReason = "InProgress"
+	InProgress      ConditionReason = v1alpha1.ProvisioningInProgressReason
 	Unknown         ConditionReason = "Unknown"
 	StaleConditions ConditionReason = "StaleConditions"
+	Deprovisioned   ConditionReason = "Deprovisioned"
+
+	BaseDomainInvalid         ConditionReason = "BaseDomainInvalid"
+	ClusterNameInvalid        ConditionReason = "ClusterNameInvalid"
+	InfraIDHintInvalid        ConditionReason = "InfraIDHintInvalid"
+	ValidationsOverridden     ConditionReason = "ValidationsOverridden"
+	WebhookBypassed           ConditionReason = "WebhookBypassed"
+	PullSecretConflict        ConditionReason = "PullSecretConflict"
+	RegistryAuthMissing       ConditionReason = "RegistryAuthMissing"
+	MissingMetadataKeys       ConditionReason = "MissingMetadataKeys"
+	NTPSourceInvalid          ConditionReason = "NTPSourceInvalid"
+	PlatformFieldsInvalid     ConditionReason = "PlatformFieldsInvalid"
+	InstallMethodInvalid      ConditionReason = "InstallMethodInvalid"
+	DNSConfigInvalid          ConditionReason = "DNSConfigInvalid"
+	ReferencesNotFound        ConditionReason = "ReferencesNotFound"
+	ReadForbidden             ConditionReason = "ReadForbidden"
+	FIPSIncompatible          ConditionReason = "FIPSIncompatible"
+	RootDeviceHintsInvalid    ConditionReason = "RootDeviceHintsInvalid"
+	NetworkingInvalid         ConditionReason = "NetworkingInvalid"
+	AnnotationsInvalid        ConditionReason = "AnnotationsInvalid"
+	DiskEncryptionInvalid     ConditionReason = "DiskEncryptionInvalid"
+	TemplateValuesInvalid     ConditionReason = "TemplateValuesInvalid"
+	BootArtifactsInvalid      ConditionReason = "BootArtifactsInvalid"
+	EmptyRender               ConditionReason = "EmptyRender"
+	MachinePoolsInvalid       ConditionReason = "MachinePoolsInvalid"
+	ChronyConfigInvalid       ConditionReason = "ChronyConfigInvalid"
+	ImageDigestSourcesInvalid ConditionReason = "ImageDigestSourcesInvalid"
+	InstallOperatorsInvalid   ConditionReason = "InstallOperatorsInvalid"
+	MACAddressInvalid         ConditionReason = "MACAddressInvalid"
+
+	ControlPlaneQuorumInvalid ConditionReason = "ControlPlaneQuorumInvalid"
+	SNOMachinePools           ConditionReason = "SNOMachinePools"
+
+	ReleaseImageUnreachable ConditionReason = "ReleaseImageUnreachable"
+
+	RepeatedFailures ConditionReason = "RepeatedFailures"
+
+	Drifted          ConditionReason = "Drifted"
+	InSync           ConditionReason = "InSync"
+	NotFound         ConditionReason = "NotFound"
+	IdentityMismatch ConditionReason = "IdentityMismatch"
+
+	NoTemplatesSelected ConditionReason = "NoTemplatesSelected"
+	AmbiguousTemplates  ConditionReason = "AmbiguousTemplates"
+	SelectorInvalid     ConditionReason = "SelectorInvalid"
+
+	InstallMethodDefaultsMissing ConditionReason = "InstallMethodDefaultsMissing"
+
+	DNSProviderNotConfigured ConditionReason = "DNSProviderNotConfigured"
+
+	DependenciesNotProvisioned ConditionReason = "DependenciesNotProvisioned"
+	DependencyCycle            ConditionReason = "DependencyCycle"
 )
 
 // SetStatusCondition is a convenience wrapper for meta.SetStatusCondition that takes in the types defined here and
@@ -66,6 +154,7 @@ func SetStatusCondition(
 }
 
 func UpdateCIStatus(ctx context.Context, c client.Client, clusterInstance *v1alpha1.ClusterInstance) error {
+	SetStandardConditions(clusterInstance)
 	if err := retry.RetryOnConflictOrRetriable(retry.RetryBackoff30Seconds, func() error {
 		return c.Status().Update(ctx, clusterInstance) //nolint:wrapcheck
 	}); err != nil {
@@ -81,6 +170,7 @@ func PatchCIStatus(
 	clusterInstance *v1alpha1.ClusterInstance,
 	patch client.Patch,
 ) error {
+	SetStandardConditions(clusterInstance)
 	if err := retry.RetryOnConflictOrRetriable(retry.RetryBackoff30Seconds, func() error {
 		return c.Status().Patch(ctx, clusterInstance, patch) //nolint:wrapcheck
 	}); err != nil {
@@ -103,6 +193,83 @@ func FindCDConditionType(
 	return nil
 }
 
+// The following constants define the maximum length of the mirrored condition fields, matching the limits enforced
+// on metav1.Condition
+const (
+	MaxReasonLength  = 1024
+	MaxMessageLength = 32768
+)
+
+// ellipsis is appended to the condition fields that are truncated
+const ellipsis = "..."
+
+// MirrorCDCondition copies the status, reason and message of the Hive ClusterDeployment condition src into dst, which
+// is of the same type. The last transition time is set to now on a status change, and the last probe time when the
+// condition changed or was last probed at least probeInterval ago, so that the probe time alone does not change on
+// every mirror. An empty status or reason is reported as Unknown, a reason exceeding its maximum length is truncated
+// with an ellipsis and the message is sanitized with SanitizeMessage.
+func MirrorCDCondition(
+	dst *hivev1.ClusterDeploymentCondition,
+	src *hivev1.ClusterDeploymentCondition,
+	now metav1.Time,
+	probeInterval time.Duration,
+) {
+	status := src.Status
+	if status == "" {
+		status = corev1.ConditionUnknown
+	}
+	reason := truncate(src.Reason, MaxReasonLength)
+	if reason == "" {
+		reason = string(Unknown)
+	}
+	message := SanitizeMessage(src.Message, MaxMessageLength)
+
+	if dst.Status != status {
+		dst.LastTransitionTime = now
+	}
+	if dst.Status != status || dst.Reason != reason || dst.Message != message ||
+		now.Sub(dst.LastProbeTime.Time) >= probeInterval {
+		dst.LastProbeTime = now
+	}
+	dst.Type = src.Type
+	dst.Status = status
+	dst.Reason = reason
+	dst.Message = message
+}
+
+// SanitizeMessage prepares a message reported by another component, e.g. Hive, to be copied into a condition: the line
+// breaks and tabs are replaced with spaces, the other control characters are removed and the message is truncated to at
+// most maxLength bytes with an ellipsis
+func SanitizeMessage(message string, maxLength int) string {
+	message = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\r' || r == '\t':
+			return ' '
+		case unicode.IsControl(r):
+			return -1
+		}
+		return r
+	}, message)
+	return truncate(message, maxLength)
+}
+
+// truncate shortens s to at most maxLength bytes, ending it with an ellipsis when it fits, without splitting a
+// multi-byte character
+func truncate(s string, maxLength int) string {
+	if len(s) <= maxLength {
+		return s
+	}
+	suffix := ellipsis
+	if maxLength <= len(ellipsis) {
+		suffix = ""
+	}
+	end := max(maxLength-len(suffix), 0)
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	return s[:end] + suffix
+}
+
 // FindStatusCondition finds the conditionType in status conditions.
 func FindStatusCondition(conditions []metav1.Condition, conditionType string) *metav1.Condition {
 	for i := range conditions {
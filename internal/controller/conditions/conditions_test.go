@@ -2,7 +2,9 @@ package conditions
 
 import (
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	hivev1 "github.com/openshift/hive/apis/hive/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -130,3 +132,324 @@ func TestFindStatusCondition(t *testing.T) {
 		})
 	}
 }
+
+func TestMirrorCDCondition(t *testing.T) {
+	then := metav1.NewTime(time.Now().Add(-time.Hour))
+	now := metav1.NewTime(time.Now())
+	longMessage := strings.Repeat("a", MaxMessageLength+10)
+
+	tests := []struct {
+		name          string
+		dst           hivev1.ClusterDeploymentCondition
+		src           hivev1.ClusterDeploymentCondition
+		probeInterval time.Duration
+		want          hivev1.ClusterDeploymentCondition
+	}{
+		{
+			name: "copies the status, reason and message verbatim",
+			dst: hivev1.ClusterDeploymentCondition{
+				Type:               hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+				Status:             corev1.ConditionFalse,
+				Reason:             "InstallationNotStarted",
+				Message:            "The installation has not started",
+				LastTransitionTime: then,
+				LastProbeTime:      then,
+			},
+			src: hivev1.ClusterDeploymentCondition{
+				Type:    hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+				Status:  corev1.ConditionTrue,
+				Reason:  "InstallationCompleted",
+				Message: "The installation has completed: Cluster is installed",
+			},
+			want: hivev1.ClusterDeploymentCondition{
+				Type:               hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+				Status:             corev1.ConditionTrue,
+				Reason:             "InstallationCompleted",
+				Message:            "The installation has completed: Cluster is installed",
+				LastTransitionTime: now,
+				LastProbeTime:      now,
+			},
+		},
+		{
+			name: "preserves the transition time when the status is unchanged",
+			dst: hivev1.ClusterDeploymentCondition{
+				Type:               hivev1.ClusterInstallFailedClusterDeploymentCondition,
+				Status:             corev1.ConditionFalse,
+				Reason:             "InstallationNotFailed",
+				Message:            "The installation has not failed",
+				LastTransitionTime: then,
+				LastProbeTime:      then,
+			},
+			src: hivev1.ClusterDeploymentCondition{
+				Type:    hivev1.ClusterInstallFailedClusterDeploymentCondition,
+				Status:  corev1.ConditionFalse,
+				Reason:  "InstallationNotFailed",
+				Message: "The installation is in progress",
+			},
+			want: hivev1.ClusterDeploymentCondition{
+				Type:               hivev1.ClusterInstallFailedClusterDeploymentCondition,
+				Status:             corev1.ConditionFalse,
+				Reason:             "InstallationNotFailed",
+				Message:            "The installation is in progress",
+				LastTransitionTime: then,
+				LastProbeTime:      now,
+			},
+		},
+		{
+			name: "reports an empty status and reason as Unknown and keeps an empty message",
+			dst: hivev1.ClusterDeploymentCondition{
+				Type: hivev1.ClusterInstallStoppedClusterDeploymentCondition,
+			},
+			src: hivev1.ClusterDeploymentCondition{
+				Type: hivev1.ClusterInstallStoppedClusterDeploymentCondition,
+			},
+			want: hivev1.ClusterDeploymentCondition{
+				Type:               hivev1.ClusterInstallStoppedClusterDeploymentCondition,
+				Status:             corev1.ConditionUnknown,
+				Reason:             "Unknown",
+				LastTransitionTime: now,
+				LastProbeTime:      now,
+			},
+		},
+		{
+			name: "truncates an oversized message with an ellipsis",
+			dst: hivev1.ClusterDeploymentCondition{
+				Type:               hivev1.ClusterInstallFailedClusterDeploymentCondition,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: then,
+			},
+			src: hivev1.ClusterDeploymentCondition{
+				Type:    hivev1.ClusterInstallFailedClusterDeploymentCondition,
+				Status:  corev1.ConditionTrue,
+				Reason:  "InstallationFailed",
+				Message: longMessage,
+			},
+			want: hivev1.ClusterDeploymentCondition{
+				Type:               hivev1.ClusterInstallFailedClusterDeploymentCondition,
+				Status:             corev1.ConditionTrue,
+				Reason:             "InstallationFailed",
+				Message:            longMessage[:MaxMessageLength-3] + "...",
+				LastTransitionTime: then,
+				LastProbeTime:      now,
+			},
+		},
+		{
+			name: "removes the control characters of the message",
+			dst: hivev1.ClusterDeploymentCondition{
+				Type:               hivev1.ClusterInstallFailedClusterDeploymentCondition,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: then,
+			},
+			src: hivev1.ClusterDeploymentCondition{
+				Type:    hivev1.ClusterInstallFailedClusterDeploymentCondition,
+				Status:  corev1.ConditionTrue,
+				Reason:  "InstallationFailed",
+				Message: "The installation has failed:\n\x1b[1mbootkube\x1b[0m did not complete",
+			},
+			want: hivev1.ClusterDeploymentCondition{
+				Type:               hivev1.ClusterInstallFailedClusterDeploymentCondition,
+				Status:             corev1.ConditionTrue,
+				Reason:             "InstallationFailed",
+				Message:            "The installation has failed: [1mbootkube[0m did not complete",
+				LastTransitionTime: then,
+				LastProbeTime:      now,
+			},
+		},
+		{
+			name: "preserves a recent probe time when the condition is unchanged",
+			dst: hivev1.ClusterDeploymentCondition{
+				Type:               hivev1.ClusterInstallFailedClusterDeploymentCondition,
+				Status:             corev1.ConditionFalse,
+				Reason:             "InstallationNotFailed",
+				Message:            "The installation is in progress",
+				LastTransitionTime: then,
+				LastProbeTime:      then,
+			},
+			src: hivev1.ClusterDeploymentCondition{
+				Type:    hivev1.ClusterInstallFailedClusterDeploymentCondition,
+				Status:  corev1.ConditionFalse,
+				Reason:  "InstallationNotFailed",
+				Message: "The installation is in progress",
+			},
+			probeInterval: 2 * time.Hour,
+			want: hivev1.ClusterDeploymentCondition{
+				Type:               hivev1.ClusterInstallFailedClusterDeploymentCondition,
+				Status:             corev1.ConditionFalse,
+				Reason:             "InstallationNotFailed",
+				Message:            "The installation is in progress",
+				LastTransitionTime: then,
+				LastProbeTime:      then,
+			},
+		},
+		{
+			name: "refreshes a recent probe time when the condition changed",
+			dst: hivev1.ClusterDeploymentCondition{
+				Type:               hivev1.ClusterInstallFailedClusterDeploymentCondition,
+				Status:             corev1.ConditionFalse,
+				Reason:             "InstallationNotFailed",
+				Message:            "The installation has not started",
+				LastTransitionTime: then,
+				LastProbeTime:      then,
+			},
+			src: hivev1.ClusterDeploymentCondition{
+				Type:    hivev1.ClusterInstallFailedClusterDeploymentCondition,
+				Status:  corev1.ConditionFalse,
+				Reason:  "InstallationNotFailed",
+				Message: "The installation is in progress",
+			},
+			probeInterval: 2 * time.Hour,
+			want: hivev1.ClusterDeploymentCondition{
+				Type:               hivev1.ClusterInstallFailedClusterDeploymentCondition,
+				Status:             corev1.ConditionFalse,
+				Reason:             "InstallationNotFailed",
+				Message:            "The installation is in progress",
+				LastTransitionTime: then,
+				LastProbeTime:      now,
+			},
+		},
+		{
+			name: "refreshes the probe time of an unchanged condition once older than the probe interval",
+			dst: hivev1.ClusterDeploymentCondition{
+				Type:               hivev1.ClusterInstallFailedClusterDeploymentCondition,
+				Status:             corev1.ConditionFalse,
+				Reason:             "InstallationNotFailed",
+				Message:            "The installation is in progress",
+				LastTransitionTime: then,
+				LastProbeTime:      then,
+			},
+			src: hivev1.ClusterDeploymentCondition{
+				Type:    hivev1.ClusterInstallFailedClusterDeploymentCondition,
+				Status:  corev1.ConditionFalse,
+				Reason:  "InstallationNotFailed",
+				Message: "The installation is in progress",
+			},
+			probeInterval: 30 * time.Minute,
+			want: hivev1.ClusterDeploymentCondition{
+				Type:               hivev1.ClusterInstallFailedClusterDeploymentCondition,
+				Status:             corev1.ConditionFalse,
+				Reason:             "InstallationNotFailed",
+				Message:            "The installation is in progress",
+				LastTransitionTime: then,
+				LastProbeTime:      now,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			MirrorCDCondition(&tt.dst, &tt.src, now, tt.probeInterval)
+			if !reflect.DeepEqual(tt.dst, tt.want) {
+				t.Errorf("MirrorCDCondition() = %v, want %v", tt.dst, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name      string
+		s         string
+		maxLength int
+		want      string
+	}{
+		{
+			name:      "keeps a string within the maximum length",
+			s:         "InstallationFailed",
+			maxLength: 18,
+			want:      "InstallationFailed",
+		},
+		{
+			name:      "truncates a string exceeding the maximum length",
+			s:         "InstallationFailed",
+			maxLength: 15,
+			want:      "Installation...",
+		},
+		{
+			name:      "does not split a multi-byte character",
+			s:         "abcdé fails",
+			maxLength: 8,
+			want:      "abcd...",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncate(tt.s, tt.maxLength)
+			if got != tt.want {
+				t.Errorf("truncate() = %q, want %q", got, tt.want)
+			}
+			if len(got) > tt.maxLength {
+				t.Errorf("truncate() length = %d, exceeds %d", len(got), tt.maxLength)
+			}
+		})
+	}
+}
+
+func TestSanitizeMessage(t *testing.T) {
+	longMessage := strings.Repeat("Installation failed. ", 2000)
+
+	tests := []struct {
+		name      string
+		message   string
+		maxLength int
+		want      string
+	}{
+		{
+			name:      "keeps a printable message",
+			message:   "The installation has failed: bootkube did not complete",
+			maxLength: MaxMessageLength,
+			want:      "The installation has failed: bootkube did not complete",
+		},
+		{
+			name:      "replaces the line breaks and tabs with spaces",
+			message:   "The installation has failed:\r\n\tbootkube did not complete",
+			maxLength: MaxMessageLength,
+			want:      "The installation has failed:   bootkube did not complete",
+		},
+		{
+			name:      "removes the other control characters",
+			message:   "\x1b[31mInstallation failed\x1b[0m\x00\x7f\u0085",
+			maxLength: MaxMessageLength,
+			want:      "[31mInstallation failed[0m",
+		},
+		{
+			name:      "truncates the sanitized message with an ellipsis",
+			message:   "Installation\x00\x00\x00 failed",
+			maxLength: 15,
+			want:      "Installation...",
+		},
+		{
+			name:      "truncates a long message to the maximum length",
+			message:   longMessage,
+			maxLength: 1024,
+			want:      longMessage[:1021] + "...",
+		},
+		{
+			name:      "truncates without an ellipsis when it does not fit",
+			message:   "Installation failed",
+			maxLength: 3,
+			want:      "Ins",
+		},
+		{
+			name:      "truncates to an empty message",
+			message:   "Installation failed",
+			maxLength: 0,
+			want:      "",
+		},
+		{
+			name:      "does not split a multi-byte character",
+			message:   "ÉÉ failed",
+			maxLength: 6,
+			want:      "É...",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SanitizeMessage(tt.message, tt.maxLength)
+			if got != tt.want {
+				t.Errorf("SanitizeMessage() = %q, want %q", got, tt.want)
+			}
+			if len(got) > tt.maxLength {
+				t.Errorf("SanitizeMessage() length = %d, exceeds %d", len(got), tt.maxLength)
+			}
+		})
+	}
+}
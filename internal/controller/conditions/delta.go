@@ -0,0 +1,71 @@
+package conditions
+
+import (
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MeaningfulDelta returns true if the conditions differ in anything but their timestamps, i.e. a condition was added
+// or removed, or its status, reason, message or observed generation changed. The order of the conditions is ignored.
+func MeaningfulDelta(old, new []metav1.Condition) bool {
+	if len(old) != len(new) {
+		return true
+	}
+	for i := range new {
+		found := FindStatusCondition(old, new[i].Type)
+		if found == nil ||
+			found.Status != new[i].Status ||
+			found.Reason != new[i].Reason ||
+			found.Message != new[i].Message ||
+			found.ObservedGeneration != new[i].ObservedGeneration {
+			return true
+		}
+	}
+	return false
+}
+
+// MeaningfulCDDelta is the MeaningfulDelta of ClusterDeployment conditions, the LastProbeTime updated on every mirror
+// of a condition and the LastTransitionTime are ignored
+func MeaningfulCDDelta(old, new []hivev1.ClusterDeploymentCondition) bool {
+	if len(old) != len(new) {
+		return true
+	}
+	for i := range new {
+		found := FindCDConditionType(old, new[i].Type)
+		if found == nil ||
+			found.Status != new[i].Status ||
+			found.Reason != new[i].Reason ||
+			found.Message != new[i].Message {
+			return true
+		}
+	}
+	return false
+}
+
+// CDProbeTimesChanged returns true if the LastProbeTime of any of the ClusterDeployment conditions changed, conditions
+// that are not found in old being ignored
+func CDProbeTimesChanged(old, new []hivev1.ClusterDeploymentCondition) bool {
+	for i := range new {
+		if found := FindCDConditionType(old, new[i].Type); found != nil &&
+			!found.LastProbeTime.Equal(&new[i].LastProbeTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusChanged returns true if the ClusterInstance status meaningfully changed, i.e. its conditions and deployment
+// conditions have a MeaningfulDelta or any of its other fields changed, so that the status is worth patching
+func StatusChanged(old, new *v1alpha1.ClusterInstanceStatus) bool {
+	if MeaningfulDelta(old.Conditions, new.Conditions) ||
+		MeaningfulCDDelta(old.DeploymentConditions, new.DeploymentConditions) {
+		return true
+	}
+
+	oldRest, newRest := old.DeepCopy(), new.DeepCopy()
+	oldRest.Conditions, newRest.Conditions = nil, nil
+	oldRest.DeploymentConditions, newRest.DeploymentConditions = nil, nil
+	return !equality.Semantic.DeepEqual(oldRest, newRest)
+}
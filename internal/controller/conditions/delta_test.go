@@ -0,0 +1,162 @@
+package conditions
+
+import (
+	"testing"
+	"time"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMeaningfulDelta(t *testing.T) {
+	then := metav1.NewTime(time.Now().Add(-time.Hour))
+	now := metav1.Now()
+	base := []metav1.Condition{
+		{Type: "Provisioned", Status: metav1.ConditionFalse, Reason: "InProgress", Message: "installing",
+			LastTransitionTime: then},
+		{Type: "ClusterInstanceValidated", Status: metav1.ConditionTrue, Reason: "Completed",
+			LastTransitionTime: then},
+	}
+	modified := func(f func(conditions []metav1.Condition) []metav1.Condition) []metav1.Condition {
+		conditions := make([]metav1.Condition, len(base))
+		copy(conditions, base)
+		return f(conditions)
+	}
+
+	tests := []struct {
+		name string
+		new  []metav1.Condition
+		want bool
+	}{
+		{
+			name: "unchanged",
+			new:  modified(func(c []metav1.Condition) []metav1.Condition { return c }),
+		},
+		{
+			name: "transition time only",
+			new: modified(func(c []metav1.Condition) []metav1.Condition {
+				c[0].LastTransitionTime = now
+				return c
+			}),
+		},
+		{
+			name: "reordered",
+			new:  modified(func(c []metav1.Condition) []metav1.Condition { return []metav1.Condition{c[1], c[0]} }),
+		},
+		{
+			name: "status changed",
+			new: modified(func(c []metav1.Condition) []metav1.Condition {
+				c[0].Status = metav1.ConditionTrue
+				return c
+			}),
+			want: true,
+		},
+		{
+			name: "message changed",
+			new: modified(func(c []metav1.Condition) []metav1.Condition {
+				c[0].Message = "installed"
+				return c
+			}),
+			want: true,
+		},
+		{
+			name: "condition added",
+			new: modified(func(c []metav1.Condition) []metav1.Condition {
+				return append(c, metav1.Condition{Type: "HardwareReady", Status: metav1.ConditionTrue})
+			}),
+			want: true,
+		},
+		{
+			name: "condition replaced",
+			new: modified(func(c []metav1.Condition) []metav1.Condition {
+				c[1] = metav1.Condition{Type: "HardwareReady", Status: metav1.ConditionTrue, Reason: "Completed"}
+				return c
+			}),
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MeaningfulDelta(base, tt.new); got != tt.want {
+				t.Errorf("MeaningfulDelta() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMeaningfulCDDelta(t *testing.T) {
+	then := metav1.NewTime(time.Now().Add(-time.Hour))
+	now := metav1.Now()
+	base := []hivev1.ClusterDeploymentCondition{{
+		Type:               hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+		Status:             corev1.ConditionFalse,
+		Reason:             "InstallationInProgress",
+		LastProbeTime:      then,
+		LastTransitionTime: then,
+	}}
+
+	probed := []hivev1.ClusterDeploymentCondition{base[0]}
+	probed[0].LastProbeTime = now
+	if MeaningfulCDDelta(base, probed) {
+		t.Errorf("MeaningfulCDDelta() = true for a probe time only change, want false")
+	}
+
+	completed := []hivev1.ClusterDeploymentCondition{base[0]}
+	completed[0].Status = corev1.ConditionTrue
+	completed[0].LastProbeTime = now
+	if !MeaningfulCDDelta(base, completed) {
+		t.Errorf("MeaningfulCDDelta() = false for a status change, want true")
+	}
+
+	if !MeaningfulCDDelta(base, nil) {
+		t.Errorf("MeaningfulCDDelta() = false for a removed condition, want true")
+	}
+}
+
+func TestCDProbeTimesChanged(t *testing.T) {
+	then := metav1.NewTime(time.Now().Add(-time.Hour))
+	base := []hivev1.ClusterDeploymentCondition{{
+		Type:          hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+		Status:        corev1.ConditionFalse,
+		LastProbeTime: then,
+	}}
+
+	if CDProbeTimesChanged(base, []hivev1.ClusterDeploymentCondition{base[0]}) {
+		t.Errorf("CDProbeTimesChanged() = true for an unchanged probe time, want false")
+	}
+
+	probed := []hivev1.ClusterDeploymentCondition{base[0]}
+	probed[0].LastProbeTime = metav1.Now()
+	if !CDProbeTimesChanged(base, probed) {
+		t.Errorf("CDProbeTimesChanged() = false for a refreshed probe time, want true")
+	}
+
+	if CDProbeTimesChanged(nil, probed) {
+		t.Errorf("CDProbeTimesChanged() = true for an added condition, want false")
+	}
+}
+
+func TestStatusChanged(t *testing.T) {
+	then := metav1.NewTime(time.Now().Add(-time.Hour))
+	old := &v1alpha1.ClusterInstanceStatus{
+		DeploymentConditions: []hivev1.ClusterDeploymentCondition{{
+			Type:          hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+			Status:        corev1.ConditionFalse,
+			LastProbeTime: then,
+		}},
+	}
+
+	probed := old.DeepCopy()
+	probed.DeploymentConditions[0].LastProbeTime = metav1.Now()
+	if StatusChanged(old, probed) {
+		t.Errorf("StatusChanged() = true for a probe time only change, want false")
+	}
+
+	withURL := probed.DeepCopy()
+	withURL.ConsoleURL = "https://console.example.com"
+	if !StatusChanged(old, withURL) {
+		t.Errorf("StatusChanged() = false for a field change, want true")
+	}
+}
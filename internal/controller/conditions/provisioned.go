@@ -0,0 +1,76 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conditions
+
+import (
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// installConditionStatus returns the status of the install condition of the ClusterDeployment, a missing condition
+// is treated as Unknown
+func installConditionStatus(
+	cdConditions []hivev1.ClusterDeploymentCondition,
+	conditionType hivev1.ClusterDeploymentConditionType,
+) corev1.ConditionStatus {
+	if cond := FindCDConditionType(cdConditions, conditionType); cond != nil {
+		return cond.Status
+	}
+	return corev1.ConditionUnknown
+}
+
+// DeriveProvisionedState translates the deletion, Spec.Installed and install conditions (Stopped, Completed and
+// Failed) of the ClusterDeployment into the reason, status and message of the ClusterInstance Provisioned condition.
+// A missing install condition is treated as Unknown, and each verdict requires the conditions it is based on to be
+// explicitly True or False, so that a partial set of conditions still yields the verdicts it supports without false
+// positives. An empty reason is returned when no verdict can be derived, e.g. the install conditions are all missing
+// or the installation stopped without being reported as installed nor failed, in which case the Provisioned condition
+// is left unchanged. The reported failure is returned as is, any grace period is left to the caller.
+func DeriveProvisionedState(cd *hivev1.ClusterDeployment) (ConditionReason, metav1.ConditionStatus, string) {
+	if !cd.DeletionTimestamp.IsZero() {
+		return Deprovisioned, metav1.ConditionFalse, "Cluster deprovisioned"
+	}
+
+	installStopped := installConditionStatus(cd.Status.Conditions,
+		hivev1.ClusterInstallStoppedClusterDeploymentCondition)
+	installCompleted := installConditionStatus(cd.Status.Conditions,
+		hivev1.ClusterInstallCompletedClusterDeploymentCondition)
+	installFailed := installConditionStatus(cd.Status.Conditions,
+		hivev1.ClusterInstallFailedClusterDeploymentCondition)
+
+	if cd.Spec.Installed {
+		if installStopped == corev1.ConditionTrue && installCompleted == corev1.ConditionTrue {
+			return Completed, metav1.ConditionTrue, "Provisioning completed"
+		}
+		// Either the Stopped or the Completed condition has not caught up with Spec.Installed
+		if installStopped == corev1.ConditionFalse || installCompleted == corev1.ConditionFalse {
+			return StaleConditions, metav1.ConditionUnknown,
+				"ClusterDeployment Spec.Installed=true, but Status.Conditions are not updated"
+		}
+	}
+
+	if installStopped == corev1.ConditionTrue && installFailed == corev1.ConditionTrue {
+		return Failed, metav1.ConditionFalse, "Provisioning failed"
+	}
+
+	if installStopped == corev1.ConditionFalse {
+		return InProgress, metav1.ConditionFalse, "Provisioning cluster"
+	}
+
+	return "", "", ""
+}
@@ -0,0 +1,200 @@
+package conditions
+
+import (
+	"testing"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDeriveProvisionedState(t *testing.T) {
+	installConditions := func(stopped, completed, failed corev1.ConditionStatus) []hivev1.ClusterDeploymentCondition {
+		return []hivev1.ClusterDeploymentCondition{
+			{Type: hivev1.ClusterInstallStoppedClusterDeploymentCondition, Status: stopped},
+			{Type: hivev1.ClusterInstallCompletedClusterDeploymentCondition, Status: completed},
+			{Type: hivev1.ClusterInstallFailedClusterDeploymentCondition, Status: failed},
+		}
+	}
+	// without removes the install condition of the given type, as if Hive had not reported it yet
+	without := func(conditionType hivev1.ClusterDeploymentConditionType,
+		conditions []hivev1.ClusterDeploymentCondition) []hivev1.ClusterDeploymentCondition {
+		var kept []hivev1.ClusterDeploymentCondition
+		for _, cond := range conditions {
+			if cond.Type != conditionType {
+				kept = append(kept, cond)
+			}
+		}
+		return kept
+	}
+	deletedAt := metav1.Now()
+
+	tests := []struct {
+		name          string
+		deleted       bool
+		installed     bool
+		conditions    []hivev1.ClusterDeploymentCondition
+		wantReason    ConditionReason
+		wantStatus    metav1.ConditionStatus
+		wantMessage   string
+		wantNoVerdict bool
+	}{
+		{
+			name:        "deprovisioned while being deleted, whatever the install conditions",
+			deleted:     true,
+			installed:   true,
+			conditions:  installConditions(corev1.ConditionTrue, corev1.ConditionTrue, corev1.ConditionFalse),
+			wantReason:  Deprovisioned,
+			wantStatus:  metav1.ConditionFalse,
+			wantMessage: "Cluster deprovisioned",
+		},
+		{
+			name:          "no verdict without install conditions",
+			wantNoVerdict: true,
+		},
+		{
+			name:      "completed when installed, stopped and completed while the failed condition is missing",
+			installed: true,
+			conditions: without(hivev1.ClusterInstallFailedClusterDeploymentCondition,
+				installConditions(corev1.ConditionTrue, corev1.ConditionTrue, corev1.ConditionFalse)),
+			wantReason:  Completed,
+			wantStatus:  metav1.ConditionTrue,
+			wantMessage: "Provisioning completed",
+		},
+		{
+			name:      "no verdict when installed and stopped while the completed condition is missing",
+			installed: true,
+			conditions: without(hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+				installConditions(corev1.ConditionTrue, corev1.ConditionTrue, corev1.ConditionFalse)),
+			wantNoVerdict: true,
+		},
+		{
+			name:      "stale when installed but not stopped while the completed condition is missing",
+			installed: true,
+			conditions: without(hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+				installConditions(corev1.ConditionFalse, corev1.ConditionTrue, corev1.ConditionFalse)),
+			wantReason:  StaleConditions,
+			wantStatus:  metav1.ConditionUnknown,
+			wantMessage: "ClusterDeployment Spec.Installed=true, but Status.Conditions are not updated",
+		},
+		{
+			name: "failed when stopped and failed while the completed condition is missing",
+			conditions: without(hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+				installConditions(corev1.ConditionTrue, corev1.ConditionFalse, corev1.ConditionTrue)),
+			wantReason:  Failed,
+			wantStatus:  metav1.ConditionFalse,
+			wantMessage: "Provisioning failed",
+		},
+		{
+			name: "no verdict when stopped while the failed condition is missing",
+			conditions: without(hivev1.ClusterInstallFailedClusterDeploymentCondition,
+				installConditions(corev1.ConditionTrue, corev1.ConditionFalse, corev1.ConditionTrue)),
+			wantNoVerdict: true,
+		},
+		{
+			name: "in progress when not stopped while the failed condition is missing",
+			conditions: without(hivev1.ClusterInstallFailedClusterDeploymentCondition,
+				installConditions(corev1.ConditionFalse, corev1.ConditionFalse, corev1.ConditionFalse)),
+			wantReason:  InProgress,
+			wantStatus:  metav1.ConditionFalse,
+			wantMessage: "Provisioning cluster",
+		},
+		{
+			name:      "no verdict when the stopped condition is missing, even though installed and completed",
+			installed: true,
+			conditions: without(hivev1.ClusterInstallStoppedClusterDeploymentCondition,
+				installConditions(corev1.ConditionTrue, corev1.ConditionTrue, corev1.ConditionFalse)),
+			wantNoVerdict: true,
+		},
+		{
+			name: "no verdict when the stopped condition is missing, even though a failure is reported",
+			conditions: without(hivev1.ClusterInstallStoppedClusterDeploymentCondition,
+				installConditions(corev1.ConditionTrue, corev1.ConditionFalse, corev1.ConditionTrue)),
+			wantNoVerdict: true,
+		},
+		{
+			name:        "completed once installed, stopped and completed",
+			installed:   true,
+			conditions:  installConditions(corev1.ConditionTrue, corev1.ConditionTrue, corev1.ConditionFalse),
+			wantReason:  Completed,
+			wantStatus:  metav1.ConditionTrue,
+			wantMessage: "Provisioning completed",
+		},
+		{
+			name:        "stale when installed but not stopped",
+			installed:   true,
+			conditions:  installConditions(corev1.ConditionFalse, corev1.ConditionTrue, corev1.ConditionFalse),
+			wantReason:  StaleConditions,
+			wantStatus:  metav1.ConditionUnknown,
+			wantMessage: "ClusterDeployment Spec.Installed=true, but Status.Conditions are not updated",
+		},
+		{
+			name:        "stale when installed but not completed",
+			installed:   true,
+			conditions:  installConditions(corev1.ConditionTrue, corev1.ConditionFalse, corev1.ConditionFalse),
+			wantReason:  StaleConditions,
+			wantStatus:  metav1.ConditionUnknown,
+			wantMessage: "ClusterDeployment Spec.Installed=true, but Status.Conditions are not updated",
+		},
+		{
+			name:          "no verdict when installed with unknown stopped and completed conditions",
+			installed:     true,
+			conditions:    installConditions(corev1.ConditionUnknown, corev1.ConditionUnknown, corev1.ConditionFalse),
+			wantNoVerdict: true,
+		},
+		{
+			name:        "failed once stopped and failed",
+			conditions:  installConditions(corev1.ConditionTrue, corev1.ConditionFalse, corev1.ConditionTrue),
+			wantReason:  Failed,
+			wantStatus:  metav1.ConditionFalse,
+			wantMessage: "Provisioning failed",
+		},
+		{
+			name:        "in progress while not stopped",
+			conditions:  installConditions(corev1.ConditionFalse, corev1.ConditionFalse, corev1.ConditionFalse),
+			wantReason:  InProgress,
+			wantStatus:  metav1.ConditionFalse,
+			wantMessage: "Provisioning cluster",
+		},
+		{
+			name:        "in progress while not stopped, even though a failure is reported",
+			conditions:  installConditions(corev1.ConditionFalse, corev1.ConditionFalse, corev1.ConditionTrue),
+			wantReason:  InProgress,
+			wantStatus:  metav1.ConditionFalse,
+			wantMessage: "Provisioning cluster",
+		},
+		{
+			name:          "no verdict when stopped without being installed nor failed",
+			conditions:    installConditions(corev1.ConditionTrue, corev1.ConditionFalse, corev1.ConditionFalse),
+			wantNoVerdict: true,
+		},
+		{
+			name:          "no verdict while the stopped condition is unknown",
+			conditions:    installConditions(corev1.ConditionUnknown, corev1.ConditionUnknown, corev1.ConditionUnknown),
+			wantNoVerdict: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cd := &hivev1.ClusterDeployment{
+				Spec:   hivev1.ClusterDeploymentSpec{Installed: tt.installed},
+				Status: hivev1.ClusterDeploymentStatus{Conditions: tt.conditions},
+			}
+			if tt.deleted {
+				cd.DeletionTimestamp = &deletedAt
+			}
+
+			reason, status, message := DeriveProvisionedState(cd)
+			if tt.wantNoVerdict {
+				if reason != "" || status != "" || message != "" {
+					t.Errorf("DeriveProvisionedState() = (%q, %q, %q), want no verdict", reason, status, message)
+				}
+				return
+			}
+			if reason != tt.wantReason || status != tt.wantStatus || message != tt.wantMessage {
+				t.Errorf("DeriveProvisionedState() = (%q, %q, %q), want (%q, %q, %q)",
+					reason, status, message, tt.wantReason, tt.wantStatus, tt.wantMessage)
+			}
+		})
+	}
+}
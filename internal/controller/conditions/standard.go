@@ -0,0 +1,82 @@
+package conditions
+
+import (
+	"fmt"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The following constants define the Kubernetes-standard conditions derived from the domain conditions, for the
+// dashboards and aggregators that only understand those
+const (
+	Available   ConditionType = "Available"
+	Progressing ConditionType = "Progressing"
+	Degraded    ConditionType = "Degraded"
+)
+
+// degradedCondition returns the domain condition degrading the ClusterInstance, i.e. a failed or timed out
+// provisioning, the first failing step of the reconcile, or a release image that cannot be reached. It returns nil if
+// the ClusterInstance is not degraded.
+func degradedCondition(clusterInstance *v1alpha1.ClusterInstance) *metav1.Condition {
+	provisioned := FindStatusCondition(clusterInstance.Status.Conditions, string(Provisioned))
+	if provisioned != nil && provisioned.Status == metav1.ConditionFalse &&
+		(provisioned.Reason == string(Failed) || provisioned.Reason == string(TimedOut)) {
+		return provisioned
+	}
+	for _, sc := range summaryConditions {
+		if cond := FindStatusCondition(clusterInstance.Status.Conditions, string(sc.conditionType)); cond != nil &&
+			cond.Status == metav1.ConditionFalse {
+			return cond
+		}
+	}
+	if provisioned != nil && provisioned.Reason == string(ReleaseImageUnreachable) {
+		return provisioned
+	}
+	return nil
+}
+
+// SetStandardConditions sets the Available, Progressing and Degraded conditions of the ClusterInstance from its phase
+// and domain conditions, which are left intact:
+//   - Available is True once the cluster is provisioned, it remains True while a later change of the spec fails.
+//   - Progressing is True while the ClusterInstance is pending, provisioning or being deleted.
+//   - Degraded is True when the provisioning failed or a step of the reconcile fails, the reason and message of the
+//     failing condition are reported.
+//
+// The three conditions are Unknown when the phase cannot be determined, e.g. the deployment conditions are stale.
+func SetStandardConditions(clusterInstance *v1alpha1.ClusterInstance) {
+	phase := Phase(clusterInstance)
+	reason := ConditionReason(phase)
+	if phase == PhaseUnknown {
+		message := "The provisioning state of the cluster is unknown"
+		for _, conditionType := range []ConditionType{Available, Progressing, Degraded} {
+			SetStatusCondition(&clusterInstance.Status.Conditions, conditionType, reason, metav1.ConditionUnknown,
+				message)
+		}
+		return
+	}
+
+	available := metav1.ConditionFalse
+	availableMessage := "The cluster is not provisioned"
+	if provisioned := FindStatusCondition(clusterInstance.Status.Conditions,
+		string(Provisioned)); provisioned != nil && provisioned.Status == metav1.ConditionTrue {
+		available = metav1.ConditionTrue
+		availableMessage = "The cluster is provisioned"
+	}
+	SetStatusCondition(&clusterInstance.Status.Conditions, Available, reason, available, availableMessage)
+
+	progressing := metav1.ConditionFalse
+	if phase == PhasePending || phase == PhaseProvisioning || phase == PhaseDeleting {
+		progressing = metav1.ConditionTrue
+	}
+	SetStatusCondition(&clusterInstance.Status.Conditions, Progressing, reason, progressing,
+		fmt.Sprintf("The ClusterInstance is in the %s phase", phase))
+
+	if cond := degradedCondition(clusterInstance); cond != nil {
+		SetStatusCondition(&clusterInstance.Status.Conditions, Degraded, ConditionReason(cond.Reason),
+			metav1.ConditionTrue, fmt.Sprintf("%s: %s", cond.Type, cond.Message))
+		return
+	}
+	SetStatusCondition(&clusterInstance.Status.Conditions, Degraded, reason, metav1.ConditionFalse,
+		"The ClusterInstance is not degraded")
+}
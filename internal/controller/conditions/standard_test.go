@@ -0,0 +1,160 @@
+package conditions
+
+import (
+	"testing"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetStandardConditions(t *testing.T) {
+	condition := func(conditionType ConditionType, status metav1.ConditionStatus, reason ConditionReason,
+		message string) metav1.Condition {
+		return metav1.Condition{Type: string(conditionType), Status: status, Reason: string(reason), Message: message}
+	}
+	rendered := []metav1.Condition{
+		condition(ClusterInstanceValidated, metav1.ConditionTrue, Completed, ""),
+		condition(RenderedTemplates, metav1.ConditionTrue, Completed, ""),
+		condition(RenderedTemplatesValidated, metav1.ConditionTrue, Completed, ""),
+		condition(RenderedTemplatesApplied, metav1.ConditionTrue, Completed, ""),
+	}
+	withConditions := func(conditions ...metav1.Condition) []metav1.Condition {
+		return append(append([]metav1.Condition{}, rendered...), conditions...)
+	}
+	now := metav1.Now()
+
+	type standard struct {
+		status metav1.ConditionStatus
+		reason string
+	}
+	tests := []struct {
+		name            string
+		conditions      []metav1.Condition
+		deletionPending bool
+		available       standard
+		progressing     standard
+		degraded        standard
+		degradedMessage string
+	}{
+		{
+			name:        "new ClusterInstance",
+			available:   standard{metav1.ConditionFalse, PhasePending},
+			progressing: standard{metav1.ConditionTrue, PhasePending},
+			degraded:    standard{metav1.ConditionFalse, PhasePending},
+		},
+		{
+			name: "validation failed",
+			conditions: []metav1.Condition{
+				condition(ClusterInstanceValidated, metav1.ConditionFalse, Failed, "invalid baseDomain"),
+			},
+			available:       standard{metav1.ConditionFalse, PhaseBlocked},
+			progressing:     standard{metav1.ConditionFalse, PhaseBlocked},
+			degraded:        standard{metav1.ConditionTrue, string(Failed)},
+			degradedMessage: "ClusterInstanceValidated: invalid baseDomain",
+		},
+		{
+			name:        "provisioning",
+			conditions:  withConditions(condition(Provisioned, metav1.ConditionFalse, InProgress, "")),
+			available:   standard{metav1.ConditionFalse, PhaseProvisioning},
+			progressing: standard{metav1.ConditionTrue, PhaseProvisioning},
+			degraded:    standard{metav1.ConditionFalse, PhaseProvisioning},
+		},
+		{
+			name:        "provisioned",
+			conditions:  withConditions(condition(Provisioned, metav1.ConditionTrue, Completed, "")),
+			available:   standard{metav1.ConditionTrue, PhaseProvisioned},
+			progressing: standard{metav1.ConditionFalse, PhaseProvisioned},
+			degraded:    standard{metav1.ConditionFalse, PhaseProvisioned},
+		},
+		{
+			name: "provisioned, a later change fails to apply",
+			conditions: []metav1.Condition{
+				condition(ClusterInstanceValidated, metav1.ConditionTrue, Completed, ""),
+				condition(RenderedTemplates, metav1.ConditionTrue, Completed, ""),
+				condition(RenderedTemplatesValidated, metav1.ConditionTrue, Completed, ""),
+				condition(RenderedTemplatesApplied, metav1.ConditionFalse, Failed, "apply failed"),
+				condition(Provisioned, metav1.ConditionTrue, Completed, ""),
+			},
+			available:       standard{metav1.ConditionTrue, PhaseProvisioned},
+			progressing:     standard{metav1.ConditionFalse, PhaseProvisioned},
+			degraded:        standard{metav1.ConditionTrue, string(Failed)},
+			degradedMessage: "RenderedTemplatesApplied: apply failed",
+		},
+		{
+			name:            "provisioning failed",
+			conditions:      withConditions(condition(Provisioned, metav1.ConditionFalse, Failed, "install failed")),
+			available:       standard{metav1.ConditionFalse, PhaseFailed},
+			progressing:     standard{metav1.ConditionFalse, PhaseFailed},
+			degraded:        standard{metav1.ConditionTrue, string(Failed)},
+			degradedMessage: "Provisioned: install failed",
+		},
+		{
+			name: "release image unreachable",
+			conditions: withConditions(condition(Provisioned, metav1.ConditionFalse, ReleaseImageUnreachable,
+				"image pull failed")),
+			available:       standard{metav1.ConditionFalse, PhaseBlocked},
+			progressing:     standard{metav1.ConditionFalse, PhaseBlocked},
+			degraded:        standard{metav1.ConditionTrue, string(ReleaseImageUnreachable)},
+			degradedMessage: "Provisioned: image pull failed",
+		},
+		{
+			name:        "deprovisioned",
+			conditions:  withConditions(condition(Provisioned, metav1.ConditionFalse, Deprovisioned, "")),
+			available:   standard{metav1.ConditionFalse, PhaseDeprovisioned},
+			progressing: standard{metav1.ConditionFalse, PhaseDeprovisioned},
+			degraded:    standard{metav1.ConditionFalse, PhaseDeprovisioned},
+		},
+		{
+			name:            "deleting",
+			conditions:      withConditions(condition(Provisioned, metav1.ConditionTrue, Completed, "")),
+			deletionPending: true,
+			available:       standard{metav1.ConditionTrue, PhaseDeleting},
+			progressing:     standard{metav1.ConditionTrue, PhaseDeleting},
+			degraded:        standard{metav1.ConditionFalse, PhaseDeleting},
+		},
+		{
+			name:        "stale deployment conditions",
+			conditions:  withConditions(condition(Provisioned, metav1.ConditionUnknown, StaleConditions, "")),
+			available:   standard{metav1.ConditionUnknown, PhaseUnknown},
+			progressing: standard{metav1.ConditionUnknown, PhaseUnknown},
+			degraded:    standard{metav1.ConditionUnknown, PhaseUnknown},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clusterInstance := &v1alpha1.ClusterInstance{
+				Status: v1alpha1.ClusterInstanceStatus{Conditions: tt.conditions},
+			}
+			if tt.deletionPending {
+				clusterInstance.DeletionTimestamp = &now
+			}
+			domainConditions := len(tt.conditions)
+
+			SetStandardConditions(clusterInstance)
+
+			// The domain conditions are kept
+			if got := len(clusterInstance.Status.Conditions); got != domainConditions+3 {
+				t.Errorf("got %d conditions, want %d", got, domainConditions+3)
+			}
+			for conditionType, want := range map[ConditionType]standard{
+				Available:   tt.available,
+				Progressing: tt.progressing,
+				Degraded:    tt.degraded,
+			} {
+				cond := FindStatusCondition(clusterInstance.Status.Conditions, string(conditionType))
+				if cond == nil {
+					t.Fatalf("condition %s not set", conditionType)
+				}
+				if got := (standard{cond.Status, cond.Reason}); got != want {
+					t.Errorf("%s = %v, want %v", conditionType, got, want)
+				}
+			}
+			if tt.degradedMessage != "" {
+				cond := FindStatusCondition(clusterInstance.Status.Conditions, string(Degraded))
+				if cond.Message != tt.degradedMessage {
+					t.Errorf("Degraded message = %q, want %q", cond.Message, tt.degradedMessage)
+				}
+			}
+		})
+	}
+}
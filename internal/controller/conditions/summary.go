@@ -0,0 +1,90 @@
+package conditions
+
+import (
+	"strings"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The following constants define the high-level phases reported by Summarize
+const (
+	PhaseDeleting      = "Deleting"
+	PhasePending       = "Pending"
+	PhaseBlocked       = "Blocked"
+	PhaseProvisioning  = "Provisioning"
+	PhaseProvisioned   = "Provisioned"
+	PhaseFailed        = "Failed"
+	PhaseDeprovisioned = "Deprovisioned"
+	PhaseUnknown       = "Unknown"
+)
+
+// summaryConditions are the conditions reported by Summarize, in reconcile order, along with their short name
+var summaryConditions = []struct {
+	conditionType ConditionType
+	name          string
+}{
+	{ClusterInstanceValidated, "validated"},
+	{RenderedTemplates, "rendered"},
+	{RenderedTemplatesValidated, "dryRun"},
+	{RenderedTemplatesApplied, "applied"},
+}
+
+// Phase derives the high-level phase of the ClusterInstance from its conditions
+func Phase(clusterInstance *v1alpha1.ClusterInstance) string {
+	if !clusterInstance.DeletionTimestamp.IsZero() {
+		return PhaseDeleting
+	}
+
+	if provisioned := FindStatusCondition(clusterInstance.Status.Conditions, string(Provisioned)); provisioned != nil {
+		switch ConditionReason(provisioned.Reason) {
+		case Completed:
+			return PhaseProvisioned
+		case InProgress:
+			return PhaseProvisioning
+		case Failed, TimedOut:
+			return PhaseFailed
+		case Deprovisioned:
+			return PhaseDeprovisioned
+		case StaleConditions:
+			return PhaseUnknown
+		case ReleaseImageUnreachable:
+			return PhaseBlocked
+		}
+	}
+
+	for _, sc := range summaryConditions {
+		if cond := FindStatusCondition(clusterInstance.Status.Conditions, string(sc.conditionType)); cond != nil &&
+			cond.Status == metav1.ConditionFalse {
+			return PhaseBlocked
+		}
+	}
+	return PhasePending
+}
+
+// Summarize returns a compact one-line summary of the ClusterInstance phase and of its most important conditions,
+// intended for logging, e.g. "phase=Provisioning validated=True rendered=True dryRun=True applied=True
+// provisioned=InProgress". Conditions that are not set are omitted.
+func Summarize(clusterInstance *v1alpha1.ClusterInstance) string {
+	var b strings.Builder
+	b.Grow(128)
+
+	b.WriteString("phase=")
+	b.WriteString(Phase(clusterInstance))
+
+	for _, sc := range summaryConditions {
+		if cond := FindStatusCondition(clusterInstance.Status.Conditions, string(sc.conditionType)); cond != nil {
+			b.WriteByte(' ')
+			b.WriteString(sc.name)
+			b.WriteByte('=')
+			b.WriteString(string(cond.Status))
+		}
+	}
+
+	if provisioned := FindStatusCondition(clusterInstance.Status.Conditions, string(Provisioned)); provisioned != nil {
+		b.WriteString(" provisioned=")
+		b.WriteString(provisioned.Reason)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,83 @@
+package conditions
+
+import (
+	"testing"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSummarize(t *testing.T) {
+	condition := func(conditionType ConditionType, status metav1.ConditionStatus, reason ConditionReason) metav1.Condition {
+		return metav1.Condition{Type: string(conditionType), Status: status, Reason: string(reason)}
+	}
+	rendered := []metav1.Condition{
+		condition(ClusterInstanceValidated, metav1.ConditionTrue, Completed),
+		condition(RenderedTemplates, metav1.ConditionTrue, Completed),
+		condition(RenderedTemplatesValidated, metav1.ConditionTrue, Completed),
+		condition(RenderedTemplatesApplied, metav1.ConditionTrue, Completed),
+	}
+	now := metav1.Now()
+
+	tests := []struct {
+		name            string
+		conditions      []metav1.Condition
+		deletionPending bool
+		want            string
+	}{
+		{
+			name: "new ClusterInstance",
+			want: "phase=Pending",
+		},
+		{
+			name:       "validation failed",
+			conditions: []metav1.Condition{condition(ClusterInstanceValidated, metav1.ConditionFalse, Failed)},
+			want:       "phase=Blocked validated=False",
+		},
+		{
+			name:       "rendered, waiting for provisioning to start",
+			conditions: append(rendered, condition(Provisioned, metav1.ConditionUnknown, Unknown)),
+			want:       "phase=Pending validated=True rendered=True dryRun=True applied=True provisioned=Unknown",
+		},
+		{
+			name:       "provisioning",
+			conditions: append(rendered, condition(Provisioned, metav1.ConditionFalse, InProgress)),
+			want:       "phase=Provisioning validated=True rendered=True dryRun=True applied=True provisioned=InProgress",
+		},
+		{
+			name:       "provisioned",
+			conditions: append(rendered, condition(Provisioned, metav1.ConditionTrue, Completed)),
+			want:       "phase=Provisioned validated=True rendered=True dryRun=True applied=True provisioned=Completed",
+		},
+		{
+			name:       "provisioning failed",
+			conditions: append(rendered, condition(Provisioned, metav1.ConditionFalse, Failed)),
+			want:       "phase=Failed validated=True rendered=True dryRun=True applied=True provisioned=Failed",
+		},
+		{
+			name:       "release image unreachable",
+			conditions: append(rendered, condition(Provisioned, metav1.ConditionFalse, ReleaseImageUnreachable)),
+			want: "phase=Blocked validated=True rendered=True dryRun=True applied=True " +
+				"provisioned=ReleaseImageUnreachable",
+		},
+		{
+			name:            "deleting",
+			conditions:      append(rendered, condition(Provisioned, metav1.ConditionTrue, Completed)),
+			deletionPending: true,
+			want:            "phase=Deleting validated=True rendered=True dryRun=True applied=True provisioned=Completed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clusterInstance := &v1alpha1.ClusterInstance{
+				Status: v1alpha1.ClusterInstanceStatus{Conditions: tt.conditions},
+			}
+			if tt.deletionPending {
+				clusterInstance.DeletionTimestamp = &now
+			}
+			if got := Summarize(clusterInstance); got != tt.want {
+				t.Errorf("Summarize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
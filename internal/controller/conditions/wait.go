@@ -0,0 +1,67 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conditions
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// WaitForPollInterval is the interval at which WaitFor polls the ClusterInstance
+var WaitForPollInterval = time.Second
+
+// WaitFor polls the ClusterInstance until its condition of the given type reports the given status, and returns the
+// condition. A ClusterInstance that does not exist yet is waited for. An error is returned when the timeout elapses,
+// the context is cancelled or the ClusterInstance cannot be read.
+func WaitFor(
+	ctx context.Context,
+	c client.Reader,
+	key types.NamespacedName,
+	conditionType ConditionType,
+	status metav1.ConditionStatus,
+	timeout time.Duration,
+) (*metav1.Condition, error) {
+	var found *metav1.Condition
+	err := wait.PollUntilContextTimeout(ctx, WaitForPollInterval, timeout, true, func(ctx context.Context) (bool, error) {
+		clusterInstance := &v1alpha1.ClusterInstance{}
+		if err := c.Get(ctx, key, clusterInstance); err != nil {
+			if errors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, err //nolint:wrapcheck
+		}
+
+		found = FindStatusCondition(clusterInstance.Status.Conditions, string(conditionType))
+		return found != nil && found.Status == status, nil
+	})
+	if err != nil {
+		if wait.Interrupted(err) {
+			return found, fmt.Errorf("timed out waiting for ClusterInstance %s condition %s to be %s: %w",
+				key, conditionType, status, err)
+		}
+		return found, fmt.Errorf("failed to wait for ClusterInstance %s condition %s: %w", key, conditionType, err)
+	}
+	return found, nil
+}
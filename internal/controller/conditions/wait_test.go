@@ -0,0 +1,70 @@
+package conditions
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestWaitFor(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, v1alpha1.AddToScheme(scheme))
+	WaitForPollInterval = 10 * time.Millisecond
+
+	key := types.NamespacedName{Name: "test-cluster", Namespace: "test-cluster"}
+	newClusterInstance := func(status metav1.ConditionStatus) *v1alpha1.ClusterInstance {
+		clusterInstance := &v1alpha1.ClusterInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+		}
+		SetStatusCondition(&clusterInstance.Status.Conditions, Provisioned, InProgress, status, "Provisioning cluster")
+		return clusterInstance
+	}
+
+	t.Run("condition reaches the status", func(t *testing.T) {
+		ctx := context.Background()
+		clusterInstance := newClusterInstance(metav1.ConditionFalse)
+		c := fakeclient.NewClientBuilder().WithScheme(scheme).WithObjects(clusterInstance).
+			WithStatusSubresource(clusterInstance).Build()
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			SetStatusCondition(&clusterInstance.Status.Conditions, Provisioned, Completed, metav1.ConditionTrue,
+				"Provisioning completed")
+			assert.NoError(t, c.Status().Update(ctx, clusterInstance))
+		}()
+
+		cond, err := WaitFor(ctx, c, key, Provisioned, metav1.ConditionTrue, 5*time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, string(Completed), cond.Reason)
+	})
+
+	t.Run("ClusterInstance created while waiting", func(t *testing.T) {
+		ctx := context.Background()
+		c := fakeclient.NewClientBuilder().WithScheme(scheme).Build()
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			assert.NoError(t, c.Create(ctx, newClusterInstance(metav1.ConditionTrue)))
+		}()
+
+		_, err := WaitFor(ctx, c, key, Provisioned, metav1.ConditionTrue, 5*time.Second)
+		assert.NoError(t, err)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		c := fakeclient.NewClientBuilder().WithScheme(scheme).
+			WithObjects(newClusterInstance(metav1.ConditionFalse)).Build()
+
+		cond, err := WaitFor(context.Background(), c, key, Provisioned, metav1.ConditionTrue, 100*time.Millisecond)
+		assert.ErrorContains(t, err, "timed out waiting for ClusterInstance test-cluster/test-cluster condition "+
+			"Provisioned to be True")
+		assert.Equal(t, metav1.ConditionFalse, cond.Status)
+	})
+}
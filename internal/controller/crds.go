@@ -0,0 +1,86 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	aiv1beta1 "github.com/openshift/assisted-service/api/v1beta1"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+// HiveKinds are the Hive kinds the ClusterDeployment reconciler watches, it cannot be started without their CRDs
+var HiveKinds = []schema.GroupVersionKind{
+	hivev1.SchemeGroupVersion.WithKind("ClusterDeployment"),
+}
+
+// ManagedClusterKinds are the kinds the ManagedCluster reconciler watches, it is not started without their CRDs
+var ManagedClusterKinds = []schema.GroupVersionKind{
+	clusterv1.SchemeGroupVersion.WithKind("ManagedCluster"),
+}
+
+// AgentKinds are the kinds the Agent reconciler watches, it is not started without their CRDs
+var AgentKinds = []schema.GroupVersionKind{
+	aiv1beta1.GroupVersion.WithKind("Agent"),
+}
+
+// KindsPollInterval is the interval at which WaitForKinds checks whether the kinds are served
+var KindsPollInterval = 5 * time.Second
+
+// MissingKinds returns the kinds that are not served by the API server, i.e. whose CRD is not installed
+func MissingKinds(mapper meta.RESTMapper, gvks ...schema.GroupVersionKind) ([]schema.GroupVersionKind, error) {
+	var missing []schema.GroupVersionKind
+	for _, gvk := range gvks {
+		if _, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+			if meta.IsNoMatchError(err) {
+				missing = append(missing, gvk)
+				continue
+			}
+			return nil, err
+		}
+	}
+	return missing, nil
+}
+
+// WaitForKinds waits up to the timeout for the kinds to be served by the API server, so that the CRDs installed
+// alongside the controller have time to be established. It returns the kinds that are still missing once the timeout
+// elapses, the kinds are checked once when the timeout is zero.
+func WaitForKinds(
+	ctx context.Context,
+	mapper meta.RESTMapper,
+	timeout time.Duration,
+	gvks ...schema.GroupVersionKind,
+) ([]schema.GroupVersionKind, error) {
+	missing, err := MissingKinds(mapper, gvks...)
+	if err != nil || len(missing) == 0 || timeout <= 0 {
+		return missing, err
+	}
+
+	err = wait.PollUntilContextTimeout(ctx, KindsPollInterval, timeout, false, func(ctx context.Context) (bool, error) {
+		missing, err = MissingKinds(mapper, gvks...)
+		return len(missing) == 0, err
+	})
+	if err != nil && !wait.Interrupted(err) {
+		return nil, err
+	}
+	return missing, nil
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// installingRESTMapper simulates CRDs being installed: the kinds are not served until the given number of lookups
+type installingRESTMapper struct {
+	meta.RESTMapper
+	lookupsBeforeInstall int
+	err                  error
+}
+
+func (m *installingRESTMapper) RESTMapping(gk schema.GroupKind, versions ...string) (*meta.RESTMapping, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.lookupsBeforeInstall > 0 {
+		m.lookupsBeforeInstall--
+		return nil, &meta.NoKindMatchError{GroupKind: gk, SearchedVersions: versions}
+	}
+	return &meta.RESTMapping{GroupVersionKind: gk.WithVersion(versions[0])}, nil
+}
+
+var _ = Describe("Missing CRDs", func() {
+	var (
+		ctx                  = context.Background()
+		originalPollInterval = KindsPollInterval
+	)
+
+	BeforeEach(func() {
+		KindsPollInterval = 10 * time.Millisecond
+	})
+
+	AfterEach(func() {
+		KindsPollInterval = originalPollInterval
+	})
+
+	It("reports the kinds whose CRDs are not installed", func() {
+		mapper := meta.NewDefaultRESTMapper(nil)
+		mapper.Add(hivev1.SchemeGroupVersion.WithKind("ClusterImageSet"), meta.RESTScopeRoot)
+
+		missing, err := MissingKinds(mapper, HiveKinds...)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(missing).To(Equal(HiveKinds))
+
+		mapper.Add(hivev1.SchemeGroupVersion.WithKind("ClusterDeployment"), meta.RESTScopeNamespace)
+		missing, err = MissingKinds(mapper, HiveKinds...)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(missing).To(BeEmpty())
+	})
+
+	It("reports the ManagedCluster CRD when it is not installed", func() {
+		missing, err := MissingKinds(meta.NewDefaultRESTMapper(nil), ManagedClusterKinds...)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(missing).To(Equal(ManagedClusterKinds))
+	})
+
+	It("reports the Agent CRD when it is not installed", func() {
+		missing, err := MissingKinds(meta.NewDefaultRESTMapper(nil), AgentKinds...)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(missing).To(Equal(AgentKinds))
+	})
+
+	It("checks the kinds once without a timeout", func() {
+		mapper := &installingRESTMapper{lookupsBeforeInstall: 1}
+		missing, err := WaitForKinds(ctx, mapper, 0, HiveKinds...)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(missing).To(Equal(HiveKinds))
+	})
+
+	It("waits for the CRDs to be installed", func() {
+		mapper := &installingRESTMapper{lookupsBeforeInstall: 3}
+		missing, err := WaitForKinds(ctx, mapper, time.Second, HiveKinds...)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(missing).To(BeEmpty())
+	})
+
+	It("returns the kinds still missing once the timeout elapses", func() {
+		mapper := &installingRESTMapper{lookupsBeforeInstall: 1000}
+		missing, err := WaitForKinds(ctx, mapper, 50*time.Millisecond, HiveKinds...)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(missing).To(Equal(HiveKinds))
+	})
+
+	It("returns the errors other than a missing kind", func() {
+		mapper := &installingRESTMapper{err: fmt.Errorf("discovery failed")}
+		_, err := WaitForKinds(ctx, mapper, time.Second, HiveKinds...)
+		Expect(err).To(MatchError("discovery failed"))
+	})
+})
@@ -0,0 +1,128 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	ci "github.com/stolostron/siteconfig/internal/controller/clusterinstance"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// isProvisioningCompleted returns true if the ClusterInstance Provisioned condition reports a completed installation
+func isProvisioningCompleted(clusterInstance *v1alpha1.ClusterInstance) bool {
+	provisioned := meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.Provisioned))
+	return provisioned != nil && provisioned.Reason == string(conditions.Completed)
+}
+
+// handleAddedNodes provisions the nodes appended to the spec of an installed cluster (day-2). Only the node-level
+// templates of the added nodes are rendered and applied, the cluster-level manifests and those of the existing nodes
+// are left untouched. The added nodes are tracked by their AddedDay2 node condition.
+// It returns true when the added nodes have been handled and the reconcile should stop. Nodes added along with other
+// spec changes are rendered with the rest of the templates.
+func (r *ClusterInstanceReconciler) handleAddedNodes(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+) (ctrl.Result, bool, error) {
+	if !isProvisioningCompleted(clusterInstance) {
+		return completed(), false, nil
+	}
+
+	appliedSpec, err := ci.GetLastAppliedSpec(clusterInstance)
+	if err != nil || appliedSpec == nil {
+		return ctrl.Result{}, false, err
+	}
+	added := ci.AddedNodes(appliedSpec, &clusterInstance.Spec)
+	if len(added) == 0 {
+		return completed(), false, nil
+	}
+
+	r.Log.Info("Rendering the nodes added after provisioning", "ClusterInstance", clusterInstance.Name,
+		"nodes", added)
+	rendered, result, err := r.applyAddedNodes(ctx, clusterInstance, added)
+	if err != nil || !rendered {
+		message := "Failed to apply the manifests of the node added after provisioning"
+		if err != nil {
+			message = fmt.Sprintf("%s: %s", message, err.Error())
+		}
+		patch := client.MergeFrom(clusterInstance.DeepCopy())
+		setAddedNodesCondition(clusterInstance, added, conditions.Failed, metav1.ConditionFalse, message)
+		if updateErr := conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch); updateErr != nil && err == nil {
+			err = updateErr
+		}
+		return completed(), true, err
+	}
+
+	// Record the hashes and render status of the added nodes' templates along with those of the existing ones
+	patch := client.MergeFrom(clusterInstance.DeepCopy())
+	clusterInstance.Status.RenderedTemplateHashes = append(clusterInstance.Status.RenderedTemplateHashes,
+		result.TemplateHashes...)
+	clusterInstance.Status.TemplateStatus = append(clusterInstance.Status.TemplateStatus, result.TemplateStatus...)
+	setAddedNodesCondition(clusterInstance, added, conditions.Completed, metav1.ConditionTrue,
+		"The node was added after provisioning, its manifests are applied")
+	clusterInstance.Status.ObservedGeneration = clusterInstance.ObjectMeta.Generation
+	if err := conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch); err != nil {
+		return ctrl.Result{}, true, err
+	}
+
+	return completed(), true, r.updateLastAppliedSpec(ctx, clusterInstance, &clusterInstance.Spec)
+}
+
+// applyAddedNodes renders, validates and applies the node-level templates of the added nodes. It returns false when
+// the rendered manifests could not all be validated or applied.
+func (r *ClusterInstanceReconciler) applyAddedNodes(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+	added []string,
+) (bool, *ci.RenderResult, error) {
+	result, err := r.TmplEngine.ProcessNodesTemplates(ctx, r.Client, *clusterInstance, added)
+	if err != nil {
+		return false, nil, err
+	}
+
+	manifestGroups, err := groupAndSortManifests(result.Manifests)
+	if err != nil {
+		return false, nil, err
+	}
+	if rendered, err := r.validateRenderedManifests(ctx, clusterInstance, manifestGroups); !rendered || err != nil {
+		return false, nil, err
+	}
+	if rendered, err := r.applyRenderedManifests(ctx, clusterInstance, manifestGroups); !rendered || err != nil {
+		return false, nil, err
+	}
+	return true, result, nil
+}
+
+// setAddedNodesCondition sets the AddedDay2 condition of the added nodes
+func setAddedNodesCondition(
+	clusterInstance *v1alpha1.ClusterInstance,
+	added []string,
+	reason conditions.ConditionReason,
+	status metav1.ConditionStatus,
+	message string,
+) {
+	for _, hostName := range added {
+		nodeStatus := getOrCreateNodeStatus(clusterInstance, hostName)
+		conditions.SetStatusCondition(&nodeStatus.Conditions, conditions.AddedDay2, reason, status, message)
+	}
+}
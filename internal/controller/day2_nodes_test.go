@@ -0,0 +1,219 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	ci "github.com/stolostron/siteconfig/internal/controller/clusterinstance"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("handleAddedNodes", func() {
+	var (
+		c          client.Client
+		r          *ClusterInstanceReconciler
+		ctx        = context.Background()
+		testParams = &ci.TestParams{
+			BmcCredentialsName:  "bmh-secret",
+			ClusterName:         "test-cluster",
+			ClusterNamespace:    "test-cluster",
+			ClusterImageSetName: "testimage:foobar",
+			ExtraManifestName:   "extra-manifest",
+			ClusterTemplateRef:  "cluster-template-ref",
+			NodeTemplateRef:     "node-template-ref",
+			PullSecret:          "pull-secret",
+		}
+		clusterInstance *v1alpha1.ClusterInstance
+		key             types.NamespacedName
+	)
+
+	const (
+		clusterTemplate = `apiVersion: test.io/v1
+kind: TestCluster
+metadata:
+  name: "{{ .Spec.ClusterName }}"
+  namespace: "{{ .Spec.ClusterName }}"
+spec:
+  nodes: "{{ len .Spec.Nodes }}"`
+
+		nodeTemplate = `apiVersion: test.io/v1
+kind: TestNode
+metadata:
+  name: "{{ .SpecialVars.CurrentNode.HostName }}"
+  namespace: "{{ .Spec.ClusterName }}"
+spec:
+  bmcAddress: "{{ .SpecialVars.CurrentNode.BmcAddress }}"`
+	)
+
+	renderedObject := func(kind, name string) (*unstructured.Unstructured, error) {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("test.io/v1")
+		obj.SetKind(kind)
+		return obj, c.Get(ctx, types.NamespacedName{Name: name, Namespace: testParams.ClusterNamespace}, obj)
+	}
+
+	// addWorker appends a worker node to the spec of the provisioned cluster
+	addWorker := func() {
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		worker := *clusterInstance.Spec.Nodes[0].DeepCopy()
+		worker.HostName = "worker-1"
+		worker.Role = "worker"
+		worker.BmcAddress = "192.0.2.10"
+		worker.BootMACAddress = "00:00:5E:00:53:10"
+		clusterInstance.Spec.Nodes = append(clusterInstance.Spec.Nodes, worker)
+		clusterInstance.Generation++
+		Expect(c.Update(ctx, clusterInstance)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		testLogger := ctrl.Log.WithName("TemplateEngine")
+		r = &ClusterInstanceReconciler{
+			Client:     c,
+			Scheme:     scheme.Scheme,
+			Log:        testLogger,
+			TmplEngine: ci.NewTemplateEngine(testLogger),
+		}
+
+		ci.SetupTestResources(ctx, c, testParams)
+		Expect(c.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-cluster-tmpl", Namespace: "default"},
+			Data:       map[string]string{"TestCluster": clusterTemplate},
+		})).To(Succeed())
+		Expect(c.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-node-tmpl", Namespace: "default"},
+			Data:       map[string]string{"TestNode": nodeTemplate},
+		})).To(Succeed())
+
+		clusterInstance = testParams.GenerateSNOClusterInstance()
+		clusterInstance.Finalizers = []string{clusterInstanceFinalizer}
+		clusterInstance.Spec.TemplateRefs = []v1alpha1.TemplateRef{{Name: "test-cluster-tmpl", Namespace: "default"}}
+		clusterInstance.Spec.Nodes[0].HostName = "node1"
+		clusterInstance.Spec.Nodes[0].TemplateRefs = []v1alpha1.TemplateRef{
+			{Name: "test-node-tmpl", Namespace: "default"}}
+		clusterInstance.Generation = 1
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+		key = client.ObjectKeyFromObject(clusterInstance)
+
+		// Install the cluster
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+			conditions.Provisioned,
+			conditions.Completed,
+			metav1.ConditionTrue,
+			"Provisioning completed")
+		Expect(c.Status().Update(ctx, clusterInstance)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		ci.TeardownTestResources(ctx, c, testParams)
+	})
+
+	It("only renders and applies the templates of a worker added to a provisioned cluster", func() {
+		existingNode := clusterInstance.Spec.Nodes[0].HostName
+		addWorker()
+
+		// Deleting the rendered manifests of the cluster and of the existing node reveals any re-apply
+		testCluster, err := renderedObject("TestCluster", testParams.ClusterName)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Delete(ctx, testCluster)).To(Succeed())
+		testNode, err := renderedObject("TestNode", existingNode)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Delete(ctx, testNode)).To(Succeed())
+
+		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(completed()))
+
+		worker, err := renderedObject("TestNode", "worker-1")
+		Expect(err).ToNot(HaveOccurred())
+		bmcAddress, _, _ := unstructured.NestedString(worker.Object, "spec", "bmcAddress")
+		Expect(bmcAddress).To(Equal("192.0.2.10"))
+
+		_, err = renderedObject("TestCluster", testParams.ClusterName)
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		_, err = renderedObject("TestNode", existingNode)
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		Expect(clusterInstance.Status.ObservedGeneration).To(Equal(clusterInstance.Generation))
+		apiGroup := "test.io/v1"
+		Expect(findManifestRendered(&v1alpha1.ManifestReference{APIGroup: &apiGroup, Kind: "TestNode", Name: "worker-1"},
+			clusterInstance.Status.ManifestsRendered)).ToNot(BeNil())
+
+		nodeStatus := getOrCreateNodeStatus(clusterInstance, "worker-1")
+		cond := conditions.FindStatusCondition(nodeStatus.Conditions, string(conditions.AddedDay2))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(conditions.FindStatusCondition(getOrCreateNodeStatus(clusterInstance, existingNode).Conditions,
+			string(conditions.AddedDay2))).To(BeNil())
+
+		appliedSpec, err := ci.GetLastAppliedSpec(clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(appliedSpec.Nodes).To(HaveLen(2))
+
+		// The next reconcile is pre-empted, the existing manifests are still not re-applied
+		_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = renderedObject("TestCluster", testParams.ClusterName)
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("does not handle the nodes added along with other spec changes", func() {
+		addWorker()
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		clusterInstance.Spec.ClusterLabels = map[string]string{"foo": "bar"}
+
+		_, stop, err := r.handleAddedNodes(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stop).To(BeFalse())
+		_, err = renderedObject("TestNode", "worker-1")
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("does not handle the nodes added before the cluster is provisioned", func() {
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+			conditions.Provisioned,
+			conditions.InProgress,
+			metav1.ConditionFalse,
+			"Provisioning cluster")
+		Expect(c.Status().Update(ctx, clusterInstance)).To(Succeed())
+		addWorker()
+
+		_, stop, err := r.handleAddedNodes(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stop).To(BeFalse())
+	})
+})
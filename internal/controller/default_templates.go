@@ -0,0 +1,206 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// installMethods are the install methods that can be configured with their own default templates
+var installMethods = []v1alpha1.InstallMethod{
+	v1alpha1.InstallMethodAssisted,
+	v1alpha1.InstallMethodIPI,
+	v1alpha1.InstallMethodUPI,
+}
+
+// DefaultTemplateRefs are the cluster-level and node-level TemplateRefs used by the ClusterInstances, and the nodes,
+// that do not define their own. The TemplateRefs defined by a ClusterInstance always take precedence. The defaults of
+// the install method of the ClusterInstance in InstallMethods, when set, take precedence over the Cluster and Node
+// defaults, which only apply to the Assisted install method.
+type DefaultTemplateRefs struct {
+	Cluster []v1alpha1.TemplateRef
+	Node    []v1alpha1.TemplateRef
+
+	InstallMethods map[v1alpha1.InstallMethod]DefaultTemplateRefs
+}
+
+// ParseTemplateRefs parses the given <namespace>/<name> references to templates
+func ParseTemplateRefs(refs []string) ([]v1alpha1.TemplateRef, error) {
+	templateRefs := make([]v1alpha1.TemplateRef, 0, len(refs))
+	for _, ref := range refs {
+		namespace, name, found := strings.Cut(ref, string(types.Separator))
+		if !found || namespace == "" || name == "" {
+			return nil, fmt.Errorf("invalid template reference %q, expected <namespace>/<name>", ref)
+		}
+		templateRefs = append(templateRefs, v1alpha1.TemplateRef{Name: name, Namespace: namespace})
+	}
+	return templateRefs, nil
+}
+
+// ParseInstallMethodTemplateRefs parses the given <installMethod>=<namespace>/<name> references to templates, a
+// method may be repeated to reference several templates
+func ParseInstallMethodTemplateRefs(refs []string) (map[v1alpha1.InstallMethod][]v1alpha1.TemplateRef, error) {
+	templateRefs := map[v1alpha1.InstallMethod][]v1alpha1.TemplateRef{}
+	for _, ref := range refs {
+		method, templateRef, found := strings.Cut(ref, "=")
+		installMethod := v1alpha1.InstallMethod(method)
+		if !found || !slices.Contains(installMethods, installMethod) {
+			return nil, fmt.Errorf("invalid install method template reference %q, expected "+
+				"<Assisted|IPI|UPI>=<namespace>/<name>", ref)
+		}
+		parsed, err := ParseTemplateRefs([]string{templateRef})
+		if err != nil {
+			return nil, err
+		}
+		templateRefs[installMethod] = append(templateRefs[installMethod], parsed...)
+	}
+	return templateRefs, nil
+}
+
+// Namespaces returns the namespaces of the default templates
+func (d DefaultTemplateRefs) Namespaces() []string {
+	var namespaces []string
+	templateRefs := append(slices.Clone(d.Cluster), d.Node...)
+	for _, installMethod := range installMethods {
+		templateRefs = append(templateRefs, d.InstallMethods[installMethod].Cluster...)
+		templateRefs = append(templateRefs, d.InstallMethods[installMethod].Node...)
+	}
+	for _, templateRef := range templateRefs {
+		if !slices.Contains(namespaces, templateRef.Namespace) {
+			namespaces = append(namespaces, templateRef.Namespace)
+		}
+	}
+	return namespaces
+}
+
+// isEmpty returns true if no default TemplateRefs are configured
+func (d DefaultTemplateRefs) isEmpty() bool {
+	return len(d.Cluster) == 0 && len(d.Node) == 0 && len(d.InstallMethods) == 0
+}
+
+// forInstallMethod returns the cluster-level and node-level defaults of the install method. The Cluster and Node
+// defaults, which target the Assisted flow, are only used by the Assisted method for each level it does not configure;
+// the other install methods only use their own defaults.
+func (d DefaultTemplateRefs) forInstallMethod(installMethod v1alpha1.InstallMethod) (
+	[]v1alpha1.TemplateRef, []v1alpha1.TemplateRef) {
+	var cluster, node []v1alpha1.TemplateRef
+	if installMethod == v1alpha1.InstallMethodAssisted {
+		cluster, node = d.Cluster, d.Node
+	}
+	if defaults, ok := d.InstallMethods[installMethod]; ok {
+		if len(defaults.Cluster) > 0 {
+			cluster = defaults.Cluster
+		}
+		if len(defaults.Node) > 0 {
+			node = defaults.Node
+		}
+	}
+	return cluster, node
+}
+
+// apply sets the default TemplateRefs of the ClusterInstance and of the nodes that do not define their own. The
+// defaults are only set in memory, they are not persisted to the ClusterInstance spec. It returns the cluster and the
+// host names of the nodes the defaults were applied to, and those left without TemplateRefs because the install
+// method of the ClusterInstance does not configure defaults for their level.
+func (d DefaultTemplateRefs) apply(clusterInstance *v1alpha1.ClusterInstance) (applied, missing []string) {
+	clusterDefaults, nodeDefaults := d.forInstallMethod(clusterInstance.Spec.GetInstallMethod())
+	if len(clusterInstance.Spec.TemplateRefs) == 0 {
+		if len(clusterDefaults) > 0 {
+			clusterInstance.Spec.TemplateRefs = slices.Clone(clusterDefaults)
+			applied = append(applied, "cluster")
+		} else if len(d.Cluster) > 0 {
+			missing = append(missing, "cluster")
+		}
+	}
+	for i := range clusterInstance.Spec.Nodes {
+		node := &clusterInstance.Spec.Nodes[i]
+		if len(node.TemplateRefs) > 0 {
+			continue
+		}
+		if len(nodeDefaults) > 0 {
+			node.TemplateRefs = slices.Clone(nodeDefaults)
+			applied = append(applied, "node "+node.HostName)
+		} else if len(d.Node) > 0 {
+			missing = append(missing, "node "+node.HostName)
+		}
+	}
+	return applied, missing
+}
+
+// handleDefaultTemplateRefs applies the DefaultTemplateRefs to the ClusterInstance and reports what they were applied
+// to in the DefaultTemplateRefsApplied condition
+func (r *ClusterInstanceReconciler) handleDefaultTemplateRefs(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+) error {
+	if r.DefaultTemplateRefs.isEmpty() {
+		return nil
+	}
+
+	original := clusterInstance.DeepCopy()
+	applied, missing := r.DefaultTemplateRefs.apply(clusterInstance)
+
+	status, reason := metav1.ConditionFalse, conditions.Completed
+	message := "The ClusterInstance defines all of its TemplateRefs"
+	if len(applied) > 0 {
+		status = metav1.ConditionTrue
+		message = fmt.Sprintf("Default TemplateRefs applied to: %s", strings.Join(applied, ", "))
+		r.Log.Info(message, "ClusterInstance", clusterInstance.Name)
+	}
+	if len(missing) > 0 {
+		// The Assisted defaults are never applied to the other install methods, report the levels left without
+		// TemplateRefs rather than rendering templates of the wrong flow
+		status, reason = metav1.ConditionFalse, conditions.InstallMethodDefaultsMissing
+		message = fmt.Sprintf("No default TemplateRefs are configured for the %s install method, the defaults of the "+
+			"Assisted install method are not applied to: %s", clusterInstance.Spec.GetInstallMethod(),
+			strings.Join(missing, ", "))
+		if len(applied) > 0 {
+			message += fmt.Sprintf("; default TemplateRefs applied to: %s", strings.Join(applied, ", "))
+		}
+		r.Log.Info(message, "ClusterInstance", clusterInstance.Name)
+	}
+
+	if existing := meta.FindStatusCondition(clusterInstance.Status.Conditions,
+		string(conditions.DefaultTemplateRefsApplied)); existing != nil &&
+		existing.Status == status && existing.Reason == string(reason) && existing.Message == message {
+		return nil
+	}
+
+	// The condition is patched from the original ClusterInstance, which does not carry the in-memory defaults
+	updated := original.DeepCopy()
+	conditions.SetStatusCondition(&updated.Status.Conditions,
+		conditions.DefaultTemplateRefsApplied,
+		reason,
+		status,
+		message)
+	if err := conditions.PatchCIStatus(ctx, r.Client, updated, client.MergeFrom(original)); err != nil {
+		return err
+	}
+	clusterInstance.ResourceVersion = updated.ResourceVersion
+	clusterInstance.Status = updated.Status
+	return nil
+}
@@ -0,0 +1,206 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("DefaultTemplateRefs", func() {
+	var (
+		c        client.Client
+		r        *ClusterInstanceReconciler
+		ctx      = context.Background()
+		defaults = DefaultTemplateRefs{
+			Cluster: []v1alpha1.TemplateRef{{Name: "base-cluster-templates", Namespace: "templates"}},
+			Node:    []v1alpha1.TemplateRef{{Name: "base-node-templates", Namespace: "templates"}},
+		}
+		clusterInstance *v1alpha1.ClusterInstance
+	)
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		r = &ClusterInstanceReconciler{
+			Client:              c,
+			Scheme:              scheme.Scheme,
+			Log:                 ctrl.Log.WithName("ClusterInstanceReconciler"),
+			DefaultTemplateRefs: defaults,
+		}
+
+		clusterInstance = &v1alpha1.ClusterInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-cluster"},
+			Spec: v1alpha1.ClusterInstanceSpec{
+				ClusterName: "test-cluster",
+				Nodes: []v1alpha1.NodeSpec{
+					{HostName: "node1"},
+					{
+						HostName:     "node2",
+						TemplateRefs: []v1alpha1.TemplateRef{{Name: "custom-node-templates", Namespace: "test-cluster"}},
+					},
+				},
+			},
+		}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+	})
+
+	It("parses the <namespace>/<name> template references", func() {
+		templateRefs, err := ParseTemplateRefs([]string{"templates/base-cluster-templates"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(templateRefs).To(Equal(defaults.Cluster))
+
+		for _, ref := range []string{"base-cluster-templates", "/base-cluster-templates", "templates/"} {
+			_, err = ParseTemplateRefs([]string{ref})
+			Expect(err).To(MatchError(ContainSubstring("expected <namespace>/<name>")))
+		}
+	})
+
+	It("returns the namespaces of the default templates", func() {
+		Expect(defaults.Namespaces()).To(Equal([]string{"templates"}))
+	})
+
+	It("applies the defaults to the cluster and the nodes without TemplateRefs", func() {
+		Expect(r.handleDefaultTemplateRefs(ctx, clusterInstance)).To(Succeed())
+
+		Expect(clusterInstance.Spec.TemplateRefs).To(Equal(defaults.Cluster))
+		Expect(clusterInstance.Spec.Nodes[0].TemplateRefs).To(Equal(defaults.Node))
+		// The TemplateRefs defined by the node override the defaults
+		Expect(clusterInstance.Spec.Nodes[1].TemplateRefs).To(Equal([]v1alpha1.TemplateRef{
+			{Name: "custom-node-templates", Namespace: "test-cluster"},
+		}))
+
+		stored := &v1alpha1.ClusterInstance{}
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), stored)).To(Succeed())
+		// The defaults are not persisted to the spec
+		Expect(stored.Spec.TemplateRefs).To(BeEmpty())
+		Expect(stored.Spec.Nodes[0].TemplateRefs).To(BeEmpty())
+
+		condition := conditions.FindStatusCondition(stored.Status.Conditions,
+			string(conditions.DefaultTemplateRefsApplied))
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Message).To(Equal("Default TemplateRefs applied to: cluster, node node1"))
+	})
+
+	It("does not apply the defaults when the ClusterInstance defines all of its TemplateRefs", func() {
+		clusterInstance.Spec.TemplateRefs = []v1alpha1.TemplateRef{{Name: "custom", Namespace: "test-cluster"}}
+		clusterInstance.Spec.Nodes[0].TemplateRefs = []v1alpha1.TemplateRef{{Name: "custom", Namespace: "test-cluster"}}
+		Expect(c.Update(ctx, clusterInstance)).To(Succeed())
+
+		Expect(r.handleDefaultTemplateRefs(ctx, clusterInstance)).To(Succeed())
+		Expect(clusterInstance.Spec.TemplateRefs).To(Equal([]v1alpha1.TemplateRef{
+			{Name: "custom", Namespace: "test-cluster"},
+		}))
+
+		condition := conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.DefaultTemplateRefsApplied))
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+	})
+
+	It("parses the <installMethod>=<namespace>/<name> template references", func() {
+		templateRefs, err := ParseInstallMethodTemplateRefs([]string{
+			"IPI=templates/ipi-cluster-templates", "IPI=templates/ipi-extras", "UPI=templates/upi-cluster-templates",
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(templateRefs).To(Equal(map[v1alpha1.InstallMethod][]v1alpha1.TemplateRef{
+			v1alpha1.InstallMethodIPI: {
+				{Name: "ipi-cluster-templates", Namespace: "templates"},
+				{Name: "ipi-extras", Namespace: "templates"},
+			},
+			v1alpha1.InstallMethodUPI: {{Name: "upi-cluster-templates", Namespace: "templates"}},
+		}))
+
+		for _, ref := range []string{"templates/ipi-cluster-templates", "Agent=templates/ipi-cluster-templates"} {
+			_, err = ParseInstallMethodTemplateRefs([]string{ref})
+			Expect(err).To(MatchError(ContainSubstring("expected <Assisted|IPI|UPI>=<namespace>/<name>")))
+		}
+	})
+
+	It("applies the defaults of the install method of the ClusterInstance", func() {
+		ipiDefaults := DefaultTemplateRefs{
+			Cluster: []v1alpha1.TemplateRef{{Name: "ipi-cluster-templates", Namespace: "ipi-templates"}},
+		}
+		upiDefaults := DefaultTemplateRefs{
+			Cluster: []v1alpha1.TemplateRef{{Name: "upi-cluster-templates", Namespace: "upi-templates"}},
+			Node:    []v1alpha1.TemplateRef{{Name: "upi-node-templates", Namespace: "upi-templates"}},
+		}
+		r.DefaultTemplateRefs.InstallMethods = map[v1alpha1.InstallMethod]DefaultTemplateRefs{
+			v1alpha1.InstallMethodIPI: ipiDefaults,
+			v1alpha1.InstallMethodUPI: upiDefaults,
+		}
+		Expect(r.DefaultTemplateRefs.Namespaces()).To(Equal([]string{"templates", "ipi-templates", "upi-templates"}))
+
+		// The IPI method does not fall back to the default node-level templates, which target the Assisted flow
+		ipi := clusterInstance.DeepCopy()
+		ipi.Spec.InstallMethod = v1alpha1.InstallMethodIPI
+		applied, missing := r.DefaultTemplateRefs.apply(ipi)
+		Expect(ipi.Spec.TemplateRefs).To(Equal(ipiDefaults.Cluster))
+		Expect(ipi.Spec.Nodes[0].TemplateRefs).To(BeEmpty())
+		Expect(applied).To(Equal([]string{"cluster"}))
+		Expect(missing).To(Equal([]string{"node node1"}))
+
+		upi := clusterInstance.DeepCopy()
+		upi.Spec.InstallMethod = v1alpha1.InstallMethodUPI
+		_, missing = r.DefaultTemplateRefs.apply(upi)
+		Expect(missing).To(BeEmpty())
+		Expect(upi.Spec.TemplateRefs).To(Equal(upiDefaults.Cluster))
+		Expect(upi.Spec.Nodes[0].TemplateRefs).To(Equal(upiDefaults.Node))
+
+		// The Assisted method, the default, uses the default templates
+		assisted := clusterInstance.DeepCopy()
+		r.DefaultTemplateRefs.apply(assisted)
+		Expect(assisted.Spec.TemplateRefs).To(Equal(defaults.Cluster))
+		Expect(assisted.Spec.Nodes[0].TemplateRefs).To(Equal(defaults.Node))
+	})
+
+	It("reports the levels left without TemplateRefs when the install method has no defaults", func() {
+		clusterInstance.Spec.InstallMethod = v1alpha1.InstallMethodIPI
+		Expect(r.handleDefaultTemplateRefs(ctx, clusterInstance)).To(Succeed())
+
+		// The Assisted defaults are not applied to the IPI ClusterInstance
+		Expect(clusterInstance.Spec.TemplateRefs).To(BeEmpty())
+		Expect(clusterInstance.Spec.Nodes[0].TemplateRefs).To(BeEmpty())
+
+		condition := conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.DefaultTemplateRefsApplied))
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(string(conditions.InstallMethodDefaultsMissing)))
+		Expect(condition.Message).To(Equal("No default TemplateRefs are configured for the IPI install method, the " +
+			"defaults of the Assisted install method are not applied to: cluster, node node1"))
+	})
+
+	It("does nothing when no defaults are configured", func() {
+		r.DefaultTemplateRefs = DefaultTemplateRefs{}
+		Expect(r.handleDefaultTemplateRefs(ctx, clusterInstance)).To(Succeed())
+		Expect(clusterInstance.Spec.TemplateRefs).To(BeEmpty())
+		Expect(clusterInstance.Status.Conditions).To(BeEmpty())
+	})
+})
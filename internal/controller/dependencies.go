@@ -0,0 +1,162 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+)
+
+// dependenciesRequeueInterval is the interval at which the provisioning of the dependencies of a ClusterInstance is
+// re-checked
+const dependenciesRequeueInterval = time.Minute
+
+// findDependencyCycle follows the DependsOn references from the ClusterInstance and returns the names of the
+// ClusterInstances forming the first dependency cycle found, the first name being repeated at the end, or nil if there
+// is no cycle. Missing dependencies are ignored.
+func findDependencyCycle(
+	ctx context.Context,
+	c client.Reader,
+	clusterInstance *v1alpha1.ClusterInstance,
+) ([]string, error) {
+	acyclic := map[string]bool{}
+
+	var visit func(name string, dependsOn []corev1.LocalObjectReference, path []string) ([]string, error)
+	visit = func(name string, dependsOn []corev1.LocalObjectReference, path []string) ([]string, error) {
+		path = append(path, name)
+		for _, dependency := range dependsOn {
+			for i := range path {
+				if path[i] == dependency.Name {
+					return append(append([]string{}, path[i:]...), dependency.Name), nil
+				}
+			}
+			if acyclic[dependency.Name] {
+				continue
+			}
+
+			dependencyInstance := &v1alpha1.ClusterInstance{}
+			key := client.ObjectKey{Name: dependency.Name, Namespace: clusterInstance.Namespace}
+			if err := c.Get(ctx, key, dependencyInstance); err != nil {
+				if errors.IsNotFound(err) {
+					acyclic[dependency.Name] = true
+					continue
+				}
+				return nil, err
+			}
+			if cycle, err := visit(dependency.Name, dependencyInstance.Spec.DependsOn, path); cycle != nil || err != nil {
+				return cycle, err
+			}
+		}
+		acyclic[name] = true
+		return nil, nil
+	}
+
+	return visit(clusterInstance.Name, clusterInstance.Spec.DependsOn, nil)
+}
+
+// pendingDependencies returns the names of the ClusterInstances listed in the DependsOn of the ClusterInstance that do
+// not report a completed provisioning, the missing ones being flagged as not found
+func pendingDependencies(
+	ctx context.Context,
+	c client.Reader,
+	clusterInstance *v1alpha1.ClusterInstance,
+) ([]string, error) {
+	var pending []string
+	for _, dependency := range clusterInstance.Spec.DependsOn {
+		dependencyInstance := &v1alpha1.ClusterInstance{}
+		key := client.ObjectKey{Name: dependency.Name, Namespace: clusterInstance.Namespace}
+		if err := c.Get(ctx, key, dependencyInstance); err != nil {
+			if errors.IsNotFound(err) {
+				pending = append(pending, fmt.Sprintf("%s (not found)", dependency.Name))
+				continue
+			}
+			return nil, err
+		}
+
+		provisioned := meta.FindStatusCondition(dependencyInstance.Status.Conditions, string(conditions.Provisioned))
+		if provisioned == nil || provisioned.Reason != string(conditions.Completed) {
+			pending = append(pending, dependency.Name)
+		}
+	}
+	return pending, nil
+}
+
+// handleDependencies holds the rendering of the ClusterInstance until the ClusterInstances it depends on are
+// provisioned, and reports the pending dependencies or a dependency cycle in the WaitingForDependencies condition. The
+// dependencies are no longer checked once the provisioning of the ClusterInstance has started. It returns true when
+// the reconcile should stop.
+func (r *ClusterInstanceReconciler) handleDependencies(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+) (ctrl.Result, bool, error) {
+	patch := client.MergeFrom(clusterInstance.DeepCopy())
+
+	var (
+		reason  conditions.ConditionReason
+		message string
+	)
+
+	if len(clusterInstance.Spec.DependsOn) > 0 && !isProvisioningStarted(clusterInstance) {
+		cycle, err := findDependencyCycle(ctx, r.Client, clusterInstance)
+		if err != nil {
+			return ctrl.Result{}, true, err
+		}
+		pending, err := pendingDependencies(ctx, r.Client, clusterInstance)
+		if err != nil {
+			return ctrl.Result{}, true, err
+		}
+
+		if cycle != nil {
+			reason = conditions.DependencyCycle
+			message = fmt.Sprintf("Dependency cycle detected: %s", strings.Join(cycle, " -> "))
+		} else if len(pending) > 0 {
+			reason = conditions.DependenciesNotProvisioned
+			message = fmt.Sprintf("Waiting for the dependencies to be provisioned: %s", strings.Join(pending, ", "))
+		}
+	}
+
+	if reason == "" {
+		if meta.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.WaitingForDependencies)) != nil {
+			meta.RemoveStatusCondition(&clusterInstance.Status.Conditions, string(conditions.WaitingForDependencies))
+			return completed(), false, conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
+		}
+		return completed(), false, nil
+	}
+
+	r.Log.Info(message, "ClusterInstance", clusterInstance.Name)
+	conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+		conditions.WaitingForDependencies,
+		reason,
+		metav1.ConditionTrue,
+		message)
+	// The status of the dependencies is not watched, re-check it periodically
+	return requeueAfter(dependenciesRequeueInterval), true,
+		conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("handleDependencies", func() {
+	var (
+		c   client.Client
+		r   *ClusterInstanceReconciler
+		ctx = context.Background()
+	)
+
+	newClusterInstance := func(name string, dependsOn ...string) *v1alpha1.ClusterInstance {
+		clusterInstance := &v1alpha1.ClusterInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-namespace"},
+			Spec:       v1alpha1.ClusterInstanceSpec{ClusterName: name},
+		}
+		for _, dependency := range dependsOn {
+			clusterInstance.Spec.DependsOn = append(clusterInstance.Spec.DependsOn,
+				corev1.LocalObjectReference{Name: dependency})
+		}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+		return clusterInstance
+	}
+
+	setProvisioned := func(clusterInstance *v1alpha1.ClusterInstance, reason conditions.ConditionReason) {
+		patch := client.MergeFrom(clusterInstance.DeepCopy())
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+			conditions.Provisioned,
+			reason,
+			metav1.ConditionFalse,
+			"Provisioning cluster")
+		Expect(conditions.PatchCIStatus(ctx, c, clusterInstance, patch)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		r = &ClusterInstanceReconciler{
+			Client: c,
+			Scheme: scheme.Scheme,
+			Log:    ctrl.Log.WithName("ClusterInstanceReconciler"),
+		}
+	})
+
+	It("does not hold a ClusterInstance without dependencies", func() {
+		clusterInstance := newClusterInstance("cluster-a")
+
+		res, stop, err := r.handleDependencies(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stop).To(BeFalse())
+		Expect(res).To(Equal(completed()))
+		Expect(meta.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.WaitingForDependencies))).To(BeNil())
+	})
+
+	It("waits for the dependencies to be provisioned and then releases the ClusterInstance", func() {
+		clusterA := newClusterInstance("cluster-a")
+		setProvisioned(clusterA, conditions.InProgress)
+		clusterInstance := newClusterInstance("cluster-b", "cluster-a", "cluster-c")
+
+		res, stop, err := r.handleDependencies(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stop).To(BeTrue())
+		Expect(res).To(Equal(requeueAfter(dependenciesRequeueInterval)))
+		cond := meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.WaitingForDependencies))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(cond.Reason).To(Equal(string(conditions.DependenciesNotProvisioned)))
+		Expect(cond.Message).To(ContainSubstring("cluster-a, cluster-c (not found)"))
+
+		setProvisioned(clusterA, conditions.Completed)
+		clusterC := newClusterInstance("cluster-c")
+		setProvisioned(clusterC, conditions.Completed)
+
+		res, stop, err = r.handleDependencies(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stop).To(BeFalse())
+		Expect(res).To(Equal(completed()))
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		Expect(meta.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.WaitingForDependencies))).To(BeNil())
+	})
+
+	It("no longer checks the dependencies once the provisioning has started", func() {
+		newClusterInstance("cluster-a")
+		clusterInstance := newClusterInstance("cluster-b", "cluster-a")
+		setProvisioned(clusterInstance, conditions.InProgress)
+
+		_, stop, err := r.handleDependencies(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stop).To(BeFalse())
+	})
+
+	It("reports a dependency cycle", func() {
+		newClusterInstance("cluster-a", "cluster-c")
+		newClusterInstance("cluster-c", "cluster-b")
+		clusterInstance := newClusterInstance("cluster-b", "cluster-a")
+
+		_, stop, err := r.handleDependencies(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stop).To(BeTrue())
+		cond := meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.WaitingForDependencies))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Reason).To(Equal(string(conditions.DependencyCycle)))
+		Expect(cond.Message).To(Equal("Dependency cycle detected: cluster-b -> cluster-a -> cluster-c -> cluster-b"))
+	})
+
+	It("reports a ClusterInstance depending on itself", func() {
+		clusterInstance := newClusterInstance("cluster-a", "cluster-a")
+
+		cycle, err := findDependencyCycle(ctx, c, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cycle).To(Equal([]string{"cluster-a", "cluster-a"}))
+	})
+
+	It("does not report shared dependencies as a cycle", func() {
+		newClusterInstance("cluster-a")
+		newClusterInstance("cluster-b", "cluster-a")
+		newClusterInstance("cluster-c", "cluster-a")
+		clusterInstance := newClusterInstance("cluster-d", "cluster-b", "cluster-c")
+
+		cycle, err := findDependencyCycle(ctx, c, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cycle).To(BeNil())
+	})
+})
@@ -0,0 +1,168 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	ci "github.com/stolostron/siteconfig/internal/controller/clusterinstance"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// dnsRecordsCreated returns true if the DNS records of the ClusterInstance were created, as reported by its
+// DNSRecordsReady condition
+func dnsRecordsCreated(clusterInstance *v1alpha1.ClusterInstance) bool {
+	return meta.IsStatusConditionTrue(clusterInstance.Status.Conditions, string(conditions.DNSRecordsReady))
+}
+
+// createdDNSRecords returns the DNS records created for the ClusterInstance, as recorded in its status, falling back to
+// the records of its spec when they were created before being recorded
+func createdDNSRecords(clusterInstance *v1alpha1.ClusterInstance) ([]ci.DNSRecord, error) {
+	if !dnsRecordsCreated(clusterInstance) {
+		return nil, nil
+	}
+	if len(clusterInstance.Status.DNSRecords) == 0 {
+		return ci.ClusterDNSRecords(clusterInstance), nil
+	}
+	records := make([]ci.DNSRecord, 0, len(clusterInstance.Status.DNSRecords))
+	for _, key := range clusterInstance.Status.DNSRecords {
+		record, err := ci.ParseDNSRecordKey(key)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// deleteDNSRecords deletes the DNS records of the ClusterInstance, if they were created
+func (r *ClusterInstanceReconciler) deleteDNSRecords(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+) error {
+	if r.DNSProvider == nil {
+		return nil
+	}
+	records, err := createdDNSRecords(clusterInstance)
+	if err != nil || len(records) == 0 {
+		return err
+	}
+	if err := r.DNSProvider.DeleteRecords(ctx, records); err != nil {
+		return fmt.Errorf("failed to delete the DNS records: %w", err)
+	}
+	r.Log.Info("Deleted the DNS records", "ClusterInstance", clusterInstance.Name)
+	return nil
+}
+
+// handleDNSRecords creates the api and *.apps DNS records of the cluster with the DNSProvider when the ClusterInstance
+// manages its DNS, and reports them in the DNSRecordsReady condition. The created records are recorded in the status,
+// those no longer desired, e.g. after a change of the VIPs, are deleted. The records are deleted and the condition
+// removed once the ClusterInstance no longer manages its DNS. A failure to create the records is returned, so that the
+// cluster is not installed without them.
+func (r *ClusterInstanceReconciler) handleDNSRecords(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+) error {
+	original := clusterInstance.DeepCopy()
+	patch := client.MergeFrom(original)
+
+	if !clusterInstance.Spec.ManageDNS {
+		if meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.DNSRecordsReady)) == nil {
+			return nil
+		}
+		if err := r.deleteDNSRecords(ctx, clusterInstance); err != nil {
+			return err
+		}
+		meta.RemoveStatusCondition(&clusterInstance.Status.Conditions, string(conditions.DNSRecordsReady))
+		clusterInstance.Status.DNSRecords = nil
+		return conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
+	}
+
+	var createErr error
+	if r.DNSProvider == nil {
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+			conditions.DNSRecordsReady,
+			conditions.DNSProviderNotConfigured,
+			metav1.ConditionFalse,
+			"manageDNS is set, but no DNS provider is configured on the controller")
+	} else {
+		records := ci.ClusterDNSRecords(clusterInstance)
+		if createErr = r.ensureDNSRecords(ctx, clusterInstance, records); createErr != nil {
+			conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+				conditions.DNSRecordsReady,
+				conditions.Failed,
+				metav1.ConditionFalse,
+				conditions.SanitizeMessage(createErr.Error(), conditions.MaxMessageLength))
+		} else {
+			message := "No DNS records are needed, the cluster defines no API or ingress VIPs"
+			if len(records) > 0 {
+				names := make([]string, 0, len(records))
+				for _, record := range records {
+					names = append(names, record.String())
+				}
+				message = fmt.Sprintf("DNS records created: %s", strings.Join(names, "; "))
+			}
+			conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+				conditions.DNSRecordsReady,
+				conditions.Completed,
+				metav1.ConditionTrue,
+				message)
+		}
+	}
+
+	if conditions.StatusChanged(&original.Status, &clusterInstance.Status) {
+		if err := conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch); err != nil {
+			return err
+		}
+	}
+	return createErr
+}
+
+// ensureDNSRecords creates the desired DNS records, deletes the previously created records that are no longer desired
+// and records the created records in the status of the ClusterInstance
+func (r *ClusterInstanceReconciler) ensureDNSRecords(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+	records []ci.DNSRecord,
+) error {
+	if err := r.DNSProvider.EnsureRecords(ctx, records); err != nil {
+		return fmt.Errorf("failed to create the DNS records: %w", err)
+	}
+
+	stale, err := ci.StaleDNSRecords(clusterInstance.Status.DNSRecords, records)
+	if err != nil {
+		return err
+	}
+	if len(stale) > 0 {
+		if err := r.DNSProvider.DeleteRecords(ctx, stale); err != nil {
+			return fmt.Errorf("failed to delete the stale DNS records: %w", err)
+		}
+		r.Log.Info("Deleted the stale DNS records", "ClusterInstance", clusterInstance.Name, "records", stale)
+	}
+
+	clusterInstance.Status.DNSRecords = make([]string, 0, len(records))
+	for _, record := range records {
+		clusterInstance.Status.DNSRecords = append(clusterInstance.Status.DNSRecords, record.Key())
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	ci "github.com/stolostron/siteconfig/internal/controller/clusterinstance"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeDNSProvider records the DNS records it holds, keyed by name and type
+type fakeDNSProvider struct {
+	records map[string]ci.DNSRecord
+	err     error
+}
+
+func (p *fakeDNSProvider) EnsureRecords(_ context.Context, records []ci.DNSRecord) error {
+	if p.err != nil {
+		return p.err
+	}
+	for _, record := range records {
+		p.records[record.Name+" "+record.Type] = record
+	}
+	return nil
+}
+
+func (p *fakeDNSProvider) DeleteRecords(_ context.Context, records []ci.DNSRecord) error {
+	if p.err != nil {
+		return p.err
+	}
+	for _, record := range records {
+		delete(p.records, record.Name+" "+record.Type)
+	}
+	return nil
+}
+
+var _ = Describe("handleDNSRecords", func() {
+	var (
+		c               client.Client
+		r               *ClusterInstanceReconciler
+		provider        *fakeDNSProvider
+		ctx             = context.Background()
+		clusterInstance *v1alpha1.ClusterInstance
+	)
+
+	dnsCondition := func() *metav1.Condition {
+		stored := &v1alpha1.ClusterInstance{}
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), stored)).To(Succeed())
+		return meta.FindStatusCondition(stored.Status.Conditions, string(conditions.DNSRecordsReady))
+	}
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		provider = &fakeDNSProvider{records: map[string]ci.DNSRecord{}}
+		r = &ClusterInstanceReconciler{
+			Client:      c,
+			Scheme:      scheme.Scheme,
+			Log:         ctrl.Log.WithName("ClusterInstanceReconciler"),
+			DNSProvider: provider,
+		}
+
+		clusterInstance = &v1alpha1.ClusterInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-cluster"},
+			Spec: v1alpha1.ClusterInstanceSpec{
+				ClusterName: "test-cluster",
+				BaseDomain:  "example.com",
+				ClusterType: v1alpha1.ClusterTypeHighlyAvailable,
+				ApiVIPs:     []string{"192.0.2.10"},
+				IngressVIPs: []string{"192.0.2.11"},
+				ManageDNS:   true,
+			},
+		}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+	})
+
+	It("creates the api and *.apps records of the cluster", func() {
+		Expect(r.handleDNSRecords(ctx, clusterInstance)).To(Succeed())
+
+		Expect(provider.records).To(Equal(map[string]ci.DNSRecord{
+			"api.test-cluster.example.com A": {
+				Name: "api.test-cluster.example.com", Type: "A", Targets: []string{"192.0.2.10"},
+			},
+			"*.apps.test-cluster.example.com A": {
+				Name: "*.apps.test-cluster.example.com", Type: "A", Targets: []string{"192.0.2.11"},
+			},
+		}))
+		cond := dnsCondition()
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(cond.Reason).To(Equal(string(conditions.Completed)))
+		Expect(cond.Message).To(Equal("DNS records created: api.test-cluster.example.com A 192.0.2.10; " +
+			"*.apps.test-cluster.example.com A 192.0.2.11"))
+	})
+
+	It("deletes the records that are no longer desired after a change of the VIPs", func() {
+		clusterInstance.Spec.ApiVIPs = []string{"192.0.2.10", "2001:db8::10"}
+		Expect(r.handleDNSRecords(ctx, clusterInstance)).To(Succeed())
+		Expect(provider.records).To(HaveKey("api.test-cluster.example.com AAAA"))
+
+		clusterInstance.Spec.ApiVIPs = []string{"192.0.2.20"}
+		Expect(r.handleDNSRecords(ctx, clusterInstance)).To(Succeed())
+		Expect(provider.records).To(Equal(map[string]ci.DNSRecord{
+			"api.test-cluster.example.com A": {
+				Name: "api.test-cluster.example.com", Type: "A", Targets: []string{"192.0.2.20"},
+			},
+			"*.apps.test-cluster.example.com A": {
+				Name: "*.apps.test-cluster.example.com", Type: "A", Targets: []string{"192.0.2.11"},
+			},
+		}))
+
+		stored := &v1alpha1.ClusterInstance{}
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), stored)).To(Succeed())
+		Expect(stored.Status.DNSRecords).To(Equal([]string{
+			"api.test-cluster.example.com A", "*.apps.test-cluster.example.com A",
+		}))
+	})
+
+	It("reports that no records are needed when the cluster defines no VIPs", func() {
+		clusterInstance.Spec.ClusterType = v1alpha1.ClusterTypeSNO
+		clusterInstance.Spec.ApiVIPs, clusterInstance.Spec.IngressVIPs = nil, nil
+
+		Expect(r.handleDNSRecords(ctx, clusterInstance)).To(Succeed())
+		Expect(provider.records).To(BeEmpty())
+
+		cond := dnsCondition()
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(cond.Message).To(Equal("No DNS records are needed, the cluster defines no API or ingress VIPs"))
+	})
+
+	It("reports and returns the failure to create the records", func() {
+		provider.err = fmt.Errorf("zone is read-only")
+
+		err := r.handleDNSRecords(ctx, clusterInstance)
+		Expect(err).To(MatchError(ContainSubstring("zone is read-only")))
+
+		cond := dnsCondition()
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Reason).To(Equal(string(conditions.Failed)))
+		Expect(cond.Message).To(Equal("failed to create the DNS records: zone is read-only"))
+	})
+
+	It("reports that no DNS provider is configured", func() {
+		r.DNSProvider = nil
+
+		Expect(r.handleDNSRecords(ctx, clusterInstance)).To(Succeed())
+
+		cond := dnsCondition()
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Reason).To(Equal(string(conditions.DNSProviderNotConfigured)))
+	})
+
+	It("deletes the records and removes the condition once the DNS is no longer managed", func() {
+		Expect(r.handleDNSRecords(ctx, clusterInstance)).To(Succeed())
+		Expect(provider.records).To(HaveLen(2))
+
+		clusterInstance.Spec.ManageDNS = false
+		Expect(r.handleDNSRecords(ctx, clusterInstance)).To(Succeed())
+		Expect(provider.records).To(BeEmpty())
+		Expect(dnsCondition()).To(BeNil())
+	})
+
+	It("deletes the records when the ClusterInstance is finalized", func() {
+		Expect(r.handleDNSRecords(ctx, clusterInstance)).To(Succeed())
+		Expect(provider.records).To(HaveLen(2))
+
+		// The recorded records are deleted, even though the VIPs changed since they were created
+		clusterInstance.Spec.ApiVIPs = []string{"2001:db8::10"}
+
+		Expect(r.finalizeClusterInstance(ctx, clusterInstance)).To(Succeed())
+		Expect(provider.records).To(BeEmpty())
+	})
+
+	It("does not delete the records that were not created", func() {
+		provider.records["api.test-cluster.example.com A"] = ci.DNSRecord{Name: "api.test-cluster.example.com"}
+
+		Expect(r.finalizeClusterInstance(ctx, clusterInstance)).To(Succeed())
+		Expect(provider.records).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,165 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	k8syaml "sigs.k8s.io/yaml"
+)
+
+const (
+	// installReportPhaseKey is the key of the install report ConfigMap holding the terminal phase of the cluster
+	installReportPhaseKey = "phase"
+	// installReportKey is the key of the install report ConfigMap holding the report
+	installReportKey = "report.yaml"
+)
+
+// installReport summarizes the provisioning outcome of a ClusterInstance for the downstream systems
+type installReport struct {
+	ClusterName          string                              `json:"clusterName"`
+	Phase                string                              `json:"phase"`
+	CompletedAt          metav1.Time                         `json:"completedAt"`
+	ClusterImageSet      string                              `json:"clusterImageSet"`
+	InstalledVersion     string                              `json:"installedVersion,omitempty"`
+	InfraID              string                              `json:"infraID,omitempty"`
+	APIURL               string                              `json:"apiURL,omitempty"`
+	ConsoleURL           string                              `json:"consoleURL,omitempty"`
+	Failures             []string                            `json:"failures,omitempty"`
+	Conditions           []metav1.Condition                  `json:"conditions,omitempty"`
+	DeploymentConditions []hivev1.ClusterDeploymentCondition `json:"deploymentConditions,omitempty"`
+	Nodes                []v1alpha1.NodeStatus               `json:"nodes,omitempty"`
+}
+
+// InstallReportConfigMapName returns the name of the ConfigMap holding the install report of the ClusterInstance
+func InstallReportConfigMapName(clusterInstance *v1alpha1.ClusterInstance) string {
+	return clusterInstance.Name + "-install-report"
+}
+
+// isTerminalPhase returns true if the provisioning of the cluster reached its final outcome
+func isTerminalPhase(phase string) bool {
+	return phase == conditions.PhaseProvisioned || phase == conditions.PhaseFailed
+}
+
+// installFailures returns the failures reported by the ClusterInstance conditions, the ClusterDeployment install
+// conditions and the nodes
+func installFailures(clusterInstance *v1alpha1.ClusterInstance) []string {
+	var failures []string
+	for _, cond := range clusterInstance.Status.Conditions {
+		if cond.Status == metav1.ConditionFalse &&
+			(cond.Reason == string(conditions.Failed) || cond.Reason == string(conditions.TimedOut)) {
+			failures = append(failures, fmt.Sprintf("%s: %s", cond.Type, cond.Message))
+		}
+	}
+	if installFailed := conditions.FindCDConditionType(clusterInstance.Status.DeploymentConditions,
+		hivev1.ClusterInstallFailedClusterDeploymentCondition); installFailed != nil &&
+		installFailed.Status == corev1.ConditionTrue {
+		failures = append(failures, fmt.Sprintf("%s: %s", installFailed.Type, installFailed.Message))
+	}
+	for _, node := range clusterInstance.Status.Nodes {
+		if node.ErrorMessage != "" {
+			failures = append(failures, fmt.Sprintf("node %s: %s", node.HostName, node.ErrorMessage))
+		}
+		for _, validation := range node.FailedValidations {
+			failures = append(failures, fmt.Sprintf("node %s: %s: %s", node.HostName, validation.ID,
+				validation.Message))
+		}
+	}
+	return failures
+}
+
+// buildInstallReport returns the install report of the ClusterInstance in its terminal phase
+func buildInstallReport(clusterInstance *v1alpha1.ClusterInstance, phase string) installReport {
+	report := installReport{
+		ClusterName:          clusterInstance.Spec.ClusterName,
+		Phase:                phase,
+		ClusterImageSet:      clusterInstance.Spec.ClusterImageSetNameRef,
+		InstalledVersion:     clusterInstance.Status.InstalledVersion,
+		InfraID:              clusterInstance.Status.InfraID,
+		APIURL:               clusterInstance.Status.APIURL,
+		ConsoleURL:           clusterInstance.Status.ConsoleURL,
+		Failures:             installFailures(clusterInstance),
+		Conditions:           clusterInstance.Status.Conditions,
+		DeploymentConditions: clusterInstance.Status.DeploymentConditions,
+		Nodes:                clusterInstance.Status.Nodes,
+	}
+	if provisioned := conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+		string(conditions.Provisioned)); provisioned != nil {
+		report.CompletedAt = provisioned.LastTransitionTime
+	}
+	return report
+}
+
+// updateInstallReport generates the install report ConfigMap of the ClusterInstance, owned by the ClusterInstance, once
+// its provisioning reached a terminal phase. The report is generated once per terminal phase, it is only regenerated
+// when the cluster reaches another terminal phase, e.g. when a failed installation is retried successfully.
+func (r *ClusterDeploymentReconciler) updateInstallReport(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+) error {
+	if !r.InstallReports {
+		return nil
+	}
+	phase := conditions.Phase(clusterInstance)
+	if !isTerminalPhase(phase) {
+		return nil
+	}
+
+	configMap := &corev1.ConfigMap{}
+	key := types.NamespacedName{Name: InstallReportConfigMapName(clusterInstance), Namespace: clusterInstance.Namespace}
+	exists := true
+	if err := r.Get(ctx, key, configMap); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		exists = false
+	}
+	if exists && configMap.Data[installReportPhaseKey] == phase {
+		return nil
+	}
+
+	report, err := k8syaml.Marshal(buildInstallReport(clusterInstance, phase))
+	if err != nil {
+		return fmt.Errorf("failed to serialize the install report: %w", err)
+	}
+	configMap.Name = key.Name
+	configMap.Namespace = key.Namespace
+	configMap.Data = map[string]string{installReportPhaseKey: phase, installReportKey: string(report)}
+	setOwnedByLabel(clusterInstance, configMap)
+	if err := ctrl.SetControllerReference(clusterInstance, configMap, r.Scheme); err != nil {
+		return err
+	}
+	if exists {
+		err = r.Update(ctx, configMap)
+	} else {
+		err = r.Create(ctx, configMap)
+	}
+	if err != nil {
+		return err
+	}
+	r.Log.Info("Generated the install report", "ClusterInstance", clusterInstance.Name, "phase", phase)
+	return nil
+}
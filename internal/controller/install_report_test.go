@@ -0,0 +1,179 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	ci "github.com/stolostron/siteconfig/internal/controller/clusterinstance"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	k8syaml "sigs.k8s.io/yaml"
+)
+
+var _ = Describe("updateInstallReport", func() {
+	var (
+		c               client.Client
+		r               *ClusterDeploymentReconciler
+		ctx             = context.Background()
+		clusterInstance *v1alpha1.ClusterInstance
+		testParams      = &ci.TestParams{
+			BmcCredentialsName:  "bmh-secret",
+			ClusterName:         "test-cluster",
+			ClusterNamespace:    "test-cluster",
+			ClusterImageSetName: "testimage:foobar",
+			PullSecret:          "pull-secret",
+		}
+	)
+
+	getReport := func() (*corev1.ConfigMap, installReport) {
+		configMap := &corev1.ConfigMap{}
+		Expect(c.Get(ctx, types.NamespacedName{
+			Name:      InstallReportConfigMapName(clusterInstance),
+			Namespace: clusterInstance.Namespace,
+		}, configMap)).To(Succeed())
+		report := installReport{}
+		Expect(k8syaml.Unmarshal([]byte(configMap.Data[installReportKey]), &report)).To(Succeed())
+		return configMap, report
+	}
+
+	setProvisioned := func(reason conditions.ConditionReason, status metav1.ConditionStatus, message string) {
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions, conditions.Provisioned, reason, status,
+			message)
+	}
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+		r = &ClusterDeploymentReconciler{
+			Client:         c,
+			Scheme:         scheme.Scheme,
+			Log:            ctrl.Log.WithName("ClusterDeploymentReconciler"),
+			InstallReports: true,
+		}
+		clusterInstance = testParams.GenerateSNOClusterInstance()
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+		clusterInstance.Status.APIURL = "https://api.test-cluster.example.com:6443"
+		clusterInstance.Status.ConsoleURL = "https://console-openshift-console.apps.test-cluster.example.com"
+		clusterInstance.Status.Nodes = []v1alpha1.NodeStatus{{HostName: "node1", BareMetalHostState: "provisioned"}}
+	})
+
+	It("summarizes the outcome of a successful installation", func() {
+		setProvisioned(conditions.Completed, metav1.ConditionTrue, "Provisioning completed")
+		clusterInstance.Status.InstalledVersion = "4.16.3"
+		clusterInstance.Status.InfraID = "test-cluster-x7k2p"
+		clusterInstance.Status.DeploymentConditions = []hivev1.ClusterDeploymentCondition{{
+			Type:   hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+			Status: corev1.ConditionTrue,
+			Reason: "InstallationCompleted",
+		}}
+
+		Expect(r.updateInstallReport(ctx, clusterInstance)).To(Succeed())
+
+		configMap, report := getReport()
+		Expect(configMap.Data[installReportPhaseKey]).To(Equal(conditions.PhaseProvisioned))
+		Expect(configMap.Labels).To(HaveKeyWithValue(OwnedByLabel, ownedByLabelValue(clusterInstance)))
+		Expect(metav1.IsControlledBy(configMap, clusterInstance)).To(BeTrue())
+
+		Expect(report.ClusterName).To(Equal("test-cluster"))
+		Expect(report.Phase).To(Equal(conditions.PhaseProvisioned))
+		Expect(report.ClusterImageSet).To(Equal("testimage:foobar"))
+		Expect(report.InstalledVersion).To(Equal("4.16.3"))
+		Expect(report.InfraID).To(Equal("test-cluster-x7k2p"))
+		Expect(report.APIURL).To(Equal("https://api.test-cluster.example.com:6443"))
+		Expect(report.ConsoleURL).To(Equal("https://console-openshift-console.apps.test-cluster.example.com"))
+		Expect(report.CompletedAt.IsZero()).To(BeFalse())
+		Expect(report.Failures).To(BeEmpty())
+		Expect(report.Conditions).To(HaveLen(1))
+		Expect(report.DeploymentConditions).To(HaveLen(1))
+		Expect(report.Nodes).To(Equal(clusterInstance.Status.Nodes))
+	})
+
+	It("lists the failures of a failed installation", func() {
+		setProvisioned(conditions.Failed, metav1.ConditionFalse, "Provisioning failed")
+		clusterInstance.Status.DeploymentConditions = []hivev1.ClusterDeploymentCondition{{
+			Type:    hivev1.ClusterInstallFailedClusterDeploymentCondition,
+			Status:  corev1.ConditionTrue,
+			Reason:  "InstallationFailed",
+			Message: "The installation failed: bootstrap timed out",
+		}}
+		clusterInstance.Status.Nodes[0].ErrorMessage = "BMC unreachable"
+		clusterInstance.Status.Nodes[0].FailedValidations = []v1alpha1.HostValidation{{
+			ID:      "has-min-cpu-cores",
+			Message: "Insufficient CPU cores",
+		}}
+
+		Expect(r.updateInstallReport(ctx, clusterInstance)).To(Succeed())
+
+		configMap, report := getReport()
+		Expect(configMap.Data[installReportPhaseKey]).To(Equal(conditions.PhaseFailed))
+		Expect(report.Phase).To(Equal(conditions.PhaseFailed))
+		Expect(report.InstalledVersion).To(BeEmpty())
+		Expect(report.Failures).To(Equal([]string{
+			"Provisioned: Provisioning failed",
+			"ClusterInstallFailed: The installation failed: bootstrap timed out",
+			"node node1: BMC unreachable",
+			"node node1: has-min-cpu-cores: Insufficient CPU cores",
+		}))
+	})
+
+	It("generates the report once per terminal phase", func() {
+		setProvisioned(conditions.Failed, metav1.ConditionFalse, "Provisioning failed")
+		Expect(r.updateInstallReport(ctx, clusterInstance)).To(Succeed())
+		configMap, _ := getReport()
+		resourceVersion := configMap.ResourceVersion
+
+		// The report of the same terminal phase is not regenerated
+		clusterInstance.Status.APIURL = "https://api.other.example.com:6443"
+		Expect(r.updateInstallReport(ctx, clusterInstance)).To(Succeed())
+		configMap, report := getReport()
+		Expect(configMap.ResourceVersion).To(Equal(resourceVersion))
+		Expect(report.APIURL).To(Equal("https://api.test-cluster.example.com:6443"))
+
+		// The report is regenerated once the retried installation succeeds
+		setProvisioned(conditions.Completed, metav1.ConditionTrue, "Provisioning completed")
+		Expect(r.updateInstallReport(ctx, clusterInstance)).To(Succeed())
+		configMap, report = getReport()
+		Expect(configMap.Data[installReportPhaseKey]).To(Equal(conditions.PhaseProvisioned))
+		Expect(report.Failures).To(BeEmpty())
+	})
+
+	It("does not generate the report before a terminal phase or when disabled", func() {
+		setProvisioned(conditions.InProgress, metav1.ConditionFalse, "Provisioning in progress")
+		Expect(r.updateInstallReport(ctx, clusterInstance)).To(Succeed())
+
+		r.InstallReports = false
+		setProvisioned(conditions.Completed, metav1.ConditionTrue, "Provisioning completed")
+		Expect(r.updateInstallReport(ctx, clusterInstance)).To(Succeed())
+
+		err := c.Get(ctx, types.NamespacedName{
+			Name:      InstallReportConfigMapName(clusterInstance),
+			Namespace: clusterInstance.Namespace,
+		}, &corev1.ConfigMap{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,144 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+//+kubebuilder:rbac:groups=cluster.open-cluster-management.io,resources=managedclusters,verbs=list;watch
+
+// ManagedClusterReconciler reconciles a ManagedCluster object to
+// update the Imported condition of the corresponding ClusterInstance
+type ManagedClusterReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+func (r *ManagedClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// Get the ManagedCluster CR
+	managedCluster := &clusterv1.ManagedCluster{}
+	if err := r.Get(ctx, req.NamespacedName, managedCluster); err != nil {
+		if errors.IsNotFound(err) {
+			r.Log.Info("ManagedCluster not found", "name", req.NamespacedName)
+			return completed(), nil
+		}
+		r.Log.Error(err, "Failed to get ManagedCluster")
+		// This is likely a case where the API is down, so requeue and try again shortly
+		return requeueWithError(err)
+	}
+
+	// Fetch ClusterInstance associated with ManagedCluster object
+	clusterInstance, err := getOwnerClusterInstance(ctx, r.Client, r.Log, managedCluster)
+	if err != nil {
+		return requeueWithError(err)
+	}
+	if clusterInstance == nil {
+		return completed(), nil
+	}
+
+	// Do not act on a ghost, i.e. a ClusterInstance of the same name that does not own the ManagedCluster
+	if !isOwnerUIDMatching(managedCluster, clusterInstance) {
+		r.Log.Info("ClusterInstance UID does not match the ManagedCluster owner, skipping",
+			"ManagedCluster", req.NamespacedName, "ClusterInstance UID", clusterInstance.UID)
+		return completed(), nil
+	}
+
+	original := clusterInstance.DeepCopy()
+	patch := client.MergeFrom(original)
+	updateCIImportedStatus(managedCluster, clusterInstance)
+	if conditions.StatusChanged(&original.Status, &clusterInstance.Status) {
+		if updateErr := conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch); updateErr != nil {
+			return requeueWithError(updateErr)
+		}
+	}
+
+	// Wait for the ManagedCluster to report further progress
+	return waitForEvent(), nil
+}
+
+// updateCIImportedStatus maps the ManagedClusterJoined and ManagedClusterConditionAvailable conditions of the
+// ManagedCluster into the Imported condition of the ClusterInstance, which is only True once the cluster has joined
+// the hub and is available. A cluster that joined but is reported as unavailable is marked as failed.
+func updateCIImportedStatus(managedCluster *clusterv1.ManagedCluster, clusterInstance *v1alpha1.ClusterInstance) {
+	joined := meta.FindStatusCondition(managedCluster.Status.Conditions, clusterv1.ManagedClusterConditionJoined)
+	available := meta.FindStatusCondition(managedCluster.Status.Conditions,
+		clusterv1.ManagedClusterConditionAvailable)
+
+	switch {
+	case joined == nil || joined.Status != metav1.ConditionTrue:
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+			conditions.Imported,
+			conditions.InProgress,
+			metav1.ConditionFalse,
+			"Waiting for the ManagedCluster to join the hub")
+	case available != nil && available.Status == metav1.ConditionTrue:
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+			conditions.Imported,
+			conditions.Completed,
+			metav1.ConditionTrue,
+			"ManagedCluster joined the hub and is available")
+	case available != nil && available.Status == metav1.ConditionFalse:
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+			conditions.Imported,
+			conditions.Failed,
+			metav1.ConditionFalse,
+			conditions.SanitizeMessage(fmt.Sprintf("ManagedCluster joined the hub but is not available: %s",
+				available.Message), conditions.MaxMessageLength))
+	default:
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+			conditions.Imported,
+			conditions.Unknown,
+			metav1.ConditionUnknown,
+			"ManagedCluster joined the hub, waiting for its availability to be reported")
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ManagedClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("managedClusterReconciler").
+		For(&clusterv1.ManagedCluster{},
+			// watch for create and update event for ManagedCluster
+			builder.WithPredicates(predicate.Funcs{
+				GenericFunc: func(e event.GenericEvent) bool { return false },
+				CreateFunc: func(e event.CreateEvent) bool {
+					return isOwnedByClusterInstance(e.Object)
+				},
+				DeleteFunc: func(e event.DeleteEvent) bool { return false },
+				UpdateFunc: func(e event.UpdateEvent) bool {
+					return isOwnedByClusterInstance(e.ObjectNew)
+				},
+			})).
+		Complete(instrument("managedcluster", r))
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("ManagedClusterReconciler", func() {
+	var (
+		c                client.Client
+		r                *ManagedClusterReconciler
+		ctx              = context.Background()
+		clusterName      = "test-cluster"
+		clusterNamespace = "test-namespace"
+		clusterInstance  *v1alpha1.ClusterInstance
+	)
+
+	newManagedCluster := func(managedConditions ...metav1.Condition) *clusterv1.ManagedCluster {
+		managedCluster := &clusterv1.ManagedCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: clusterName},
+			Status:     clusterv1.ManagedClusterStatus{Conditions: managedConditions},
+		}
+		setLabelOwnership(clusterInstance, managedCluster)
+		return managedCluster
+	}
+
+	reconcileImported := func(managedCluster *clusterv1.ManagedCluster) *metav1.Condition {
+		Expect(c.Create(ctx, managedCluster)).To(Succeed())
+		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: managedCluster.Name}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(waitForEvent()))
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		return meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.Imported))
+	}
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		r = &ManagedClusterReconciler{
+			Client: c,
+			Scheme: scheme.Scheme,
+			Log:    ctrl.Log.WithName("ManagedClusterReconciler"),
+		}
+
+		clusterInstance = &v1alpha1.ClusterInstance{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterName,
+				Namespace: clusterNamespace,
+			},
+			Spec: v1alpha1.ClusterInstanceSpec{
+				ClusterName:            clusterName,
+				PullSecretRef:          corev1.LocalObjectReference{Name: "pull-secret"},
+				ClusterImageSetNameRef: "testimage:foobar",
+				BaseDomain:             "example.com",
+				ClusterType:            v1alpha1.ClusterTypeSNO,
+			},
+		}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+	})
+
+	DescribeTable("maps the ManagedCluster conditions to the Imported condition",
+		func(managedConditions []metav1.Condition, status metav1.ConditionStatus,
+			reason conditions.ConditionReason, message string) {
+			cond := reconcileImported(newManagedCluster(managedConditions...))
+			Expect(cond).ToNot(BeNil())
+			Expect(cond.Status).To(Equal(status))
+			Expect(cond.Reason).To(Equal(string(reason)))
+			Expect(cond.Message).To(Equal(message))
+		},
+		Entry("no conditions reported yet", nil,
+			metav1.ConditionFalse, conditions.InProgress, "Waiting for the ManagedCluster to join the hub"),
+		Entry("not joined",
+			[]metav1.Condition{
+				{Type: clusterv1.ManagedClusterConditionJoined, Status: metav1.ConditionFalse},
+			},
+			metav1.ConditionFalse, conditions.InProgress, "Waiting for the ManagedCluster to join the hub"),
+		Entry("joined without availability",
+			[]metav1.Condition{
+				{Type: clusterv1.ManagedClusterConditionJoined, Status: metav1.ConditionTrue},
+			},
+			metav1.ConditionUnknown, conditions.Unknown,
+			"ManagedCluster joined the hub, waiting for its availability to be reported"),
+		Entry("joined with an unknown availability",
+			[]metav1.Condition{
+				{Type: clusterv1.ManagedClusterConditionJoined, Status: metav1.ConditionTrue},
+				{Type: clusterv1.ManagedClusterConditionAvailable, Status: metav1.ConditionUnknown},
+			},
+			metav1.ConditionUnknown, conditions.Unknown,
+			"ManagedCluster joined the hub, waiting for its availability to be reported"),
+		Entry("joined and available",
+			[]metav1.Condition{
+				{Type: clusterv1.ManagedClusterConditionJoined, Status: metav1.ConditionTrue},
+				{Type: clusterv1.ManagedClusterConditionAvailable, Status: metav1.ConditionTrue},
+			},
+			metav1.ConditionTrue, conditions.Completed, "ManagedCluster joined the hub and is available"),
+		Entry("joined but unavailable",
+			[]metav1.Condition{
+				{Type: clusterv1.ManagedClusterConditionJoined, Status: metav1.ConditionTrue},
+				{Type: clusterv1.ManagedClusterConditionAvailable, Status: metav1.ConditionFalse,
+					Message: "Registration agent stopped updating its lease."},
+			},
+			metav1.ConditionFalse, conditions.Failed,
+			"ManagedCluster joined the hub but is not available: Registration agent stopped updating its lease."),
+	)
+
+	It("ignores a ManagedCluster not owned by a ClusterInstance", func() {
+		managedCluster := &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: clusterName}}
+		Expect(c.Create(ctx, managedCluster)).To(Succeed())
+		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: managedCluster.Name}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(completed()))
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		Expect(meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.Imported))).To(BeNil())
+	})
+
+	It("ignores a ManagedCluster that is not found", func() {
+		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: clusterName}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(completed()))
+	})
+})
@@ -0,0 +1,127 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// The following constants define the results of a reconcile, as recorded by the reconcile metrics
+const (
+	reconcileResultSuccess = "success"
+	reconcileResultRequeue = "requeue"
+	reconcileResultError   = "error"
+)
+
+var (
+	// reconcileTotal counts the reconciles by controller and result
+	reconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "siteconfig_reconcile_total",
+		Help: "Total number of reconciles by controller and result (success, requeue or error).",
+	}, []string{"controller", "result"})
+
+	// reconcileDuration observes the duration of the reconciles by controller
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "siteconfig_reconcile_duration_seconds",
+		Help:    "Duration of the reconciles by controller, in seconds.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+	}, []string{"controller"})
+
+	// clusterInstancesByPhase is the current number of ClusterInstances by phase, as of their last reconcile
+	clusterInstancesByPhase = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "siteconfig_clusterinstances",
+		Help: "Current number of ClusterInstances by phase.",
+	}, []string{"phase"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(reconcileTotal, reconcileDuration, clusterInstancesByPhase)
+}
+
+// instrumentedReconciler records the outcome and duration of the reconciles of the wrapped reconciler
+type instrumentedReconciler struct {
+	name       string
+	reconciler reconcile.Reconciler
+}
+
+// instrument wraps the reconciler so that its reconciles are recorded under the given controller name
+func instrument(name string, reconciler reconcile.Reconciler) reconcile.Reconciler {
+	return &instrumentedReconciler{name: name, reconciler: reconciler}
+}
+
+func (i *instrumentedReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	start := time.Now()
+	res, err := i.reconciler.Reconcile(ctx, req)
+	reconcileDuration.WithLabelValues(i.name).Observe(time.Since(start).Seconds())
+	reconcileTotal.WithLabelValues(i.name, reconcileResult(res, err)).Inc()
+	return res, err
+}
+
+// reconcileResult classifies the outcome of a reconcile
+func reconcileResult(res ctrl.Result, err error) string {
+	switch {
+	case err != nil:
+		return reconcileResultError
+	case res.Requeue || res.RequeueAfter > 0:
+		return reconcileResultRequeue
+	default:
+		return reconcileResultSuccess
+	}
+}
+
+// phaseTracker tracks the last observed phase of every ClusterInstance, to maintain the clusterInstancesByPhase gauge
+// without listing all the ClusterInstances on every reconcile
+type phaseTracker struct {
+	mu     sync.Mutex
+	phases map[types.NamespacedName]string
+}
+
+var clusterInstancePhases = &phaseTracker{phases: map[types.NamespacedName]string{}}
+
+// record sets the phase of the ClusterInstance, moving it between the phase gauges
+func (p *phaseTracker) record(key types.NamespacedName, phase string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if previous, found := p.phases[key]; found {
+		if previous == phase {
+			return
+		}
+		clusterInstancesByPhase.WithLabelValues(previous).Dec()
+	}
+	p.phases[key] = phase
+	clusterInstancesByPhase.WithLabelValues(phase).Inc()
+}
+
+// forget removes the ClusterInstance from the phase gauges, once it no longer exists
+func (p *phaseTracker) forget(key types.NamespacedName) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if previous, found := p.phases[key]; found {
+		clusterInstancesByPhase.WithLabelValues(previous).Dec()
+		delete(p.phases, key)
+	}
+}
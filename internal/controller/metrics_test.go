@@ -0,0 +1,139 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	ci "github.com/stolostron/siteconfig/internal/controller/clusterinstance"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// metricValue returns the current value of the counter or gauge
+func metricValue(collector prometheus.Metric) float64 {
+	m := &dto.Metric{}
+	Expect(collector.Write(m)).To(Succeed())
+	if m.Counter != nil {
+		return m.Counter.GetValue()
+	}
+	return m.Gauge.GetValue()
+}
+
+// histogramCount returns the number of observations of the histogram of the controller
+func histogramCount(controllerName string) uint64 {
+	m := &dto.Metric{}
+	observer, err := reconcileDuration.GetMetricWithLabelValues(controllerName)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(observer.(prometheus.Metric).Write(m)).To(Succeed())
+	return m.Histogram.GetSampleCount()
+}
+
+var _ = Describe("Reconcile metrics", func() {
+	var (
+		ctx = context.Background()
+	)
+
+	DescribeTable("counts the reconciles by result",
+		func(res ctrl.Result, err error, result string) {
+			name := fmt.Sprintf("test-%s", result)
+			counter := reconcileTotal.WithLabelValues(name, result)
+			before, observed := metricValue(counter), histogramCount(name)
+
+			reconciler := instrument(name, reconcile.Func(func(context.Context, ctrl.Request) (ctrl.Result, error) {
+				return res, err
+			}))
+			gotRes, gotErr := reconciler.Reconcile(ctx, ctrl.Request{})
+			Expect(gotRes).To(Equal(res))
+			if err == nil {
+				Expect(gotErr).ToNot(HaveOccurred())
+			} else {
+				Expect(gotErr).To(MatchError(err))
+			}
+
+			Expect(metricValue(counter)).To(Equal(before + 1))
+			Expect(histogramCount(name)).To(Equal(observed + 1))
+		},
+		Entry("success", completed(), nil, reconcileResultSuccess),
+		Entry("requeue after", requeueAfter(time.Minute), nil, reconcileResultRequeue),
+		Entry("requeue", ctrl.Result{Requeue: true}, nil, reconcileResultRequeue),
+		Entry("error", ctrl.Result{}, fmt.Errorf("boom"), reconcileResultError),
+	)
+
+	It("tracks the number of ClusterInstances by phase", func() {
+		c := fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		r := &ClusterInstanceReconciler{
+			Client:     c,
+			Scheme:     scheme.Scheme,
+			Log:        ctrl.Log.WithName("ClusterInstanceReconciler"),
+			TmplEngine: ci.NewTemplateEngine(ctrl.Log.WithName("TemplateEngine")),
+		}
+		testParams := &ci.TestParams{
+			BmcCredentialsName:  "bmh-secret",
+			ClusterName:         "metrics-cluster",
+			ClusterNamespace:    "metrics-cluster",
+			ClusterImageSetName: "testimage:foobar",
+			PullSecret:          "pull-secret",
+		}
+		clusterInstance := testParams.GenerateSNOClusterInstance()
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+		key := client.ObjectKeyFromObject(clusterInstance)
+
+		reconciler := instrument("clusterinstance", r)
+		res, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(metricValue(reconcileTotal.WithLabelValues("clusterinstance", reconcileResult(res, err)))).To(
+			BeNumerically(">=", 1))
+
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		phase := conditions.Phase(clusterInstance)
+		gauge := clusterInstancesByPhase.WithLabelValues(phase)
+		Expect(clusterInstancePhases.phases).To(HaveKeyWithValue(key, phase))
+		count := metricValue(gauge)
+		Expect(count).To(BeNumerically(">=", 1))
+
+		// The ClusterInstance moves to another phase
+		clusterInstancePhases.record(key, conditions.PhaseProvisioned)
+		Expect(metricValue(gauge)).To(Equal(count - 1))
+		provisioned := clusterInstancesByPhase.WithLabelValues(conditions.PhaseProvisioned)
+		provisionedCount := metricValue(provisioned)
+
+		// The ClusterInstance is removed from the gauge once deleted, i.e. after its finalizer is removed
+		Expect(c.Delete(ctx, clusterInstance)).To(Succeed())
+		Eventually(func() map[types.NamespacedName]string {
+			_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+			Expect(err).ToNot(HaveOccurred())
+			return clusterInstancePhases.phases
+		}).WithTimeout(time.Second).ShouldNot(HaveKey(key))
+		Expect(metricValue(provisioned)).To(Equal(provisionedCount - 1))
+	})
+})
@@ -0,0 +1,108 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"slices"
+
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// WatchedNamespaces is the allowlist of the namespaces in which the ClusterInstances and ClusterDeployments are
+// reconciled, so that several instances of the controller can share a hub. All namespaces are reconciled when it is
+// empty.
+type WatchedNamespaces []string
+
+// Contains returns true if the namespace is reconciled
+func (w WatchedNamespaces) Contains(namespace string) bool {
+	return len(w) == 0 || slices.Contains(w, namespace)
+}
+
+// CacheNamespaces returns the namespaces to be cached by the manager, i.e. the watched namespaces and the additional
+// namespaces the controller reads from (e.g. the SiteConfig namespace holding the default templates). It returns nil,
+// i.e. all namespaces are cached, when the watched namespaces are not restricted.
+func (w WatchedNamespaces) CacheNamespaces(additional ...string) map[string]cache.Config {
+	if len(w) == 0 {
+		return nil
+	}
+
+	namespaces := map[string]cache.Config{}
+	for _, namespace := range append(slices.Clone(w), additional...) {
+		if namespace != "" {
+			namespaces[namespace] = cache.Config{}
+		}
+	}
+	return namespaces
+}
+
+// predicate filters the events of the objects outside of the watched namespaces
+func (w WatchedNamespaces) predicate() predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return w.Contains(obj.GetNamespace())
+	})
+}
+
+// uncachedNamespacesClient reads the objects of the namespaces that are not cached by the manager through the API
+// reader, e.g. the templates referenced by a ClusterInstance from a namespace outside of the watched namespaces, since
+// the cache fails to read them
+type uncachedNamespacesClient struct {
+	client.Client
+	apiReader        client.Reader
+	cachedNamespaces map[string]cache.Config
+}
+
+// NewUncachedNamespacesClient returns a client reading the objects of the namespaces that are not in the
+// cachedNamespaces through the API reader, and the others through the given client. The given client is returned as is
+// when all namespaces are cached, i.e. the cachedNamespaces are nil.
+func NewUncachedNamespacesClient(
+	c client.Client,
+	apiReader client.Reader,
+	cachedNamespaces map[string]cache.Config,
+) client.Client {
+	if cachedNamespaces == nil {
+		return c
+	}
+	return &uncachedNamespacesClient{Client: c, apiReader: apiReader, cachedNamespaces: cachedNamespaces}
+}
+
+// isCached returns true if the objects of the namespace are cached, cluster-scoped objects are always cached
+func (c *uncachedNamespacesClient) isCached(namespace string) bool {
+	_, ok := c.cachedNamespaces[namespace]
+	return ok || namespace == ""
+}
+
+func (c *uncachedNamespacesClient) Get(
+	ctx context.Context,
+	key client.ObjectKey,
+	obj client.Object,
+	opts ...client.GetOption,
+) error {
+	if c.isCached(key.Namespace) {
+		return c.Client.Get(ctx, key, obj, opts...)
+	}
+	return c.apiReader.Get(ctx, key, obj, opts...)
+}
+
+func (c *uncachedNamespacesClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if c.isCached((&client.ListOptions{}).ApplyOptions(opts).Namespace) {
+		return c.Client.List(ctx, list, opts...)
+	}
+	return c.apiReader.List(ctx, list, opts...)
+}
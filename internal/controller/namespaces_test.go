@@ -0,0 +1,156 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	ci "github.com/stolostron/siteconfig/internal/controller/clusterinstance"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+var _ = Describe("WatchedNamespaces", func() {
+	var (
+		c          client.Client
+		ctx        = context.Background()
+		watched    = WatchedNamespaces{"in-scope"}
+		testParams = &ci.TestParams{
+			BmcCredentialsName:  "bmh-secret",
+			ClusterName:         "test-cluster",
+			ClusterNamespace:    "out-of-scope",
+			ClusterImageSetName: "testimage:foobar",
+			PullSecret:          "pull-secret",
+		}
+	)
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+	})
+
+	It("watches all namespaces when empty", func() {
+		var all WatchedNamespaces
+		Expect(all.Contains("any")).To(BeTrue())
+		Expect(all.CacheNamespaces("siteconfig-system")).To(BeNil())
+	})
+
+	It("caches the watched and the additional namespaces", func() {
+		Expect(watched.Contains("in-scope")).To(BeTrue())
+		Expect(watched.Contains("out-of-scope")).To(BeFalse())
+		Expect(watched.CacheNamespaces("siteconfig-system", "")).To(Equal(map[string]cache.Config{
+			"in-scope":          {},
+			"siteconfig-system": {},
+		}))
+	})
+
+	It("reads the objects of the namespaces that are not cached through the API reader", func() {
+		var all WatchedNamespaces
+		Expect(NewUncachedNamespacesClient(c, nil, all.CacheNamespaces())).To(BeIdenticalTo(c))
+
+		// The cache only holds the objects of the cached namespaces
+		apiReader := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+		for _, namespace := range []string{"in-scope", "templates"} {
+			template := ci.GetMockClusterTemplate("cluster-template", namespace)
+			Expect(apiReader.Create(ctx, template.DeepCopy())).To(Succeed())
+			if namespace == "in-scope" {
+				Expect(c.Create(ctx, template)).To(Succeed())
+			}
+		}
+
+		uncached := NewUncachedNamespacesClient(c, apiReader, watched.CacheNamespaces())
+		for _, namespace := range []string{"in-scope", "templates"} {
+			Expect(uncached.Get(ctx, client.ObjectKey{Name: "cluster-template", Namespace: namespace},
+				&corev1.ConfigMap{})).To(Succeed())
+			configMaps := &corev1.ConfigMapList{}
+			Expect(uncached.List(ctx, configMaps, client.InNamespace(namespace))).To(Succeed())
+			Expect(configMaps.Items).To(HaveLen(1))
+		}
+	})
+
+	It("filters the events of the objects outside of the watched namespaces", func() {
+		p := watched.predicate()
+		inScope := &v1alpha1.ClusterInstance{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "in-scope"}}
+		outOfScope := &v1alpha1.ClusterInstance{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "out-of-scope"}}
+		Expect(p.Create(event.CreateEvent{Object: inScope})).To(BeTrue())
+		Expect(p.Create(event.CreateEvent{Object: outOfScope})).To(BeFalse())
+		Expect(p.Update(event.UpdateEvent{ObjectOld: outOfScope, ObjectNew: outOfScope})).To(BeFalse())
+	})
+
+	It("ignores the ClusterInstances outside of the watched namespaces", func() {
+		r := &ClusterInstanceReconciler{
+			Client:            c,
+			Scheme:            scheme.Scheme,
+			Log:               ctrl.Log.WithName("ClusterInstanceReconciler"),
+			TmplEngine:        ci.NewTemplateEngine(ctrl.Log.WithName("TemplateEngine")),
+			WatchedNamespaces: watched,
+		}
+		clusterInstance := testParams.GenerateSNOClusterInstance()
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		key := client.ObjectKeyFromObject(clusterInstance)
+		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(completed()))
+
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		Expect(clusterInstance.Finalizers).To(BeEmpty())
+		Expect(clusterInstance.Status.Conditions).To(BeEmpty())
+	})
+
+	It("ignores the ClusterDeployments outside of the watched namespaces", func() {
+		r := &ClusterDeploymentReconciler{
+			Client:            c,
+			Scheme:            scheme.Scheme,
+			Log:               ctrl.Log.WithName("ClusterDeploymentReconciler"),
+			WatchedNamespaces: watched,
+		}
+		clusterInstance := testParams.GenerateSNOClusterInstance()
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+		clusterDeployment := &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterInstance.Name,
+				Namespace: clusterInstance.Namespace,
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: ClusterInstanceApiVersion,
+					Kind:       v1alpha1.ClusterInstanceKind,
+					Name:       clusterInstance.Name,
+				}},
+			},
+		}
+		Expect(c.Create(ctx, clusterDeployment)).To(Succeed())
+
+		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(clusterDeployment)})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(completed()))
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		Expect(clusterInstance.Status.ClusterDeploymentRef).To(BeNil())
+	})
+})
@@ -0,0 +1,135 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+)
+
+// RetryNodeAnnotation requests the hardware resources of a single node, identified by its host name, to be deleted and
+// provisioned again without reinstalling the cluster. It is removed once the resources are deleted.
+const RetryNodeAnnotation = v1alpha1.Group + "/retry-node"
+
+// nodeRetryRequeueInterval is the interval at which the deletion of the hardware resources of a node being retried is
+// checked
+const nodeRetryRequeueInterval = 10 * time.Second
+
+// findNode returns the node of the ClusterInstance with the given host name, nil if there is none
+func findNode(clusterInstance *v1alpha1.ClusterInstance, hostName string) *v1alpha1.NodeSpec {
+	for i := range clusterInstance.Spec.Nodes {
+		if clusterInstance.Spec.Nodes[i].HostName == hostName {
+			return &clusterInstance.Spec.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// handleRetryNode deletes the hardware resources, e.g. the BareMetalHost, of the node named by the
+// RetryNodeAnnotation and clears the rendered template hashes of that node only, so that its templates are rendered
+// and applied again while the resources of the cluster and of the other nodes are left untouched. It requeues until
+// the resources are deleted and returns true when the node is being retried.
+func (r *ClusterInstanceReconciler) handleRetryNode(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+) (ctrl.Result, bool, error) {
+	hostName, ok := clusterInstance.GetAnnotations()[RetryNodeAnnotation]
+	if !ok {
+		return completed(), false, nil
+	}
+
+	// The node templates are rendered with the default TemplateRefs, without persisting them
+	rendering := clusterInstance.DeepCopy()
+	r.DefaultTemplateRefs.apply(rendering)
+
+	node := findNode(rendering, hostName)
+	if node == nil {
+		r.Log.Info("Ignoring the retry of an unknown node", "ClusterInstance", clusterInstance.Name,
+			"hostName", hostName)
+		r.Recorder.Event(clusterInstance, corev1.EventTypeWarning, "NodeRetryIgnored",
+			fmt.Sprintf("Node %q is not defined in the ClusterInstance", hostName))
+		return completed(), false, r.removeRetryNodeAnnotation(ctx, clusterInstance)
+	}
+
+	manifests, err := r.TmplEngine.RenderNodeHardwareManifests(ctx, r.Client, *rendering, *node)
+	if err != nil {
+		return completed(), false, fmt.Errorf("failed to render the hardware resources of node %s: %w", hostName, err)
+	}
+
+	deleting := false
+	for _, manifest := range manifests {
+		obj, err := toUnstructured(manifest)
+		if err != nil {
+			return completed(), false, err
+		}
+		if err := r.Client.Delete(ctx, &obj); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return completed(), false, err
+		}
+		r.Log.Info("Deleted hardware resource of the node to be retried", "hostName", hostName,
+			obj.GetKind(), obj.GetName())
+
+		// The resource may be held by finalizers, e.g. while the host is being deprovisioned
+		if err := r.Client.Get(ctx, client.ObjectKeyFromObject(&obj), &obj); err == nil {
+			deleting = true
+		} else if !errors.IsNotFound(err) {
+			return completed(), false, err
+		}
+	}
+	if deleting {
+		r.Log.Info("Waiting for the hardware resources of the node to be deleted", "hostName", hostName)
+		return requeueAfter(nodeRetryRequeueInterval), false, nil
+	}
+
+	patch := client.MergeFrom(clusterInstance.DeepCopy())
+	var hashes []v1alpha1.RenderedTemplateHash
+	for _, hash := range clusterInstance.Status.RenderedTemplateHashes {
+		if hash.HostName != hostName {
+			hashes = append(hashes, hash)
+		}
+	}
+	clusterInstance.Status.RenderedTemplateHashes = hashes
+	if err := conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch); err != nil {
+		return completed(), false, err
+	}
+	r.Recorder.Event(clusterInstance, corev1.EventTypeNormal, "NodeRetried",
+		fmt.Sprintf("Deleted the hardware resources of node %q to be provisioned again", hostName))
+
+	return completed(), true, r.removeRetryNodeAnnotation(ctx, clusterInstance)
+}
+
+func (r *ClusterInstanceReconciler) removeRetryNodeAnnotation(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+) error {
+	patch := client.MergeFrom(clusterInstance.DeepCopy())
+	annotations := clusterInstance.GetAnnotations()
+	delete(annotations, RetryNodeAnnotation)
+	clusterInstance.SetAnnotations(annotations)
+	return r.Patch(ctx, clusterInstance, patch)
+}
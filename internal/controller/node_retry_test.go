@@ -0,0 +1,183 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	bmh_v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	ci "github.com/stolostron/siteconfig/internal/controller/clusterinstance"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("handleRetryNode", func() {
+	var (
+		c               client.Client
+		r               *ClusterInstanceReconciler
+		recorder        *record.FakeRecorder
+		ctx             = context.Background()
+		clusterInstance *v1alpha1.ClusterInstance
+		nodeTemplateRef = v1alpha1.TemplateRef{Name: "node-templates", Namespace: "test-cluster"}
+	)
+
+	bmhKey := func(hostName string) client.ObjectKey {
+		return client.ObjectKey{Name: hostName, Namespace: "test-cluster"}
+	}
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		recorder = record.NewFakeRecorder(10)
+		testLogger := ctrl.Log.WithName("ClusterInstanceReconciler")
+		r = &ClusterInstanceReconciler{
+			Client:     c,
+			Scheme:     scheme.Scheme,
+			Log:        testLogger,
+			Recorder:   recorder,
+			TmplEngine: ci.NewTemplateEngine(testLogger),
+		}
+
+		Expect(c.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: nodeTemplateRef.Name, Namespace: nodeTemplateRef.Namespace},
+			Data: map[string]string{
+				"BareMetalHost": `apiVersion: metal3.io/v1alpha1
+kind: BareMetalHost
+metadata:
+  name: "{{ .SpecialVars.CurrentNode.HostName }}"
+  namespace: "{{ .Spec.ClusterName }}"`,
+			},
+		})).To(Succeed())
+
+		clusterInstance = &v1alpha1.ClusterInstance{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "test-cluster",
+				Namespace:  "test-cluster",
+				Finalizers: []string{clusterInstanceFinalizer},
+				Generation: 1,
+			},
+			Spec: v1alpha1.ClusterInstanceSpec{
+				ClusterName: "test-cluster",
+				Nodes: []v1alpha1.NodeSpec{
+					{HostName: "node1", Role: "master", TemplateRefs: []v1alpha1.TemplateRef{nodeTemplateRef}},
+					{HostName: "node2", Role: "master", TemplateRefs: []v1alpha1.TemplateRef{nodeTemplateRef}},
+				},
+			},
+			Status: v1alpha1.ClusterInstanceStatus{
+				ObservedGeneration: 1,
+				RenderedTemplateHashes: []v1alpha1.RenderedTemplateHash{
+					{TemplateRef: v1alpha1.TemplateRef{Name: "cluster-templates", Namespace: "test-cluster"},
+						Hash: "cluster"},
+					{TemplateRef: nodeTemplateRef, HostName: "node1", Hash: "node1"},
+					{TemplateRef: nodeTemplateRef, HostName: "node2", Hash: "node2"},
+				},
+			},
+		}
+
+		for _, hostName := range []string{"node1", "node2"} {
+			Expect(c.Create(ctx, &bmh_v1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{Name: hostName, Namespace: "test-cluster"},
+			})).To(Succeed())
+		}
+	})
+
+	It("does nothing when no node retry is requested", func() {
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		res, retrying, err := r.handleRetryNode(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(completed()))
+		Expect(retrying).To(BeFalse())
+		Expect(c.Get(ctx, bmhKey("node1"), &bmh_v1alpha1.BareMetalHost{})).To(Succeed())
+	})
+
+	It("re-provisions only the hardware resources of the retried node", func() {
+		clusterInstance.Annotations = map[string]string{RetryNodeAnnotation: "node1"}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		res, retrying, err := r.handleRetryNode(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(completed()))
+		Expect(retrying).To(BeTrue())
+
+		err = c.Get(ctx, bmhKey("node1"), &bmh_v1alpha1.BareMetalHost{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		Expect(c.Get(ctx, bmhKey("node2"), &bmh_v1alpha1.BareMetalHost{})).To(Succeed())
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		Expect(clusterInstance.GetAnnotations()).ToNot(HaveKey(RetryNodeAnnotation))
+		// Only the templates of the retried node are rendered and applied again
+		Expect(clusterInstance.Status.RenderedTemplateHashes).To(ConsistOf(
+			HaveField("Hash", "cluster"),
+			HaveField("Hash", "node2")))
+		Expect(recorder.Events).To(Receive(ContainSubstring("NodeRetried")))
+	})
+
+	It("waits for the hardware resources of the retried node to be deleted", func() {
+		bmh := &bmh_v1alpha1.BareMetalHost{}
+		Expect(c.Get(ctx, bmhKey("node1"), bmh)).To(Succeed())
+		bmh.Finalizers = []string{"baremetalhost.metal3.io"}
+		Expect(c.Update(ctx, bmh)).To(Succeed())
+
+		clusterInstance.Annotations = map[string]string{RetryNodeAnnotation: "node1"}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		res, retrying, err := r.handleRetryNode(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(requeueAfter(nodeRetryRequeueInterval)))
+		Expect(retrying).To(BeFalse())
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		Expect(clusterInstance.GetAnnotations()).To(HaveKeyWithValue(RetryNodeAnnotation, "node1"))
+		Expect(clusterInstance.Status.RenderedTemplateHashes).To(HaveLen(3))
+	})
+
+	It("ignores the retry of an unknown node", func() {
+		clusterInstance.Annotations = map[string]string{RetryNodeAnnotation: "node3"}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		_, retrying, err := r.handleRetryNode(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(retrying).To(BeFalse())
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		Expect(clusterInstance.GetAnnotations()).ToNot(HaveKey(RetryNodeAnnotation))
+		Expect(recorder.Events).To(Receive(ContainSubstring("NodeRetryIgnored")))
+		Expect(c.Get(ctx, bmhKey("node1"), &bmh_v1alpha1.BareMetalHost{})).To(Succeed())
+	})
+
+	It("does not pre-empt the reconcile-loop while retrying a node", func() {
+		clusterInstance.Annotations = map[string]string{RetryNodeAnnotation: "node1"}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(clusterInstance)})
+		Expect(err).ToNot(HaveOccurred())
+		// The reconcile proceeds to the secret references precheck rather than being pre-empted
+		Expect(res).To(Equal(requeueAfter(missingReferencesRequeueInterval)))
+	})
+})
@@ -0,0 +1,199 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// observedClusterDeploymentInterval is the interval at which an observe-only ClusterDeployment is compared to the
+// rendered one, as its changes are not watched
+const observedClusterDeploymentInterval = 5 * time.Minute
+
+// isClusterDeploymentObject returns true if the rendered manifest is the Hive ClusterDeployment
+func isClusterDeploymentObject(obj *unstructured.Unstructured) bool {
+	return obj.GetKind() == "ClusterDeployment" && obj.GetAPIVersion() == hivev1.SchemeGroupVersion.String()
+}
+
+// excludeClusterDeploymentManifests removes the ClusterDeployment from the rendered manifests, so that it is not
+// applied
+func excludeClusterDeploymentManifests(manifestGroups map[int][]interface{}) error {
+	for syncWave, group := range manifestGroups {
+		kept := make([]interface{}, 0, len(group))
+		for _, item := range group {
+			obj, err := toUnstructured(item)
+			if err != nil {
+				return err
+			}
+			if !isClusterDeploymentObject(&obj) {
+				kept = append(kept, item)
+			}
+		}
+		manifestGroups[syncWave] = kept
+	}
+	return nil
+}
+
+// clusterDeploymentDrift returns the sorted paths of the fields of the rendered ClusterDeployment, i.e. its spec, labels
+// and annotations, that differ in the live ClusterDeployment. The fields only set on the live ClusterDeployment, e.g.
+// those defaulted by Hive, are not reported.
+func clusterDeploymentDrift(rendered, live *unstructured.Unstructured) []string {
+	var drift []string
+	for _, path := range [][]string{{"metadata", "labels"}, {"metadata", "annotations"}, {"spec"}} {
+		renderedValue, found, _ := unstructured.NestedFieldNoCopy(rendered.Object, path...)
+		if !found {
+			continue
+		}
+		liveValue, _, _ := unstructured.NestedFieldNoCopy(live.Object, path...)
+		drift = append(drift, fieldDrift(strings.Join(path, "."), renderedValue, liveValue)...)
+	}
+	sort.Strings(drift)
+	return drift
+}
+
+// fieldDrift returns the paths of the rendered value, walking down its maps, that differ in the live value
+func fieldDrift(path string, rendered, live interface{}) []string {
+	renderedMap, ok := rendered.(map[string]interface{})
+	if !ok {
+		if equality.Semantic.DeepEqual(rendered, live) {
+			return nil
+		}
+		return []string{path}
+	}
+
+	liveMap, _ := live.(map[string]interface{})
+	var drift []string
+	for key, value := range renderedMap {
+		drift = append(drift, fieldDrift(path+"."+key, value, liveMap[key])...)
+	}
+	return drift
+}
+
+// handleObservedClusterDeployment compares the rendered ClusterDeployment of an observe-only ClusterInstance to the
+// live one, which is applied by the user, and reports the drift in the ClusterDeploymentDrifted condition. Only the
+// ClusterDeployment template is rendered. The live ClusterDeployment is recorded in the ClusterDeploymentRef, so that
+// its status is mirrored even though it is not owned by the ClusterInstance. The comparison is repeated periodically;
+// the condition is removed when the ClusterDeployment is not observe-only.
+func (r *ClusterInstanceReconciler) handleObservedClusterDeployment(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+) (ctrl.Result, error) {
+	patch := client.MergeFrom(clusterInstance.DeepCopy())
+	if !clusterInstance.Spec.ObserveOnlyClusterDeployment {
+		if meta.RemoveStatusCondition(&clusterInstance.Status.Conditions,
+			string(conditions.ClusterDeploymentDrifted)) {
+			return completed(), conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
+		}
+		return completed(), nil
+	}
+
+	manifest, err := r.TmplEngine.RenderClusterDeployment(ctx, r.Client, *clusterInstance)
+	if err != nil {
+		// The rendering errors are reported when the manifests are rendered to be applied
+		r.Log.Info("Unable to render the ClusterDeployment to observe", "ClusterInstance", clusterInstance.Name,
+			"error", err.Error())
+		return requeueAfter(observedClusterDeploymentInterval), nil
+	}
+	if manifest == nil {
+		r.Log.Info("No ClusterDeployment is rendered to observe", "ClusterInstance", clusterInstance.Name)
+		meta.RemoveStatusCondition(&clusterInstance.Status.Conditions, string(conditions.ClusterDeploymentDrifted))
+		return completed(), conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
+	}
+	rendered := &unstructured.Unstructured{Object: manifest}
+
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(rendered.GroupVersionKind())
+	key := client.ObjectKeyFromObject(rendered)
+	err = r.Get(ctx, key, live)
+	if err == nil && clusterInstance.GetAnnotations()[SkipClusterDeploymentRefInitAnnotation] != "true" {
+		// Reference the live ClusterDeployment, so that the ClusterDeploymentReconciler mirrors its status
+		clusterInstance.Status.ClusterDeploymentRef = &corev1.LocalObjectReference{Name: live.GetName()}
+	}
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return requeueWithError(err)
+		}
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+			conditions.ClusterDeploymentDrifted,
+			conditions.NotFound,
+			metav1.ConditionUnknown,
+			fmt.Sprintf("Waiting for the ClusterDeployment %s to be applied", key))
+	} else if drift := clusterDeploymentDrift(rendered, live); len(drift) > 0 {
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+			conditions.ClusterDeploymentDrifted,
+			conditions.Drifted,
+			metav1.ConditionTrue,
+			fmt.Sprintf("The ClusterDeployment %s differs from the rendered manifest: %s", key,
+				strings.Join(drift, ", ")))
+	} else {
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+			conditions.ClusterDeploymentDrifted,
+			conditions.InSync,
+			metav1.ConditionFalse,
+			fmt.Sprintf("The ClusterDeployment %s matches the rendered manifest", key))
+	}
+	return requeueAfter(observedClusterDeploymentInterval),
+		conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
+}
+
+// getObservingClusterInstance returns the observe-only ClusterInstance whose ClusterDeploymentRef references the
+// ClusterDeployment, nil if there is none. The ClusterDeployment of an observe-only ClusterInstance is applied by the
+// user, hence it is not owned by the ClusterInstance.
+func getObservingClusterInstance(
+	ctx context.Context,
+	c client.Client,
+	cd client.Object,
+) (*v1alpha1.ClusterInstance, error) {
+	clusterInstances := &v1alpha1.ClusterInstanceList{}
+	if err := c.List(ctx, clusterInstances, client.InNamespace(cd.GetNamespace())); err != nil {
+		return nil, err
+	}
+	for i := range clusterInstances.Items {
+		clusterInstance := &clusterInstances.Items[i]
+		if ref := clusterInstance.Status.ClusterDeploymentRef; clusterInstance.Spec.ObserveOnlyClusterDeployment &&
+			ref != nil && ref.Name == cd.GetName() {
+			return clusterInstance, nil
+		}
+	}
+	return nil, nil
+}
+
+// isMirroredClusterDeployment returns true if the status of the ClusterDeployment is mirrored into a ClusterInstance,
+// i.e. it is owned by a ClusterInstance or observed by an observe-only ClusterInstance
+func (r *ClusterDeploymentReconciler) isMirroredClusterDeployment(obj client.Object) bool {
+	if isOwnedByClusterInstance(obj) {
+		return true
+	}
+	clusterInstance, err := getObservingClusterInstance(context.Background(), r.Client, obj)
+	return err == nil && clusterInstance != nil
+}
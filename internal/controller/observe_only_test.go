@@ -0,0 +1,225 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	agentv1 "github.com/openshift/hive/apis/hive/v1/agent"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	ci "github.com/stolostron/siteconfig/internal/controller/clusterinstance"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const observedClusterDeploymentTemplate = `apiVersion: hive.openshift.io/v1
+kind: ClusterDeployment
+metadata:
+  name: "{{ .Spec.ClusterName }}"
+  namespace: "{{ .Spec.ClusterName }}"
+  labels:
+    team: edge
+spec:
+  baseDomain: "{{ .Spec.BaseDomain }}"
+  clusterName: "{{ .Spec.ClusterName }}"
+  platform:
+    agentBareMetal:
+      agentSelector:
+        matchLabels:
+          cluster-name: "{{ .Spec.ClusterName }}"`
+
+var _ = Describe("handleObservedClusterDeployment", func() {
+	var (
+		c               client.Client
+		r               *ClusterInstanceReconciler
+		ctx             = context.Background()
+		clusterName     = "test-cluster"
+		clusterInstance *v1alpha1.ClusterInstance
+	)
+
+	liveClusterDeployment := func() *hivev1.ClusterDeployment {
+		return &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterName,
+				Namespace: clusterName,
+				Labels:    map[string]string{"team": "edge", "hive.openshift.io/cluster-platform": "agent-baremetal"},
+			},
+			Spec: hivev1.ClusterDeploymentSpec{
+				BaseDomain:  "example.com",
+				ClusterName: clusterName,
+				Platform: hivev1.Platform{
+					AgentBareMetal: &agentv1.BareMetalPlatform{
+						AgentSelector: metav1.LabelSelector{
+							MatchLabels: map[string]string{"cluster-name": clusterName},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	driftCondition := func() *metav1.Condition {
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		return meta.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.ClusterDeploymentDrifted))
+	}
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		r = &ClusterInstanceReconciler{
+			Client:     c,
+			Scheme:     scheme.Scheme,
+			Log:        ctrl.Log.WithName("ClusterInstanceReconciler"),
+			TmplEngine: ci.NewTemplateEngine(ctrl.Log.WithName("TemplateEngine")),
+		}
+
+		Expect(c.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-templates", Namespace: "default"},
+			Data:       map[string]string{"ClusterDeployment": observedClusterDeploymentTemplate},
+		})).To(Succeed())
+
+		clusterInstance = &v1alpha1.ClusterInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: clusterName, Namespace: clusterName},
+			Spec: v1alpha1.ClusterInstanceSpec{
+				ClusterName:                  clusterName,
+				BaseDomain:                   "example.com",
+				ClusterType:                  v1alpha1.ClusterTypeSNO,
+				ObserveOnlyClusterDeployment: true,
+				TemplateRefs:                 []v1alpha1.TemplateRef{{Name: "cluster-templates", Namespace: "default"}},
+			},
+		}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+	})
+
+	It("waits for the ClusterDeployment to be applied", func() {
+		res, err := r.handleObservedClusterDeployment(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(requeueAfter(observedClusterDeploymentInterval)))
+
+		cond := driftCondition()
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionUnknown))
+		Expect(cond.Reason).To(Equal(string(conditions.NotFound)))
+	})
+
+	It("reports a ClusterDeployment matching the rendered manifest as in sync", func() {
+		// The fields only set on the live ClusterDeployment are not a drift
+		Expect(c.Create(ctx, liveClusterDeployment())).To(Succeed())
+
+		res, err := r.handleObservedClusterDeployment(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(requeueAfter(observedClusterDeploymentInterval)))
+
+		cond := driftCondition()
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Reason).To(Equal(string(conditions.InSync)))
+
+		// The live ClusterDeployment is referenced for its status to be mirrored
+		Expect(clusterInstance.Status.ClusterDeploymentRef).To(Equal(&corev1.LocalObjectReference{Name: clusterName}))
+	})
+
+	It("only renders the ClusterDeployment template", func() {
+		configMap := &corev1.ConfigMap{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: "cluster-templates", Namespace: "default"}, configMap)).To(Succeed())
+		configMap.Data["ManagedCluster"] = "apiVersion: v1\nkind: ManagedCluster\nmetadata:\n  name: {{ .Spec.Missing }}"
+		Expect(c.Update(ctx, configMap)).To(Succeed())
+		Expect(c.Create(ctx, liveClusterDeployment())).To(Succeed())
+
+		_, err := r.handleObservedClusterDeployment(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+
+		cond := driftCondition()
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Reason).To(Equal(string(conditions.InSync)))
+	})
+
+	It("reports the fields of the ClusterDeployment that drifted from the rendered manifest", func() {
+		clusterDeployment := liveClusterDeployment()
+		clusterDeployment.Labels["team"] = "core"
+		clusterDeployment.Spec.BaseDomain = "example.org"
+		Expect(c.Create(ctx, clusterDeployment)).To(Succeed())
+
+		_, err := r.handleObservedClusterDeployment(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+
+		cond := driftCondition()
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(cond.Reason).To(Equal(string(conditions.Drifted)))
+		Expect(cond.Message).To(Equal("The ClusterDeployment test-cluster/test-cluster differs from the rendered " +
+			"manifest: metadata.labels.team, spec.baseDomain"))
+
+		// The ClusterDeployment is not written
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterDeployment), clusterDeployment)).To(Succeed())
+		Expect(clusterDeployment.Spec.BaseDomain).To(Equal("example.org"))
+	})
+
+	It("removes the condition once the ClusterDeployment is no longer observe-only", func() {
+		_, err := r.handleObservedClusterDeployment(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(driftCondition()).ToNot(BeNil())
+
+		clusterInstance.Spec.ObserveOnlyClusterDeployment = false
+		res, err := r.handleObservedClusterDeployment(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(completed()))
+		Expect(driftCondition()).To(BeNil())
+	})
+
+	It("excludes the ClusterDeployment from the manifests to apply", func() {
+		clusterDeployment := map[string]interface{}{
+			"apiVersion": hivev1.SchemeGroupVersion.String(),
+			"kind":       "ClusterDeployment",
+			"metadata":   map[string]interface{}{"name": clusterName},
+		}
+		configMap := map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": clusterName},
+		}
+		manifestGroups := map[int][]interface{}{1: {configMap, clusterDeployment}, 2: {clusterDeployment}}
+		Expect(excludeClusterDeploymentManifests(manifestGroups)).To(Succeed())
+		Expect(manifestGroups).To(Equal(map[int][]interface{}{1: {configMap}, 2: {}}))
+	})
+
+	It("only compares the spec, labels and annotations of the rendered ClusterDeployment", func() {
+		rendered := &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{"name": clusterName},
+			"spec":     map[string]interface{}{"installed": false, "pullSecretRef": map[string]interface{}{"name": "ps"}},
+		}}
+		live := &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{"name": clusterName, "labels": map[string]interface{}{"a": "b"}},
+			"spec":     map[string]interface{}{"installed": true},
+			"status":   map[string]interface{}{"installedTimestamp": "now"},
+		}}
+		Expect(clusterDeploymentDrift(rendered, live)).To(Equal([]string{"spec.installed", "spec.pullSecretRef.name"}))
+	})
+})
@@ -0,0 +1,127 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+)
+
+// isProvisioningStarted returns true if the ClusterInstance Provisioned condition reports an in-progress or completed
+// installation
+func isProvisioningStarted(clusterInstance *v1alpha1.ClusterInstance) bool {
+	provisioned := meta.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.Provisioned))
+	if provisioned == nil {
+		return false
+	}
+	switch conditions.ConditionReason(provisioned.Reason) {
+	case conditions.InProgress, conditions.Completed:
+		return true
+	}
+	return false
+}
+
+// isOwnedBy returns true if the object carries the OwnedByLabel of the ClusterInstance and, when set, its
+// OwnerUIDLabel matches the ClusterInstance UID
+func isOwnedBy(clusterInstance *v1alpha1.ClusterInstance, obj *unstructured.Unstructured) bool {
+	labels := obj.GetLabels()
+	if labels[OwnedByLabel] != ownedByLabelValue(clusterInstance) {
+		return false
+	}
+	if uid, found := labels[OwnerUIDLabel]; found && uid != string(clusterInstance.UID) {
+		return false
+	}
+	return true
+}
+
+// pruneOrphanedManifests deletes the resources listed in the ManifestsRendered status of the ClusterInstance that are
+// no longer rendered from its current spec, e.g. the BareMetalHost of a node removed from Spec.Nodes, and removes them
+// from the status. Only the resources labeled as owned by the ClusterInstance are deleted, and the resources of a
+// cluster being installed or installed are never pruned.
+func (r *ClusterInstanceReconciler) pruneOrphanedManifests(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+) error {
+	if isProvisioningStarted(clusterInstance) || len(clusterInstance.Status.ManifestsRendered) == 0 {
+		return nil
+	}
+
+	manifests, err := r.TmplEngine.ProcessTemplates(ctx, r.Client, *clusterInstance)
+	if err != nil {
+		return fmt.Errorf("failed to render the templates to find the orphaned manifests: %w", err)
+	}
+	current := make([]v1alpha1.ManifestReference, 0, len(manifests))
+	for _, manifest := range manifests {
+		manifestRef, err := createManifestReference(manifest, 0)
+		if err != nil {
+			return err
+		}
+		current = append(current, *manifestRef)
+	}
+
+	patch := client.MergeFrom(clusterInstance.DeepCopy())
+	var kept []v1alpha1.ManifestReference
+	for i := range clusterInstance.Status.ManifestsRendered {
+		manifestRef := clusterInstance.Status.ManifestsRendered[i]
+		if manifestRef.APIGroup == nil || manifestRef.Status == v1alpha1.ManifestSuppressed ||
+			findManifestRendered(&manifestRef, current) != nil {
+			kept = append(kept, manifestRef)
+			continue
+		}
+
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(schema.FromAPIVersionAndKind(*manifestRef.APIGroup, manifestRef.Kind))
+		key := client.ObjectKey{Name: manifestRef.Name, Namespace: manifestRef.Namespace}
+		if err := r.Client.Get(ctx, key, obj); err != nil {
+			if !errors.IsNotFound(err) && !meta.IsNoMatchError(err) {
+				return err
+			}
+			// The resource is already gone
+			continue
+		}
+		if !isOwnedBy(clusterInstance, obj) {
+			r.Log.Info("Orphaned manifest is not owned by the ClusterInstance, leaving it in place",
+				"ClusterInstance", clusterInstance.Name, "kind", manifestRef.Kind, "name", manifestRef.Name)
+			kept = append(kept, manifestRef)
+			continue
+		}
+		if err := r.Client.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		r.Log.Info("Deleted orphaned manifest", "ClusterInstance", clusterInstance.Name,
+			"kind", manifestRef.Kind, "name", manifestRef.Name, "namespace", manifestRef.Namespace)
+		r.Recorder.Event(clusterInstance, corev1.EventTypeNormal, "OrphanedManifestDeleted",
+			fmt.Sprintf("Deleted %s %s/%s which is no longer rendered", manifestRef.Kind, manifestRef.Namespace,
+				manifestRef.Name))
+	}
+
+	if len(kept) == len(clusterInstance.Status.ManifestsRendered) {
+		return nil
+	}
+	clusterInstance.Status.ManifestsRendered = kept
+	return conditions.PatchCIStatus(ctx, r.Client, clusterInstance, patch)
+}
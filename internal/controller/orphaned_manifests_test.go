@@ -0,0 +1,175 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	bmh_v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	ci "github.com/stolostron/siteconfig/internal/controller/clusterinstance"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("pruneOrphanedManifests", func() {
+	var (
+		c               client.Client
+		r               *ClusterInstanceReconciler
+		recorder        *record.FakeRecorder
+		ctx             = context.Background()
+		clusterInstance *v1alpha1.ClusterInstance
+		nodeTemplateRef = v1alpha1.TemplateRef{Name: "node-templates", Namespace: "test-cluster"}
+		bmhAPIGroup     = "metal3.io/v1alpha1"
+	)
+
+	bmhKey := func(hostName string) client.ObjectKey {
+		return client.ObjectKey{Name: hostName, Namespace: "test-cluster"}
+	}
+
+	bmhManifest := func(hostName string) v1alpha1.ManifestReference {
+		return v1alpha1.ManifestReference{
+			APIGroup:  &bmhAPIGroup,
+			Kind:      "BareMetalHost",
+			Name:      hostName,
+			Namespace: "test-cluster",
+			Status:    v1alpha1.ManifestRenderedSuccess,
+		}
+	}
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		recorder = record.NewFakeRecorder(10)
+		testLogger := ctrl.Log.WithName("ClusterInstanceReconciler")
+		r = &ClusterInstanceReconciler{
+			Client:     c,
+			Scheme:     scheme.Scheme,
+			Log:        testLogger,
+			Recorder:   recorder,
+			TmplEngine: ci.NewTemplateEngine(testLogger),
+		}
+
+		Expect(c.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: nodeTemplateRef.Name, Namespace: nodeTemplateRef.Namespace},
+			Data: map[string]string{
+				"BareMetalHost": `apiVersion: metal3.io/v1alpha1
+kind: BareMetalHost
+metadata:
+  name: "{{ .SpecialVars.CurrentNode.HostName }}"
+  namespace: "{{ .Spec.ClusterName }}"`,
+			},
+		})).To(Succeed())
+
+		clusterInstance = &v1alpha1.ClusterInstance{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: "test-cluster",
+				UID:       "test-cluster-uid",
+			},
+			Spec: v1alpha1.ClusterInstanceSpec{
+				ClusterName: "test-cluster",
+				Nodes: []v1alpha1.NodeSpec{
+					{HostName: "node1", Role: "master", TemplateRefs: []v1alpha1.TemplateRef{nodeTemplateRef}},
+					{HostName: "node2", Role: "master", TemplateRefs: []v1alpha1.TemplateRef{nodeTemplateRef}},
+				},
+			},
+		}
+	})
+
+	It("deletes the BareMetalHost of a node removed from the ClusterInstance", func() {
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		rendered, err := r.handleRenderTemplates(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rendered).To(BeTrue())
+		Expect(c.Get(ctx, bmhKey("node1"), &bmh_v1alpha1.BareMetalHost{})).To(Succeed())
+		Expect(c.Get(ctx, bmhKey("node2"), &bmh_v1alpha1.BareMetalHost{})).To(Succeed())
+
+		clusterInstance.Spec.Nodes = clusterInstance.Spec.Nodes[:1]
+		Expect(c.Update(ctx, clusterInstance)).To(Succeed())
+		rendered, err = r.handleRenderTemplates(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rendered).To(BeTrue())
+
+		Expect(c.Get(ctx, bmhKey("node1"), &bmh_v1alpha1.BareMetalHost{})).To(Succeed())
+		err = c.Get(ctx, bmhKey("node2"), &bmh_v1alpha1.BareMetalHost{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		Expect(clusterInstance.Status.ManifestsRendered).To(ConsistOf(HaveField("Name", "node1")))
+		Expect(recorder.Events).To(Receive(ContainSubstring("OrphanedManifestDeleted")))
+	})
+
+	It("leaves the resources that are not owned by the ClusterInstance in place", func() {
+		clusterInstance.Spec.Nodes = clusterInstance.Spec.Nodes[:1]
+		clusterInstance.Status.ManifestsRendered = []v1alpha1.ManifestReference{bmhManifest("node2")}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+		Expect(c.Create(ctx, &bmh_v1alpha1.BareMetalHost{
+			ObjectMeta: metav1.ObjectMeta{Name: "node2", Namespace: "test-cluster"},
+		})).To(Succeed())
+
+		Expect(r.pruneOrphanedManifests(ctx, clusterInstance)).To(Succeed())
+		Expect(c.Get(ctx, bmhKey("node2"), &bmh_v1alpha1.BareMetalHost{})).To(Succeed())
+		Expect(clusterInstance.Status.ManifestsRendered).To(HaveLen(1))
+	})
+
+	It("leaves the resources owned by another ClusterInstance of the same name in place", func() {
+		clusterInstance.Spec.Nodes = clusterInstance.Spec.Nodes[:1]
+		clusterInstance.Status.ManifestsRendered = []v1alpha1.ManifestReference{bmhManifest("node2")}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+		Expect(c.Create(ctx, &bmh_v1alpha1.BareMetalHost{
+			ObjectMeta: metav1.ObjectMeta{Name: "node2", Namespace: "test-cluster", Labels: map[string]string{
+				OwnedByLabel:  ownedByLabelValue(clusterInstance),
+				OwnerUIDLabel: "previous-uid",
+			}},
+		})).To(Succeed())
+
+		Expect(r.pruneOrphanedManifests(ctx, clusterInstance)).To(Succeed())
+		Expect(c.Get(ctx, bmhKey("node2"), &bmh_v1alpha1.BareMetalHost{})).To(Succeed())
+	})
+
+	It("does not prune the resources of a cluster being provisioned", func() {
+		clusterInstance.Spec.Nodes = clusterInstance.Spec.Nodes[:1]
+		clusterInstance.Status.ManifestsRendered = []v1alpha1.ManifestReference{bmhManifest("node2")}
+		conditions.SetStatusCondition(&clusterInstance.Status.Conditions,
+			conditions.Provisioned,
+			conditions.InProgress,
+			metav1.ConditionFalse,
+			"Provisioning cluster")
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+		Expect(c.Create(ctx, &bmh_v1alpha1.BareMetalHost{
+			ObjectMeta: metav1.ObjectMeta{Name: "node2", Namespace: "test-cluster", Labels: map[string]string{
+				OwnedByLabel: ownedByLabelValue(clusterInstance),
+			}},
+		})).To(Succeed())
+
+		Expect(r.pruneOrphanedManifests(ctx, clusterInstance)).To(Succeed())
+		Expect(c.Get(ctx, bmhKey("node2"), &bmh_v1alpha1.BareMetalHost{})).To(Succeed())
+	})
+})
@@ -0,0 +1,206 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// OwnedByLabel is set on every resource created for a ClusterInstance, so that all the resources owned by a
+// ClusterInstance can be listed with a single label selector, including the cluster-scoped resources and the
+// resources created outside of the ClusterInstance namespace, which cannot carry an owner reference
+const OwnedByLabel = v1alpha1.Group + "/owned-by"
+
+// OwnerUIDLabel and OwnerAnnotation identify the ClusterInstance owning a resource that cannot carry an owner
+// reference to it, i.e. a cluster-scoped resource or a resource created outside of the ClusterInstance namespace. The
+// UID is recorded as a label so that it can be selected on, and the <namespace>/<name> of the ClusterInstance as an
+// annotation since it may exceed the maximum length of a label value
+const (
+	OwnerUIDLabel   = v1alpha1.Group + "/owner-uid"
+	OwnerAnnotation = v1alpha1.Group + "/owner"
+)
+
+// ownedByLabelValue returns the value of the OwnedByLabel identifying the ClusterInstance, i.e. <namespace>.<name>,
+// or a hash of it when it is not a valid label value (e.g. it is too long)
+func ownedByLabelValue(clusterInstance *v1alpha1.ClusterInstance) string {
+	value := clusterInstance.Namespace + "." + clusterInstance.Name
+	if len(validation.IsValidLabelValue(value)) == 0 {
+		return value
+	}
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])[:validation.LabelValueMaxLength]
+}
+
+// setOwnedByLabel sets the OwnedByLabel of the object to the ClusterInstance, preserving its other labels
+func setOwnedByLabel(clusterInstance *v1alpha1.ClusterInstance, obj metav1.Object) {
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[OwnedByLabel] = ownedByLabelValue(clusterInstance)
+	obj.SetLabels(labels)
+}
+
+// setLabelOwnership records the ClusterInstance as the owner of the object in its labels and annotations, in place of
+// an owner reference
+func setLabelOwnership(clusterInstance *v1alpha1.ClusterInstance, obj metav1.Object) {
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[OwnerUIDLabel] = string(clusterInstance.UID)
+	obj.SetLabels(labels)
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[OwnerAnnotation] = types.NamespacedName{
+		Namespace: clusterInstance.Namespace,
+		Name:      clusterInstance.Name,
+	}.String()
+	obj.SetAnnotations(annotations)
+}
+
+// labelOwner returns the namespaced name and UID of the ClusterInstance recorded as the owner of the object by
+// setLabelOwnership, false if there is none
+func labelOwner(obj metav1.Object) (types.NamespacedName, types.UID, bool) {
+	owner, found := obj.GetAnnotations()[OwnerAnnotation]
+	if !found {
+		return types.NamespacedName{}, "", false
+	}
+	namespace, name, found := strings.Cut(owner, string(types.Separator))
+	if !found || namespace == "" || name == "" {
+		return types.NamespacedName{}, "", false
+	}
+	return types.NamespacedName{Namespace: namespace, Name: name}, types.UID(obj.GetLabels()[OwnerUIDLabel]), true
+}
+
+// ownerClusterInstance returns the namespaced name and UID of the ClusterInstance owning the object, as recorded by
+// its owner reference or, failing that, by its ownership labels, false if it is not owned by a ClusterInstance
+func ownerClusterInstance(obj metav1.Object) (types.NamespacedName, types.UID, bool) {
+	for _, ownerRef := range obj.GetOwnerReferences() {
+		if ownerRef.Kind == v1alpha1.ClusterInstanceKind {
+			return types.NamespacedName{Namespace: obj.GetNamespace(), Name: ownerRef.Name}, ownerRef.UID, true
+		}
+	}
+	return labelOwner(obj)
+}
+
+// isOwnedByClusterInstance returns true if the object is owned by a ClusterInstance, by reference or by label
+func isOwnedByClusterInstance(obj metav1.Object) bool {
+	_, _, owned := ownerClusterInstance(obj)
+	return owned
+}
+
+// ownedBySelector returns the label selector matching the resources owned by the ClusterInstance
+func ownedBySelector(clusterInstance *v1alpha1.ClusterInstance) client.MatchingLabels {
+	return client.MatchingLabels{OwnedByLabel: ownedByLabelValue(clusterInstance)}
+}
+
+// listOwnedObjects lists the resources of the given kind owned by the ClusterInstance, in all namespaces
+func listOwnedObjects(
+	ctx context.Context,
+	c client.Reader,
+	clusterInstance *v1alpha1.ClusterInstance,
+	gvk schema.GroupVersionKind,
+) ([]unstructured.Unstructured, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	if err := c.List(ctx, list, ownedBySelector(clusterInstance)); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// deleteOwnedObjects deletes the resources owned by the ClusterInstance that are of a kind it rendered, including
+// those that are no longer referenced by its status
+func deleteOwnedObjects(ctx context.Context, c client.Client, clusterInstance *v1alpha1.ClusterInstance) error {
+	gvks := map[schema.GroupVersionKind]bool{}
+	for _, manifest := range clusterInstance.Status.ManifestsRendered {
+		if manifest.APIGroup == nil {
+			continue
+		}
+		gvks[schema.FromAPIVersionAndKind(*manifest.APIGroup, manifest.Kind)] = true
+	}
+
+	for gvk := range gvks {
+		objects, err := listOwnedObjects(ctx, c, clusterInstance, gvk)
+		if err != nil {
+			if meta.IsNoMatchError(err) {
+				// The kind is no longer served, there is nothing left to delete
+				continue
+			}
+			return err
+		}
+		for i := range objects {
+			if uid, found := objects[i].GetLabels()[OwnerUIDLabel]; found && uid != string(clusterInstance.UID) {
+				// The resource is labeled as owned by another ClusterInstance of the same name
+				continue
+			}
+			if err := c.Delete(ctx, &objects[i]); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// orphanObject removes the ClusterInstance owner reference, OwnedByLabel and label ownership from the object, so that it is neither
+// garbage collected nor deleted along with the ClusterInstance
+func orphanObject(
+	ctx context.Context,
+	c client.Client,
+	clusterInstance *v1alpha1.ClusterInstance,
+	obj *unstructured.Unstructured,
+) error {
+	if err := c.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	ownerRefs := []metav1.OwnerReference{}
+	for _, ownerRef := range obj.GetOwnerReferences() {
+		if ownerRef.Kind != v1alpha1.ClusterInstanceKind || ownerRef.Name != clusterInstance.Name {
+			ownerRefs = append(ownerRefs, ownerRef)
+		}
+	}
+	obj.SetOwnerReferences(ownerRefs)
+
+	labels := obj.GetLabels()
+	delete(labels, OwnedByLabel)
+	delete(labels, OwnerUIDLabel)
+	obj.SetLabels(labels)
+
+	annotations := obj.GetAnnotations()
+	delete(annotations, OwnerAnnotation)
+	obj.SetAnnotations(annotations)
+
+	return c.Update(ctx, obj)
+}
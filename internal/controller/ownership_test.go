@@ -0,0 +1,345 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+
+	bmh_v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("OwnedByLabel", func() {
+	var (
+		c               client.Client
+		r               *ClusterInstanceReconciler
+		ctx             = context.Background()
+		clusterInstance *v1alpha1.ClusterInstance
+	)
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		r = &ClusterInstanceReconciler{
+			Client: c,
+			Scheme: scheme.Scheme,
+			Log:    ctrl.Log.WithName("ClusterInstanceReconciler"),
+		}
+
+		clusterInstance = &v1alpha1.ClusterInstance{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "test-cluster",
+				Namespace:  "test-cluster",
+				Finalizers: []string{clusterInstanceFinalizer},
+			},
+			Spec: v1alpha1.ClusterInstanceSpec{ClusterName: "test-cluster"},
+		}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+	})
+
+	It("identifies the ClusterInstance by namespace and name", func() {
+		Expect(ownedByLabelValue(clusterInstance)).To(Equal("test-cluster.test-cluster"))
+
+		clusterInstance.Name = strings.Repeat("a", 63)
+		value := ownedByLabelValue(clusterInstance)
+		Expect(validation.IsValidLabelValue(value)).To(BeEmpty())
+		Expect(value).ToNot(Equal(ownedByLabelValue(&v1alpha1.ClusterInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: strings.Repeat("b", 63), Namespace: "test-cluster"},
+		})))
+	})
+
+	It("preserves the existing labels", func() {
+		configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"foo": "bar"}}}
+		setOwnedByLabel(clusterInstance, configMap)
+		Expect(configMap.GetLabels()).To(Equal(map[string]string{
+			"foo":        "bar",
+			OwnedByLabel: "test-cluster.test-cluster",
+		}))
+	})
+
+	It("sets the owner label on the applied manifests so that they can be listed in one query", func() {
+		manifestGroups := map[int][]interface{}{
+			0: {
+				map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "ConfigMap",
+					"metadata": map[string]interface{}{
+						"name":      "extra-manifests",
+						"namespace": "test-cluster",
+						"labels":    map[string]interface{}{"foo": "bar"},
+					},
+				},
+				// Resources outside of the ClusterInstance namespace carry no owner reference, but are labeled
+				map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "ConfigMap",
+					"metadata": map[string]interface{}{
+						"name":      "extra-manifests",
+						"namespace": "other-namespace",
+					},
+				},
+			},
+		}
+		// A ConfigMap that is not owned by the ClusterInstance
+		Expect(c.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "test-cluster"},
+		})).To(Succeed())
+
+		ok, err := r.executeRenderedManifests(ctx, c, clusterInstance, manifestGroups,
+			v1alpha1.ManifestRenderedSuccess)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ok).To(BeTrue())
+
+		owned, err := listOwnedObjects(ctx, c, clusterInstance, corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(owned).To(HaveLen(2))
+		for _, obj := range owned {
+			Expect(obj.GetName()).To(Equal("extra-manifests"))
+			Expect(obj.GetLabels()).To(HaveKeyWithValue(OwnedByLabel, "test-cluster.test-cluster"))
+		}
+		Expect(owned[0].GetNamespace()).ToNot(Equal(owned[1].GetNamespace()))
+	})
+
+	It("deletes the owned resources that are no longer referenced by the status on finalization", func() {
+		apiGroup := hivev1.SchemeGroupVersion.String()
+		clusterInstance.Status.ManifestsRendered = []v1alpha1.ManifestReference{{
+			APIGroup:  &apiGroup,
+			Kind:      "ClusterDeployment",
+			Name:      "test-cluster",
+			Namespace: "test-cluster",
+			Status:    v1alpha1.ManifestRenderedSuccess,
+		}}
+
+		referenced := &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-cluster"},
+		}
+		unreferenced := &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "renamed", Namespace: "test-cluster"},
+		}
+		setOwnedByLabel(clusterInstance, unreferenced)
+		notOwned := &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "not-owned", Namespace: "test-cluster"},
+		}
+		for _, obj := range []client.Object{referenced, unreferenced, notOwned} {
+			Expect(c.Create(ctx, obj)).To(Succeed())
+		}
+
+		Expect(r.finalizeClusterInstance(ctx, clusterInstance)).To(Succeed())
+
+		Expect(apierrors.IsNotFound(c.Get(ctx, client.ObjectKeyFromObject(referenced), referenced))).To(BeTrue())
+		Expect(apierrors.IsNotFound(c.Get(ctx, client.ObjectKeyFromObject(unreferenced), unreferenced))).To(BeTrue())
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(notOwned), notOwned)).To(Succeed())
+	})
+
+	DescribeTable("deletes or orphans the rendered manifests on finalization depending on preserveOnDelete",
+		func(preserveOnDelete bool) {
+			clusterInstance.Spec.PreserveOnDelete = preserveOnDelete
+			cdAPIGroup := hivev1.SchemeGroupVersion.String()
+			bmhAPIGroup := bmh_v1alpha1.GroupVersion.String()
+			clusterInstance.Status.ManifestsRendered = []v1alpha1.ManifestReference{
+				{
+					APIGroup:  &cdAPIGroup,
+					Kind:      "ClusterDeployment",
+					Name:      "test-cluster",
+					Namespace: "test-cluster",
+					Status:    v1alpha1.ManifestRenderedSuccess,
+				},
+				{
+					APIGroup:  &bmhAPIGroup,
+					Kind:      "BareMetalHost",
+					Name:      "node1",
+					Namespace: "test-cluster",
+					Status:    v1alpha1.ManifestRenderedSuccess,
+				},
+			}
+			ownerRefs := []metav1.OwnerReference{{
+				APIVersion: ClusterInstanceApiVersion,
+				Kind:       v1alpha1.ClusterInstanceKind,
+				Name:       clusterInstance.Name,
+			}}
+
+			clusterDeployment := &hivev1.ClusterDeployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "test-cluster",
+					Namespace:       "test-cluster",
+					OwnerReferences: ownerRefs,
+				},
+				Spec: hivev1.ClusterDeploymentSpec{PreserveOnDelete: preserveOnDelete},
+			}
+			bmh := &bmh_v1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{Name: "node1", Namespace: "test-cluster", OwnerReferences: ownerRefs},
+			}
+			// An owned resource that is no longer referenced by the status
+			unreferenced := &bmh_v1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{Name: "node2", Namespace: "test-cluster"},
+			}
+			for _, obj := range []client.Object{clusterDeployment, bmh, unreferenced} {
+				setOwnedByLabel(clusterInstance, obj)
+				Expect(c.Create(ctx, obj)).To(Succeed())
+			}
+
+			Expect(r.finalizeClusterInstance(ctx, clusterInstance)).To(Succeed())
+
+			cdErr := c.Get(ctx, client.ObjectKeyFromObject(clusterDeployment), clusterDeployment)
+			bmhErr := c.Get(ctx, client.ObjectKeyFromObject(bmh), bmh)
+			unreferencedErr := c.Get(ctx, client.ObjectKeyFromObject(unreferenced), unreferenced)
+			if !preserveOnDelete {
+				Expect(apierrors.IsNotFound(cdErr)).To(BeTrue())
+				Expect(apierrors.IsNotFound(bmhErr)).To(BeTrue())
+				Expect(apierrors.IsNotFound(unreferencedErr)).To(BeTrue())
+				return
+			}
+			Expect(cdErr).ToNot(HaveOccurred())
+			Expect(clusterDeployment.OwnerReferences).To(BeEmpty())
+			Expect(clusterDeployment.Labels).ToNot(HaveKey(OwnedByLabel))
+			Expect(clusterDeployment.Spec.PreserveOnDelete).To(BeTrue())
+			// The BareMetalHosts survive, so that the running nodes are not deprovisioned
+			Expect(bmhErr).ToNot(HaveOccurred())
+			Expect(bmh.OwnerReferences).To(BeEmpty())
+			Expect(bmh.Labels).ToNot(HaveKey(OwnedByLabel))
+			Expect(unreferencedErr).ToNot(HaveOccurred())
+		},
+		Entry("deprovisioned by default", false),
+		Entry("preserved", true),
+	)
+
+	Context("label ownership", func() {
+		BeforeEach(func() {
+			clusterInstance.UID = "test-cluster-uid"
+		})
+
+		It("records the owner of the resources created outside of the ClusterInstance namespace", func() {
+			manifestGroups := map[int][]interface{}{
+				0: {
+					map[string]interface{}{
+						"apiVersion": "v1",
+						"kind":       "ConfigMap",
+						"metadata": map[string]interface{}{
+							"name":      "extra-manifests",
+							"namespace": "other-namespace",
+						},
+					},
+				},
+			}
+			ok, err := r.executeRenderedManifests(ctx, c, clusterInstance, manifestGroups,
+				v1alpha1.ManifestRenderedSuccess)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+
+			configMap := &corev1.ConfigMap{}
+			Expect(c.Get(ctx, types.NamespacedName{Name: "extra-manifests", Namespace: "other-namespace"},
+				configMap)).To(Succeed())
+			Expect(configMap.OwnerReferences).To(BeEmpty())
+			Expect(configMap.Labels).To(HaveKeyWithValue(OwnerUIDLabel, "test-cluster-uid"))
+			Expect(configMap.Annotations).To(HaveKeyWithValue(OwnerAnnotation, "test-cluster/test-cluster"))
+
+			key, uid, owned := ownerClusterInstance(configMap)
+			Expect(owned).To(BeTrue())
+			Expect(key).To(Equal(types.NamespacedName{Name: "test-cluster", Namespace: "test-cluster"}))
+			Expect(uid).To(Equal(types.UID("test-cluster-uid")))
+		})
+
+		It("resolves the ClusterInstance owning a resource by label", func() {
+			clusterDeployment := &hivev1.ClusterDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "other-namespace"},
+			}
+			Expect(isOwnedByClusterInstance(clusterDeployment)).To(BeFalse())
+
+			setLabelOwnership(clusterInstance, clusterDeployment)
+			Expect(isOwnedByClusterInstance(clusterDeployment)).To(BeTrue())
+
+			owner, err := getOwnerClusterInstance(ctx, c, r.Log, clusterDeployment)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(owner).ToNot(BeNil())
+			Expect(owner.Name).To(Equal("test-cluster"))
+			Expect(owner.Namespace).To(Equal("test-cluster"))
+
+			clusterInstance.UID = "recreated-uid"
+			Expect(isOwnerUIDMatching(clusterDeployment, clusterInstance)).To(BeFalse())
+		})
+
+		It("deletes the resources owned by label on finalization", func() {
+			apiGroup := hivev1.SchemeGroupVersion.String()
+			clusterInstance.Status.ManifestsRendered = []v1alpha1.ManifestReference{{
+				APIGroup:  &apiGroup,
+				Kind:      "ClusterDeployment",
+				Name:      "renamed",
+				Namespace: "other-namespace",
+				Status:    v1alpha1.ManifestRenderedSuccess,
+			}}
+
+			owned := &hivev1.ClusterDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "other-namespace"},
+			}
+			setOwnedByLabel(clusterInstance, owned)
+			setLabelOwnership(clusterInstance, owned)
+
+			// Labeled as owned by a former ClusterInstance of the same name
+			stale := &hivev1.ClusterDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "stale", Namespace: "other-namespace"},
+			}
+			setOwnedByLabel(clusterInstance, stale)
+			setLabelOwnership(&v1alpha1.ClusterInstance{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-cluster", UID: "former-uid"},
+			}, stale)
+
+			for _, obj := range []client.Object{owned, stale} {
+				Expect(c.Create(ctx, obj)).To(Succeed())
+			}
+
+			Expect(r.finalizeClusterInstance(ctx, clusterInstance)).To(Succeed())
+
+			Expect(apierrors.IsNotFound(c.Get(ctx, client.ObjectKeyFromObject(owned), owned))).To(BeTrue())
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(stale), stale)).To(Succeed())
+		})
+
+		It("removes the label ownership when orphaning a resource", func() {
+			clusterDeployment := &hivev1.ClusterDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "other-namespace"},
+			}
+			setOwnedByLabel(clusterInstance, clusterDeployment)
+			setLabelOwnership(clusterInstance, clusterDeployment)
+			Expect(c.Create(ctx, clusterDeployment)).To(Succeed())
+
+			obj := &unstructured.Unstructured{}
+			obj.SetGroupVersionKind(hivev1.SchemeGroupVersion.WithKind("ClusterDeployment"))
+			obj.SetName(clusterDeployment.Name)
+			obj.SetNamespace(clusterDeployment.Namespace)
+			Expect(orphanObject(ctx, c, clusterInstance, obj)).To(Succeed())
+
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterDeployment), clusterDeployment)).To(Succeed())
+			Expect(isOwnedByClusterInstance(clusterDeployment)).To(BeFalse())
+			Expect(clusterDeployment.Labels).ToNot(HaveKey(OwnerUIDLabel))
+		})
+	})
+})
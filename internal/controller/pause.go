@@ -0,0 +1,112 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// PausedKey is the key of the pause ConfigMap that pauses all reconciliation when set to "true"
+const PausedKey = "paused"
+
+// pausedRequeueInterval is the interval at which a paused reconcile is retried, in case the update of the pause
+// ConfigMap is missed
+const pausedRequeueInterval = time.Minute
+
+// PauseSwitch identifies the ConfigMap consulted by the reconcilers before reconciling, so that all reconciliation
+// can be paused, e.g. during hub upgrades. A nil PauseSwitch never pauses.
+type PauseSwitch struct {
+	Name      string
+	Namespace string
+}
+
+// IsPaused returns true if the pause ConfigMap exists and its PausedKey is set to "true"
+func (p *PauseSwitch) IsPaused(ctx context.Context, c client.Reader) (bool, error) {
+	if p == nil || p.Name == "" {
+		return false, nil
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := c.Get(ctx, types.NamespacedName{Name: p.Name, Namespace: p.Namespace}, configMap); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return configMap.Data[PausedKey] == "true", nil
+}
+
+// isPauseConfigMap returns true if the object is the pause ConfigMap
+func (p *PauseSwitch) isPauseConfigMap(obj client.Object) bool {
+	return p != nil && p.Name != "" && obj.GetName() == p.Name && obj.GetNamespace() == p.Namespace
+}
+
+// predicate filters the events of the pause ConfigMap
+func (p *PauseSwitch) predicate() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return p.isPauseConfigMap(e.Object) },
+		UpdateFunc:  func(e event.UpdateEvent) bool { return p.isPauseConfigMap(e.ObjectNew) },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return p.isPauseConfigMap(e.Object) },
+		GenericFunc: func(e event.GenericEvent) bool { return false },
+	}
+}
+
+// mapPauseToClusterInstances enqueues all the ClusterInstances, so that a change of the pause ConfigMap takes effect
+// promptly
+func mapPauseToClusterInstances(ctx context.Context, c client.Client) []reconcile.Request {
+	clusterInstances := &v1alpha1.ClusterInstanceList{}
+	if err := c.List(ctx, clusterInstances); err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := make([]reconcile.Request, 0, len(clusterInstances.Items))
+	for _, clusterInstance := range clusterInstances.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: clusterInstance.Name, Namespace: clusterInstance.Namespace},
+		})
+	}
+	return requests
+}
+
+// mapPauseToClusterDeployments enqueues the ClusterDeployments of all the ClusterInstances, so that a change of the
+// pause ConfigMap takes effect promptly
+func mapPauseToClusterDeployments(ctx context.Context, c client.Client) []reconcile.Request {
+	clusterInstances := &v1alpha1.ClusterInstanceList{}
+	if err := c.List(ctx, clusterInstances); err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := []reconcile.Request{}
+	for _, clusterInstance := range clusterInstances.Items {
+		if ref := clusterInstance.Status.ClusterDeploymentRef; ref != nil && ref.Name != "" {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: ref.Name, Namespace: clusterInstance.Namespace},
+			})
+		}
+	}
+	return requests
+}
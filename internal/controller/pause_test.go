@@ -0,0 +1,173 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	ci "github.com/stolostron/siteconfig/internal/controller/clusterinstance"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("PauseSwitch", func() {
+	var (
+		c           client.Client
+		ctx         = context.Background()
+		pauseSwitch = &PauseSwitch{Name: "siteconfig-pause", Namespace: "siteconfig-system"}
+		pauseCM     *corev1.ConfigMap
+		testParams  = &ci.TestParams{
+			BmcCredentialsName:  "bmh-secret",
+			ClusterName:         "test-cluster",
+			ClusterNamespace:    "test-cluster",
+			ClusterImageSetName: "testimage:foobar",
+			PullSecret:          "pull-secret",
+		}
+	)
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		pauseCM = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: pauseSwitch.Name, Namespace: pauseSwitch.Namespace},
+			Data:       map[string]string{PausedKey: "true"},
+		}
+	})
+
+	DescribeTable("reports whether reconciliation is paused",
+		func(data map[string]string, create bool, expected bool) {
+			if create {
+				pauseCM.Data = data
+				Expect(c.Create(ctx, pauseCM)).To(Succeed())
+			}
+			paused, err := pauseSwitch.IsPaused(ctx, c)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(paused).To(Equal(expected))
+		},
+		Entry("paused", map[string]string{PausedKey: "true"}, true, true),
+		Entry("not paused", map[string]string{PausedKey: "false"}, true, false),
+		Entry("key not set", map[string]string{}, true, false),
+		Entry("ConfigMap not found", nil, false, false),
+	)
+
+	It("never pauses when disabled", func() {
+		Expect(c.Create(ctx, pauseCM)).To(Succeed())
+
+		var disabled *PauseSwitch
+		paused, err := disabled.IsPaused(ctx, c)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(paused).To(BeFalse())
+	})
+
+	It("only matches the pause ConfigMap", func() {
+		Expect(pauseSwitch.isPauseConfigMap(pauseCM)).To(BeTrue())
+		Expect(pauseSwitch.isPauseConfigMap(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: pauseSwitch.Name, Namespace: "other"},
+		})).To(BeFalse())
+	})
+
+	It("short-circuits the ClusterInstance reconcile while paused", func() {
+		r := &ClusterInstanceReconciler{
+			Client:      c,
+			Scheme:      scheme.Scheme,
+			Log:         ctrl.Log.WithName("ClusterInstanceReconciler"),
+			TmplEngine:  ci.NewTemplateEngine(ctrl.Log.WithName("TemplateEngine")),
+			PauseSwitch: pauseSwitch,
+		}
+		clusterInstance := testParams.GenerateSNOClusterInstance()
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+		Expect(c.Create(ctx, pauseCM)).To(Succeed())
+
+		key := client.ObjectKeyFromObject(clusterInstance)
+		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(requeueAfter(pausedRequeueInterval)))
+
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		Expect(clusterInstance.Finalizers).To(BeEmpty())
+
+		// Resume reconciliation
+		pauseCM.Data[PausedKey] = "false"
+		Expect(c.Update(ctx, pauseCM)).To(Succeed())
+
+		_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Get(ctx, key, clusterInstance)).To(Succeed())
+		Expect(clusterInstance.Finalizers).To(ContainElement(clusterInstanceFinalizer))
+	})
+
+	It("short-circuits the ClusterDeployment reconcile while paused", func() {
+		r := &ClusterDeploymentReconciler{
+			Client:      c,
+			Scheme:      scheme.Scheme,
+			Log:         ctrl.Log.WithName("ClusterDeploymentReconciler"),
+			PauseSwitch: pauseSwitch,
+		}
+		clusterInstance := testParams.GenerateSNOClusterInstance()
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+		clusterDeployment := &hivev1.ClusterDeployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterInstance.Name,
+				Namespace: clusterInstance.Namespace,
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: ClusterInstanceApiVersion,
+					Kind:       v1alpha1.ClusterInstanceKind,
+					Name:       clusterInstance.Name,
+				}},
+			},
+		}
+		Expect(c.Create(ctx, clusterDeployment)).To(Succeed())
+		Expect(c.Create(ctx, pauseCM)).To(Succeed())
+
+		key := client.ObjectKeyFromObject(clusterDeployment)
+		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(requeueAfter(pausedRequeueInterval)))
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		Expect(clusterInstance.Status.ClusterDeploymentRef).To(BeNil())
+
+		// Resume reconciliation
+		Expect(c.Delete(ctx, pauseCM)).To(Succeed())
+
+		_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		Expect(clusterInstance.Status.ClusterDeploymentRef).To(Equal(
+			&corev1.LocalObjectReference{Name: clusterDeployment.Name}))
+
+		// The ClusterDeployments are enqueued when the pause ConfigMap changes
+		Expect(mapPauseToClusterDeployments(ctx, c)).To(ConsistOf(reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: clusterDeployment.Name, Namespace: clusterDeployment.Namespace},
+		}))
+		Expect(mapPauseToClusterInstances(ctx, c)).To(ConsistOf(reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(clusterInstance),
+		}))
+	})
+})
@@ -0,0 +1,101 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ReasonAnnotations maps reasons of the ClusterDeployment conditions to ClusterInstance annotations. The message of the
+// condition reporting a mapped reason is exposed in the annotation, e.g. to extract an URL or an ID it carries without
+// parsing the DeploymentConditions.
+type ReasonAnnotations map[string]string
+
+// ParseReasonAnnotations parses the given <reason>=<annotation> mappings
+func ParseReasonAnnotations(mappings []string) (ReasonAnnotations, error) {
+	reasonAnnotations := make(ReasonAnnotations, len(mappings))
+	for _, mapping := range mappings {
+		reason, annotation, found := strings.Cut(mapping, "=")
+		if !found || reason == "" || annotation == "" {
+			return nil, fmt.Errorf("invalid reason annotation %q, expected <reason>=<annotation>", mapping)
+		}
+		if errs := validation.IsQualifiedName(annotation); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid annotation %q of reason %s: %s", annotation, reason,
+				strings.Join(errs, "; "))
+		}
+		reasonAnnotations[reason] = annotation
+	}
+	return reasonAnnotations, nil
+}
+
+// apply sets the annotations of the reasons reported by the conditions of the ClusterDeployment to the condition
+// messages, and removes those of the reasons no longer reported. It returns true if the annotations changed.
+func (m ReasonAnnotations) apply(cd *hivev1.ClusterDeployment, ci *v1alpha1.ClusterInstance) bool {
+	if len(m) == 0 {
+		return false
+	}
+
+	messages := map[string]string{}
+	for _, condition := range cd.Status.Conditions {
+		if _, ok := messages[condition.Reason]; !ok {
+			messages[condition.Reason] = condition.Message
+		}
+	}
+
+	annotations := ci.GetAnnotations()
+	changed := false
+	for reason, annotation := range m {
+		current, set := annotations[annotation]
+		message, reported := messages[reason]
+		switch {
+		case reported && (!set || current != message):
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			annotations[annotation] = message
+			changed = true
+		case !reported && set:
+			delete(annotations, annotation)
+			changed = true
+		}
+	}
+	if changed {
+		ci.SetAnnotations(annotations)
+	}
+	return changed
+}
+
+// updateReasonAnnotations updates the ClusterInstance annotations mapped to the reasons of the ClusterDeployment
+// conditions
+func (r *ClusterDeploymentReconciler) updateReasonAnnotations(
+	ctx context.Context,
+	cd *hivev1.ClusterDeployment,
+	ci *v1alpha1.ClusterInstance,
+) error {
+	patch := client.MergeFrom(ci.DeepCopy())
+	if !r.ReasonAnnotations.apply(cd, ci) {
+		return nil
+	}
+	return r.Patch(ctx, ci, patch)
+}
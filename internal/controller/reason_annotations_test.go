@@ -0,0 +1,123 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	ci "github.com/stolostron/siteconfig/internal/controller/clusterinstance"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("ReasonAnnotations", func() {
+	const installLogsAnnotation = "example.com/install-logs-url"
+
+	var (
+		c               client.Client
+		r               *ClusterDeploymentReconciler
+		ctx             = context.Background()
+		clusterInstance *v1alpha1.ClusterInstance
+		testParams      = &ci.TestParams{
+			BmcCredentialsName:  "bmh-secret",
+			ClusterName:         "test-cluster",
+			ClusterNamespace:    "test-cluster",
+			ClusterImageSetName: "testimage:foobar",
+			PullSecret:          "pull-secret",
+		}
+	)
+
+	clusterDeploymentWith := func(conditions ...hivev1.ClusterDeploymentCondition) *hivev1.ClusterDeployment {
+		return &hivev1.ClusterDeployment{Status: hivev1.ClusterDeploymentStatus{Conditions: conditions}}
+	}
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+		r = &ClusterDeploymentReconciler{
+			Client:            c,
+			Scheme:            scheme.Scheme,
+			Log:               ctrl.Log.WithName("ClusterDeploymentReconciler"),
+			ReasonAnnotations: ReasonAnnotations{"InstallLogsReady": installLogsAnnotation},
+		}
+		clusterInstance = testParams.GenerateSNOClusterInstance()
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+	})
+
+	It("exposes the message of the condition reporting a mapped reason in the annotation", func() {
+		cd := clusterDeploymentWith(
+			hivev1.ClusterDeploymentCondition{
+				Type:    hivev1.ProvisionFailedCondition,
+				Status:  corev1.ConditionFalse,
+				Reason:  "NoFailures",
+				Message: "No failures",
+			},
+			hivev1.ClusterDeploymentCondition{
+				Type:    hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+				Status:  corev1.ConditionTrue,
+				Reason:  "InstallLogsReady",
+				Message: "https://logs.example.com/test-cluster",
+			})
+		Expect(r.updateReasonAnnotations(ctx, cd, clusterInstance)).To(Succeed())
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		Expect(clusterInstance.GetAnnotations()).To(
+			HaveKeyWithValue(installLogsAnnotation, "https://logs.example.com/test-cluster"))
+	})
+
+	It("removes the annotation once the reason is no longer reported", func() {
+		cd := clusterDeploymentWith(hivev1.ClusterDeploymentCondition{
+			Type:    hivev1.ClusterInstallCompletedClusterDeploymentCondition,
+			Status:  corev1.ConditionTrue,
+			Reason:  "InstallLogsReady",
+			Message: "https://logs.example.com/test-cluster",
+		})
+		Expect(r.updateReasonAnnotations(ctx, cd, clusterInstance)).To(Succeed())
+
+		cd.Status.Conditions[0].Reason = "InstallationCompleted"
+		Expect(r.updateReasonAnnotations(ctx, cd, clusterInstance)).To(Succeed())
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		Expect(clusterInstance.GetAnnotations()).ToNot(HaveKey(installLogsAnnotation))
+	})
+
+	It("does not change the ClusterInstance when the annotations are up to date", func() {
+		cd := clusterDeploymentWith()
+		Expect(r.ReasonAnnotations.apply(cd, clusterInstance)).To(BeFalse())
+
+		r.ReasonAnnotations = nil
+		cd.Status.Conditions = []hivev1.ClusterDeploymentCondition{{Reason: "InstallLogsReady", Message: "url"}}
+		Expect(r.ReasonAnnotations.apply(cd, clusterInstance)).To(BeFalse())
+	})
+
+	It("parses the <reason>=<annotation> mappings", func() {
+		reasonAnnotations, err := ParseReasonAnnotations([]string{"InstallLogsReady=" + installLogsAnnotation})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reasonAnnotations).To(Equal(ReasonAnnotations{"InstallLogsReady": installLogsAnnotation}))
+
+		_, err = ParseReasonAnnotations([]string{"InstallLogsReady"})
+		Expect(err).To(MatchError(`invalid reason annotation "InstallLogsReady", expected <reason>=<annotation>`))
+		_, err = ParseReasonAnnotations([]string{"InstallLogsReady=not a key"})
+		Expect(err).To(MatchError(ContainSubstring(`invalid annotation "not a key" of reason InstallLogsReady`)))
+	})
+})
@@ -0,0 +1,101 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+
+	ci "github.com/stolostron/siteconfig/internal/controller/clusterinstance"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// The reconcilers classify their errors to decide whether a failed reconcile is retried:
+//   - a TransientError, e.g. a failed API call, may succeed when retried and is requeued with the rate limiter;
+//   - a ValidationError (see clusterinstance.ValidationError) reports an invalid spec, it is surfaced in the
+//     ClusterInstanceValidated condition and is not requeued since the spec must be fixed first;
+//   - a TerminalError cannot be recovered by retrying, it is surfaced in a condition and is not requeued.
+//
+// An unclassified error is handled as a TransientError, a TransientError wrapping a ValidationError is requeued.
+
+// TransientError is a failure that may succeed when retried
+type TransientError struct {
+	Err error
+}
+
+func (e *TransientError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransientError) Unwrap() error {
+	return e.Err
+}
+
+// TerminalError is a failure that retrying the reconcile does not recover from, e.g. until the spec is changed
+type TerminalError struct {
+	Err error
+}
+
+func (e *TerminalError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TerminalError) Unwrap() error {
+	return e.Err
+}
+
+// transientAPIError wraps an error returned by the API server, e.g. a conflict or a timeout, in a TransientError, so
+// that it is retried even when it is reported as a validation error
+func transientAPIError(err error) error {
+	var statusErr apierrors.APIStatus
+	if err != nil && errors.As(err, &statusErr) {
+		return &TransientError{Err: err}
+	}
+	return err
+}
+
+// renderError classifies a failure to render the templates: a template that cannot be rendered (see
+// clusterinstance.RenderError) is a TerminalError until the template or the spec is changed, whereas any other failure,
+// e.g. to read a template, is a TransientError
+func renderError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var statusErr apierrors.APIStatus
+	var renderErr *ci.RenderError
+	if !errors.As(err, &statusErr) && errors.As(err, &renderErr) {
+		return &TerminalError{Err: err}
+	}
+	return &TransientError{Err: err}
+}
+
+// isTerminal returns true if the error is not recovered by retrying the reconcile, i.e. it is a TerminalError or a
+// ValidationError not wrapped by a TransientError
+func isTerminal(err error) bool {
+	var transientErr *TransientError
+	if errors.As(err, &transientErr) {
+		return false
+	}
+	var terminalErr *TerminalError
+	var validationErr *ci.ValidationError
+	return errors.As(err, &terminalErr) || errors.As(err, &validationErr)
+}
+
+// isNotRequeued returns true if the reconcile failed with an error that controller-runtime does not requeue
+func isNotRequeued(err error) bool {
+	return errors.Is(err, reconcile.TerminalError(nil))
+}
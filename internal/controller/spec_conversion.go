@@ -0,0 +1,77 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// handleSpecConversion converts, in memory, the spec of a ClusterInstance stored at an older schema version to the
+// current one, as reported by the SpecConverted condition. The stored spec is left untouched. The reconcile is stopped
+// for a spec at a newer, unsupported, schema version, e.g. written for a newer controller.
+func (r *ClusterInstanceReconciler) handleSpecConversion(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+) (ctrl.Result, bool, error) {
+	original := clusterInstance.DeepCopy()
+	from, convertErr := clusterInstance.ConvertSpec()
+
+	// The condition is patched from the original ClusterInstance, which does not carry the in-memory conversion
+	updated := original.DeepCopy()
+	switch {
+	case convertErr != nil:
+		r.Log.Info("Unable to convert the ClusterInstance spec", "ClusterInstance", clusterInstance.Name,
+			"error", convertErr.Error())
+		conditions.SetStatusCondition(&updated.Status.Conditions,
+			conditions.SpecConverted,
+			conditions.Failed,
+			metav1.ConditionFalse,
+			fmt.Sprintf("Unable to convert the spec: %s", convertErr.Error()))
+	case from < v1alpha1.SchemaVersion:
+		message := fmt.Sprintf("Spec converted from schema version %d to %d", from, v1alpha1.SchemaVersion)
+		r.Log.Info(message, "ClusterInstance", clusterInstance.Name)
+		conditions.SetStatusCondition(&updated.Status.Conditions,
+			conditions.SpecConverted,
+			conditions.Completed,
+			metav1.ConditionTrue,
+			message)
+	default:
+		meta.RemoveStatusCondition(&updated.Status.Conditions, string(conditions.SpecConverted))
+	}
+
+	if conditions.StatusChanged(&original.Status, &updated.Status) {
+		if err := conditions.PatchCIStatus(ctx, r.Client, updated, client.MergeFrom(original)); err != nil {
+			return ctrl.Result{}, true, err
+		}
+		clusterInstance.ResourceVersion = updated.ResourceVersion
+		clusterInstance.Status = updated.Status
+	}
+
+	// Retrying does not convert the spec, wait for the spec, or the controller, to be updated
+	if convertErr != nil {
+		return waitForEvent(), true, &TerminalError{Err: fmt.Errorf("failed to convert the spec: %w", convertErr)}
+	}
+	return completed(), false, nil
+}
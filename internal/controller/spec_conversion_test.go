@@ -0,0 +1,91 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("handleSpecConversion", func() {
+	var (
+		c               client.Client
+		r               *ClusterInstanceReconciler
+		ctx             = context.Background()
+		clusterInstance *v1alpha1.ClusterInstance
+	)
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		r = &ClusterInstanceReconciler{
+			Client: c,
+			Scheme: scheme.Scheme,
+			Log:    ctrl.Log.WithName("ClusterInstanceReconciler"),
+		}
+
+		clusterInstance = &v1alpha1.ClusterInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-cluster"},
+			Spec:       v1alpha1.ClusterInstanceSpec{ClusterName: "test-cluster"},
+		}
+	})
+
+	It("proceeds with a ClusterInstance at the current schema version", func() {
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+		clusterInstance.Status.Conditions = []metav1.Condition{{
+			Type: string(conditions.SpecConverted), Status: metav1.ConditionFalse,
+			Reason: string(conditions.Failed), LastTransitionTime: metav1.Now()}}
+		Expect(c.Status().Update(ctx, clusterInstance)).To(Succeed())
+
+		_, stop, err := r.handleSpecConversion(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stop).To(BeFalse())
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		Expect(conditions.FindStatusCondition(clusterInstance.Status.Conditions,
+			string(conditions.SpecConverted))).To(BeNil())
+	})
+
+	It("stops the reconcile of a ClusterInstance at a newer schema version", func() {
+		clusterInstance.Annotations = map[string]string{v1alpha1.SchemaVersionAnnotation: "99"}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+
+		_, stop, err := r.handleSpecConversion(ctx, clusterInstance)
+		Expect(err).To(HaveOccurred())
+		Expect(isTerminal(err)).To(BeTrue())
+		Expect(stop).To(BeTrue())
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), clusterInstance)).To(Succeed())
+		cond := conditions.FindStatusCondition(clusterInstance.Status.Conditions, string(conditions.SpecConverted))
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Reason).To(Equal(string(conditions.Failed)))
+		Expect(cond.Message).To(Equal(
+			"Unable to convert the spec: schema version 99 is newer than the supported schema version 1"))
+	})
+})
@@ -0,0 +1,234 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// templateRefName returns the <namespace>/<name> of the TemplateRef
+func templateRefName(templateRef v1alpha1.TemplateRef) string {
+	return types.NamespacedName{Namespace: templateRef.Namespace, Name: templateRef.Name}.String()
+}
+
+// selectTemplates returns the TemplateRefs of the template ConfigMaps of the namespace matching the selector, sorted by
+// name so that the templates are merged in a deterministic order, along with the template keys of each ConfigMap
+func selectTemplates(
+	ctx context.Context,
+	c client.Client,
+	namespace string,
+	selector labels.Selector,
+) ([]v1alpha1.TemplateRef, map[v1alpha1.TemplateRef][]string, error) {
+	configMaps := &corev1.ConfigMapList{}
+	if err := c.List(ctx, configMaps, client.InNamespace(namespace),
+		client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, nil, err
+	}
+	sort.Slice(configMaps.Items, func(i, j int) bool {
+		return configMaps.Items[i].Name < configMaps.Items[j].Name
+	})
+
+	templateRefs := make([]v1alpha1.TemplateRef, 0, len(configMaps.Items))
+	templateKeys := map[v1alpha1.TemplateRef][]string{}
+	for _, configMap := range configMaps.Items {
+		templateRef := v1alpha1.TemplateRef{Name: configMap.Name, Namespace: configMap.Namespace}
+		templateRefs = append(templateRefs, templateRef)
+		for key := range configMap.Data {
+			templateKeys[templateRef] = append(templateKeys[templateRef], key)
+		}
+	}
+	return templateRefs, templateKeys, nil
+}
+
+// ambiguousTemplates returns, for each template key defined by more than one of the given templates with at least one
+// of them selected, the sorted <namespace>/<name> of the templates defining it. The keys of the referenced templates
+// are fetched, those that do not exist are reported by handleMissingTemplateRefs.
+func ambiguousTemplates(
+	ctx context.Context,
+	c client.Client,
+	templateRefs []v1alpha1.TemplateRef,
+	selectedKeys map[v1alpha1.TemplateRef][]string,
+) (map[string][]string, error) {
+	templateKeys := map[v1alpha1.TemplateRef][]string{}
+	for templateRef, keys := range selectedKeys {
+		templateKeys[templateRef] = keys
+	}
+	for _, templateRef := range templateRefs {
+		if _, ok := templateKeys[templateRef]; ok {
+			continue
+		}
+		configMap := &corev1.ConfigMap{}
+		if err := c.Get(ctx, types.NamespacedName{Name: templateRef.Name, Namespace: templateRef.Namespace},
+			configMap); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		for key := range configMap.Data {
+			templateKeys[templateRef] = append(templateKeys[templateRef], key)
+		}
+	}
+
+	definedBy := map[string][]v1alpha1.TemplateRef{}
+	for templateRef, keys := range templateKeys {
+		for _, key := range keys {
+			definedBy[key] = append(definedBy[key], templateRef)
+		}
+	}
+	ambiguous := map[string][]string{}
+	for key, refs := range definedBy {
+		if len(refs) < 2 || !slices.ContainsFunc(refs, func(templateRef v1alpha1.TemplateRef) bool {
+			_, selected := selectedKeys[templateRef]
+			return selected
+		}) {
+			continue
+		}
+		names := make([]string, 0, len(refs))
+		for _, templateRef := range refs {
+			names = append(names, templateRefName(templateRef))
+		}
+		sort.Strings(names)
+		ambiguous[key] = names
+	}
+	return ambiguous, nil
+}
+
+// handleTemplateSelector resolves the TemplateSelector of the ClusterInstance and appends the selected templates,
+// sorted by name, to its cluster-level TemplateRefs. The selected templates are only set in memory, they are not
+// persisted to the ClusterInstance spec. The resolution is reported in the TemplateSelectorResolved condition, it
+// returns true when no template is selected, the selector is invalid or the same template key is defined by more than
+// one of the templates, in which case the reconcile should stop.
+func (r *ClusterInstanceReconciler) handleTemplateSelector(
+	ctx context.Context,
+	clusterInstance *v1alpha1.ClusterInstance,
+) (ctrl.Result, bool, error) {
+	original := clusterInstance.DeepCopy()
+	patch := client.MergeFrom(original)
+	updated := original.DeepCopy()
+
+	templateSelector := clusterInstance.Spec.TemplateSelector
+	if templateSelector == nil {
+		if meta.RemoveStatusCondition(&updated.Status.Conditions, string(conditions.TemplateSelectorResolved)) {
+			if err := conditions.PatchCIStatus(ctx, r.Client, updated, patch); err != nil {
+				return ctrl.Result{}, true, err
+			}
+			clusterInstance.ResourceVersion = updated.ResourceVersion
+			clusterInstance.Status = updated.Status
+		}
+		return completed(), false, nil
+	}
+
+	var (
+		res     = completed()
+		stop    = true
+		reason  conditions.ConditionReason
+		status  = metav1.ConditionFalse
+		message string
+	)
+	selector, selectorErr := metav1.LabelSelectorAsSelector(&templateSelector.LabelSelector)
+	var selected []v1alpha1.TemplateRef
+	var selectedKeys map[v1alpha1.TemplateRef][]string
+	if selectorErr == nil {
+		var err error
+		if selected, selectedKeys, err = selectTemplates(ctx, r.Client, templateSelector.Namespace,
+			selector); err != nil {
+			return ctrl.Result{}, true, err
+		}
+	}
+
+	switch {
+	case selectorErr != nil:
+		// Fixing the selector changes the spec, which triggers a new reconcile
+		reason = conditions.SelectorInvalid
+		message = fmt.Sprintf("Invalid templateSelector: %s", selectorErr.Error())
+		res = waitForEvent()
+	case len(selected) == 0:
+		// The template ConfigMaps are not watched, re-check them periodically
+		reason = conditions.NoTemplatesSelected
+		message = fmt.Sprintf("No template ConfigMaps in namespace %s match the templateSelector",
+			templateSelector.Namespace)
+		res = requeueAfter(missingReferencesRequeueInterval)
+	default:
+		ambiguous, err := ambiguousTemplates(ctx, r.Client, clusterInstance.Spec.TemplateRefs, selectedKeys)
+		if err != nil {
+			return ctrl.Result{}, true, err
+		}
+		if len(ambiguous) > 0 {
+			keys := make([]string, 0, len(ambiguous))
+			for key := range ambiguous {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			conflicts := make([]string, 0, len(keys))
+			for _, key := range keys {
+				conflicts = append(conflicts, fmt.Sprintf("%s (%s)", key, strings.Join(ambiguous[key], ", ")))
+			}
+			reason = conditions.AmbiguousTemplates
+			message = fmt.Sprintf("Templates defined more than once: %s", strings.Join(conflicts, "; "))
+			res = requeueAfter(missingReferencesRequeueInterval)
+			break
+		}
+
+		names := make([]string, 0, len(selected))
+		for _, templateRef := range selected {
+			names = append(names, templateRefName(templateRef))
+			if !slices.Contains(clusterInstance.Spec.TemplateRefs, templateRef) {
+				clusterInstance.Spec.TemplateRefs = append(clusterInstance.Spec.TemplateRefs, templateRef)
+			}
+		}
+		reason, status, stop = conditions.Completed, metav1.ConditionTrue, false
+		message = fmt.Sprintf("Selected templates: %s", strings.Join(names, ", "))
+	}
+
+	if existing := meta.FindStatusCondition(original.Status.Conditions,
+		string(conditions.TemplateSelectorResolved)); existing != nil &&
+		existing.Status == status && existing.Reason == string(reason) && existing.Message == message {
+		return res, stop, nil
+	}
+	if stop {
+		r.Log.Info(message, "ClusterInstance", clusterInstance.Name)
+	}
+
+	// The condition is patched from the original ClusterInstance, which does not carry the selected templates
+	conditions.SetStatusCondition(&updated.Status.Conditions,
+		conditions.TemplateSelectorResolved,
+		reason,
+		status,
+		message)
+	if err := conditions.PatchCIStatus(ctx, r.Client, updated, patch); err != nil {
+		return ctrl.Result{}, true, err
+	}
+	clusterInstance.ResourceVersion = updated.ResourceVersion
+	clusterInstance.Status = updated.Status
+	return res, stop, nil
+}
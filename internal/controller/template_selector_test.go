@@ -0,0 +1,198 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stolostron/siteconfig/api/v1alpha1"
+	"github.com/stolostron/siteconfig/internal/controller/conditions"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("handleTemplateSelector", func() {
+	var (
+		c               client.Client
+		r               *ClusterInstanceReconciler
+		ctx             = context.Background()
+		clusterInstance *v1alpha1.ClusterInstance
+		clusterTemplate = map[string]string{"role": "cluster-template"}
+	)
+
+	createTemplate := func(name string, labels map[string]string, keys ...string) {
+		data := map[string]string{}
+		for _, key := range keys {
+			data[key] = "apiVersion: v1"
+		}
+		Expect(c.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "templates", Labels: labels},
+			Data:       data,
+		})).To(Succeed())
+	}
+
+	selectorCondition := func() *metav1.Condition {
+		stored := &v1alpha1.ClusterInstance{}
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), stored)).To(Succeed())
+		return meta.FindStatusCondition(stored.Status.Conditions, string(conditions.TemplateSelectorResolved))
+	}
+
+	BeforeEach(func() {
+		c = fakeclient.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithStatusSubresource(&v1alpha1.ClusterInstance{}).
+			Build()
+		r = &ClusterInstanceReconciler{
+			Client: c,
+			Scheme: scheme.Scheme,
+			Log:    ctrl.Log.WithName("ClusterInstanceReconciler"),
+		}
+
+		clusterInstance = &v1alpha1.ClusterInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-cluster"},
+			Spec: v1alpha1.ClusterInstanceSpec{
+				ClusterName: "test-cluster",
+				TemplateSelector: &v1alpha1.TemplateSelector{
+					Namespace:     "templates",
+					LabelSelector: metav1.LabelSelector{MatchLabels: clusterTemplate},
+				},
+			},
+		}
+		Expect(c.Create(ctx, clusterInstance)).To(Succeed())
+	})
+
+	It("appends the selected templates to the TemplateRefs in the order of their names", func() {
+		createTemplate("site-extras", clusterTemplate, "ConfigMap")
+		createTemplate("ai-cluster", clusterTemplate, "ClusterDeployment", "AgentClusterInstall")
+		createTemplate("ai-node", map[string]string{"role": "node-template"}, "BareMetalHost")
+		createTemplate("manual", nil, "KlusterletAddonConfig")
+		clusterInstance.Spec.TemplateRefs = []v1alpha1.TemplateRef{{Name: "manual", Namespace: "templates"}}
+
+		res, stop, err := r.handleTemplateSelector(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stop).To(BeFalse())
+		Expect(res).To(Equal(completed()))
+		Expect(clusterInstance.Spec.TemplateRefs).To(Equal([]v1alpha1.TemplateRef{
+			{Name: "manual", Namespace: "templates"},
+			{Name: "ai-cluster", Namespace: "templates"},
+			{Name: "site-extras", Namespace: "templates"},
+		}))
+
+		cond := selectorCondition()
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(cond.Reason).To(Equal(string(conditions.Completed)))
+		Expect(cond.Message).To(Equal("Selected templates: templates/ai-cluster, templates/site-extras"))
+
+		// The selected templates are not persisted to the spec
+		stored := &v1alpha1.ClusterInstance{}
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clusterInstance), stored)).To(Succeed())
+		Expect(stored.Spec.TemplateRefs).To(BeEmpty())
+	})
+
+	It("resolves the same TemplateRefs on every reconcile", func() {
+		for _, name := range []string{"c-templates", "a-templates", "b-templates"} {
+			createTemplate(name, clusterTemplate, name)
+		}
+		// A template both referenced and selected is only rendered once
+		referenced := []v1alpha1.TemplateRef{{Name: "b-templates", Namespace: "templates"}}
+
+		var resolved [][]v1alpha1.TemplateRef
+		for i := 0; i < 3; i++ {
+			clusterInstance.Spec.TemplateRefs = append([]v1alpha1.TemplateRef{}, referenced...)
+			_, stop, err := r.handleTemplateSelector(ctx, clusterInstance)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stop).To(BeFalse())
+			resolved = append(resolved, clusterInstance.Spec.TemplateRefs)
+		}
+		Expect(resolved[0]).To(Equal([]v1alpha1.TemplateRef{
+			{Name: "b-templates", Namespace: "templates"},
+			{Name: "a-templates", Namespace: "templates"},
+			{Name: "c-templates", Namespace: "templates"},
+		}))
+		Expect(resolved[1]).To(Equal(resolved[0]))
+		Expect(resolved[2]).To(Equal(resolved[0]))
+	})
+
+	It("stops when no template is selected", func() {
+		createTemplate("ai-node", map[string]string{"role": "node-template"}, "BareMetalHost")
+
+		res, stop, err := r.handleTemplateSelector(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stop).To(BeTrue())
+		Expect(res).To(Equal(requeueAfter(missingReferencesRequeueInterval)))
+
+		cond := selectorCondition()
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Reason).To(Equal(string(conditions.NoTemplatesSelected)))
+	})
+
+	It("stops when the same template key is defined by more than one template", func() {
+		createTemplate("ai-cluster", clusterTemplate, "ClusterDeployment", "AgentClusterInstall")
+		createTemplate("ai-cluster-v2", clusterTemplate, "ClusterDeployment")
+		createTemplate("manual", nil, "AgentClusterInstall")
+		clusterInstance.Spec.TemplateRefs = []v1alpha1.TemplateRef{{Name: "manual", Namespace: "templates"}}
+
+		res, stop, err := r.handleTemplateSelector(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stop).To(BeTrue())
+		Expect(res).To(Equal(requeueAfter(missingReferencesRequeueInterval)))
+
+		cond := selectorCondition()
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Reason).To(Equal(string(conditions.AmbiguousTemplates)))
+		Expect(cond.Message).To(Equal("Templates defined more than once: " +
+			"AgentClusterInstall (templates/ai-cluster, templates/manual); " +
+			"ClusterDeployment (templates/ai-cluster, templates/ai-cluster-v2)"))
+	})
+
+	It("stops when the selector is invalid", func() {
+		clusterInstance.Spec.TemplateSelector.LabelSelector = metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "role", Operator: "Matches"}},
+		}
+
+		res, stop, err := r.handleTemplateSelector(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stop).To(BeTrue())
+		Expect(res).To(Equal(waitForEvent()))
+
+		cond := selectorCondition()
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Reason).To(Equal(string(conditions.SelectorInvalid)))
+	})
+
+	It("removes the condition once the selector is removed", func() {
+		_, _, err := r.handleTemplateSelector(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(selectorCondition()).ToNot(BeNil())
+
+		clusterInstance.Spec.TemplateSelector = nil
+		_, stop, err := r.handleTemplateSelector(ctx, clusterInstance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stop).To(BeFalse())
+		Expect(selectorCondition()).To(BeNil())
+	})
+})
@@ -29,6 +29,9 @@ spec:
   clusterDeploymentRef:
     name: "{{ .Spec.ClusterName }}"
   holdInstallation: {{ .Spec.HoldInstallation }}
+{{ if .Spec.PlatformType }}
+  platformType: "{{ .Spec.PlatformType }}"
+{{ end }}
   imageSetRef:
     name: "{{ .Spec.ClusterImageSetNameRef }}"
 {{ if .Spec.ApiVIPs }}
@@ -59,6 +62,10 @@ spec:
   provisionRequirements:
     controlPlaneAgents: {{ .SpecialVars.ControlPlaneAgents }}
     workerAgents: {{ .SpecialVars.WorkerAgents }}
+{{ if .SpecialVars.DiskEncryption }}
+  diskEncryption:
+{{ .SpecialVars.DiskEncryption | toYaml | indent 4 }}
+{{ end }}
 {{ if .Spec.Proxy }}
   proxy:
 {{ .Spec.Proxy | toYaml | indent 4 }}
@@ -89,6 +96,12 @@ spec:
       agentSelector:
         matchLabels:
           cluster-name: "{{ .Spec.ClusterName }}"
+{{ if .Spec.InstallAttemptsLimit }}
+  installAttemptsLimit: {{ .Spec.InstallAttemptsLimit }}
+{{ end }}
+{{ if .Spec.PreserveOnDelete }}
+  preserveOnDelete: true
+{{ end }}
   pullSecretRef:
     name: "{{ .Spec.PullSecretRef.Name }}"`
 
@@ -113,9 +126,14 @@ spec:
   ignitionConfigOverride: '{{ .Spec.IgnitionConfigOverride }}'
   nmStateConfigLabelSelector:
     matchLabels:
-      nmstate-label: "{{ .Spec.ClusterName }}"
+      nmstate-label: "{{ .SpecialVars.InfraEnvName }}"
   additionalNTPSources:
-{{ .Spec.AdditionalNTPSources | toYaml | indent 4 }}`
+{{ .Spec.AdditionalNTPSources | toYaml | indent 4 }}
+{{ if .SpecialVars.RootFSURL }}
+  kernelArguments:
+    - operation: append
+      value: "coreos.live.rootfs_url={{ .SpecialVars.RootFSURL }}"
+{{ end }}`
 
 const KlusterletAddonConfig = `apiVersion: agent.open-cluster-management.io/v1
 kind: KlusterletAddonConfig
@@ -149,7 +167,7 @@ metadata:
   name: "{{ .SpecialVars.CurrentNode.HostName }}"
   namespace: "{{ .Spec.ClusterName }}"
   labels:
-    nmstate-label: "{{ .Spec.ClusterName }}"
+    nmstate-label: "{{ .SpecialVars.InfraEnvName }}"
 spec:
   config:
 {{ .SpecialVars.CurrentNode.NodeNetwork.NetConfig | toYaml | indent 4}}
@@ -175,9 +193,8 @@ metadata:
   annotations:
     siteconfig.open-cluster-management.io/sync-wave: "1"
     inspect.metal3.io: "{{ .SpecialVars.CurrentNode.IronicInspect }}"
-{{ if .SpecialVars.CurrentNode.NodeLabels }}
-    bmac.agent-install.openshift.io.node-label:
-{{ .SpecialVars.CurrentNode.NodeLabels | toYaml | indent 6 }}
+{{ range $key, $value := .SpecialVars.CurrentNode.NodeLabels }}
+    bmac.agent-install.openshift.io.node-label.{{ $key }}: {{ $value | quote }}
 {{ end }}
     bmac.agent-install.openshift.io/hostname: "{{ .SpecialVars.CurrentNode.HostName }}"
 {{ if .SpecialVars.CurrentNode.InstallerArgs  }}
@@ -188,7 +205,7 @@ metadata:
 {{ end }}
     bmac.agent-install.openshift.io/role: "{{ .SpecialVars.CurrentNode.Role }}"
   labels:
-    infraenvs.agent-install.openshift.io: "{{ .Spec.ClusterName }}"
+    infraenvs.agent-install.openshift.io: "{{ .SpecialVars.InfraEnvName }}"
 spec:
   bootMode: "{{ .SpecialVars.CurrentNode.BootMode }}"
   bmc:
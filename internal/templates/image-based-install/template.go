@@ -70,6 +70,12 @@ spec:
       agentSelector:
         matchLabels:
           cluster-name: "{{ .Spec.ClusterName }}"
+{{ if .Spec.InstallAttemptsLimit }}
+  installAttemptsLimit: {{ .Spec.InstallAttemptsLimit }}
+{{ end }}
+{{ if .Spec.PreserveOnDelete }}
+  preserveOnDelete: true
+{{ end }}
   pullSecretRef:
     name: "{{ .Spec.PullSecretRef.Name }}"`
 
@@ -131,9 +137,8 @@ metadata:
   annotations:
     siteconfig.open-cluster-management.io/sync-wave: "1"
     inspect.metal3.io: "{{ .SpecialVars.CurrentNode.IronicInspect }}"
-{{ if .SpecialVars.CurrentNode.NodeLabels }}
-    bmac.agent-install.openshift.io.node-label:
-{{ .SpecialVars.CurrentNode.NodeLabels | toYaml | indent 6 }}
+{{ range $key, $value := .SpecialVars.CurrentNode.NodeLabels }}
+    bmac.agent-install.openshift.io.node-label.{{ $key }}: {{ $value | quote }}
 {{ end }}
     bmac.agent-install.openshift.io/hostname: "{{ .SpecialVars.CurrentNode.HostName }}"
 {{ if .SpecialVars.CurrentNode.InstallerArgs  }}